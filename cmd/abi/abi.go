@@ -1,7 +1,6 @@
 package abi
 
 import (
-	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -11,15 +10,33 @@ import (
 
 	_ "embed"
 
-	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/spf13/cobra"
 )
 
+type (
+	// abiResult is what's printed with --json.
+	abiResult struct {
+		Methods []abiMethod    `json:"methods"`
+		Input   map[string]any `json:"input,omitempty"`
+	}
+	abiMethod struct {
+		Selector  string `json:"selector"`
+		Signature string `json:"signature"`
+	}
+)
+
 var (
 	//go:embed usage.md
-	usage         string
-	inputFileName *string
-	inputData     *string
+	usage                string
+	inputFileName        *string
+	inputData            *string
+	inputABI             *string
+	inputAddress         *string
+	inputChainID         *uint64
+	inputSourcifyURL     *string
+	inputEtherscanURL    *string
+	inputEtherscanAPIKey *string
 )
 
 var ABICmd = &cobra.Command{
@@ -27,18 +44,16 @@ var ABICmd = &cobra.Command{
 	Short: "Parse an ABI and print the encoded signatures.",
 	Long:  usage,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// it would be nice to have a generic reader
-
-		rawData, err := getInputData(cmd, args)
-		if err != nil {
-			return err
-		}
-		buf := bytes.NewReader(rawData)
-		abi, err := gethabi.JSON(buf)
+		abi, err := loadABI(cmd.Context(), cmd, args)
 		if err != nil {
 			return err
 		}
+		var result abiResult
 		for _, meth := range abi.Methods {
+			if util.JSONOutput() {
+				result.Methods = append(result.Methods, abiMethod{Selector: hex.EncodeToString(meth.ID), Signature: meth.String()})
+				continue
+			}
 			fmt.Printf("Selector:%s\tSignature:%s\n", hex.EncodeToString(meth.ID), meth)
 		}
 		if *inputData != "" {
@@ -58,9 +73,16 @@ var ABICmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
-			fmt.Println("Input data:")
-			prettyInput, _ := json.MarshalIndent(inputVals, "", "  ")
-			fmt.Println(string(prettyInput))
+			if util.JSONOutput() {
+				result.Input = inputVals
+			} else {
+				fmt.Println("Input data:")
+				prettyInput, _ := json.MarshalIndent(inputVals, "", "  ")
+				fmt.Println(string(prettyInput))
+			}
+		}
+		if util.JSONOutput() {
+			return util.PrintJSON(result)
 		}
 		return nil
 	},
@@ -73,6 +95,15 @@ func init() {
 	flagSet := ABICmd.PersistentFlags()
 	inputFileName = flagSet.String("file", "", "Provide a filename to read and analyze")
 	inputData = flagSet.String("data", "", "Provide input data to be unpacked based on the ABI definition")
+	inputABI = flagSet.String("abi", "", "Provide the ABI as inline JSON")
+	inputAddress = flagSet.String("address", "", "Fetch the ABI of this verified contract from Sourcify or an Etherscan compatible API")
+	inputChainID = flagSet.Uint64("chain-id", 1, "The chain ID of the contract when fetching the ABI")
+	inputSourcifyURL = flagSet.String("sourcify-url", "https://sourcify.dev/server", "The Sourcify server used to fetch the ABI")
+	inputEtherscanURL = flagSet.String("etherscan-url", "", "The Etherscan compatible API used to fetch the ABI instead of Sourcify, e.g. https://api.etherscan.io/v2/api")
+	inputEtherscanAPIKey = flagSet.String("etherscan-api-key", "", "The API key for the Etherscan compatible API")
+
+	ABICmd.AddCommand(ABIDecodeCmd)
+	ABICmd.AddCommand(ABIEncodeCmd)
 }
 
 func parseContractInputData(data string) ([]byte, []byte, error) {
@@ -0,0 +1,64 @@
+package abi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/spf13/cobra"
+)
+
+var inputTopics *[]string
+
+var ABIDecodeCmd = &cobra.Command{
+	Use:   "decode 0xdata",
+	Short: "Decode calldata, an event log, or a revert error.",
+	Long: `Decode calldata, an event log, or a revert error using the ABI.
+
+The data is decoded as calldata if its selector matches a function or as a
+revert error if it matches an error. The standard Error(string) and
+Panic(uint256) errors are always decoded. If --topic is set, the data is
+decoded as the data of an event log with the given topics.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := hexutil.Decode(args[0])
+		if err != nil {
+			return fmt.Errorf("unable to decode the data: %w", err)
+		}
+		abi, err := loadABI(cmd.Context(), cmd, nil)
+		if err != nil {
+			return err
+		}
+
+		var result *util.DecodedABIData
+		if len(*inputTopics) > 0 {
+			topics := make([]common.Hash, 0, len(*inputTopics))
+			for _, t := range *inputTopics {
+				b, err := hexutil.Decode(t)
+				if err != nil || len(b) != common.HashLength {
+					return fmt.Errorf("the topic %s isn't a 32 byte hex value", t)
+				}
+				topics = append(topics, common.BytesToHash(b))
+			}
+			result, err = util.DecodeLog(abi, topics, data)
+		} else {
+			result, err = util.DecodeCallOrError(abi, data)
+		}
+		if err != nil {
+			return err
+		}
+
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+func init() {
+	inputTopics = ABIDecodeCmd.Flags().StringSlice("topic", nil, "The topics of an event log, starting with the event signature")
+}
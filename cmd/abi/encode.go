@@ -0,0 +1,186 @@
+package abi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/spf13/cobra"
+)
+
+var ABIEncodeCmd = &cobra.Command{
+	Use:   "encode method [args...]",
+	Short: "Encode a function call from the arguments.",
+	Long: `Encode a function call from the arguments using the ABI.
+
+The method is either a function name or, for overloaded functions, the full
+signature such as "transfer(address,uint256)". Numbers can be decimal or hex,
+bytes are hex, and arrays and tuples are JSON arrays such as '["0x01","0x02"]'.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		abi, err := loadABI(cmd.Context(), cmd, nil)
+		if err != nil {
+			return err
+		}
+
+		method, err := findMethod(abi, args[0])
+		if err != nil {
+			return err
+		}
+		if len(args)-1 != len(method.Inputs) {
+			return fmt.Errorf("%s takes %d arguments but %d were given", method.Sig, len(method.Inputs), len(args)-1)
+		}
+
+		values := make([]interface{}, 0, len(method.Inputs))
+		for i, input := range method.Inputs {
+			v, err := parseArg(input.Type, args[i+1])
+			if err != nil {
+				return fmt.Errorf("invalid argument %s: %w", input.Name, err)
+			}
+			values = append(values, v.Interface())
+		}
+
+		packed, err := method.Inputs.Pack(values...)
+		if err != nil {
+			return err
+		}
+		data := hexutil.Encode(append(method.ID, packed...))
+		if util.JSONOutput() {
+			return util.PrintJSON(struct {
+				Data string `json:"data"`
+			}{data})
+		}
+		fmt.Println(data)
+		return nil
+	},
+}
+
+// findMethod looks up the method by its name or signature.
+func findMethod(abi *gethabi.ABI, name string) (*gethabi.Method, error) {
+	if m, ok := abi.Methods[name]; ok {
+		return &m, nil
+	}
+	sig := strings.ReplaceAll(name, " ", "")
+	for _, m := range abi.Methods {
+		if m.Sig == sig {
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("the method %s wasn't found in the given abi", name)
+}
+
+// parseArg converts a command line argument to the Go type that the abi
+// package expects for the type.
+func parseArg(t gethabi.Type, arg string) (reflect.Value, error) {
+	switch t.T {
+	case gethabi.SliceTy, gethabi.ArrayTy, gethabi.TupleTy:
+		d := json.NewDecoder(strings.NewReader(arg))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return reflect.Value{}, fmt.Errorf("%s must be a JSON array: %w", t, err)
+		}
+		return convertArg(t, v)
+	}
+	return convertArg(t, arg)
+}
+
+func convertArg(t gethabi.Type, v interface{}) (reflect.Value, error) {
+	switch t.T {
+	case gethabi.IntTy, gethabi.UintTy:
+		n, ok := new(big.Int).SetString(fmt.Sprint(v), 0)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%v isn't a number", v)
+		}
+		if t.T == gethabi.UintTy && n.Sign() < 0 {
+			return reflect.Value{}, fmt.Errorf("%v is negative", v)
+		}
+		if !fits(n, t) {
+			return reflect.Value{}, fmt.Errorf("%v doesn't fit in %s", v, t)
+		}
+		if t.Size > 64 {
+			return reflect.ValueOf(n), nil
+		}
+		if t.T == gethabi.UintTy {
+			return reflect.ValueOf(n.Uint64()).Convert(t.GetType()), nil
+		}
+		return reflect.ValueOf(n.Int64()).Convert(t.GetType()), nil
+	case gethabi.BoolTy:
+		b, err := strconv.ParseBool(fmt.Sprint(v))
+		return reflect.ValueOf(b), err
+	case gethabi.StringTy:
+		return reflect.ValueOf(fmt.Sprint(v)), nil
+	case gethabi.AddressTy:
+		s := fmt.Sprint(v)
+		if !common.IsHexAddress(s) {
+			return reflect.Value{}, fmt.Errorf("%s isn't an address", s)
+		}
+		return reflect.ValueOf(common.HexToAddress(s)), nil
+	case gethabi.BytesTy:
+		b, err := hexutil.Decode(fmt.Sprint(v))
+		return reflect.ValueOf(b), err
+	case gethabi.FixedBytesTy, gethabi.FunctionTy:
+		b, err := hexutil.Decode(fmt.Sprint(v))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(t.GetType()).Elem()
+		if len(b) > out.Len() {
+			return reflect.Value{}, fmt.Errorf("%v is longer than %d bytes", v, out.Len())
+		}
+		reflect.Copy(out, reflect.ValueOf(b))
+		return out, nil
+	case gethabi.SliceTy, gethabi.ArrayTy:
+		elems, ok := v.([]interface{})
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s must be an array", t)
+		}
+		var out reflect.Value
+		if t.T == gethabi.SliceTy {
+			out = reflect.MakeSlice(t.GetType(), len(elems), len(elems))
+		} else if len(elems) != t.Size {
+			return reflect.Value{}, fmt.Errorf("%s needs %d elements but %d were given", t, t.Size, len(elems))
+		} else {
+			out = reflect.New(t.GetType()).Elem()
+		}
+		for i, e := range elems {
+			ev, err := convertArg(*t.Elem, e)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(ev)
+		}
+		return out, nil
+	case gethabi.TupleTy:
+		elems, ok := v.([]interface{})
+		if !ok || len(elems) != len(t.TupleElems) {
+			return reflect.Value{}, fmt.Errorf("%s must be an array of %d elements", t, len(t.TupleElems))
+		}
+		out := reflect.New(t.GetType()).Elem()
+		for i, e := range elems {
+			ev, err := convertArg(*t.TupleElems[i], e)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Field(i).Set(ev)
+		}
+		return out, nil
+	}
+	return reflect.Value{}, fmt.Errorf("the type %s isn't supported", t)
+}
+
+// fits returns true if the number is in the range of the integer type.
+func fits(n *big.Int, t gethabi.Type) bool {
+	if t.T == gethabi.UintTy {
+		return n.BitLen() <= t.Size
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+	return n.Cmp(limit) < 0 && n.Cmp(new(big.Int).Neg(limit)) >= 0
+}
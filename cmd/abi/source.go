@@ -0,0 +1,46 @@
+package abi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/spf13/cobra"
+)
+
+// loadABI parses the ABI from the first source that's set. An inline ABI is
+// used first, then the ABI file, then the ABI verified for the contract
+// address, and finally the arguments or stdin.
+func loadABI(ctx context.Context, cmd *cobra.Command, args []string) (*gethabi.ABI, error) {
+	var (
+		rawData []byte
+		err     error
+	)
+	switch {
+	case *inputABI != "":
+		rawData = []byte(*inputABI)
+	case *inputFileName != "":
+		rawData, err = getInputData(cmd, args)
+	case *inputAddress != "":
+		source := util.ABISource{
+			ChainID:         *inputChainID,
+			SourcifyURL:     *inputSourcifyURL,
+			EtherscanURL:    *inputEtherscanURL,
+			EtherscanAPIKey: *inputEtherscanAPIKey,
+		}
+		rawData, err = source.FetchABI(ctx, *inputAddress)
+	default:
+		rawData, err = getInputData(cmd, args)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	abi, err := gethabi.JSON(bytes.NewReader(rawData))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the abi: %w", err)
+	}
+	return &abi, nil
+}
@@ -0,0 +1,535 @@
+package blockfetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	blockFetchParams struct {
+		RPCURL string
+	}
+
+	rpcAccessTuple struct {
+		Address     common.Address `json:"address"`
+		StorageKeys []common.Hash  `json:"storageKeys"`
+	}
+	rpcAuthorization struct {
+		ChainID *hexutil.Big   `json:"chainId"`
+		Address common.Address `json:"address"`
+		Nonce   hexutil.Uint64 `json:"nonce"`
+		YParity hexutil.Uint64 `json:"yParity"`
+		R       *hexutil.Big   `json:"r"`
+		S       *hexutil.Big   `json:"s"`
+	}
+	rpcTransaction struct {
+		Type                 *hexutil.Uint64    `json:"type"`
+		Hash                 common.Hash        `json:"hash"`
+		From                 common.Address     `json:"from"`
+		To                   *common.Address    `json:"to"`
+		Nonce                hexutil.Uint64     `json:"nonce"`
+		Value                *hexutil.Big       `json:"value"`
+		Gas                  hexutil.Uint64     `json:"gas"`
+		GasPrice             *hexutil.Big       `json:"gasPrice"`
+		MaxFeePerGas         *hexutil.Big       `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas *hexutil.Big       `json:"maxPriorityFeePerGas"`
+		MaxFeePerBlobGas     *hexutil.Big       `json:"maxFeePerBlobGas"`
+		Input                hexutil.Bytes      `json:"input"`
+		ChainID              *hexutil.Big       `json:"chainId"`
+		AccessList           []rpcAccessTuple   `json:"accessList"`
+		BlobVersionedHashes  []common.Hash      `json:"blobVersionedHashes"`
+		AuthorizationList    []rpcAuthorization `json:"authorizationList"`
+		TransactionIndex     *hexutil.Uint64    `json:"transactionIndex"`
+		V                    *hexutil.Big       `json:"v"`
+		R                    *hexutil.Big       `json:"r"`
+		S                    *hexutil.Big       `json:"s"`
+	}
+	rpcWithdrawal struct {
+		Index          hexutil.Uint64 `json:"index"`
+		ValidatorIndex hexutil.Uint64 `json:"validatorIndex"`
+		Address        common.Address `json:"address"`
+		Amount         hexutil.Uint64 `json:"amount"`
+	}
+	// rpcBlock has the fields of every fork up to Prague. go-ethereum
+	// v1.10.26 doesn't know about the withdrawal and blob fields, so the
+	// block is decoded here instead of with ethclient. The hash, nonce, and
+	// miner are null for the pending block.
+	rpcBlock struct {
+		Number                hexutil.Uint64   `json:"number"`
+		Hash                  *common.Hash     `json:"hash"`
+		ParentHash            common.Hash      `json:"parentHash"`
+		Nonce                 *hexutil.Bytes   `json:"nonce"`
+		MixHash               common.Hash      `json:"mixHash"`
+		Sha3Uncles            common.Hash      `json:"sha3Uncles"`
+		Miner                 *common.Address  `json:"miner"`
+		StateRoot             common.Hash      `json:"stateRoot"`
+		TransactionsRoot      common.Hash      `json:"transactionsRoot"`
+		ReceiptsRoot          common.Hash      `json:"receiptsRoot"`
+		Difficulty            *hexutil.Big     `json:"difficulty"`
+		TotalDifficulty       *hexutil.Big     `json:"totalDifficulty"`
+		ExtraData             hexutil.Bytes    `json:"extraData"`
+		Size                  hexutil.Uint64   `json:"size"`
+		GasLimit              hexutil.Uint64   `json:"gasLimit"`
+		GasUsed               hexutil.Uint64   `json:"gasUsed"`
+		Timestamp             hexutil.Uint64   `json:"timestamp"`
+		BaseFeePerGas         *hexutil.Big     `json:"baseFeePerGas"`
+		WithdrawalsRoot       *common.Hash     `json:"withdrawalsRoot"`
+		Withdrawals           []rpcWithdrawal  `json:"withdrawals"`
+		BlobGasUsed           *hexutil.Uint64  `json:"blobGasUsed"`
+		ExcessBlobGas         *hexutil.Uint64  `json:"excessBlobGas"`
+		ParentBeaconBlockRoot *common.Hash     `json:"parentBeaconBlockRoot"`
+		RequestsHash          *common.Hash     `json:"requestsHash"`
+		Transactions          []rpcTransaction `json:"transactions"`
+		Uncles                []common.Hash    `json:"uncles"`
+	}
+
+	// decodedTransaction is a transaction with its amounts as numbers and the
+	// values derived from the block, which is what the JSON output has.
+	decodedTransaction struct {
+		Index                uint64             `json:"index"`
+		Hash                 common.Hash        `json:"hash"`
+		Type                 uint64             `json:"type"`
+		TypeName             string             `json:"typeName"`
+		From                 common.Address     `json:"from"`
+		To                   *common.Address    `json:"to"`
+		Nonce                uint64             `json:"nonce"`
+		Value                *big.Int           `json:"value"`
+		Gas                  uint64             `json:"gas"`
+		GasPrice             *big.Int           `json:"gasPrice,omitempty"`
+		MaxFeePerGas         *big.Int           `json:"maxFeePerGas,omitempty"`
+		MaxPriorityFeePerGas *big.Int           `json:"maxPriorityFeePerGas,omitempty"`
+		EffectiveGasPrice    *big.Int           `json:"effectiveGasPrice,omitempty"`
+		MaxFeePerBlobGas     *big.Int           `json:"maxFeePerBlobGas,omitempty"`
+		BlobVersionedHashes  []common.Hash      `json:"blobVersionedHashes,omitempty"`
+		ChainID              *big.Int           `json:"chainId,omitempty"`
+		AccessList           []rpcAccessTuple   `json:"accessList,omitempty"`
+		AuthorizationList    []rpcAuthorization `json:"authorizationList,omitempty"`
+		Selector             string             `json:"selector,omitempty"`
+		Input                hexutil.Bytes      `json:"input"`
+		V                    *big.Int           `json:"v,omitempty"`
+		R                    *big.Int           `json:"r,omitempty"`
+		S                    *big.Int           `json:"s,omitempty"`
+	}
+
+	// decodedWithdrawal is a withdrawal with its amount in wei, the
+	// consensus layer amount being in gwei.
+	decodedWithdrawal struct {
+		Index          uint64         `json:"index"`
+		ValidatorIndex uint64         `json:"validatorIndex"`
+		Address        common.Address `json:"address"`
+		Amount         *big.Int       `json:"amount"`
+	}
+
+	// decodedBlock is the block with its quantities as numbers, and the
+	// signer of Bor and Clique blocks recovered from the extra data.
+	decodedBlock struct {
+		Number                uint64               `json:"number"`
+		Hash                  *common.Hash         `json:"hash"`
+		ParentHash            common.Hash          `json:"parentHash"`
+		Timestamp             uint64               `json:"timestamp"`
+		Time                  time.Time            `json:"time"`
+		Miner                 *common.Address      `json:"miner"`
+		Signer                *common.Address      `json:"signer,omitempty"`
+		Difficulty            *big.Int             `json:"difficulty"`
+		TotalDifficulty       *big.Int             `json:"totalDifficulty,omitempty"`
+		Nonce                 *hexutil.Bytes       `json:"nonce"`
+		MixHash               common.Hash          `json:"mixHash"`
+		Size                  uint64               `json:"size"`
+		GasLimit              uint64               `json:"gasLimit"`
+		GasUsed               uint64               `json:"gasUsed"`
+		BaseFeePerGas         *big.Int             `json:"baseFeePerGas,omitempty"`
+		BurntFees             *big.Int             `json:"burntFees,omitempty"`
+		ExtraData             hexutil.Bytes        `json:"extraData"`
+		StateRoot             common.Hash          `json:"stateRoot"`
+		TransactionsRoot      common.Hash          `json:"transactionsRoot"`
+		ReceiptsRoot          common.Hash          `json:"receiptsRoot"`
+		Sha3Uncles            common.Hash          `json:"sha3Uncles"`
+		WithdrawalsRoot       *common.Hash         `json:"withdrawalsRoot,omitempty"`
+		BlobGasUsed           *uint64              `json:"blobGasUsed,omitempty"`
+		ExcessBlobGas         *uint64              `json:"excessBlobGas,omitempty"`
+		Blobs                 int                  `json:"blobs"`
+		ParentBeaconBlockRoot *common.Hash         `json:"parentBeaconBlockRoot,omitempty"`
+		RequestsHash          *common.Hash         `json:"requestsHash,omitempty"`
+		Uncles                []common.Hash        `json:"uncles"`
+		Withdrawals           []decodedWithdrawal  `json:"withdrawals,omitempty"`
+		Transactions          []decodedTransaction `json:"transactions"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage           string
+	inputBlockFetch blockFetchParams
+
+	// blockTags are the block tags accepted instead of a number or hash.
+	blockTags = []string{"earliest", "latest", "pending", "safe", "finalized"}
+)
+
+var BlockFetchCmd = &cobra.Command{
+	Use:   "blockfetch {number|hash|tag}",
+	Short: "Fetch a block and print it fully decoded.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected exactly one block number, hash, or tag")
+		}
+		if _, _, err := parseBlockID(args[0]); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		rpc, err := util.DialRPC(ctx, inputBlockFetch.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to dial rpc")
+			return err
+		}
+		defer rpc.Close()
+
+		block, err := fetchBlock(ctx, rpc, args[0])
+		if err != nil {
+			return err
+		}
+
+		if util.JSONOutput() {
+			out, err := json.MarshalIndent(block, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+		printBlock(block)
+		return nil
+	},
+}
+
+// parseBlockID returns the method and argument to fetch the block with. The
+// block is identified by a tag, a hash, or a decimal or hex number.
+func parseBlockID(id string) (string, interface{}, error) {
+	for _, tag := range blockTags {
+		if id == tag {
+			return "eth_getBlockByNumber", tag, nil
+		}
+	}
+	if strings.HasPrefix(id, "0x") && len(id) == 2+2*common.HashLength {
+		hash, err := hexutil.Decode(id)
+		if err != nil {
+			return "", nil, fmt.Errorf("the block hash %s is invalid: %w", id, err)
+		}
+		return "eth_getBlockByHash", common.BytesToHash(hash), nil
+	}
+
+	number, err := strconv.ParseUint(id, 0, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s isn't a block number, hash, or one of the tags %s", id, strings.Join(blockTags, ", "))
+	}
+	return "eth_getBlockByNumber", hexutil.Uint64(number), nil
+}
+
+func fetchBlock(ctx context.Context, rpc *ethrpc.Client, id string) (*decodedBlock, error) {
+	method, arg, err := parseBlockID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err = rpc.CallContext(ctx, &raw, method, arg, true); err != nil {
+		return nil, fmt.Errorf("unable to fetch the block: %w", err)
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, fmt.Errorf("the block %s wasn't found", id)
+	}
+
+	var block rpcBlock
+	if err = json.Unmarshal(raw, &block); err != nil {
+		return nil, fmt.Errorf("unable to decode the block: %w", err)
+	}
+
+	decoded := decodeBlock(&block)
+	signer, err := recoverSigner(raw)
+	if err != nil {
+		log.Debug().Err(err).Msg("Unable to recover the signer")
+	} else {
+		decoded.Signer = signer
+	}
+	return decoded, nil
+}
+
+// recoverSigner recovers the signer of a Bor or Clique block from the seal at
+// the end of the extra data. It fails for blocks without a seal.
+func recoverSigner(raw json.RawMessage) (*common.Address, error) {
+	header := new(ethtypes.Header)
+	if err := header.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	// The extra data starts with 32 bytes of vanity and ends with the seal.
+	if len(header.Extra) < 32+ethcrypto.SignatureLength {
+		return nil, fmt.Errorf("the extra data is too short to have a seal")
+	}
+	signature := header.Extra[len(header.Extra)-ethcrypto.SignatureLength:]
+	pubKey, err := ethcrypto.Ecrecover(clique.SealHash(header).Bytes(), signature)
+	if err != nil {
+		return nil, err
+	}
+	signer := common.BytesToAddress(ethcrypto.Keccak256(pubKey[1:])[12:])
+	return &signer, nil
+}
+
+func decodeBlock(b *rpcBlock) *decodedBlock {
+	d := &decodedBlock{
+		Number:                uint64(b.Number),
+		Hash:                  b.Hash,
+		ParentHash:            b.ParentHash,
+		Timestamp:             uint64(b.Timestamp),
+		Time:                  time.Unix(int64(b.Timestamp), 0).UTC(),
+		Miner:                 b.Miner,
+		Difficulty:            toBig(b.Difficulty),
+		TotalDifficulty:       toBig(b.TotalDifficulty),
+		Nonce:                 b.Nonce,
+		MixHash:               b.MixHash,
+		Size:                  uint64(b.Size),
+		GasLimit:              uint64(b.GasLimit),
+		GasUsed:               uint64(b.GasUsed),
+		BaseFeePerGas:         toBig(b.BaseFeePerGas),
+		ExtraData:             b.ExtraData,
+		StateRoot:             b.StateRoot,
+		TransactionsRoot:      b.TransactionsRoot,
+		ReceiptsRoot:          b.ReceiptsRoot,
+		Sha3Uncles:            b.Sha3Uncles,
+		WithdrawalsRoot:       b.WithdrawalsRoot,
+		ParentBeaconBlockRoot: b.ParentBeaconBlockRoot,
+		RequestsHash:          b.RequestsHash,
+		Uncles:                b.Uncles,
+	}
+	if d.BaseFeePerGas != nil {
+		d.BurntFees = new(big.Int).Mul(d.BaseFeePerGas, new(big.Int).SetUint64(d.GasUsed))
+	}
+	if b.BlobGasUsed != nil {
+		v := uint64(*b.BlobGasUsed)
+		d.BlobGasUsed = &v
+	}
+	if b.ExcessBlobGas != nil {
+		v := uint64(*b.ExcessBlobGas)
+		d.ExcessBlobGas = &v
+	}
+
+	for _, w := range b.Withdrawals {
+		d.Withdrawals = append(d.Withdrawals, decodedWithdrawal{
+			Index:          uint64(w.Index),
+			ValidatorIndex: uint64(w.ValidatorIndex),
+			Address:        w.Address,
+			Amount:         new(big.Int).Mul(new(big.Int).SetUint64(uint64(w.Amount)), big.NewInt(1e9)),
+		})
+	}
+
+	d.Transactions = make([]decodedTransaction, 0, len(b.Transactions))
+	for i := range b.Transactions {
+		tx := decodeTransaction(&b.Transactions[i], d.BaseFeePerGas)
+		if b.Transactions[i].TransactionIndex == nil {
+			tx.Index = uint64(i)
+		}
+		d.Blobs += len(tx.BlobVersionedHashes)
+		d.Transactions = append(d.Transactions, tx)
+	}
+	return d
+}
+
+// decodeTransaction decodes the transaction, computing the price it paid per
+// gas from the base fee of its block.
+func decodeTransaction(tx *rpcTransaction, baseFee *big.Int) decodedTransaction {
+	var txType uint64
+	if tx.Type != nil {
+		txType = uint64(*tx.Type)
+	}
+	d := decodedTransaction{
+		Hash:                 tx.Hash,
+		Type:                 txType,
+		TypeName:             util.TxTypeName(txType),
+		From:                 tx.From,
+		To:                   tx.To,
+		Nonce:                uint64(tx.Nonce),
+		Value:                toBig(tx.Value),
+		Gas:                  uint64(tx.Gas),
+		GasPrice:             toBig(tx.GasPrice),
+		MaxFeePerGas:         toBig(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: toBig(tx.MaxPriorityFeePerGas),
+		MaxFeePerBlobGas:     toBig(tx.MaxFeePerBlobGas),
+		BlobVersionedHashes:  tx.BlobVersionedHashes,
+		ChainID:              toBig(tx.ChainID),
+		AccessList:           tx.AccessList,
+		AuthorizationList:    tx.AuthorizationList,
+		Input:                tx.Input,
+		V:                    toBig(tx.V),
+		R:                    toBig(tx.R),
+		S:                    toBig(tx.S),
+	}
+	if tx.TransactionIndex != nil {
+		d.Index = uint64(*tx.TransactionIndex)
+	}
+	if tx.To != nil && len(tx.Input) >= 4 {
+		d.Selector = hexutil.Encode(tx.Input[:4])
+	}
+
+	// After London, the price is the base fee plus the tip, capped by the max
+	// fee. Endpoints usually report it as the gas price of mined transactions
+	// already, but not all of them do.
+	switch {
+	case d.MaxFeePerGas != nil && d.MaxPriorityFeePerGas != nil && baseFee != nil:
+		d.EffectiveGasPrice = new(big.Int).Add(baseFee, d.MaxPriorityFeePerGas)
+		if d.EffectiveGasPrice.Cmp(d.MaxFeePerGas) > 0 {
+			d.EffectiveGasPrice = new(big.Int).Set(d.MaxFeePerGas)
+		}
+	case d.GasPrice != nil:
+		d.EffectiveGasPrice = d.GasPrice
+	}
+	return d
+}
+
+func printBlock(b *decodedBlock) {
+	util.PrintSection("Block")
+	util.PrintField("Number", fmt.Sprint(b.Number))
+	if b.Hash != nil {
+		util.PrintField("Hash", b.Hash.Hex())
+	} else {
+		util.PrintField("Hash", "pending")
+	}
+	util.PrintField("Parent hash", b.ParentHash.Hex())
+	util.PrintField("Time", fmt.Sprintf("%s (%d)", b.Time.Format(time.RFC3339), b.Timestamp))
+	if b.Miner != nil {
+		util.PrintField("Miner", b.Miner.Hex())
+	}
+	if b.Signer != nil {
+		util.PrintField("Signer", b.Signer.Hex())
+	}
+	util.PrintField("Difficulty", b.Difficulty.String())
+	if b.TotalDifficulty != nil {
+		util.PrintField("Total difficulty", b.TotalDifficulty.String())
+	}
+	util.PrintField("Size", fmt.Sprintf("%d bytes", b.Size))
+	util.PrintField("Gas used", fmt.Sprintf("%d of %d (%.2f%%)", b.GasUsed, b.GasLimit, 100*float64(b.GasUsed)/float64(max(b.GasLimit, 1))))
+	if b.BaseFeePerGas != nil {
+		util.PrintField("Base fee", util.FormatWei(b.BaseFeePerGas, "gwei", 9))
+		util.PrintField("Burnt fees", util.FormatWei(b.BurntFees, "ether", 18))
+	}
+	util.PrintField("Transactions", fmt.Sprint(len(b.Transactions)))
+	util.PrintField("Uncles", fmt.Sprint(len(b.Uncles)))
+	util.PrintField("Extra data", fmt.Sprintf("%s (%d bytes)", b.ExtraData, len(b.ExtraData)))
+
+	util.PrintSection("Roots")
+	util.PrintField("State", b.StateRoot.Hex())
+	util.PrintField("Transactions", b.TransactionsRoot.Hex())
+	util.PrintField("Receipts", b.ReceiptsRoot.Hex())
+	util.PrintField("Uncles", b.Sha3Uncles.Hex())
+	if b.WithdrawalsRoot != nil {
+		util.PrintField("Withdrawals", b.WithdrawalsRoot.Hex())
+	}
+	if b.ParentBeaconBlockRoot != nil {
+		util.PrintField("Parent beacon", b.ParentBeaconBlockRoot.Hex())
+	}
+	if b.RequestsHash != nil {
+		util.PrintField("Requests hash", b.RequestsHash.Hex())
+	}
+
+	if b.BlobGasUsed != nil || b.ExcessBlobGas != nil {
+		util.PrintSection("Blobs")
+		util.PrintField("Blobs", fmt.Sprint(b.Blobs))
+		if b.BlobGasUsed != nil {
+			util.PrintField("Blob gas used", fmt.Sprint(*b.BlobGasUsed))
+		}
+		if b.ExcessBlobGas != nil {
+			util.PrintField("Excess blob gas", fmt.Sprint(*b.ExcessBlobGas))
+		}
+	}
+
+	if b.Withdrawals != nil {
+		util.PrintSection(fmt.Sprintf("Withdrawals (%d)", len(b.Withdrawals)))
+		for _, w := range b.Withdrawals {
+			util.PrintField(fmt.Sprintf("Withdrawal %d", w.Index), fmt.Sprintf("validator %d to %s: %s", w.ValidatorIndex, w.Address.Hex(), util.FormatWei(w.Amount, "ether", 18)))
+		}
+	}
+
+	for _, tx := range b.Transactions {
+		printTransaction(&tx)
+	}
+}
+
+func printTransaction(tx *decodedTransaction) {
+	util.PrintSection(fmt.Sprintf("Transaction %d", tx.Index))
+	util.PrintField("Hash", tx.Hash.Hex())
+	util.PrintField("Type", fmt.Sprintf("%d (%s)", tx.Type, tx.TypeName))
+	util.PrintField("From", tx.From.Hex())
+	if tx.To != nil {
+		util.PrintField("To", tx.To.Hex())
+	} else {
+		util.PrintField("To", "contract creation")
+	}
+	util.PrintField("Nonce", fmt.Sprint(tx.Nonce))
+	if tx.Value != nil {
+		util.PrintField("Value", util.FormatWei(tx.Value, "ether", 18))
+	}
+	util.PrintField("Gas limit", fmt.Sprint(tx.Gas))
+	if tx.MaxFeePerGas != nil && tx.MaxPriorityFeePerGas != nil {
+		util.PrintField("Max fee", util.FormatWei(tx.MaxFeePerGas, "gwei", 9))
+		util.PrintField("Max priority fee", util.FormatWei(tx.MaxPriorityFeePerGas, "gwei", 9))
+	}
+	if tx.EffectiveGasPrice != nil {
+		util.PrintField("Gas price", util.FormatWei(tx.EffectiveGasPrice, "gwei", 9))
+	}
+	if tx.MaxFeePerBlobGas != nil {
+		util.PrintField("Max blob fee", util.FormatWei(tx.MaxFeePerBlobGas, "gwei", 9))
+	}
+	for i, h := range tx.BlobVersionedHashes {
+		util.PrintField(fmt.Sprintf("Blob hash %d", i), h.Hex())
+	}
+	if tx.ChainID != nil {
+		util.PrintField("Chain ID", tx.ChainID.String())
+	}
+	if tx.AccessList != nil {
+		keys := 0
+		for _, t := range tx.AccessList {
+			keys += len(t.StorageKeys)
+		}
+		util.PrintField("Access list", fmt.Sprintf("%d addresses, %d storage keys", len(tx.AccessList), keys))
+	}
+	for i, auth := range tx.AuthorizationList {
+		util.PrintField(fmt.Sprintf("Authorization %d", i), fmt.Sprintf("%s on chain %s with nonce %d", auth.Address.Hex(), auth.ChainID, uint64(auth.Nonce)))
+	}
+	if tx.Selector != "" {
+		util.PrintField("Selector", tx.Selector)
+	}
+	util.PrintField("Input size", fmt.Sprintf("%d bytes", len(tx.Input)))
+	if tx.V != nil && tx.R != nil && tx.S != nil {
+		util.PrintField("Signature", fmt.Sprintf("v=%s r=%s s=%s", tx.V, tx.R, tx.S))
+	}
+}
+
+func toBig(b *hexutil.Big) *big.Int {
+	if b == nil {
+		return nil
+	}
+	return b.ToInt()
+}
+
+func init() {
+	flagSet := BlockFetchCmd.PersistentFlags()
+	flagSet.StringVarP(&inputBlockFetch.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint url")
+}
@@ -0,0 +1,304 @@
+package chaininfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	chainInfoParams struct {
+		RPCURL string
+	}
+
+	rpcBlock struct {
+		Number                hexutil.Uint64  `json:"number"`
+		Hash                  common.Hash     `json:"hash"`
+		Timestamp             hexutil.Uint64  `json:"timestamp"`
+		Difficulty            *hexutil.Big    `json:"difficulty"`
+		BaseFeePerGas         *hexutil.Big    `json:"baseFeePerGas"`
+		WithdrawalsRoot       *common.Hash    `json:"withdrawalsRoot"`
+		BlobGasUsed           *hexutil.Uint64 `json:"blobGasUsed"`
+		ParentBeaconBlockRoot *common.Hash    `json:"parentBeaconBlockRoot"`
+		RequestsHash          *common.Hash    `json:"requestsHash"`
+	}
+
+	rpcSyncStatus struct {
+		StartingBlock hexutil.Uint64 `json:"startingBlock"`
+		CurrentBlock  hexutil.Uint64 `json:"currentBlock"`
+		HighestBlock  hexutil.Uint64 `json:"highestBlock"`
+	}
+
+	syncStatus struct {
+		Syncing      bool   `json:"syncing"`
+		CurrentBlock uint64 `json:"currentBlock,omitempty"`
+		HighestBlock uint64 `json:"highestBlock,omitempty"`
+	}
+
+	latestBlock struct {
+		Number    uint64      `json:"number"`
+		Hash      common.Hash `json:"hash"`
+		Timestamp uint64      `json:"timestamp"`
+		// Age is how long ago the block was produced, which is a quick way
+		// to tell a stalled endpoint.
+		Age string `json:"age"`
+	}
+
+	// forkStatus is whether a fork is active at the latest block. Active is
+	// null when neither the header nor the probe could tell.
+	forkStatus struct {
+		Active   *bool    `json:"active"`
+		Evidence []string `json:"evidence"`
+	}
+
+	report struct {
+		Endpoint      string                 `json:"endpoint"`
+		ChainID       *big.Int               `json:"chainId"`
+		NetworkID     string                 `json:"networkId,omitempty"`
+		ClientVersion string                 `json:"clientVersion,omitempty"`
+		Modules       map[string]string      `json:"modules,omitempty"`
+		Sync          *syncStatus            `json:"sync,omitempty"`
+		PeerCount     *uint64                `json:"peerCount,omitempty"`
+		GasPrice      *big.Int               `json:"gasPrice,omitempty"`
+		LatestBlock   *latestBlock           `json:"latestBlock,omitempty"`
+		Forks         map[string]*forkStatus `json:"forks"`
+		Errors        map[string]string      `json:"errors,omitempty"`
+	}
+
+	// forkProbe is an init code only valid once the fork is active, which is
+	// run with eth_call. An invalid opcode error means the fork isn't active.
+	forkProbe struct {
+		Fork   string
+		Opcode string
+		Code   string
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage          string
+	inputChainInfo chainInfoParams
+
+	forkProbes = []forkProbe{
+		// PUSH0 PUSH0 RETURN
+		{Fork: "shanghai", Opcode: "PUSH0", Code: "0x5f5ff3"},
+		// PUSH1 1 PUSH0 TSTORE PUSH0 TLOAD STOP
+		{Fork: "cancun", Opcode: "TSTORE", Code: "0x60015f5d5f5c00"},
+	}
+)
+
+var ChainInfoCmd = &cobra.Command{
+	Use:     "chaininfo",
+	Aliases: []string{"chainid"},
+	Short:   "Report the chain, client, modules, forks, and sync status of an endpoint.",
+	Long:    usage,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		rpc, err := util.DialRPC(ctx, inputChainInfo.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to dial rpc")
+			return err
+		}
+		defer rpc.Close()
+
+		r, err := getReport(ctx, rpc)
+		if err != nil {
+			return err
+		}
+
+		out, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+
+		if r.ChainID == nil {
+			return fmt.Errorf("the endpoint didn't return a chain id")
+		}
+		return nil
+	},
+}
+
+// getReport sends every call in a single batch. The endpoint not supporting a
+// method is part of the report, so only the batch failing is an error.
+func getReport(ctx context.Context, rpc *ethrpc.Client) (*report, error) {
+	var (
+		chainID       hexutil.Big
+		networkID     string
+		clientVersion string
+		modules       map[string]string
+		syncing       json.RawMessage
+		peerCount     hexutil.Uint64
+		gasPrice      hexutil.Big
+		block         *rpcBlock
+	)
+	batch := []ethrpc.BatchElem{
+		{Method: "eth_chainId", Result: &chainID},
+		{Method: "net_version", Result: &networkID},
+		{Method: "web3_clientVersion", Result: &clientVersion},
+		{Method: "rpc_modules", Result: &modules},
+		{Method: "eth_syncing", Result: &syncing},
+		{Method: "net_peerCount", Result: &peerCount},
+		{Method: "eth_gasPrice", Result: &gasPrice},
+		{Method: "eth_getBlockByNumber", Args: []interface{}{"latest", false}, Result: &block},
+	}
+	probes := len(batch)
+	for _, p := range forkProbes {
+		batch = append(batch, ethrpc.BatchElem{
+			Method: "eth_call",
+			Args:   []interface{}{map[string]string{"data": p.Code}, "latest"},
+			Result: new(hexutil.Bytes),
+		})
+	}
+	if err := rpc.BatchCallContext(ctx, batch); err != nil {
+		return nil, fmt.Errorf("unable to query the endpoint: %w", err)
+	}
+
+	r := &report{
+		Endpoint: inputChainInfo.RPCURL,
+		Forks:    make(map[string]*forkStatus),
+		Errors:   make(map[string]string),
+	}
+	for _, elem := range batch[:probes] {
+		if elem.Error != nil {
+			r.Errors[elem.Method] = elem.Error.Error()
+		}
+	}
+	ok := func(method string) bool {
+		_, failed := r.Errors[method]
+		return !failed
+	}
+
+	if ok("eth_chainId") {
+		r.ChainID = chainID.ToInt()
+	}
+	r.NetworkID = networkID
+	r.ClientVersion = clientVersion
+	r.Modules = modules
+	if ok("net_peerCount") {
+		count := uint64(peerCount)
+		r.PeerCount = &count
+	}
+	if ok("eth_gasPrice") {
+		r.GasPrice = gasPrice.ToInt()
+	}
+	if ok("eth_syncing") {
+		r.Sync = parseSyncing(syncing)
+	}
+	if block != nil {
+		produced := time.Unix(int64(block.Timestamp), 0)
+		r.LatestBlock = &latestBlock{
+			Number:    uint64(block.Number),
+			Hash:      block.Hash,
+			Timestamp: uint64(block.Timestamp),
+			Age:       time.Since(produced).Truncate(time.Second).String(),
+		}
+	}
+
+	setHeaderForks(r, block)
+	for i, p := range forkProbes {
+		setProbedFork(r, p, batch[probes+i].Error)
+	}
+	return r, nil
+}
+
+func parseSyncing(raw json.RawMessage) *syncStatus {
+	var syncing bool
+	if err := json.Unmarshal(raw, &syncing); err == nil {
+		return &syncStatus{Syncing: syncing}
+	}
+	var status rpcSyncStatus
+	if err := json.Unmarshal(raw, &status); err != nil {
+		log.Debug().Err(err).RawJSON("syncing", raw).Msg("Unable to decode the sync status")
+		return nil
+	}
+	return &syncStatus{
+		Syncing:      true,
+		CurrentBlock: uint64(status.CurrentBlock),
+		HighestBlock: uint64(status.HighestBlock),
+	}
+}
+
+func (r *report) fork(name string) *forkStatus {
+	f, ok := r.Forks[name]
+	if !ok {
+		f = new(forkStatus)
+		r.Forks[name] = f
+	}
+	return f
+}
+
+// set records the evidence, and marks the fork active if any evidence says
+// it is.
+func (f *forkStatus) set(active bool, evidence string) {
+	f.Evidence = append(f.Evidence, evidence)
+	if f.Active == nil || active {
+		f.Active = &active
+	}
+}
+
+// setHeaderForks tells the forks apart by the fields they added to the
+// header. Chains that don't follow Ethereum's consensus, like Bor, activate
+// the execution changes of a fork without its header fields, so a missing
+// field alone isn't conclusive.
+func setHeaderForks(r *report, block *rpcBlock) {
+	if block == nil {
+		return
+	}
+	fields := []struct {
+		fork, field string
+		present     bool
+	}{
+		{"london", "baseFeePerGas", block.BaseFeePerGas != nil},
+		{"shanghai", "withdrawalsRoot", block.WithdrawalsRoot != nil},
+		{"cancun", "blobGasUsed", block.BlobGasUsed != nil},
+		{"cancun", "parentBeaconBlockRoot", block.ParentBeaconBlockRoot != nil},
+		{"prague", "requestsHash", block.RequestsHash != nil},
+	}
+	for _, f := range fields {
+		if f.present {
+			r.fork(f.fork).set(true, fmt.Sprintf("the latest block has %s", f.field))
+		} else {
+			r.fork(f.fork).set(false, fmt.Sprintf("the latest block has no %s", f.field))
+		}
+	}
+
+	if block.Difficulty != nil {
+		if block.Difficulty.ToInt().Sign() == 0 {
+			r.fork("paris").set(true, "the difficulty of the latest block is zero")
+		} else {
+			r.fork("paris").set(false, fmt.Sprintf("the difficulty of the latest block is %s", block.Difficulty.ToInt()))
+		}
+	}
+}
+
+// setProbedFork tells whether the opcode of the fork runs. An error other
+// than an invalid opcode is only recorded as evidence.
+func setProbedFork(r *report, p forkProbe, err error) {
+	f := r.fork(p.Fork)
+	switch {
+	case err == nil:
+		f.set(true, fmt.Sprintf("%s runs in eth_call", p.Opcode))
+	case strings.Contains(strings.ToLower(err.Error()), "opcode"):
+		f.set(false, fmt.Sprintf("%s is an invalid opcode in eth_call", p.Opcode))
+	default:
+		f.Evidence = append(f.Evidence, fmt.Sprintf("the %s probe failed: %s", p.Opcode, err))
+	}
+}
+
+func init() {
+	ChainInfoCmd.PersistentFlags().StringVarP(&inputChainInfo.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint url")
+}
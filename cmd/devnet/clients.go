@@ -0,0 +1,297 @@
+package devnet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/hdwallet"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+)
+
+// stopTimeout is how long a client has to exit after it's interrupted before
+// it's killed.
+const stopTimeout = 10 * time.Second
+
+// start starts the devnet and waits until its RPC is ready. A detached client
+// is started in its own session so it keeps running after we exit. The
+// process is nil for kurtosis, which runs the devnet itself.
+func start(ctx context.Context, detach bool) (*state, *exec.Cmd, error) {
+	p := inputDevnet
+	s := &state{Client: p.Client, URL: fmt.Sprintf("http://%s:%d", p.Host, p.Port)}
+
+	var process *exec.Cmd
+	switch p.Client {
+	case clientKurtosis:
+		url, err := startKurtosis(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.URL = url
+		s.Enclave = p.KurtosisEnclave
+	default:
+		args := anvilArgs()
+		if p.Client == clientGeth {
+			args = gethArgs()
+		}
+		s.LogFile = p.LogFile
+		if s.LogFile == "" {
+			s.LogFile = filepath.Join(os.TempDir(), fmt.Sprintf("polycli-devnet-%s.log", p.Client))
+		}
+		out, err := os.Create(s.LogFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer out.Close()
+
+		process = exec.Command(bin(p.Client), args...)
+		process.Stdout = out
+		process.Stderr = out
+		if detach {
+			process.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		}
+		if err = process.Start(); err != nil {
+			return nil, nil, fmt.Errorf("unable to start %s: %w", p.Client, err)
+		}
+		s.PID = process.Process.Pid
+		log.Info().Str("client", p.Client).Int("pid", s.PID).Str("log", s.LogFile).Msg("Started devnet")
+	}
+
+	if err := waitUntilReady(ctx, s); err != nil {
+		if stopErr := stop(ctx, s, process); stopErr != nil {
+			log.Error().Err(stopErr).Msg("Unable to stop the devnet")
+		}
+		return nil, nil, err
+	}
+	log.Info().Str("client", s.Client).Str("url", s.URL).Msg("Devnet is ready")
+	return s, process, nil
+}
+
+// stop tears down the devnet. A process we started in this run is waited
+// for, otherwise the process of the saved state is polled until it exits.
+func stop(ctx context.Context, s *state, process *exec.Cmd) error {
+	if s.Enclave != "" {
+		out, err := exec.CommandContext(ctx, bin(s.Client), "enclave", "rm", "--force", s.Enclave).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("unable to remove the enclave %s: %w: %s", s.Enclave, err, bytes.TrimSpace(out))
+		}
+		log.Info().Str("enclave", s.Enclave).Msg("Removed devnet enclave")
+		return nil
+	}
+	if s.PID == 0 {
+		return nil
+	}
+
+	exited := make(chan struct{})
+	if process != nil {
+		go func() {
+			_ = process.Wait()
+			close(exited)
+		}()
+	} else {
+		go func() {
+			// Signal 0 only checks that the process still exists.
+			for syscall.Kill(s.PID, 0) == nil {
+				time.Sleep(100 * time.Millisecond)
+			}
+			close(exited)
+		}()
+	}
+
+	if err := syscall.Kill(s.PID, syscall.SIGINT); err != nil {
+		if err == syscall.ESRCH {
+			log.Warn().Int("pid", s.PID).Msg("The devnet had already exited")
+			return nil
+		}
+		return err
+	}
+	select {
+	case <-exited:
+	case <-time.After(stopTimeout):
+		log.Warn().Int("pid", s.PID).Msg("The devnet didn't exit in time, killing it")
+		if err := syscall.Kill(s.PID, syscall.SIGKILL); err != nil {
+			return err
+		}
+		<-exited
+	}
+	log.Info().Str("client", s.Client).Int("pid", s.PID).Msg("Stopped devnet")
+	return nil
+}
+
+// bin returns the binary of the client. The client of a saved devnet may not
+// be the one of the flag, so it's passed in.
+func bin(client string) string {
+	if inputDevnet.Bin != "" {
+		return inputDevnet.Bin
+	}
+	return client
+}
+
+// anvilArgs funds the accounts of the mnemonic with anvil, which derives them
+// the same way as we do.
+func anvilArgs() []string {
+	p := inputDevnet
+	args := []string{
+		"--host", p.Host,
+		"--port", strconv.Itoa(p.Port),
+		"--chain-id", strconv.FormatUint(p.ChainID, 10),
+		"--mnemonic", p.Mnemonic,
+		"--accounts", strconv.Itoa(p.Accounts),
+		"--balance", strconv.FormatUint(p.Balance, 10),
+	}
+	if p.BlockTime > 0 {
+		args = append(args, "--block-time", strconv.Itoa(int(p.BlockTime/time.Second)))
+	}
+	if p.Genesis != "" {
+		args = append(args, "--init", p.Genesis)
+	}
+	return args
+}
+
+// gethArgs runs geth in dev mode with the state in memory. Its developer
+// account is random, so the accounts of the mnemonic are funded from it once
+// it's ready.
+func gethArgs() []string {
+	p := inputDevnet
+	args := []string{
+		"--dev",
+		"--http",
+		"--http.addr", p.Host,
+		"--http.port", strconv.Itoa(p.Port),
+		"--http.api", "eth,net,web3,txpool,debug",
+	}
+	if p.BlockTime > 0 {
+		args = append(args, "--dev.period", strconv.Itoa(int(p.BlockTime/time.Second)))
+	}
+	if p.ChainID != 1337 {
+		log.Warn().Uint64("chainID", p.ChainID).Msg("Geth ignores the chain id in dev mode and uses 1337")
+	}
+	return args
+}
+
+// startKurtosis runs the package in the enclave, which returns once the
+// network is up, and returns the URL of the rpc port of the service.
+func startKurtosis(ctx context.Context) (string, error) {
+	p := inputDevnet
+	args := []string{"run", "--enclave", p.KurtosisEnclave, p.KurtosisPackage}
+	if p.KurtosisArgsFile != "" {
+		args = append(args, "--args-file", p.KurtosisArgsFile)
+	}
+	log.Info().Str("package", p.KurtosisPackage).Str("enclave", p.KurtosisEnclave).Msg("Running kurtosis package")
+	run := exec.CommandContext(ctx, bin(clientKurtosis), args...)
+	run.Stdout = os.Stderr
+	run.Stderr = os.Stderr
+	if err := run.Run(); err != nil {
+		return "", fmt.Errorf("unable to run the kurtosis package: %w", err)
+	}
+
+	out, err := exec.CommandContext(ctx, bin(clientKurtosis), "port", "print", p.KurtosisEnclave, p.KurtosisService, "rpc").Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to get the rpc port of %s: %w", p.KurtosisService, err)
+	}
+	url := strings.TrimSpace(string(out))
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	return url, nil
+}
+
+// waitUntilReady polls the chain id until the RPC answers, then funds the
+// accounts if the client doesn't.
+func waitUntilReady(ctx context.Context, s *state) error {
+	ctx, cancel := context.WithTimeout(ctx, inputDevnet.Timeout)
+	defer cancel()
+
+	rpc, err := util.DialRPC(ctx, s.URL)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to dial rpc")
+		return err
+	}
+	defer rpc.Close()
+
+	for {
+		var chainID hexutil.Big
+		err = rpc.CallContext(ctx, &chainID, "eth_chainId")
+		if err == nil {
+			break
+		}
+		log.Trace().Err(err).Msg("Devnet isn't ready yet")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the devnet wasn't ready in time: %w", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	if s.Client != clientGeth {
+		return nil
+	}
+	return fundAccounts(ctx, rpc)
+}
+
+// fundAccounts sends the balance to each account of the mnemonic from the
+// unlocked developer account of geth, and waits until the last one is funded.
+func fundAccounts(ctx context.Context, rpc *ethrpc.Client) error {
+	p := inputDevnet
+	if p.Accounts < 1 {
+		return nil
+	}
+	var developer []ethcommon.Address
+	if err := rpc.CallContext(ctx, &developer, "eth_accounts"); err != nil {
+		return err
+	}
+	if len(developer) == 0 {
+		return fmt.Errorf("geth has no developer account")
+	}
+
+	pw, err := hdwallet.NewPolyWallet(p.Mnemonic, "")
+	if err != nil {
+		return err
+	}
+	if err = pw.SetPath("m/44'/60'/0'"); err != nil {
+		return err
+	}
+	export, err := pw.ExportHDAddressRange(0, p.Accounts)
+	if err != nil {
+		return err
+	}
+	value := new(big.Int).Mul(new(big.Int).SetUint64(p.Balance), big.NewInt(1e18))
+
+	var last ethcommon.Address
+	for _, a := range export.Addresses {
+		last = ethcommon.HexToAddress(a.ETHAddress)
+		tx := map[string]interface{}{"from": developer[0], "to": last, "value": (*hexutil.Big)(value)}
+		var hash ethcommon.Hash
+		if err = rpc.CallContext(ctx, &hash, "eth_sendTransaction", tx); err != nil {
+			return fmt.Errorf("unable to fund %s: %w", last, err)
+		}
+	}
+
+	for {
+		var balance hexutil.Big
+		if err = rpc.CallContext(ctx, &balance, "eth_getBalance", last, "latest"); err != nil {
+			return err
+		}
+		if balance.ToInt().Sign() > 0 {
+			log.Info().Int("accounts", len(export.Addresses)).Msg("Funded devnet accounts")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the devnet accounts weren't funded in time")
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package devnet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "embed"
+
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	clientAnvil    = "anvil"
+	clientGeth     = "geth"
+	clientKurtosis = "kurtosis"
+
+	codeQualityMnemonic = "code code code code code code code code code code code quality"
+
+	// rpcPlaceholder is replaced by the URL of the devnet in the arguments of
+	// the command that's run against it.
+	rpcPlaceholder = "{rpc}"
+)
+
+type (
+	devnetParams struct {
+		Client    string
+		Bin       string
+		Host      string
+		Port      int
+		ChainID   uint64
+		BlockTime time.Duration
+		Mnemonic  string
+		Accounts  int
+		Balance   uint64
+		Genesis   string
+		LogFile   string
+		StateFile string
+		Timeout   time.Duration
+
+		KurtosisPackage  string
+		KurtosisArgsFile string
+		KurtosisEnclave  string
+		KurtosisService  string
+	}
+
+	// state is what's saved about a started devnet, so it can be found and
+	// stopped by later commands.
+	state struct {
+		Client  string `json:"client"`
+		URL     string `json:"url"`
+		PID     int    `json:"pid,omitempty"`
+		Enclave string `json:"enclave,omitempty"`
+		LogFile string `json:"logFile,omitempty"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage       string
+	inputDevnet devnetParams
+)
+
+var DevnetCmd = &cobra.Command{
+	Use:   "devnet",
+	Short: "Start, use, and tear down a local chain for load tests.",
+	Long:  usage,
+}
+
+var DevnetStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start a devnet in the background.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.NoArgs(cmd, args); err != nil {
+			return err
+		}
+		return validateParams()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if s, err := loadState(); err == nil {
+			return fmt.Errorf("a %s devnet is already running at %s, stop it first", s.Client, s.URL)
+		}
+		s, _, err := start(cmd.Context(), true)
+		if err != nil {
+			return err
+		}
+		if err = saveState(s); err != nil {
+			return err
+		}
+		return printState(s)
+	},
+}
+
+var DevnetStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the devnet started in the background.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := loadState()
+		if err != nil {
+			return err
+		}
+		if err = stop(cmd.Context(), s, nil); err != nil {
+			return err
+		}
+		return os.Remove(inputDevnet.StateFile)
+	},
+}
+
+var DevnetURLCmd = &cobra.Command{
+	Use:   "url",
+	Short: "Print the RPC URL of the devnet started in the background.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := loadState()
+		if err != nil {
+			return err
+		}
+		return printState(s)
+	},
+}
+
+var DevnetRunCmd = &cobra.Command{
+	Use:   "run -- command [args...]",
+	Short: "Start a devnet, run a polycli command against it, and tear it down.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MinimumNArgs(1)(cmd, args); err != nil {
+			return err
+		}
+		return validateParams()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		self, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		s, process, err := start(ctx, false)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := stop(ctx, s, process); err != nil {
+				log.Error().Err(err).Msg("Unable to stop the devnet")
+			}
+		}()
+
+		child := exec.CommandContext(ctx, self, withRPC(args, s.URL)...)
+		child.Stdin = os.Stdin
+		child.Stdout = os.Stdout
+		child.Stderr = os.Stderr
+		log.Info().Strs("args", child.Args[1:]).Msg("Running command against the devnet")
+		return child.Run()
+	},
+}
+
+func validateParams() error {
+	switch inputDevnet.Client {
+	case clientAnvil, clientGeth, clientKurtosis:
+	default:
+		return fmt.Errorf("the client %s is not supported, expected anvil, geth, or kurtosis", inputDevnet.Client)
+	}
+	if inputDevnet.Genesis != "" && inputDevnet.Client != clientAnvil {
+		return fmt.Errorf("--genesis is only supported by anvil")
+	}
+	if inputDevnet.BlockTime%time.Second != 0 {
+		return fmt.Errorf("the block time needs to be in whole seconds")
+	}
+	return nil
+}
+
+// withRPC replaces the placeholder in the arguments with the URL. If there's
+// no placeholder, the URL is added as the last argument, which is where
+// commands like loadtest and monitor expect it.
+func withRPC(args []string, url string) []string {
+	out := make([]string, 0, len(args)+1)
+	replaced := false
+	for _, arg := range args {
+		if strings.Contains(arg, rpcPlaceholder) {
+			arg = strings.ReplaceAll(arg, rpcPlaceholder, url)
+			replaced = true
+		}
+		out = append(out, arg)
+	}
+	if !replaced {
+		out = append(out, url)
+	}
+	return out
+}
+
+func loadState() (*state, error) {
+	data, err := os.ReadFile(inputDevnet.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no devnet is running")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s state
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("unable to parse the state file %s: %w", inputDevnet.StateFile, err)
+	}
+	return &s, nil
+}
+
+func saveState(s *state) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(inputDevnet.StateFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(inputDevnet.StateFile, data, 0644)
+}
+
+// printState prints the URL of the devnet, or its whole state with --json.
+func printState(s *state) error {
+	if util.JSONOutput() {
+		return util.PrintJSON(s)
+	}
+	fmt.Println(s.URL)
+	return nil
+}
+
+func defaultStateFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "polycli-devnet.json")
+	}
+	return filepath.Join(home, ".polygon-cli", "devnet.json")
+}
+
+func init() {
+	flagSet := DevnetCmd.PersistentFlags()
+	flagSet.StringVar(&inputDevnet.Client, "client", clientAnvil, "The devnet to start [anvil, geth, kurtosis]")
+	flagSet.StringVar(&inputDevnet.Bin, "bin", "", "The binary of the client. Defaults to the name of the client in the PATH")
+	flagSet.StringVar(&inputDevnet.Host, "host", "127.0.0.1", "The address the RPC of anvil or geth listens on")
+	flagSet.IntVar(&inputDevnet.Port, "port", 8545, "The port the RPC of anvil or geth listens on")
+	flagSet.Uint64Var(&inputDevnet.ChainID, "chain-id", 1337, "The chain id of anvil. Geth always uses 1337 in dev mode")
+	flagSet.DurationVar(&inputDevnet.BlockTime, "block-time", time.Second, "The time between blocks of anvil or geth in whole seconds. Blocks are only made for transactions if this is 0")
+	flagSet.StringVar(&inputDevnet.Mnemonic, "mnemonic", codeQualityMnemonic, "The mnemonic of the funded accounts of anvil or geth, which defaults to the one of the default load test private key")
+	flagSet.IntVar(&inputDevnet.Accounts, "accounts", 10, "The number of funded accounts of anvil or geth")
+	flagSet.Uint64Var(&inputDevnet.Balance, "balance", 1000000, "The ether each account of anvil or geth is funded with")
+	flagSet.StringVar(&inputDevnet.Genesis, "genesis", "", "A genesis file anvil starts from, like one written by genalloc")
+	flagSet.StringVar(&inputDevnet.LogFile, "log-file", "", "The file the output of anvil or geth is written to. Defaults to a file in the temporary directory")
+	flagSet.StringVar(&inputDevnet.StateFile, "state-file", defaultStateFile(), "The file where the devnet started in the background is saved")
+	flagSet.DurationVar(&inputDevnet.Timeout, "timeout", 5*time.Minute, "How long to wait for the devnet to be ready")
+	flagSet.StringVar(&inputDevnet.KurtosisPackage, "kurtosis-package", "github.com/ethpandaops/ethereum-package", "The kurtosis package that's run")
+	flagSet.StringVar(&inputDevnet.KurtosisArgsFile, "kurtosis-args-file", "", "The args file of the kurtosis package")
+	flagSet.StringVar(&inputDevnet.KurtosisEnclave, "kurtosis-enclave", "polycli-devnet", "The kurtosis enclave the package runs in")
+	flagSet.StringVar(&inputDevnet.KurtosisService, "kurtosis-service", "el-1-geth-lighthouse", "The kurtosis service whose rpc port is used")
+
+	DevnetCmd.AddCommand(DevnetStartCmd)
+	DevnetCmd.AddCommand(DevnetStopCmd)
+	DevnetCmd.AddCommand(DevnetURLCmd)
+	DevnetCmd.AddCommand(DevnetRunCmd)
+}
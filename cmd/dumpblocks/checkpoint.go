@@ -0,0 +1,129 @@
+package dumpblocks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// workerCheckpoint is the part of the range dumped by a single worker.
+	// Next is the first block that hasn't been dumped yet, so the worker is
+	// done once Next is past End.
+	workerCheckpoint struct {
+		Start uint64 `json:"start"`
+		End   uint64 `json:"end"`
+		Next  uint64 `json:"next"`
+	}
+
+	// checkpoint records the progress of each worker so an interrupted dump
+	// can be resumed. The range is inclusive on both ends.
+	checkpoint struct {
+		Start   uint64              `json:"start"`
+		End     uint64              `json:"end"`
+		Workers []*workerCheckpoint `json:"workers"`
+
+		path string
+		lock sync.Mutex
+	}
+)
+
+// newCheckpoint splits the range into contiguous parts, one per worker.
+func newCheckpoint(path string, start, end uint64, workers uint) *checkpoint {
+	c := &checkpoint{Start: start, End: end, path: path}
+
+	total := end - start + 1
+	size := total / uint64(workers)
+	if total%uint64(workers) != 0 {
+		size++
+	}
+	for s := start; s <= end; s += size {
+		e := s + size - 1
+		if e > end || e < s {
+			e = end
+		}
+		c.Workers = append(c.Workers, &workerCheckpoint{Start: s, End: e, Next: s})
+		if e == end {
+			break
+		}
+	}
+	return c
+}
+
+// loadCheckpoint reads the checkpoint file if it exists. The checkpoint must
+// be for the same range, but the number of workers is kept from the file
+// because the parts have already been assigned.
+func loadCheckpoint(path string, start, end uint64, workers uint) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		c := newCheckpoint(path, start, end, workers)
+		return c, c.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checkpoint{path: path}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("unable to parse the checkpoint %s: %w", path, err)
+	}
+	if c.Start != start || c.End != end {
+		return nil, fmt.Errorf("the checkpoint %s is for blocks %d to %d, not %d to %d", path, c.Start, c.End, start, end)
+	}
+
+	log.Info().Str("checkpoint", path).Int("workers", len(c.Workers)).Uint64("remaining", c.remaining()).Msg("Resuming from checkpoint")
+	return c, nil
+}
+
+// complete records that the worker has dumped every block before next.
+func (c *checkpoint) complete(worker int, next uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.Workers[worker].Next = next
+	return c.save()
+}
+
+// remaining is the number of blocks that haven't been dumped yet.
+func (c *checkpoint) remaining() uint64 {
+	var remaining uint64
+	for _, w := range c.Workers {
+		if w.Next <= w.End {
+			remaining += w.End - w.Next + 1
+		}
+	}
+	return remaining
+}
+
+// resumed returns true if any worker has made progress.
+func (c *checkpoint) resumed() bool {
+	for _, w := range c.Workers {
+		if w.Next != w.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the checkpoint to a temporary file and renames it so an
+// interruption never leaves a partially written checkpoint. Nothing is
+// written if there's no checkpoint file.
+func (c *checkpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -1,6 +1,7 @@
 package dumpblocks
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -33,9 +34,19 @@ type (
 		Threads            uint
 		ShouldDumpBlocks   bool
 		ShouldDumpReceipts bool
+		ShouldDumpLogs     bool
+		LogAddresses       []string
+		LogTopics          []string
+		ShouldDumpTraces   bool
+		TraceMethod        string
+		Tracer             string
+		TracerConfigStr    string
+		tracerConfig       map[string]interface{}
 		Filename           string
 		Mode               string
 		FilterStr          string
+		Checkpoint         string
+		Retries            uint
 		filter             Filter
 	}
 	Filter struct {
@@ -57,80 +68,69 @@ var DumpblocksCmd = &cobra.Command{
 	Long:  usage,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
-		ec, err := ethrpc.DialContext(ctx, args[0])
+		ec, err := util.DialRPC(ctx, args[0])
 		if err != nil {
 			return err
 		}
 
-		var wg sync.WaitGroup
-		log.Info().Uint("thread", inputDumpblocks.Threads).Msg("Thread count")
-		var pool = make(chan bool, inputDumpblocks.Threads)
-		start := inputDumpblocks.Start
-		end := inputDumpblocks.End
+		cp := newCheckpoint("", inputDumpblocks.Start, inputDumpblocks.End, inputDumpblocks.Threads)
+		if inputDumpblocks.Checkpoint != "" {
+			cp, err = loadCheckpoint(inputDumpblocks.Checkpoint, inputDumpblocks.Start, inputDumpblocks.End, inputDumpblocks.Threads)
+			if err != nil {
+				return err
+			}
+		}
 
-		for start < end {
-			rangeStart := start
-			rangeEnd := rangeStart + inputDumpblocks.BatchSize
+		if inputDumpblocks.Mode == "parquet" {
+			// Parquet files can't be appended to, so a resumed dump is written
+			// to a new set of files.
+			parquetOut, err = newParquetWriters(inputDumpblocks.Filename, cp.resumed())
+			if err != nil {
+				return err
+			}
+		}
 
-			if rangeEnd > end {
-				rangeEnd = end
+		var wg sync.WaitGroup
+		log.Info().Int("workers", len(cp.Workers)).Msg("Worker count")
+		for i, w := range cp.Workers {
+			if w.Next > w.End {
+				continue
 			}
 
-			pool <- true
 			wg.Add(1)
-			log.Info().Uint64("start", rangeStart).Uint64("end", rangeEnd).Msg("Getting range")
-			go func() {
+			go func(i int, w *workerCheckpoint) {
 				defer wg.Done()
-				for {
-					failCount := 0
-					blocks, err := util.GetBlockRange(ctx, rangeStart, rangeEnd, ec)
-					if err != nil {
-						failCount = failCount + 1
-						if failCount > 5 {
-							log.Error().Uint64("rangeStart", rangeStart).Uint64("rangeEnd", rangeEnd).Msg("Unable to fetch blocks")
-							break
-						}
-						time.Sleep(5 * time.Second)
-						continue
+				for next := w.Next; next <= w.End; {
+					rangeStart := next
+					rangeEnd := rangeStart + inputDumpblocks.BatchSize - 1
+					if rangeEnd > w.End || rangeEnd < rangeStart {
+						rangeEnd = w.End
 					}
 
-					blocks = filterBlocks(blocks)
-
-					if inputDumpblocks.ShouldDumpBlocks {
-						err = writeResponses(blocks, "block")
-						if err != nil {
-							log.Error().Err(err).Msg("Error writing blocks")
-						}
+					log.Info().Int("worker", i).Uint64("start", rangeStart).Uint64("end", rangeEnd).Msg("Getting range")
+					if err := dumpRange(ctx, ec, rangeStart, rangeEnd); err != nil {
+						log.Error().Err(err).Int("worker", i).Uint64("rangeStart", rangeStart).Uint64("rangeEnd", rangeEnd).Msg("Stopping worker, rerun with the checkpoint to resume")
+						return
 					}
 
-					if inputDumpblocks.ShouldDumpReceipts {
-						failCount = 0
-						receipts, err := util.GetReceipts(ctx, blocks, ec, inputDumpblocks.BatchSize)
-						if err != nil {
-							failCount = failCount + 1
-							if failCount > 5 {
-								log.Error().Uint64("rangeStart", rangeStart).Uint64("rangeEnd", rangeEnd).Msg("Unable to fetch receipts")
-								break
-							}
-							time.Sleep(5 * time.Second)
-							continue
-						}
-
-						err = writeResponses(receipts, "transaction")
-						if err != nil {
-							log.Error().Err(err).Msg("Error writing receipts")
-						}
+					next = rangeEnd + 1
+					if err := cp.complete(i, next); err != nil {
+						log.Error().Err(err).Msg("Unable to save checkpoint")
 					}
-
-					break
 				}
-				<-pool
-			}()
-			start = rangeEnd
+			}(i, w)
 		}
 
 		log.Info().Msg("Finished requesting data starting to wait")
 		wg.Wait()
+		if parquetOut != nil {
+			if err = parquetOut.close(); err != nil {
+				return err
+			}
+		}
+		if remaining := cp.remaining(); remaining > 0 {
+			return util.PartialFailure(fmt.Errorf("%d blocks weren't dumped", remaining))
+		}
 		log.Info().Msg("Done")
 
 		return nil
@@ -166,15 +166,36 @@ var DumpblocksCmd = &cobra.Command{
 		if inputDumpblocks.Threads == 0 {
 			inputDumpblocks.Threads = 1
 		}
-		if !slices.Contains([]string{"json", "proto"}, inputDumpblocks.Mode) {
-			return fmt.Errorf("output format must one of [json, proto]")
+		if !slices.Contains([]string{"json", "proto", "parquet"}, inputDumpblocks.Mode) {
+			return fmt.Errorf("output format must one of [json, proto, parquet]")
 		}
 
 		if err := json.Unmarshal([]byte(inputDumpblocks.FilterStr), &inputDumpblocks.filter); err != nil {
 			return fmt.Errorf("could not unmarshal filter string")
 		}
 
+		if inputDumpblocks.ShouldDumpLogs && inputDumpblocks.Mode == "proto" {
+			return fmt.Errorf("logs can't be dumped in proto mode")
+		}
+		if inputDumpblocks.ShouldDumpTraces && inputDumpblocks.Mode == "proto" {
+			return fmt.Errorf("traces can't be dumped in proto mode")
+		}
+		if !slices.Contains(traceMethods, inputDumpblocks.TraceMethod) {
+			return fmt.Errorf("trace method must be one of %v", traceMethods)
+		}
+		if inputDumpblocks.TracerConfigStr != "" {
+			if err := json.Unmarshal([]byte(inputDumpblocks.TracerConfigStr), &inputDumpblocks.tracerConfig); err != nil {
+				return fmt.Errorf("could not unmarshal tracer config")
+			}
+		}
+
 		// Make sure the filters are all lowercase.
+		for i := range inputDumpblocks.LogAddresses {
+			inputDumpblocks.LogAddresses[i] = strings.ToLower(inputDumpblocks.LogAddresses[i])
+		}
+		for i := range inputDumpblocks.LogTopics {
+			inputDumpblocks.LogTopics[i] = strings.ToLower(inputDumpblocks.LogTopics[i])
+		}
 		for i := 0; i < len(inputDumpblocks.filter.To); i++ {
 			inputDumpblocks.filter.To[i] = strings.ToLower(inputDumpblocks.filter.To[i])
 		}
@@ -187,20 +208,136 @@ var DumpblocksCmd = &cobra.Command{
 }
 
 func init() {
-	DumpblocksCmd.PersistentFlags().UintVarP(&inputDumpblocks.Threads, "concurrency", "c", 1, "how many go routines to leverage")
+	DumpblocksCmd.PersistentFlags().UintVarP(&inputDumpblocks.Threads, "concurrency", "c", 1, "how many workers to split the range across")
 	DumpblocksCmd.PersistentFlags().BoolVarP(&inputDumpblocks.ShouldDumpBlocks, "dump-blocks", "B", true, "if the blocks will be dumped")
 	DumpblocksCmd.PersistentFlags().BoolVarP(&inputDumpblocks.ShouldDumpReceipts, "dump-receipts", "r", true, "if the receipts will be dumped")
-	DumpblocksCmd.PersistentFlags().StringVarP(&inputDumpblocks.Filename, "filename", "f", "", "where to write the output to (default stdout)")
-	DumpblocksCmd.PersistentFlags().StringVarP(&inputDumpblocks.Mode, "mode", "m", "json", "the output format [json, proto]")
+	DumpblocksCmd.PersistentFlags().BoolVarP(&inputDumpblocks.ShouldDumpLogs, "dump-logs", "l", false, "if the logs will be dumped")
+	DumpblocksCmd.PersistentFlags().StringSliceVar(&inputDumpblocks.LogAddresses, "log-address", nil, "only dump logs, and receipts with logs, emitted by these contract addresses")
+	DumpblocksCmd.PersistentFlags().StringSliceVar(&inputDumpblocks.LogTopics, "log-topic0", nil, "only dump logs, and receipts with logs, whose first topic is one of these event signature hashes")
+	DumpblocksCmd.PersistentFlags().BoolVar(&inputDumpblocks.ShouldDumpTraces, "dump-traces", false, "if the block traces will be dumped")
+	DumpblocksCmd.PersistentFlags().StringVar(&inputDumpblocks.TraceMethod, "trace-method", "debug", "how blocks are traced [debug, trace, state-diff]. debug uses debug_traceBlockByNumber, trace uses trace_block, and state-diff uses trace_replayBlockTransactions")
+	DumpblocksCmd.PersistentFlags().StringVar(&inputDumpblocks.Tracer, "tracer", "callTracer", "the tracer used by debug_traceBlockByNumber")
+	DumpblocksCmd.PersistentFlags().StringVar(&inputDumpblocks.TracerConfigStr, "tracer-config", "", "the tracer config used by debug_traceBlockByNumber as JSON, e.g. {\"diffMode\":true} for the prestateTracer")
+	DumpblocksCmd.PersistentFlags().StringVarP(&inputDumpblocks.Filename, "filename", "f", "", "where to write the output to (default stdout). In parquet mode this is the output directory (default the current directory)")
+	DumpblocksCmd.PersistentFlags().StringVarP(&inputDumpblocks.Mode, "mode", "m", "json", "the output format [json, proto, parquet]")
 	DumpblocksCmd.PersistentFlags().Uint64VarP(&inputDumpblocks.BatchSize, "batch-size", "b", 150, "the batch size. Realistically, this probably shouldn't be bigger than 999. Most providers seem to cap at 1000.")
+	DumpblocksCmd.PersistentFlags().StringVar(&inputDumpblocks.Checkpoint, "checkpoint", "", "a file recording the progress of each worker so an interrupted dump can be resumed by running the same command again")
+	DumpblocksCmd.PersistentFlags().UintVar(&inputDumpblocks.Retries, "retries", 5, "how many times to retry a failed request before the worker stops")
 	DumpblocksCmd.PersistentFlags().StringVarP(&inputDumpblocks.FilterStr, "filter", "F", "{}", "filter output based on tx to and from, not setting a filter means all are allowed")
 }
 
+// dumpRange fetches and writes the blocks, receipts, logs, and traces in the
+// inclusive range. Each request is retried so a network flap doesn't stop the
+// dump, and nothing is written until everything has been fetched so a failed
+// range can be dumped again without duplicates.
+func dumpRange(ctx context.Context, ec *ethrpc.Client, rangeStart, rangeEnd uint64) error {
+	var blocks, receipts, logs, traces []*json.RawMessage
+
+	if inputDumpblocks.ShouldDumpBlocks || inputDumpblocks.ShouldDumpReceipts {
+		err := retry(ctx, func() (err error) {
+			blocks, err = util.GetBlockRange(ctx, rangeStart, rangeEnd, ec)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to fetch blocks: %w", err)
+		}
+		blocks = filterBlocks(blocks)
+	}
+
+	if inputDumpblocks.ShouldDumpReceipts {
+		err := retry(ctx, func() (err error) {
+			receipts, err = util.GetReceipts(ctx, blocks, ec, inputDumpblocks.BatchSize)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to fetch receipts: %w", err)
+		}
+		receipts = filterReceipts(receipts)
+	}
+
+	if inputDumpblocks.ShouldDumpLogs {
+		err := retry(ctx, func() (err error) {
+			logs, err = getLogs(ctx, ec, rangeStart, rangeEnd)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to fetch logs: %w", err)
+		}
+	}
+
+	if inputDumpblocks.ShouldDumpTraces {
+		err := retry(ctx, func() (err error) {
+			traces, err = getTraces(ctx, ec, rangeStart, rangeEnd)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to fetch traces: %w", err)
+		}
+	}
+
+	if inputDumpblocks.ShouldDumpBlocks {
+		if err := writeResponses(blocks, "block"); err != nil {
+			log.Error().Err(err).Msg("Error writing blocks")
+		}
+	}
+	if inputDumpblocks.ShouldDumpReceipts {
+		if err := writeResponses(receipts, "transaction"); err != nil {
+			log.Error().Err(err).Msg("Error writing receipts")
+		}
+	}
+	if inputDumpblocks.ShouldDumpLogs {
+		if err := writeResponses(logs, "log"); err != nil {
+			log.Error().Err(err).Msg("Error writing logs")
+		}
+	}
+	if inputDumpblocks.ShouldDumpTraces {
+		if err := writeResponses(traces, "trace"); err != nil {
+			log.Error().Err(err).Msg("Error writing traces")
+		}
+	}
+
+	return nil
+}
+
+// retry calls f until it succeeds, backing off a little more after each
+// failure, and gives up after the configured number of retries.
+func retry(ctx context.Context, f func() error) error {
+	var err error
+	for attempt := uint(0); attempt <= inputDumpblocks.Retries; attempt++ {
+		if err = f(); err == nil {
+			return nil
+		}
+		log.Warn().Err(err).Uint("attempt", attempt+1).Msg("Request failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * 5 * time.Second):
+		}
+	}
+	return err
+}
+
 // writeResponses writes the data to either stdout or a file if one is provided.
-// The message type can be either "block" or "transaction". The format of the
-// output is either "json" or "proto" depending on the mode.
+// The message type can be either "block", "transaction", "log", or "trace". The format of the
+// output is either "json", "proto", or "parquet" depending on the mode.
 func writeResponses(msg []*json.RawMessage, msgType string) error {
 	switch inputDumpblocks.Mode {
+	case "parquet":
+		var err error
+		switch msgType {
+		case "block":
+			err = parquetOut.writeBlocks(msg)
+		case "transaction":
+			err = parquetOut.writeReceipts(msg)
+		case "log":
+			err = parquetOut.writeLogs(msg)
+		case "trace":
+			err = parquetOut.writeTraces(msg)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to write %s parquet", msgType)
+		}
 	case "json":
 		if err := writeJSON(msg); err != nil {
 			log.Error().Err(err).Msgf("Failed to write %s json", msgType)
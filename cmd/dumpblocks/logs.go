@@ -0,0 +1,73 @@
+package dumpblocks
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/slices"
+)
+
+// getLogs fetches the logs in the block range that match the address and
+// topic0 filters. Both ends of the range are inclusive like the block range.
+func getLogs(ctx context.Context, ec *ethrpc.Client, start, end uint64) ([]*json.RawMessage, error) {
+	filter := map[string]interface{}{
+		"fromBlock": "0x" + strconv.FormatUint(start, 16),
+		"toBlock":   "0x" + strconv.FormatUint(end, 16),
+	}
+	if len(inputDumpblocks.LogAddresses) > 0 {
+		filter["address"] = inputDumpblocks.LogAddresses
+	}
+	if len(inputDumpblocks.LogTopics) > 0 {
+		filter["topics"] = []interface{}{inputDumpblocks.LogTopics}
+	}
+
+	var logs []*json.RawMessage
+	if err := ec.CallContext(ctx, &logs, "eth_getLogs", filter); err != nil {
+		return nil, err
+	}
+	log.Trace().Uint64("start", start).Uint64("end", end).Int("logs", len(logs)).Msg("Fetched logs")
+	return logs, nil
+}
+
+// filterReceipts keeps the receipts with at least one log matching the address
+// and topic0 filters. If neither filter is set, all receipts are kept.
+func filterReceipts(receipts []*json.RawMessage) []*json.RawMessage {
+	if len(inputDumpblocks.LogAddresses) == 0 && len(inputDumpblocks.LogTopics) == 0 {
+		return receipts
+	}
+
+	filtered := []*json.RawMessage{}
+	for _, msg := range receipts {
+		var receipt rpctypes.RawTxReceipt
+		if err := json.Unmarshal(*msg, &receipt); err != nil {
+			log.Error().Bytes("receipt", *msg).Msg("Unable to unmarshal receipt")
+			continue
+		}
+
+		for _, l := range receipt.Logs {
+			if matchesLogFilter(l) {
+				filtered = append(filtered, msg)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+func matchesLogFilter(l rpctypes.RawTxLogs) bool {
+	if len(inputDumpblocks.LogAddresses) > 0 && !slices.Contains(inputDumpblocks.LogAddresses, strings.ToLower(string(l.Address))) {
+		return false
+	}
+	if len(inputDumpblocks.LogTopics) > 0 {
+		if len(l.Topics) == 0 || !slices.Contains(inputDumpblocks.LogTopics, strings.ToLower(string(l.Topics[0]))) {
+			return false
+		}
+	}
+	return true
+}
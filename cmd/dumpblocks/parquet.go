@@ -0,0 +1,375 @@
+package dumpblocks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/rs/zerolog/log"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type (
+	// parquetBlock is a row in blocks.parquet. The column names and types are
+	// part of the output format so they shouldn't be changed. Quantities that
+	// can exceed 64 bits are written as decimal strings.
+	parquetBlock struct {
+		Number           int64   `parquet:"name=number, type=INT64"`
+		Hash             string  `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+		ParentHash       string  `parquet:"name=parent_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Timestamp        int64   `parquet:"name=timestamp, type=INT64"`
+		Miner            string  `parquet:"name=miner, type=BYTE_ARRAY, convertedtype=UTF8"`
+		GasLimit         int64   `parquet:"name=gas_limit, type=INT64"`
+		GasUsed          int64   `parquet:"name=gas_used, type=INT64"`
+		BaseFeePerGas    *string `parquet:"name=base_fee_per_gas, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Difficulty       string  `parquet:"name=difficulty, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Size             int64   `parquet:"name=size, type=INT64"`
+		Nonce            string  `parquet:"name=nonce, type=BYTE_ARRAY, convertedtype=UTF8"`
+		ExtraData        string  `parquet:"name=extra_data, type=BYTE_ARRAY, convertedtype=UTF8"`
+		StateRoot        string  `parquet:"name=state_root, type=BYTE_ARRAY, convertedtype=UTF8"`
+		TransactionsRoot string  `parquet:"name=transactions_root, type=BYTE_ARRAY, convertedtype=UTF8"`
+		ReceiptsRoot     string  `parquet:"name=receipts_root, type=BYTE_ARRAY, convertedtype=UTF8"`
+		TransactionCount int64   `parquet:"name=transaction_count, type=INT64"`
+		UncleCount       int64   `parquet:"name=uncle_count, type=INT64"`
+	}
+
+	// parquetTransaction is a row in transactions.parquet.
+	parquetTransaction struct {
+		BlockNumber          int64   `parquet:"name=block_number, type=INT64"`
+		BlockHash            string  `parquet:"name=block_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Hash                 string  `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+		TransactionIndex     int64   `parquet:"name=transaction_index, type=INT64"`
+		Type                 int64   `parquet:"name=type, type=INT64"`
+		From                 string  `parquet:"name=from, type=BYTE_ARRAY, convertedtype=UTF8"`
+		To                   *string `parquet:"name=to, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Nonce                int64   `parquet:"name=nonce, type=INT64"`
+		Value                string  `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Gas                  int64   `parquet:"name=gas, type=INT64"`
+		GasPrice             string  `parquet:"name=gas_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+		MaxFeePerGas         *string `parquet:"name=max_fee_per_gas, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		MaxPriorityFeePerGas *string `parquet:"name=max_priority_fee_per_gas, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		ChainID              *string `parquet:"name=chain_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Input                string  `parquet:"name=input, type=BYTE_ARRAY, convertedtype=UTF8"`
+	}
+
+	// parquetReceipt is a row in receipts.parquet.
+	parquetReceipt struct {
+		BlockNumber       int64   `parquet:"name=block_number, type=INT64"`
+		BlockHash         string  `parquet:"name=block_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+		TransactionHash   string  `parquet:"name=transaction_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+		TransactionIndex  int64   `parquet:"name=transaction_index, type=INT64"`
+		From              string  `parquet:"name=from, type=BYTE_ARRAY, convertedtype=UTF8"`
+		To                *string `parquet:"name=to, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		ContractAddress   *string `parquet:"name=contract_address, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Status            int64   `parquet:"name=status, type=INT64"`
+		GasUsed           int64   `parquet:"name=gas_used, type=INT64"`
+		CumulativeGasUsed int64   `parquet:"name=cumulative_gas_used, type=INT64"`
+		EffectiveGasPrice *string `parquet:"name=effective_gas_price, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		LogCount          int64   `parquet:"name=log_count, type=INT64"`
+	}
+
+	// parquetLog is a row in logs.parquet. Unused topics are nulls.
+	parquetLog struct {
+		BlockNumber      int64   `parquet:"name=block_number, type=INT64"`
+		BlockHash        string  `parquet:"name=block_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+		TransactionHash  string  `parquet:"name=transaction_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+		TransactionIndex int64   `parquet:"name=transaction_index, type=INT64"`
+		LogIndex         int64   `parquet:"name=log_index, type=INT64"`
+		Address          string  `parquet:"name=address, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Topic0           *string `parquet:"name=topic0, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Topic1           *string `parquet:"name=topic1, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Topic2           *string `parquet:"name=topic2, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Topic3           *string `parquet:"name=topic3, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Data             string  `parquet:"name=data, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Removed          bool    `parquet:"name=removed, type=BOOLEAN"`
+	}
+
+	// parquetTrace is a row in traces.parquet. The trace is the raw JSON
+	// result because its shape depends on the method and tracer.
+	parquetTrace struct {
+		BlockNumber int64  `parquet:"name=block_number, type=INT64"`
+		Method      string `parquet:"name=method, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Trace       string `parquet:"name=trace, type=BYTE_ARRAY, convertedtype=UTF8"`
+	}
+
+	// parquetWriters writes each table to its own file in the output
+	// directory. The workers share the writers so every write holds the lock.
+	parquetWriters struct {
+		blocks       *writer.ParquetWriter
+		transactions *writer.ParquetWriter
+		receipts     *writer.ParquetWriter
+		logs         *writer.ParquetWriter
+		traces       *writer.ParquetWriter
+		files        []*os.File
+		lock         sync.Mutex
+	}
+)
+
+var parquetOut *parquetWriters
+
+// newParquetWriters creates blocks.parquet, transactions.parquet,
+// receipts.parquet, logs.parquet, and traces.parquet in the directory. When
+// resuming, the files are numbered so the ones from earlier runs aren't
+// overwritten.
+func newParquetWriters(dir string, resume bool) (*parquetWriters, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	suffix := ""
+	if resume {
+		for i := 1; ; i++ {
+			suffix = fmt.Sprintf("-%d", i)
+			if _, err := os.Stat(filepath.Join(dir, "blocks"+suffix+".parquet")); errors.Is(err, os.ErrNotExist) {
+				break
+			}
+		}
+	}
+
+	w := &parquetWriters{}
+	create := func(name string, schema interface{}) (*writer.ParquetWriter, error) {
+		f, err := os.Create(filepath.Join(dir, name+suffix+".parquet"))
+		if err != nil {
+			return nil, err
+		}
+		w.files = append(w.files, f)
+
+		pw, err := writer.NewParquetWriterFromWriter(f, schema, 4)
+		if err != nil {
+			return nil, err
+		}
+		pw.CompressionType = parquet.CompressionCodec_ZSTD
+		return pw, nil
+	}
+
+	var err error
+	if w.blocks, err = create("blocks", new(parquetBlock)); err != nil {
+		w.close()
+		return nil, err
+	}
+	if w.transactions, err = create("transactions", new(parquetTransaction)); err != nil {
+		w.close()
+		return nil, err
+	}
+	if w.receipts, err = create("receipts", new(parquetReceipt)); err != nil {
+		w.close()
+		return nil, err
+	}
+	if w.logs, err = create("logs", new(parquetLog)); err != nil {
+		w.close()
+		return nil, err
+	}
+	if w.traces, err = create("traces", new(parquetTrace)); err != nil {
+		w.close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// writeBlocks writes the blocks and their transactions.
+func (w *parquetWriters) writeBlocks(msg []*json.RawMessage) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, m := range msg {
+		var b rpctypes.RawBlockResponse
+		if err := json.Unmarshal(*m, &b); err != nil {
+			log.Error().Err(err).RawJSON("msg", *m).Msg("Failed to unmarshal block")
+			continue
+		}
+		if err := w.blocks.Write(newParquetBlock(&b)); err != nil {
+			return err
+		}
+		for i := range b.Transactions {
+			if err := w.transactions.Write(newParquetTransaction(&b.Transactions[i])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeReceipts writes the receipts.
+func (w *parquetWriters) writeReceipts(msg []*json.RawMessage) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, m := range msg {
+		var r rpctypes.RawTxReceipt
+		if err := json.Unmarshal(*m, &r); err != nil {
+			log.Error().Err(err).RawJSON("msg", *m).Msg("Failed to unmarshal receipt")
+			continue
+		}
+		if err := w.receipts.Write(newParquetReceipt(&r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLogs writes the logs.
+func (w *parquetWriters) writeLogs(msg []*json.RawMessage) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, m := range msg {
+		var l rpctypes.RawTxLogs
+		if err := json.Unmarshal(*m, &l); err != nil {
+			log.Error().Err(err).RawJSON("msg", *m).Msg("Failed to unmarshal log")
+			continue
+		}
+		if err := w.logs.Write(newParquetLog(&l)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTraces writes the block traces.
+func (w *parquetWriters) writeTraces(msg []*json.RawMessage) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, m := range msg {
+		var t blockTrace
+		if err := json.Unmarshal(*m, &t); err != nil {
+			log.Error().Err(err).RawJSON("msg", *m).Msg("Failed to unmarshal trace")
+			continue
+		}
+		number := rpctypes.RawQuantityResponse(t.BlockNumber)
+		row := parquetTrace{BlockNumber: number.ToInt64(), Method: t.Method, Trace: string(t.Result)}
+		if err := w.traces.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close writes the footers and closes the files. The files aren't readable
+// until this is called.
+func (w *parquetWriters) close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var firstErr error
+	for _, pw := range []*writer.ParquetWriter{w.blocks, w.transactions, w.receipts, w.logs, w.traces} {
+		if pw == nil {
+			continue
+		}
+		if err := pw.WriteStop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, f := range w.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	w.files = nil
+	return firstErr
+}
+
+func newParquetBlock(b *rpctypes.RawBlockResponse) parquetBlock {
+	return parquetBlock{
+		Number:           b.Number.ToInt64(),
+		Hash:             string(b.Hash),
+		ParentHash:       string(b.ParentHash),
+		Timestamp:        b.Timestamp.ToInt64(),
+		Miner:            string(b.Miner),
+		GasLimit:         b.GasLimit.ToInt64(),
+		GasUsed:          b.GasUsed.ToInt64(),
+		BaseFeePerGas:    optionalQuantity(b.BaseFeePerGas),
+		Difficulty:       b.Difficulty.String(),
+		Size:             b.Size.ToInt64(),
+		Nonce:            string(b.Nonce),
+		ExtraData:        string(b.ExtraData),
+		StateRoot:        string(b.StateRoot),
+		TransactionsRoot: string(b.TransactionsRoot),
+		ReceiptsRoot:     string(b.ReceiptsRoot),
+		TransactionCount: int64(len(b.Transactions)),
+		UncleCount:       int64(len(b.Uncles)),
+	}
+}
+
+func newParquetTransaction(tx *rpctypes.RawTransactionResponse) parquetTransaction {
+	return parquetTransaction{
+		BlockNumber:          tx.BlockNumber.ToInt64(),
+		BlockHash:            string(tx.BlockHash),
+		Hash:                 string(tx.Hash),
+		TransactionIndex:     tx.TransactionIndex.ToInt64(),
+		Type:                 tx.Type.ToInt64(),
+		From:                 string(tx.From),
+		To:                   optionalString(string(tx.To)),
+		Nonce:                tx.Nonce.ToInt64(),
+		Value:                tx.Value.String(),
+		Gas:                  tx.Gas.ToInt64(),
+		GasPrice:             tx.GasPrice.String(),
+		MaxFeePerGas:         optionalQuantity(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: optionalQuantity(tx.MaxPriorityFeePerGas),
+		ChainID:              optionalQuantity(tx.ChainID),
+		Input:                string(tx.Input),
+	}
+}
+
+func newParquetReceipt(r *rpctypes.RawTxReceipt) parquetReceipt {
+	return parquetReceipt{
+		BlockNumber:       r.BlockNumber.ToInt64(),
+		BlockHash:         string(r.BlockHash),
+		TransactionHash:   string(r.TransactionHash),
+		TransactionIndex:  r.TransactionIndex.ToInt64(),
+		From:              string(r.From),
+		To:                optionalString(string(r.To)),
+		ContractAddress:   optionalString(string(r.ContractAddress)),
+		Status:            r.Status.ToInt64(),
+		GasUsed:           r.GasUsed.ToInt64(),
+		CumulativeGasUsed: r.CumulativeGasUsed.ToInt64(),
+		EffectiveGasPrice: optionalQuantity(r.EffectiveGasPrice),
+		LogCount:          int64(len(r.Logs)),
+	}
+}
+
+func newParquetLog(l *rpctypes.RawTxLogs) parquetLog {
+	row := parquetLog{
+		BlockNumber:      l.BlockNumber.ToInt64(),
+		BlockHash:        string(l.BlockHash),
+		TransactionHash:  string(l.TransactionHash),
+		TransactionIndex: l.TransactionIndex.ToInt64(),
+		LogIndex:         l.LogIndex.ToInt64(),
+		Address:          string(l.Address),
+		Data:             string(l.Data),
+		Removed:          l.Removed,
+	}
+	for i, topic := range []**string{&row.Topic0, &row.Topic1, &row.Topic2, &row.Topic3} {
+		if i < len(l.Topics) {
+			*topic = optionalString(string(l.Topics[i]))
+		}
+	}
+	return row
+}
+
+// optionalString returns nil for missing values so they're written as nulls.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// optionalQuantity returns the quantity as a decimal string, or nil if it's
+// missing.
+func optionalQuantity(q rpctypes.RawQuantityResponse) *string {
+	if q == "" {
+		return nil
+	}
+	s := q.String()
+	return &s
+}
@@ -0,0 +1,75 @@
+package dumpblocks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// traceMethods are the supported values of --trace-method.
+var traceMethods = []string{"debug", "trace", "state-diff"}
+
+// blockTrace is the trace of a single block as it's written to the output.
+// The result is kept as the raw response because its shape depends on the
+// method and tracer.
+type blockTrace struct {
+	BlockNumber string          `json:"blockNumber"`
+	Method      string          `json:"method"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// traceCall returns the method and params used to trace a block.
+func traceCall(block string) (string, []interface{}) {
+	switch inputDumpblocks.TraceMethod {
+	case "trace":
+		return "trace_block", []interface{}{block}
+	case "state-diff":
+		return "trace_replayBlockTransactions", []interface{}{block, []string{"stateDiff"}}
+	default:
+		config := map[string]interface{}{"tracer": inputDumpblocks.Tracer}
+		if inputDumpblocks.tracerConfig != nil {
+			config["tracerConfig"] = inputDumpblocks.tracerConfig
+		}
+		return "debug_traceBlockByNumber", []interface{}{block, config}
+	}
+}
+
+// getTraces traces every block in the inclusive range in a single batch.
+func getTraces(ctx context.Context, ec *ethrpc.Client, start, end uint64) ([]*json.RawMessage, error) {
+	blms := make([]ethrpc.BatchElem, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		method, args := traceCall("0x" + strconv.FormatUint(i, 16))
+		blms = append(blms, ethrpc.BatchElem{
+			Method: method,
+			Args:   args,
+			Result: new(json.RawMessage),
+		})
+	}
+	log.Trace().Uint64("start", start).Uint64("end", end).Msg("Fetching traces")
+
+	if err := ec.BatchCallContext(ctx, blms); err != nil {
+		return nil, err
+	}
+
+	traces := make([]*json.RawMessage, 0, len(blms))
+	for _, b := range blms {
+		if b.Error != nil {
+			return nil, fmt.Errorf("unable to trace block %v: %w", b.Args[0], b.Error)
+		}
+		data, err := json.Marshal(blockTrace{
+			BlockNumber: b.Args[0].(string),
+			Method:      b.Method,
+			Result:      *b.Result.(*json.RawMessage),
+		})
+		if err != nil {
+			return nil, err
+		}
+		msg := json.RawMessage(data)
+		traces = append(traces, &msg)
+	}
+	return traces, nil
+}
@@ -0,0 +1,558 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	_ "embed"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// The error codes of the Engine API.
+const (
+	errUnknownPayload           = -38001
+	errInvalidPayloadAttributes = -38003
+)
+
+// The statuses of payloads.
+const (
+	statusValid            = "VALID"
+	statusInvalid          = "INVALID"
+	statusSyncing          = "SYNCING"
+	statusAccepted         = "ACCEPTED"
+	statusInvalidBlockHash = "INVALID_BLOCK_HASH"
+)
+
+type (
+	engineParams struct {
+		RPCURL       string
+		Blocks       int
+		Version      int
+		BuildTime    time.Duration
+		FeeRecipient string
+		Checks       bool
+		Seed         int64
+
+		feeRecipient ethcommon.Address
+	}
+
+	forkchoiceState struct {
+		HeadBlockHash      ethcommon.Hash `json:"headBlockHash"`
+		SafeBlockHash      ethcommon.Hash `json:"safeBlockHash"`
+		FinalizedBlockHash ethcommon.Hash `json:"finalizedBlockHash"`
+	}
+
+	// payloadAttributes has the fields of every version. The withdrawals
+	// are only sent from V2 and the parent beacon block root from V3.
+	payloadAttributes struct {
+		Timestamp             hexutil.Uint64    `json:"timestamp"`
+		PrevRandao            ethcommon.Hash    `json:"prevRandao"`
+		SuggestedFeeRecipient ethcommon.Address `json:"suggestedFeeRecipient"`
+		Withdrawals           *[]any            `json:"withdrawals,omitempty"`
+		ParentBeaconBlockRoot *ethcommon.Hash   `json:"parentBeaconBlockRoot,omitempty"`
+	}
+
+	payloadStatus struct {
+		Status          string          `json:"status"`
+		LatestValidHash *ethcommon.Hash `json:"latestValidHash"`
+		ValidationError *string         `json:"validationError"`
+	}
+
+	forkchoiceResponse struct {
+		PayloadStatus payloadStatus  `json:"payloadStatus"`
+		PayloadID     *hexutil.Bytes `json:"payloadId"`
+	}
+
+	// executionPayload has the fields of the payload we look at. The
+	// payload is sent back to the client as it was returned.
+	executionPayload struct {
+		BlockHash    ethcommon.Hash  `json:"blockHash"`
+		BlockNumber  hexutil.Uint64  `json:"blockNumber"`
+		GasUsed      hexutil.Uint64  `json:"gasUsed"`
+		Transactions []hexutil.Bytes `json:"transactions"`
+	}
+
+	// builtPayload is the result of engine_getPayload.
+	builtPayload struct {
+		raw             json.RawMessage
+		payload         executionPayload
+		versionedHashes []ethcommon.Hash
+		beaconRoot      ethcommon.Hash
+	}
+
+	head struct {
+		Hash      ethcommon.Hash `json:"hash"`
+		Number    hexutil.Uint64 `json:"number"`
+		Timestamp hexutil.Uint64 `json:"timestamp"`
+	}
+
+	blockResult struct {
+		Number       uint64         `json:"number"`
+		Hash         ethcommon.Hash `json:"hash"`
+		Transactions int            `json:"transactions"`
+		GasUsed      uint64         `json:"gasUsed"`
+		// BuildTime is the time from the request to build the payload to
+		// the client setting it as the head.
+		BuildTime time.Duration `json:"buildTime"`
+		Error     string        `json:"error,omitempty"`
+	}
+
+	checkResult struct {
+		Name   string `json:"name"`
+		Passed bool   `json:"passed"`
+		Detail string `json:"detail,omitempty"`
+	}
+
+	methodReport struct {
+		Method string  `json:"method"`
+		Calls  int     `json:"calls"`
+		P50    float64 `json:"p50"`
+		P90    float64 `json:"p90"`
+		P99    float64 `json:"p99"`
+		Max    float64 `json:"max"`
+
+		latencies []time.Duration
+	}
+
+	report struct {
+		Blocks       int             `json:"blocks"`
+		Errors       int             `json:"errors"`
+		Transactions int             `json:"transactions"`
+		GasUsed      uint64          `json:"gasUsed"`
+		Checks       []checkResult   `json:"checks,omitempty"`
+		Methods      []*methodReport `json:"methods"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage       string
+	inputEngine engineParams
+)
+
+var EngineCmd = &cobra.Command{
+	Use:   "engine",
+	Short: "Build payloads and check the responses of an execution client over the Engine API.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("this command expects no arguments")
+		}
+		if inputEngine.Version < 1 || inputEngine.Version > 3 {
+			return fmt.Errorf("the engine api version needs to be 1, 2, or 3")
+		}
+		if inputEngine.Blocks < 0 {
+			return fmt.Errorf("the number of blocks can't be negative")
+		}
+		if !ethcommon.IsHexAddress(inputEngine.FeeRecipient) {
+			return fmt.Errorf("the fee recipient %s isn't an address", inputEngine.FeeRecipient)
+		}
+		inputEngine.feeRecipient = ethcommon.HexToAddress(inputEngine.FeeRecipient)
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		rpc, err := util.DialRPC(ctx, inputEngine.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Str("url", inputEngine.RPCURL).Msg("Unable to dial rpc")
+			return err
+		}
+		defer rpc.Close()
+
+		d := newDriver(rpc)
+		r := d.run(ctx)
+		return printReport(r)
+	},
+}
+
+// driver acts as the consensus client of the execution client. It builds
+// the blocks on top of the head one after another.
+type driver struct {
+	rpc     *ethrpc.Client
+	rng     *rand.Rand
+	methods map[string]*methodReport
+	report  *report
+}
+
+func newDriver(rpc *ethrpc.Client) *driver {
+	d := &driver{
+		rpc:     rpc,
+		rng:     rand.New(rand.NewSource(inputEngine.Seed)),
+		methods: make(map[string]*methodReport),
+		report:  &report{},
+	}
+	for _, method := range []string{"engine_forkchoiceUpdated", "engine_getPayload", "engine_newPayload"} {
+		m := &methodReport{Method: fmt.Sprintf("%sV%d", method, inputEngine.Version)}
+		d.methods[m.Method] = m
+		d.report.Methods = append(d.report.Methods, m)
+	}
+	return d
+}
+
+func (d *driver) run(ctx context.Context) *report {
+	current, err := d.getHead(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the head")
+		d.report.Errors++
+		return d.report
+	}
+	log.Info().Uint64("number", uint64(current.Number)).Stringer("hash", current.Hash).Int("version", inputEngine.Version).Msg("Starting from the head")
+
+	if inputEngine.Checks {
+		d.runChecks(ctx, current)
+	}
+	for i := 0; i < inputEngine.Blocks && ctx.Err() == nil; i++ {
+		res, next := d.buildBlock(ctx, current, inputEngine.Checks && i == 0)
+		d.report.Blocks++
+		if res.Error != "" {
+			d.report.Errors++
+		} else {
+			d.report.Transactions += res.Transactions
+			d.report.GasUsed += res.GasUsed
+			current = next
+		}
+		printResult(res)
+	}
+
+	for _, m := range d.report.Methods {
+		m.summarize()
+	}
+	return d.report
+}
+
+// buildBlock has the client build a payload on top of the head, imports it,
+// and makes it the head. The first block also checks how the client handles
+// the payload being sent again or with the wrong hash.
+func (d *driver) buildBlock(ctx context.Context, parent *head, check bool) (*blockResult, *head) {
+	res := &blockResult{Number: uint64(parent.Number) + 1}
+	start := time.Now()
+	attrs := d.getAttributes(parent)
+	fcu, err := d.forkchoiceUpdated(ctx, parent.Hash, attrs)
+	if err == nil && (fcu.PayloadStatus.Status != statusValid || fcu.PayloadID == nil) {
+		err = fmt.Errorf("the client didn't start building a payload, the status is %s", fcu.PayloadStatus.Status)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+
+	// The client adds transactions to the payload until it's requested
+	time.Sleep(inputEngine.BuildTime)
+	built, err := d.getPayload(ctx, *fcu.PayloadID)
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+	if attrs.ParentBeaconBlockRoot != nil {
+		built.beaconRoot = *attrs.ParentBeaconBlockRoot
+	}
+	res.Hash = built.payload.BlockHash
+	res.Transactions = len(built.payload.Transactions)
+	res.GasUsed = uint64(built.payload.GasUsed)
+
+	status, err := d.newPayload(ctx, built)
+	if err == nil && status.Status != statusValid {
+		err = fmt.Errorf("the client didn't accept the payload it built, the status is %s", status.Status)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+	if check {
+		d.checkPayload(ctx, built)
+	}
+
+	fcu, err = d.forkchoiceUpdated(ctx, built.payload.BlockHash, nil)
+	if err == nil && fcu.PayloadStatus.Status != statusValid {
+		err = fmt.Errorf("the client didn't make the payload the head, the status is %s", fcu.PayloadStatus.Status)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+	res.BuildTime = time.Since(start)
+	return res, &head{Hash: built.payload.BlockHash, Number: built.payload.BlockNumber, Timestamp: attrs.Timestamp}
+}
+
+func (d *driver) getAttributes(parent *head) *payloadAttributes {
+	timestamp := uint64(time.Now().Unix())
+	if timestamp <= uint64(parent.Timestamp) {
+		timestamp = uint64(parent.Timestamp) + 1
+	}
+	attrs := &payloadAttributes{
+		Timestamp:             hexutil.Uint64(timestamp),
+		SuggestedFeeRecipient: inputEngine.feeRecipient,
+	}
+	d.rng.Read(attrs.PrevRandao[:])
+	if inputEngine.Version >= 2 {
+		withdrawals := []any{}
+		attrs.Withdrawals = &withdrawals
+	}
+	if inputEngine.Version >= 3 {
+		var root ethcommon.Hash
+		d.rng.Read(root[:])
+		attrs.ParentBeaconBlockRoot = &root
+	}
+	return attrs
+}
+
+func (d *driver) getHead(ctx context.Context) (*head, error) {
+	var h head
+	if err := d.rpc.CallContext(ctx, &h, "eth_getBlockByNumber", "latest", false); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// call sends an Engine API call and records its latency.
+func (d *driver) call(ctx context.Context, result any, method string, args ...any) error {
+	method = fmt.Sprintf("%sV%d", method, inputEngine.Version)
+	start := time.Now()
+	err := d.rpc.CallContext(ctx, result, method, args...)
+	if m, ok := d.methods[method]; ok {
+		m.Calls++
+		m.latencies = append(m.latencies, time.Since(start))
+	}
+	return err
+}
+
+func (d *driver) forkchoiceUpdated(ctx context.Context, hash ethcommon.Hash, attrs *payloadAttributes) (*forkchoiceResponse, error) {
+	// The safe and finalized blocks are left unset, so the client doesn't
+	// finalize the blocks we build.
+	state := forkchoiceState{HeadBlockHash: hash}
+	var res forkchoiceResponse
+	if err := d.call(ctx, &res, "engine_forkchoiceUpdated", state, attrs); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *driver) getPayload(ctx context.Context, id hexutil.Bytes) (*builtPayload, error) {
+	var raw json.RawMessage
+	if err := d.call(ctx, &raw, "engine_getPayload", id); err != nil {
+		return nil, err
+	}
+	built := &builtPayload{raw: raw}
+	// From V2, the payload comes with its value and blobs
+	if inputEngine.Version >= 2 {
+		var envelope struct {
+			ExecutionPayload json.RawMessage `json:"executionPayload"`
+			BlobsBundle      *struct {
+				Commitments []hexutil.Bytes `json:"commitments"`
+			} `json:"blobsBundle"`
+		}
+		if err := json.Unmarshal(raw, &envelope); err != nil {
+			return nil, err
+		}
+		built.raw = envelope.ExecutionPayload
+		if envelope.BlobsBundle != nil {
+			for _, commitment := range envelope.BlobsBundle.Commitments {
+				built.versionedHashes = append(built.versionedHashes, getVersionedHash(commitment))
+			}
+		}
+	}
+	if err := json.Unmarshal(built.raw, &built.payload); err != nil {
+		return nil, err
+	}
+	return built, nil
+}
+
+// getVersionedHash returns the hash of a blob commitment that its
+// transaction refers to.
+func getVersionedHash(commitment []byte) ethcommon.Hash {
+	h := ethcommon.Hash(sha256.Sum256(commitment))
+	h[0] = 0x01
+	return h
+}
+
+func (d *driver) newPayload(ctx context.Context, built *builtPayload) (*payloadStatus, error) {
+	args := []any{built.raw}
+	if inputEngine.Version >= 3 {
+		hashes := built.versionedHashes
+		if hashes == nil {
+			hashes = []ethcommon.Hash{}
+		}
+		args = append(args, hashes, built.beaconRoot)
+	}
+	var status payloadStatus
+	if err := d.call(ctx, &status, "engine_newPayload", args...); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// runChecks checks that the client handles requests it can't serve the way
+// the Engine API specifies.
+func (d *driver) runChecks(ctx context.Context, current *head) {
+	var id [8]byte
+	d.rng.Read(id[:])
+	_, err := d.getPayload(ctx, id[:])
+	d.addCheck("getPayload of an unknown payload", getErrorCode(err) == errUnknownPayload, "expected error %d, got %v", errUnknownPayload, err)
+
+	var unknown ethcommon.Hash
+	d.rng.Read(unknown[:])
+	fcu, err := d.forkchoiceUpdated(ctx, unknown, nil)
+	d.addCheck("forkchoiceUpdated to an unknown head", err == nil && fcu.PayloadStatus.Status == statusSyncing, "expected %s, got %s", statusSyncing, describe(fcu, err))
+
+	// The timestamp of a payload needs to be after the one of its parent
+	attrs := d.getAttributes(current)
+	attrs.Timestamp = current.Timestamp
+	_, err = d.forkchoiceUpdated(ctx, current.Hash, attrs)
+	d.addCheck("forkchoiceUpdated with a stale timestamp", getErrorCode(err) == errInvalidPayloadAttributes, "expected error %d, got %v", errInvalidPayloadAttributes, err)
+}
+
+// checkPayload sends the payload again, which the client should accept,
+// and with the wrong block hash, which it should reject.
+func (d *driver) checkPayload(ctx context.Context, built *builtPayload) {
+	status, err := d.newPayload(ctx, built)
+	d.addCheck("newPayload of a known payload", err == nil && status.Status == statusValid, "expected %s, got %s", statusValid, describe(status, err))
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(built.raw, &fields); err != nil {
+		d.addCheck("newPayload with the wrong block hash", false, "unable to decode the payload: %v", err)
+		return
+	}
+	var wrong ethcommon.Hash
+	d.rng.Read(wrong[:])
+	fields["blockHash"], _ = json.Marshal(wrong)
+	tampered := *built
+	tampered.raw, _ = json.Marshal(fields)
+	status, err = d.newPayload(ctx, &tampered)
+	// V1 has a status of its own for this, and later versions use INVALID
+	d.addCheck("newPayload with the wrong block hash", err == nil && (status.Status == statusInvalid || status.Status == statusInvalidBlockHash), "expected %s, got %s", statusInvalid, describe(status, err))
+}
+
+func (d *driver) addCheck(name string, passed bool, format string, args ...any) {
+	c := checkResult{Name: name, Passed: passed}
+	if !passed {
+		c.Detail = fmt.Sprintf(format, args...)
+	}
+	d.report.Checks = append(d.report.Checks, c)
+	if util.JSONOutput() {
+		return
+	}
+	if passed {
+		log.Info().Str("check", name).Msg("Passed")
+	} else {
+		log.Warn().Str("check", name).Str("detail", c.Detail).Msg("Failed")
+	}
+}
+
+func getErrorCode(err error) int {
+	var rpcErr ethrpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode()
+	}
+	return 0
+}
+
+// describe returns the status of a response or its error.
+func describe(res any, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	switch r := res.(type) {
+	case *forkchoiceResponse:
+		return r.PayloadStatus.Status
+	case *payloadStatus:
+		return r.Status
+	}
+	return fmt.Sprint(res)
+}
+
+func (m *methodReport) summarize() {
+	if len(m.latencies) == 0 {
+		return
+	}
+	sort.Slice(m.latencies, func(i, j int) bool {
+		return m.latencies[i] < m.latencies[j]
+	})
+	percentile := func(p float64) float64 {
+		return m.latencies[int(p*float64(len(m.latencies)-1))].Seconds()
+	}
+	m.P50 = percentile(0.5)
+	m.P90 = percentile(0.9)
+	m.P99 = percentile(0.99)
+	m.Max = percentile(1)
+}
+
+func printResult(res *blockResult) {
+	if util.JSONOutput() {
+		out, err := json.Marshal(res)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to marshal the result")
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
+	if res.Error != "" {
+		log.Error().Uint64("number", res.Number).Str("error", res.Error).Msg("Unable to build the block")
+		return
+	}
+	log.Info().
+		Uint64("number", res.Number).
+		Stringer("hash", res.Hash).
+		Int("transactions", res.Transactions).
+		Uint64("gasUsed", res.GasUsed).
+		Dur("buildTime", res.BuildTime).
+		Msg("Built block")
+}
+
+// printReport prints the report and fails when a block couldn't be built or
+// a check failed.
+func printReport(r *report) error {
+	failed := 0
+	for _, c := range r.Checks {
+		if !c.Passed {
+			failed++
+		}
+	}
+	if util.JSONOutput() {
+		out, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("blocks=%d errors=%d transactions=%d gasUsed=%d checks=%d failedChecks=%d\n", r.Blocks, r.Errors, r.Transactions, r.GasUsed, len(r.Checks), failed)
+		for _, m := range r.Methods {
+			fmt.Printf("  method=%s calls=%d p50=%.3fs p90=%.3fs p99=%.3fs max=%.3fs\n", m.Method, m.Calls, m.P50, m.P90, m.P99, m.Max)
+		}
+	}
+	if r.Errors > 0 {
+		return util.PartialFailure(fmt.Errorf("%d of the blocks couldn't be built", r.Errors))
+	}
+	if failed > 0 {
+		return util.AssertionFailure(fmt.Errorf("%d of the checks failed", failed))
+	}
+	return nil
+}
+
+func init() {
+	flagSet := EngineCmd.PersistentFlags()
+	flagSet.StringVarP(&inputEngine.RPCURL, "rpc-url", "r", "http://localhost:8551", "The Engine API endpoint of the execution client. The JWT secret is set with --rpc-jwt-secret")
+	flagSet.IntVarP(&inputEngine.Blocks, "blocks", "n", 10, "The number of blocks to build")
+	flagSet.IntVar(&inputEngine.Version, "engine-version", 3, "The version of the Engine API methods (1 | 2 | 3)")
+	flagSet.DurationVar(&inputEngine.BuildTime, "build-time", 500*time.Millisecond, "How long the client builds each payload before it's requested")
+	flagSet.StringVar(&inputEngine.FeeRecipient, "fee-recipient", "0x0000000000000000000000000000000000000000", "The address the fees of the blocks go to")
+	flagSet.BoolVar(&inputEngine.Checks, "checks", true, "Check that the client handles unknown payloads, unknown heads, stale timestamps, and wrong block hashes as specified")
+	flagSet.Int64Var(&inputEngine.Seed, "seed", 123456, "The seed of the random values of the payloads")
+}
@@ -0,0 +1,86 @@
+package enr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/maticnetwork/polygon-cli/p2p"
+)
+
+// discovery is a throwaway discv4 node used to query the discovery endpoint
+// of the input nodes.
+type discovery struct {
+	db   *enode.DB
+	disc *discover.UDPv4
+}
+
+// newDiscovery starts the discovery node. The bootnodes seed its table so
+// lookups are sent to them.
+func newDiscovery(bootnodes []*enode.Node) (*discovery, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	db, err := enode.OpenDB("")
+	if err != nil {
+		return nil, err
+	}
+	ln := enode.NewLocalNode(db, key)
+	conn, err := p2p.Listen(ln)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	disc, err := discover.ListenV4(conn, ln, discover.Config{PrivateKey: key, Bootnodes: bootnodes})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &discovery{db: db, disc: disc}, nil
+}
+
+func (d *discovery) Close() {
+	d.disc.Close()
+	d.db.Close()
+}
+
+// check pings the node and requests its current record. If findnode is set,
+// a lookup of the node's own key is run, which sends findnode to the node and
+// returns the closest nodes it and its neighbors know about.
+func (d *discovery) check(node *enode.Node, findnode bool) map[string]interface{} {
+	result := map[string]interface{}{"alive": false}
+	if node.UDP() == 0 {
+		result["error"] = "the node doesn't have a discovery port"
+		return result
+	}
+
+	start := time.Now()
+	if err := d.disc.Ping(node); err != nil {
+		result["error"] = fmt.Sprintf("ping failed: %s", err)
+		return result
+	}
+	result["alive"] = true
+	result["latency"] = time.Since(start).String()
+
+	if live, err := d.disc.RequestENR(node); err != nil {
+		result["enrError"] = err.Error()
+	} else {
+		result["seq"] = live.Seq()
+		result["enr"] = live.String()
+	}
+
+	if findnode {
+		neighbors := make([]string, 0)
+		for _, n := range d.disc.LookupPubkey(node.Pubkey()) {
+			if n.ID() != node.ID() && n.ID() != d.disc.Self().ID() {
+				neighbors = append(neighbors, n.URLv4())
+			}
+		}
+		result["neighbors"] = neighbors
+	}
+	return result
+}
@@ -1,6 +1,7 @@
 package enr
 
 import (
+	"crypto/ecdsa"
 	_ "embed"
 	"encoding/json"
 	"fmt"
@@ -10,13 +11,22 @@ import (
 	"os"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/maticnetwork/polygon-cli/util"
 )
 
 var (
 	//go:embed usage.md
-	usage         string
-	inputFileName *string
+	usage           string
+	inputFileName   *string
+	inputDecode     *bool
+	inputValidate   *bool
+	inputPing       *bool
+	inputFindnode   *bool
+	inputNodeKey    *string
+	inputNodeKeyHex *string
 )
 
 var ENRCmd = &cobra.Command{
@@ -31,6 +41,13 @@ var ENRCmd = &cobra.Command{
 		}
 		lines := strings.Split(string(rawData), "\n")
 
+		key, err := getNodeKey()
+		if err != nil {
+			return err
+		}
+
+		nodes := make([]*enode.Node, 0, len(lines))
+		invalid := 0
 		for _, l := range lines {
 			var node *enode.Node
 			var err error
@@ -38,23 +55,48 @@ var ENRCmd = &cobra.Command{
 			if l == "" {
 				continue
 			}
-			isENR := false
 			if strings.HasPrefix(l, "enr:") {
-				isENR = true
 				node, err = enode.Parse(enode.V4ID{}, l)
 				if err != nil {
 					log.Error().Err(err).Str("line", l).Msg("Unable to parse enr record")
+					invalid++
 					continue
 				}
 			} else {
 				node, err = enode.ParseV4(l)
 				if err != nil {
 					log.Error().Err(err).Str("line", l).Msg("Unable to parse node record")
+					invalid++
 					continue
 				}
+				if key != nil {
+					node, err = signNode(node, key)
+					if err != nil {
+						log.Error().Err(err).Str("line", l).Msg("Unable to sign node record")
+						invalid++
+						continue
+					}
+				}
+			}
+			nodes = append(nodes, node)
+		}
+
+		var disc *discovery
+		if *inputPing || *inputFindnode {
+			var bootnodes []*enode.Node
+			if *inputFindnode {
+				bootnodes = nodes
+			}
+			disc, err = newDiscovery(bootnodes)
+			if err != nil {
+				return fmt.Errorf("unable to start discovery: %w", err)
 			}
-			genericNode := make(map[string]string, 0)
-			if isENR {
+			defer disc.Close()
+		}
+
+		for _, node := range nodes {
+			genericNode := make(map[string]interface{}, 0)
+			if len(node.Record().Signature()) > 0 {
 				genericNode["enr"] = node.String()
 			}
 			genericNode["enode"] = node.URLv4()
@@ -62,6 +104,12 @@ var ENRCmd = &cobra.Command{
 			genericNode["ip"] = node.IP().String()
 			genericNode["tcp"] = fmt.Sprintf("%d", node.TCP())
 			genericNode["udp"] = fmt.Sprintf("%d", node.UDP())
+			if *inputDecode {
+				genericNode["record"] = decodeRecord(node.Record())
+			}
+			if disc != nil {
+				genericNode["discovery"] = disc.check(node, *inputFindnode)
+			}
 			jsonOut, err := json.Marshal(genericNode)
 			if err != nil {
 				log.Error().Err(err).Msg("unable to convert node to json")
@@ -69,9 +117,16 @@ var ENRCmd = &cobra.Command{
 			}
 			fmt.Println(string(jsonOut))
 		}
+
+		if *inputValidate && invalid > 0 {
+			return util.AssertionFailure(fmt.Errorf("%d of the input lines aren't valid node records", invalid))
+		}
 		return nil
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
+		if *inputNodeKey != "" && *inputNodeKeyHex != "" {
+			return fmt.Errorf("only one of --nodekey or --nodekey-hex can be set")
+		}
 		return nil
 	},
 }
@@ -79,7 +134,14 @@ var ENRCmd = &cobra.Command{
 func init() {
 	flagSet := ENRCmd.PersistentFlags()
 	inputFileName = flagSet.String("file", "", "Provide a file that's holding ENRs")
+	inputDecode = flagSet.Bool("decode", false, "Include every key/value pair of the record in the output")
+	inputValidate = flagSet.Bool("validate", false, "Exit with an error if any of the input lines isn't a valid record")
+	inputPing = flagSet.Bool("ping", false, "Ping the discovery endpoint of each node and request its current record")
+	inputFindnode = flagSet.Bool("findnode", false, "Also run a lookup through each node and print the nodes it returns")
+	inputNodeKey = flagSet.String("nodekey", "", "A node key file used to sign an ENR for enode inputs")
+	inputNodeKeyHex = flagSet.String("nodekey-hex", "", "A hex encoded node key used to sign an ENR for enode inputs")
 }
+
 func getInputData(cmd *cobra.Command, args []string) ([]byte, error) {
 	if inputFileName != nil && *inputFileName != "" {
 		return os.ReadFile(*inputFileName)
@@ -92,3 +154,36 @@ func getInputData(cmd *cobra.Command, args []string) ([]byte, error) {
 
 	return io.ReadAll(os.Stdin)
 }
+
+func getNodeKey() (*ecdsa.PrivateKey, error) {
+	switch {
+	case *inputNodeKey != "":
+		return crypto.LoadECDSA(*inputNodeKey)
+	case *inputNodeKeyHex != "":
+		return crypto.HexToECDSA(strings.TrimPrefix(*inputNodeKeyHex, "0x"))
+	}
+	return nil, nil
+}
+
+// signNode converts an enode URL into an ENR. An ENR has to be signed by the
+// node's own key, so the key has to match the public key in the URL.
+func signNode(node *enode.Node, key *ecdsa.PrivateKey) (*enode.Node, error) {
+	if enode.PubkeyToIDV4(&key.PublicKey) != node.ID() {
+		return nil, fmt.Errorf("the node key doesn't match the enode public key")
+	}
+	var r enr.Record
+	if ip := node.IP(); ip != nil {
+		r.Set(enr.IP(ip))
+	}
+	if node.TCP() != 0 {
+		r.Set(enr.TCP(node.TCP()))
+	}
+	if node.UDP() != 0 {
+		r.Set(enr.UDP(node.UDP()))
+	}
+	r.SetSeq(1)
+	if err := enode.SignV4(&r, key); err != nil {
+		return nil, err
+	}
+	return enode.New(enode.V4ID{}, &r)
+}
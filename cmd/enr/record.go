@@ -0,0 +1,65 @@
+package enr
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ethEntry is the "eth" entry advertised by execution clients.
+type ethEntry struct {
+	ForkID forkid.ID
+	Rest   []rlp.RawValue `rlp:"tail"`
+}
+
+// decodeRecord returns every key/value pair in the record. The keys defined by
+// the ENR spec and the eth fork ID are decoded, anything else is left as the
+// hex encoded RLP value.
+func decodeRecord(r *enr.Record) map[string]interface{} {
+	elements := r.AppendElements(nil)
+	decoded := map[string]interface{}{"seq": r.Seq()}
+	for i := 1; i+1 < len(elements); i += 2 {
+		key, _ := elements[i].(string)
+		raw, _ := elements[i+1].(rlp.RawValue)
+		decoded[key] = decodeValue(key, raw)
+	}
+	return decoded
+}
+
+func decodeValue(key string, raw rlp.RawValue) interface{} {
+	switch key {
+	case "id":
+		var id string
+		if rlp.DecodeBytes(raw, &id) == nil {
+			return id
+		}
+	case "secp256k1":
+		var pub []byte
+		if rlp.DecodeBytes(raw, &pub) == nil {
+			return hexutil.Encode(pub)
+		}
+	case "ip", "ip6":
+		var ip []byte
+		if rlp.DecodeBytes(raw, &ip) == nil {
+			return net.IP(ip).String()
+		}
+	case "tcp", "tcp6", "udp", "udp6", "quic":
+		var port uint16
+		if rlp.DecodeBytes(raw, &port) == nil {
+			return port
+		}
+	case "eth":
+		var entry ethEntry
+		if rlp.DecodeBytes(raw, &entry) == nil {
+			return map[string]string{
+				"hash": hexutil.Encode(entry.ForkID.Hash[:]),
+				"next": fmt.Sprintf("%d", entry.ForkID.Next),
+			}
+		}
+	}
+	return hexutil.Encode(raw)
+}
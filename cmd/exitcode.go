@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/spf13/cobra"
+)
+
+// withConfigErrors marks the errors of the flags and of the checks of the
+// arguments and flags before a command runs as config errors, so polycli
+// exits with util.ExitConfig for them.
+func withConfigErrors(root *cobra.Command) {
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return util.ConfigError(err)
+	})
+	walkCommands(root, func(c *cobra.Command) {
+		if args := c.Args; args != nil {
+			c.Args = func(cmd *cobra.Command, a []string) error {
+				return util.ConfigError(args(cmd, a))
+			}
+		}
+		if preRunE := c.PreRunE; preRunE != nil {
+			c.PreRunE = func(cmd *cobra.Command, a []string) error {
+				return util.ConfigError(preRunE(cmd, a))
+			}
+		}
+	})
+}
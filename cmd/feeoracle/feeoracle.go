@@ -0,0 +1,424 @@
+package feeoracle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	oracleParams struct {
+		RPCURLs           []string
+		Interval          time.Duration
+		Duration          time.Duration
+		FeeHistoryBlocks  uint64
+		RewardPercentile  float64
+		MaxPendingSamples int
+	}
+
+	// suggestion is what an endpoint's fee oracles suggested at one point
+	// in time. Any of the prices is nil if its method failed.
+	suggestion struct {
+		Time        time.Time `json:"time"`
+		Endpoint    string    `json:"endpoint"`
+		BlockNumber uint64    `json:"blockNumber"`
+		// GasPrice is the eth_gasPrice suggestion.
+		GasPrice *big.Int `json:"gasPrice"`
+		// MaxPriorityFee is the eth_maxPriorityFeePerGas suggestion.
+		MaxPriorityFee *big.Int `json:"maxPriorityFeePerGas"`
+		// FeeHistoryBaseFee is the base fee of the next block according to
+		// eth_feeHistory, and FeeHistoryTip is the mean reward at the
+		// percentile over the history.
+		FeeHistoryBaseFee *big.Int `json:"feeHistoryBaseFee"`
+		FeeHistoryTip     *big.Int `json:"feeHistoryTip"`
+		Errors            []string `json:"errors,omitempty"`
+
+		// The fees actually paid in the first block after the suggestion.
+		Inclusion *inclusionFees `json:"inclusion,omitempty"`
+	}
+
+	// inclusionFees are the fees paid by the transactions of a block. The
+	// prices are the effective prices per gas. Without transactions, the
+	// prices are the base fee and the tips are zero.
+	inclusionFees struct {
+		BlockNumber  uint64   `json:"blockNumber"`
+		Transactions int      `json:"transactions"`
+		BaseFee      *big.Int `json:"baseFee"`
+		MinPrice     *big.Int `json:"minPrice"`
+		MedianPrice  *big.Int `json:"medianPrice"`
+		MinTip       *big.Int `json:"minTip"`
+		MedianTip    *big.Int `json:"medianTip"`
+	}
+
+	// accuracy compares one kind of suggestion against the inclusion fees
+	// of an endpoint.
+	accuracy struct {
+		Samples int `json:"samples"`
+		// MeanRatio is the suggestion divided by the median paid on average.
+		MeanRatio float64 `json:"meanRatio"`
+		// BelowMin is the number of suggestions below the cheapest
+		// transaction of the block.
+		BelowMin int `json:"belowMin"`
+
+		totalRatio float64
+	}
+
+	endpointReport struct {
+		Endpoint       string    `json:"endpoint"`
+		Samples        int       `json:"samples"`
+		Errors         int       `json:"errors"`
+		GasPrice       *accuracy `json:"gasPrice"`
+		MaxPriorityFee *accuracy `json:"maxPriorityFeePerGas"`
+		FeeHistoryTip  *accuracy `json:"feeHistoryTip"`
+		BaseFee        *accuracy `json:"feeHistoryBaseFee"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage       string
+	inputOracle oracleParams
+)
+
+var FeeOracleCmd = &cobra.Command{
+	Use:   "fee-oracle",
+	Short: "Compare the fee suggestions of one or more endpoints against the fees paid.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("this command expects no arguments")
+		}
+		if len(inputOracle.RPCURLs) == 0 {
+			return fmt.Errorf("at least one --rpc-url is required")
+		}
+		if inputOracle.Interval <= 0 {
+			return fmt.Errorf("the interval must be positive")
+		}
+		if inputOracle.FeeHistoryBlocks < 1 {
+			return fmt.Errorf("the fee history needs at least one block")
+		}
+		if inputOracle.MaxPendingSamples < 1 {
+			return fmt.Errorf("at least one pending suggestion must be kept")
+		}
+		if inputOracle.RewardPercentile < 0 || inputOracle.RewardPercentile > 100 {
+			return fmt.Errorf("the reward percentile must be between 0 and 100")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if inputOracle.Duration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, inputOracle.Duration)
+			defer cancel()
+		}
+
+		clients := make([]*ethclient.Client, 0, len(inputOracle.RPCURLs))
+		for _, url := range inputOracle.RPCURLs {
+			client, err := util.DialEthClient(ctx, url)
+			if err != nil {
+				log.Error().Err(err).Str("url", url).Msg("Unable to dial rpc")
+				return err
+			}
+			defer client.Close()
+			clients = append(clients, client)
+		}
+
+		reports := compare(ctx, clients)
+		return printReports(reports)
+	},
+}
+
+// compare polls the oracles of every endpoint at each interval, and matches
+// the suggestions with the fees of the first block produced after them. The
+// blocks are read from the first endpoint.
+func compare(ctx context.Context, clients []*ethclient.Client) []*endpointReport {
+	reports := make([]*endpointReport, len(clients))
+	for i, url := range inputOracle.RPCURLs {
+		reports[i] = &endpointReport{
+			Endpoint:       url,
+			GasPrice:       new(accuracy),
+			MaxPriorityFee: new(accuracy),
+			FeeHistoryTip:  new(accuracy),
+			BaseFee:        new(accuracy),
+		}
+	}
+
+	ticker := time.NewTicker(inputOracle.Interval)
+	defer ticker.Stop()
+
+	var pending []*suggestion
+	var lastBlockNumber uint64
+	started := false
+	blocks := make(map[uint64]*inclusionFees)
+	for {
+		suggestions := make([]*suggestion, len(clients))
+		var wg sync.WaitGroup
+		for i, client := range clients {
+			wg.Add(1)
+			go func(i int, client *ethclient.Client) {
+				defer wg.Done()
+				suggestions[i] = getSuggestion(ctx, client, inputOracle.RPCURLs[i])
+			}(i, client)
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return reports
+		}
+		for i, s := range suggestions {
+			reports[i].Samples++
+			if len(s.Errors) > 0 {
+				reports[i].Errors++
+				log.Warn().Str("endpoint", s.Endpoint).Strs("errors", s.Errors).Msg("Unable to get all the suggestions")
+			}
+			if s.BlockNumber == 0 {
+				// Without the block number, the suggestion can't be
+				// matched with a block.
+				continue
+			}
+			pending = append(pending, s)
+		}
+		if len(pending) > inputOracle.MaxPendingSamples {
+			log.Warn().Int("dropped", len(pending)-inputOracle.MaxPendingSamples).Msg("Dropping the oldest suggestions, no new blocks were produced")
+			pending = pending[len(pending)-inputOracle.MaxPendingSamples:]
+		}
+
+		latest, err := clients[0].BlockNumber(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to get the latest block number")
+		} else {
+			if !started || latest < lastBlockNumber || latest-lastBlockNumber > uint64(inputOracle.MaxPendingSamples) {
+				// Only the blocks after the suggestions matter, and an
+				// endpoint can be a block behind.
+				lastBlockNumber = latest
+				if latest > 0 {
+					lastBlockNumber = latest - 1
+				}
+				started = true
+			}
+			for bn := lastBlockNumber + 1; bn <= latest; bn++ {
+				fees, err := getInclusionFees(ctx, clients[0], bn)
+				if err != nil {
+					log.Error().Err(err).Uint64("block", bn).Msg("Unable to get the block")
+					break
+				}
+				blocks[bn] = fees
+				lastBlockNumber = bn
+			}
+		}
+
+		remaining := pending[:0]
+		for _, s := range pending {
+			fees, ok := blocks[s.BlockNumber+1]
+			if !ok {
+				remaining = append(remaining, s)
+				continue
+			}
+			s.Inclusion = fees
+			for i, url := range inputOracle.RPCURLs {
+				if url == s.Endpoint {
+					reports[i].add(s)
+				}
+			}
+			printSuggestion(s)
+		}
+		pending = remaining
+		for bn := range blocks {
+			if bn+uint64(inputOracle.MaxPendingSamples) < lastBlockNumber {
+				delete(blocks, bn)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return reports
+		case <-ticker.C:
+		}
+	}
+}
+
+// getSuggestion calls the fee oracle methods of the endpoint.
+func getSuggestion(ctx context.Context, client *ethclient.Client, endpoint string) *suggestion {
+	s := &suggestion{Time: time.Now(), Endpoint: endpoint}
+	addError := func(method string, err error) {
+		s.Errors = append(s.Errors, fmt.Sprintf("%s: %s", method, err))
+	}
+
+	var err error
+	if s.BlockNumber, err = client.BlockNumber(ctx); err != nil {
+		addError("eth_blockNumber", err)
+	}
+	if s.GasPrice, err = client.SuggestGasPrice(ctx); err != nil {
+		addError("eth_gasPrice", err)
+	}
+	if s.MaxPriorityFee, err = client.SuggestGasTipCap(ctx); err != nil {
+		addError("eth_maxPriorityFeePerGas", err)
+	}
+	history, err := client.FeeHistory(ctx, inputOracle.FeeHistoryBlocks, nil, []float64{inputOracle.RewardPercentile})
+	if err != nil {
+		addError("eth_feeHistory", err)
+		return s
+	}
+	if len(history.BaseFee) > 0 {
+		// The last base fee is the one of the next block.
+		s.FeeHistoryBaseFee = history.BaseFee[len(history.BaseFee)-1]
+	}
+	total := new(big.Int)
+	count := 0
+	for _, rewards := range history.Reward {
+		if len(rewards) > 0 && rewards[0] != nil {
+			total.Add(total, rewards[0])
+			count++
+		}
+	}
+	if count > 0 {
+		s.FeeHistoryTip = total.Div(total, big.NewInt(int64(count)))
+	}
+	return s
+}
+
+// getInclusionFees returns the fees paid in the block.
+func getInclusionFees(ctx context.Context, client *ethclient.Client, number uint64) (*inclusionFees, error) {
+	block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, err
+	}
+	baseFee := block.BaseFee()
+	if baseFee == nil {
+		baseFee = new(big.Int)
+	}
+
+	var prices, tips []*big.Int
+	for _, tx := range block.Transactions() {
+		tip, err := tx.EffectiveGasTip(baseFee)
+		if err != nil {
+			// The transaction can't pay the base fee, which only happens
+			// for system transactions on some chains.
+			continue
+		}
+		tips = append(tips, tip)
+		prices = append(prices, new(big.Int).Add(baseFee, tip))
+	}
+
+	fees := &inclusionFees{
+		BlockNumber:  number,
+		Transactions: len(prices),
+		BaseFee:      baseFee,
+		MinPrice:     baseFee,
+		MedianPrice:  baseFee,
+		MinTip:       new(big.Int),
+		MedianTip:    new(big.Int),
+	}
+	if len(prices) > 0 {
+		fees.MinPrice, fees.MedianPrice = minAndMedian(prices)
+		fees.MinTip, fees.MedianTip = minAndMedian(tips)
+	}
+	return fees, nil
+}
+
+func minAndMedian(values []*big.Int) (*big.Int, *big.Int) {
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Cmp(values[j]) < 0
+	})
+	return values[0], values[len(values)/2]
+}
+
+// add compares the suggestion against the fees paid. The gas price is
+// compared to the prices, the priority fees to the tips, and the predicted
+// base fee to the actual one.
+func (r *endpointReport) add(s *suggestion) {
+	in := s.Inclusion
+	r.GasPrice.add(s.GasPrice, in.MinPrice, in.MedianPrice)
+	r.MaxPriorityFee.add(s.MaxPriorityFee, in.MinTip, in.MedianTip)
+	r.FeeHistoryTip.add(s.FeeHistoryTip, in.MinTip, in.MedianTip)
+	r.BaseFee.add(s.FeeHistoryBaseFee, in.BaseFee, in.BaseFee)
+}
+
+func (a *accuracy) add(suggested, min, median *big.Int) {
+	if suggested == nil {
+		return
+	}
+	a.Samples++
+	if suggested.Cmp(min) < 0 {
+		a.BelowMin++
+	}
+	if median.Sign() > 0 {
+		ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(suggested), new(big.Float).SetInt(median)).Float64()
+		a.totalRatio += ratio
+		a.MeanRatio = a.totalRatio / float64(a.Samples)
+	}
+}
+
+func printSuggestion(s *suggestion) {
+	if util.JSONOutput() {
+		out, err := json.Marshal(s)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to marshal the suggestion")
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	in := s.Inclusion
+	line := fmt.Sprintf("%s block=%d gasPrice=%s maxPriorityFee=%s feeHistoryBaseFee=%s feeHistoryTip=%s | block=%d txs=%d baseFee=%s minPrice=%s medianPrice=%s minTip=%s medianTip=%s",
+		s.Time.Format("15:04:05.000"), s.BlockNumber,
+		util.FormatGwei(s.GasPrice), util.FormatGwei(s.MaxPriorityFee), util.FormatGwei(s.FeeHistoryBaseFee), util.FormatGwei(s.FeeHistoryTip),
+		in.BlockNumber, in.Transactions, util.FormatGwei(in.BaseFee), util.FormatGwei(in.MinPrice), util.FormatGwei(in.MedianPrice), util.FormatGwei(in.MinTip), util.FormatGwei(in.MedianTip))
+	if len(inputOracle.RPCURLs) > 1 {
+		line += " endpoint=" + s.Endpoint
+	}
+	fmt.Println(line)
+}
+
+func printReports(reports []*endpointReport) error {
+	if util.JSONOutput() {
+		out, err := json.Marshal(reports)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	for _, r := range reports {
+		fmt.Printf("endpoint=%s samples=%d errors=%d\n", r.Endpoint, r.Samples, r.Errors)
+		for _, a := range []struct {
+			name string
+			*accuracy
+		}{
+			{"gasPrice", r.GasPrice},
+			{"maxPriorityFee", r.MaxPriorityFee},
+			{"feeHistoryTip", r.FeeHistoryTip},
+			{"feeHistoryBaseFee", r.BaseFee},
+		} {
+			fmt.Printf("  %s compared=%d meanRatio=%.3f belowMin=%d\n", a.name, a.Samples, a.MeanRatio, a.BelowMin)
+		}
+	}
+	return nil
+}
+
+func init() {
+	flagSet := FeeOracleCmd.PersistentFlags()
+	flagSet.StringSliceVarP(&inputOracle.RPCURLs, "rpc-url", "r", []string{"http://localhost:8545"}, "The RPC endpoints to compare. Repeat the flag for more. The blocks are read from the first one")
+	flagSet.DurationVar(&inputOracle.Interval, "interval", 5*time.Second, "How often the fee oracles are polled")
+	flagSet.DurationVar(&inputOracle.Duration, "duration", 0, "How long to run, or 0 to run until interrupted")
+	flagSet.Uint64Var(&inputOracle.FeeHistoryBlocks, "fee-history-blocks", 10, "The number of blocks requested from eth_feeHistory")
+	flagSet.Float64Var(&inputOracle.RewardPercentile, "reward-percentile", 50, "The reward percentile requested from eth_feeHistory")
+	flagSet.IntVar(&inputOracle.MaxPendingSamples, "max-pending", 1000, "The number of suggestions kept while waiting for the next block")
+}
@@ -146,6 +146,7 @@ func (blockReader *ProtoBlockReader) ReadBlock() (rpctypes.PolyBlock, error) {
 		Transactions:     txs,
 		Uncles:           uncles,
 		BaseFeePerGas:    rpctypes.RawQuantityResponse(block.BaseFeePerGas),
+		MixHash:          rpctypes.RawData32Response(block.MixHash),
 	}
 
 	return rpctypes.NewPolyBlock(&raw), nil
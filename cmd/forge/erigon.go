@@ -0,0 +1,151 @@
+package forge
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/rs/zerolog/log"
+)
+
+// exportFileName is the name of the block export written to the data dir.
+const exportFileName = "chain.rlp"
+
+// exportBlocksToRLP writes the blocks as a stream of RLP encoded blocks, which
+// is the format read by `erigon import` and `geth import`. Unlike forging for
+// Edge, the blocks aren't executed, so they're exported as they were read and
+// the importing client validates them against its genesis.
+func exportBlocksToRLP(blockReader BlockReader) error {
+	if err := os.MkdirAll(inputForge.DataDir, 0o755); err != nil {
+		return err
+	}
+	name := filepath.Join(inputForge.DataDir, exportFileName)
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", name, err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+
+	var i uint64 = 0
+	if !inputForge.ShouldReadFirstBlock {
+		if _, err = blockReader.ReadBlock(); err != nil {
+			return fmt.Errorf("could not read off the genesis block from input: %w", err)
+		}
+		i++
+	}
+
+	blockHashSet := make(map[ethcommon.Hash]struct{}, 0)
+	var lastNumber uint64 = 0
+	var exported, mismatched int
+	for ; i < inputForge.Count; i++ {
+		polyBlock, err := blockReader.ReadBlock()
+		if err == ErrBlockReadEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read block %d due to error: %w", i, err)
+		}
+
+		if _, hasKey := blockHashSet[polyBlock.Hash()]; hasKey {
+			log.Trace().Str("blockhash", polyBlock.Hash().String()).Msg("Skipping duplicate block")
+			continue
+		}
+		blockHashSet[polyBlock.Hash()] = struct{}{}
+
+		if inputForge.HasConsecutiveBlocks && lastNumber != 0 && polyBlock.Number().Uint64()-1 != lastNumber {
+			return fmt.Errorf("encountered non consecutive block numbers on input. Got %s and expected %d", polyBlock.Number().String(), lastNumber+1)
+		}
+		lastNumber = polyBlock.Number().Uint64()
+
+		block, err := PolyBlockToGeth(polyBlock)
+		if err != nil {
+			return fmt.Errorf("unable to convert block %s: %w", polyBlock.Number().String(), err)
+		}
+		// The hash won't match if the input is missing fields like the mix
+		// hash, which the importing client will reject.
+		if block.Hash() != polyBlock.Hash() {
+			mismatched++
+			log.Warn().
+				Str("number", polyBlock.Number().String()).
+				Str("expected", polyBlock.Hash().String()).
+				Str("got", block.Hash().String()).
+				Msg("The rebuilt block hash doesn't match")
+			if inputForge.ShouldVerifyBlocks {
+				return fmt.Errorf("the rebuilt hash of block %s doesn't match, use --verify-blocks=false to export it anyway", polyBlock.Number().String())
+			}
+		}
+
+		if err = rlp.Encode(w, block); err != nil {
+			return err
+		}
+		exported++
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	log.Info().Str("file", name).Int("blocks", exported).Int("mismatched", mismatched).Msg("Exported blocks")
+	return nil
+}
+
+// PolyBlockToGeth rebuilds the geth block from the generic PolyBlock. The
+// header and transactions are decoded from their RPC JSON representation so
+// every transaction type is handled the same way geth does.
+func PolyBlockToGeth(polyBlock rpctypes.PolyBlock) (*types.Block, error) {
+	raw, err := polyBlock.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	var header types.Header
+	if err = header.UnmarshalJSON(withoutEmptyFields(fields)); err != nil {
+		return nil, fmt.Errorf("unable to decode the header: %w", err)
+	}
+
+	txs := make([]*types.Transaction, 0, len(polyBlock.Transactions()))
+	for _, polyTx := range polyBlock.Transactions() {
+		raw, err := polyTx.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var txFields map[string]json.RawMessage
+		if err = json.Unmarshal(raw, &txFields); err != nil {
+			return nil, err
+		}
+		tx := new(types.Transaction)
+		if err = tx.UnmarshalJSON(withoutEmptyFields(txFields)); err != nil {
+			return nil, fmt.Errorf("unable to decode transaction %s: %w", polyTx.Hash().String(), err)
+		}
+		txs = append(txs, tx)
+	}
+
+	// The header keeps the uncle hash, so a body without the uncles would be
+	// rejected on import.
+	if len(polyBlock.Uncles()) > 0 {
+		return nil, fmt.Errorf("the block has %d uncles, but only their hashes are known", len(polyBlock.Uncles()))
+	}
+	return types.NewBlockWithHeader(&header).WithBody(txs, nil), nil
+}
+
+// withoutEmptyFields drops the fields the readers leave as empty strings, so
+// they're treated as missing rather than failing to decode as hex.
+func withoutEmptyFields(fields map[string]json.RawMessage) []byte {
+	for k, v := range fields {
+		if string(v) == `""` || string(v) == "null" {
+			delete(fields, k)
+		}
+	}
+	out, _ := json.Marshal(fields)
+	return out
+}
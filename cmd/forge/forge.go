@@ -55,6 +55,9 @@ type (
 		ShouldRewriteTxNonces bool
 		HasConsecutiveBlocks  bool
 		ShouldProcessBlocks   bool
+		ProjectID             string
+		DatabaseID            string
+		StartBlock            uint64
 
 		GenesisData []byte
 	}
@@ -75,12 +78,25 @@ var ForgeCmd = &cobra.Command{
 
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("forge called")
-		blockchain, err := NewEdgeBlockchain()
+		var blockReader BlockReader
+		var err error
+		switch inputForge.Mode {
+		case "capture":
+			blockReader, err = OpenCaptureBlockReader(inputForge.BlocksFile)
+		case "datastore":
+			blockReader, err = OpenDatastoreBlockReader(cmd.Context(), inputForge.ProjectID, inputForge.DatabaseID, inputForge.StartBlock)
+		default:
+			blockReader, err = OpenBlockReader(inputForge.BlocksFile, inputForge.Mode)
+		}
 		if err != nil {
 			return err
 		}
 
-		blockReader, err := OpenBlockReader(inputForge.BlocksFile, inputForge.Mode)
+		if inputForge.Client == "erigon" {
+			return exportBlocksToRLP(blockReader)
+		}
+
+		blockchain, err := NewEdgeBlockchain()
 		if err != nil {
 			return err
 		}
@@ -95,11 +111,24 @@ var ForgeCmd = &cobra.Command{
 		return err
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
-		if inputForge.Client != "edge" {
-			return fmt.Errorf("the client %s is not supported. Only Edge is supported", inputForge.Client)
+		if !slices.Contains([]string{"edge", "erigon"}, inputForge.Client) {
+			return fmt.Errorf("the client %s is not supported. Only Edge and Erigon are supported", inputForge.Client)
+		}
+		if !slices.Contains([]string{"json", "proto", "capture", "datastore"}, inputForge.Mode) {
+			return fmt.Errorf("output format must one of [json, proto, capture, datastore]")
 		}
-		if !slices.Contains([]string{"json", "proto"}, inputForge.Mode) {
-			return fmt.Errorf("output format must one of [json, proto]")
+		if inputForge.Mode == "datastore" && inputForge.ProjectID == "" {
+			return fmt.Errorf("--project-id is required in datastore mode")
+		}
+		if inputForge.Mode != "datastore" && inputForge.BlocksFile == "" {
+			return fmt.Errorf("--blocks is required in %s mode", inputForge.Mode)
+		}
+		if slices.Contains([]string{"capture", "datastore"}, inputForge.Mode) && inputForge.IncludeTxFees {
+			return fmt.Errorf("the sensor doesn't capture receipts so --tx-fees can't be used in %s mode", inputForge.Mode)
+		}
+		// Erigon imports the exported blocks on top of its own genesis.
+		if inputForge.Client == "erigon" {
+			return nil
 		}
 		f, err := os.Open(inputForge.GenesisFile)
 		if err != nil {
@@ -116,11 +145,11 @@ var ForgeCmd = &cobra.Command{
 }
 
 func init() {
-	ForgeCmd.PersistentFlags().StringVarP(&inputForge.Client, "client", "c", "edge", "Specify which blockchain client should be use to forge the data")
+	ForgeCmd.PersistentFlags().StringVarP(&inputForge.Client, "client", "c", "edge", "Specify which blockchain client should be use to forge the data [edge, erigon]")
 	ForgeCmd.PersistentFlags().StringVarP(&inputForge.DataDir, "data-dir", "d", "./forged-data", "Specify a folder to be used to store the chain data")
 	ForgeCmd.PersistentFlags().StringVarP(&inputForge.GenesisFile, "genesis", "g", "genesis.json", "Specify a file to be used for genesis configuration")
 	ForgeCmd.PersistentFlags().StringVarP(&inputForge.Verifier, "verifier", "V", "dummy", "Specify a consensus engine to use for forging")
-	ForgeCmd.PersistentFlags().StringVarP(&inputForge.Mode, "mode", "m", "json", "The forge mode indicates how we should get the transactions for our blocks [json, proto]")
+	ForgeCmd.PersistentFlags().StringVarP(&inputForge.Mode, "mode", "m", "json", "The forge mode indicates how we should get the transactions for our blocks [json, proto, capture, datastore]")
 	ForgeCmd.PersistentFlags().Uint64VarP(&inputForge.Count, "count", "C", 100, "The number of blocks to try to forge")
 	ForgeCmd.PersistentFlags().StringVarP(&inputForge.BlocksFile, "blocks", "b", "", "A file of encoded blocks; the format of this file should match the mode")
 	ForgeCmd.PersistentFlags().StringVarP(&inputForge.BaseBlockReward, "base-block-reward", "B", "2_000_000_000_000_000_000", "The amount rewarded for mining blocks")
@@ -130,11 +159,10 @@ func init() {
 	ForgeCmd.PersistentFlags().BoolVar(&inputForge.ShouldVerifyBlocks, "verify-blocks", true, "whether to verify blocks, set false if forging nonconsecutive blocks")
 	ForgeCmd.PersistentFlags().BoolVar(&inputForge.ShouldRewriteTxNonces, "rewrite-tx-nonces", false, "whether to rewrite transaction nonces, set true if forging nonconsecutive blocks")
 	ForgeCmd.PersistentFlags().BoolVar(&inputForge.HasConsecutiveBlocks, "consecutive-blocks", true, "whether the blocks file has consecutive blocks")
+	ForgeCmd.PersistentFlags().StringVar(&inputForge.ProjectID, "project-id", "", "The GCP project ID of the sensor's datastore in datastore mode")
+	ForgeCmd.PersistentFlags().StringVar(&inputForge.DatabaseID, "database-id", "", "The datastore database ID of the sensor in datastore mode")
+	ForgeCmd.PersistentFlags().Uint64Var(&inputForge.StartBlock, "start-block", 1, "The number of the first block to read in datastore mode")
 	ForgeCmd.PersistentFlags().BoolVarP(&inputForge.ShouldProcessBlocks, "process-blocks", "p", true, "whether the transactions in blocks should be processed applied to the state")
-
-	if err := cobra.MarkFlagRequired(ForgeCmd.PersistentFlags(), "blocks"); err != nil {
-		log.Error().Err(err).Msg("Unable to mark blocks flag as required")
-	}
 }
 
 type edgeBlockchainHandle struct {
@@ -0,0 +1,261 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/maticnetwork/polygon-cli/p2p"
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+type (
+	// CaptureBlockReader reads the blocks the sensor received from peers out
+	// of its capture file.
+	CaptureBlockReader struct {
+		blocks []*types.Block
+	}
+	// DatastoreBlockReader reads the blocks the sensor wrote to datastore,
+	// starting at a block number and following the chain from there.
+	DatastoreBlockReader struct {
+		ctx    context.Context
+		client *datastore.Client
+		next   uint64
+		parent string
+	}
+)
+
+// OpenCaptureBlockReader reads all the blocks in the capture file up front
+// because they aren't captured in order.
+func OpenCaptureBlockReader(file string) (*CaptureBlockReader, error) {
+	reader, err := p2p.NewCaptureReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	blocks, err := p2p.CapturedBlocks(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the blocks in %s: %w", file, err)
+	}
+	return &CaptureBlockReader{blocks: blocks}, nil
+}
+
+func (blockReader *CaptureBlockReader) ReadBlock() (rpctypes.PolyBlock, error) {
+	if len(blockReader.blocks) == 0 {
+		return nil, ErrBlockReadEOF
+	}
+	block := blockReader.blocks[0]
+	blockReader.blocks = blockReader.blocks[1:]
+	return rpctypes.NewPolyBlock(newRawBlockResponse(block)), nil
+}
+
+// OpenDatastoreBlockReader connects to the datastore database of the sensor.
+func OpenDatastoreBlockReader(ctx context.Context, projectID, databaseID string, start uint64) (*DatastoreBlockReader, error) {
+	client, err := datastore.NewClientWithDatabase(ctx, projectID, databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to datastore: %w", err)
+	}
+	return &DatastoreBlockReader{ctx: ctx, client: client, next: start}, nil
+}
+
+// ReadBlock reads the block at the next number. If the sensor saw several
+// blocks at that number, the child of the previous block is picked. Headers
+// that were written without their body can't be forged, so they're an error.
+func (blockReader *DatastoreBlockReader) ReadBlock() (rpctypes.PolyBlock, error) {
+	number := blockReader.next
+	query := datastore.NewQuery(database.BlocksKind).FilterField("Number", "=", fmt.Sprint(number))
+	var blocks []database.DatastoreBlock
+	keys, err := blockReader.client.GetAll(blockReader.ctx, query, &blocks)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query block %d: %w", number, err)
+	}
+	if len(keys) == 0 {
+		return nil, ErrBlockReadEOF
+	}
+
+	picked := 0
+	for i, block := range blocks {
+		if block.DatastoreHeader != nil && block.ParentHash != nil && block.ParentHash.Name == blockReader.parent {
+			picked = i
+			break
+		}
+		if len(block.TotalDifficulty) > 0 {
+			picked = i
+		}
+	}
+	key, block := keys[picked], blocks[picked]
+	if block.DatastoreHeader == nil {
+		return nil, fmt.Errorf("block %s doesn't have a header", key.Name)
+	}
+
+	txs := make([]database.DatastoreTransaction, len(block.Transactions))
+	if len(block.Transactions) > 0 {
+		if err = blockReader.client.GetMulti(blockReader.ctx, block.Transactions, txs); err != nil {
+			return nil, fmt.Errorf("unable to get the transactions of block %s: %w", key.Name, err)
+		}
+	} else if common.HexToHash(block.TxHash) != types.EmptyRootHash {
+		return nil, fmt.Errorf("the body of block %s wasn't written by the sensor", key.Name)
+	}
+
+	raw := newDatastoreRawBlockResponse(key.Name, &block, block.Transactions, txs)
+	blockReader.next++
+	blockReader.parent = key.Name
+	return rpctypes.NewPolyBlock(raw), nil
+}
+
+// newRawBlockResponse converts the block to the format returned by the RPC.
+func newRawBlockResponse(block *types.Block) *rpctypes.RawBlockResponse {
+	header := block.Header()
+	txs := make([]rpctypes.RawTransactionResponse, 0, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		var from common.Address
+		if sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+			from = sender
+		}
+		var to string
+		if tx.To() != nil {
+			to = tx.To().Hex()
+		}
+		v, r, s := tx.RawSignatureValues()
+		txs = append(txs, rpctypes.RawTransactionResponse{
+			BlockHash:            rpctypes.RawData32Response(block.Hash().Hex()),
+			BlockNumber:          quantity(block.Number()),
+			From:                 rpctypes.RawData20Response(from.Hex()),
+			Gas:                  rpctypes.RawQuantityResponse(hexutil.EncodeUint64(tx.Gas())),
+			GasPrice:             quantity(tx.GasPrice()),
+			MaxPriorityFeePerGas: quantity(tx.GasTipCap()),
+			MaxFeePerGas:         quantity(tx.GasFeeCap()),
+			Hash:                 rpctypes.RawData32Response(tx.Hash().Hex()),
+			Input:                rpctypes.RawDataResponse(hexutil.Encode(tx.Data())),
+			Nonce:                rpctypes.RawQuantityResponse(hexutil.EncodeUint64(tx.Nonce())),
+			To:                   rpctypes.RawData20Response(to),
+			TransactionIndex:     rpctypes.RawQuantityResponse(hexutil.EncodeUint64(uint64(i))),
+			Value:                quantity(tx.Value()),
+			V:                    quantity(v),
+			R:                    quantity(r),
+			S:                    quantity(s),
+			Type:                 rpctypes.RawQuantityResponse(hexutil.EncodeUint64(uint64(tx.Type()))),
+			ChainID:              quantity(tx.ChainId()),
+		})
+	}
+
+	uncles := make([]rpctypes.RawData32Response, 0, len(block.Uncles()))
+	for _, uncle := range block.Uncles() {
+		uncles = append(uncles, rpctypes.RawData32Response(uncle.Hash().Hex()))
+	}
+
+	return &rpctypes.RawBlockResponse{
+		Number:           quantity(header.Number),
+		Hash:             rpctypes.RawData32Response(block.Hash().Hex()),
+		ParentHash:       rpctypes.RawData32Response(header.ParentHash.Hex()),
+		Nonce:            rpctypes.RawData8Response(hexutil.Encode(header.Nonce[:])),
+		SHA3Uncles:       rpctypes.RawData32Response(header.UncleHash.Hex()),
+		LogsBloom:        rpctypes.RawData256Response(hexutil.Encode(header.Bloom.Bytes())),
+		TransactionsRoot: rpctypes.RawData32Response(header.TxHash.Hex()),
+		StateRoot:        rpctypes.RawData32Response(header.Root.Hex()),
+		ReceiptsRoot:     rpctypes.RawData32Response(header.ReceiptHash.Hex()),
+		Miner:            rpctypes.RawData20Response(header.Coinbase.Hex()),
+		Difficulty:       quantity(header.Difficulty),
+		ExtraData:        rpctypes.RawDataResponse(hexutil.Encode(header.Extra)),
+		Size:             rpctypes.RawQuantityResponse(hexutil.EncodeUint64(uint64(block.Size()))),
+		GasLimit:         rpctypes.RawQuantityResponse(hexutil.EncodeUint64(header.GasLimit)),
+		GasUsed:          rpctypes.RawQuantityResponse(hexutil.EncodeUint64(header.GasUsed)),
+		Timestamp:        rpctypes.RawQuantityResponse(hexutil.EncodeUint64(header.Time)),
+		Transactions:     txs,
+		Uncles:           uncles,
+		BaseFeePerGas:    quantity(header.BaseFee),
+		MixHash:          rpctypes.RawData32Response(header.MixDigest.Hex()),
+	}
+}
+
+// newDatastoreRawBlockResponse converts the block written by the sensor to
+// the format returned by the RPC. Datastore keeps most numbers as decimal
+// strings so they're converted back to quantities.
+func newDatastoreRawBlockResponse(hash string, block *database.DatastoreBlock, keys []*datastore.Key, dsTxs []database.DatastoreTransaction) *rpctypes.RawBlockResponse {
+	header := block.DatastoreHeader
+	number := decimal(header.Number)
+
+	txs := make([]rpctypes.RawTransactionResponse, 0, len(dsTxs))
+	for i, tx := range dsTxs {
+		txs = append(txs, rpctypes.RawTransactionResponse{
+			BlockHash:            rpctypes.RawData32Response(hash),
+			BlockNumber:          number,
+			From:                 rpctypes.RawData20Response(tx.From),
+			Gas:                  decimal(tx.Gas),
+			GasPrice:             decimal(tx.GasPrice),
+			MaxPriorityFeePerGas: decimal(tx.GasTipCap),
+			MaxFeePerGas:         decimal(tx.GasFeeCap),
+			Hash:                 rpctypes.RawData32Response(keys[i].Name),
+			Input:                rpctypes.RawDataResponse(hexutil.Encode(tx.Data)),
+			Nonce:                decimal(tx.Nonce),
+			To:                   rpctypes.RawData20Response(tx.To),
+			TransactionIndex:     rpctypes.RawQuantityResponse(hexutil.EncodeUint64(uint64(i))),
+			Value:                decimal(tx.Value),
+			V:                    decimal(tx.V),
+			R:                    decimal(tx.R),
+			S:                    decimal(tx.S),
+			Type:                 rpctypes.RawQuantityResponse(hexutil.EncodeUint64(uint64(tx.Type))),
+		})
+	}
+
+	uncles := make([]rpctypes.RawData32Response, 0, len(block.Uncles))
+	for _, uncle := range block.Uncles {
+		uncles = append(uncles, rpctypes.RawData32Response(uncle.Name))
+	}
+
+	var parentHash string
+	if header.ParentHash != nil {
+		parentHash = header.ParentHash.Name
+	}
+	nonce, _ := new(big.Int).SetString(header.Nonce, 10)
+	var blockNonce types.BlockNonce
+	if nonce != nil {
+		blockNonce = types.EncodeNonce(nonce.Uint64())
+	}
+
+	return &rpctypes.RawBlockResponse{
+		Number:           number,
+		Hash:             rpctypes.RawData32Response(hash),
+		ParentHash:       rpctypes.RawData32Response(parentHash),
+		Nonce:            rpctypes.RawData8Response(hexutil.Encode(blockNonce[:])),
+		SHA3Uncles:       rpctypes.RawData32Response(header.UncleHash),
+		LogsBloom:        rpctypes.RawData256Response(hexutil.Encode(header.Bloom)),
+		TransactionsRoot: rpctypes.RawData32Response(header.TxHash),
+		StateRoot:        rpctypes.RawData32Response(header.Root),
+		ReceiptsRoot:     rpctypes.RawData32Response(header.ReceiptHash),
+		Miner:            rpctypes.RawData20Response(header.Coinbase),
+		Difficulty:       decimal(header.Difficulty),
+		TotalDifficulty:  decimal(block.TotalDifficulty),
+		ExtraData:        rpctypes.RawDataResponse(hexutil.Encode(header.Extra)),
+		GasLimit:         decimal(header.GasLimit),
+		GasUsed:          decimal(header.GasUsed),
+		Timestamp:        rpctypes.RawQuantityResponse(hexutil.EncodeUint64(uint64(header.Time.Unix()))),
+		Transactions:     txs,
+		Uncles:           uncles,
+		BaseFeePerGas:    decimal(header.BaseFee),
+		MixHash:          rpctypes.RawData32Response(header.MixDigest),
+	}
+}
+
+func quantity(n *big.Int) rpctypes.RawQuantityResponse {
+	if n == nil {
+		return ""
+	}
+	return rpctypes.RawQuantityResponse(hexutil.EncodeBig(n))
+}
+
+// decimal converts a decimal string written by the sensor to a quantity. The
+// sensor writes missing values like the base fee of old blocks as "<nil>".
+func decimal(s string) rpctypes.RawQuantityResponse {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return ""
+	}
+	return quantity(n)
+}
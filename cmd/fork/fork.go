@@ -15,6 +15,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/maticnetwork/polygon-cli/util"
 )
 
 var (
@@ -30,7 +31,7 @@ var ForkCmd = &cobra.Command{
 	Long:  "",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Info().Str("rpc", rpcURL).Str("blockHash", blockHash.String()).Msg("Starting Analysis")
-		c, err := ethclient.Dial(rpcURL)
+		c, err := util.DialEthClient(cmd.Context(), rpcURL)
 		if err != nil {
 			log.Error().Err(err).Str("rpc", rpcURL).Msg("Could not rpc dial connection")
 			return err
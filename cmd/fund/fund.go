@@ -0,0 +1,503 @@
+package fund
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/maticnetwork/polygon-cli/hdwallet"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	fundParams struct {
+		RPCURL        string
+		PrivateKey    string
+		Addresses     []string
+		AddressesFile string
+		Mnemonic      string
+		Password      string
+		Path          string
+		HDStart       int
+		HDCount       int
+		Amount        string
+		TopUp         bool
+		GasLimit      uint64
+		BatchSize     int
+		Progress      string
+		Timeout       time.Duration
+		Signer        signer.Config
+
+		amount *big.Int
+	}
+
+	// fundResult is the outcome of funding a single address. The results are
+	// saved to the progress file so funded addresses are skipped on a rerun.
+	// A transfer is saved as pending once it's sent, so a rerun waits for its
+	// receipt instead of sending it again.
+	fundResult struct {
+		Address ethcommon.Address `json:"address"`
+		TxHash  ethcommon.Hash    `json:"txHash"`
+		Amount  string            `json:"amount"`
+		Pending bool              `json:"pending,omitempty"`
+		Error   string            `json:"error,omitempty"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage     string
+	inputFund fundParams
+)
+
+var FundCmd = &cobra.Command{
+	Use:   "fund",
+	Short: "Bulk fund a list of wallets from a single funder.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := inputFund.Signer.Validate(); err != nil {
+			return err
+		}
+		if inputFund.Signer.Kind == signer.KindPrivateKey && inputFund.PrivateKey == "" {
+			return fmt.Errorf("the funder --private-key is required")
+		}
+		if len(inputFund.Addresses) == 0 && inputFund.AddressesFile == "" && inputFund.Mnemonic == "" {
+			return fmt.Errorf("the addresses to fund must be set with --addresses, --addresses-file, or --mnemonic")
+		}
+		if inputFund.Mnemonic != "" && inputFund.HDCount < 1 {
+			return fmt.Errorf("--hd-count must be at least 1")
+		}
+		if inputFund.BatchSize < 1 {
+			return fmt.Errorf("--batch-size must be at least 1")
+		}
+		amount, err := util.ParseEther(inputFund.Amount)
+		if err != nil {
+			return err
+		}
+		if amount.Sign() == 0 {
+			return fmt.Errorf("the amount %s must be a positive number of ether", inputFund.Amount)
+		}
+		inputFund.amount = amount
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		addresses, err := loadAddresses()
+		if err != nil {
+			return err
+		}
+		funder, err := signer.New(ctx, inputFund.Signer, inputFund.PrivateKey)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to create the signer")
+			return err
+		}
+
+		c, err := util.DialEthClient(ctx, inputFund.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to dial rpc")
+			return err
+		}
+		defer c.Close()
+
+		progress, err := loadProgress(inputFund.Progress)
+		if err != nil {
+			return err
+		}
+
+		if err = fundAddresses(ctx, c, funder, addresses, progress); err != nil {
+			return err
+		}
+		return reportBalances(ctx, c, addresses)
+	},
+}
+
+// loadAddresses combines the addresses from the flag, the file, and the HD
+// wallet. Duplicates are removed so an address is never funded twice.
+func loadAddresses() ([]ethcommon.Address, error) {
+	raw := append([]string{}, inputFund.Addresses...)
+
+	if inputFund.AddressesFile != "" {
+		f, err := os.Open(inputFund.AddressesFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			raw = append(raw, line)
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if inputFund.Mnemonic != "" {
+		pw, err := hdwallet.NewPolyWallet(inputFund.Mnemonic, inputFund.Password)
+		if err != nil {
+			return nil, err
+		}
+		if err = pw.SetPath(inputFund.Path); err != nil {
+			return nil, err
+		}
+		export, err := pw.ExportHDAddressRange(inputFund.HDStart, inputFund.HDCount)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range export.Addresses {
+			raw = append(raw, a.ETHAddress)
+		}
+	}
+
+	seen := make(map[ethcommon.Address]struct{}, len(raw))
+	addresses := make([]ethcommon.Address, 0, len(raw))
+	for _, r := range raw {
+		if !ethcommon.IsHexAddress(r) {
+			return nil, fmt.Errorf("the address %s is invalid", r)
+		}
+		a := ethcommon.HexToAddress(r)
+		if _, ok := seen[a]; ok {
+			continue
+		}
+		seen[a] = struct{}{}
+		addresses = append(addresses, a)
+	}
+	return addresses, nil
+}
+
+// loadProgress reads the results of an earlier run. Only the addresses that
+// were funded successfully or are still pending are returned.
+func loadProgress(path string) (map[ethcommon.Address]fundResult, error) {
+	funded := make(map[ethcommon.Address]fundResult)
+	if path == "" {
+		return funded, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return funded, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results []fundResult
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("unable to parse the progress file %s: %w", path, err)
+	}
+	for _, r := range results {
+		if r.Error == "" {
+			funded[r.Address] = r
+		}
+	}
+	log.Info().Str("progress", path).Int("funded", len(funded)).Msg("Resuming from progress file")
+	return funded, nil
+}
+
+func saveProgress(path string, progress map[ethcommon.Address]fundResult) error {
+	if path == "" {
+		return nil
+	}
+	results := make([]fundResult, 0, len(progress))
+	for _, r := range progress {
+		results = append(results, r)
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fundAddresses sends the transfers in batches. Each batch is sent with
+// consecutive nonces and then every receipt is awaited before the next batch
+// so a failure never leaves more than a batch in flight.
+func fundAddresses(ctx context.Context, c *ethclient.Client, s signer.Signer, addresses []ethcommon.Address, progress map[ethcommon.Address]fundResult) error {
+	funder := s.Address()
+	chainID, err := c.ChainID(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the chain id")
+		return err
+	}
+
+	// The transfers sent by an interrupted run are awaited first.
+	sent := make([]fundResult, 0)
+	for _, r := range progress {
+		if r.Pending {
+			sent = append(sent, r)
+		}
+	}
+	if len(sent) > 0 {
+		log.Info().Int("pending", len(sent)).Msg("Waiting for the transfers of the earlier run")
+	}
+	failed := 0
+	for _, r := range waitForBatch(ctx, c, sent) {
+		if r.Error != "" {
+			failed++
+			log.Error().Str("address", r.Address.Hex()).Str("txHash", r.TxHash.Hex()).Str("error", r.Error).Msg("Unable to fund address")
+		}
+		progress[r.Address] = r
+	}
+	if saveErr := saveProgress(inputFund.Progress, progress); saveErr != nil {
+		log.Error().Err(saveErr).Msg("Unable to save the progress")
+	}
+
+	pending := make([]ethcommon.Address, 0, len(addresses))
+	for _, a := range addresses {
+		if _, ok := progress[a]; !ok {
+			pending = append(pending, a)
+		}
+	}
+	log.Info().Int("addresses", len(addresses)).Int("pending", len(pending)).Str("amount", inputFund.amount.String()).Msg("Funding addresses")
+
+	// The gas is ignored here, so this only catches the funder being short by
+	// more than the fees.
+	balance, err := c.BalanceAt(ctx, funder, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the funder balance")
+		return err
+	}
+	needed := new(big.Int).Mul(inputFund.amount, big.NewInt(int64(len(pending))))
+	if !inputFund.TopUp && balance.Cmp(needed) < 0 {
+		return fmt.Errorf("the funder %s has %s wei but %s wei is needed", funder.Hex(), balance, needed)
+	}
+
+	nonce, err := c.PendingNonceAt(ctx, funder)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the funder nonce")
+		return err
+	}
+
+	for start := 0; start < len(pending); start += inputFund.BatchSize {
+		end := start + inputFund.BatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+
+		txs, err := sendBatch(ctx, c, s, chainID, pending[start:end], &nonce)
+		sent = make([]fundResult, 0, len(txs))
+		for _, tx := range txs {
+			r := fundResult{Address: *tx.To(), TxHash: tx.Hash(), Amount: tx.Value().String(), Pending: true}
+			sent = append(sent, r)
+			progress[r.Address] = r
+		}
+		if saveErr := saveProgress(inputFund.Progress, progress); saveErr != nil {
+			log.Error().Err(saveErr).Msg("Unable to save the progress")
+		}
+		for _, r := range waitForBatch(ctx, c, sent) {
+			if r.Error != "" {
+				failed++
+				log.Error().Str("address", r.Address.Hex()).Str("txHash", r.TxHash.Hex()).Str("error", r.Error).Msg("Unable to fund address")
+			}
+			progress[r.Address] = r
+		}
+		if saveErr := saveProgress(inputFund.Progress, progress); saveErr != nil {
+			log.Error().Err(saveErr).Msg("Unable to save the progress")
+		}
+		if err != nil {
+			return err
+		}
+		log.Info().Int("funded", end).Int("pending", len(pending)).Msg("Funded batch")
+	}
+
+	if failed > 0 {
+		return util.PartialFailure(fmt.Errorf("%d addresses couldn't be funded", failed))
+	}
+	return nil
+}
+
+// sendBatch signs and sends a transfer to each address. When topping up, the
+// addresses that already hold the amount are skipped and the others only
+// receive the difference.
+func sendBatch(ctx context.Context, c *ethclient.Client, s signer.Signer, chainID *big.Int, addresses []ethcommon.Address, nonce *uint64) ([]*ethtypes.Transaction, error) {
+	header, err := c.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	gasPrice, err := c.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var gasTipCap *big.Int
+	if header.BaseFee != nil && !signer.RequiresLegacy(s) {
+		if gasTipCap, err = c.SuggestGasTipCap(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	txs := make([]*ethtypes.Transaction, 0, len(addresses))
+	for _, a := range addresses {
+		amount := inputFund.amount
+		if inputFund.TopUp {
+			balance, err := c.BalanceAt(ctx, a, nil)
+			if err != nil {
+				return txs, err
+			}
+			if balance.Cmp(amount) >= 0 {
+				log.Debug().Str("address", a.Hex()).Str("balance", balance.String()).Msg("Address already funded")
+				continue
+			}
+			amount = new(big.Int).Sub(amount, balance)
+		}
+
+		to := a
+		var tx *ethtypes.Transaction
+		if gasTipCap != nil {
+			// Leave room for the base fee to double before the next block.
+			gasFeeCap := new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), gasTipCap)
+			tx = ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+				ChainID:   chainID,
+				Nonce:     *nonce,
+				GasTipCap: gasTipCap,
+				GasFeeCap: gasFeeCap,
+				Gas:       inputFund.GasLimit,
+				To:        &to,
+				Value:     amount,
+			})
+		} else {
+			tx = ethtypes.NewTx(&ethtypes.LegacyTx{
+				Nonce:    *nonce,
+				GasPrice: gasPrice,
+				Gas:      inputFund.GasLimit,
+				To:       &to,
+				Value:    amount,
+			})
+		}
+
+		signed, err := s.SignTx(ctx, tx, chainID)
+		if err != nil {
+			return txs, err
+		}
+		if err = c.SendTransaction(ctx, signed); err != nil {
+			log.Error().Err(err).Str("address", a.Hex()).Uint64("nonce", *nonce).Msg("Unable to send transaction")
+			return txs, err
+		}
+		log.Trace().Str("address", a.Hex()).Str("txHash", signed.Hash().Hex()).Uint64("nonce", *nonce).Msg("Sent transaction")
+		*nonce++
+		txs = append(txs, signed)
+	}
+	return txs, nil
+}
+
+// waitForBatch waits for the receipt of every pending transfer in the batch
+// until the timeout.
+func waitForBatch(ctx context.Context, c *ethclient.Client, sent []fundResult) []fundResult {
+	ctx, cancel := context.WithTimeout(ctx, inputFund.Timeout)
+	defer cancel()
+
+	results := make([]fundResult, 0, len(sent))
+	for _, r := range sent {
+		r.Pending = false
+		for {
+			receipt, err := c.TransactionReceipt(ctx, r.TxHash)
+			if err == nil {
+				if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+					r.Error = "transaction failed"
+				}
+				break
+			}
+			if !errors.Is(err, ethereum.NotFound) {
+				r.Error = err.Error()
+				break
+			}
+			select {
+			case <-ctx.Done():
+				r.Error = "timed out waiting for the receipt"
+			case <-time.After(time.Second):
+				continue
+			}
+			break
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// balancesOutput is the balance report printed with --json. The balances are
+// decimal strings of wei so they don't lose precision.
+type balancesOutput struct {
+	Balances    []balanceOutput `json:"balances"`
+	BelowAmount int             `json:"belowAmount"`
+	Total       string          `json:"total"`
+}
+
+type balanceOutput struct {
+	Address     string `json:"address"`
+	Balance     string `json:"balance"`
+	BelowAmount bool   `json:"belowAmount"`
+}
+
+// reportBalances prints the final balance of every address.
+func reportBalances(ctx context.Context, c *ethclient.Client, addresses []ethcommon.Address) error {
+	total := new(big.Int)
+	out := balancesOutput{Balances: make([]balanceOutput, 0, len(addresses))}
+	for _, a := range addresses {
+		balance, err := c.BalanceAt(ctx, a, nil)
+		if err != nil {
+			return err
+		}
+		total.Add(total, balance)
+		below := balance.Cmp(inputFund.amount) < 0
+		if below {
+			out.BelowAmount++
+		}
+		out.Balances = append(out.Balances, balanceOutput{Address: a.Hex(), Balance: balance.String(), BelowAmount: below})
+	}
+	out.Total = total.String()
+	if util.JSONOutput() {
+		return util.PrintJSON(out)
+	}
+
+	fmt.Printf("%-42s %30s\n", "Address", "Balance (wei)")
+	for _, b := range out.Balances {
+		marker := ""
+		if b.BelowAmount {
+			marker = " (below amount)"
+		}
+		fmt.Printf("%-42s %30s%s\n", b.Address, b.Balance, marker)
+	}
+	fmt.Printf("\nAddresses: %d\nBelow amount: %d\nTotal balance: %s wei\n", len(addresses), out.BelowAmount, out.Total)
+	return nil
+}
+
+func init() {
+	flagSet := FundCmd.PersistentFlags()
+	flagSet.StringVarP(&inputFund.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint url")
+	flagSet.StringVar(&inputFund.PrivateKey, "private-key", "", "The hex encoded private key of the funder")
+	flagSet.StringSliceVar(&inputFund.Addresses, "addresses", nil, "A comma separated list of addresses to fund")
+	flagSet.StringVar(&inputFund.AddressesFile, "addresses-file", "", "A file with one address to fund per line")
+	flagSet.StringVar(&inputFund.Mnemonic, "mnemonic", "", "A mnemonic used to derive the addresses to fund")
+	flagSet.StringVar(&inputFund.Password, "password", "", "The password used along with the mnemonic")
+	flagSet.StringVar(&inputFund.Path, "path", "m/44'/60'/0'", "The derivation path of the HD addresses")
+	flagSet.IntVar(&inputFund.HDStart, "hd-start", 0, "The index of the first HD address to fund")
+	flagSet.IntVar(&inputFund.HDCount, "hd-count", 10, "The number of HD addresses to fund")
+	flagSet.StringVar(&inputFund.Amount, "amount", "1", "The amount of ether to send to each address")
+	flagSet.BoolVar(&inputFund.TopUp, "top-up", false, "Only send the difference between the amount and the current balance")
+	flagSet.Uint64Var(&inputFund.GasLimit, "gas-limit", 21000, "The gas limit of each transfer")
+	flagSet.IntVar(&inputFund.BatchSize, "batch-size", 100, "The number of transfers sent before waiting for their receipts")
+	flagSet.StringVar(&inputFund.Progress, "progress", "", "A file where the funded addresses are saved so an interrupted run can be resumed")
+	flagSet.DurationVar(&inputFund.Timeout, "timeout", 2*time.Minute, "How long to wait for the receipts of a batch")
+	inputFund.Signer.AddFlags(flagSet)
+}
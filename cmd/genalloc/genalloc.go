@@ -0,0 +1,221 @@
+package genalloc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	_ "embed"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/maticnetwork/polygon-cli/genesis"
+	"github.com/maticnetwork/polygon-cli/hdwallet"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const codeQualityMnemonic = "code code code code code code code code code code code quality"
+
+type genallocParams struct {
+	Addresses   []string
+	Mnemonic    string
+	Password    string
+	Path        string
+	HDStart     int
+	HDCount     int
+	Balance     string
+	Deployer    string
+	Contracts   []string
+	GenesisFile string
+	Output      string
+}
+
+var (
+	//go:embed usage.md
+	usage         string
+	inputGenalloc genallocParams
+
+	// loadtestFlags are the load test flags that take the address of each
+	// contract.
+	loadtestFlags = map[string]string{
+		genesis.ContractLoadTester: "--lt-address",
+		genesis.ContractERC20:      "--erc20-address",
+		genesis.ContractERC721:     "--erc721-address",
+		genesis.ContractCaller:     "--caller-address",
+		genesis.ContractLogEmitter: "--log-emitter-address",
+		genesis.ContractColdAccess: "--cold-access-address",
+		genesis.ContractCompute:    "--compute-address",
+		genesis.ContractDisperse:   "--disperse-address",
+	}
+)
+
+var GenallocCmd = &cobra.Command{
+	Use:   "genalloc",
+	Short: "Generate a genesis allocation with funded accounts and the load test contracts.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if inputGenalloc.HDCount < 0 {
+			return fmt.Errorf("--hd-count can't be negative")
+		}
+		if inputGenalloc.Deployer != "" && !ethcommon.IsHexAddress(inputGenalloc.Deployer) {
+			return fmt.Errorf("the deployer %s is invalid", inputGenalloc.Deployer)
+		}
+		seen := make(map[string]bool)
+		for _, c := range inputGenalloc.Contracts {
+			if _, err := genesis.Address(ethcommon.Address{}, c); err != nil {
+				return err
+			}
+			if seen[c] {
+				return fmt.Errorf("the contract %s is listed more than once", c)
+			}
+			seen[c] = true
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := loadAccounts()
+		if err != nil {
+			return err
+		}
+		balance, err := util.ParseEther(inputGenalloc.Balance)
+		if err != nil {
+			return err
+		}
+
+		var deployer ethcommon.Address
+		switch {
+		case inputGenalloc.Deployer != "":
+			deployer = ethcommon.HexToAddress(inputGenalloc.Deployer)
+		case len(accounts) > 0:
+			deployer = accounts[0]
+		case len(inputGenalloc.Contracts) > 0:
+			return fmt.Errorf("the --deployer is required when no accounts are funded")
+		}
+
+		alloc, included, err := genesis.NewAlloc(genesis.Config{
+			Accounts:  accounts,
+			Balance:   balance,
+			Deployer:  deployer,
+			Contracts: inputGenalloc.Contracts,
+		})
+		if err != nil {
+			return err
+		}
+		for _, c := range included {
+			log.Info().Str("contract", c.Name).Str("address", c.Address.Hex()).Str("flag", loadtestFlags[c.Name]).Msg("Included contract")
+		}
+
+		out, err := render(alloc)
+		if err != nil {
+			return err
+		}
+		if inputGenalloc.Output == "" {
+			fmt.Println(string(out))
+			return nil
+		}
+		if err = os.WriteFile(inputGenalloc.Output, append(out, '\n'), 0644); err != nil {
+			return err
+		}
+		log.Info().Str("file", inputGenalloc.Output).Int("accounts", len(alloc)).Msg("Wrote genesis allocation")
+		return nil
+	},
+}
+
+// loadAccounts combines the addresses from the flag and the HD wallet.
+// Duplicates are removed so the order of the others is kept.
+func loadAccounts() ([]ethcommon.Address, error) {
+	raw := append([]string{}, inputGenalloc.Addresses...)
+	if inputGenalloc.HDCount > 0 {
+		pw, err := hdwallet.NewPolyWallet(inputGenalloc.Mnemonic, inputGenalloc.Password)
+		if err != nil {
+			return nil, err
+		}
+		if err = pw.SetPath(inputGenalloc.Path); err != nil {
+			return nil, err
+		}
+		export, err := pw.ExportHDAddressRange(inputGenalloc.HDStart, inputGenalloc.HDCount)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range export.Addresses {
+			raw = append(raw, a.ETHAddress)
+		}
+	}
+
+	seen := make(map[ethcommon.Address]struct{}, len(raw))
+	accounts := make([]ethcommon.Address, 0, len(raw))
+	for _, r := range raw {
+		if !ethcommon.IsHexAddress(r) {
+			return nil, fmt.Errorf("the address %s is invalid", r)
+		}
+		a := ethcommon.HexToAddress(r)
+		if _, ok := seen[a]; ok {
+			continue
+		}
+		seen[a] = struct{}{}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
+
+// render returns the allocation as the alloc of a genesis. If a genesis file
+// is given, the allocation is merged into its alloc and the whole genesis is
+// returned. The genesis is kept as raw JSON, so fields go-ethereum doesn't
+// know about, like newer forks or the config of other clients, are kept.
+func render(alloc core.GenesisAlloc) ([]byte, error) {
+	gen := make(map[string]json.RawMessage)
+	existing := make(map[string]json.RawMessage)
+	if inputGenalloc.GenesisFile != "" {
+		data, err := os.ReadFile(inputGenalloc.GenesisFile)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &gen); err != nil {
+			return nil, fmt.Errorf("unable to parse the genesis file %s: %w", inputGenalloc.GenesisFile, err)
+		}
+		if raw, ok := gen["alloc"]; ok {
+			if err = json.Unmarshal(raw, &existing); err != nil {
+				return nil, fmt.Errorf("unable to parse the alloc of %s: %w", inputGenalloc.GenesisFile, err)
+			}
+		}
+	}
+
+	for address, account := range alloc {
+		raw, err := json.Marshal(account)
+		if err != nil {
+			return nil, err
+		}
+		// The keys of the alloc may or may not be prefixed or checksummed.
+		for key := range existing {
+			if ethcommon.IsHexAddress(key) && ethcommon.HexToAddress(key) == address {
+				log.Warn().Str("address", address.Hex()).Msg("Replacing the existing allocation")
+				delete(existing, key)
+			}
+		}
+		existing[address.Hex()] = raw
+	}
+
+	merged, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
+	gen["alloc"] = merged
+	return json.MarshalIndent(gen, "", "  ")
+}
+
+func init() {
+	flagSet := GenallocCmd.PersistentFlags()
+	flagSet.StringSliceVar(&inputGenalloc.Addresses, "addresses", nil, "A comma separated list of addresses to fund")
+	flagSet.StringVar(&inputGenalloc.Mnemonic, "mnemonic", codeQualityMnemonic, "A mnemonic used to derive the addresses to fund")
+	flagSet.StringVar(&inputGenalloc.Password, "password", "", "The password used along with the mnemonic")
+	flagSet.StringVar(&inputGenalloc.Path, "path", "m/44'/60'/0'", "The derivation path of the HD addresses")
+	flagSet.IntVar(&inputGenalloc.HDStart, "hd-start", 0, "The index of the first HD address to fund")
+	flagSet.IntVar(&inputGenalloc.HDCount, "hd-count", 10, "The number of HD addresses to fund")
+	flagSet.StringVar(&inputGenalloc.Balance, "balance", "1000000", "The amount of ether each address is funded with")
+	flagSet.StringVar(&inputGenalloc.Deployer, "deployer", "", "The account the contract addresses are derived from. Defaults to the first funded address")
+	flagSet.StringSliceVar(&inputGenalloc.Contracts, "contracts", genesis.Contracts, "A comma separated list of the load test contracts to include")
+	flagSet.StringVar(&inputGenalloc.GenesisFile, "genesis", "", "A genesis file the allocation is merged into. Only the alloc is written if this is empty")
+	flagSet.StringVarP(&inputGenalloc.Output, "output", "o", "", "The file to write to. The output is written to stdout if this is empty")
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/spf13/cobra"
 
 	_ "embed"
@@ -52,6 +53,12 @@ var (
 	inputFileName *string
 )
 
+// hashOutput is the hash printed with --json.
+type hashOutput struct {
+	Function string `json:"function"`
+	Hash     string `json:"hash"`
+}
+
 // hashCmd represents the hash command
 var HashCmd = &cobra.Command{
 	Use:   fmt.Sprintf("hash [%s]", strings.Join(supportedHashFunctions, "|")),
@@ -70,6 +77,12 @@ var HashCmd = &cobra.Command{
 		}
 		h.Write(data)
 		hashOut := h.Sum(nil)
+		if util.JSONOutput() {
+			if err = util.PrintJSON(hashOutput{Function: args[0], Hash: hex.EncodeToString(hashOut)}); err != nil {
+				cmd.PrintErrf("There was an error printing the hash: %s", err.Error())
+			}
+			return
+		}
 		cmd.Println(hex.EncodeToString(hashOut))
 
 	},
@@ -0,0 +1,148 @@
+package keystore
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	//go:embed usage.md
+	usage                string
+	inputKeystoreDir     *string
+	inputPasswordFile    *string
+	inputPasswordEnv     *string
+	inputPrivateKeyFile  *string
+	inputConfirmPassword *bool
+)
+
+var KeystoreCmd = &cobra.Command{
+	Use:   "keystore",
+	Short: "Manage encrypted keystore accounts.",
+	Long:  usage,
+}
+
+var KeystoreCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new account in the keystore.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password, err := readNewPassword()
+		if err != nil {
+			return err
+		}
+		account, err := signer.OpenKeystore(*inputKeystoreDir).NewAccount(password)
+		if err != nil {
+			return err
+		}
+		return printAccount(account)
+	},
+}
+
+var KeystoreImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import a hex encoded private key into the keystore.",
+	Long: `Import a hex encoded private key into the keystore.
+
+The key is read from --private-key-file or prompted for so it never shows up
+in the shell history.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hexKey, err := signer.ReadPassword(*inputPrivateKeyFile, "", "Private key: ")
+		if err != nil {
+			return err
+		}
+		key, err := ethcrypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+		if err != nil {
+			return fmt.Errorf("couldn't process the hex private key: %w", err)
+		}
+		password, err := readNewPassword()
+		if err != nil {
+			return err
+		}
+		account, err := signer.OpenKeystore(*inputKeystoreDir).ImportECDSA(key, password)
+		if err != nil {
+			return err
+		}
+		return printAccount(account)
+	},
+}
+
+var KeystoreListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the accounts in the keystore.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list := signer.OpenKeystore(*inputKeystoreDir).Accounts()
+		if util.JSONOutput() {
+			out := make([]accountOutput, 0, len(list))
+			for _, account := range list {
+				out = append(out, accountOutput{Address: account.Address.Hex(), File: account.URL.Path})
+			}
+			return util.PrintJSON(out)
+		}
+		for _, account := range list {
+			fmt.Printf("%s\t%s\n", account.Address.Hex(), account.URL.Path)
+		}
+		return nil
+	},
+}
+
+// accountOutput is an account of the keystore printed with --json.
+type accountOutput struct {
+	Address string `json:"address"`
+	File    string `json:"file"`
+}
+
+// printAccount prints a created or imported account.
+func printAccount(account accounts.Account) error {
+	if util.JSONOutput() {
+		return util.PrintJSON(accountOutput{Address: account.Address.Hex(), File: account.URL.Path})
+	}
+	fmt.Printf("Address: %s\nFile:    %s\n", account.Address.Hex(), account.URL.Path)
+	return nil
+}
+
+// readNewPassword reads the passphrase of a new account. It's asked for twice
+// when it's typed in so a typo doesn't lock the account.
+func readNewPassword() (string, error) {
+	password, err := signer.ReadPassword(*inputPasswordFile, *inputPasswordEnv, "Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if *inputPasswordFile != "" {
+		return password, nil
+	}
+	if _, ok := os.LookupEnv(*inputPasswordEnv); ok || !*inputConfirmPassword {
+		return password, nil
+	}
+	confirm, err := signer.ReadPassword("", "", "Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != password {
+		return "", fmt.Errorf("the passphrases don't match")
+	}
+	return password, nil
+}
+
+func init() {
+	flagSet := KeystoreCmd.PersistentFlags()
+	inputKeystoreDir = flagSet.String("keystore", signer.DefaultKeystoreDir(), "The keystore directory")
+	inputPasswordFile = flagSet.String("password-file", "", "A file with the passphrase of the account")
+	inputPasswordEnv = flagSet.String("password-env", signer.DefaultPasswordEnv, "The environment variable with the passphrase of the account")
+	inputConfirmPassword = flagSet.Bool("confirm", true, "Ask for a typed in passphrase twice when creating or importing an account")
+	inputPrivateKeyFile = KeystoreImportCmd.Flags().String("private-key-file", "", "A file with the hex encoded private key to import")
+
+	KeystoreCmd.AddCommand(KeystoreCreateCmd)
+	KeystoreCmd.AddCommand(KeystoreImportCmd)
+	KeystoreCmd.AddCommand(KeystoreListCmd)
+}
@@ -0,0 +1,278 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+const (
+	// abortMinSamples is the number of requests needed before the error
+	// rate is checked, so a couple of early errors don't abort the run.
+	abortMinSamples = 100
+	// abortPollInterval is how often the monitor looks for a new block.
+	abortPollInterval = time.Second
+)
+
+var errLoadTestAborted = errors.New("the load test was aborted")
+
+// abortMonitor stops the load test when the chain looks unhealthy, rather
+// than spending gas on transactions that won't be included.
+type abortMonitor struct {
+	maxErrorRate  float64
+	stalledBlocks uint64
+	minBalance    *big.Int
+	maxBaseFee    *big.Int
+
+	mu     sync.Mutex
+	reason string
+}
+
+// newAbortMonitor returns a monitor for the configured rules, or nil if none
+// of them is enabled.
+func newAbortMonitor() (*abortMonitor, error) {
+	ltp := inputLoadTestParams
+	m := &abortMonitor{
+		maxErrorRate:  *ltp.AbortErrorRate,
+		stalledBlocks: *ltp.AbortStalledBlocks,
+	}
+	if *ltp.AbortMinBalance != "" {
+		minBalance, err := parseMinBalance(*ltp.AbortMinBalance)
+		if err != nil {
+			return nil, err
+		}
+		m.minBalance = minBalance
+	}
+	if *ltp.AbortMaxBaseFee > 0 {
+		m.maxBaseFee = new(big.Int).SetUint64(*ltp.AbortMaxBaseFee)
+	}
+	if *ltp.CallOnly {
+		// Without transactions there's nothing to be included and no gas
+		// spent, so only the error rate applies.
+		m.stalledBlocks = 0
+		m.minBalance = nil
+		m.maxBaseFee = nil
+	}
+	if m.maxErrorRate <= 0 && m.stalledBlocks == 0 && m.minBalance == nil && m.maxBaseFee == nil {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// parseMinBalance converts a decimal amount of ether to wei.
+func parseMinBalance(amount string) (*big.Int, error) {
+	f, ok := new(big.Float).SetPrec(256).SetString(amount)
+	if !ok || f.Sign() < 0 {
+		return nil, fmt.Errorf("the minimum balance %s must be a number of ether", amount)
+	}
+	wei, _ := f.Mul(f, new(big.Float).SetPrec(256).SetInt(big.NewInt(1e18))).Int(nil)
+	return wei, nil
+}
+
+// getReason returns why the load test was aborted, or an empty string.
+func (m *abortMonitor) getReason() string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reason
+}
+
+func (m *abortMonitor) abort(reason string, cancel context.CancelFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.reason != "" {
+		return
+	}
+	m.reason = reason
+	log.Error().Str("reason", reason).Msg("Aborting the load test")
+	cancel()
+}
+
+// watch checks the rules on every new block until the context is done.
+// sentNonce returns the nonce after the last transaction that was sent.
+func (m *abortMonitor) watch(ctx context.Context, c *ethclient.Client, cancel context.CancelFunc, sentNonce func() uint64) {
+	ltp := inputLoadTestParams
+	ticker := time.NewTicker(abortPollInterval)
+	defer ticker.Stop()
+
+	var lastBlockNumber, lastConfirmedNonce, stalledSince uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if m.maxErrorRate > 0 {
+			samples, errs := countSamples()
+			if samples >= abortMinSamples {
+				rate := float64(errs) / float64(samples) * 100
+				if rate > m.maxErrorRate {
+					m.abort(fmt.Sprintf("%.2f%% of the %d requests failed, above the %.2f%% limit", rate, samples, m.maxErrorRate), cancel)
+					return
+				}
+			}
+		}
+
+		header, err := c.HeaderByNumber(ctx, nil)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to get the latest header to check the abort rules")
+			continue
+		}
+		blockNumber := header.Number.Uint64()
+		if blockNumber == lastBlockNumber {
+			continue
+		}
+		lastBlockNumber = blockNumber
+
+		if m.maxBaseFee != nil && header.BaseFee != nil && header.BaseFee.Cmp(m.maxBaseFee) > 0 {
+			m.abort(fmt.Sprintf("the base fee of block %d is %s wei, above the %s wei cap", blockNumber, header.BaseFee, m.maxBaseFee), cancel)
+			return
+		}
+
+		if m.minBalance != nil {
+			balance, err := c.BalanceAt(ctx, *ltp.FromETHAddress, header.Number)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to get the balance to check the abort rules")
+			} else if balance.Cmp(m.minBalance) < 0 {
+				m.abort(fmt.Sprintf("the balance of %s wei is below the %s wei minimum", balance, m.minBalance), cancel)
+				return
+			}
+		}
+
+		if m.stalledBlocks > 0 {
+			confirmedNonce, err := c.NonceAt(ctx, *ltp.FromETHAddress, header.Number)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to get the nonce to check the abort rules")
+				continue
+			}
+			// The count only runs while there are transactions waiting
+			// to be included.
+			if confirmedNonce != lastConfirmedNonce || confirmedNonce >= sentNonce() {
+				lastConfirmedNonce = confirmedNonce
+				stalledSince = blockNumber
+				continue
+			}
+			if blockNumber-stalledSince >= m.stalledBlocks {
+				m.abort(fmt.Sprintf("none of our transactions were included in the last %d blocks", blockNumber-stalledSince), cancel)
+				return
+			}
+		}
+	}
+}
+
+// countSamples returns the number of requests so far and how many failed.
+func countSamples() (samples, errs int) {
+	loadTestResutsMutex.RLock()
+	defer loadTestResutsMutex.RUnlock()
+	for _, s := range loadTestResults {
+		if s.IsError {
+			errs++
+		}
+	}
+	return len(loadTestResults), errs
+}
+
+// printAbortSummary explains why the load test stopped and where it was.
+func printAbortSummary(ctx context.Context, c *ethclient.Client, reason string, startNonce, sentNonce uint64) {
+	ltp := inputLoadTestParams
+	samples, errs := countSamples()
+
+	p := message.NewPrinter(language.English)
+	p.Printf("Load Test Aborted: %s\n", reason)
+	p.Printf("Requests: %v\tErrors: %v\n", number.Decimal(samples), number.Decimal(errs))
+	if *ltp.CallOnly {
+		return
+	}
+	confirmedNonce, err := c.NonceAt(ctx, *ltp.FromETHAddress, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the nonce for the abort summary")
+		return
+	}
+	balance, err := c.BalanceAt(ctx, *ltp.FromETHAddress, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the balance for the abort summary")
+		return
+	}
+	var included uint64
+	if confirmedNonce > startNonce {
+		included = confirmedNonce - startNonce
+	}
+	p.Printf("Transactions Sent: %v\tIncluded: %v\tBalance: %v wei\n", number.Decimal(sentNonce-startNonce), number.Decimal(included), balance)
+}
+
+// sweepFunds sends the pending balance to the address. The sweep gets the
+// next pending nonce, so it's only included after the transactions that are
+// still waiting, and it fails if they end up spending more than the pending
+// balance accounted for.
+func sweepFunds(ctx context.Context, c *ethclient.Client, to ethcommon.Address) error {
+	ltp := inputLoadTestParams
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+
+	nonce, err := c.PendingNonceAt(ctx, *ltp.FromETHAddress)
+	if err != nil {
+		return err
+	}
+	balance, err := c.PendingBalanceAt(ctx, *ltp.FromETHAddress)
+	if err != nil {
+		return err
+	}
+	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
+	if gasPrice == nil {
+		return fmt.Errorf("unable to get the gas price for the sweep")
+	}
+	gas := uint64(21000)
+	value := new(big.Int).Sub(balance, new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gas)))
+	if value.Sign() <= 0 {
+		return fmt.Errorf("the balance of %s wei doesn't cover the gas of the sweep", balance)
+	}
+
+	var tx *ethtypes.Transaction
+	if *ltp.LegacyTransactionMode {
+		tx = ethtypes.NewTx(&ethtypes.LegacyTx{
+			Nonce:    nonce,
+			To:       &to,
+			Value:    value,
+			Gas:      gas,
+			GasPrice: gasPrice,
+		})
+	} else {
+		tx = ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			To:        &to,
+			Gas:       gas,
+			GasFeeCap: gasPrice,
+			GasTipCap: gasTipCap,
+			Value:     value,
+		})
+	}
+
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		return err
+	}
+	stx, err := tops.Signer(*ltp.FromETHAddress, tx)
+	if err != nil {
+		return err
+	}
+	if err = c.SendTransaction(ctx, stx); err != nil {
+		return err
+	}
+	log.Info().Str("to", to.String()).Str("value", value.String()).Str("hash", stx.Hash().String()).Msg("Swept the remaining funds")
+	return nil
+}
@@ -5,13 +5,15 @@ import (
 	_ "embed"
 	"fmt"
 	"math/big"
-	"math/rand"
 	"net/url"
 	"sync"
 	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/maticnetwork/polygon-cli/multicall"
 	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -32,6 +34,7 @@ type (
 		WaitTime    time.Duration
 		Receipt     string
 		IsError     bool
+		ErrorClass  string
 		Nonce       uint64
 	}
 	loadTestParams struct {
@@ -41,6 +44,9 @@ type (
 		BatchSize                           *uint64
 		TimeLimit                           *int64
 		ToRandom                            *bool
+		Recipients                          *string
+		RecipientPoolSize                   *uint64
+		MulticallAddress                    *string
 		CallOnly                            *bool
 		CallOnlyLatestBlock                 *bool
 		URL                                 *url.URL
@@ -62,6 +68,78 @@ type (
 		LtAddress                           *string
 		ERC20Address                        *string
 		ERC721Address                       *string
+		CallerAddress                       *string
+		CallDepth                           *uint64
+		CallWidth                           *uint64
+		LogEmitterAddress                   *string
+		LogCount                            *uint64
+		LogTopics                           *uint64
+		LogDataSize                         *uint64
+		ColdAccessAddress                   *string
+		ColdAccessCount                     *uint64
+		ColdAccessList                      *bool
+		ComputeAddress                      *string
+		ComputeGas                          *uint64
+		ComputeOp                           *string
+		InscriptionData                     *string
+		InscriptionSize                     *uint64
+		InscriptionRandom                   *bool
+		DisperseAddress                     *string
+		DisperseRecipients                  *uint64
+		DisperseToken                       *bool
+		MultisigAddress                     *string
+		MultisigOwners                      *uint64
+		MultisigThreshold                   *uint64
+		MultisigDelegateCall                *bool
+		MultisigFund                        *string
+		SetCodeDelegate                     *string
+		SetCodeAuthorities                  *uint64
+		SetCodeAuthCount                    *uint64
+		RPCReadMix                          *string
+		RPCReadLogsRange                    *uint64
+		ArchiveMix                          *string
+		ArchiveDistribution                 *string
+		TraceMix                            *string
+		TraceTracers                        *[]string
+		ReadTransports                      *[]string
+		ReadBatchSize                       *uint64
+		ReadHTTPURL                         *string
+		ReadWSURL                           *string
+		ReadReferenceURL                    *string
+		ReadInvariants                      *bool
+		ErrorPolicy                         *string
+		HTTPMaxIdleConnsPerHost             *int
+		HTTPDialTimeout                     *time.Duration
+		HTTPResponseTimeout                 *time.Duration
+		HTTPKeepAlives                      *bool
+		HTTP2                               *bool
+		HTTPHeaders                         *[]string
+		SendVia                             *string
+		RelayURL                            *string
+		RelayKey                            *string
+		BundleSize                          *int
+		BundleBlockOffset                   *uint64
+		BundleBlockCount                    *uint64
+		AbortErrorRate                      *float64
+		AbortStalledBlocks                  *uint64
+		AbortMinBalance                     *string
+		AbortMaxBaseFee                     *uint64
+		SweepAddress                        *string
+		Controller                          *bool
+		Agent                               *bool
+		ControlAddress                      *string
+		ControlTLSCert                      *string
+		ControlTLSKey                       *string
+		ControlTLSCA                        *string
+		ControlToken                        *string
+		Agents                              *int
+		AccountStart                        *int
+		Phases                              *[]string
+		StartAt                             *string
+		NTPServer                           *string
+		Mnemonic                            *string
+		MnemonicPassword                    *string
+		MnemonicPath                        *string
 		DelAddress                          *string
 		ContractCallNumberOfBlocksToWaitFor *uint64
 		ContractCallBlockInterval           *uint64
@@ -73,12 +151,15 @@ type (
 		SummaryOutputMode                   *string
 		LegacyTransactionMode               *bool
 		RecallLength                        *uint64
+		L2FeeModel                          *string
+		SignerConfig                        *signer.Config
 
 		// Computed
 		CurrentGasPrice     *big.Int
 		CurrentGasTipCap    *big.Int
 		CurrentNonce        *uint64
 		ECDSAPrivateKey     *ecdsa.PrivateKey
+		Signer              signer.Signer
 		FromETHAddress      *ethcommon.Address
 		ToETHAddress        *ethcommon.Address
 		SendAmount          *big.Int
@@ -87,6 +168,7 @@ type (
 		Mode                loadTestMode
 		ParsedModes         []loadTestMode
 		MultiMode           bool
+		FeeModel            l2FeeModel
 	}
 
 	txpoolStatus struct {
@@ -147,8 +229,6 @@ var (
 		0xFF, 0xBA, 0xDD, 0x11,
 		0xF0, 0x0D, 0xBA, 0xBE,
 	}
-
-	randSrc *rand.Rand
 )
 
 // LoadtestCmd represents the loadtest command
@@ -157,6 +237,12 @@ var LoadtestCmd = &cobra.Command{
 	Short: "Run a generic load test against an Eth/EVM style JSON-RPC endpoint.",
 	Long:  usage,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if *inputLoadTestParams.Controller {
+			return runController(cmd.Context())
+		}
+		if *inputLoadTestParams.Agent {
+			return runAgent(cmd.Context())
+		}
 		err := runLoadTest(cmd.Context())
 		if err != nil {
 			return err
@@ -167,6 +253,35 @@ var LoadtestCmd = &cobra.Command{
 		zerolog.DurationFieldUnit = time.Second
 		zerolog.DurationFieldInteger = true
 
+		if err := util.UseJSONFormat(cmd.Flags(), "output-mode", "json"); err != nil {
+			return err
+		}
+		if (*inputLoadTestParams.ControlTLSCert == "") != (*inputLoadTestParams.ControlTLSKey == "") {
+			return fmt.Errorf("--control-tls-cert and --control-tls-key must be set together")
+		}
+		if *inputLoadTestParams.Controller {
+			if *inputLoadTestParams.Agent {
+				return fmt.Errorf("an instance can't be both the controller and an agent")
+			}
+			if *inputLoadTestParams.Agents < 1 {
+				return fmt.Errorf("the controller needs at least one agent")
+			}
+			if *inputLoadTestParams.AccountStart < 0 {
+				return fmt.Errorf("the account start index can't be negative")
+			}
+			// The controller doesn't send anything, so it doesn't need an
+			// RPC endpoint.
+			if _, err := parseStartAt(*inputLoadTestParams.StartAt); err != nil {
+				return err
+			}
+			_, err := parsePhases(*inputLoadTestParams.Phases, *inputLoadTestParams.TimeLimit, *inputLoadTestParams.RateLimit)
+			return err
+		}
+		if *inputLoadTestParams.Agent && *inputLoadTestParams.Mnemonic == "" {
+			return fmt.Errorf("agents need the --mnemonic of the accounts handed out by the controller")
+		}
+
+		args = util.WithProfileRPCURL(args)
 		if len(args) != 1 {
 			return fmt.Errorf("expected exactly one argument")
 		}
@@ -183,6 +298,46 @@ var LoadtestCmd = &cobra.Command{
 		if *inputLoadTestParams.AdaptiveBackoffFactor <= 0.0 {
 			return fmt.Errorf("the backoff factor needs to be non-zero positive")
 		}
+		switch *inputLoadTestParams.SendVia {
+		case sendViaPublic, sendViaPrivate, sendViaBundle:
+		default:
+			return fmt.Errorf("the send method %s is not supported, expected public, private, or bundle", *inputLoadTestParams.SendVia)
+		}
+		if *inputLoadTestParams.AbortMinBalance != "" {
+			if _, err = parseMinBalance(*inputLoadTestParams.AbortMinBalance); err != nil {
+				return err
+			}
+		}
+		if *inputLoadTestParams.SweepAddress != "" && !ethcommon.IsHexAddress(*inputLoadTestParams.SweepAddress) {
+			return fmt.Errorf("the sweep address %s is invalid", *inputLoadTestParams.SweepAddress)
+		}
+		if *inputLoadTestParams.ComputeOp != "keccak" && *inputLoadTestParams.ComputeOp != "arith" {
+			return fmt.Errorf("the compute op %s is not supported, expected keccak or arith", *inputLoadTestParams.ComputeOp)
+		}
+		if err = validateRecipients(); err != nil {
+			return err
+		}
+		if *inputLoadTestParams.MultisigThreshold == 0 || *inputLoadTestParams.MultisigThreshold > *inputLoadTestParams.MultisigOwners {
+			return fmt.Errorf("the multisig threshold needs to be between 1 and the number of owners")
+		}
+		if *inputLoadTestParams.SetCodeAuthCount == 0 || *inputLoadTestParams.SetCodeAuthCount > *inputLoadTestParams.SetCodeAuthorities {
+			return fmt.Errorf("the set code authorizations per transaction need to be between 1 and the number of authorities")
+		}
+		if *inputLoadTestParams.RPCReadLogsRange == 0 {
+			return fmt.Errorf("the rpc read logs range needs to be non-zero positive")
+		}
+		if *inputLoadTestParams.HTTPMaxIdleConnsPerHost < 0 {
+			return fmt.Errorf("the idle connections per host can't be negative")
+		}
+		if _, err = parseHTTPHeaders(*inputLoadTestParams.HTTPHeaders); err != nil {
+			return err
+		}
+		if *inputLoadTestParams.DisperseRecipients == 0 {
+			return fmt.Errorf("the disperse recipients need to be non-zero positive")
+		}
+		if err = inputLoadTestParams.SignerConfig.Validate(); err != nil {
+			return err
+		}
 		return nil
 	},
 }
@@ -196,7 +351,14 @@ func init() {
 	ltp.PrivateKey = LoadtestCmd.PersistentFlags().String("private-key", codeQualityPrivateKey, "The hex encoded private key that we'll use to send transactions")
 	ltp.ChainID = LoadtestCmd.PersistentFlags().Uint64("chain-id", 0, "The chain id for the transactions.")
 	ltp.ToAddress = LoadtestCmd.PersistentFlags().String("to-address", "0xDEADBEEFDEADBEEFDEADBEEFDEADBEEFDEADBEEF", "The address that we're going to send to")
-	ltp.ToRandom = LoadtestCmd.PersistentFlags().Bool("to-random", false, "When doing a transfer test, should we send to random addresses rather than DEADBEEFx5")
+	ltp.ToRandom = LoadtestCmd.PersistentFlags().Bool("to-random", false, "When doing a transfer test, should we send to random addresses rather than DEADBEEFx5. This is the same as --recipients random")
+	ltp.Recipients = LoadtestCmd.PersistentFlags().String("recipients", recipientsFixed, `The addresses the transfer, ERC20, ERC721, and disperse modes send to
+fixed - always send to --to-address
+random - send to a new random address every time, which grows the state
+pool - cycle through --recipient-pool-size addresses derived from --seed, which are funded before the test
+seed - send to a new address derived from --seed every time, which gives the same addresses on every run`)
+	ltp.RecipientPoolSize = LoadtestCmd.PersistentFlags().Uint64("recipient-pool-size", 1000, "If the recipients are a pool, this controls how many addresses it has")
+	ltp.MulticallAddress = LoadtestCmd.PersistentFlags().String("multicall-address", multicall.DefaultAddress, "The Multicall3 contract the balances of the recipient pool are read through before the test (empty to batch eth_getBalance requests instead)")
 	ltp.CallOnly = LoadtestCmd.PersistentFlags().Bool("call-only", false, "When using this mode, rather than sending a transaction, we'll just call. This mode is incompatible with adaptive rate limiting, summarization, and a few other features.")
 	ltp.CallOnlyLatestBlock = LoadtestCmd.PersistentFlags().Bool("call-only-latest", false, "When using call only mode with recall, should we execute on the latest block or on the original block")
 	ltp.HexSendAmount = LoadtestCmd.PersistentFlags().String("send-amount", "0x38D7EA4C68000", "The amount of wei that we'll send every transaction")
@@ -218,14 +380,104 @@ r - random modes
 2 - ERC20 Transfers
 7 - ERC721 Mints
 R - total recall
-rpc - call random rpc methods`)
+rpc - call random rpc methods
+cd - nested contract to contract calls
+l - emit logs
+C - touch cold accounts and storage slots
+k - pure compute loops
+I - inscriptions, transactions to ourselves with data
+D - disperse ether or ERC20 tokens to many recipients per transaction
+M - multisig wallet transactions signed by a threshold of owners
+sc - EIP-7702 transactions setting and clearing account delegations
+rr - a weighted mix of read rpc calls with latencies per method
+ar - reads of historical state with latencies per block age
+tr - debug traces of recent transactions and blocks with different tracers`)
 	ltp.Function = LoadtestCmd.PersistentFlags().Uint64P("function", "f", 1, "A specific function to be called if running with `--mode f` or a specific precompiled contract when running with `--mode a`")
 	ltp.Iterations = LoadtestCmd.PersistentFlags().Uint64P("iterations", "i", 1, "If we're making contract calls, this controls how many times the contract will execute the instruction in a loop. If we are making ERC721 Mints, this indicates the minting batch size")
 	ltp.ByteCount = LoadtestCmd.PersistentFlags().Uint64P("byte-count", "b", 1024, "If we're in store mode, this controls how many bytes we'll try to store in our contract")
-	ltp.Seed = LoadtestCmd.PersistentFlags().Int64("seed", 123456, "A seed for generating random values and addresses")
+	ltp.Seed = LoadtestCmd.PersistentFlags().Int64("seed", 123456, "A seed for every random choice of the load test, such as the recipients, the data, and the modes of random mode. The same seed reproduces the choices of a run")
 	ltp.LtAddress = LoadtestCmd.PersistentFlags().String("lt-address", "", "The address of a pre-deployed load test contract")
 	ltp.ERC20Address = LoadtestCmd.PersistentFlags().String("erc20-address", "", "The address of a pre-deployed erc 20 contract")
 	ltp.ERC721Address = LoadtestCmd.PersistentFlags().String("erc721-address", "", "The address of a pre-deployed erc 721 contract")
+	ltp.CallerAddress = LoadtestCmd.PersistentFlags().String("caller-address", "", "The address of a pre-deployed caller contract")
+	ltp.CallDepth = LoadtestCmd.PersistentFlags().Uint64("call-depth", 8, "If we're in call depth mode, this controls how deep the nested calls of each transaction go")
+	ltp.CallWidth = LoadtestCmd.PersistentFlags().Uint64("call-width", 4, "If we're in call depth mode, this controls how many calls the top level call fans out to. Each transaction makes call-depth * call-width calls")
+	ltp.LogEmitterAddress = LoadtestCmd.PersistentFlags().String("log-emitter-address", "", "The address of a pre-deployed log emitter contract")
+	ltp.LogCount = LoadtestCmd.PersistentFlags().Uint64("log-count", 10, "If we're in logs mode, this controls how many logs each transaction emits")
+	ltp.LogTopics = LoadtestCmd.PersistentFlags().Uint64("log-topics", 4, "If we're in logs mode, this controls how many topics each log has (0 to 4)")
+	ltp.LogDataSize = LoadtestCmd.PersistentFlags().Uint64("log-data-size", 32, "If we're in logs mode, this controls how many bytes of data each log has")
+	ltp.ColdAccessAddress = LoadtestCmd.PersistentFlags().String("cold-access-address", "", "The address of a pre-deployed cold access contract")
+	ltp.ColdAccessCount = LoadtestCmd.PersistentFlags().Uint64("cold-access-count", 100, "If we're in cold mode, this controls how many distinct accounts and storage slots each transaction touches")
+	ltp.ColdAccessList = LoadtestCmd.PersistentFlags().Bool("cold-access-list", false, "If we're in cold mode, include every touched account and storage slot in the access list of the transaction so they're warm")
+	ltp.ComputeAddress = LoadtestCmd.PersistentFlags().String("compute-address", "", "The address of a pre-deployed compute loop contract")
+	ltp.ComputeGas = LoadtestCmd.PersistentFlags().Uint64("compute-gas", 1000000, "If we're in compute mode, this is the gas limit of each transaction, all of which is burned on compute")
+	ltp.ComputeOp = LoadtestCmd.PersistentFlags().String("compute-op", "keccak", "If we're in compute mode, this controls the loop that's executed (keccak | arith)")
+	ltp.InscriptionData = LoadtestCmd.PersistentFlags().String("inscription-data", `data:,{"p":"prc-20","op":"mint","tick":"pols","amt":"100000000"}`, "If we're in inscription mode, this is the data of each transaction")
+	ltp.InscriptionSize = LoadtestCmd.PersistentFlags().Uint64("inscription-size", 0, "If we're in inscription mode, send this many bytes of data instead of the inscription data")
+	ltp.InscriptionRandom = LoadtestCmd.PersistentFlags().Bool("inscription-random", false, "If we're in inscription mode, send random data in every transaction rather than repeating the same payload")
+	ltp.DisperseAddress = LoadtestCmd.PersistentFlags().String("disperse-address", "", "The address of a pre-deployed disperse contract")
+	ltp.DisperseRecipients = LoadtestCmd.PersistentFlags().Uint64("disperse-recipients", 100, "If we're in disperse mode, this controls how many recipients each transaction pays")
+	ltp.DisperseToken = LoadtestCmd.PersistentFlags().Bool("disperse-token", false, "If we're in disperse mode, send ERC20 tokens rather than ether")
+	ltp.MultisigAddress = LoadtestCmd.PersistentFlags().String("multisig-address", "", "The address of a pre-deployed multisig wallet, which needs to have the owners derived from the seed")
+	ltp.MultisigOwners = LoadtestCmd.PersistentFlags().Uint64("multisig-owners", 5, "If we're in multisig mode, this controls how many owners the wallet has")
+	ltp.MultisigThreshold = LoadtestCmd.PersistentFlags().Uint64("multisig-threshold", 3, "If we're in multisig mode, this controls how many owner signatures each transaction needs")
+	ltp.MultisigDelegateCall = LoadtestCmd.PersistentFlags().Bool("multisig-delegate-call", false, "If we're in multisig mode, delegate call the disperse contract to pay the disperse recipients rather than sending to a single recipient")
+	ltp.MultisigFund = LoadtestCmd.PersistentFlags().String("multisig-fund", "0xDE0B6B3A7640000", "The amount of wei that we'll send to a newly deployed multisig wallet")
+	ltp.SetCodeDelegate = LoadtestCmd.PersistentFlags().String("set-code-delegate", "", "If we're in set code mode, the address the authorities delegate to. Defaults to the load test contract")
+	ltp.SetCodeAuthorities = LoadtestCmd.PersistentFlags().Uint64("set-code-authorities", 100, "If we're in set code mode, this controls how many authority accounts derived from the seed are used in turn")
+	ltp.SetCodeAuthCount = LoadtestCmd.PersistentFlags().Uint64("set-code-auth-count", 1, "If we're in set code mode, this controls how many authorizations each transaction has")
+	ltp.RPCReadMix = LoadtestCmd.PersistentFlags().String("rpc-read-mix", "eth_getBalance:1,eth_call:1,eth_getLogs:1,eth_getBlockByNumber:1", "If we're in rpc read mode, the read methods and their weights, e.g. eth_getBalance:3,eth_call:1")
+	ltp.RPCReadLogsRange = LoadtestCmd.PersistentFlags().Uint64("rpc-read-logs-range", 100, "If we're in rpc read mode, this controls how many blocks each eth_getLogs call covers")
+	ltp.ArchiveMix = LoadtestCmd.PersistentFlags().String("archive-mix", "eth_getBalance:1,eth_getStorageAt:1,eth_call:1", "If we're in archive mode, the read methods and their weights, e.g. eth_getStorageAt:3,eth_call:1")
+	ltp.ArchiveDistribution = LoadtestCmd.PersistentFlags().String("archive-distribution", "log", "If we're in archive mode, how the blocks are spread over the history (log | uniform). Log reads recent and old state about as often, uniform mostly reads old state")
+	ltp.TraceMix = LoadtestCmd.PersistentFlags().String("trace-mix", "debug_traceTransaction:1,debug_traceBlockByNumber:1", "If we're in trace mode, the trace methods and their weights")
+	ltp.TraceTracers = LoadtestCmd.PersistentFlags().StringSlice("trace-tracers", []string{"callTracer", "prestateTracer", "structLogger"}, "If we're in trace mode, the tracers each call picks one of. structLogger is the default opcode logger")
+	ltp.ReadTransports = LoadtestCmd.PersistentFlags().StringSlice("read-transports", []string{}, "If we're in a read mode, compare sending the same calls over these transports (http | batch | ws)")
+	ltp.ReadBatchSize = LoadtestCmd.PersistentFlags().Uint64("read-batch-size", 10, "When comparing read transports, this controls how many calls each request sends, in one batch or one by one")
+	ltp.ReadHTTPURL = LoadtestCmd.PersistentFlags().String("read-http-url", "", "When comparing read transports, the HTTP endpoint of the http and batch transports. Defaults to the RPC endpoint")
+	ltp.ReadWSURL = LoadtestCmd.PersistentFlags().String("read-ws-url", "", "When comparing read transports, the WebSocket endpoint of the ws transport. Defaults to the RPC endpoint")
+	ltp.ReadReferenceURL = LoadtestCmd.PersistentFlags().String("read-reference-url", "", "If we're in a read mode, compare the results with the same calls to this endpoint. Calls at the latest block are skipped")
+	ltp.ReadInvariants = LoadtestCmd.PersistentFlags().Bool("read-invariants", false, "If we're in a read mode, check that the blocks read link to their parents and match their receipts roots")
+	ltp.SendVia = LoadtestCmd.PersistentFlags().String("send-via", sendViaPublic, `How the load test transactions are sent
+public - eth_sendRawTransaction to the RPC endpoint
+private - eth_sendPrivateTransaction to the relay
+bundle - eth_sendBundle to the relay with bundles of --bundle-size transactions`)
+	ltp.RelayURL = LoadtestCmd.PersistentFlags().String("relay-url", "", "The URL of the relay for private transactions and bundles. Defaults to the RPC endpoint")
+	ltp.RelayKey = LoadtestCmd.PersistentFlags().String("relay-key", "", "The hex encoded private key used to sign the relay requests. Defaults to a random key")
+	ltp.BundleSize = LoadtestCmd.PersistentFlags().Int("bundle-size", 1, "The number of transactions in each bundle")
+	ltp.BundleBlockOffset = LoadtestCmd.PersistentFlags().Uint64("bundle-block-offset", 1, "The bundles target the block this many blocks after the current one")
+	ltp.BundleBlockCount = LoadtestCmd.PersistentFlags().Uint64("bundle-block-count", 1, "Each bundle is sent for this many consecutive target blocks")
+	ltp.AbortErrorRate = LoadtestCmd.PersistentFlags().Float64("abort-error-rate", 0, "Abort the load test when more than this percentage of the requests failed, once there are at least 100. Zero disables the rule")
+	ltp.AbortStalledBlocks = LoadtestCmd.PersistentFlags().Uint64("abort-stalled-blocks", 0, "Abort the load test when none of our pending transactions were included for this many blocks. Zero disables the rule")
+	ltp.AbortMinBalance = LoadtestCmd.PersistentFlags().String("abort-min-balance", "", "Abort the load test when the balance of the sending account drops below this amount of ether")
+	ltp.AbortMaxBaseFee = LoadtestCmd.PersistentFlags().Uint64("abort-max-base-fee", 0, "Abort the load test when the base fee goes above this many wei. Zero disables the rule")
+	ltp.ErrorPolicy = LoadtestCmd.PersistentFlags().String("error-policy", "", `What to do after a request fails with a class of error, e.g. insufficient-funds:abort,txpool-full:skip
+The classes are nonce-too-low, underpriced, insufficient-funds, txpool-full, connection, and other
+retry - send the next request with the same nonce (the default, except for nonce-too-low and underpriced)
+skip - move on to the next nonce
+abort - stop the load test`)
+	ltp.HTTPMaxIdleConnsPerHost = LoadtestCmd.PersistentFlags().Int("http-max-idle-conns-per-host", 0, "The number of idle connections to each HTTP endpoint that are kept for reuse. Zero keeps as many as the concurrency")
+	ltp.HTTPDialTimeout = LoadtestCmd.PersistentFlags().Duration("http-dial-timeout", 30*time.Second, "How long to wait for a connection to an HTTP endpoint")
+	ltp.HTTPResponseTimeout = LoadtestCmd.PersistentFlags().Duration("http-response-timeout", 0, "How long to wait for the response of an HTTP endpoint after sending a request. Zero waits as long as the request")
+	ltp.HTTPKeepAlives = LoadtestCmd.PersistentFlags().Bool("http-keep-alives", true, "Reuse the connections to the HTTP endpoints. Without keep-alives, every request opens a new connection")
+	ltp.HTTP2 = LoadtestCmd.PersistentFlags().Bool("http2", true, "Use HTTP/2 with the HTTPS endpoints that support it")
+	ltp.HTTPHeaders = LoadtestCmd.PersistentFlags().StringArray("header", []string{}, "A header sent with the requests to the HTTP endpoints, e.g. \"Authorization: Bearer abc\". Repeat the flag for more")
+	ltp.SweepAddress = LoadtestCmd.PersistentFlags().String("sweep-address", "", "When the load test is aborted, send the remaining funds of the sending account to this address")
+	ltp.Controller = LoadtestCmd.PersistentFlags().Bool("controller", false, "Run as the controller of a distributed load test. The controller hands out rates, accounts, and phases to the agents and combines their results")
+	ltp.Agent = LoadtestCmd.PersistentFlags().Bool("agent", false, "Run as an agent of a distributed load test, taking the rate, account, and phases from the controller")
+	ltp.ControlAddress = LoadtestCmd.PersistentFlags().String("control-address", "localhost:7890", "The address the controller listens on and the agents connect to")
+	ltp.ControlTLSCert = LoadtestCmd.PersistentFlags().String("control-tls-cert", "", "The certificate the controller serves the control plane over TLS with")
+	ltp.ControlTLSKey = LoadtestCmd.PersistentFlags().String("control-tls-key", "", "The private key of the certificate of --control-tls-cert")
+	ltp.ControlTLSCA = LoadtestCmd.PersistentFlags().String("control-tls-ca", "", "The CA certificates the agents check the certificate of the controller with. Setting it makes the agents connect over TLS")
+	ltp.ControlToken = LoadtestCmd.PersistentFlags().String("control-token", "", "A shared token the agents authenticate to the controller with")
+	ltp.Agents = LoadtestCmd.PersistentFlags().Int("agents", 1, "The number of agents the controller waits for before starting the load test")
+	ltp.AccountStart = LoadtestCmd.PersistentFlags().Int("account-start", 0, "The index of the HD account of the first agent. Each agent gets the next index")
+	ltp.Phases = LoadtestCmd.PersistentFlags().StringSlice("phases", nil, "The phases of a distributed load test as duration@rate, e.g. 1m@100,5m@1000. The rate is the total across the agents. Defaults to one phase of --time-limit at --rate-limit")
+	ltp.StartAt = LoadtestCmd.PersistentFlags().String("start-at", "", "A scheduled start time for a distributed load test in RFC 3339 format, e.g. 2024-01-02T15:04:05Z. Defaults to a few seconds after the agents registered")
+	ltp.NTPServer = LoadtestCmd.PersistentFlags().String("ntp-server", "pool.ntp.org", "The NTP server the controller and agents correct their clocks with, so the phases start at the same time everywhere. Set it to an empty string to use the local clocks")
+	ltp.Mnemonic = LoadtestCmd.PersistentFlags().String("mnemonic", "", "The mnemonic the agents derive their accounts from")
+	ltp.MnemonicPassword = LoadtestCmd.PersistentFlags().String("mnemonic-password", "", "The password used along with the mnemonic")
+	ltp.MnemonicPath = LoadtestCmd.PersistentFlags().String("mnemonic-path", "m/44'/60'/0'", "The derivation path of the agent accounts")
 	ltp.ContractCallNumberOfBlocksToWaitFor = LoadtestCmd.PersistentFlags().Uint64("contract-call-nb-blocks-to-wait-for", 30, "The number of blocks to wait for before giving up on a contract deployment")
 	ltp.ContractCallBlockInterval = LoadtestCmd.PersistentFlags().Uint64("contract-call-block-interval", 1, "During deployment, this flag controls if we should check every block, every other block, or every nth block to determine that the contract has been deployed")
 	ltp.ForceContractDeploy = LoadtestCmd.PersistentFlags().Bool("force-contract-deploy", false, "Some load test modes don't require a contract deployment. Set this flag to true to force contract deployments. This will still respect the --lt-address flags.")
@@ -237,6 +489,13 @@ rpc - call random rpc methods`)
 	ltp.SummaryOutputMode = LoadtestCmd.PersistentFlags().String("output-mode", "text", "Format mode for summary output (json | text)")
 	ltp.LegacyTransactionMode = LoadtestCmd.PersistentFlags().Bool("legacy", false, "Send a legacy transaction instead of an EIP1559 transaction.")
 	ltp.RecallLength = LoadtestCmd.PersistentFlags().Uint64("recall-blocks", 50, "The number of blocks that we'll attempt to fetch for recall")
+	ltp.L2FeeModel = LoadtestCmd.PersistentFlags().String("l2-fee-model", string(l2FeeModelAuto), `The fee model used to compute the expected and actual transaction costs
+auto - detect the fee model from the chain
+none - gas used times the gas price
+op - OP stack chains, which charge an L1 data fee on top of the execution fee
+zkevm - Polygon zkEVM chains, which charge an effective gas price that includes the L1 data cost`)
+	ltp.SignerConfig = new(signer.Config)
+	ltp.SignerConfig.AddFlags(LoadtestCmd.PersistentFlags())
 	inputLoadTestParams = *ltp
 
 	// TODO Compression
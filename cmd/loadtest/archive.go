@@ -0,0 +1,101 @@
+package loadtest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The distributions of the ages of the blocks that archive mode reads the
+// state at.
+const (
+	// archiveDistributionLog spreads the ages over the orders of magnitude,
+	// so recent and old state are read about as often.
+	archiveDistributionLog = "log"
+	// archiveDistributionUniform spreads the blocks evenly over the history,
+	// so most reads are of old state.
+	archiveDistributionUniform = "uniform"
+)
+
+// archiveAgeBucket groups the reads by how many blocks old the state is.
+// Full nodes usually keep the state of the last 128 blocks, so older state
+// needs an archive node.
+type archiveAgeBucket struct {
+	Name  string
+	Limit uint64
+}
+
+var (
+	archiveAgeBuckets = []archiveAgeBucket{
+		{"0-127", 128},
+		{"128-9999", 10_000},
+		{"10000-999999", 1_000_000},
+		{"1000000+", math.MaxUint64},
+	}
+	archiveMix   *readMix
+	archiveStats *readStats
+)
+
+// setupArchive parses the mix of archive mode.
+func setupArchive() error {
+	ltp := inputLoadTestParams
+	if *ltp.ArchiveDistribution != archiveDistributionLog && *ltp.ArchiveDistribution != archiveDistributionUniform {
+		return fmt.Errorf("the archive distribution %s is not supported, expected log or uniform", *ltp.ArchiveDistribution)
+	}
+	var err error
+	archiveMix, err = parseReadMix(*ltp.ArchiveMix, rpcReadGetBalance, rpcReadGetStorageAt, rpcReadCall)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(archiveAgeBuckets))
+	for i, b := range archiveAgeBuckets {
+		names[i] = b.Name
+	}
+	archiveStats = newReadStats(names)
+	return nil
+}
+
+// getArchiveAge returns how many blocks before the head the state is read.
+func getArchiveAge(rng *rand.Rand, head uint64) uint64 {
+	if *inputLoadTestParams.ArchiveDistribution == archiveDistributionUniform {
+		return rng.Uint64() % (head + 1)
+	}
+	age := uint64(math.Exp(rng.Float64()*math.Log(float64(head)+1))) - 1
+	return min(age, head)
+}
+
+func getArchiveAgeBucket(age uint64) string {
+	for _, b := range archiveAgeBuckets {
+		if age < b.Limit {
+			return b.Name
+		}
+	}
+	return archiveAgeBuckets[len(archiveAgeBuckets)-1].Name
+}
+
+// getArchiveCall picks a read of the balance, storage, or a contract call at
+// a block in the history of the chain. The accounts and contracts come from
+// the recent activity, whether or not they existed at the block, since the
+// lookups go through the state of the block either way.
+func getArchiveCall(rng *rand.Rand, ia *IndexedActivity) readCall {
+	age := getArchiveAge(rng, ia.BlockNumber)
+	block := hexutil.EncodeUint64(ia.BlockNumber - age)
+	method := archiveMix.pick(rng)
+	var args []any
+	switch method {
+	case rpcReadGetBalance:
+		args = []any{getActiveAddress(rng, ia), block}
+	case rpcReadGetStorageAt:
+		contract := getActiveAddress(rng, ia)
+		if len(ia.Contracts) > 0 {
+			contract = ia.Contracts[rng.Intn(len(ia.Contracts))]
+		}
+		// The first slots are where most contracts keep their state
+		args = []any{contract, hexutil.EncodeUint64(uint64(rng.Intn(10))), block}
+	case rpcReadCall:
+		args = getBalanceOfCall(rng, ia, block)
+	}
+	return readCall{Method: method, Args: args, Key: getArchiveAgeBucket(age)}
+}
@@ -0,0 +1,212 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/spf13/cobra"
+)
+
+// compareMetric is a value of the summaries that's compared between the base
+// run and the current one.
+type compareMetric struct {
+	Name string
+	// Rate metrics are percentages, which are compared in percentage points
+	// instead of relative to the base run.
+	Rate           bool
+	HigherIsBetter bool
+	Threshold      float64
+	value          func(SummaryOutput) float64
+}
+
+// CompareResult is how a metric changed between the runs. Change is in
+// percent of the base value, or in percentage points for rates.
+type CompareResult struct {
+	Metric    string
+	Rate      bool
+	Base      float64
+	Current   float64
+	Change    float64
+	Threshold float64
+	Regressed bool
+	// Skipped is set when the base run doesn't have the metric, e.g. because
+	// it was exported by an older version.
+	Skipped bool
+}
+
+type CompareOutput struct {
+	Base        string
+	Current     string
+	Results     []CompareResult
+	Regressions int
+}
+
+var (
+	compareTPSThreshold       *float64
+	compareLatencyThreshold   *float64
+	compareErrorRateThreshold *float64
+	compareGasThreshold       *float64
+	compareOutputMode         *string
+)
+
+// LoadtestCompareCmd diffs two summaries exported with --output-mode json.
+var LoadtestCompareCmd = &cobra.Command{
+	Use:   "compare base.json current.json",
+	Short: "Compare the summaries of two load test runs and flag regressions.",
+	Long: `Compare the summaries of two load test runs and flag regressions.
+
+The summaries are the output of a load test run with --output-mode json, e.g.
+
+  polycli loadtest --output-mode json [flags] http://localhost:8545 > current.json
+
+The throughput, latency percentiles, error rates, and gas per transaction of
+the current run are compared with the base run. A metric regresses when it's
+worse than the base run by more than its threshold. The thresholds of the
+throughput, latencies, and gas are relative to the base run in percent, and
+the one of the error rates is in percentage points.
+
+The command fails with exit code 5 when any metric regressed, so it can gate
+the release of a node in CI.`,
+	Args: cobra.ExactArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := util.UseJSONFormat(cmd.Flags(), "output", "json"); err != nil {
+			return err
+		}
+		if *compareOutputMode != "text" && *compareOutputMode != "json" {
+			return fmt.Errorf("the output mode %s is not supported, expected text or json", *compareOutputMode)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		base, err := readSummaryOutput(args[0])
+		if err != nil {
+			return err
+		}
+		current, err := readSummaryOutput(args[1])
+		if err != nil {
+			return err
+		}
+
+		out := CompareOutput{Base: args[0], Current: args[1]}
+		out.Results = compareSummaries(base, current, getCompareMetrics())
+		for _, r := range out.Results {
+			if r.Regressed {
+				out.Regressions++
+			}
+		}
+		if *compareOutputMode == "json" {
+			val, _ := json.MarshalIndent(out, "", "    ")
+			fmt.Println(string(val))
+		} else {
+			printCompareOutput(out)
+		}
+
+		if out.Regressions > 0 {
+			// The regressions were printed, the usage would only hide them.
+			cmd.SilenceUsage = true
+			return util.AssertionFailure(fmt.Errorf("%d of the metrics regressed beyond their thresholds", out.Regressions))
+		}
+		return nil
+	},
+}
+
+func init() {
+	flagSet := LoadtestCompareCmd.Flags()
+	compareTPSThreshold = flagSet.Float64("tps-threshold", 10, "The drop in percent of the transactions and gas per second that's a regression")
+	compareLatencyThreshold = flagSet.Float64("latency-threshold", 20, "The increase in percent of a latency percentile that's a regression")
+	compareErrorRateThreshold = flagSet.Float64("error-rate-threshold", 1, "The increase in percentage points of the error rates that's a regression")
+	compareGasThreshold = flagSet.Float64("gas-threshold", 5, "The increase in percent of the gas used per transaction that's a regression")
+	compareOutputMode = flagSet.String("output", "text", "The format of the comparison (text | json)")
+
+	LoadtestCmd.AddCommand(LoadtestCompareCmd)
+}
+
+func readSummaryOutput(path string) (SummaryOutput, error) {
+	var s SummaryOutput
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err = json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("unable to decode the summary %s, it needs to be exported with --output-mode json: %w", path, err)
+	}
+	if s.TotalTx == 0 {
+		return s, fmt.Errorf("the summary %s doesn't have any transactions", path)
+	}
+	return s, nil
+}
+
+func getCompareMetrics() []compareMetric {
+	return []compareMetric{
+		{Name: "Transactions per second", HigherIsBetter: true, Threshold: *compareTPSThreshold, value: func(s SummaryOutput) float64 { return s.TransactionsPerSec }},
+		{Name: "Gas per second", HigherIsBetter: true, Threshold: *compareTPSThreshold, value: func(s SummaryOutput) float64 { return s.GasPerSecond }},
+		{Name: "Latency median", Threshold: *compareLatencyThreshold, value: func(s SummaryOutput) float64 { return s.Latencies.Median }},
+		{Name: "Latency P90", Threshold: *compareLatencyThreshold, value: func(s SummaryOutput) float64 { return s.LatencyPercentiles.P90 }},
+		{Name: "Latency P95", Threshold: *compareLatencyThreshold, value: func(s SummaryOutput) float64 { return s.LatencyPercentiles.P95 }},
+		{Name: "Latency P99", Threshold: *compareLatencyThreshold, value: func(s SummaryOutput) float64 { return s.LatencyPercentiles.P99 }},
+		{Name: "Latency max", Threshold: *compareLatencyThreshold, value: func(s SummaryOutput) float64 { return s.Latencies.Max }},
+		{Name: "Failed transactions", Rate: true, Threshold: *compareErrorRateThreshold, value: func(s SummaryOutput) float64 {
+			return 100 * float64(s.TotalTx-s.SuccessfulTx) / float64(s.TotalTx)
+		}},
+		{Name: "Failed requests", Rate: true, Threshold: *compareErrorRateThreshold, value: func(s SummaryOutput) float64 {
+			if s.Samples == 0 {
+				return math.NaN()
+			}
+			return 100 * float64(s.SampleErrors) / float64(s.Samples)
+		}},
+		{Name: "Gas per transaction", Threshold: *compareGasThreshold, value: func(s SummaryOutput) float64 { return float64(s.TotalGasUsed) / float64(s.TotalTx) }},
+	}
+}
+
+func compareSummaries(base, current SummaryOutput, metrics []compareMetric) []CompareResult {
+	results := make([]CompareResult, 0, len(metrics))
+	for _, m := range metrics {
+		r := CompareResult{Metric: m.Name, Rate: m.Rate, Base: m.value(base), Current: m.value(current), Threshold: m.Threshold}
+		switch {
+		case math.IsNaN(r.Base) || math.IsNaN(r.Current):
+			// NaN can't be encoded in JSON
+			r.Base, r.Current = 0, 0
+			r.Skipped = true
+		case m.Rate:
+			r.Change = r.Current - r.Base
+		case r.Base == 0:
+			r.Skipped = true
+		default:
+			r.Change = 100 * (r.Current - r.Base) / r.Base
+		}
+		if !r.Skipped && m.HigherIsBetter {
+			r.Regressed = -r.Change > m.Threshold
+		} else if !r.Skipped {
+			r.Regressed = r.Change > m.Threshold
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func printCompareOutput(out CompareOutput) {
+	fmt.Printf("Base: %s\tCurrent: %s\n", out.Base, out.Current)
+	fmt.Printf("%-24s %16s %16s %12s %10s\n", "Metric", "Base", "Current", "Change", "Threshold")
+	for _, r := range out.Results {
+		unit := "%"
+		if r.Rate {
+			unit = "pp"
+		}
+		change := "n/a"
+		if !r.Skipped {
+			change = fmt.Sprintf("%+.2f%s", r.Change, unit)
+			if r.Regressed {
+				change += " !"
+			}
+		}
+		fmt.Printf("%-24s %16.4f %16.4f %12s %10s\n", r.Metric, r.Base, r.Current, change, fmt.Sprintf("%.2f%s", r.Threshold, unit))
+	}
+	if out.Regressions > 0 {
+		fmt.Printf("Regressions: %d\n", out.Regressions)
+	} else {
+		fmt.Println("No regressions")
+	}
+}
@@ -0,0 +1,612 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maticnetwork/polygon-cli/hdwallet"
+	"github.com/maticnetwork/polygon-cli/proto/gen/pb"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The control plane is the LoadTestControl gRPC service of proto/loadtest.proto.
+// Its messages are converted to the structs below at the edges, since the
+// structs are also what the summary prints as JSON.
+const (
+	// controlStartDelay gives the agents time to receive their assignments
+	// before the first phase starts.
+	controlStartDelay = 5 * time.Second
+)
+
+type (
+	// loadTestPhase is a part of the scenario with a total rate across all
+	// the agents.
+	loadTestPhase struct {
+		Duration time.Duration `json:"duration"`
+		Rate     float64       `json:"rate"`
+	}
+
+	// agentAssignment is the share of the load test given to an agent. The
+	// agent sends from the HD account at AccountIndex and runs the phases
+	// at its share of their rate, starting at StartTime. The start time is
+	// on the NTP corrected clock.
+	agentAssignment struct {
+		AgentIndex   int             `json:"agentIndex"`
+		AccountIndex int             `json:"accountIndex"`
+		Phases       []loadTestPhase `json:"phases"`
+		StartTime    time.Time       `json:"startTime"`
+
+		// clock is the agent's own clock correction.
+		clock clockSync
+	}
+
+	// agentReport is what an agent sent in each phase. The samples are
+	// split into the phases by their corrected time, so the phases of all
+	// the agents cover the same window.
+	agentReport struct {
+		AgentIndex int           `json:"agentIndex"`
+		Name       string        `json:"name"`
+		Address    string        `json:"address"`
+		Clock      clockSync     `json:"clock"`
+		Phases     []phaseResult `json:"phases"`
+
+		// ControllerSkew is how far the agent's corrected clock was ahead
+		// of the controller's when it registered, including the time the
+		// request took to arrive. It's set by the controller.
+		ControllerSkew time.Duration `json:"controllerSkew"`
+	}
+
+	phaseResult struct {
+		Requests  uint64  `json:"requests"`
+		Errors    uint64  `json:"errors"`
+		TotalWait float64 `json:"totalWait"`
+	}
+
+	// registration is an agent waiting for the others to register. It only
+	// gets its index once they all did, so an agent that leaves before then
+	// frees its slot.
+	registration struct {
+		name  string
+		skew  time.Duration
+		index int
+	}
+
+	// controller hands out the assignments once all the agents registered,
+	// and collects their reports.
+	controller struct {
+		pb.UnimplementedLoadTestControlServer
+
+		agents       int
+		accountStart int
+		phases       []loadTestPhase
+		clock        clockSync
+		startAt      time.Time
+
+		mu        sync.Mutex
+		pending   []*registration
+		names     []string
+		skews     []time.Duration
+		startTime time.Time
+		ready     chan struct{}
+		reports   map[int]*agentReport
+		done      chan struct{}
+	}
+)
+
+// parsePhases parses phases written as duration@rate, e.g. 30s@100. Without
+// phases, the load test is a single phase at the rate limit for the time
+// limit.
+func parsePhases(raw []string, timeLimit int64, rateLimit float64) ([]loadTestPhase, error) {
+	if len(raw) == 0 {
+		if timeLimit <= 0 {
+			return nil, fmt.Errorf("a distributed load test needs --phases or a --time-limit")
+		}
+		raw = []string{fmt.Sprintf("%ds@%v", timeLimit, rateLimit)}
+	}
+
+	phases := make([]loadTestPhase, 0, len(raw))
+	for _, r := range raw {
+		d, rt, ok := strings.Cut(r, "@")
+		if !ok {
+			return nil, fmt.Errorf("the phase %s should be written as duration@rate", r)
+		}
+		duration, err := time.ParseDuration(d)
+		if err != nil {
+			return nil, fmt.Errorf("invalid phase duration %s: %w", d, err)
+		}
+		phaseRate, err := strconv.ParseFloat(rt, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid phase rate %s: %w", rt, err)
+		}
+		if duration <= 0 || phaseRate <= 0 {
+			return nil, fmt.Errorf("the phase %s needs a positive duration and rate", r)
+		}
+		phases = append(phases, loadTestPhase{Duration: duration, Rate: phaseRate})
+	}
+	return phases, nil
+}
+
+// Register is called by the agents, and returns their assignment once all of
+// them registered. The request carries the clock of the agent, so the
+// controller can check how far apart their corrected clocks are.
+func (c *controller) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.AgentAssignment, error) {
+	clock := newClockSync(req.Clock)
+	c.mu.Lock()
+	if len(c.names) == c.agents {
+		c.mu.Unlock()
+		return nil, status.Errorf(codes.ResourceExhausted, "all %d agents already registered", c.agents)
+	}
+	reg := &registration{name: req.Name, skew: clock.toCorrected(req.LocalTime.AsTime()).Sub(c.clock.now())}
+	c.pending = append(c.pending, reg)
+	log.Info().Str("name", req.Name).Dur("clockOffset", clock.Offset).Dur("controllerSkew", reg.skew).Int("registered", len(c.pending)).Int("expected", c.agents).Msg("Agent registered")
+	if len(c.pending) == c.agents {
+		for i, p := range c.pending {
+			p.index = i
+			c.names = append(c.names, p.name)
+			c.skews = append(c.skews, p.skew)
+		}
+		c.startTime = c.clock.now().Add(controlStartDelay)
+		if c.startAt.After(c.startTime) {
+			c.startTime = c.startAt
+		} else if !c.startAt.IsZero() {
+			log.Warn().Time("startAt", c.startAt).Msg("The scheduled start time passed before the agents registered, starting now")
+		}
+		log.Info().Time("start", c.startTime).Msg("All the agents registered")
+		close(c.ready)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.ready:
+	case <-ctx.Done():
+		c.release(reg)
+		return nil, ctx.Err()
+	}
+	index := reg.index
+
+	// Each agent gets its share of the rate, so the agents together run the
+	// phases at their total rate.
+	phases := make([]loadTestPhase, len(c.phases))
+	for i, p := range c.phases {
+		phases[i] = loadTestPhase{Duration: p.Duration, Rate: p.Rate / float64(c.agents)}
+	}
+	a := &agentAssignment{
+		AgentIndex:   index,
+		AccountIndex: c.accountStart + index,
+		Phases:       phases,
+		StartTime:    c.startTime,
+	}
+	return a.proto(), nil
+}
+
+// release frees the slot of an agent that left before all the agents
+// registered.
+func (c *controller) release(reg *registration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.names) == c.agents {
+		return
+	}
+	for i, p := range c.pending {
+		if p == reg {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			break
+		}
+	}
+	log.Warn().Str("name", reg.name).Int("registered", len(c.pending)).Int("expected", c.agents).Msg("Agent left before the start")
+}
+
+// Report is called by the agents once they're done.
+func (c *controller) Report(ctx context.Context, req *pb.AgentReport) (*pb.ReportAck, error) {
+	report := newAgentReport(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if report.AgentIndex < 0 || report.AgentIndex >= len(c.names) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown agent %d", report.AgentIndex)
+	}
+	if len(report.Phases) != len(c.phases) {
+		return nil, status.Errorf(codes.InvalidArgument, "expected %d phases, got %d", len(c.phases), len(report.Phases))
+	}
+	_, reported := c.reports[report.AgentIndex]
+	report.ControllerSkew = c.skews[report.AgentIndex]
+	c.reports[report.AgentIndex] = report
+	log.Info().Int("agent", report.AgentIndex).Str("name", report.Name).Str("address", report.Address).Msg("Agent reported")
+	if !reported && len(c.reports) == c.agents {
+		close(c.done)
+	}
+	return &pb.ReportAck{}, nil
+}
+
+// checkControlToken rejects the calls that don't carry the control token. It
+// does nothing without a token.
+func checkControlToken(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if token == "" {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) != 1 || subtle.ConstantTimeCompare([]byte(values[0]), []byte("Bearer "+token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid control token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// isLoopback tells if the controller only listens on the loopback interface.
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// controlServerCredentials returns the TLS credentials of the controller if
+// its certificate is set, or insecure credentials otherwise.
+func controlServerCredentials() (credentials.TransportCredentials, error) {
+	ltp := inputLoadTestParams
+	if *ltp.ControlTLSCert == "" {
+		return insecure.NewCredentials(), nil
+	}
+	cert, err := tls.LoadX509KeyPair(*ltp.ControlTLSCert, *ltp.ControlTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the control certificate: %w", err)
+	}
+	return credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}), nil
+}
+
+// controlClientCredentials returns the TLS credentials the agents check the
+// controller's certificate with if the CA is set, or insecure credentials
+// otherwise.
+func controlClientCredentials() (credentials.TransportCredentials, error) {
+	ltp := inputLoadTestParams
+	if *ltp.ControlTLSCA == "" {
+		return insecure.NewCredentials(), nil
+	}
+	pem, err := os.ReadFile(*ltp.ControlTLSCA)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the control ca certificates: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", *ltp.ControlTLSCA)
+	}
+	return credentials.NewTLS(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}), nil
+}
+
+// runController serves the control plane until all the agents reported or
+// it's interrupted, and prints the combined results.
+func runController(ctx context.Context) error {
+	ltp := inputLoadTestParams
+	phases, err := parsePhases(*ltp.Phases, *ltp.TimeLimit, *ltp.RateLimit)
+	if err != nil {
+		return err
+	}
+	startAt, err := parseStartAt(*ltp.StartAt)
+	if err != nil {
+		return err
+	}
+	c := &controller{
+		agents:       *ltp.Agents,
+		accountStart: *ltp.AccountStart,
+		phases:       phases,
+		clock:        syncClock(*ltp.NTPServer),
+		startAt:      startAt,
+		ready:        make(chan struct{}),
+		reports:      make(map[int]*agentReport),
+		done:         make(chan struct{}),
+	}
+
+	creds, err := controlServerCredentials()
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", *ltp.ControlAddress)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to listen for agents")
+		return err
+	}
+	if *ltp.ControlTLSCert == "" && !isLoopback(lis.Addr()) {
+		log.Warn().Str("address", lis.Addr().String()).Msg("The control plane isn't encrypted, set --control-tls-cert and --control-tls-key to serve it over TLS")
+	}
+	server := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(checkControlToken(*ltp.ControlToken)))
+	pb.RegisterLoadTestControlServer(server, c)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Error().Err(err).Msg("Control plane stopped")
+		}
+	}()
+	defer server.Stop()
+	log.Info().Str("address", lis.Addr().String()).Int("agents", c.agents).Msg("Waiting for agents to register")
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	select {
+	case <-c.done:
+	case <-sigCh:
+		log.Info().Msg("Interrupted.. Printing the reports received so far")
+	case <-ctx.Done():
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return printControllerResults(c.phases, c.reports)
+}
+
+func printControllerResults(phases []loadTestPhase, reports map[int]*agentReport) error {
+	totals := make([]phaseResult, len(phases))
+	for _, r := range reports {
+		for i, pr := range r.Phases {
+			totals[i].Requests += pr.Requests
+			totals[i].Errors += pr.Errors
+			totals[i].TotalWait += pr.TotalWait
+		}
+	}
+
+	if *inputLoadTestParams.SummaryOutputMode == "json" {
+		val, err := json.MarshalIndent(map[string]any{"phases": phases, "totals": totals, "agents": reports}, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(val))
+		return nil
+	}
+
+	p := message.NewPrinter(language.English)
+	p.Printf("Agents Reported: %v\n", number.Decimal(len(reports)))
+	for i := 0; i < len(reports); i++ {
+		r, ok := reports[i]
+		if !ok {
+			continue
+		}
+		p.Printf("Agent: %v\tName: %s\tAddress: %s\tClock Offset: %v\tNTP Round Trip: %v\tController Skew: %v\n",
+			number.Decimal(r.AgentIndex), r.Name, r.Address, r.Clock.Offset, r.Clock.RoundTrip, r.ControllerSkew)
+	}
+	for i, phase := range phases {
+		t := totals[i]
+		var meanWait float64
+		if t.Requests > 0 {
+			meanWait = t.TotalWait / float64(t.Requests)
+		}
+		p.Printf("Phase: %v\tDuration: %v\tTarget Rate: %v\tAchieved Rate: %v\tRequests: %v\tErrors: %v\tMean Wait: %v\n",
+			number.Decimal(i), phase.Duration, number.Decimal(phase.Rate),
+			number.Decimal(float64(t.Requests)/phase.Duration.Seconds()),
+			number.Decimal(t.Requests), number.Decimal(t.Errors), number.Decimal(meanWait))
+	}
+	return nil
+}
+
+// parseStartAt parses the scheduled start time, which is optional.
+func parseStartAt(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time %s, expected RFC 3339: %w", raw, err)
+	}
+	return t, nil
+}
+
+// runAgent registers with the controller, runs the assigned share of the load
+// test, and reports back.
+func runAgent(ctx context.Context) error {
+	ltp := inputLoadTestParams
+	if ltp.SignerConfig.Kind != signer.KindPrivateKey {
+		return fmt.Errorf("agents derive their account from the mnemonic, so the %s signer isn't supported", ltp.SignerConfig.Kind)
+	}
+
+	creds, err := controlClientCredentials()
+	if err != nil {
+		return err
+	}
+	conn, err := grpc.DialContext(ctx, *ltp.ControlAddress, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to dial the controller")
+		return err
+	}
+	defer conn.Close()
+	client := pb.NewLoadTestControlClient(conn)
+	if *ltp.ControlToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*ltp.ControlToken)
+	}
+
+	name, err := os.Hostname()
+	if err != nil {
+		name = "unknown"
+	}
+	clock := syncClock(*ltp.NTPServer)
+	log.Info().Str("controller", *ltp.ControlAddress).Msg("Registering with the controller")
+	req := &pb.RegisterRequest{Name: name, LocalTime: timestamppb.Now(), Clock: clock.proto()}
+	res, err := client.Register(ctx, req, grpc.WaitForReady(true))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to register with the controller")
+		return err
+	}
+	assignment := newAgentAssignment(res)
+	if len(assignment.Phases) == 0 {
+		return fmt.Errorf("the controller didn't assign any phases")
+	}
+
+	pw, err := hdwallet.NewPolyWallet(*ltp.Mnemonic, *ltp.MnemonicPassword)
+	if err != nil {
+		return err
+	}
+	if err = pw.SetPath(*ltp.MnemonicPath); err != nil {
+		return err
+	}
+	export, err := pw.ExportHDAddressRange(assignment.AccountIndex, 1)
+	if err != nil {
+		return err
+	}
+	account := export.Addresses[0]
+	log.Info().Int("agent", assignment.AgentIndex).Int("accountIndex", assignment.AccountIndex).Str("address", account.ETHAddress).Time("start", assignment.StartTime).Msg("Received assignment")
+
+	// The phases decide the rate and how long the agent runs.
+	var total time.Duration
+	for _, p := range assignment.Phases {
+		total += p.Duration
+	}
+	*ltp.PrivateKey = account.HexPrivateKey
+	*ltp.RateLimit = assignment.Phases[0].Rate
+	*ltp.AdaptiveRateLimit = false
+	*ltp.TimeLimit = int64(math.Ceil(total.Seconds()))
+	*ltp.Requests = math.MaxInt64
+	assignment.clock = clock
+	agentSchedule = assignment
+
+	select {
+	case <-time.After(time.Until(clock.toLocal(assignment.StartTime))):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err = runLoadTest(ctx); err != nil {
+		return err
+	}
+
+	report := &agentReport{
+		AgentIndex: assignment.AgentIndex,
+		Name:       name,
+		Address:    account.ETHAddress,
+		Clock:      clock,
+		Phases:     getPhaseResults(assignment),
+	}
+	if _, err = client.Report(ctx, report.proto()); err != nil {
+		log.Error().Err(err).Msg("Unable to report to the controller")
+		return err
+	}
+	return nil
+}
+
+// agentSchedule is the assignment of the agent, or nil when it isn't one.
+var agentSchedule *agentAssignment
+
+// runPhases moves the rate limit to the rate of each phase as it starts. The
+// phases are timed from the start time shared by all the agents rather than
+// from when this agent finished its setup, so the agents change phases
+// together.
+func runPhases(ctx context.Context, rl *rate.Limiter, a *agentAssignment) {
+	end := a.StartTime
+	for i, p := range a.Phases {
+		end = end.Add(p.Duration)
+		if a.clock.now().After(end) {
+			continue
+		}
+		rl.SetLimit(rate.Limit(p.Rate))
+		log.Info().Int("phase", i).Float64("rate", p.Rate).Time("end", end).Msg("Starting phase")
+		select {
+		case <-time.After(time.Until(a.clock.toLocal(end))):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// getPhaseResults splits the samples into the phases by their corrected
+// request time.
+func getPhaseResults(a *agentAssignment) []phaseResult {
+	results := make([]phaseResult, len(a.Phases))
+	loadTestResutsMutex.RLock()
+	defer loadTestResutsMutex.RUnlock()
+	for _, s := range loadTestResults {
+		requestTime := a.clock.toCorrected(s.RequestTime)
+		end := a.StartTime
+		for i, p := range a.Phases {
+			end = end.Add(p.Duration)
+			if requestTime.Before(end) {
+				results[i].Requests++
+				if s.IsError {
+					results[i].Errors++
+				}
+				results[i].TotalWait += s.WaitTime.Seconds()
+				break
+			}
+		}
+	}
+	return results
+}
+
+func newClockSync(c *pb.ClockSync) clockSync {
+	return clockSync{Offset: c.GetOffset().AsDuration(), RoundTrip: c.GetRoundTrip().AsDuration()}
+}
+
+func (s clockSync) proto() *pb.ClockSync {
+	return &pb.ClockSync{Offset: durationpb.New(s.Offset), RoundTrip: durationpb.New(s.RoundTrip)}
+}
+
+func newAgentAssignment(a *pb.AgentAssignment) *agentAssignment {
+	phases := make([]loadTestPhase, 0, len(a.Phases))
+	for _, p := range a.Phases {
+		phases = append(phases, loadTestPhase{Duration: p.Duration.AsDuration(), Rate: p.Rate})
+	}
+	return &agentAssignment{
+		AgentIndex:   int(a.AgentIndex),
+		AccountIndex: int(a.AccountIndex),
+		Phases:       phases,
+		StartTime:    a.StartTime.AsTime(),
+	}
+}
+
+func (a *agentAssignment) proto() *pb.AgentAssignment {
+	phases := make([]*pb.LoadTestPhase, 0, len(a.Phases))
+	for _, p := range a.Phases {
+		phases = append(phases, &pb.LoadTestPhase{Duration: durationpb.New(p.Duration), Rate: p.Rate})
+	}
+	return &pb.AgentAssignment{
+		AgentIndex:   int64(a.AgentIndex),
+		AccountIndex: int64(a.AccountIndex),
+		Phases:       phases,
+		StartTime:    timestamppb.New(a.StartTime),
+	}
+}
+
+func newAgentReport(r *pb.AgentReport) *agentReport {
+	phases := make([]phaseResult, 0, len(r.Phases))
+	for _, p := range r.Phases {
+		phases = append(phases, phaseResult{Requests: p.Requests, Errors: p.Errors, TotalWait: p.TotalWait})
+	}
+	return &agentReport{
+		AgentIndex: int(r.AgentIndex),
+		Name:       r.Name,
+		Address:    r.Address,
+		Clock:      newClockSync(r.Clock),
+		Phases:     phases,
+	}
+}
+
+func (r *agentReport) proto() *pb.AgentReport {
+	phases := make([]*pb.PhaseResult, 0, len(r.Phases))
+	for _, p := range r.Phases {
+		phases = append(phases, &pb.PhaseResult{Requests: p.Requests, Errors: p.Errors, TotalWait: p.TotalWait})
+	}
+	return &pb.AgentReport{
+		AgentIndex: int64(r.AgentIndex),
+		Name:       r.Name,
+		Address:    r.Address,
+		Clock:      r.Clock.proto(),
+		Phases:     phases,
+	}
+}
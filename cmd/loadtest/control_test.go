@@ -0,0 +1,71 @@
+package loadtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maticnetwork/polygon-cli/proto/gen/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRegisterCancelledFreesSlot(t *testing.T) {
+	c := &controller{
+		agents:  2,
+		phases:  []loadTestPhase{{Duration: time.Minute, Rate: 10}},
+		ready:   make(chan struct{}),
+		reports: make(map[int]*agentReport),
+		done:    make(chan struct{}),
+	}
+
+	// The agent leaves while it waits for the other one.
+	ctx, cancel := context.WithCancel(context.Background())
+	left := make(chan error)
+	go func() {
+		_, err := c.Register(ctx, &pb.RegisterRequest{Name: "left", LocalTime: timestamppb.Now()})
+		left <- err
+	}()
+	for {
+		c.mu.Lock()
+		registered := len(c.pending)
+		c.mu.Unlock()
+		if registered == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-left; err == nil {
+		t.Fatal("expected the cancelled registration to fail")
+	}
+
+	// Two more agents still fill both slots.
+	var wg sync.WaitGroup
+	indexes := make([]int64, 2)
+	errs := make([]error, 2)
+	for i := range indexes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			a, err := c.Register(context.Background(), &pb.RegisterRequest{Name: "agent", LocalTime: timestamppb.Now()})
+			errs[i] = err
+			if err == nil {
+				indexes[i] = a.AgentIndex
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("registration %d failed: %v", i, err)
+		}
+	}
+	if indexes[0] == indexes[1] {
+		t.Errorf("expected different agent indexes, got %d twice", indexes[0])
+	}
+	if len(c.names) != 2 {
+		t.Errorf("expected 2 registered agents, got %d", len(c.names))
+	}
+}
@@ -0,0 +1,201 @@
+package loadtest
+
+import (
+	"math"
+	"math/big"
+	"sort"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// fairnessDeciles is the number of buckets of the position distribution.
+const fairnessDeciles = 10
+
+// FairnessReport describes how the transactions in the test window were
+// ordered within their blocks.
+type FairnessReport struct {
+	// OurTransactions is the number of our transactions in the test window.
+	OurTransactions int
+	// TipPositionCorrelation is the Spearman rank correlation between the tip
+	// and the position of all the transactions in the test window. Higher tips
+	// getting earlier positions gives a negative correlation, and it's zero
+	// when there's no relationship or the tips are all the same.
+	TipPositionCorrelation float64
+	// MeanRelativePosition is where our transactions landed in their blocks on
+	// average, from 0 for the first position to 1 for the last.
+	MeanRelativePosition float64
+	// PositionDeciles is the number of our transactions in each tenth of the
+	// blocks, from the first tenth to the last.
+	PositionDeciles [fairnessDeciles]int
+	// Sandwiches are our transactions that look like they were sandwiched.
+	Sandwiches []Sandwich
+}
+
+// Sandwich is one of our transactions with the transactions right before and
+// after it sent by the same account to the same address, which is the shape
+// of a sandwich attack.
+type Sandwich struct {
+	BlockNumber uint64
+	Transaction ethcommon.Hash
+	Front       ethcommon.Hash
+	Back        ethcommon.Hash
+	Sender      ethcommon.Address
+}
+
+// getFairnessReport analyzes the ordering of the blocks. It has to run before
+// the block summaries are filtered, since that drops the transactions that
+// aren't ours.
+func getFairnessReport(bs map[uint64]blockSummary, startNonce, endNonce uint64) FairnessReport {
+	from := *inputLoadTestParams.FromETHAddress
+	isOurs := func(tx rpctypes.RawTransactionResponse) bool {
+		nonce := tx.Nonce.ToUint64()
+		return tx.From.ToAddress() == from && nonce >= startNonce && nonce <= endNonce
+	}
+
+	report := FairnessReport{}
+	var tips, positions []float64
+	var totalPosition float64
+	for _, bn := range getSortedMapKeys(bs) {
+		block := bs[bn].Block
+		txs := make([]rpctypes.RawTransactionResponse, len(block.Transactions))
+		copy(txs, block.Transactions)
+		sort.Slice(txs, func(i, j int) bool {
+			return txs[i].TransactionIndex.ToUint64() < txs[j].TransactionIndex.ToUint64()
+		})
+
+		hasOurs := false
+		for _, tx := range txs {
+			if isOurs(tx) {
+				hasOurs = true
+				break
+			}
+		}
+		if !hasOurs {
+			continue
+		}
+
+		baseFee := block.BaseFeePerGas.ToBigInt()
+		for i, tx := range txs {
+			position := 0.0
+			if len(txs) > 1 {
+				position = float64(i) / float64(len(txs)-1)
+			}
+			tip, _ := new(big.Float).SetInt(getEffectiveTip(tx, baseFee)).Float64()
+			tips = append(tips, tip)
+			positions = append(positions, position)
+
+			if !isOurs(tx) {
+				continue
+			}
+			report.OurTransactions++
+			totalPosition += position
+			decile := int(position * fairnessDeciles)
+			if decile == fairnessDeciles {
+				decile--
+			}
+			report.PositionDeciles[decile]++
+
+			if i == 0 || i == len(txs)-1 {
+				continue
+			}
+			front, back := txs[i-1], txs[i+1]
+			sender, to := front.From.ToAddress(), front.To.ToAddress()
+			if sender != from && sender == back.From.ToAddress() && to != (ethcommon.Address{}) && to == back.To.ToAddress() {
+				report.Sandwiches = append(report.Sandwiches, Sandwich{
+					BlockNumber: bn,
+					Transaction: tx.Hash.ToHash(),
+					Front:       front.Hash.ToHash(),
+					Back:        back.Hash.ToHash(),
+					Sender:      sender,
+				})
+			}
+		}
+	}
+
+	if report.OurTransactions > 0 {
+		report.MeanRelativePosition = totalPosition / float64(report.OurTransactions)
+	}
+	report.TipPositionCorrelation = spearman(tips, positions)
+	return report
+}
+
+// getEffectiveTip returns the tip per gas the transaction pays to the block
+// producer. Without a base fee, it's the whole gas price.
+func getEffectiveTip(tx rpctypes.RawTransactionResponse, baseFee *big.Int) *big.Int {
+	if tx.MaxFeePerGas != "" && tx.MaxPriorityFeePerGas != "" {
+		tip := tx.MaxPriorityFeePerGas.ToBigInt()
+		maxTip := new(big.Int).Sub(tx.MaxFeePerGas.ToBigInt(), baseFee)
+		if maxTip.Cmp(tip) < 0 {
+			return maxTip
+		}
+		return tip
+	}
+	return new(big.Int).Sub(tx.GasPrice.ToBigInt(), baseFee)
+}
+
+// spearman returns the Spearman rank correlation of the values, or zero if
+// either of them doesn't vary.
+func spearman(x, y []float64) float64 {
+	if len(x) < 2 || len(x) != len(y) {
+		return 0
+	}
+	rx, ry := ranks(x), ranks(y)
+
+	var meanX, meanY float64
+	for i := range rx {
+		meanX += rx[i]
+		meanY += ry[i]
+	}
+	meanX /= float64(len(rx))
+	meanY /= float64(len(ry))
+
+	var cov, varX, varY float64
+	for i := range rx {
+		dx, dy := rx[i]-meanX, ry[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// ranks returns the rank of each value, where tied values get the average of
+// their ranks.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+
+	r := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			r[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return r
+}
+
+func printFairnessReport(p *message.Printer, report FairnessReport) {
+	p.Printf("Tip/Position Correlation: %v\tMean Relative Position: %v\n", number.Decimal(report.TipPositionCorrelation), number.Percent(report.MeanRelativePosition))
+	p.Printf("Position Deciles: %v\n", report.PositionDeciles)
+	p.Printf("Possible Sandwiches: %v\n", number.Decimal(len(report.Sandwiches)))
+	for _, s := range report.Sandwiches {
+		p.Printf("Block: %v\tTx: %s\tFront: %s\tBack: %s\tSender: %s\n", number.Decimal(s.BlockNumber), s.Transaction, s.Front, s.Back, s.Sender)
+	}
+}
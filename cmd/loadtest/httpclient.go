@@ -0,0 +1,84 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/util"
+)
+
+// loadTestHTTPClient is shared by the RPC clients and the relay of the load
+// test, so the connections to an endpoint are pooled across them.
+var loadTestHTTPClient *http.Client
+
+// newLoadTestHTTPClient returns a client with the transport configured by the
+// flags. The default transport only keeps two idle connections per host,
+// which makes the workers open a new connection for most requests at high
+// concurrency.
+func newLoadTestHTTPClient() *http.Client {
+	ltp := inputLoadTestParams
+	maxIdleConnsPerHost := *ltp.HTTPMaxIdleConnsPerHost
+	if maxIdleConnsPerHost == 0 {
+		maxIdleConnsPerHost = int(*ltp.Concurrency)
+	}
+	dialer := &net.Dialer{
+		Timeout:   *ltp.HTTPDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 util.ProxyFunc(),
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          max(100, maxIdleConnsPerHost),
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: *ltp.HTTPResponseTimeout,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     !*ltp.HTTPKeepAlives,
+		ForceAttemptHTTP2:     *ltp.HTTP2,
+		TLSClientConfig:       util.RPCTLSConfig(),
+	}
+	if !*ltp.HTTP2 {
+		// A non-nil empty map turns off the upgrade to HTTP/2
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return &http.Client{Transport: transport}
+}
+
+// parseHTTPHeaders parses headers like "Authorization: Bearer abc".
+func parseHTTPHeaders(headers []string) (http.Header, error) {
+	h := make(http.Header, len(headers))
+	for _, header := range headers {
+		key, value, ok := strings.Cut(header, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("the header %s needs to be a name and a value, e.g. \"Authorization: Bearer abc\"", header)
+		}
+		h.Add(key, strings.TrimSpace(value))
+	}
+	return h, nil
+}
+
+// dialRPC dials an RPC endpoint of the load test. HTTP endpoints use the
+// client and send the custom headers, while other endpoints are dialed as
+// usual.
+func dialRPC(ctx context.Context, rawURL string, hc *http.Client) (*ethrpc.Client, error) {
+	client, err := util.DialRPCWithClient(ctx, rawURL, hc)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := parseHTTPHeaders(*inputLoadTestParams.HTTPHeaders)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range headers {
+		client.SetHeader(key, strings.Join(values, ", "))
+	}
+	return client, nil
+}
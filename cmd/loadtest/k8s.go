@@ -0,0 +1,452 @@
+package loadtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	k8sKindJob        = "job"
+	k8sKindDeployment = "deployment"
+
+	k8sFormatManifest = "manifest"
+	k8sFormatHelm     = "helm"
+)
+
+type (
+	k8sObject struct {
+		APIVersion string            `yaml:"apiVersion"`
+		Kind       string            `yaml:"kind"`
+		Metadata   k8sMetadata       `yaml:"metadata"`
+		Type       string            `yaml:"type,omitempty"`
+		StringData map[string]string `yaml:"stringData,omitempty"`
+		Spec       interface{}       `yaml:"spec,omitempty"`
+	}
+	k8sMetadata struct {
+		Name        string            `yaml:"name,omitempty"`
+		Namespace   string            `yaml:"namespace,omitempty"`
+		Labels      map[string]string `yaml:"labels,omitempty"`
+		Annotations map[string]string `yaml:"annotations,omitempty"`
+	}
+	k8sJobSpec struct {
+		BackoffLimit int            `yaml:"backoffLimit"`
+		Parallelism  int            `yaml:"parallelism"`
+		Completions  int            `yaml:"completions"`
+		Template     k8sPodTemplate `yaml:"template"`
+	}
+	k8sDeploymentSpec struct {
+		Replicas int            `yaml:"replicas"`
+		Selector k8sSelector    `yaml:"selector"`
+		Template k8sPodTemplate `yaml:"template"`
+	}
+	k8sSelector struct {
+		MatchLabels map[string]string `yaml:"matchLabels"`
+	}
+	k8sPodTemplate struct {
+		Metadata k8sMetadata `yaml:"metadata"`
+		Spec     k8sPodSpec  `yaml:"spec"`
+	}
+	k8sPodSpec struct {
+		RestartPolicy  string         `yaml:"restartPolicy,omitempty"`
+		InitContainers []k8sContainer `yaml:"initContainers,omitempty"`
+		Containers     []k8sContainer `yaml:"containers"`
+	}
+	k8sContainer struct {
+		Name          string        `yaml:"name"`
+		Image         string        `yaml:"image"`
+		Args          []string      `yaml:"args"`
+		Env           []k8sEnvVar   `yaml:"env,omitempty"`
+		Ports         []k8sPort     `yaml:"ports,omitempty"`
+		Resources     *k8sResources `yaml:"resources,omitempty"`
+		RestartPolicy string        `yaml:"restartPolicy,omitempty"`
+	}
+	k8sEnvVar struct {
+		Name      string       `yaml:"name"`
+		ValueFrom k8sEnvSource `yaml:"valueFrom"`
+	}
+	k8sEnvSource struct {
+		SecretKeyRef k8sSecretKey `yaml:"secretKeyRef"`
+	}
+	k8sSecretKey struct {
+		Name string `yaml:"name"`
+		Key  string `yaml:"key"`
+	}
+	k8sPort struct {
+		Name          string `yaml:"name"`
+		ContainerPort int    `yaml:"containerPort"`
+	}
+	k8sResources struct {
+		Requests map[string]string `yaml:"requests"`
+		Limits   map[string]string `yaml:"limits"`
+	}
+
+	// helmValues are the values of a chart that runs the load test. They
+	// hold the same settings as the manifests, in the shape most charts use.
+	helmValues struct {
+		Image          string            `yaml:"image"`
+		Kind           string            `yaml:"kind"`
+		Replicas       int               `yaml:"replicas"`
+		Args           []string          `yaml:"args"`
+		Env            []k8sEnvVar       `yaml:"env,omitempty"`
+		Secret         helmSecret        `yaml:"secret"`
+		PodLabels      map[string]string `yaml:"podLabels"`
+		PodAnnotations map[string]string `yaml:"podAnnotations,omitempty"`
+		Resources      *k8sResources     `yaml:"resources,omitempty"`
+		Monitor        helmMonitor       `yaml:"monitor"`
+	}
+	helmSecret struct {
+		Create     bool              `yaml:"create"`
+		Name       string            `yaml:"name"`
+		StringData map[string]string `yaml:"stringData,omitempty"`
+	}
+	helmMonitor struct {
+		Enabled bool     `yaml:"enabled"`
+		Args    []string `yaml:"args,omitempty"`
+		Port    int      `yaml:"port,omitempty"`
+	}
+
+	// k8sConfig is the load test configuration translated for the pod.
+	k8sConfig struct {
+		loadtestArgs []string
+		monitorArgs  []string
+		env          []k8sEnvVar
+		secretData   map[string]string
+	}
+)
+
+var (
+	k8sKind         *string
+	k8sFormat       *string
+	k8sName         *string
+	k8sNamespace    *string
+	k8sImage        *string
+	k8sReplicas     *int
+	k8sSecretName   *string
+	k8sCreateSecret *bool
+	k8sMonitor      *bool
+	k8sMetricsPort  *int
+	k8sCPU          *string
+	k8sMemory       *string
+	k8sOutput       *string
+
+	// k8sSecretFlags are the flags whose values are moved into a secret
+	// rather than written into the arguments of the container.
+	k8sSecretFlags = []string{"mnemonic", "mnemonic-password", "private-key", "relay-key", "rpc-token"}
+
+	// k8sFileFlags are the flags that refer to local files, which aren't
+	// mounted into the pod.
+	k8sFileFlags = []string{"config", "keystore", "keystore-password-file", "rpc-jwt-secret", "rpc-tls-ca", "rpc-tls-cert", "rpc-tls-key"}
+)
+
+// LoadtestGenerateK8sCmd renders the manifests that run a load test in a
+// cluster with the flags it's given.
+var LoadtestGenerateK8sCmd = &cobra.Command{
+	Use:   "generate-k8s url",
+	Short: "Generate the Kubernetes manifests or Helm values that run a load test in a cluster.",
+	Long: `Generate the Kubernetes manifests or Helm values that run a load test in a cluster.
+
+The load test is configured with the same flags and endpoint as the loadtest
+command, and the ones that are set are passed to the load test in the pod, e.g.
+
+  polycli loadtest generate-k8s --image polycli:latest --mode t --rate-limit 500 --time-limit 600 http://rpc:8545 | kubectl apply -f -
+
+The entrypoint of the image needs to be polycli. The load test runs as a Job
+by default, or as a Deployment with --kind deployment for load that runs until
+it's stopped, like the agents of a distributed load test. With --format helm,
+the same settings are written as Helm values instead.
+
+The values of the private key, the mnemonic and its password, the relay key,
+and the RPC token are moved into a Secret and passed to the load test through
+environment variables, so they aren't in the arguments of the pod. The Secret
+is written along with the manifests unless --create-secret=false, in which
+case it needs to exist with the same keys and only the flags that are set are
+read from it.
+
+A polycli monitor runs next to the load test as a sidecar that serves the
+metrics of the chain on --metrics-port, and the pods are annotated for
+Prometheus to scrape it. The sidecar is a native sidecar, which needs
+Kubernetes 1.29 or later so the Job completes when the load test does.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		switch *k8sKind {
+		case k8sKindJob, k8sKindDeployment:
+		default:
+			return fmt.Errorf("the kind %s is not supported, expected job or deployment", *k8sKind)
+		}
+		if *k8sFormat != k8sFormatManifest && *k8sFormat != k8sFormatHelm {
+			return fmt.Errorf("the format %s is not supported, expected manifest or helm", *k8sFormat)
+		}
+		if *k8sImage == "" {
+			return fmt.Errorf("the --image of polycli is required")
+		}
+		if *k8sReplicas < 1 {
+			return fmt.Errorf("the replicas need to be non-zero positive")
+		}
+		// The load test configuration is checked the same way as when it's run.
+		return LoadtestCmd.Args(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c := newK8sConfig(cmd, util.WithProfileRPCURL(args))
+		var docs []interface{}
+		if *k8sFormat == k8sFormatHelm {
+			docs = append(docs, renderHelmValues(c))
+		} else {
+			docs = renderK8sManifests(c)
+		}
+
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		for _, doc := range docs {
+			if err := enc.Encode(doc); err != nil {
+				return err
+			}
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		if len(c.secretData) > 0 && *k8sCreateSecret {
+			log.Warn().Msg("The output contains the secret values, so keep it out of version control")
+		}
+
+		if *k8sOutput == "" {
+			fmt.Print(buf.String())
+			return nil
+		}
+		if err := os.WriteFile(*k8sOutput, buf.Bytes(), 0600); err != nil {
+			return err
+		}
+		log.Info().Str("file", *k8sOutput).Str("kind", *k8sKind).Str("format", *k8sFormat).Msg("Wrote the load test manifests")
+		return nil
+	},
+}
+
+func init() {
+	flagSet := LoadtestGenerateK8sCmd.Flags()
+	k8sKind = flagSet.String("kind", k8sKindJob, "The workload that runs the load test (job | deployment)")
+	k8sFormat = flagSet.String("format", k8sFormatManifest, "What's generated, the manifests to apply or the values of a chart (manifest | helm)")
+	k8sName = flagSet.String("name", "polycli-loadtest", "The name of the workload and the prefix of its secret")
+	k8sNamespace = flagSet.String("namespace", "", "The namespace of the manifests. Defaults to the namespace they're applied in")
+	k8sImage = flagSet.String("image", "", "The polycli image the pods run")
+	k8sReplicas = flagSet.Int("replicas", 1, "The number of pods that run the load test at the same time")
+	k8sSecretName = flagSet.String("secret-name", "", "The name of the secret with the keys. Defaults to the name with a -keys suffix")
+	k8sCreateSecret = flagSet.Bool("create-secret", true, "Write the secret along with the manifests. Otherwise it needs to exist already")
+	k8sMonitor = flagSet.Bool("monitor", true, "Run a monitor sidecar that serves the metrics of the chain for Prometheus")
+	k8sMetricsPort = flagSet.Int("metrics-port", 9090, "The port the monitor sidecar serves the metrics on")
+	k8sCPU = flagSet.String("cpu", "", "The CPU request and limit of the load test container, e.g. 2")
+	k8sMemory = flagSet.String("memory", "", "The memory request and limit of the load test container, e.g. 1Gi")
+	k8sOutput = flagSet.StringP("output", "o", "", "The file to write to. The output is written to stdout if this is empty")
+
+	LoadtestCmd.AddCommand(LoadtestGenerateK8sCmd)
+}
+
+// newK8sConfig translates the flags that were set into the arguments of the
+// containers. The flags of the root command, like the RPC token, are passed
+// to the monitor as well.
+func newK8sConfig(cmd *cobra.Command, args []string) k8sConfig {
+	c := k8sConfig{loadtestArgs: []string{"loadtest"}, secretData: make(map[string]string)}
+	var rootArgs []string
+	cmd.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+		// The values of the profile were set on the flags already, and the
+		// pod doesn't have the config file.
+		if !util.Changed(cmd.InheritedFlags(), f.Name) || f.Name == "profile" {
+			return
+		}
+		if contains(k8sFileFlags, f.Name) {
+			log.Warn().Str("flag", f.Name).Msg("The flag refers to a local file that isn't mounted into the pod, so it's left out")
+			return
+		}
+		var flagArgs []string
+		if contains(k8sSecretFlags, f.Name) {
+			env := "POLYCLI_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+			c.secretData[f.Name] = f.Value.String()
+			c.env = append(c.env, k8sEnvVar{
+				Name:      env,
+				ValueFrom: k8sEnvSource{SecretKeyRef: k8sSecretKey{Name: secretName(), Key: f.Name}},
+			})
+			// Kubernetes expands the variable in the arguments.
+			flagArgs = []string{fmt.Sprintf("--%s=$(%s)", f.Name, env)}
+		} else {
+			flagArgs = k8sFlagArgs(f)
+		}
+		// --json would conflict with the --output=prometheus of the monitor.
+		if cmd.Root().PersistentFlags().Lookup(f.Name) != nil && f.Name != "json" {
+			rootArgs = append(rootArgs, flagArgs...)
+		}
+		c.loadtestArgs = append(c.loadtestArgs, flagArgs...)
+	})
+	c.loadtestArgs = append(c.loadtestArgs, args...)
+
+	// The controller has no endpoint to monitor.
+	if *k8sMonitor && len(args) > 0 {
+		c.monitorArgs = append([]string{"monitor", "--no-tui", "--output=prometheus", fmt.Sprintf("--prometheus-addr=:%d", *k8sMetricsPort)}, rootArgs...)
+		c.monitorArgs = append(c.monitorArgs, args[0])
+	}
+	return c
+}
+
+// k8sFlagArgs returns the arguments that set the flag to its value. The
+// values of array flags are repeated, the ones of slices are joined.
+func k8sFlagArgs(f *pflag.Flag) []string {
+	s, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return []string{fmt.Sprintf("--%s=%s", f.Name, f.Value.String())}
+	}
+	if f.Value.Type() != "stringArray" {
+		return []string{fmt.Sprintf("--%s=%s", f.Name, strings.Join(s.GetSlice(), ","))}
+	}
+	out := make([]string, 0, len(s.GetSlice()))
+	for _, v := range s.GetSlice() {
+		out = append(out, fmt.Sprintf("--%s=%s", f.Name, v))
+	}
+	return out
+}
+
+func renderK8sManifests(c k8sConfig) []interface{} {
+	var docs []interface{}
+	if len(c.secretData) > 0 && *k8sCreateSecret {
+		docs = append(docs, k8sObject{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata:   k8sMetadata{Name: secretName(), Namespace: *k8sNamespace, Labels: k8sLabels()},
+			Type:       "Opaque",
+			StringData: c.secretData,
+		})
+	}
+
+	template := k8sPodTemplate{
+		Metadata: k8sMetadata{Labels: k8sLabels(), Annotations: k8sAnnotations(c)},
+		Spec:     k8sPodSpec{Containers: []k8sContainer{loadtestContainer(c)}},
+	}
+	if c.monitorArgs != nil {
+		template.Spec.InitContainers = []k8sContainer{monitorContainer(c)}
+	}
+	metadata := k8sMetadata{Name: *k8sName, Namespace: *k8sNamespace, Labels: k8sLabels()}
+
+	if *k8sKind == k8sKindDeployment {
+		if !*inputLoadTestParams.Agent {
+			log.Warn().Msg("The pods of a deployment are restarted when the load test finishes, so it should run until it's stopped")
+		}
+		return append(docs, k8sObject{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Metadata:   metadata,
+			Spec: k8sDeploymentSpec{
+				Replicas: *k8sReplicas,
+				Selector: k8sSelector{MatchLabels: k8sLabels()},
+				Template: template,
+			},
+		})
+	}
+	// A failed load test isn't retried, since it would send the load again.
+	template.Spec.RestartPolicy = "Never"
+	return append(docs, k8sObject{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Metadata:   metadata,
+		Spec: k8sJobSpec{
+			BackoffLimit: 0,
+			Parallelism:  *k8sReplicas,
+			Completions:  *k8sReplicas,
+			Template:     template,
+		},
+	})
+}
+
+func renderHelmValues(c k8sConfig) helmValues {
+	v := helmValues{
+		Image:          *k8sImage,
+		Kind:           *k8sKind,
+		Replicas:       *k8sReplicas,
+		Args:           c.loadtestArgs,
+		Env:            c.env,
+		Secret:         helmSecret{Create: len(c.secretData) > 0 && *k8sCreateSecret, Name: secretName()},
+		PodLabels:      k8sLabels(),
+		PodAnnotations: k8sAnnotations(c),
+		Resources:      k8sResourceLimits(),
+		Monitor:        helmMonitor{Enabled: c.monitorArgs != nil},
+	}
+	if *k8sCreateSecret {
+		v.Secret.StringData = c.secretData
+	}
+	if c.monitorArgs != nil {
+		v.Monitor.Args = c.monitorArgs
+		v.Monitor.Port = *k8sMetricsPort
+	}
+	return v
+}
+
+func loadtestContainer(c k8sConfig) k8sContainer {
+	return k8sContainer{
+		Name:      "loadtest",
+		Image:     *k8sImage,
+		Args:      c.loadtestArgs,
+		Env:       c.env,
+		Resources: k8sResourceLimits(),
+	}
+}
+
+// monitorContainer is a native sidecar, an init container that keeps running
+// until the other containers exit.
+func monitorContainer(c k8sConfig) k8sContainer {
+	var env []k8sEnvVar
+	for _, e := range c.env {
+		if e.ValueFrom.SecretKeyRef.Key == "rpc-token" {
+			env = append(env, e)
+		}
+	}
+	return k8sContainer{
+		Name:          "monitor",
+		Image:         *k8sImage,
+		Args:          c.monitorArgs,
+		Env:           env,
+		Ports:         []k8sPort{{Name: "metrics", ContainerPort: *k8sMetricsPort}},
+		RestartPolicy: "Always",
+	}
+}
+
+func k8sLabels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":      *k8sName,
+		"app.kubernetes.io/component": "loadtest",
+	}
+}
+
+func k8sAnnotations(c k8sConfig) map[string]string {
+	if c.monitorArgs == nil {
+		return nil
+	}
+	return map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   fmt.Sprint(*k8sMetricsPort),
+		"prometheus.io/path":   "/metrics",
+	}
+}
+
+func k8sResourceLimits() *k8sResources {
+	if *k8sCPU == "" && *k8sMemory == "" {
+		return nil
+	}
+	r := make(map[string]string)
+	if *k8sCPU != "" {
+		r["cpu"] = *k8sCPU
+	}
+	if *k8sMemory != "" {
+		r["memory"] = *k8sMemory
+	}
+	return &k8sResources{Requests: r, Limits: r}
+}
+
+func secretName() string {
+	if *k8sSecretName != "" {
+		return *k8sSecretName
+	}
+	return *k8sName + "-keys"
+}
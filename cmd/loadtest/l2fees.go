@@ -0,0 +1,171 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/rs/zerolog/log"
+)
+
+// l2FeeModel is how the chain charges for a transaction on top of the gas
+// used times the gas price.
+type l2FeeModel string
+
+const (
+	l2FeeModelAuto l2FeeModel = "auto"
+	// l2FeeModelNone is the plain L1 fee model, gas used times the gas price.
+	l2FeeModelNone l2FeeModel = "none"
+	// l2FeeModelOP is the OP stack fee model, where an L1 data fee computed by
+	// the GasPriceOracle predeploy is charged on top of the execution fee.
+	l2FeeModelOP l2FeeModel = "op"
+	// l2FeeModelZkEVM is the Polygon zkEVM fee model, where the L1 data cost is
+	// folded into the effective gas price the sequencer charges, which can be
+	// lower than the gas price of the transaction.
+	l2FeeModelZkEVM l2FeeModel = "zkevm"
+)
+
+// opGasPriceOracle is the address of the OP stack GasPriceOracle predeploy.
+var opGasPriceOracle = ethcommon.HexToAddress("0x420000000000000000000000000000000000000F")
+
+const opGasPriceOracleABI = `[{"inputs":[{"internalType":"bytes","name":"_data","type":"bytes"}],"name":"getL1Fee","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+// resolveL2FeeModel validates the fee model, and detects it from the chain
+// when it's auto. OP stack chains are detected by the GasPriceOracle predeploy
+// and zkEVM chains by the zkevm RPC namespace.
+func resolveL2FeeModel(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, model string) (l2FeeModel, error) {
+	switch m := l2FeeModel(model); m {
+	case l2FeeModelNone, l2FeeModelOP, l2FeeModelZkEVM:
+		return m, nil
+	case l2FeeModelAuto:
+	default:
+		return "", fmt.Errorf("invalid L2 fee model %q, expected one of: auto, none, op, zkevm", model)
+	}
+
+	code, err := c.CodeAt(ctx, opGasPriceOracle, nil)
+	if err == nil && len(code) > 0 {
+		log.Debug().Msg("OP stack GasPriceOracle detected")
+		return l2FeeModelOP, nil
+	}
+
+	var batch string
+	if err = rpc.CallContext(ctx, &batch, "zkevm_batchNumber"); err == nil {
+		log.Debug().Str("batch", batch).Msg("zkEVM RPC detected")
+		return l2FeeModelZkEVM, nil
+	}
+
+	return l2FeeModelNone, nil
+}
+
+// estimateL1Fee returns the L1 data fee the chain will charge for the
+// transaction. It's zero unless the fee model is OP.
+func estimateL1Fee(ctx context.Context, c *ethclient.Client, model l2FeeModel, tx *types.Transaction) (*big.Int, error) {
+	if model != l2FeeModelOP {
+		return new(big.Int), nil
+	}
+
+	oracle, err := gethabi.JSON(strings.NewReader(opGasPriceOracleABI))
+	if err != nil {
+		return nil, err
+	}
+	rawTx, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	input, err := oracle.Pack("getL1Fee", rawTx)
+	if err != nil {
+		return nil, err
+	}
+	output, err := c.CallContract(ctx, ethereum.CallMsg{To: &opGasPriceOracle, Data: input}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to call the GasPriceOracle: %w", err)
+	}
+	values, err := oracle.Unpack("getL1Fee", output)
+	if err != nil {
+		return nil, err
+	}
+	return values[0].(*big.Int), nil
+}
+
+// logExpectedTransferCost logs the expected cost of a transfer with the
+// current gas prices, including the L1 data fee, so the cost of a test can be
+// estimated up front.
+func logExpectedTransferCost(ctx context.Context, c *ethclient.Client, model l2FeeModel, chainID, gasPrice *big.Int) {
+	to := *inputLoadTestParams.ToETHAddress
+	var tx *types.Transaction
+	if *inputLoadTestParams.LegacyTransactionMode {
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce:    *inputLoadTestParams.CurrentNonce,
+			GasPrice: gasPrice,
+			Gas:      21000,
+			To:       &to,
+			Value:    inputLoadTestParams.SendAmount,
+		})
+	} else {
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     *inputLoadTestParams.CurrentNonce,
+			GasTipCap: inputLoadTestParams.CurrentGasTipCap,
+			GasFeeCap: gasPrice,
+			Gas:       21000,
+			To:        &to,
+			Value:     inputLoadTestParams.SendAmount,
+		})
+	}
+
+	l1Fee, err := estimateL1Fee(ctx, c, model, tx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to estimate the L1 data fee")
+		return
+	}
+	executionFee := new(big.Int).Mul(big.NewInt(21000), gasPrice)
+
+	log.Info().
+		Str("feeModel", string(model)).
+		Str("executionFee", executionFee.String()).
+		Str("l1Fee", l1Fee.String()).
+		Str("total", new(big.Int).Add(executionFee, l1Fee).String()).
+		Msg("Expected cost per transfer in wei")
+	if model == l2FeeModelZkEVM {
+		log.Info().Msg("zkEVM charges an effective gas price that includes the L1 data cost, so the execution fee is an upper bound")
+	}
+}
+
+// transactionFees is the total of the fees paid by the transactions.
+type transactionFees struct {
+	// ExecutionFee is the gas used times the effective gas price.
+	ExecutionFee *big.Int
+	// L1Fee is the L1 data fee charged on top of the execution fee.
+	L1Fee     *big.Int
+	L1GasUsed uint64
+	Total     *big.Int
+}
+
+func getTotalFees(receipts map[ethcommon.Hash]rpctypes.RawTxReceipt) transactionFees {
+	fees := transactionFees{ExecutionFee: new(big.Int), L1Fee: new(big.Int), Total: new(big.Int)}
+	for _, receipt := range receipts {
+		gasUsed := receipt.GasUsed.ToBigInt()
+		fees.ExecutionFee.Add(fees.ExecutionFee, gasUsed.Mul(gasUsed, receipt.EffectiveGasPrice.ToBigInt()))
+		if receipt.L1Fee != "" {
+			fees.L1Fee.Add(fees.L1Fee, receipt.L1Fee.ToBigInt())
+			fees.L1GasUsed += receipt.L1GasUsed.ToUint64()
+		}
+	}
+	fees.Total.Add(fees.ExecutionFee, fees.L1Fee)
+	return fees
+}
+
+func (f *transactionFees) add(other transactionFees) {
+	f.ExecutionFee.Add(f.ExecutionFee, other.ExecutionFee)
+	f.L1Fee.Add(f.L1Fee, other.L1Fee)
+	f.L1GasUsed += other.L1GasUsed
+	f.Total.Add(f.Total, other.Total)
+}
@@ -2,6 +2,7 @@ package loadtest
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -17,21 +18,24 @@ import (
 
 	"github.com/maticnetwork/polygon-cli/contracts/tokens"
 	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/maticnetwork/polygon-cli/signer"
 
 	_ "embed"
 
-	"github.com/maticnetwork/polygon-cli/metrics"
-
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	ethrpc "github.com/ethereum/go-ethereum/rpc"
 
 	"github.com/maticnetwork/polygon-cli/contracts"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
 )
 
@@ -59,6 +63,17 @@ const (
 	loadTestModeRandom
 	loadTestModeRecall
 	loadTestModeRPC
+	loadTestModeCallDepth
+	loadTestModeLogs
+	loadTestModeColdAccess
+	loadTestModeCompute
+	loadTestModeInscription
+	loadTestModeDisperse
+	loadTestModeMultisig
+	loadTestModeSetCode
+	loadTestModeRPCRead
+	loadTestModeArchive
+	loadTestModeTrace
 
 	codeQualitySeed       = "code code code code code code code code code code code quality"
 	codeQualityPrivateKey = "42b6e34dc21598a807dc19d7784c71b2a7a01f6480dc6f58258f78e539f1a1fa"
@@ -92,14 +107,36 @@ func characterToLoadTestMode(mode string) (loadTestMode, error) {
 		return loadTestModeRecall, nil
 	case "rpc":
 		return loadTestModeRPC, nil
+	case "cd", "call-depth":
+		return loadTestModeCallDepth, nil
+	case "l", "logs":
+		return loadTestModeLogs, nil
+	case "C", "cold":
+		return loadTestModeColdAccess, nil
+	case "k", "compute":
+		return loadTestModeCompute, nil
+	case "I", "inscription":
+		return loadTestModeInscription, nil
+	case "D", "disperse":
+		return loadTestModeDisperse, nil
+	case "M", "multisig":
+		return loadTestModeMultisig, nil
+	case "sc", "set-code":
+		return loadTestModeSetCode, nil
+	case "rr", "rpc-read":
+		return loadTestModeRPCRead, nil
+	case "ar", "archive":
+		return loadTestModeArchive, nil
+	case "tr", "trace":
+		return loadTestModeTrace, nil
 	default:
 		return 0, fmt.Errorf("unrecognized load test mode: %s", mode)
 	}
 }
 
-func getRandomMode() loadTestMode {
+func getRandomMode(rng *rand.Rand) loadTestMode {
 	maxMode := int(loadTestModeRandom)
-	return loadTestMode(randSrc.Intn(maxMode))
+	return loadTestMode(rng.Intn(maxMode))
 }
 
 func modeRequiresLoadTestContract(m loadTestMode) bool {
@@ -110,6 +147,11 @@ func modeRequiresLoadTestContract(m loadTestMode) bool {
 		m == loadTestModeStore {
 		return true
 	}
+	// Without another delegate, the authorities delegate to the load test
+	// contract
+	if m == loadTestModeSetCode && *inputLoadTestParams.SetCodeDelegate == "" {
+		return true
+	}
 	return false
 }
 func anyModeRequiresLoadTestContract(modes []loadTestMode) bool {
@@ -129,7 +171,7 @@ func hasMode(mode loadTestMode, modes []loadTestMode) bool {
 	return false
 }
 
-func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
+func initializeLoadTestParams(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) error {
 	log.Info().Msg("Connecting with RPC endpoint to initialize load test parameters")
 	gas, err := c.SuggestGasPrice(ctx)
 	if err != nil {
@@ -148,9 +190,20 @@ func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
 		inputLoadTestParams.CurrentGasTipCap = gasTipCap
 	}
 
-	privateKey, err := ethcrypto.HexToECDSA(*inputLoadTestParams.PrivateKey)
+	s, err := signer.New(ctx, *inputLoadTestParams.SignerConfig, *inputLoadTestParams.PrivateKey)
 	if err != nil {
-		log.Error().Err(err).Msg("Couldn't process the hex private key")
+		log.Error().Err(err).Msg("Unable to create the transaction signer")
+		return err
+	}
+	if signer.RequiresLegacy(s) && !*inputLoadTestParams.LegacyTransactionMode {
+		return errors.New("the signer only supports legacy transactions, so --legacy is required")
+	}
+	// The precompiled contract modes sign an ecrecover input locally, so a
+	// throwaway key is used when the transactions are signed elsewhere.
+	var privateKey *ecdsa.PrivateKey
+	if local, ok := s.(*signer.LocalSigner); ok {
+		privateKey = local.Key
+	} else if privateKey, err = ethcrypto.GenerateKey(); err != nil {
 		return err
 	}
 
@@ -162,7 +215,7 @@ func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
 	}
 	log.Trace().Uint64("blocknumber", blockNumber).Msg("Current Block Number")
 
-	ethAddress := ethcrypto.PubkeyToAddress(privateKey.PublicKey)
+	ethAddress := s.Address()
 
 	nonce, err := c.NonceAt(ctx, ethAddress, bigBlockNumber)
 	if err != nil {
@@ -217,12 +270,19 @@ func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
 	inputLoadTestParams.CurrentGasPrice = gas
 	inputLoadTestParams.CurrentNonce = &nonce
 	inputLoadTestParams.ECDSAPrivateKey = privateKey
+	inputLoadTestParams.Signer = s
 	inputLoadTestParams.FromETHAddress = &ethAddress
 	if *inputLoadTestParams.ChainID == 0 {
 		*inputLoadTestParams.ChainID = chainID.Uint64()
 	}
 	inputLoadTestParams.CurrentBaseFee = header.BaseFee
 
+	inputLoadTestParams.FeeModel, err = resolveL2FeeModel(ctx, c, rpc, *inputLoadTestParams.L2FeeModel)
+	if err != nil {
+		return err
+	}
+	logExpectedTransferCost(ctx, c, inputLoadTestParams.FeeModel, chainID, gas)
+
 	modes := *inputLoadTestParams.Modes
 	if len(modes) == 0 {
 		return fmt.Errorf("expected at least one mode")
@@ -253,13 +313,79 @@ func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
 		log.Trace().Msg("setting call only mode since we're doing RPC testing")
 		*inputLoadTestParams.CallOnly = true
 	}
+	if hasMode(loadTestModeRPCRead, inputLoadTestParams.ParsedModes) {
+		if inputLoadTestParams.MultiMode && !*inputLoadTestParams.CallOnly {
+			return fmt.Errorf("rpc read mode must be called with call-only when multiple modes are used")
+		}
+		*inputLoadTestParams.CallOnly = true
+		if err = setupRPCRead(); err != nil {
+			return err
+		}
+	}
+	if hasMode(loadTestModeArchive, inputLoadTestParams.ParsedModes) {
+		if inputLoadTestParams.MultiMode && !*inputLoadTestParams.CallOnly {
+			return fmt.Errorf("archive mode must be called with call-only when multiple modes are used")
+		}
+		*inputLoadTestParams.CallOnly = true
+		if err = setupArchive(); err != nil {
+			return err
+		}
+	}
+	if hasMode(loadTestModeTrace, inputLoadTestParams.ParsedModes) {
+		if inputLoadTestParams.MultiMode && !*inputLoadTestParams.CallOnly {
+			return fmt.Errorf("trace mode must be called with call-only when multiple modes are used")
+		}
+		*inputLoadTestParams.CallOnly = true
+		if err = setupTrace(); err != nil {
+			return err
+		}
+	}
+	if err = setupReadTransports(ctx); err != nil {
+		return err
+	}
+	if err = setupReadValidation(ctx); err != nil {
+		return err
+	}
+	if err = setupSendErrorPolicies(); err != nil {
+		return err
+	}
+	// The authorizations follow the transaction nonces, which other modes
+	// would take some of
+	if hasMode(loadTestModeSetCode, inputLoadTestParams.ParsedModes) {
+		if inputLoadTestParams.MultiMode {
+			return fmt.Errorf("set code mode can't be used in combination with any other modes")
+		}
+		if *inputLoadTestParams.LegacyTransactionMode || *inputLoadTestParams.CallOnly {
+			return fmt.Errorf("set code mode can't be used with legacy transactions or call only")
+		}
+		if *inputLoadTestParams.SendVia != sendViaPublic {
+			return fmt.Errorf("set code transactions can't be sent through a relay")
+		}
+		if _, ok := s.(*signer.LocalSigner); !ok {
+			return fmt.Errorf("set code transactions need to be signed with a private key")
+		}
+	}
 	// TODO check for duplicate modes?
 
 	if *inputLoadTestParams.CallOnly && *inputLoadTestParams.AdaptiveRateLimit {
 		return fmt.Errorf("using call only with adaptive rate limit doesn't make sense")
 	}
 
-	randSrc = rand.New(rand.NewSource(*inputLoadTestParams.Seed))
+	relay = nil
+	if *inputLoadTestParams.SendVia != sendViaPublic {
+		if *inputLoadTestParams.CallOnly {
+			return fmt.Errorf("sending via a relay doesn't make sense with call only")
+		}
+		relayURL := *inputLoadTestParams.RelayURL
+		if relayURL == "" {
+			relayURL = inputLoadTestParams.URL.String()
+		}
+		relay, err = newPrivateRelay(c, *inputLoadTestParams.SendVia, relayURL, *inputLoadTestParams.RelayKey, *inputLoadTestParams.BundleSize, *inputLoadTestParams.BundleBlockOffset, *inputLoadTestParams.BundleBlockCount)
+		if err != nil {
+			return err
+		}
+		log.Info().Str("sendVia", *inputLoadTestParams.SendVia).Str("relay", relayURL).Msg("Sending the load test transactions through a relay")
+	}
 
 	return nil
 }
@@ -297,7 +423,8 @@ func runLoadTest(ctx context.Context) error {
 		overallTimer = new(time.Timer)
 	}
 
-	rpc, err := ethrpc.DialContext(ctx, inputLoadTestParams.URL.String())
+	loadTestHTTPClient = newLoadTestHTTPClient()
+	rpc, err := dialRPC(ctx, inputLoadTestParams.URL.String(), loadTestHTTPClient)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to dial rpc")
 		return err
@@ -306,7 +433,7 @@ func runLoadTest(ctx context.Context) error {
 	ec := ethclient.NewClient(rpc)
 
 	loopFunc := func() error {
-		err = initializeLoadTestParams(ctx, ec)
+		err = initializeLoadTestParams(ctx, ec, rpc)
 		if err != nil {
 			return err
 		}
@@ -329,6 +456,10 @@ func runLoadTest(ctx context.Context) error {
 	case <-sigCh:
 		log.Info().Msg("Interrupted.. Stopping load test")
 	case err = <-errCh:
+		if errors.Is(err, errLoadTestAborted) {
+			printResults(loadTestResults)
+			return err
+		}
 		if err != nil {
 			log.Fatal().Err(err).Msg("Received critical error while running load test")
 		}
@@ -418,7 +549,6 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	routines := *ltp.Concurrency
 	requests := *ltp.Requests
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 	mode := ltp.Mode
 	steadyStateTxPoolSize := *ltp.SteadyStateTxPoolSize
 	adaptiveRateLimitIncrement := *ltp.AdaptiveRateLimitIncrement
@@ -432,8 +562,11 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	if *ltp.AdaptiveRateLimit && rl != nil {
 		go updateRateLimit(rateLimitCtx, rl, rpc, steadyStateTxPoolSize, adaptiveRateLimitIncrement, time.Duration(*ltp.AdaptiveCycleDuration)*time.Second, *ltp.AdaptiveBackoffFactor)
 	}
+	if agentSchedule != nil && rl != nil {
+		go runPhases(rateLimitCtx, rl, agentSchedule)
+	}
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	tops = configureTransactOpts(tops)
 	// configureTransactOpts will set some paramters meant for load testing that could interfere with the deployment of our contracts
 	tops.GasLimit = 0
@@ -447,35 +580,22 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	}
 	cops := new(bind.CallOpts)
 
-	// deploy and instantiate the load tester contract
-	var ltAddr ethcommon.Address
-	var ltContract *contracts.LoadTester
-	if anyModeRequiresLoadTestContract(ltp.ParsedModes) || *inputLoadTestParams.ForceContractDeploy {
-		ltAddr, ltContract, err = getLoadTestContract(ctx, c, tops, cops)
-		if err != nil {
-			return err
-		}
-		log.Debug().Str("ltAddr", ltAddr.String()).Msg("Obtained load test contract address")
+	lc, err := deployContracts(ctx, c, tops, cops)
+	if err != nil {
+		return err
 	}
-
-	var erc20Addr ethcommon.Address
-	var erc20Contract *tokens.ERC20
-	if mode == loadTestModeERC20 || mode == loadTestModeRandom {
-		erc20Addr, erc20Contract, err = getERC20Contract(ctx, c, tops, cops)
-		if err != nil {
+	if err = lc.rebind(contractBackend(c)); err != nil {
+		return err
+	}
+	if *ltp.Recipients == recipientsPool {
+		if err = setupRecipientPool(ctx, c, rpc); err != nil {
 			return err
 		}
-		log.Debug().Str("erc20Addr", erc20Addr.String()).Msg("Obtained erc 20 contract address")
 	}
-
-	var erc721Addr ethcommon.Address
-	var erc721Contract *tokens.ERC721
-	if mode == loadTestModeERC721 || mode == loadTestModeRandom {
-		erc721Addr, erc721Contract, err = getERC721Contract(ctx, c, tops, cops)
-		if err != nil {
+	if mode == loadTestModeSetCode {
+		if err = setupSetCode(ctx, c); err != nil {
 			return err
 		}
-		log.Debug().Str("erc721Addr", erc721Addr.String()).Msg("Obtained erc 721 contract address")
 	}
 
 	var recallTransactions []rpctypes.PolyTransaction
@@ -491,7 +611,7 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	}
 
 	var indexedActivity *IndexedActivity
-	if mode == loadTestModeRPC || mode == loadTestModeRandom {
+	if mode == loadTestModeRPC || mode == loadTestModeRandom || hasMode(loadTestModeRPCRead, ltp.ParsedModes) || hasMode(loadTestModeArchive, ltp.ParsedModes) || hasMode(loadTestModeTrace, ltp.ParsedModes) {
 		indexedActivity, err = getIndexedRecentActivity(ctx, c, rpc)
 		if err != nil {
 			return err
@@ -504,6 +624,9 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 			Int("erc721", len(indexedActivity.ERC721Addresses)).
 			Int("contracts", len(indexedActivity.Contracts)).
 			Msg("retrieved recent indexed activity")
+		if hasMode(loadTestModeTrace, ltp.ParsedModes) && len(indexedActivity.TransactionIDs) == 0 {
+			return fmt.Errorf("there are no recent transactions to trace")
+		}
 	}
 
 	var currentNonceMutex sync.Mutex
@@ -521,6 +644,29 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	}
 
 	startNonce := currentNonce
+	requestStartNonce = startNonce
+	if relay != nil {
+		relay.start(currentNonce)
+	}
+
+	// The workers stop taking new requests once runCtx is canceled, while
+	// the requests in flight still finish with ctx.
+	runCtx, abort := context.WithCancel(ctx)
+	defer abort()
+	monitor, err := newAbortMonitor()
+	if err != nil {
+		return err
+	}
+	if monitor != nil {
+		go monitor.watch(rateLimitCtx, c, abort, func() uint64 {
+			currentNonceMutex.Lock()
+			defer currentNonceMutex.Unlock()
+			return currentNonce
+		})
+	} else {
+		// The error policies can abort without any of the rules
+		monitor = new(abortMonitor)
+	}
 	log.Debug().Uint64("currentNonce", currentNonce).Msg("Starting main load test loop")
 	var wg sync.WaitGroup
 	for i = 0; i < routines; i = i + 1 {
@@ -535,9 +681,25 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 			var tErr error
 
 			for j = 0; j < requests; j = j + 1 {
+				if runCtx.Err() != nil {
+					break
+				}
+				// Every request is a trace of its own, linked to the trace of
+				// the load test, so the traces stay small enough to be read
+				// and can be sampled.
+				reqCtx, reqSpan := util.Tracer().Start(ctx, "loadtest.request",
+					trace.WithNewRoot(),
+					trace.WithLinks(trace.LinkFromContext(ctx)),
+					trace.WithAttributes(attribute.Int64("loadtest.routine", i), attribute.Int64("loadtest.request", j)))
 				if rl != nil {
-					tErr = rl.Wait(ctx)
+					_, waitSpan := util.Tracer().Start(reqCtx, "loadtest.wait")
+					tErr = rl.Wait(runCtx)
+					waitSpan.End()
 					if tErr != nil {
+						if runCtx.Err() != nil {
+							reqSpan.End()
+							break
+						}
 						log.Error().Err(tErr).Msg("Encountered a rate limiting error")
 					}
 				}
@@ -552,55 +714,79 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 				}
 
 				localMode := mode
-				// if there are multiple modes, iterate through them, 'r' mode is supported here
+				// if there are multiple modes, iterate through them by nonce so a retry uses the same mode, 'r' mode is supported here
 				if ltp.MultiMode {
-					localMode = ltp.ParsedModes[int(i+j)%(len(ltp.ParsedModes))]
+					localMode = ltp.ParsedModes[int((myNonceValue-startNonce)%uint64(len(ltp.ParsedModes)))]
 				}
-				// if we're doing random, we'll just pick one based on the current index
+				// if we're doing random, we'll just pick one based on the current nonce
 				if localMode == loadTestModeRandom {
-					localMode = getRandomMode()
+					localMode = getRandomMode(newRequestRand(myNonceValue, randStreamMode))
 				}
+				reqSpan.SetAttributes(attribute.String("loadtest.mode", localMode.String()), attribute.Int64("loadtest.nonce", int64(myNonceValue)))
 				switch localMode {
 				case loadTestModeTransaction:
-					startReq, endReq, tErr = loadTestTransaction(ctx, c, myNonceValue)
+					startReq, endReq, tErr = loadTestTransaction(reqCtx, c, myNonceValue)
 				case loadTestModeDeploy:
-					startReq, endReq, tErr = loadTestDeploy(ctx, c, myNonceValue)
+					startReq, endReq, tErr = loadTestDeploy(reqCtx, c, myNonceValue)
 				case loadTestModeFunction, loadTestModeCall:
-					startReq, endReq, tErr = loadTestFunction(ctx, c, myNonceValue, ltContract)
+					startReq, endReq, tErr = loadTestFunction(reqCtx, c, myNonceValue, lc.lt)
 				case loadTestModeInc:
-					startReq, endReq, tErr = loadTestInc(ctx, c, myNonceValue, ltContract)
+					startReq, endReq, tErr = loadTestInc(reqCtx, c, myNonceValue, lc.lt)
 				case loadTestModeStore:
-					startReq, endReq, tErr = loadTestStore(ctx, c, myNonceValue, ltContract)
+					startReq, endReq, tErr = loadTestStore(reqCtx, c, myNonceValue, lc.lt)
 				case loadTestModeERC20:
-					startReq, endReq, tErr = loadTestERC20(ctx, c, myNonceValue, erc20Contract, ltAddr)
+					startReq, endReq, tErr = loadTestERC20(reqCtx, c, myNonceValue, lc.erc20, lc.ltAddr)
 				case loadTestModeERC721:
-					startReq, endReq, tErr = loadTestERC721(ctx, c, myNonceValue, erc721Contract, ltAddr)
+					startReq, endReq, tErr = loadTestERC721(reqCtx, c, myNonceValue, lc.erc721, lc.ltAddr)
 				case loadTestModePrecompiledContract:
-					startReq, endReq, tErr = loadTestCallPrecompiledContracts(ctx, c, myNonceValue, ltContract, true)
+					startReq, endReq, tErr = loadTestCallPrecompiledContracts(reqCtx, c, myNonceValue, lc.lt, true)
 				case loadTestModePrecompiledContracts:
-					startReq, endReq, tErr = loadTestCallPrecompiledContracts(ctx, c, myNonceValue, ltContract, false)
+					startReq, endReq, tErr = loadTestCallPrecompiledContracts(reqCtx, c, myNonceValue, lc.lt, false)
 				case loadTestModeRecall:
-					startReq, endReq, tErr = loadTestRecall(ctx, c, myNonceValue, recallTransactions[int(currentNonce)%len(recallTransactions)])
+					startReq, endReq, tErr = loadTestRecall(reqCtx, c, myNonceValue, recallTransactions[int(currentNonce)%len(recallTransactions)])
 				case loadTestModeRPC:
-					startReq, endReq, tErr = loadTestRPC(ctx, c, myNonceValue, indexedActivity)
+					startReq, endReq, tErr = loadTestRPC(reqCtx, c, myNonceValue, indexedActivity)
+				case loadTestModeCallDepth:
+					startReq, endReq, tErr = loadTestCallDepth(reqCtx, c, myNonceValue, lc.caller)
+				case loadTestModeLogs:
+					startReq, endReq, tErr = loadTestLogs(reqCtx, c, myNonceValue, lc.logEmitter)
+				case loadTestModeColdAccess:
+					startReq, endReq, tErr = loadTestColdAccess(reqCtx, c, myNonceValue, lc.coldAccessAddr)
+				case loadTestModeCompute:
+					startReq, endReq, tErr = loadTestCompute(reqCtx, c, myNonceValue, lc.compute)
+				case loadTestModeInscription:
+					startReq, endReq, tErr = loadTestInscription(reqCtx, c, myNonceValue)
+				case loadTestModeDisperse:
+					startReq, endReq, tErr = loadTestDisperse(reqCtx, c, myNonceValue, lc.disperse, lc.erc20Addr)
+				case loadTestModeMultisig:
+					startReq, endReq, tErr = loadTestMultisig(reqCtx, c, myNonceValue, lc.multisig, lc.multisigAddr, lc.disperseAddr)
+				case loadTestModeSetCode:
+					startReq, endReq, tErr = loadTestSetCode(reqCtx, c, rpc, myNonceValue, lc.ltAddr)
+				case loadTestModeRPCRead:
+					startReq, endReq, tErr = loadTestRead(reqCtx, rpc, myNonceValue, indexedActivity, getRPCReadCall, rpcReadStats)
+				case loadTestModeArchive:
+					startReq, endReq, tErr = loadTestRead(reqCtx, rpc, myNonceValue, indexedActivity, getArchiveCall, archiveStats)
+				case loadTestModeTrace:
+					startReq, endReq, tErr = loadTestRead(reqCtx, rpc, myNonceValue, indexedActivity, getTraceCall, traceStats)
 				default:
 					log.Error().Str("mode", mode.String()).Msg("We've arrived at a load test mode that we don't recognize")
 				}
-				recordSample(i, j, tErr, startReq, endReq, myNonceValue)
+				errorClass := ""
 				if tErr != nil {
-					log.Error().Err(tErr).Uint64("nonce", myNonceValue).Msg("Recorded an error while sending transactions")
-					// The nonce is used to index the recalled transactions in call-only mode. We don't want to retry a transaction if it legit failed on the chain
-					if !*ltp.CallOnly {
+					errorClass = classifySendError(tErr)
+				}
+				recordSample(i, j, tErr, errorClass, startReq, endReq, myNonceValue)
+				if errorClass != "" {
+					reqSpan.SetAttributes(attribute.String("loadtest.error_class", errorClass))
+				}
+				util.EndSpan(reqSpan, tErr)
+				if tErr != nil {
+					log.Error().Err(tErr).Uint64("nonce", myNonceValue).Str("class", errorClass).Msg("Recorded an error while sending transactions")
+					switch getSendErrorPolicy(errorClass) {
+					case sendErrorRetry:
 						retryForNonce = true
-					}
-					if strings.Contains(tErr.Error(), "replacement transaction underpriced") && retryForNonce {
-						retryForNonce = false
-					}
-					if strings.Contains(tErr.Error(), "transaction underpriced") && retryForNonce {
-						retryForNonce = false
-					}
-					if strings.Contains(tErr.Error(), "nonce too low") && retryForNonce {
-						retryForNonce = false
+					case sendErrorAbort:
+						monitor.abort(fmt.Sprintf("a request failed with a %s error: %s", errorClass, tErr), abort)
 					}
 				}
 
@@ -611,20 +797,38 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	}
 	log.Trace().Msg("Finished starting go routines. Waiting..")
 	wg.Wait()
+	if relay != nil {
+		if err = relay.flush(ctx); err != nil {
+			log.Error().Err(err).Msg("Unable to send the last bundle")
+		}
+	}
 	cancel()
 	log.Debug().Uint64("currentNonce", currentNonce).Msg("Finished main load test loop")
+	if reason := monitor.getReason(); reason != "" {
+		printAbortSummary(ctx, c, reason, startNonce, currentNonce)
+		if *ltp.SweepAddress != "" && !*ltp.CallOnly {
+			if err = sweepFunds(ctx, c, ethcommon.HexToAddress(*ltp.SweepAddress)); err != nil {
+				log.Error().Err(err).Msg("Unable to sweep the remaining funds")
+			}
+		}
+		return fmt.Errorf("%w: %s", errLoadTestAborted, reason)
+	}
 	log.Debug().Msg("Waiting for transactions to actually be mined")
 	if *ltp.CallOnly {
 		return nil
 	}
-	finalBlockNumber, err := waitForFinalBlock(ctx, c, rpc, startBlockNumber, startNonce, currentNonce)
+	confirmCtx, confirmSpan := util.Tracer().Start(ctx, "loadtest.confirm")
+	finalBlockNumber, err := waitForFinalBlock(confirmCtx, c, rpc, startBlockNumber, startNonce, currentNonce)
+	util.EndSpan(confirmSpan, err)
 	if err != nil {
 		log.Error().Err(err).Msg("there was an issue waiting for all transactions to be mined")
 	}
 
-	lightSummary(ctx, c, rpc, startBlockNumber, startNonce, finalBlockNumber, currentNonce, rl)
+	summaryCtx, summarySpan := util.Tracer().Start(ctx, "loadtest.summary")
+	defer summarySpan.End()
+	lightSummary(summaryCtx, c, rpc, startBlockNumber, startNonce, finalBlockNumber, currentNonce, rl)
 	if *ltp.ShouldProduceSummary {
-		err = summarizeTransactions(ctx, c, rpc, startBlockNumber, startNonce, finalBlockNumber, currentNonce)
+		err = summarizeTransactions(summaryCtx, c, rpc, startBlockNumber, startNonce, finalBlockNumber, currentNonce)
 		if err != nil {
 			log.Error().Err(err).Msg("There was an issue creating the load test summary")
 		}
@@ -632,119 +836,6 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	return nil
 }
 
-func getLoadTestContract(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, cops *bind.CallOpts) (ltAddr ethcommon.Address, ltContract *contracts.LoadTester, err error) {
-	ltAddr = ethcommon.HexToAddress(*inputLoadTestParams.LtAddress)
-
-	if *inputLoadTestParams.LtAddress == "" {
-		ltAddr, _, _, err = contracts.DeployLoadTester(tops, c)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to create the load testing contract. Do you have the right chain id? Do you have enough funds?")
-			return
-		}
-	}
-	log.Trace().Interface("contractaddress", ltAddr).Msg("Load test contract address")
-
-	ltContract, err = contracts.NewLoadTester(ltAddr, c)
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to instantiate new contract")
-		return
-	}
-	err = blockUntilSuccessful(ctx, c, func() error {
-		_, err = ltContract.GetCallCounter(cops)
-		return err
-	})
-
-	return
-}
-func getERC20Contract(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, cops *bind.CallOpts) (erc20Addr ethcommon.Address, erc20Contract *tokens.ERC20, err error) {
-	erc20Addr = ethcommon.HexToAddress(*inputLoadTestParams.ERC20Address)
-	shouldMint := false
-	if *inputLoadTestParams.ERC20Address == "" {
-		erc20Addr, _, _, err = tokens.DeployERC20(tops, c, "ERC20TestToken", "T20")
-		if err != nil {
-			log.Error().Err(err).Msg("Unable to deploy ERC20 contract")
-			return
-		}
-		// if we're deploying a new ERC 20 we should mint tokens
-		shouldMint = true
-	}
-	log.Trace().Interface("contractaddress", erc20Addr).Msg("ERC20 contract address")
-
-	erc20Contract, err = tokens.NewERC20(erc20Addr, c)
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to instantiate new erc20 contract")
-		return
-	}
-
-	err = blockUntilSuccessful(ctx, c, func() error {
-		_, err = erc20Contract.BalanceOf(cops, *inputLoadTestParams.FromETHAddress)
-		return err
-	})
-	if err != nil {
-		return
-	}
-
-	if !shouldMint {
-		return
-	}
-	_, err = erc20Contract.Mint(tops, metrics.UnitMegaether)
-	if err != nil {
-		log.Error().Err(err).Msg("There was an error minting ERC20")
-		return
-	}
-
-	err = blockUntilSuccessful(ctx, c, func() error {
-		var balance *big.Int
-		balance, err = erc20Contract.BalanceOf(cops, *inputLoadTestParams.FromETHAddress)
-		if err != nil {
-			return err
-		}
-		if balance.Uint64() == 0 {
-			err = fmt.Errorf("ERC20 Balance is Zero")
-			return err
-		}
-		return nil
-	})
-
-	return
-}
-func getERC721Contract(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, cops *bind.CallOpts) (erc721Addr ethcommon.Address, erc721Contract *tokens.ERC721, err error) {
-	erc721Addr = ethcommon.HexToAddress(*inputLoadTestParams.ERC721Address)
-	shouldMint := true
-	if *inputLoadTestParams.ERC721Address == "" {
-		erc721Addr, _, _, err = tokens.DeployERC721(tops, c)
-		if err != nil {
-			log.Error().Err(err).Msg("Unable to deploy ERC721 contract")
-			return
-		}
-		shouldMint = false
-	}
-	log.Trace().Interface("contractaddress", erc721Addr).Msg("ERC721 contract address")
-
-	erc721Contract, err = tokens.NewERC721(erc721Addr, c)
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to instantiate new erc721 contract")
-		return
-	}
-
-	err = blockUntilSuccessful(ctx, c, func() error {
-		_, err = erc721Contract.BalanceOf(cops, *inputLoadTestParams.FromETHAddress)
-		return err
-	})
-	if err != nil {
-		return
-	}
-	if !shouldMint {
-		return
-	}
-
-	err = blockUntilSuccessful(ctx, c, func() error {
-		_, err = erc721Contract.MintBatch(tops, *inputLoadTestParams.FromETHAddress, new(big.Int).SetUint64(1))
-		return err
-	})
-	return
-}
-
 func blockUntilSuccessful(ctx context.Context, c *ethclient.Client, f func() error) error {
 	numberOfBlocksToWaitFor := *inputLoadTestParams.ContractCallNumberOfBlocksToWaitFor
 	blockInterval := *inputLoadTestParams.ContractCallBlockInterval
@@ -804,16 +895,12 @@ func blockUntilSuccessful(ctx context.Context, c *ethclient.Client, f func() err
 func loadTestTransaction(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 time.Time, t2 time.Time, err error) {
 	ltp := inputLoadTestParams
 
-	to := ltp.ToETHAddress
-	if *ltp.ToRandom {
-		to = getRandomAddress()
-	}
+	to := getRecipient(newRequestRand(nonce, randStreamRequest), recipientIndex(nonce, 0, 1))
 
 	amount := ltp.SendAmount
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -857,7 +944,90 @@ func loadTestTransaction(ctx context.Context, c *ethclient.Client, nonce uint64)
 	if *ltp.CallOnly {
 		_, err = c.CallContract(ctx, txToCallMsg(stx), nil)
 	} else {
-		err = c.SendTransaction(ctx, stx)
+		err = sendTransaction(ctx, c, stx)
+	}
+	return
+}
+
+// getInscriptionData returns the payload of an inscription transaction. By
+// default it's the inscription text, and when a size is given it's that many
+// bytes. Random payloads are different for every transaction.
+func getInscriptionData(rng *rand.Rand) []byte {
+	ltp := inputLoadTestParams
+	if *ltp.InscriptionSize == 0 && !*ltp.InscriptionRandom {
+		return []byte(*ltp.InscriptionData)
+	}
+
+	size := *ltp.InscriptionSize
+	if size == 0 {
+		size = uint64(len(*ltp.InscriptionData))
+	}
+	data := make([]byte, size)
+	if *ltp.InscriptionRandom {
+		_, _ = rng.Read(data)
+	} else {
+		_, _ = hexwordRead(data)
+	}
+	return data
+}
+
+// loadTestInscription sends a transaction with no value to ourselves with the
+// payload as its data, which is the traffic pattern of inscriptions.
+func loadTestInscription(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 time.Time, t2 time.Time, err error) {
+	ltp := inputLoadTestParams
+
+	to := ltp.FromETHAddress
+	data := getInscriptionData(newRequestRand(nonce, randStreamRequest))
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops.GasLimit, err = core.IntrinsicGas(data, nil, false, true, true)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to compute the intrinsic gas")
+		return
+	}
+	tops = configureTransactOpts(tops)
+	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
+
+	var tx *ethtypes.Transaction
+	if *ltp.LegacyTransactionMode {
+		tx = ethtypes.NewTx(&ethtypes.LegacyTx{
+			Nonce:    nonce,
+			To:       to,
+			Value:    big.NewInt(0),
+			Gas:      tops.GasLimit,
+			GasPrice: gasPrice,
+			Data:     data,
+		})
+	} else {
+		tx = ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			To:        to,
+			Gas:       tops.GasLimit,
+			GasFeeCap: gasPrice,
+			GasTipCap: gasTipCap,
+			Data:      data,
+			Value:     big.NewInt(0),
+		})
+	}
+
+	stx, err := tops.Signer(*ltp.FromETHAddress, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign transaction")
+		return
+	}
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		_, err = c.CallContract(ctx, txToCallMsg(stx), nil)
+	} else {
+		err = sendTransaction(ctx, c, stx)
 	}
 	return
 }
@@ -926,9 +1096,8 @@ func loadTestDeploy(ctx context.Context, c *ethclient.Client, nonce uint64) (t1
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -952,21 +1121,20 @@ func loadTestDeploy(ctx context.Context, c *ethclient.Client, nonce uint64) (t1
 // around deciding which function to execute. When we're in function
 // mode where the user has provided a specific function to execute, we
 // should use that function. Otherwise, we'll select random functions.
-func getCurrentLoadTestFunction() uint64 {
+func getCurrentLoadTestFunction(rng *rand.Rand) uint64 {
 	if loadTestModeFunction == inputLoadTestParams.Mode {
 		return *inputLoadTestParams.Function
 	}
-	return contracts.GetRandomOPCode()
+	return contracts.GetRandomOPCode(rng)
 }
 func loadTestFunction(ctx context.Context, c *ethclient.Client, nonce uint64, ltContract *contracts.LoadTester) (t1 time.Time, t2 time.Time, err error) {
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 	iterations := ltp.Iterations
-	f := getCurrentLoadTestFunction()
+	f := getCurrentLoadTestFunction(newRequestRand(nonce, randStreamRequest))
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -1001,10 +1169,10 @@ func loadTestCallPrecompiledContracts(ctx context.Context, c *ethclient.Client,
 	if useSelectedAddress {
 		f = int(*ltp.Function)
 	} else {
-		f = contracts.GetRandomPrecompiledContractAddress()
+		f = contracts.GetRandomPrecompiledContractAddress(newRequestRand(nonce, randStreamRequest))
 	}
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -1033,9 +1201,8 @@ func loadTestInc(ctx context.Context, c *ethclient.Client, nonce uint64, ltContr
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -1064,9 +1231,8 @@ func loadTestStore(ctx context.Context, c *ethclient.Client, nonce uint64, ltCon
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -1093,19 +1259,249 @@ func loadTestStore(ctx context.Context, c *ethclient.Client, nonce uint64, ltCon
 	return
 }
 
-func loadTestERC20(ctx context.Context, c *ethclient.Client, nonce uint64, erc20Contract *tokens.ERC20, ltAddress ethcommon.Address) (t1 time.Time, t2 time.Time, err error) {
+func loadTestCallDepth(ctx context.Context, c *ethclient.Client, nonce uint64, callerContract *contracts.Caller) (t1 time.Time, t2 time.Time, err error) {
+	ltp := inputLoadTestParams
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+	tops = configureTransactOpts(tops)
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		tops.NoSend = true
+		var tx *ethtypes.Transaction
+		tx, err = callerContract.Call(tops, *ltp.CallDepth, *ltp.CallWidth)
+		if err != nil {
+			return
+		}
+		msg := txToCallMsg(tx)
+		_, err = c.CallContract(ctx, msg, nil)
+	} else {
+		_, err = callerContract.Call(tops, *ltp.CallDepth, *ltp.CallWidth)
+	}
+	return
+}
+
+func loadTestLogs(ctx context.Context, c *ethclient.Client, nonce uint64, logEmitterContract *contracts.LogEmitter) (t1 time.Time, t2 time.Time, err error) {
 	ltp := inputLoadTestParams
 
-	to := ltp.ToETHAddress
-	if *ltp.ToRandom {
-		to = getRandomAddress()
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
 	}
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+	tops = configureTransactOpts(tops)
+
+	data := make([]byte, *ltp.LogDataSize)
+	_, _ = hexwordRead(data)
+	seed := newRequestRand(nonce, randStreamRequest).Uint64()
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		tops.NoSend = true
+		var tx *ethtypes.Transaction
+		tx, err = logEmitterContract.Emit(tops, *ltp.LogCount, *ltp.LogTopics, seed, data)
+		if err != nil {
+			return
+		}
+		msg := txToCallMsg(tx)
+		_, err = c.CallContract(ctx, msg, nil)
+	} else {
+		_, err = logEmitterContract.Emit(tops, *ltp.LogCount, *ltp.LogTopics, seed, data)
+	}
+	return
+}
+
+// loadTestColdAccess sends a transaction that touches many distinct accounts
+// and storage slots. The transaction is built by hand because the bound
+// contracts don't support access lists.
+func loadTestColdAccess(ctx context.Context, c *ethclient.Client, nonce uint64, coldAccessAddr ethcommon.Address) (t1 time.Time, t2 time.Time, err error) {
+	ltp := inputLoadTestParams
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops = configureTransactOpts(tops)
+	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
+
+	// A random seed keeps the accounts and slots cold across transactions
+	seedBytes := make([]byte, 20)
+	_, _ = newRequestRand(nonce, randStreamRequest).Read(seedBytes)
+	seed := new(big.Int).SetBytes(seedBytes)
+	data := contracts.ColdAccessInput(*ltp.ColdAccessCount, seed)
+	var accessList ethtypes.AccessList
+	if *ltp.ColdAccessList {
+		accessList = contracts.ColdAccessList(coldAccessAddr, *ltp.ColdAccessCount, seed)
+	}
+
+	gas := tops.GasLimit
+	if gas == 0 {
+		gas, err = c.EstimateGas(ctx, ethereum.CallMsg{
+			From:       *ltp.FromETHAddress,
+			To:         &coldAccessAddr,
+			Data:       data,
+			AccessList: accessList,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to estimate gas")
+			return
+		}
+	}
+
+	var tx *ethtypes.Transaction
+	switch {
+	case *ltp.LegacyTransactionMode && accessList == nil:
+		tx = ethtypes.NewTx(&ethtypes.LegacyTx{
+			Nonce:    nonce,
+			To:       &coldAccessAddr,
+			Gas:      gas,
+			GasPrice: gasPrice,
+			Data:     data,
+		})
+	case *ltp.LegacyTransactionMode:
+		tx = ethtypes.NewTx(&ethtypes.AccessListTx{
+			ChainID:    chainID,
+			Nonce:      nonce,
+			To:         &coldAccessAddr,
+			Gas:        gas,
+			GasPrice:   gasPrice,
+			Data:       data,
+			AccessList: accessList,
+		})
+	default:
+		tx = ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      nonce,
+			To:         &coldAccessAddr,
+			Gas:        gas,
+			GasFeeCap:  gasPrice,
+			GasTipCap:  gasTipCap,
+			Data:       data,
+			AccessList: accessList,
+		})
+	}
+
+	stx, err := tops.Signer(*ltp.FromETHAddress, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign transaction")
+		return
+	}
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		_, err = c.CallContract(ctx, txToCallMsg(stx), nil)
+	} else {
+		err = sendTransaction(ctx, c, stx)
+	}
+	return
+}
+
+func loadTestCompute(ctx context.Context, c *ethclient.Client, nonce uint64, computeContract *contracts.ComputeLoop) (t1 time.Time, t2 time.Time, err error) {
+	ltp := inputLoadTestParams
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+	// The contract loops until the gas runs out, so the gas limit is the
+	// compute budget of the transaction
+	tops.GasLimit = *ltp.ComputeGas
+	tops = configureTransactOpts(tops)
+	keccak := *ltp.ComputeOp == "keccak"
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		tops.NoSend = true
+		var tx *ethtypes.Transaction
+		tx, err = computeContract.Compute(tops, keccak)
+		if err != nil {
+			return
+		}
+		msg := txToCallMsg(tx)
+		_, err = c.CallContract(ctx, msg, nil)
+	} else {
+		_, err = computeContract.Compute(tops, keccak)
+	}
+	return
+}
+
+// loadTestDisperse sends a transaction that pays every recipient the send
+// amount in ether, or in tokens if the disperse token flag is set.
+func loadTestDisperse(ctx context.Context, c *ethclient.Client, nonce uint64, disperseContract *contracts.Disperse, erc20Addr ethcommon.Address) (t1 time.Time, t2 time.Time, err error) {
+	ltp := inputLoadTestParams
+
+	count := *ltp.DisperseRecipients
+	recipients := make([]ethcommon.Address, count)
+	amounts := make([]*big.Int, count)
+	rng := newRequestRand(nonce, randStreamRequest)
+	for i := range recipients {
+		recipients[i] = *getRecipient(rng, recipientIndex(nonce, i, count))
+		amounts[i] = ltp.SendAmount
+	}
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+	tops = configureTransactOpts(tops)
+	// The token is left as the zero address to send ether
+	var token ethcommon.Address
+	if *ltp.DisperseToken {
+		token = erc20Addr
+	} else {
+		tops.Value = new(big.Int).Mul(ltp.SendAmount, new(big.Int).SetUint64(count))
+	}
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		tops.NoSend = true
+		var tx *ethtypes.Transaction
+		tx, err = disperseContract.Disperse(tops, token, recipients, amounts)
+		if err != nil {
+			return
+		}
+		msg := txToCallMsg(tx)
+		_, err = c.CallContract(ctx, msg, nil)
+	} else {
+		_, err = disperseContract.Disperse(tops, token, recipients, amounts)
+	}
+	return
+}
+
+func loadTestERC20(ctx context.Context, c *ethclient.Client, nonce uint64, erc20Contract *tokens.ERC20, ltAddress ethcommon.Address) (t1 time.Time, t2 time.Time, err error) {
+	ltp := inputLoadTestParams
+
+	to := getRecipient(newRequestRand(nonce, randStreamRequest), recipientIndex(nonce, 0, 1))
 	amount := ltp.SendAmount
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -1135,15 +1531,11 @@ func loadTestERC721(ctx context.Context, c *ethclient.Client, nonce uint64, erc7
 	ltp := inputLoadTestParams
 	iterations := ltp.Iterations
 
-	to := ltp.ToETHAddress
-	if *ltp.ToRandom {
-		to = getRandomAddress()
-	}
+	to := getRecipient(newRequestRand(nonce, randStreamRequest), recipientIndex(nonce, 0, 1))
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -1173,9 +1565,8 @@ func loadTestRecall(ctx context.Context, c *ethclient.Client, nonce uint64, orig
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
 
-	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable create transaction signer")
 		return
@@ -1211,14 +1602,14 @@ func loadTestRecall(ctx context.Context, c *ethclient.Client, nonce uint64, orig
 		// we're not going to return the error in the case because there is no point retrying
 		err = nil
 	} else {
-		err = c.SendTransaction(ctx, stx)
+		err = sendTransaction(ctx, c, stx)
 	}
 	return
 }
 
 func loadTestRPC(ctx context.Context, c *ethclient.Client, nonce uint64, ia *IndexedActivity) (t1 time.Time, t2 time.Time, err error) {
-
-	funcNum := randSrc.Intn(300)
+	rng := newRequestRand(nonce, randStreamRequest)
+	funcNum := rng.Intn(300)
 	t1 = time.Now()
 	defer func() { t2 = time.Now() }()
 	if funcNum < 10 {
@@ -1228,30 +1619,30 @@ func loadTestRPC(ctx context.Context, c *ethclient.Client, nonce uint64, ia *Ind
 		log.Trace().Msg("eth_estimateGas")
 	} else if funcNum < 33 {
 		log.Trace().Msg("eth_getTransactionCount")
-		_, err = c.NonceAt(ctx, ethcommon.HexToAddress(ia.Addresses[randSrc.Intn(len(ia.Addresses))]), nil)
+		_, err = c.NonceAt(ctx, ethcommon.HexToAddress(ia.Addresses[rng.Intn(len(ia.Addresses))]), nil)
 	} else if funcNum < 47 {
 		log.Trace().Msg("eth_getCode")
-		_, err = c.CodeAt(ctx, ethcommon.HexToAddress(ia.Contracts[randSrc.Intn(len(ia.Contracts))]), nil)
+		_, err = c.CodeAt(ctx, ethcommon.HexToAddress(ia.Contracts[rng.Intn(len(ia.Contracts))]), nil)
 	} else if funcNum < 64 {
 		log.Trace().Msg("eth_getBlockByNumber")
-		_, err = c.BlockByNumber(ctx, big.NewInt(int64(randSrc.Intn(int(ia.BlockNumber)))))
+		_, err = c.BlockByNumber(ctx, big.NewInt(int64(rng.Intn(int(ia.BlockNumber)))))
 	} else if funcNum < 84 {
 		log.Trace().Msg("eth_getTransactionByHash")
-		_, _, err = c.TransactionByHash(ctx, ethcommon.HexToHash(ia.TransactionIDs[randSrc.Intn(len(ia.TransactionIDs))]))
+		_, _, err = c.TransactionByHash(ctx, ethcommon.HexToHash(ia.TransactionIDs[rng.Intn(len(ia.TransactionIDs))]))
 	} else if funcNum < 109 {
 		log.Trace().Msg("eth_getBalance")
-		_, err = c.BalanceAt(ctx, ethcommon.HexToAddress(ia.Addresses[randSrc.Intn(len(ia.Addresses))]), nil)
+		_, err = c.BalanceAt(ctx, ethcommon.HexToAddress(ia.Addresses[rng.Intn(len(ia.Addresses))]), nil)
 	} else if funcNum < 142 {
 		log.Trace().Msg("eth_getTransactionReceipt")
-		_, err = c.TransactionReceipt(ctx, ethcommon.HexToHash(ia.TransactionIDs[randSrc.Intn(len(ia.TransactionIDs))]))
+		_, err = c.TransactionReceipt(ctx, ethcommon.HexToHash(ia.TransactionIDs[rng.Intn(len(ia.TransactionIDs))]))
 	} else if funcNum < 192 {
 		log.Trace().Msg("eth_getLogs")
-		h := ethcommon.HexToHash(ia.BlockIDs[randSrc.Intn(len(ia.BlockIDs))])
+		h := ethcommon.HexToHash(ia.BlockIDs[rng.Intn(len(ia.BlockIDs))])
 		_, err = c.FilterLogs(ctx, ethereum.FilterQuery{BlockHash: &h})
 	} else {
 		log.Trace().Msg("eth_call")
-		erc20Str := string(ia.ERC20Addresses[randSrc.Intn(len(ia.ERC20Addresses))])
-		erc721Str := string(ia.ERC721Addresses[randSrc.Intn(len(ia.ERC721Addresses))])
+		erc20Str := string(ia.ERC20Addresses[rng.Intn(len(ia.ERC20Addresses))])
+		erc721Str := string(ia.ERC721Addresses[rng.Intn(len(ia.ERC721Addresses))])
 		erc20Addr := ethcommon.HexToAddress(erc20Str)
 		erc721Addr := ethcommon.HexToAddress(erc721Str)
 		log.Trace().
@@ -1292,7 +1683,7 @@ func loadTestRPC(ctx context.Context, c *ethclient.Client, nonce uint64, ia *Ind
 	return
 }
 
-func recordSample(goRoutineID, requestID int64, err error, start, end time.Time, nonce uint64) {
+func recordSample(goRoutineID, requestID int64, err error, errorClass string, start, end time.Time, nonce uint64) {
 	s := loadTestSample{}
 	s.GoRoutineID = goRoutineID
 	s.RequestID = requestID
@@ -1301,6 +1692,7 @@ func recordSample(goRoutineID, requestID int64, err error, start, end time.Time,
 	s.Nonce = nonce
 	if err != nil {
 		s.IsError = true
+		s.ErrorClass = errorClass
 	}
 	loadTestResutsMutex.Lock()
 	loadTestResults = append(loadTestResults, s)
@@ -1321,9 +1713,9 @@ func (hw *hexwordReader) Read(p []byte) (n int, err error) {
 	return
 }
 
-func getRandomAddress() *ethcommon.Address {
+func getRandomAddress(rng *rand.Rand) *ethcommon.Address {
 	addr := make([]byte, 20)
-	n, err := randSrc.Read(addr)
+	n, err := rng.Read(addr)
 	if err != nil {
 		log.Error().Err(err).Msg("There was an issue getting random bytes for the address")
 	}
@@ -21,11 +21,22 @@ func _() {
 	_ = x[loadTestModeRandom-10]
 	_ = x[loadTestModeRecall-11]
 	_ = x[loadTestModeRPC-12]
+	_ = x[loadTestModeCallDepth-13]
+	_ = x[loadTestModeLogs-14]
+	_ = x[loadTestModeColdAccess-15]
+	_ = x[loadTestModeCompute-16]
+	_ = x[loadTestModeInscription-17]
+	_ = x[loadTestModeDisperse-18]
+	_ = x[loadTestModeMultisig-19]
+	_ = x[loadTestModeSetCode-20]
+	_ = x[loadTestModeRPCRead-21]
+	_ = x[loadTestModeArchive-22]
+	_ = x[loadTestModeTrace-23]
 }
 
-const _loadTestMode_name = "loadTestModeTransactionloadTestModeDeployloadTestModeCallloadTestModeFunctionloadTestModeIncloadTestModeStoreloadTestModeERC20loadTestModeERC721loadTestModePrecompiledContractsloadTestModePrecompiledContractloadTestModeRandomloadTestModeRecallloadTestModeRPC"
+const _loadTestMode_name = "loadTestModeTransactionloadTestModeDeployloadTestModeCallloadTestModeFunctionloadTestModeIncloadTestModeStoreloadTestModeERC20loadTestModeERC721loadTestModePrecompiledContractsloadTestModePrecompiledContractloadTestModeRandomloadTestModeRecallloadTestModeRPCloadTestModeCallDepthloadTestModeLogsloadTestModeColdAccessloadTestModeComputeloadTestModeInscriptionloadTestModeDisperseloadTestModeMultisigloadTestModeSetCodeloadTestModeRPCReadloadTestModeArchiveloadTestModeTrace"
 
-var _loadTestMode_index = [...]uint16{0, 23, 41, 57, 77, 92, 109, 126, 144, 176, 207, 225, 243, 258}
+var _loadTestMode_index = [...]uint16{0, 23, 41, 57, 77, 92, 109, 126, 144, 176, 207, 225, 243, 258, 279, 295, 317, 336, 359, 379, 399, 418, 437, 456, 473}
 
 func (i loadTestMode) String() string {
 	if i < 0 || i >= loadTestMode(len(_loadTestMode_index)-1) {
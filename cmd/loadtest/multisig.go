@@ -0,0 +1,178 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/maticnetwork/polygon-cli/contracts"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	multisigOwnerKeys     []*ecdsa.PrivateKey
+	multisigOwnerKeysErr  error
+	multisigOwnerKeysOnce sync.Once
+)
+
+// getMultisigOwnerKeys returns the keys of the wallet owners. They're derived
+// from the seed, so a wallet deployed by an earlier run with the same seed and
+// number of owners can be reused.
+func getMultisigOwnerKeys() ([]*ecdsa.PrivateKey, error) {
+	multisigOwnerKeysOnce.Do(func() {
+		ltp := inputLoadTestParams
+		keys := make([]*ecdsa.PrivateKey, *ltp.MultisigOwners)
+		for i := range keys {
+			b := make([]byte, 24)
+			copy(b, "multisig")
+			binary.BigEndian.PutUint64(b[8:], uint64(*ltp.Seed))
+			binary.BigEndian.PutUint64(b[16:], uint64(i))
+			key, err := ethcrypto.ToECDSA(ethcrypto.Keccak256(b))
+			if err != nil {
+				// The odds of a hash that isn't a valid key are negligible
+				multisigOwnerKeysErr = fmt.Errorf("unable to derive multisig owner key %d: %w", i, err)
+				return
+			}
+			keys[i] = key
+		}
+		multisigOwnerKeys = keys
+	})
+	return multisigOwnerKeys, multisigOwnerKeysErr
+}
+
+func getMultisigOwners() ([]ethcommon.Address, error) {
+	keys, err := getMultisigOwnerKeys()
+	if err != nil {
+		return nil, err
+	}
+	owners := make([]ethcommon.Address, len(keys))
+	for i, key := range keys {
+		owners[i] = ethcrypto.PubkeyToAddress(key.PublicKey)
+	}
+	return owners, nil
+}
+
+// setupMultisig sets the owners and threshold of a newly deployed wallet and
+// funds it, then waits until both are done.
+func setupMultisig(ctx context.Context, c *ethclient.Client, wallet *contracts.Multisig, walletAddr ethcommon.Address, tops *bind.TransactOpts) error {
+	ltp := inputLoadTestParams
+	fund, err := hexToBigInt(*ltp.MultisigFund)
+	if err != nil {
+		log.Error().Err(err).Msg("Couldn't parse the multisig funding amount")
+		return err
+	}
+
+	owners, err := getMultisigOwners()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to derive the multisig owners")
+		return err
+	}
+	_, err = wallet.Setup(tops, *ltp.MultisigThreshold, owners)
+	if err != nil {
+		log.Error().Err(err).Msg("There was an error setting up the multisig")
+		return err
+	}
+	fundOpts := *tops
+	fundOpts.Value = fund
+	if _, err = wallet.Fund(&fundOpts); err != nil {
+		log.Error().Err(err).Msg("There was an error funding the multisig")
+		return err
+	}
+
+	return blockUntilSuccessful(ctx, c, func() error {
+		threshold, err := c.StorageAt(ctx, walletAddr, ethcommon.BigToHash(big.NewInt(1)), nil)
+		if err != nil {
+			return err
+		}
+		if new(big.Int).SetBytes(threshold).Sign() == 0 {
+			return fmt.Errorf("the multisig isn't set up")
+		}
+		balance, err := c.BalanceAt(ctx, walletAddr, nil)
+		if err != nil {
+			return err
+		}
+		if balance.Cmp(fund) < 0 {
+			return fmt.Errorf("the multisig isn't funded")
+		}
+		return nil
+	})
+}
+
+// loadTestMultisig has the wallet send the send amount to a recipient, or pay
+// the disperse recipients by delegate calling the disperse contract, with the
+// signatures of threshold owners. The wallet only takes increasing nonces, so
+// the nonce of the transaction is used.
+func loadTestMultisig(ctx context.Context, c *ethclient.Client, nonce uint64, wallet *contracts.Multisig, walletAddr, disperseAddr ethcommon.Address) (t1 time.Time, t2 time.Time, err error) {
+	ltp := inputLoadTestParams
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	walletNonce := nonce + 1
+
+	rng := newRequestRand(nonce, randStreamRequest)
+	operation := contracts.MultisigOperationCall
+	to := *getRecipient(rng, recipientIndex(nonce, 0, 1))
+	value := ltp.SendAmount
+	var data []byte
+	if *ltp.MultisigDelegateCall {
+		count := *ltp.DisperseRecipients
+		recipients := make([]ethcommon.Address, count)
+		amounts := make([]*big.Int, count)
+		for i := range recipients {
+			recipients[i] = *getRecipient(rng, recipientIndex(nonce, i, count))
+			amounts[i] = ltp.SendAmount
+		}
+		data, err = contracts.DisperseInput(ethcommon.Address{}, recipients, amounts)
+		if err != nil {
+			return
+		}
+		operation = contracts.MultisigOperationDelegateCall
+		to = disperseAddr
+		value = big.NewInt(0)
+	}
+
+	hash := contracts.MultisigHash(walletAddr, chainID, walletNonce, operation, to, value, data)
+	keys, err := getMultisigOwnerKeys()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to derive the multisig owner keys")
+		return
+	}
+	signatures, err := contracts.SignMultisig(hash, keys[:*ltp.MultisigThreshold])
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign the multisig transaction")
+		return
+	}
+
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+	tops = configureTransactOpts(tops)
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		tops.NoSend = true
+		var tx *ethtypes.Transaction
+		tx, err = wallet.Exec(tops, operation, to, value, walletNonce, data, signatures)
+		if err != nil {
+			return
+		}
+		msg := txToCallMsg(tx)
+		_, err = c.CallContract(ctx, msg, nil)
+	} else {
+		_, err = wallet.Exec(tops, operation, to, value, walletNonce, data, signatures)
+	}
+	return
+}
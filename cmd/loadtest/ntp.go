@@ -0,0 +1,134 @@
+package loadtest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ntpEpochOffset is the number of seconds between the NTP epoch in 1900
+	// and the unix epoch.
+	ntpEpochOffset = 2208988800
+	ntpTimeout     = 5 * time.Second
+	// ntpSamples is the number of queries made to measure the offset. The
+	// sample with the shortest round trip is the most accurate one.
+	ntpSamples = 4
+)
+
+// clockSync is how far the local clock is from the NTP server. Adding the
+// offset to a local time gives the server time.
+type clockSync struct {
+	Offset    time.Duration `json:"offset"`
+	RoundTrip time.Duration `json:"roundTrip"`
+}
+
+// now returns the current time corrected by the offset.
+func (s clockSync) now() time.Time {
+	return time.Now().Add(s.Offset)
+}
+
+// toLocal converts a corrected time to the local clock.
+func (s clockSync) toLocal(t time.Time) time.Time {
+	return t.Add(-s.Offset)
+}
+
+// toCorrected converts a local time to the corrected clock.
+func (s clockSync) toCorrected(t time.Time) time.Time {
+	return t.Add(s.Offset)
+}
+
+// syncClock measures the offset of the local clock against the NTP server. If
+// there's no server or it can't be reached, the local clock is used as is.
+func syncClock(server string) clockSync {
+	if server == "" {
+		return clockSync{}
+	}
+	var best *clockSync
+	var err error
+	for i := 0; i < ntpSamples; i++ {
+		var s clockSync
+		s, err = queryNTP(server)
+		if err != nil {
+			// A server that doesn't answer would only time out again.
+			break
+		}
+		if best == nil || s.RoundTrip < best.RoundTrip {
+			best = &s
+		}
+	}
+	if best == nil {
+		log.Warn().Err(err).Str("server", server).Msg("Unable to reach the NTP server, the local clock won't be corrected")
+		return clockSync{}
+	}
+	log.Info().Str("server", server).Dur("offset", best.Offset).Dur("roundTrip", best.RoundTrip).Msg("Measured the clock offset")
+	return *best
+}
+
+// queryNTP makes a single SNTP request. The offset is computed from the four
+// timestamps of the exchange as described in RFC 4330.
+func queryNTP(server string) (clockSync, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, ntpTimeout)
+	if err != nil {
+		return clockSync{}, err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(ntpTimeout)); err != nil {
+		return clockSync{}, err
+	}
+
+	// The first byte is no leap second warning, version 4, and client mode.
+	req := make([]byte, 48)
+	req[0] = 0x23
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTPTime(sent))
+	if _, err = conn.Write(req); err != nil {
+		return clockSync{}, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return clockSync{}, err
+	}
+	received := time.Now()
+	if n < 48 {
+		return clockSync{}, fmt.Errorf("short NTP response of %d bytes", n)
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return clockSync{}, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+	if stratum := resp[1]; stratum == 0 {
+		return clockSync{}, fmt.Errorf("the NTP server sent a kiss of death")
+	}
+	if binary.BigEndian.Uint64(resp[24:]) != binary.BigEndian.Uint64(req[40:]) {
+		return clockSync{}, fmt.Errorf("the NTP response doesn't match the request")
+	}
+
+	serverReceived := fromNTPTime(binary.BigEndian.Uint64(resp[32:]))
+	serverSent := fromNTPTime(binary.BigEndian.Uint64(resp[40:]))
+	return clockSync{
+		Offset:    (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2,
+		RoundTrip: received.Sub(sent) - serverSent.Sub(serverReceived),
+	}, nil
+}
+
+// toNTPTime encodes the time as seconds since 1900 in the upper 32 bits and
+// the fraction of a second in the lower 32 bits.
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanos := int64((ts & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanos)
+}
@@ -28,14 +28,26 @@ import (
 )
 
 func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNonce, endNonce uint64) {
-	filterBlockSummary(bs, startNonce, endNonce)
-	mapKeys := getSortedMapKeys(bs)
-	if len(mapKeys) == 0 {
+	fairness := getFairnessReport(bs, startNonce, endNonce)
+	// the number of transactions in each block, including the ones that
+	// aren't ours, which are dropped by the filter
+	blockTxCounts := make(map[uint64]int, len(bs))
+	for k, summary := range bs {
+		blockTxCounts[k] = len(summary.Block.Transactions)
+	}
+	firstBlock, lastBlockNumber := filterBlockSummary(bs, startNonce, endNonce)
+	if firstBlock > lastBlockNumber {
 		return
 	}
+	// every block of the test window is listed, including the ones before
+	// our first and after our last transaction
+	mapKeys := getSortedMapKeys(bs)
 
 	var totalTransactions uint64 = 0
 	var totalGasUsed uint64 = 0
+	var totalBlockTransactions uint64 = 0
+	var totalBlockGasUsed uint64 = 0
+	totalFees := getTotalFees(nil)
 	p := message.NewPrinter(language.English)
 
 	allLatencies := make([]time.Duration, 0)
@@ -51,10 +63,20 @@ func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNon
 		if gasUsed == 0 {
 			blockUtilization = 0
 		}
+		blockGasUsed := summary.Block.GasUsed.ToUint64()
+		otherGasUsed := uint64(0)
+		if blockGasUsed > gasUsed {
+			otherGasUsed = blockGasUsed - gasUsed
+		}
+		baseFee := getBaseFee(summary.Block)
+		baseFeeText := "n/a"
+		if baseFee != nil {
+			baseFeeText = baseFee.String()
+		}
 		// if we're at trace, debug, or info level we'll output the block level metrics
 		if zerolog.GlobalLevel() <= zerolog.InfoLevel {
 			if summaryOutputMode == "text" {
-				_, _ = p.Printf("Block number: %v\tTime: %s\tGas Limit: %v\tGas Used: %v\tNum Tx: %v\tUtilization %v\tLatencies: %v\t%v\t%v\n",
+				_, _ = p.Printf("Block number: %v\tTime: %s\tGas Limit: %v\tGas Used: %v\tNum Tx: %v\tUtilization %v\tLatencies: %v\t%v\t%v\tBlock Tx: %v\tOther Gas Used: %v\tBase Fee: %v\n",
 					number.Decimal(summary.Block.Number.ToUint64()),
 					time.Unix(summary.Block.Timestamp.ToInt64(), 0),
 					number.Decimal(summary.Block.GasLimit.ToUint64()),
@@ -63,7 +85,10 @@ func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNon
 					number.Percent(blockUtilization),
 					number.Decimal(minLatency.Seconds()),
 					number.Decimal(medianLatency.Seconds()),
-					number.Decimal(maxLatency.Seconds()))
+					number.Decimal(maxLatency.Seconds()),
+					number.Decimal(blockTxCounts[v]),
+					number.Decimal(otherGasUsed),
+					baseFeeText)
 			} else if summaryOutputMode == "json" {
 				jsonSummary := Summary{}
 				jsonSummary.BlockNumber = summary.Block.Number.ToUint64()
@@ -72,6 +97,10 @@ func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNon
 				jsonSummary.GasUsed = gasUsed
 				jsonSummary.NumTx = len(summary.Block.Transactions)
 				jsonSummary.Utilization = blockUtilization
+				jsonSummary.BlockNumTx = blockTxCounts[v]
+				jsonSummary.BlockGasUsed = blockGasUsed
+				jsonSummary.OtherGasUsed = otherGasUsed
+				jsonSummary.BaseFee = baseFee
 				latencies := Latency{}
 				latencies.Min = minLatency.Seconds()
 				latencies.Median = medianLatency.Seconds()
@@ -84,39 +113,78 @@ func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNon
 		}
 		totalTransactions += uint64(len(summary.Block.Transactions))
 		totalGasUsed += gasUsed
+		totalFees.add(getTotalFees(summary.Receipts))
+		// the block totals span the same blocks as the mining time, from our
+		// first to our last transaction
+		if v >= firstBlock && v <= lastBlockNumber {
+			totalBlockTransactions += uint64(blockTxCounts[v])
+			totalBlockGasUsed += blockGasUsed
+		}
 	}
-	parentOfFirstBlock, _ := c.BlockByNumber(context.Background(), big.NewInt(bs[mapKeys[0]].Block.Number.ToInt64()-1))
-	lastBlock := bs[mapKeys[len(mapKeys)-1]].Block
+	parentOfFirstBlock, _ := c.BlockByNumber(context.Background(), new(big.Int).SetUint64(firstBlock-1))
+	lastBlock := bs[lastBlockNumber].Block
 	totalMiningTime := time.Duration(lastBlock.Timestamp.ToUint64()-parentOfFirstBlock.Time()) * time.Second
 	tps := float64(totalTransactions) / totalMiningTime.Seconds()
 	gaspersec := float64(totalGasUsed) / totalMiningTime.Seconds()
 	minLatency, medianLatency, maxLatency := getMinMedianMax(allLatencies)
+	// getMinMedianMax sorted the latencies
+	percentiles := getLatencyPercentiles(allLatencies)
 	successfulTx, totalTx := getSuccessfulTransactionCount(bs)
 
 	if summaryOutputMode == "text" {
+		p.Printf("Seed: %d\n", *inputLoadTestParams.Seed)
 		p.Printf("Successful Tx: %v\tTotal Tx: %v\n", number.Decimal(successfulTx), number.Decimal(totalTx))
 		p.Printf("Total Mining Time: %s\n", totalMiningTime)
 		p.Printf("Total Transactions: %v\n", number.Decimal(totalTransactions))
 		p.Printf("Total Gas Used: %v\n", number.Decimal(totalGasUsed))
+		p.Printf("Total Block Transactions: %v\tTotal Block Gas Used: %v\tOur Share of Block Gas: %v\n", number.Decimal(totalBlockTransactions), number.Decimal(totalBlockGasUsed), number.Percent(getShare(totalGasUsed, totalBlockGasUsed)))
+		p.Printf("Total Fees (wei): %v\tExecution: %v\tL1 Data: %v\tFee Model: %s\n", totalFees.Total, totalFees.ExecutionFee, totalFees.L1Fee, inputLoadTestParams.FeeModel)
+		if totalFees.L1GasUsed > 0 {
+			p.Printf("Total L1 Gas Used: %v\n", number.Decimal(totalFees.L1GasUsed))
+		}
 		p.Printf("Transactions per sec: %v\n", number.Decimal(tps))
 		p.Printf("Gas Per Second: %v\n", number.Decimal(gaspersec))
 		p.Printf("Latencies - Min: %v\tMedian: %v\tMax: %v\n", number.Decimal(minLatency.Seconds()), number.Decimal(medianLatency.Seconds()), number.Decimal(maxLatency.Seconds()))
+		p.Printf("Latency Percentiles - P50: %v\tP90: %v\tP95: %v\tP99: %v\n", number.Decimal(percentiles.P50), number.Decimal(percentiles.P90), number.Decimal(percentiles.P95), number.Decimal(percentiles.P99))
+		if samples, errs := countSamples(); errs > 0 {
+			errorClasses := countSendErrors()
+			p.Printf("Request Errors: %v of %v", number.Decimal(errs), number.Decimal(samples))
+			for _, class := range sendErrorClasses {
+				if errorClasses[class] > 0 {
+					p.Printf("\t%s: %v", class, number.Decimal(errorClasses[class]))
+				}
+			}
+			p.Printf("\n")
+		}
+		printFairnessReport(p, fairness)
 		// TODO: Add some kind of indication of block time variance
 	} else if summaryOutputMode == "json" {
 		summaryOutput := SummaryOutput{}
 		summaryOutput.Summaries = jsonSummaryList
+		summaryOutput.Seed = *inputLoadTestParams.Seed
 		summaryOutput.SuccessfulTx = successfulTx
 		summaryOutput.TotalTx = totalTx
 		summaryOutput.TotalMiningTime = totalMiningTime
 		summaryOutput.TotalGasUsed = totalGasUsed
+		summaryOutput.TotalBlockTx = totalBlockTransactions
+		summaryOutput.TotalBlockGasUsed = totalBlockGasUsed
+		summaryOutput.FeeModel = string(inputLoadTestParams.FeeModel)
+		summaryOutput.TotalExecutionFee = totalFees.ExecutionFee
+		summaryOutput.TotalL1Fee = totalFees.L1Fee
+		summaryOutput.TotalL1GasUsed = totalFees.L1GasUsed
+		summaryOutput.TotalFee = totalFees.Total
 		summaryOutput.TransactionsPerSec = tps
 		summaryOutput.GasPerSecond = gaspersec
+		summaryOutput.Fairness = fairness
 
 		latencies := Latency{}
 		latencies.Min = minLatency.Seconds()
 		latencies.Median = medianLatency.Seconds()
 		latencies.Max = maxLatency.Seconds()
 		summaryOutput.Latencies = latencies
+		summaryOutput.LatencyPercentiles = percentiles
+		summaryOutput.Samples, summaryOutput.SampleErrors = countSamples()
+		summaryOutput.SampleErrorClasses = countSendErrors()
 
 		val, _ := json.MarshalIndent(summaryOutput, "", "    ")
 		p.Println(string(val))
@@ -124,7 +192,10 @@ func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNon
 		log.Error().Str("mode", summaryOutputMode).Msg("Invalid mode for summary output")
 	}
 }
-func filterBlockSummary(blockSummaries map[uint64]blockSummary, startNonce, endNonce uint64) {
+
+// filterBlockSummary drops the transactions and receipts that aren't ours from
+// the block summaries, and returns the first and last block with one of ours.
+func filterBlockSummary(blockSummaries map[uint64]blockSummary, startNonce, endNonce uint64) (uint64, uint64) {
 	validTx := make(map[ethcommon.Hash]struct{}, 0)
 	var minBlock uint64 = math.MaxUint64
 	var maxBlock uint64 = 0
@@ -141,17 +212,8 @@ func filterBlockSummary(blockSummaries map[uint64]blockSummary, startNonce, endN
 			}
 		}
 	}
-	keys := getSortedMapKeys(blockSummaries)
-	for _, k := range keys {
-		if k < minBlock {
-			delete(blockSummaries, k)
-		}
-		if k > maxBlock {
-			delete(blockSummaries, k)
-		}
-	}
 
-	for _, bs := range blockSummaries {
+	for k, bs := range blockSummaries {
 		filteredTransactions := make([]rpctypes.RawTransactionResponse, 0)
 		for txKey, tx := range bs.Block.Transactions {
 			if _, hasKey := validTx[tx.Hash.ToHash()]; hasKey {
@@ -166,8 +228,18 @@ func filterBlockSummary(blockSummaries map[uint64]blockSummary, startNonce, endN
 			}
 		}
 		bs.Receipts = filteredReceipts
+		blockSummaries[k] = bs
+	}
+	return minBlock, maxBlock
+}
 
+// getBaseFee returns the base fee of the block, or nil if the block doesn't
+// have one.
+func getBaseFee(block *rpctypes.RawBlockResponse) *big.Int {
+	if block.BaseFeePerGas == "" {
+		return nil
 	}
+	return block.BaseFeePerGas.ToBigInt()
 }
 func getMapValues[K constraints.Ordered, V any](m map[K]V) []V {
 	newSlice := make([]V, 0)
@@ -207,6 +279,22 @@ func getMinMedianMax[V constraints.Float | constraints.Integer](values []V) (V,
 	return min, median, max
 }
 
+// getLatencyPercentiles expects the latencies to be sorted.
+func getLatencyPercentiles(sorted []time.Duration) LatencyPercentiles {
+	percentile := func(p float64) float64 {
+		if len(sorted) == 0 {
+			return 0
+		}
+		return sorted[int(p*float64(len(sorted)-1))].Seconds()
+	}
+	return LatencyPercentiles{
+		P50: percentile(0.5),
+		P90: percentile(0.9),
+		P95: percentile(0.95),
+		P99: percentile(0.99),
+	}
+}
+
 func getSortedMapKeys[V any, K constraints.Ordered](m map[K]V) []K {
 	keys := make([]K, 0)
 	for k := range m {
@@ -228,6 +316,14 @@ func getSuccessfulTransactionCount(bs map[uint64]blockSummary) (successful, tota
 	return
 }
 
+// getShare returns part as a fraction of total, or zero if total is zero.
+func getShare(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
 func getTotalGasUsed(receipts map[ethcommon.Hash]rpctypes.RawTxReceipt) uint64 {
 	var totalGasUsed uint64 = 0
 	for _, receipt := range receipts {
@@ -242,6 +338,15 @@ type Latency struct {
 	Max    float64
 }
 
+// LatencyPercentiles are the percentiles of the latencies of all our
+// transactions in seconds.
+type LatencyPercentiles struct {
+	P50 float64
+	P90 float64
+	P95 float64
+	P99 float64
+}
+
 type Summary struct {
 	BlockNumber uint64
 	Time        time.Time
@@ -250,17 +355,41 @@ type Summary struct {
 	NumTx       int
 	Utilization float64
 	Latencies   Latency
+	// BlockNumTx and BlockGasUsed include the transactions that aren't ours,
+	// and OtherGasUsed is the gas used by them.
+	BlockNumTx   int
+	BlockGasUsed uint64
+	OtherGasUsed uint64
+	// BaseFee is omitted for blocks without a base fee.
+	BaseFee *big.Int `json:",omitempty"`
 }
 
 type SummaryOutput struct {
 	Summaries          []Summary
+	Seed               int64
 	SuccessfulTx       int64
 	TotalTx            int64
 	TotalMiningTime    time.Duration
 	TotalGasUsed       uint64
+	TotalBlockTx       uint64
+	TotalBlockGasUsed  uint64
+	FeeModel           string
+	TotalExecutionFee  *big.Int
+	TotalL1Fee         *big.Int
+	TotalL1GasUsed     uint64
+	TotalFee           *big.Int
 	TransactionsPerSec float64
 	GasPerSecond       float64
+	Fairness           FairnessReport
 	Latencies          Latency
+	LatencyPercentiles LatencyPercentiles
+	// Samples is the number of requests we sent and SampleErrors the ones
+	// that failed before reaching a block.
+	Samples      int
+	SampleErrors int
+	// SampleErrorClasses is the number of failed requests of every class of
+	// error.
+	SampleErrorClasses map[string]int
 }
 
 func summarizeTransactions(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, startBlockNumber, startNonce, lastBlockNumber, endNonce uint64) error {
@@ -413,6 +542,7 @@ func printResults(lts []loadTestSample) {
 
 	log.Info().Msg("* Results")
 	log.Info().Int("samples", len(lts)).Msg("Samples")
+	log.Info().Int64("seed", *inputLoadTestParams.Seed).Msg("Seed")
 
 	var startTime = lts[0].RequestTime
 	var endTime = lts[len(lts)-1].RequestTime
@@ -432,6 +562,19 @@ func printResults(lts []loadTestSample) {
 	log.Info().Time("endTime", endTime).Msg("End")
 	log.Info().Float64("meanWait", meanWait).Msg("Mean Wait")
 	log.Info().Uint64("numErrors", numErrors).Msg("Num errors")
+	if numErrors > 0 {
+		errorClasses := countSendErrors()
+		event := log.Info()
+		for _, class := range sendErrorClasses {
+			event = event.Int(class, errorClasses[class])
+		}
+		event.Msg("Errors by class")
+	}
+	rpcReadStats.print("method", "Read call latencies")
+	archiveStats.print("blockAge", "Historical state read latencies")
+	traceStats.print("trace", "Trace latencies")
+	printReadTransports()
+	readValidation.print()
 }
 
 func lightSummary(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, startBlockNumber, startNonce, endBlockNumber, endNonce uint64, rl *rate.Limiter) {
@@ -458,5 +601,6 @@ func lightSummary(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client,
 		Float64("testDuration", testDuration.Seconds()).
 		Float64("tps", tps).
 		Float64("final rate limit", float64(rl.Limit())).
+		Int64("seed", *inputLoadTestParams.Seed).
 		Msg("rough test summary (ignores errors)")
 }
@@ -0,0 +1,57 @@
+package loadtest
+
+import (
+	"math/rand"
+)
+
+// The random streams of a request. The mode of a request is picked from its
+// own stream so it isn't correlated with the choices the mode makes.
+const (
+	randStreamRequest uint64 = iota
+	randStreamMode
+)
+
+// requestStartNonce is our nonce when the load test starts. Requests are
+// numbered from it, so the same seed gives the same choices on a later run.
+var requestStartNonce uint64
+
+// splitMix is a small random source. It's much cheaper to create than the
+// sources of math/rand, so every request can have its own.
+type splitMix struct {
+	state uint64
+}
+
+func (s *splitMix) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *splitMix) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// newRequestRand returns the random source of the stream of the request with
+// the nonce. It only depends on the seed, the stream, and the position of the
+// request, so the random choices of a run can be reproduced no matter which
+// worker sent which request.
+func newRequestRand(nonce, stream uint64) *rand.Rand {
+	mix := &splitMix{state: uint64(*inputLoadTestParams.Seed)}
+	mix.state ^= (&splitMix{state: requestPosition(nonce)<<8 | stream}).Uint64()
+	return rand.New(mix)
+}
+
+// requestPosition returns the position of the request with the nonce in the
+// run.
+func requestPosition(nonce uint64) uint64 {
+	if nonce > requestStartNonce {
+		return nonce - requestStartNonce
+	}
+	return 0
+}
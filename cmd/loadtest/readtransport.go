@@ -0,0 +1,204 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// The transports the read modes can compare. Batch sends the calls of a
+// request in one JSON-RPC batch over HTTP, while http and ws send them one by
+// one.
+const (
+	readTransportHTTP  = "http"
+	readTransportBatch = "batch"
+	readTransportWS    = "ws"
+)
+
+// readTransport is a way of sending the read calls and the stats of the
+// calls sent over it. Latencies are of all the calls of a request.
+type readTransport struct {
+	Name   string
+	client *ethrpc.Client
+
+	lock      sync.Mutex
+	latencies []time.Duration
+	calls     int
+	errors    int
+	busy      time.Duration
+}
+
+var readTransports []*readTransport
+
+// setupReadTransports dials the endpoints of the transports that are
+// compared. The HTTP and WebSocket endpoints default to the RPC endpoint if
+// it has the right scheme.
+func setupReadTransports(ctx context.Context) error {
+	ltp := inputLoadTestParams
+	readTransports = nil
+	if len(*ltp.ReadTransports) == 0 {
+		return nil
+	}
+	if !hasMode(loadTestModeRPCRead, ltp.ParsedModes) && !hasMode(loadTestModeArchive, ltp.ParsedModes) && !hasMode(loadTestModeTrace, ltp.ParsedModes) {
+		return fmt.Errorf("the read transports can only be compared in the rpc read, archive, and trace modes")
+	}
+	if *ltp.ReadBatchSize == 0 {
+		return fmt.Errorf("the read batch size needs to be non-zero positive")
+	}
+
+	getURL := func(flag string, schemes ...string) (string, error) {
+		if flag != "" {
+			return flag, nil
+		}
+		if contains(schemes, ltp.URL.Scheme) {
+			return ltp.URL.String(), nil
+		}
+		return "", fmt.Errorf("the rpc url isn't a %s endpoint, so it needs to be set with a flag", schemes[0])
+	}
+	for _, name := range *ltp.ReadTransports {
+		var url string
+		var err error
+		switch name {
+		case readTransportHTTP, readTransportBatch:
+			url, err = getURL(*ltp.ReadHTTPURL, "http", "https")
+		case readTransportWS:
+			url, err = getURL(*ltp.ReadWSURL, "ws", "wss")
+		default:
+			return fmt.Errorf("the read transport %s is not supported, expected http, batch, or ws", name)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to compare the %s transport: %w", name, err)
+		}
+		for _, t := range readTransports {
+			if t.Name == name {
+				return fmt.Errorf("the read transport %s is given more than once", name)
+			}
+		}
+		// Each transport gets its own connections
+		client, err := dialRPC(ctx, url, newLoadTestHTTPClient())
+		if err != nil {
+			log.Error().Err(err).Str("url", url).Msg("Unable to dial the read transport")
+			return err
+		}
+		readTransports = append(readTransports, &readTransport{Name: name, client: client})
+	}
+	return nil
+}
+
+// compareReadTransports sends the same read calls over every transport. The
+// order of the transports rotates with each request, so none of them always
+// benefits from the caches warmed up by the others.
+func compareReadTransports(ctx context.Context, nonce uint64, rng *rand.Rand, ia *IndexedActivity, getCall func(*rand.Rand, *IndexedActivity) readCall, stats *readStats) (t1 time.Time, t2 time.Time, err error) {
+	calls := make([]readCall, *inputLoadTestParams.ReadBatchSize)
+	for i := range calls {
+		calls[i] = getCall(rng, ia)
+	}
+
+	t1 = time.Now()
+	for i := range readTransports {
+		t := readTransports[(nonce+uint64(i))%uint64(len(readTransports))]
+		var tErr error
+		if t.Name == readTransportBatch {
+			tErr = t.sendBatch(ctx, calls)
+		} else {
+			tErr = t.sendEach(ctx, calls, stats)
+		}
+		if err == nil {
+			err = tErr
+		}
+	}
+	t2 = time.Now()
+	return
+}
+
+// sendEach sends the calls one by one. Their latencies are also recorded in
+// the stats of the mode.
+func (t *readTransport) sendEach(ctx context.Context, calls []readCall, stats *readStats) error {
+	var err error
+	errs := 0
+	start := time.Now()
+	for _, call := range calls {
+		var result json.RawMessage
+		t1, t2, cErr := callRead(ctx, t.client, call.Method, call.Args, &result)
+		stats.record(call.Key, t2.Sub(t1), cErr)
+		if cErr != nil {
+			errs++
+			if err == nil {
+				err = cErr
+			}
+		}
+	}
+	t.record(time.Since(start), len(calls), errs)
+	return err
+}
+
+// sendBatch sends the calls in a single batch.
+func (t *readTransport) sendBatch(ctx context.Context, calls []readCall) error {
+	elems := make([]ethrpc.BatchElem, len(calls))
+	for i, call := range calls {
+		elems[i] = ethrpc.BatchElem{Method: call.Method, Args: call.Args, Result: new(json.RawMessage)}
+	}
+	start := time.Now()
+	err := t.client.BatchCallContext(ctx, elems)
+	latency := time.Since(start)
+	if err != nil {
+		t.record(latency, len(calls), len(calls))
+		return err
+	}
+	errs := 0
+	for i, e := range elems {
+		if cErr := getReadError(calls[i].Method, e.Error); cErr != nil {
+			errs++
+			if err == nil {
+				err = cErr
+			}
+		}
+	}
+	t.record(latency, len(calls), errs)
+	return err
+}
+
+func (t *readTransport) record(latency time.Duration, calls, errs int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.latencies = append(t.latencies, latency)
+	t.calls += calls
+	t.errors += errs
+	t.busy += latency
+}
+
+// printReadTransports logs the throughput and latencies of every transport.
+// The throughput is the calls per second of the time spent sending them, so
+// it's comparable even though the transports take turns.
+func printReadTransports() {
+	for _, t := range readTransports {
+		t.lock.Lock()
+		if len(t.latencies) == 0 {
+			t.lock.Unlock()
+			continue
+		}
+		sort.Slice(t.latencies, func(i, j int) bool {
+			return t.latencies[i] < t.latencies[j]
+		})
+		percentiles := getLatencyPercentiles(t.latencies)
+		log.Info().
+			Str("transport", t.Name).
+			Int("requests", len(t.latencies)).
+			Int("calls", t.calls).
+			Int("errors", t.errors).
+			Float64("callsPerSecond", float64(t.calls)/t.busy.Seconds()).
+			Float64("p50", percentiles.P50).
+			Float64("p90", percentiles.P90).
+			Float64("p99", percentiles.P99).
+			Float64("max", t.latencies[len(t.latencies)-1].Seconds()).
+			Msg("Read transport")
+		t.lock.Unlock()
+	}
+}
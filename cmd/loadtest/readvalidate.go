@@ -0,0 +1,300 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/rs/zerolog/log"
+)
+
+// The kinds of mismatches the read validation finds.
+const (
+	readMismatchReference    = "reference"
+	readMismatchParentHash   = "parentHash"
+	readMismatchReceiptsRoot = "receiptsRoot"
+)
+
+// readValidator checks the results of the read modes against a reference
+// endpoint and the invariants of the chain. The validation calls aren't part
+// of the latencies.
+type readValidator struct {
+	reference  *ethrpc.Client
+	invariants bool
+
+	lock       sync.Mutex
+	checked    int
+	skipped    int
+	failed     int
+	mismatches map[string]int
+}
+
+var readValidation *readValidator
+
+// setupReadValidation dials the reference endpoint if the read modes are
+// validated.
+func setupReadValidation(ctx context.Context) error {
+	ltp := inputLoadTestParams
+	readValidation = nil
+	if *ltp.ReadReferenceURL == "" && !*ltp.ReadInvariants {
+		return nil
+	}
+	if !hasMode(loadTestModeRPCRead, ltp.ParsedModes) && !hasMode(loadTestModeArchive, ltp.ParsedModes) && !hasMode(loadTestModeTrace, ltp.ParsedModes) {
+		return fmt.Errorf("only the rpc read, archive, and trace modes can be validated")
+	}
+	if len(readTransports) > 0 {
+		return fmt.Errorf("the reads can't be validated while comparing transports")
+	}
+	v := &readValidator{invariants: *ltp.ReadInvariants, mismatches: make(map[string]int)}
+	if *ltp.ReadReferenceURL != "" {
+		var err error
+		v.reference, err = dialRPC(ctx, *ltp.ReadReferenceURL, loadTestHTTPClient)
+		if err != nil {
+			log.Error().Err(err).Str("url", *ltp.ReadReferenceURL).Msg("Unable to dial the reference endpoint")
+			return err
+		}
+	}
+	readValidation = v
+	return nil
+}
+
+// validate compares the result of the call with the reference endpoint and
+// checks the invariants of the blocks it returned.
+func (v *readValidator) validate(ctx context.Context, rpc *ethrpc.Client, call readCall, result json.RawMessage) {
+	if v.reference != nil {
+		// The endpoints can be at different heights, so calls at a block
+		// tag like latest can't be compared.
+		if hasBlockTag(call.Args) {
+			v.count(func() { v.skipped++ })
+		} else {
+			var reference json.RawMessage
+			if err := v.reference.CallContext(ctx, &reference, call.Method, call.Args...); getReadError(call.Method, err) != nil {
+				log.Warn().Err(err).Str("method", call.Method).Msg("Unable to get the result of the reference endpoint")
+				v.count(func() { v.failed++ })
+			} else {
+				equal, err := equalSharedFields(result, reference)
+				if err != nil {
+					log.Warn().Err(err).Str("method", call.Method).Msg("Unable to compare the results")
+				}
+				v.check(equal, readMismatchReference, call, "The result differs from the reference endpoint")
+			}
+		}
+	}
+	if v.invariants && call.Method == rpcReadGetBlockByNumber {
+		v.checkBlock(ctx, rpc, call, result)
+	}
+}
+
+// checkBlock checks that the block links to its parent and that the receipts
+// of its transactions match its receipts root.
+func (v *readValidator) checkBlock(ctx context.Context, rpc *ethrpc.Client, call readCall, result json.RawMessage) {
+	var block struct {
+		Number       hexutil.Uint64 `json:"number"`
+		ParentHash   ethcommon.Hash `json:"parentHash"`
+		ReceiptsRoot ethcommon.Hash `json:"receiptsRoot"`
+		Transactions []struct {
+			Hash ethcommon.Hash    `json:"hash"`
+			From ethcommon.Address `json:"from"`
+		} `json:"transactions"`
+	}
+	if err := json.Unmarshal(result, &block); err != nil {
+		log.Warn().Err(err).Msg("Unable to decode the block to check its invariants")
+		v.count(func() { v.failed++ })
+		return
+	}
+
+	if block.Number > 0 {
+		var parent struct {
+			Hash ethcommon.Hash `json:"hash"`
+		}
+		if err := rpc.CallContext(ctx, &parent, rpcReadGetBlockByNumber, hexutil.EncodeUint64(uint64(block.Number)-1), false); err != nil {
+			log.Warn().Err(err).Msg("Unable to get the parent of the block")
+			v.count(func() { v.failed++ })
+		} else {
+			v.check(parent.Hash == block.ParentHash, readMismatchParentHash, call, "The parent hash of the block differs from the hash of the previous block")
+		}
+	}
+
+	receipts := make([]*json.RawMessage, 0, len(block.Transactions))
+	elems := make([]ethrpc.BatchElem, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		// The state sync transactions of bor are sent from the zero
+		// address and aren't part of the receipts root
+		if tx.From == (ethcommon.Address{}) {
+			continue
+		}
+		r := new(json.RawMessage)
+		receipts = append(receipts, r)
+		elems = append(elems, ethrpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []any{tx.Hash}, Result: r})
+	}
+	if len(elems) > 0 {
+		err := rpc.BatchCallContext(ctx, elems)
+		for _, e := range elems {
+			if err == nil {
+				err = e.Error
+			}
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("Unable to get the receipts of the block")
+			v.count(func() { v.failed++ })
+			return
+		}
+	}
+	root, err := getReceiptsRoot(receipts)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to encode the receipts of the block")
+		v.count(func() { v.failed++ })
+		return
+	}
+	v.check(root == block.ReceiptsRoot, readMismatchReceiptsRoot, call, "The receipts of the block don't match its receipts root")
+}
+
+func (v *readValidator) count(f func()) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	f()
+}
+
+func (v *readValidator) check(ok bool, kind string, call readCall, msg string) {
+	v.lock.Lock()
+	v.checked++
+	if !ok {
+		v.mismatches[kind]++
+	}
+	v.lock.Unlock()
+	if !ok {
+		args, _ := json.Marshal(call.Args)
+		log.Warn().Str("method", call.Method).RawJSON("args", args).Str("check", kind).Msg(msg)
+	}
+}
+
+// print logs the number of checks and the mismatches of every kind. It's a
+// warning if anything mismatched.
+func (v *readValidator) print() {
+	if v == nil {
+		return
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	mismatches := 0
+	for _, n := range v.mismatches {
+		mismatches += n
+	}
+	event := log.Info()
+	if mismatches > 0 {
+		event = log.Warn()
+	}
+	for _, kind := range []string{readMismatchReference, readMismatchParentHash, readMismatchReceiptsRoot} {
+		event = event.Int(kind, v.mismatches[kind])
+	}
+	event.Int("checked", v.checked).Int("skipped", v.skipped).Int("failed", v.failed).Int("mismatches", mismatches).Msg("Read validation")
+}
+
+// hasBlockTag is true if any of the arguments is a block tag that depends on
+// the head of the endpoint.
+func hasBlockTag(args []any) bool {
+	for _, arg := range args {
+		switch arg {
+		case "latest", "pending", "safe", "finalized":
+			return true
+		}
+	}
+	return false
+}
+
+// equalSharedFields compares the results, ignoring fields of objects that
+// only one of them has, since clients return different extra fields.
+func equalSharedFields(a, b json.RawMessage) (bool, error) {
+	var av, bv any
+	if err := json.Unmarshal(a, &av); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		return false, err
+	}
+	return equalShared(av, bv), nil
+}
+
+func equalShared(a, b any) bool {
+	switch at := a.(type) {
+	case map[string]any:
+		bt, ok := b.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, av := range at {
+			if bv, ok := bt[k]; ok && !equalShared(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bt, ok := b.([]any)
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !equalShared(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// encodedReceipts are the consensus encodings of the receipts of a block.
+type encodedReceipts [][]byte
+
+func (r encodedReceipts) Len() int { return len(r) }
+
+func (r encodedReceipts) EncodeIndex(i int, w *bytes.Buffer) {
+	w.Write(r[i])
+}
+
+// getReceiptsRoot computes the receipts root from the JSON receipts. They're
+// encoded here rather than with go-ethereum, which leaves out the receipts of
+// transaction types it doesn't know.
+func getReceiptsRoot(raw []*json.RawMessage) (ethcommon.Hash, error) {
+	encoded := make(encodedReceipts, len(raw))
+	for i, r := range raw {
+		var receipt struct {
+			Type              hexutil.Uint64  `json:"type"`
+			Root              hexutil.Bytes   `json:"root"`
+			Status            *hexutil.Uint64 `json:"status"`
+			CumulativeGasUsed hexutil.Uint64  `json:"cumulativeGasUsed"`
+			LogsBloom         ethtypes.Bloom  `json:"logsBloom"`
+			Logs              []*ethtypes.Log `json:"logs"`
+		}
+		if err := json.Unmarshal(*r, &receipt); err != nil {
+			return ethcommon.Hash{}, err
+		}
+		// Receipts before Byzantium have the state root instead of the
+		// status
+		postState := []byte(receipt.Root)
+		if receipt.Status != nil && *receipt.Status == 1 {
+			postState = []byte{1}
+		} else if receipt.Status != nil {
+			postState = []byte{}
+		}
+		payload, err := rlp.EncodeToBytes([]any{postState, uint64(receipt.CumulativeGasUsed), receipt.LogsBloom, receipt.Logs})
+		if err != nil {
+			return ethcommon.Hash{}, err
+		}
+		if receipt.Type != ethtypes.LegacyTxType {
+			payload = append([]byte{byte(receipt.Type)}, payload...)
+		}
+		encoded[i] = payload
+	}
+	return ethtypes.DeriveSha(encoded, trie.NewStackTrie(nil)), nil
+}
@@ -0,0 +1,228 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/multicall"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/rs/zerolog/log"
+)
+
+// The recipient strategies decide which addresses the transfer modes send to.
+// They grow the state in very different ways: a fixed address or a pool only
+// updates existing accounts, while random and seed derived addresses create a
+// new account with almost every transfer.
+const (
+	recipientsFixed  = "fixed"
+	recipientsRandom = "random"
+	recipientsPool   = "pool"
+	recipientsSeed   = "seed"
+)
+
+var recipientPool []ethcommon.Address
+
+// validateRecipients checks the recipient flags. --to-random is kept as a
+// shorthand for the random strategy.
+func validateRecipients() error {
+	ltp := inputLoadTestParams
+	if *ltp.ToRandom {
+		*ltp.Recipients = recipientsRandom
+	}
+	switch *ltp.Recipients {
+	case recipientsFixed, recipientsRandom, recipientsSeed:
+	case recipientsPool:
+		if *ltp.RecipientPoolSize == 0 {
+			return fmt.Errorf("the recipient pool size needs to be non-zero positive")
+		}
+	default:
+		return fmt.Errorf("the recipient strategy %s is not supported, expected fixed, random, pool, or seed", *ltp.Recipients)
+	}
+	return nil
+}
+
+// getRecipient returns the address a transfer goes to. Random addresses come
+// from the random source of the request, and the pool and seed addresses from
+// the index of the recipient, so they don't depend on the order the workers
+// sent the requests in either.
+func getRecipient(rng *rand.Rand, index uint64) *ethcommon.Address {
+	ltp := inputLoadTestParams
+	switch *ltp.Recipients {
+	case recipientsRandom:
+		return getRandomAddress(rng)
+	case recipientsPool:
+		return &recipientPool[index%uint64(len(recipientPool))]
+	case recipientsSeed:
+		addr := deriveRecipient(*ltp.Seed, index)
+		return &addr
+	default:
+		return ltp.ToETHAddress
+	}
+}
+
+// recipientIndex returns the index of the i-th of the count recipients of the
+// request with the nonce.
+func recipientIndex(nonce uint64, i int, count uint64) uint64 {
+	return requestPosition(nonce)*count + uint64(i)
+}
+
+// deriveRecipient returns the address at the index for the seed. The same seed
+// gives the same addresses on every run, so a later run can send to the
+// accounts created by an earlier one.
+func deriveRecipient(seed int64, index uint64) ethcommon.Address {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(seed))
+	binary.BigEndian.PutUint64(b[8:], index)
+	return ethcommon.BytesToAddress(ethcrypto.Keccak256(b)[12:])
+}
+
+// setupRecipientPool derives the addresses of the pool and funds the ones
+// that don't have a balance yet, so the load test only sends to existing
+// accounts. It waits until the funding transactions are included, since the
+// load test starts from the confirmed nonce.
+func setupRecipientPool(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) error {
+	ltp := inputLoadTestParams
+	size := *ltp.RecipientPoolSize
+	recipientPool = make([]ethcommon.Address, size)
+	for i := range recipientPool {
+		recipientPool[i] = deriveRecipient(*ltp.Seed, uint64(i))
+	}
+	if *ltp.CallOnly {
+		return nil
+	}
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	tops, err := signer.NewTransactOpts(ctx, ltp.Signer, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return err
+	}
+	nonce, err := c.PendingNonceAt(ctx, *ltp.FromETHAddress)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get account nonce")
+		return err
+	}
+	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
+	if gasPrice == nil {
+		return fmt.Errorf("unable to get the gas price to fund the recipient pool")
+	}
+
+	balances, err := getRecipientBalances(ctx, rpc)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the balances of the recipients")
+		return err
+	}
+
+	funded := 0
+	for i := range recipientPool {
+		if balances[i].Sign() > 0 {
+			continue
+		}
+		var tx *ethtypes.Transaction
+		if *ltp.LegacyTransactionMode {
+			tx = ethtypes.NewTx(&ethtypes.LegacyTx{
+				Nonce:    nonce,
+				To:       &recipientPool[i],
+				Value:    ltp.SendAmount,
+				Gas:      21000,
+				GasPrice: gasPrice,
+			})
+		} else {
+			tx = ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+				ChainID:   chainID,
+				Nonce:     nonce,
+				To:        &recipientPool[i],
+				Gas:       21000,
+				GasFeeCap: gasPrice,
+				GasTipCap: gasTipCap,
+				Value:     ltp.SendAmount,
+			})
+		}
+		stx, err := tops.Signer(*ltp.FromETHAddress, tx)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to sign transaction")
+			return err
+		}
+		if err = c.SendTransaction(ctx, stx); err != nil {
+			log.Error().Err(err).Str("recipient", recipientPool[i].String()).Msg("Unable to fund the recipient")
+			return err
+		}
+		nonce++
+		funded++
+	}
+	log.Info().Uint64("size", size).Int("funded", funded).Msg("Set up the recipient pool")
+	if funded == 0 {
+		return nil
+	}
+
+	return blockUntilSuccessful(ctx, c, func() error {
+		confirmed, err := c.NonceAt(ctx, *ltp.FromETHAddress, nil)
+		if err != nil {
+			return err
+		}
+		if confirmed < nonce {
+			return fmt.Errorf("%d of the funding transactions are still pending", nonce-confirmed)
+		}
+		return nil
+	})
+}
+
+// getRecipientBalances reads the balances of the pool through Multicall3 where
+// it's deployed, or in batches of eth_getBalance otherwise, rather than one
+// request per recipient.
+func getRecipientBalances(ctx context.Context, rpc *ethrpc.Client) ([]*big.Int, error) {
+	reader, err := multicall.NewReader(ctx, rpc, *inputLoadTestParams.MulticallAddress, 0)
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]*big.Int, len(recipientPool))
+
+	if reader.Deployed() {
+		calls := make([]multicall.Call, 0, len(recipientPool))
+		for _, recipient := range recipientPool {
+			call, err := reader.EthBalance(recipient)
+			if err != nil {
+				return nil, err
+			}
+			calls = append(calls, call)
+		}
+		results, err := reader.Call(ctx, "latest", calls)
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range results {
+			if balances[i], err = multicall.DecodeBig(r); err != nil {
+				return nil, fmt.Errorf("unable to read the balance of %s: %w", recipientPool[i], err)
+			}
+		}
+		return balances, nil
+	}
+
+	raw := make([]hexutil.Big, len(recipientPool))
+	for start := 0; start < len(recipientPool); start += multicall.DefaultBatchSize {
+		end := min(start+multicall.DefaultBatchSize, len(recipientPool))
+		batch := make([]ethrpc.BatchElem, 0, end-start)
+		for i := start; i < end; i++ {
+			batch = append(batch, ethrpc.BatchElem{Method: "eth_getBalance", Args: []interface{}{recipientPool[i], "latest"}, Result: &raw[i]})
+		}
+		if err = rpc.BatchCallContext(ctx, batch); err != nil {
+			return nil, err
+		}
+		for i, elem := range batch {
+			if elem.Error != nil {
+				return nil, fmt.Errorf("unable to read the balance of %s: %w", recipientPool[start+i], elem.Error)
+			}
+			balances[start+i] = raw[start+i].ToInt()
+		}
+	}
+	return balances, nil
+}
@@ -0,0 +1,285 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sendViaPublic sends the transactions with eth_sendRawTransaction.
+	sendViaPublic = "public"
+	// sendViaPrivate sends each transaction with eth_sendPrivateTransaction.
+	sendViaPrivate = "private"
+	// sendViaBundle groups the transactions in bundles sent with
+	// eth_sendBundle.
+	sendViaBundle = "bundle"
+)
+
+// relay is where the load test transactions are sent when they aren't sent
+// to the public mempool. It's nil when they are.
+var relay *privateRelay
+
+// privateRelay sends transactions to a Flashbots style relay. The requests
+// are signed with the relay key in the X-Flashbots-Signature header.
+type privateRelay struct {
+	url         string
+	method      string
+	key         *ecdsa.PrivateKey
+	client      *http.Client
+	ec          *ethclient.Client
+	bundleSize  int
+	blockOffset uint64
+	blockCount  uint64
+	requestID   atomic.Uint64
+
+	// pending holds the transactions of the next bundles by nonce. Bundles
+	// are only sent with consecutive nonces, since a bundle with a nonce gap
+	// can't be included.
+	mu        sync.Mutex
+	pending   map[uint64]*ethtypes.Transaction
+	nextNonce uint64
+}
+
+func newPrivateRelay(ec *ethclient.Client, method, url, keyHex string, bundleSize int, blockOffset, blockCount uint64) (*privateRelay, error) {
+	if bundleSize < 1 {
+		return nil, fmt.Errorf("the bundle size needs to be at least one")
+	}
+	if blockCount < 1 {
+		return nil, fmt.Errorf("the bundle needs to target at least one block")
+	}
+
+	var key *ecdsa.PrivateKey
+	var err error
+	if keyHex == "" {
+		// The relay key only identifies the sender for the relay's
+		// reputation system, so a throwaway key works.
+		key, err = ethcrypto.GenerateKey()
+	} else {
+		key, err = ethcrypto.HexToECDSA(keyHex)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid relay key: %w", err)
+	}
+
+	return &privateRelay{
+		url:         url,
+		method:      method,
+		key:         key,
+		client:      loadTestHTTPClient,
+		ec:          ec,
+		bundleSize:  bundleSize,
+		blockOffset: blockOffset,
+		blockCount:  blockCount,
+		pending:     make(map[uint64]*ethtypes.Transaction),
+	}, nil
+}
+
+// start sets the nonce of the first transaction of the load test.
+func (r *privateRelay) start(nonce uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextNonce = nonce
+}
+
+// send sends the transaction privately, or adds it to the next bundle and
+// sends the bundle once it's full.
+func (r *privateRelay) send(ctx context.Context, tx *ethtypes.Transaction) error {
+	if r.method == sendViaPrivate {
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return r.call(ctx, "eth_sendPrivateTransaction", map[string]any{"tx": hexutil.Encode(raw)})
+	}
+
+	r.mu.Lock()
+	r.pending[tx.Nonce()] = tx
+	var bundles [][]*ethtypes.Transaction
+	for {
+		bundle := r.takeBundle(r.bundleSize)
+		if bundle == nil {
+			break
+		}
+		bundles = append(bundles, bundle)
+	}
+	r.mu.Unlock()
+
+	for _, bundle := range bundles {
+		if err := r.sendBundle(ctx, bundle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush sends the transactions that didn't fill a bundle.
+func (r *privateRelay) flush(ctx context.Context) error {
+	if r.method != sendViaBundle {
+		return nil
+	}
+
+	r.mu.Lock()
+	bundle := r.takeBundle(len(r.pending))
+	if len(r.pending) > 0 {
+		log.Warn().Int("transactions", len(r.pending)).Uint64("nonce", r.nextNonce).Msg("Transactions after a nonce gap weren't bundled")
+	}
+	r.mu.Unlock()
+
+	if bundle == nil {
+		return nil
+	}
+	return r.sendBundle(ctx, bundle)
+}
+
+// takeBundle removes and returns size pending transactions with consecutive
+// nonces starting at the next nonce, or nil if there aren't enough. It must be
+// called with the lock held.
+func (r *privateRelay) takeBundle(size int) []*ethtypes.Transaction {
+	if size == 0 {
+		return nil
+	}
+	for i := 0; i < size; i++ {
+		if _, ok := r.pending[r.nextNonce+uint64(i)]; !ok {
+			return nil
+		}
+	}
+
+	bundle := make([]*ethtypes.Transaction, 0, size)
+	for i := 0; i < size; i++ {
+		bundle = append(bundle, r.pending[r.nextNonce])
+		delete(r.pending, r.nextNonce)
+		r.nextNonce++
+	}
+	return bundle
+}
+
+// sendBundle sends the bundle for each of the target blocks, so it has more
+// than one chance to be included.
+func (r *privateRelay) sendBundle(ctx context.Context, bundle []*ethtypes.Transaction) error {
+	sort.Slice(bundle, func(i, j int) bool {
+		return bundle[i].Nonce() < bundle[j].Nonce()
+	})
+	txs := make([]string, 0, len(bundle))
+	for _, tx := range bundle {
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		txs = append(txs, hexutil.Encode(raw))
+	}
+
+	blockNumber, err := r.ec.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	target := blockNumber + r.blockOffset
+	for i := uint64(0); i < r.blockCount; i++ {
+		err = r.call(ctx, "eth_sendBundle", map[string]any{
+			"txs":         txs,
+			"blockNumber": hexutil.EncodeUint64(target + i),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Trace().Int("transactions", len(txs)).Uint64("targetBlock", target).Uint64("blocks", r.blockCount).Msg("Sent bundle")
+	return nil
+}
+
+// call sends a JSON-RPC request signed with the relay key.
+func (r *privateRelay) call(ctx context.Context, method string, params ...any) error {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      r.requestID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	hash := ethcrypto.Keccak256Hash(body).Hex()
+	sig, err := ethcrypto.Sign(accounts.TextHash([]byte(hash)), r.key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Flashbots-Signature", ethcrypto.PubkeyToAddress(r.key.PublicKey).Hex()+":"+hexutil.Encode(sig))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("relay returned %s: %s", resp.Status, data)
+	}
+
+	var result struct {
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("unable to decode the relay response: %w", err)
+	}
+	if result.Error != nil {
+		return fmt.Errorf("relay error %d: %s", result.Error.Code, result.Error.Message)
+	}
+	return nil
+}
+
+// sendTransaction sends the transaction to the relay if there is one, and to
+// the RPC endpoint otherwise.
+func sendTransaction(ctx context.Context, c *ethclient.Client, tx *ethtypes.Transaction) error {
+	if relay != nil {
+		return relay.send(ctx, tx)
+	}
+	return c.SendTransaction(ctx, tx)
+}
+
+// relayBackend is a contract backend which sends the transactions of the
+// bound contracts with sendTransaction.
+type relayBackend struct {
+	*ethclient.Client
+}
+
+func (b relayBackend) SendTransaction(ctx context.Context, tx *ethtypes.Transaction) error {
+	return sendTransaction(ctx, b.Client, tx)
+}
+
+// contractBackend returns the backend to bind the contracts used by the load
+// test transactions with.
+func contractBackend(c *ethclient.Client) bind.ContractBackend {
+	if relay == nil {
+		return c
+	}
+	return relayBackend{c}
+}
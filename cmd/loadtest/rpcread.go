@@ -0,0 +1,279 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// The read calls of the read modes. They're sent as raw requests, so the
+// latencies don't include decoding the responses.
+const (
+	rpcReadGetBalance       = "eth_getBalance"
+	rpcReadGetStorageAt     = "eth_getStorageAt"
+	rpcReadCall             = "eth_call"
+	rpcReadGetLogs          = "eth_getLogs"
+	rpcReadGetBlockByNumber = "eth_getBlockByNumber"
+)
+
+// rpcReadWeight is a method of the mix and how often it's picked relative to
+// the other methods.
+type rpcReadWeight struct {
+	Method string
+	Weight uint64
+}
+
+// readMix is a weighted mix of read methods.
+type readMix struct {
+	weights []rpcReadWeight
+	total   uint64
+}
+
+// readCall is a read call and the key its latency is grouped by.
+type readCall struct {
+	Method string
+	Args   []any
+	Key    string
+}
+
+// rpcReadStat holds the latencies and errors of a method or another group of
+// calls.
+type rpcReadStat struct {
+	Latencies []time.Duration
+	Errors    int
+}
+
+// readStats groups the latencies of the calls by a key, e.g. the method.
+type readStats struct {
+	lock  sync.Mutex
+	keys  []string
+	stats map[string]*rpcReadStat
+}
+
+var (
+	rpcReadMix   *readMix
+	rpcReadStats *readStats
+)
+
+// parseReadMix parses a mix like "eth_getBalance:3,eth_call:1" of the
+// supported methods. A method without a weight has a weight of one.
+func parseReadMix(mix string, supported ...string) (*readMix, error) {
+	m := new(readMix)
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(mix, ",") {
+		method, weightStr, hasWeight := strings.Cut(strings.TrimSpace(entry), ":")
+		if !contains(supported, method) {
+			return nil, fmt.Errorf("the read method %s is not supported, expected one of %s", method, strings.Join(supported, ", "))
+		}
+		if seen[method] {
+			return nil, fmt.Errorf("the read method %s is in the mix more than once", method)
+		}
+		seen[method] = true
+		weight := uint64(1)
+		if hasWeight {
+			var err error
+			weight, err = strconv.ParseUint(weightStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse the weight of the read method %s: %w", method, err)
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+		m.weights = append(m.weights, rpcReadWeight{Method: method, Weight: weight})
+		m.total += weight
+	}
+	if m.total == 0 {
+		return nil, fmt.Errorf("the read mix needs at least one method with a non-zero weight")
+	}
+	return m, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// methods returns the methods of the mix in order.
+func (m *readMix) methods() []string {
+	methods := make([]string, len(m.weights))
+	for i, w := range m.weights {
+		methods[i] = w.Method
+	}
+	return methods
+}
+
+func (m *readMix) pick(rng *rand.Rand) string {
+	pick := rng.Uint64() % m.total
+	for _, w := range m.weights {
+		if pick < w.Weight {
+			return w.Method
+		}
+		pick -= w.Weight
+	}
+	return ""
+}
+
+func newReadStats(keys []string) *readStats {
+	s := &readStats{keys: keys, stats: make(map[string]*rpcReadStat, len(keys))}
+	for _, k := range keys {
+		s.stats[k] = new(rpcReadStat)
+	}
+	return s
+}
+
+func (s *readStats) record(key string, latency time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stat := s.stats[key]
+	stat.Latencies = append(stat.Latencies, latency)
+	if err != nil {
+		stat.Errors++
+	}
+}
+
+// print logs the number of calls, errors, and latency percentiles of every
+// key with calls.
+func (s *readStats) print(field, msg string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, k := range s.keys {
+		stat := s.stats[k]
+		if len(stat.Latencies) == 0 {
+			continue
+		}
+		sort.Slice(stat.Latencies, func(i, j int) bool {
+			return stat.Latencies[i] < stat.Latencies[j]
+		})
+		percentiles := getLatencyPercentiles(stat.Latencies)
+		log.Info().
+			Str(field, k).
+			Int("calls", len(stat.Latencies)).
+			Int("errors", stat.Errors).
+			Float64("p50", percentiles.P50).
+			Float64("p90", percentiles.P90).
+			Float64("p95", percentiles.P95).
+			Float64("p99", percentiles.P99).
+			Float64("max", stat.Latencies[len(stat.Latencies)-1].Seconds()).
+			Msg(msg)
+	}
+}
+
+// setupRPCRead parses the mix of rpc read mode.
+func setupRPCRead() error {
+	var err error
+	rpcReadMix, err = parseReadMix(*inputLoadTestParams.RPCReadMix, rpcReadGetBalance, rpcReadCall, rpcReadGetLogs, rpcReadGetBlockByNumber)
+	if err != nil {
+		return err
+	}
+	rpcReadStats = newReadStats(rpcReadMix.methods())
+	return nil
+}
+
+// getBalanceOfCall returns the arguments of an eth_call of balanceOf(address)
+// of our account on a token, or any contract we've seen being called, at the
+// block.
+func getBalanceOfCall(rng *rand.Rand, ia *IndexedActivity, block string) []any {
+	ltp := inputLoadTestParams
+	contracts := ia.ERC20Addresses
+	if len(contracts) == 0 {
+		contracts = ia.Contracts
+	}
+	to := ltp.FromETHAddress.String()
+	if len(contracts) > 0 {
+		to = contracts[rng.Intn(len(contracts))]
+	}
+	data := append(ethcrypto.Keccak256([]byte("balanceOf(address)"))[:4], ethcommon.LeftPadBytes(ltp.FromETHAddress.Bytes(), 32)...)
+	return []any{map[string]any{"to": to, "data": hexutil.Bytes(data)}, block}
+}
+
+// getActiveAddress returns one of the addresses of the recent activity, or
+// ours if there wasn't any.
+func getActiveAddress(rng *rand.Rand, ia *IndexedActivity) string {
+	if len(ia.Addresses) == 0 {
+		return inputLoadTestParams.FromETHAddress.String()
+	}
+	return ia.Addresses[rng.Intn(len(ia.Addresses))]
+}
+
+// callRead sends the read call, decodes the result into it, and returns its
+// latency.
+func callRead(ctx context.Context, rpc *ethrpc.Client, method string, args []any, result *json.RawMessage) (t1 time.Time, t2 time.Time, err error) {
+	t1 = time.Now()
+	err = rpc.CallContext(ctx, result, method, args...)
+	t2 = time.Now()
+	err = getReadError(method, err)
+	log.Trace().Str("method", method).Err(err).Dur("latency", t2.Sub(t1)).Msg("Read call")
+	return
+}
+
+// getReadError drops the errors of calls the node served. A contract
+// reverting is still a call the node executed.
+func getReadError(method string, err error) error {
+	if err != nil && method == rpcReadCall && strings.Contains(err.Error(), "execution reverted") {
+		return nil
+	}
+	return err
+}
+
+// loadTestRead sends the read call of the request and records its latency
+// in the stats of the mode, then validates the result if enabled. When
+// transports are compared, the same calls are sent over each of them
+// instead.
+func loadTestRead(ctx context.Context, rpc *ethrpc.Client, nonce uint64, ia *IndexedActivity, getCall func(*rand.Rand, *IndexedActivity) readCall, stats *readStats) (t1 time.Time, t2 time.Time, err error) {
+	rng := newRequestRand(nonce, randStreamRequest)
+	if len(readTransports) > 0 {
+		return compareReadTransports(ctx, nonce, rng, ia, getCall, stats)
+	}
+	call := getCall(rng, ia)
+	var result json.RawMessage
+	t1, t2, err = callRead(ctx, rpc, call.Method, call.Args, &result)
+	stats.record(call.Key, t2.Sub(t1), err)
+	if err == nil && readValidation != nil {
+		readValidation.validate(ctx, rpc, call, result)
+	}
+	return
+}
+
+// getRPCReadCall picks a read call of the mix. The addresses, contracts,
+// and blocks it reads come from the recent activity of the chain.
+func getRPCReadCall(rng *rand.Rand, ia *IndexedActivity) readCall {
+	ltp := inputLoadTestParams
+	method := rpcReadMix.pick(rng)
+	var args []any
+	switch method {
+	case rpcReadGetBalance:
+		args = []any{getActiveAddress(rng, ia), "latest"}
+	case rpcReadCall:
+		args = getBalanceOfCall(rng, ia, "latest")
+	case rpcReadGetLogs:
+		to := rng.Uint64() % (ia.BlockNumber + 1)
+		from := uint64(0)
+		if to >= *ltp.RPCReadLogsRange {
+			from = to - *ltp.RPCReadLogsRange + 1
+		}
+		args = []any{map[string]any{"fromBlock": hexutil.EncodeUint64(from), "toBlock": hexutil.EncodeUint64(to)}}
+	case rpcReadGetBlockByNumber:
+		args = []any{hexutil.EncodeUint64(rng.Uint64() % (ia.BlockNumber + 1)), true}
+	}
+	return readCall{Method: method, Args: args, Key: method}
+}
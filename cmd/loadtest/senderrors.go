@@ -0,0 +1,117 @@
+package loadtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+)
+
+// The classes of the errors of the requests. The policy of the class decides
+// what happens to the nonce of a failed transaction.
+const (
+	sendErrorNonceTooLow       = "nonce-too-low"
+	sendErrorUnderpriced       = "underpriced"
+	sendErrorInsufficientFunds = "insufficient-funds"
+	sendErrorTxPoolFull        = "txpool-full"
+	sendErrorConnection        = "connection"
+	sendErrorOther             = "other"
+)
+
+// The policies of the error classes.
+const (
+	// sendErrorRetry sends the next request with the same nonce, so there's
+	// no gap in the nonces.
+	sendErrorRetry = "retry"
+	// sendErrorSkip moves on to the next nonce. It's for errors where the
+	// nonce was already used, or a transaction with it is in the pool.
+	sendErrorSkip = "skip"
+	// sendErrorAbort stops the load test, since the next requests would
+	// fail the same way.
+	sendErrorAbort = "abort"
+)
+
+var (
+	sendErrorClasses = []string{sendErrorNonceTooLow, sendErrorUnderpriced, sendErrorInsufficientFunds, sendErrorTxPoolFull, sendErrorConnection, sendErrorOther}
+	// sendErrorPolicies are the policies of the classes, with the defaults
+	// overridden by --error-policy.
+	sendErrorPolicies map[string]string
+)
+
+// setupSendErrorPolicies parses a list of policies like
+// "insufficient-funds:abort,txpool-full:retry".
+func setupSendErrorPolicies() error {
+	sendErrorPolicies = map[string]string{
+		sendErrorNonceTooLow:       sendErrorSkip,
+		sendErrorUnderpriced:       sendErrorSkip,
+		sendErrorInsufficientFunds: sendErrorRetry,
+		sendErrorTxPoolFull:        sendErrorRetry,
+		sendErrorConnection:        sendErrorRetry,
+		sendErrorOther:             sendErrorRetry,
+	}
+	policies := strings.TrimSpace(*inputLoadTestParams.ErrorPolicy)
+	if policies == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(policies, ",") {
+		class, policy, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return fmt.Errorf("the error policy %s needs to be a class and a policy, e.g. txpool-full:retry", entry)
+		}
+		if !contains(sendErrorClasses, class) {
+			return fmt.Errorf("the error class %s is not supported, expected one of %s", class, strings.Join(sendErrorClasses, ", "))
+		}
+		if policy != sendErrorRetry && policy != sendErrorSkip && policy != sendErrorAbort {
+			return fmt.Errorf("the error policy %s is not supported, expected retry, skip, or abort", policy)
+		}
+		sendErrorPolicies[class] = policy
+	}
+	return nil
+}
+
+// classifySendError returns the class of the error of a request, going by
+// the messages of the common clients.
+func classifySendError(err error) string {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "nonce too low"):
+		return sendErrorNonceTooLow
+	// This covers replacement transaction underpriced too
+	case strings.Contains(msg, "underpriced"):
+		return sendErrorUnderpriced
+	case strings.Contains(msg, "insufficient funds"):
+		return sendErrorInsufficientFunds
+	case strings.Contains(msg, "txpool is full"), strings.Contains(msg, "transaction pool is full"), strings.Contains(msg, "tx pool is full"):
+		return sendErrorTxPoolFull
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		strings.Contains(msg, "connection reset"), strings.Contains(msg, "connection refused"), strings.Contains(msg, "broken pipe"):
+		return sendErrorConnection
+	}
+	return sendErrorOther
+}
+
+// getSendErrorPolicy returns the policy of the class. The nonces aren't
+// retried in call only mode, where they index the calls rather than
+// transactions that could be retried.
+func getSendErrorPolicy(class string) string {
+	policy := sendErrorPolicies[class]
+	if policy == sendErrorRetry && *inputLoadTestParams.CallOnly {
+		return sendErrorSkip
+	}
+	return policy
+}
+
+// countSendErrors returns the number of failed requests of every class.
+func countSendErrors() map[string]int {
+	loadTestResutsMutex.RLock()
+	defer loadTestResutsMutex.RUnlock()
+	counts := make(map[string]int)
+	for _, s := range loadTestResults {
+		if s.IsError {
+			counts[s.ErrorClass]++
+		}
+	}
+	return counts
+}
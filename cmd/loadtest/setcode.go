@@ -0,0 +1,165 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// setCodeAuthGas is what each authorization costs when the authority
+	// account is empty, which is the worst case.
+	setCodeAuthGas = 25000
+	// setCodeCallGas covers the call to the first authority, which runs the
+	// increment of the load test contract when it's delegated to it.
+	setCodeCallGas = 80000
+)
+
+var (
+	setCodeAuthorityKeys   []*ecdsa.PrivateKey
+	setCodeAuthorityNonces []uint64
+	// setCodeStartNonce is our nonce when the load test starts. The
+	// authorizations of a transaction are picked from its position after it,
+	// so the authorizations of every authority are included in nonce order
+	// no matter which worker sends them.
+	setCodeStartNonce uint64
+)
+
+// setupSetCode derives the authority keys from the seed and gets their
+// nonces. The authorities don't need to be funded, we pay for their
+// authorizations.
+func setupSetCode(ctx context.Context, c *ethclient.Client) error {
+	ltp := inputLoadTestParams
+	size := *ltp.SetCodeAuthorities
+	setCodeAuthorityKeys = make([]*ecdsa.PrivateKey, size)
+	setCodeAuthorityNonces = make([]uint64, size)
+	for i := range setCodeAuthorityKeys {
+		b := make([]byte, 24)
+		copy(b, "set code")
+		binary.BigEndian.PutUint64(b[8:], uint64(*ltp.Seed))
+		binary.BigEndian.PutUint64(b[16:], uint64(i))
+		key, err := ethcrypto.ToECDSA(ethcrypto.Keccak256(b))
+		if err != nil {
+			return err
+		}
+		setCodeAuthorityKeys[i] = key
+		setCodeAuthorityNonces[i], err = c.NonceAt(ctx, ethcrypto.PubkeyToAddress(key.PublicKey), nil)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to get the nonce of the authority")
+			return err
+		}
+	}
+	nonce, err := c.PendingNonceAt(ctx, *ltp.FromETHAddress)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get account nonce")
+		return err
+	}
+	setCodeStartNonce = nonce
+	log.Info().Uint64("authorities", size).Msg("Set up the set code authorities")
+	return nil
+}
+
+// getSetCodeAuthorizations returns the authorizations of the transaction with
+// the nonce. The authorities are used in turn, and each one alternates
+// between delegating to the address and clearing its delegation. It also
+// returns the first authority, which the transaction calls.
+func getSetCodeAuthorizations(chainID *big.Int, nonce uint64, delegate ethcommon.Address) ([]signer.SetCodeAuthorization, ethcommon.Address, error) {
+	ltp := inputLoadTestParams
+	count := *ltp.SetCodeAuthCount
+	size := uint64(len(setCodeAuthorityKeys))
+	var position uint64
+	if nonce > setCodeStartNonce {
+		position = nonce - setCodeStartNonce
+	}
+
+	auths := make([]signer.SetCodeAuthorization, 0, count)
+	var first ethcommon.Address
+	for i := uint64(0); i < count; i++ {
+		use := position*count + i
+		index := use % size
+		// How many times the authority was used before this one
+		round := use / size
+		address := delegate
+		if round%2 == 1 {
+			address = ethcommon.Address{}
+		}
+		key := setCodeAuthorityKeys[index]
+		auth, err := signer.SignSetCodeAuthorization(key, chainID, address, setCodeAuthorityNonces[index]+round)
+		if err != nil {
+			return nil, ethcommon.Address{}, err
+		}
+		if i == 0 {
+			first = ethcrypto.PubkeyToAddress(key.PublicKey)
+		}
+		auths = append(auths, auth)
+	}
+	return auths, first, nil
+}
+
+// loadTestSetCode sends an EIP-7702 transaction with authorizations that set
+// and clear delegations, and calls the first authority. When it was just
+// delegated to the load test contract, the call runs its increment function
+// in the authority account.
+func loadTestSetCode(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, nonce uint64, ltAddr ethcommon.Address) (t1 time.Time, t2 time.Time, err error) {
+	ltp := inputLoadTestParams
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	delegate := ltAddr
+	var data []byte
+	if *ltp.SetCodeDelegate != "" {
+		delegate = ethcommon.HexToAddress(*ltp.SetCodeDelegate)
+	} else {
+		data = ethcrypto.Keccak256([]byte("inc()"))[:4]
+	}
+
+	auths, to, err := getSetCodeAuthorizations(chainID, nonce, delegate)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign the authorizations")
+		return
+	}
+
+	var gas uint64
+	if ltp.ForceGasLimit != nil {
+		gas = *ltp.ForceGasLimit
+	}
+	if gas == 0 {
+		gas = setCodeCallGas + setCodeAuthGas*uint64(len(auths))
+	}
+	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
+	tx := &signer.SetCodeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasPrice,
+		Gas:       gas,
+		To:        to,
+		Value:     big.NewInt(0),
+		Data:      data,
+		AuthList:  auths,
+	}
+	raw, hash, err := signer.SignSetCodeTx(tx, ltp.ECDSAPrivateKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign transaction")
+		return
+	}
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	var sentHash ethcommon.Hash
+	err = rpc.CallContext(ctx, &sentHash, "eth_sendRawTransaction", hexutil.Encode(raw))
+	if err == nil && sentHash != hash {
+		err = fmt.Errorf("the node returned the hash %s for the transaction %s", sentHash, hash)
+	}
+	return
+}
@@ -0,0 +1,385 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/maticnetwork/polygon-cli/contracts"
+	"github.com/maticnetwork/polygon-cli/contracts/tokens"
+	"github.com/maticnetwork/polygon-cli/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// loadTestContracts are the contracts needed by the load test modes. The
+// contracts which aren't needed are left nil.
+type loadTestContracts struct {
+	ltAddr         ethcommon.Address
+	lt             *contracts.LoadTester
+	erc20Addr      ethcommon.Address
+	erc20          *tokens.ERC20
+	erc721Addr     ethcommon.Address
+	erc721         *tokens.ERC721
+	callerAddr     ethcommon.Address
+	caller         *contracts.Caller
+	logEmitterAddr ethcommon.Address
+	logEmitter     *contracts.LogEmitter
+	coldAccessAddr ethcommon.Address
+	computeAddr    ethcommon.Address
+	compute        *contracts.ComputeLoop
+	disperseAddr   ethcommon.Address
+	disperse       *contracts.Disperse
+	multisigAddr   ethcommon.Address
+	multisig       *contracts.Multisig
+}
+
+// pendingContract is a contract whose deployment was submitted, and ready
+// returns nil once the contract can be used.
+type pendingContract struct {
+	name    string
+	address ethcommon.Address
+	ready   func() error
+}
+
+// deployContracts deploys the contracts needed by the load test modes, unless
+// their address was given. The deployments don't depend on each other, so
+// they're all submitted first with consecutive nonces and then awaited
+// together, rather than waiting for blocks between each one. Submitting in
+// order means a failed submission can't leave a nonce gap behind the others.
+func deployContracts(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, cops *bind.CallOpts) (*loadTestContracts, error) {
+	ltp := inputLoadTestParams
+	lc := new(loadTestContracts)
+
+	nonce, err := c.PendingNonceAt(ctx, *ltp.FromETHAddress)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get account nonce")
+		return nil, err
+	}
+	nextTransactOpts := func() *bind.TransactOpts {
+		opts := *tops
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+		nonce++
+		return &opts
+	}
+	codeReady := func(address ethcommon.Address) func() error {
+		return func() error {
+			code, err := c.CodeAt(ctx, address, nil)
+			if err != nil {
+				return err
+			}
+			if len(code) == 0 {
+				return fmt.Errorf("contract %s has no code", address)
+			}
+			return nil
+		}
+	}
+
+	var pending []pendingContract
+	modes := ltp.ParsedModes
+	random := hasMode(loadTestModeRandom, modes)
+
+	if anyModeRequiresLoadTestContract(modes) || *ltp.ForceContractDeploy {
+		lc.ltAddr = ethcommon.HexToAddress(*ltp.LtAddress)
+		if *ltp.LtAddress == "" {
+			lc.ltAddr, _, _, err = contracts.DeployLoadTester(nextTransactOpts(), c)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to create the load testing contract. Do you have the right chain id? Do you have enough funds?")
+				return nil, err
+			}
+		}
+		lc.lt, err = contracts.NewLoadTester(lc.ltAddr, c)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to instantiate new contract")
+			return nil, err
+		}
+		pending = append(pending, pendingContract{"load test", lc.ltAddr, func() error {
+			_, err := lc.lt.GetCallCounter(cops)
+			return err
+		}})
+	}
+
+	erc20Deployed := false
+	disperseToken := hasMode(loadTestModeDisperse, modes) && *ltp.DisperseToken
+	if hasMode(loadTestModeERC20, modes) || random || disperseToken {
+		lc.erc20Addr = ethcommon.HexToAddress(*ltp.ERC20Address)
+		if *ltp.ERC20Address == "" {
+			lc.erc20Addr, _, _, err = tokens.DeployERC20(nextTransactOpts(), c, "ERC20TestToken", "T20")
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy ERC20 contract")
+				return nil, err
+			}
+			erc20Deployed = true
+		}
+		lc.erc20, err = tokens.NewERC20(lc.erc20Addr, c)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to instantiate new erc20 contract")
+			return nil, err
+		}
+		pending = append(pending, pendingContract{"erc 20", lc.erc20Addr, func() error {
+			_, err := lc.erc20.BalanceOf(cops, *ltp.FromETHAddress)
+			return err
+		}})
+	}
+
+	erc721Deployed := false
+	if hasMode(loadTestModeERC721, modes) || random {
+		lc.erc721Addr = ethcommon.HexToAddress(*ltp.ERC721Address)
+		if *ltp.ERC721Address == "" {
+			lc.erc721Addr, _, _, err = tokens.DeployERC721(nextTransactOpts(), c)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy ERC721 contract")
+				return nil, err
+			}
+			erc721Deployed = true
+		}
+		lc.erc721, err = tokens.NewERC721(lc.erc721Addr, c)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to instantiate new erc721 contract")
+			return nil, err
+		}
+		pending = append(pending, pendingContract{"erc 721", lc.erc721Addr, func() error {
+			_, err := lc.erc721.BalanceOf(cops, *ltp.FromETHAddress)
+			return err
+		}})
+	}
+
+	if hasMode(loadTestModeCallDepth, modes) {
+		lc.callerAddr = ethcommon.HexToAddress(*ltp.CallerAddress)
+		if *ltp.CallerAddress == "" {
+			lc.callerAddr, _, _, err = contracts.DeployCaller(nextTransactOpts(), c)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy caller contract")
+				return nil, err
+			}
+		}
+		lc.caller = contracts.NewCaller(lc.callerAddr, c)
+		pending = append(pending, pendingContract{"caller", lc.callerAddr, codeReady(lc.callerAddr)})
+	}
+
+	if hasMode(loadTestModeLogs, modes) {
+		lc.logEmitterAddr = ethcommon.HexToAddress(*ltp.LogEmitterAddress)
+		if *ltp.LogEmitterAddress == "" {
+			lc.logEmitterAddr, _, _, err = contracts.DeployLogEmitter(nextTransactOpts(), c)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy log emitter contract")
+				return nil, err
+			}
+		}
+		lc.logEmitter = contracts.NewLogEmitter(lc.logEmitterAddr, c)
+		pending = append(pending, pendingContract{"log emitter", lc.logEmitterAddr, codeReady(lc.logEmitterAddr)})
+	}
+
+	if hasMode(loadTestModeColdAccess, modes) {
+		lc.coldAccessAddr = ethcommon.HexToAddress(*ltp.ColdAccessAddress)
+		if *ltp.ColdAccessAddress == "" {
+			lc.coldAccessAddr, _, err = contracts.DeployColdAccess(nextTransactOpts(), c)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy cold access contract")
+				return nil, err
+			}
+		}
+		pending = append(pending, pendingContract{"cold access", lc.coldAccessAddr, codeReady(lc.coldAccessAddr)})
+	}
+
+	if hasMode(loadTestModeCompute, modes) {
+		lc.computeAddr = ethcommon.HexToAddress(*ltp.ComputeAddress)
+		if *ltp.ComputeAddress == "" {
+			lc.computeAddr, _, _, err = contracts.DeployComputeLoop(nextTransactOpts(), c)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy compute loop contract")
+				return nil, err
+			}
+		}
+		lc.compute = contracts.NewComputeLoop(lc.computeAddr, c)
+		pending = append(pending, pendingContract{"compute loop", lc.computeAddr, codeReady(lc.computeAddr)})
+	}
+
+	// The multisig pays many recipients by delegate calling the disperse
+	// contract, the way Safe wallets use MultiSend.
+	multisigDelegateCall := hasMode(loadTestModeMultisig, modes) && *ltp.MultisigDelegateCall
+	if hasMode(loadTestModeDisperse, modes) || multisigDelegateCall {
+		lc.disperseAddr = ethcommon.HexToAddress(*ltp.DisperseAddress)
+		if *ltp.DisperseAddress == "" {
+			lc.disperseAddr, _, _, err = contracts.DeployDisperse(nextTransactOpts(), c)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy disperse contract")
+				return nil, err
+			}
+		}
+		lc.disperse = contracts.NewDisperse(lc.disperseAddr, c)
+		pending = append(pending, pendingContract{"disperse", lc.disperseAddr, codeReady(lc.disperseAddr)})
+	}
+
+	multisigDeployed := false
+	if hasMode(loadTestModeMultisig, modes) {
+		lc.multisigAddr = ethcommon.HexToAddress(*ltp.MultisigAddress)
+		if *ltp.MultisigAddress == "" {
+			var multisigAddr ethcommon.Address
+			multisigAddr, _, err = contracts.DeployMultisig(nextTransactOpts(), c)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy multisig contract")
+				return nil, err
+			}
+			// The proxy only stores the address, so it doesn't have to wait
+			// for the multisig contract
+			lc.multisigAddr, _, _, err = contracts.DeployMultisigProxy(nextTransactOpts(), c, multisigAddr)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deploy multisig wallet")
+				return nil, err
+			}
+			pending = append(pending, pendingContract{"multisig", multisigAddr, codeReady(multisigAddr)})
+			multisigDeployed = true
+		}
+		lc.multisig = contracts.NewMultisig(lc.multisigAddr, c)
+		pending = append(pending, pendingContract{"multisig wallet", lc.multisigAddr, codeReady(lc.multisigAddr)})
+	}
+
+	if err = waitForContracts(ctx, c, pending); err != nil {
+		return nil, err
+	}
+
+	// Minting depends on the deployments, so it's only done once they're
+	// ready. A new ERC20 is minted, and an existing ERC721 gets one more NFT.
+	if erc20Deployed {
+		if err = mintERC20(ctx, c, lc.erc20, tops, cops); err != nil {
+			return nil, err
+		}
+	}
+	if disperseToken {
+		if err = approveERC20(ctx, c, lc.erc20, lc.disperseAddr, tops, cops); err != nil {
+			return nil, err
+		}
+	}
+	if multisigDeployed {
+		if err = setupMultisig(ctx, c, lc.multisig, lc.multisigAddr, tops); err != nil {
+			return nil, err
+		}
+	}
+	if lc.erc721 != nil && !erc721Deployed {
+		err = blockUntilSuccessful(ctx, c, func() error {
+			_, err := lc.erc721.MintBatch(tops, *ltp.FromETHAddress, new(big.Int).SetUint64(1))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return lc, nil
+}
+
+// rebind binds the contracts to the backend. The setup transactions are sent
+// to the RPC endpoint, while the load test transactions can go to a relay.
+func (lc *loadTestContracts) rebind(backend bind.ContractBackend) (err error) {
+	if lc.lt != nil {
+		if lc.lt, err = contracts.NewLoadTester(lc.ltAddr, backend); err != nil {
+			return err
+		}
+	}
+	if lc.erc20 != nil {
+		if lc.erc20, err = tokens.NewERC20(lc.erc20Addr, backend); err != nil {
+			return err
+		}
+	}
+	if lc.erc721 != nil {
+		if lc.erc721, err = tokens.NewERC721(lc.erc721Addr, backend); err != nil {
+			return err
+		}
+	}
+	if lc.caller != nil {
+		lc.caller = contracts.NewCaller(lc.callerAddr, backend)
+	}
+	if lc.logEmitter != nil {
+		lc.logEmitter = contracts.NewLogEmitter(lc.logEmitterAddr, backend)
+	}
+	if lc.compute != nil {
+		lc.compute = contracts.NewComputeLoop(lc.computeAddr, backend)
+	}
+	if lc.disperse != nil {
+		lc.disperse = contracts.NewDisperse(lc.disperseAddr, backend)
+	}
+	if lc.multisig != nil {
+		lc.multisig = contracts.NewMultisig(lc.multisigAddr, backend)
+	}
+	return nil
+}
+
+// waitForContracts blocks until all the contracts are ready, and returns the
+// first error if any of them isn't.
+func waitForContracts(ctx context.Context, c *ethclient.Client, pending []pendingContract) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(pending))
+	for i, p := range pending {
+		wg.Add(1)
+		go func(i int, p pendingContract) {
+			defer wg.Done()
+			errs[i] = blockUntilSuccessful(ctx, c, p.ready)
+			if errs[i] != nil {
+				log.Error().Err(errs[i]).Str("contract", p.name).Str("address", p.address.String()).Msg("Contract isn't ready")
+				return
+			}
+			log.Debug().Str("contract", p.name).Str("address", p.address.String()).Msg("Obtained contract address")
+		}(i, p)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mintERC20(ctx context.Context, c *ethclient.Client, erc20 *tokens.ERC20, tops *bind.TransactOpts, cops *bind.CallOpts) error {
+	_, err := erc20.Mint(tops, metrics.UnitMegaether)
+	if err != nil {
+		log.Error().Err(err).Msg("There was an error minting ERC20")
+		return err
+	}
+
+	return blockUntilSuccessful(ctx, c, func() error {
+		balance, err := erc20.BalanceOf(cops, *inputLoadTestParams.FromETHAddress)
+		if err != nil {
+			return err
+		}
+		if balance.Uint64() == 0 {
+			return fmt.Errorf("ERC20 Balance is Zero")
+		}
+		return nil
+	})
+}
+
+// approveERC20 lets the spender transfer all of our tokens, unless it already
+// has an allowance.
+func approveERC20(ctx context.Context, c *ethclient.Client, erc20 *tokens.ERC20, spender ethcommon.Address, tops *bind.TransactOpts, cops *bind.CallOpts) error {
+	allowance, err := erc20.Allowance(cops, *inputLoadTestParams.FromETHAddress, spender)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the ERC20 allowance")
+		return err
+	}
+	if allowance.Sign() > 0 {
+		return nil
+	}
+	_, err = erc20.Approve(tops, spender, abi.MaxUint256)
+	if err != nil {
+		log.Error().Err(err).Msg("There was an error approving ERC20")
+		return err
+	}
+
+	return blockUntilSuccessful(ctx, c, func() error {
+		allowance, err := erc20.Allowance(cops, *inputLoadTestParams.FromETHAddress, spender)
+		if err != nil {
+			return err
+		}
+		if allowance.Sign() == 0 {
+			return fmt.Errorf("ERC20 Allowance is Zero")
+		}
+		return nil
+	})
+}
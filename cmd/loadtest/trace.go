@@ -0,0 +1,61 @@
+package loadtest
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+const (
+	traceTransaction   = "debug_traceTransaction"
+	traceBlockByNumber = "debug_traceBlockByNumber"
+	// traceStructLogger is the default tracer, which logs every opcode. It's
+	// used when the request doesn't name a tracer.
+	traceStructLogger = "structLogger"
+)
+
+var (
+	traceMix   *readMix
+	traceStats *readStats
+)
+
+// setupTrace parses the methods and tracers of trace mode.
+func setupTrace() error {
+	ltp := inputLoadTestParams
+	var err error
+	traceMix, err = parseReadMix(*ltp.TraceMix, traceTransaction, traceBlockByNumber)
+	if err != nil {
+		return err
+	}
+	if len(*ltp.TraceTracers) == 0 {
+		return fmt.Errorf("trace mode needs at least one tracer")
+	}
+	keys := make([]string, 0)
+	for _, method := range traceMix.methods() {
+		for _, tracer := range *ltp.TraceTracers {
+			keys = append(keys, method+" "+tracer)
+		}
+	}
+	traceStats = newReadStats(keys)
+	return nil
+}
+
+// getTraceCall picks a trace of a recent transaction or block with one of
+// the tracers. Tracing re-executes the transactions, so it's far heavier
+// than the other read calls.
+func getTraceCall(rng *rand.Rand, ia *IndexedActivity) readCall {
+	tracers := *inputLoadTestParams.TraceTracers
+	method := traceMix.pick(rng)
+	tracer := tracers[rng.Intn(len(tracers))]
+	config := map[string]any{}
+	if tracer != traceStructLogger {
+		config["tracer"] = tracer
+	}
+	var args []any
+	switch method {
+	case traceTransaction:
+		args = []any{ia.TransactionIDs[rng.Intn(len(ia.TransactionIDs))], config}
+	case traceBlockByNumber:
+		args = []any{ia.BlockNumbers[rng.Intn(len(ia.BlockNumbers))], config}
+	}
+	return readCall{Method: method, Args: args, Key: method + " " + tracer}
+}
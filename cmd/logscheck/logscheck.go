@@ -0,0 +1,531 @@
+package logscheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	checkParams struct {
+		RPCURL        string
+		Queries       int
+		Concurrency   int
+		Window        uint64
+		Confirmations uint64
+		MaxRange      uint64
+		MaxAddresses  int
+		BatchSize     int
+		Seed          int64
+	}
+
+	// logKey identifies a log in the chain.
+	logKey struct {
+		BlockNumber uint64
+		TxHash      ethcommon.Hash
+		Index       uint
+	}
+
+	// queryResult is the outcome of one eth_getLogs query compared with the
+	// logs of the receipts.
+	queryResult struct {
+		FromBlock uint64              `json:"fromBlock"`
+		ToBlock   uint64              `json:"toBlock"`
+		Addresses []ethcommon.Address `json:"addresses,omitempty"`
+		Topics    [][]ethcommon.Hash  `json:"topics,omitempty"`
+		Latency   time.Duration       `json:"latency"`
+		Expected  int                 `json:"expected"`
+		Returned  int                 `json:"returned"`
+		// Missing are the logs of the receipts that the query didn't
+		// return, and Extra the logs it returned that aren't in the
+		// receipts or don't match the filter.
+		Missing []logKey `json:"missing,omitempty"`
+		Extra   []logKey `json:"extra,omitempty"`
+		Error   string   `json:"error,omitempty"`
+	}
+
+	// rangeReport holds the latencies of the queries with a range in the
+	// bucket.
+	rangeReport struct {
+		Ranges  string  `json:"ranges"`
+		Queries int     `json:"queries"`
+		P50     float64 `json:"p50"`
+		P90     float64 `json:"p90"`
+		P99     float64 `json:"p99"`
+		Max     float64 `json:"max"`
+
+		latencies []time.Duration
+	}
+
+	report struct {
+		Queries       int            `json:"queries"`
+		Errors        int            `json:"errors"`
+		Mismatched    int            `json:"mismatched"`
+		MissingLogs   int            `json:"missingLogs"`
+		ExtraLogs     int            `json:"extraLogs"`
+		ExpectedLogs  int            `json:"expectedLogs"`
+		LatencyRanges []*rangeReport `json:"latencyRanges"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage      string
+	inputCheck checkParams
+)
+
+var LogsCheckCmd = &cobra.Command{
+	Use:   "logs-check",
+	Short: "Stress eth_getLogs with varying filters and check the logs against the receipts.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("this command expects no arguments")
+		}
+		if inputCheck.Queries < 1 || inputCheck.Concurrency < 1 {
+			return fmt.Errorf("the queries and concurrency need to be positive")
+		}
+		if inputCheck.Window < 1 || inputCheck.MaxRange < 1 {
+			return fmt.Errorf("the window and the max range need at least one block")
+		}
+		if inputCheck.MaxRange > inputCheck.Window {
+			return fmt.Errorf("the max range can't be larger than the window")
+		}
+		if inputCheck.MaxAddresses < 0 {
+			return fmt.Errorf("the max addresses can't be negative")
+		}
+		if inputCheck.BatchSize < 1 {
+			return fmt.Errorf("the batch size needs to be positive")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		rpc, err := util.DialRPC(ctx, inputCheck.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Str("url", inputCheck.RPCURL).Msg("Unable to dial rpc")
+			return err
+		}
+		defer rpc.Close()
+		client := ethclient.NewClient(rpc)
+
+		latest, err := client.BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		// Recent blocks could still be reorganized, which would look like
+		// missing logs.
+		if latest < inputCheck.Confirmations+inputCheck.Window {
+			return fmt.Errorf("the chain needs at least %d blocks for the window and confirmations", inputCheck.Window+inputCheck.Confirmations)
+		}
+		last := latest - inputCheck.Confirmations
+		first := last - inputCheck.Window + 1
+		log.Info().Uint64("first", first).Uint64("last", last).Int("queries", inputCheck.Queries).Msg("Starting the eth_getLogs checks")
+
+		c := &checker{client: client, rpc: rpc, first: first, last: last, blocks: make(map[uint64][]ethtypes.Log)}
+		r := c.run(ctx)
+		return printReport(r)
+	},
+}
+
+// checker runs the queries. The logs of the receipts are cached per block,
+// since the ranges of the queries overlap.
+type checker struct {
+	client      *ethclient.Client
+	rpc         *ethrpc.Client
+	first, last uint64
+
+	blocksLock sync.Mutex
+	blocks     map[uint64][]ethtypes.Log
+}
+
+func (c *checker) run(ctx context.Context) *report {
+	r := &report{}
+	for _, bucket := range []string{"1-9", "10-99", "100-999", "1000+"} {
+		r.LatencyRanges = append(r.LatencyRanges, &rangeReport{Ranges: bucket})
+	}
+
+	queries := make(chan int)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < inputCheck.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range queries {
+				// Each query has its own source, so a seed reproduces the
+				// same queries with any concurrency.
+				rng := rand.New(rand.NewSource(inputCheck.Seed + int64(q)))
+				res, err := c.query(ctx, rng)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Error().Err(err).Msg("Unable to get the receipts of the range")
+					}
+					continue
+				}
+				lock.Lock()
+				r.add(res)
+				lock.Unlock()
+				printResult(res)
+			}
+		}()
+	}
+	for q := 0; q < inputCheck.Queries && ctx.Err() == nil; q++ {
+		queries <- q
+	}
+	close(queries)
+	wg.Wait()
+
+	for _, rr := range r.LatencyRanges {
+		rr.summarize()
+	}
+	return r
+}
+
+// query picks a range and a filter, and compares what eth_getLogs returns
+// with the logs of the receipts that match the filter.
+func (c *checker) query(ctx context.Context, rng *rand.Rand) (*queryResult, error) {
+	// Ranges are spread over the orders of magnitude up to the max range
+	size := uint64(1)
+	for size*10 <= inputCheck.MaxRange && rng.Intn(2) == 0 {
+		size *= 10
+	}
+	size = 1 + uint64(rng.Int63n(int64(min(size*10, inputCheck.MaxRange))))
+	from := c.first + uint64(rng.Int63n(int64(c.last-c.first-size+2)))
+	to := from + size - 1
+
+	logs, err := c.getLogs(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	q := getFilter(rng, logs)
+	q.FromBlock = new(big.Int).SetUint64(from)
+	q.ToBlock = new(big.Int).SetUint64(to)
+	res := &queryResult{FromBlock: from, ToBlock: to, Addresses: q.Addresses, Topics: q.Topics}
+
+	expected := make(map[logKey]struct{})
+	for _, l := range logs {
+		if matches(l, q) {
+			expected[getLogKey(l)] = struct{}{}
+		}
+	}
+	res.Expected = len(expected)
+
+	start := time.Now()
+	returned, err := c.client.FilterLogs(ctx, q)
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+	res.Returned = len(returned)
+	seen := make(map[logKey]struct{}, len(returned))
+	for _, l := range returned {
+		k := getLogKey(l)
+		seen[k] = struct{}{}
+		if _, ok := expected[k]; !ok || !matches(l, q) {
+			res.Extra = append(res.Extra, k)
+		}
+	}
+	for k := range expected {
+		if _, ok := seen[k]; !ok {
+			res.Missing = append(res.Missing, k)
+		}
+	}
+	return res, nil
+}
+
+// getFilter builds a filter from the addresses and topics of the logs, so
+// most queries match some of them. Sometimes an address or topic that's not
+// in the range is added, which shouldn't match anything.
+func getFilter(rng *rand.Rand, logs []ethtypes.Log) ethereum.FilterQuery {
+	q := ethereum.FilterQuery{}
+	randomLog := func() *ethtypes.Log {
+		if len(logs) == 0 {
+			return nil
+		}
+		return &logs[rng.Intn(len(logs))]
+	}
+
+	for i := rng.Intn(inputCheck.MaxAddresses + 1); i > 0; i-- {
+		if l := randomLog(); l != nil && rng.Intn(4) > 0 {
+			q.Addresses = append(q.Addresses, l.Address)
+		} else {
+			var a ethcommon.Address
+			rng.Read(a[:])
+			q.Addresses = append(q.Addresses, a)
+		}
+	}
+
+	switch rng.Intn(4) {
+	case 1:
+		// A single event signature
+		if l := randomLog(); l != nil && len(l.Topics) > 0 {
+			q.Topics = [][]ethcommon.Hash{{l.Topics[0]}}
+		}
+	case 2:
+		// Any of a few event signatures
+		var set []ethcommon.Hash
+		for i := 1 + rng.Intn(3); i > 0; i-- {
+			if l := randomLog(); l != nil && len(l.Topics) > 0 {
+				set = append(set, l.Topics[0])
+			} else {
+				var h ethcommon.Hash
+				rng.Read(h[:])
+				set = append(set, h)
+			}
+		}
+		q.Topics = [][]ethcommon.Hash{set}
+	case 3:
+		// Any event with the first indexed argument, e.g. the sender of
+		// transfers
+		if l := randomLog(); l != nil && len(l.Topics) > 1 {
+			q.Topics = [][]ethcommon.Hash{nil, {l.Topics[1]}}
+		}
+	}
+	return q
+}
+
+// matches applies the address and topic filters of the query to the log.
+func matches(l ethtypes.Log, q ethereum.FilterQuery) bool {
+	if q.FromBlock != nil && l.BlockNumber < q.FromBlock.Uint64() {
+		return false
+	}
+	if q.ToBlock != nil && l.BlockNumber > q.ToBlock.Uint64() {
+		return false
+	}
+	if len(q.Addresses) > 0 && !contains(q.Addresses, l.Address) {
+		return false
+	}
+	if len(q.Topics) > len(l.Topics) {
+		return false
+	}
+	for i, set := range q.Topics {
+		if len(set) > 0 && !contains(set, l.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func getLogKey(l ethtypes.Log) logKey {
+	return logKey{BlockNumber: l.BlockNumber, TxHash: l.TxHash, Index: l.Index}
+}
+
+// getLogs returns the logs of the receipts of the blocks in the range.
+func (c *checker) getLogs(ctx context.Context, from, to uint64) ([]ethtypes.Log, error) {
+	var missing []uint64
+	c.blocksLock.Lock()
+	for bn := from; bn <= to; bn++ {
+		if _, ok := c.blocks[bn]; !ok {
+			missing = append(missing, bn)
+		}
+	}
+	c.blocksLock.Unlock()
+
+	for len(missing) > 0 {
+		n := min(len(missing), inputCheck.BatchSize)
+		if err := c.fetchBlocks(ctx, missing[:n]); err != nil {
+			return nil, err
+		}
+		missing = missing[n:]
+	}
+
+	var logs []ethtypes.Log
+	c.blocksLock.Lock()
+	defer c.blocksLock.Unlock()
+	for bn := from; bn <= to; bn++ {
+		logs = append(logs, c.blocks[bn]...)
+	}
+	return logs, nil
+}
+
+// fetchBlocks gets the transactions of the blocks and then their receipts
+// in batches, and caches their logs.
+func (c *checker) fetchBlocks(ctx context.Context, numbers []uint64) error {
+	type block struct {
+		Transactions []ethcommon.Hash `json:"transactions"`
+	}
+	blocks := make([]block, len(numbers))
+	elems := make([]ethrpc.BatchElem, len(numbers))
+	for i, bn := range numbers {
+		elems[i] = ethrpc.BatchElem{Method: "eth_getBlockByNumber", Args: []any{hexutil.EncodeUint64(bn), false}, Result: &blocks[i]}
+	}
+	if err := c.rpc.BatchCallContext(ctx, elems); err != nil {
+		return err
+	}
+
+	type receipt struct {
+		Logs []ethtypes.Log `json:"logs"`
+	}
+	var hashes []ethcommon.Hash
+	var blockOf []int
+	for i, e := range elems {
+		if e.Error != nil {
+			return fmt.Errorf("unable to get the block %d: %w", numbers[i], e.Error)
+		}
+		for _, h := range blocks[i].Transactions {
+			hashes = append(hashes, h)
+			blockOf = append(blockOf, i)
+		}
+	}
+	logs := make([][]ethtypes.Log, len(numbers))
+	for start := 0; start < len(hashes); start += inputCheck.BatchSize {
+		end := min(start+inputCheck.BatchSize, len(hashes))
+		receipts := make([]*receipt, end-start)
+		elems := make([]ethrpc.BatchElem, end-start)
+		for i := range elems {
+			elems[i] = ethrpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []any{hashes[start+i]}, Result: &receipts[i]}
+		}
+		if err := c.rpc.BatchCallContext(ctx, elems); err != nil {
+			return err
+		}
+		for i, e := range elems {
+			if e.Error != nil {
+				return fmt.Errorf("unable to get the receipt %s: %w", hashes[start+i], e.Error)
+			}
+			if receipts[i] == nil {
+				return fmt.Errorf("the receipt %s wasn't found", hashes[start+i])
+			}
+			b := blockOf[start+i]
+			logs[b] = append(logs[b], receipts[i].Logs...)
+		}
+	}
+
+	c.blocksLock.Lock()
+	defer c.blocksLock.Unlock()
+	for i, bn := range numbers {
+		c.blocks[bn] = logs[i]
+	}
+	return nil
+}
+
+func (r *report) add(res *queryResult) {
+	r.Queries++
+	if res.Error != "" {
+		r.Errors++
+		return
+	}
+	r.ExpectedLogs += res.Expected
+	r.MissingLogs += len(res.Missing)
+	r.ExtraLogs += len(res.Extra)
+	if len(res.Missing) > 0 || len(res.Extra) > 0 {
+		r.Mismatched++
+	}
+	size := res.ToBlock - res.FromBlock + 1
+	bucket := 0
+	for limit := uint64(10); size >= limit && bucket < len(r.LatencyRanges)-1; limit *= 10 {
+		bucket++
+	}
+	rr := r.LatencyRanges[bucket]
+	rr.Queries++
+	rr.latencies = append(rr.latencies, res.Latency)
+}
+
+func (rr *rangeReport) summarize() {
+	if len(rr.latencies) == 0 {
+		return
+	}
+	sort.Slice(rr.latencies, func(i, j int) bool {
+		return rr.latencies[i] < rr.latencies[j]
+	})
+	percentile := func(p float64) float64 {
+		return rr.latencies[int(p*float64(len(rr.latencies)-1))].Seconds()
+	}
+	rr.P50 = percentile(0.5)
+	rr.P90 = percentile(0.9)
+	rr.P99 = percentile(0.99)
+	rr.Max = percentile(1)
+}
+
+func printResult(res *queryResult) {
+	if util.JSONOutput() {
+		out, err := json.Marshal(res)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to marshal the result")
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
+	event := log.Debug()
+	if res.Error != "" || len(res.Missing) > 0 || len(res.Extra) > 0 {
+		event = log.Warn()
+	}
+	event.Uint64("from", res.FromBlock).
+		Uint64("to", res.ToBlock).
+		Int("addresses", len(res.Addresses)).
+		Int("topics", len(res.Topics)).
+		Dur("latency", res.Latency).
+		Int("expected", res.Expected).
+		Int("returned", res.Returned).
+		Int("missing", len(res.Missing)).
+		Int("extra", len(res.Extra)).
+		Str("error", res.Error).
+		Msg("eth_getLogs")
+}
+
+// printReport prints the report and fails when any query was missing logs
+// or returned logs it shouldn't have.
+func printReport(r *report) error {
+	if util.JSONOutput() {
+		out, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("queries=%d errors=%d mismatched=%d expectedLogs=%d missingLogs=%d extraLogs=%d\n", r.Queries, r.Errors, r.Mismatched, r.ExpectedLogs, r.MissingLogs, r.ExtraLogs)
+		for _, rr := range r.LatencyRanges {
+			fmt.Printf("  range=%s queries=%d p50=%.3fs p90=%.3fs p99=%.3fs max=%.3fs\n", rr.Ranges, rr.Queries, rr.P50, rr.P90, rr.P99, rr.Max)
+		}
+	}
+	if r.Mismatched > 0 {
+		return util.AssertionFailure(fmt.Errorf("%d of the queries didn't return the logs of the receipts", r.Mismatched))
+	}
+	return nil
+}
+
+func init() {
+	flagSet := LogsCheckCmd.PersistentFlags()
+	flagSet.StringVarP(&inputCheck.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint to check")
+	flagSet.IntVarP(&inputCheck.Queries, "queries", "n", 100, "The number of eth_getLogs queries")
+	flagSet.IntVarP(&inputCheck.Concurrency, "concurrency", "c", 4, "The number of queries sent concurrently")
+	flagSet.Uint64Var(&inputCheck.Window, "window", 1000, "The number of recent blocks the queries cover")
+	flagSet.Uint64Var(&inputCheck.Confirmations, "confirmations", 5, "The number of the most recent blocks left out, since they could still be reorganized")
+	flagSet.Uint64Var(&inputCheck.MaxRange, "max-range", 500, "The largest block range of a query")
+	flagSet.IntVar(&inputCheck.MaxAddresses, "max-addresses", 3, "The largest number of addresses in the filter of a query")
+	flagSet.IntVar(&inputCheck.BatchSize, "batch-size", 100, "The number of blocks or receipts fetched per batch request")
+	flagSet.Int64Var(&inputCheck.Seed, "seed", 123456, "The seed of the random ranges and filters, the same seed gives the same queries")
+}
@@ -0,0 +1,493 @@
+package mempoolwatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+)
+
+type (
+	watchParams struct {
+		RPCURLs       []string
+		Source        string
+		PollInterval  time.Duration
+		To            []string
+		From          []string
+		Selectors     []string
+		MinValue      string
+		StatsInterval time.Duration
+		Concurrency   int
+
+		to        map[common.Address]struct{}
+		from      map[common.Address]struct{}
+		selectors map[[4]byte]struct{}
+		minValue  *big.Int
+	}
+
+	// pendingTx is the part of the RPC transaction that's summarized.
+	pendingTx struct {
+		Hash                 common.Hash     `json:"hash"`
+		From                 common.Address  `json:"from"`
+		To                   *common.Address `json:"to"`
+		Nonce                hexutil.Uint64  `json:"nonce"`
+		Value                *hexutil.Big    `json:"value"`
+		Gas                  hexutil.Uint64  `json:"gas"`
+		GasPrice             *hexutil.Big    `json:"gasPrice"`
+		MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+		Input                hexutil.Bytes   `json:"input"`
+	}
+
+	// txSummary is a line of the output.
+	txSummary struct {
+		Time     time.Time       `json:"time"`
+		Endpoint string          `json:"endpoint"`
+		Hash     common.Hash     `json:"hash"`
+		From     common.Address  `json:"from"`
+		To       *common.Address `json:"to"`
+		Nonce    uint64          `json:"nonce"`
+		Value    *big.Int        `json:"value"`
+		Gas      uint64          `json:"gas"`
+		GasPrice *big.Int        `json:"gasPrice,omitempty"`
+		MaxFee   *big.Int        `json:"maxFeePerGas,omitempty"`
+		MaxTip   *big.Int        `json:"maxPriorityFeePerGas,omitempty"`
+		Selector string          `json:"selector,omitempty"`
+	}
+
+	// sighting is a pending transaction hash seen by an endpoint.
+	sighting struct {
+		endpoint int
+		hash     common.Hash
+		time     time.Time
+	}
+
+	firstSighting struct {
+		time   time.Time
+		seenBy map[int]struct{}
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage      string
+	inputWatch watchParams
+
+	sources = []string{"auto", "subscribe", "filter", "txpool"}
+)
+
+var MempoolWatchCmd = &cobra.Command{
+	Use:   "mempool-watch",
+	Short: "Stream the pending transactions of one or more endpoints.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("this command expects no arguments")
+		}
+		if len(inputWatch.RPCURLs) == 0 {
+			return fmt.Errorf("at least one --rpc-url is required")
+		}
+		if !slices.Contains(sources, inputWatch.Source) {
+			return fmt.Errorf("the source must be one of %v", sources)
+		}
+		if inputWatch.Concurrency < 1 {
+			return fmt.Errorf("the concurrency must be at least 1")
+		}
+
+		var err error
+		if inputWatch.to, err = parseAddresses(inputWatch.To); err != nil {
+			return err
+		}
+		if inputWatch.from, err = parseAddresses(inputWatch.From); err != nil {
+			return err
+		}
+		if inputWatch.selectors, err = parseSelectors(inputWatch.Selectors); err != nil {
+			return err
+		}
+		if inputWatch.minValue, err = util.ParseEther(inputWatch.MinValue); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		clients := make([]*ethrpc.Client, 0, len(inputWatch.RPCURLs))
+		for _, url := range inputWatch.RPCURLs {
+			client, err := util.DialRPC(ctx, url)
+			if err != nil {
+				log.Error().Err(err).Str("url", url).Msg("Unable to dial rpc")
+				return err
+			}
+			defer client.Close()
+			clients = append(clients, client)
+		}
+
+		return watch(ctx, clients)
+	},
+}
+
+// watch streams the sightings of every endpoint. The first time a hash is
+// seen the transaction is fetched from that endpoint and printed if it
+// passes the filters. Later sightings by other endpoints are recorded as lag.
+func watch(ctx context.Context, clients []*ethrpc.Client) error {
+	sightings := make(chan sighting, 1024)
+	errs := make(chan error, len(clients))
+	for i, client := range clients {
+		go func(i int, client *ethrpc.Client) {
+			errs <- watchEndpoint(ctx, i, client, sightings)
+		}(i, client)
+	}
+
+	stats := newLagStats(inputWatch.RPCURLs)
+	seen := make(map[common.Hash]*firstSighting)
+	jobs := make(chan struct{}, inputWatch.Concurrency)
+	var printMu sync.Mutex
+
+	var statsTicker <-chan time.Time
+	if len(clients) > 1 && inputWatch.StatsInterval > 0 {
+		ticker := time.NewTicker(inputWatch.StatsInterval)
+		defer ticker.Stop()
+		statsTicker = ticker.C
+	}
+
+	pruneTicker := time.NewTicker(time.Minute)
+	defer pruneTicker.Stop()
+
+	running := len(clients)
+	for {
+		select {
+		case <-ctx.Done():
+			stats.print()
+			return nil
+		case err := <-errs:
+			if err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Msg("Stopped watching an endpoint")
+			}
+			if running--; running == 0 {
+				stats.print()
+				return fmt.Errorf("no endpoints are being watched")
+			}
+		case <-statsTicker:
+			stats.print()
+		case <-pruneTicker.C:
+			// Forget the hashes that every endpoint has already seen, or that
+			// are too old to still be pending anywhere.
+			for hash, first := range seen {
+				if len(first.seenBy) == len(clients) || time.Since(first.time) > 10*time.Minute {
+					delete(seen, hash)
+				}
+			}
+		case s := <-sightings:
+			first, ok := seen[s.hash]
+			if ok {
+				if _, dup := first.seenBy[s.endpoint]; !dup {
+					first.seenBy[s.endpoint] = struct{}{}
+					stats.record(s.endpoint, s.time.Sub(first.time))
+				}
+				continue
+			}
+			seen[s.hash] = &firstSighting{time: s.time, seenBy: map[int]struct{}{s.endpoint: {}}}
+			stats.recordFirst(s.endpoint)
+
+			jobs <- struct{}{}
+			go func(s sighting) {
+				defer func() { <-jobs }()
+				summary, err := fetchSummary(ctx, clients[s.endpoint], s)
+				if err != nil {
+					log.Debug().Err(err).Str("hash", s.hash.Hex()).Msg("Unable to fetch pending transaction")
+					return
+				}
+				if summary == nil || !matches(summary) {
+					return
+				}
+				printMu.Lock()
+				defer printMu.Unlock()
+				printSummary(summary)
+			}(s)
+		}
+	}
+}
+
+// watchEndpoint sends the hashes of the pending transactions seen by the
+// endpoint until the context is done.
+func watchEndpoint(ctx context.Context, i int, client *ethrpc.Client, out chan<- sighting) error {
+	url := inputWatch.RPCURLs[i]
+	source := inputWatch.Source
+	if source == "auto" {
+		source = "filter"
+		if strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://") || !strings.Contains(url, "://") {
+			source = "subscribe"
+		}
+	}
+	log.Info().Str("url", url).Str("source", source).Msg("Watching pending transactions")
+
+	send := func(hash common.Hash) {
+		select {
+		case out <- sighting{endpoint: i, hash: hash, time: time.Now()}:
+		case <-ctx.Done():
+		}
+	}
+
+	switch source {
+	case "subscribe":
+		return subscribe(ctx, client, send)
+	case "txpool":
+		return pollTxPool(ctx, client, send)
+	default:
+		return pollFilter(ctx, client, send)
+	}
+}
+
+func subscribe(ctx context.Context, client *ethrpc.Client, send func(common.Hash)) error {
+	hashes := make(chan common.Hash, 1024)
+	sub, err := client.EthSubscribe(ctx, hashes, "newPendingTransactions")
+	if err != nil {
+		return fmt.Errorf("unable to subscribe to new pending transactions: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case hash := <-hashes:
+			send(hash)
+		}
+	}
+}
+
+// pollFilter polls a pending transaction filter. The filter is created again
+// if the node drops it.
+func pollFilter(ctx context.Context, client *ethrpc.Client, send func(common.Hash)) error {
+	var id string
+	ticker := time.NewTicker(inputWatch.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if id == "" {
+			if err := client.CallContext(ctx, &id, "eth_newPendingTransactionFilter"); err != nil {
+				return fmt.Errorf("unable to create a pending transaction filter: %w", err)
+			}
+		}
+
+		var hashes []common.Hash
+		if err := client.CallContext(ctx, &hashes, "eth_getFilterChanges", id); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Warn().Err(err).Msg("Unable to get the filter changes, creating a new filter")
+			id = ""
+		}
+		for _, hash := range hashes {
+			send(hash)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollTxPool polls txpool_content for clients without pending transaction
+// filters or subscriptions.
+func pollTxPool(ctx context.Context, client *ethrpc.Client, send func(common.Hash)) error {
+	var known map[common.Hash]struct{}
+	ticker := time.NewTicker(inputWatch.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		var content struct {
+			Pending map[string]map[string]struct {
+				Hash common.Hash `json:"hash"`
+			} `json:"pending"`
+		}
+		if err := client.CallContext(ctx, &content, "txpool_content"); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("unable to get the txpool content: %w", err)
+		}
+
+		current := make(map[common.Hash]struct{})
+		for _, nonces := range content.Pending {
+			for _, tx := range nonces {
+				current[tx.Hash] = struct{}{}
+				// The first poll only records what's already pending.
+				if _, ok := known[tx.Hash]; !ok && known != nil {
+					send(tx.Hash)
+				}
+			}
+		}
+		known = current
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func fetchSummary(ctx context.Context, client *ethrpc.Client, s sighting) (*txSummary, error) {
+	var tx *pendingTx
+	if err := client.CallContext(ctx, &tx, "eth_getTransactionByHash", s.hash); err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, nil
+	}
+
+	summary := &txSummary{
+		Time:     s.time,
+		Endpoint: inputWatch.RPCURLs[s.endpoint],
+		Hash:     tx.Hash,
+		From:     tx.From,
+		To:       tx.To,
+		Nonce:    uint64(tx.Nonce),
+		Value:    big.NewInt(0),
+		Gas:      uint64(tx.Gas),
+	}
+	if tx.Value != nil {
+		summary.Value = tx.Value.ToInt()
+	}
+	if tx.MaxFeePerGas != nil {
+		summary.MaxFee = tx.MaxFeePerGas.ToInt()
+		summary.MaxTip = tx.MaxPriorityFeePerGas.ToInt()
+	} else if tx.GasPrice != nil {
+		summary.GasPrice = tx.GasPrice.ToInt()
+	}
+	if len(tx.Input) >= 4 {
+		summary.Selector = hexutil.Encode(tx.Input[:4])
+	}
+	return summary, nil
+}
+
+func matches(s *txSummary) bool {
+	if len(inputWatch.from) > 0 {
+		if _, ok := inputWatch.from[s.From]; !ok {
+			return false
+		}
+	}
+	if len(inputWatch.to) > 0 {
+		if s.To == nil {
+			return false
+		}
+		if _, ok := inputWatch.to[*s.To]; !ok {
+			return false
+		}
+	}
+	if len(inputWatch.selectors) > 0 {
+		var selector [4]byte
+		b, err := hexutil.Decode(s.Selector)
+		if err != nil {
+			return false
+		}
+		copy(selector[:], b)
+		if _, ok := inputWatch.selectors[selector]; !ok {
+			return false
+		}
+	}
+	return s.Value.Cmp(inputWatch.minValue) >= 0
+}
+
+func printSummary(s *txSummary) {
+	if util.JSONOutput() {
+		out, err := json.Marshal(s)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to marshal the transaction summary")
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	to := "create"
+	if s.To != nil {
+		to = s.To.Hex()
+	}
+	fee := fmt.Sprintf("gasPrice=%s", util.FormatGwei(s.GasPrice))
+	if s.MaxFee != nil {
+		fee = fmt.Sprintf("maxFee=%s maxTip=%s", util.FormatGwei(s.MaxFee), util.FormatGwei(s.MaxTip))
+	}
+	line := fmt.Sprintf("%s %s %s -> %s nonce=%d value=%s gas=%d %s",
+		s.Time.Format("15:04:05.000"), s.Hash.Hex(), s.From.Hex(), to, s.Nonce, formatEther(s.Value), s.Gas, fee)
+	if s.Selector != "" {
+		line += " selector=" + s.Selector
+	}
+	if len(inputWatch.RPCURLs) > 1 {
+		line += " endpoint=" + s.Endpoint
+	}
+	fmt.Println(line)
+}
+
+func formatEther(wei *big.Int) string {
+	f := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e18))
+	return strings.TrimRight(strings.TrimRight(f.Text('f', 18), "0"), ".") + "eth"
+}
+
+func parseAddresses(list []string) (map[common.Address]struct{}, error) {
+	addresses := make(map[common.Address]struct{}, len(list))
+	for _, a := range list {
+		if !common.IsHexAddress(a) {
+			return nil, fmt.Errorf("the address %s is invalid", a)
+		}
+		addresses[common.HexToAddress(a)] = struct{}{}
+	}
+	return addresses, nil
+}
+
+// parseSelectors accepts 4 byte hex selectors or method signatures like
+// transfer(address,uint256).
+func parseSelectors(list []string) (map[[4]byte]struct{}, error) {
+	selectors := make(map[[4]byte]struct{}, len(list))
+	for _, s := range list {
+		var selector [4]byte
+		if strings.Contains(s, "(") {
+			copy(selector[:], ethcrypto.Keccak256([]byte(strings.ReplaceAll(s, " ", "")))[:4])
+		} else {
+			b, err := hexutil.Decode(s)
+			if err != nil || len(b) != 4 {
+				return nil, fmt.Errorf("the selector %s must be 4 bytes of hex or a method signature", s)
+			}
+			copy(selector[:], b)
+		}
+		selectors[selector] = struct{}{}
+	}
+	return selectors, nil
+}
+
+func init() {
+	flagSet := MempoolWatchCmd.PersistentFlags()
+	flagSet.StringSliceVarP(&inputWatch.RPCURLs, "rpc-url", "r", []string{"ws://localhost:8546"}, "The RPC endpoints to watch. Repeat the flag to compare endpoints")
+	flagSet.StringVar(&inputWatch.Source, "source", "auto", "How pending transactions are received ["+strings.Join(sources, ", ")+"]. auto subscribes over websockets and ipc and polls a filter over http")
+	flagSet.DurationVar(&inputWatch.PollInterval, "poll-interval", time.Second, "How often the filter or txpool is polled")
+	flagSet.StringSliceVar(&inputWatch.To, "to", nil, "Only show transactions to these addresses")
+	flagSet.StringSliceVar(&inputWatch.From, "from", nil, "Only show transactions from these addresses")
+	flagSet.StringArrayVar(&inputWatch.Selectors, "selector", nil, "Only show calls to this 4 byte selector or method signature. Repeat the flag for more")
+	flagSet.StringVar(&inputWatch.MinValue, "min-value", "0", "Only show transactions sending at least this much ether")
+	flagSet.DurationVar(&inputWatch.StatsInterval, "stats-interval", 30*time.Second, "How often the lag between endpoints is printed, or 0 to only print it on exit")
+	flagSet.IntVar(&inputWatch.Concurrency, "concurrency", 16, "The number of transactions fetched at the same time")
+}
@@ -0,0 +1,87 @@
+package mempoolwatch
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxLagSamples bounds the memory used for the lag percentiles. Once it's
+// reached the oldest samples are dropped.
+const maxLagSamples = 10000
+
+type (
+	// lagStats tracks how long after the first endpoint each endpoint sees a
+	// pending transaction.
+	lagStats struct {
+		endpoints []endpointStats
+		mu        sync.Mutex
+	}
+
+	endpointStats struct {
+		url   string
+		seen  int
+		first int
+		lags  []time.Duration
+	}
+)
+
+func newLagStats(urls []string) *lagStats {
+	s := &lagStats{endpoints: make([]endpointStats, len(urls))}
+	for i, url := range urls {
+		s.endpoints[i].url = url
+	}
+	return s
+}
+
+// recordFirst records that the endpoint saw a transaction before the others.
+func (s *lagStats) recordFirst(endpoint int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endpoints[endpoint].seen++
+	s.endpoints[endpoint].first++
+}
+
+// record records that the endpoint saw a transaction after the lag.
+func (s *lagStats) record(endpoint int, lag time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := &s.endpoints[endpoint]
+	e.seen++
+	if len(e.lags) == maxLagSamples {
+		e.lags = e.lags[1:]
+	}
+	e.lags = append(e.lags, lag)
+}
+
+// print logs the stats of every endpoint. It's only useful when comparing
+// endpoints.
+func (s *lagStats) print() {
+	if len(s.endpoints) < 2 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.endpoints {
+		lags := append([]time.Duration{}, e.lags...)
+		sort.Slice(lags, func(i, j int) bool { return lags[i] < lags[j] })
+		log.Info().
+			Str("endpoint", e.url).
+			Int("seen", e.seen).
+			Int("first", e.first).
+			Dur("p50", percentile(lags, 0.5)).
+			Dur("p90", percentile(lags, 0.9)).
+			Dur("max", percentile(lags, 1)).
+			Msg("Pending transaction lag")
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
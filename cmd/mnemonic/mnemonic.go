@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/maticnetwork/polygon-cli/hdwallet"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/spf13/cobra"
 )
 
@@ -12,6 +13,11 @@ var (
 	inputMnemonicLang  *string
 )
 
+// mnemonicOutput is the mnemonic printed with --json.
+type mnemonicOutput struct {
+	Mnemonic string `json:"mnemonic"`
+}
+
 // mnemonicCmd represents the mnemonic command
 var MnemonicCmd = &cobra.Command{
 	Use:   "mnemonic",
@@ -22,6 +28,9 @@ var MnemonicCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if util.JSONOutput() {
+			return util.PrintJSON(mnemonicOutput{Mnemonic: mnemonic})
+		}
 		cmd.Println(mnemonic)
 		return nil
 	},
@@ -0,0 +1,251 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/gizak/termui/v3/widgets"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+// maxReorgDepth is how far back the monitor will walk to find the common
+// ancestor when a reorg is detected.
+const maxReorgDepth = 128
+
+// maxAlerts is the number of alerts kept in the alert log.
+const maxAlerts = 1000
+
+type (
+	alertKind string
+
+	alert struct {
+		Time    time.Time
+		Kind    alertKind
+		Block   *big.Int
+		Message string
+	}
+
+	// alertLog keeps the alerts raised by the monitor and optionally posts
+	// them to a webhook.
+	alertLog struct {
+		alerts  []alert
+		total   map[alertKind]uint64
+		lock    sync.RWMutex
+		webhook string
+		client  *http.Client
+	}
+
+	// webhookPayload is compatible with Slack incoming webhooks through the
+	// text field, and carries the summary, severity, source, and timestamp
+	// fields used by PagerDuty events.
+	webhookPayload struct {
+		Text          string            `json:"text"`
+		Summary       string            `json:"summary"`
+		Severity      string            `json:"severity"`
+		Source        string            `json:"source"`
+		Timestamp     string            `json:"timestamp"`
+		CustomDetails map[string]string `json:"custom_details"`
+	}
+)
+
+const (
+	alertKindReorg     alertKind = "reorg"
+	alertKindBlockGap  alertKind = "block_gap"
+	alertSourceMonitor           = "polycli-monitor"
+)
+
+func newAlertLog(webhook string) *alertLog {
+	return &alertLog{
+		webhook: webhook,
+		total:   make(map[alertKind]uint64),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// add records the alert and posts it to the webhook in the background.
+func (a *alertLog) add(ctx context.Context, kind alertKind, block *big.Int, format string, args ...interface{}) {
+	al := alert{
+		Time:    time.Now(),
+		Kind:    kind,
+		Block:   block,
+		Message: fmt.Sprintf(format, args...),
+	}
+	log.Warn().Str("kind", string(kind)).Str("block", block.String()).Msg(al.Message)
+
+	a.insert(al)
+
+	if a.webhook != "" {
+		go a.post(ctx, al)
+	}
+}
+
+// insert records the alert without posting it, e.g. when replaying a session.
+func (a *alertLog) insert(al alert) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.alerts = append(a.alerts, al)
+	a.total[al.Kind]++
+	if len(a.alerts) > maxAlerts {
+		a.alerts = a.alerts[len(a.alerts)-maxAlerts:]
+	}
+}
+
+func (a *alertLog) post(ctx context.Context, al alert) {
+	payload := webhookPayload{
+		Text:      fmt.Sprintf("[%s] %s", al.Kind, al.Message),
+		Summary:   al.Message,
+		Severity:  "warning",
+		Source:    alertSourceMonitor,
+		Timestamp: al.Time.UTC().Format(time.RFC3339),
+		CustomDetails: map[string]string{
+			"kind":  string(al.Kind),
+			"block": al.Block.String(),
+		},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to marshal alert")
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.webhook, bytes.NewReader(body))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create alert webhook request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to post alert to webhook")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Error().Int("status", resp.StatusCode).Msg("Alert webhook returned an error")
+	}
+}
+
+// since returns the alerts raised after the given time.
+func (a *alertLog) since(t time.Time) []alert {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	var alerts []alert
+	for _, al := range a.alerts {
+		if al.Time.After(t) {
+			alerts = append(alerts, al)
+		}
+	}
+	return alerts
+}
+
+// counts returns the number of alerts of each kind raised since the monitor
+// started, including the ones that have dropped out of the log.
+func (a *alertLog) counts() map[alertKind]uint64 {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	counts := make(map[alertKind]uint64, len(a.total))
+	for kind, count := range a.total {
+		counts[kind] = count
+	}
+	return counts
+}
+
+// render shows the alerts with the newest first.
+func (a *alertLog) render(list *widgets.List) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	list.Title = fmt.Sprintf("Alerts (%d)", len(a.alerts))
+	rows := make([]string, 0, len(a.alerts))
+	for i := len(a.alerts) - 1; i >= 0; i-- {
+		al := a.alerts[i]
+		rows = append(rows, fmt.Sprintf("%s [%s] %s", al.Time.Format("15:04:05"), al.Kind, al.Message))
+	}
+	list.Rows = rows
+}
+
+// checkNewBlock compares a newly retrieved head block against its parent and
+// raises alerts for reorgs and block gaps. The parent is nil if it hasn't been
+// retrieved.
+func (ms *monitorStatus) checkNewBlock(ctx context.Context, rpc *ethrpc.Client, block, parent rpctypes.PolyBlock) {
+	if ms.Alerts == nil || parent == nil {
+		return
+	}
+
+	if block.ParentHash() != parent.Hash() {
+		ms.handleReorg(ctx, rpc, block)
+		return
+	}
+
+	if maxBlockGap > 0 && block.Time() > parent.Time() {
+		gap := time.Duration(block.Time()-parent.Time()) * time.Second
+		if gap > maxBlockGap {
+			ms.Alerts.add(ctx, alertKindBlockGap, block.Number(),
+				"Block %s was produced %s after its parent, which exceeds %s", block.Number(), gap, maxBlockGap)
+		}
+	}
+}
+
+// handleReorg walks back from the block until the retrieved blocks agree with
+// the canonical chain again, replacing the stale blocks along the way.
+func (ms *monitorStatus) handleReorg(ctx context.Context, rpc *ethrpc.Client, block rpctypes.PolyBlock) {
+	var (
+		child    = block
+		depth    = 0
+		replaced []common.Hash
+	)
+
+	for depth < maxReorgDepth {
+		number := new(big.Int).Sub(child.Number(), one)
+		ms.BlocksLock.RLock()
+		stale, ok := ms.Blocks[number.String()]
+		ms.BlocksLock.RUnlock()
+		if !ok || stale.Hash() == child.ParentHash() {
+			break
+		}
+
+		var raw rpctypes.RawBlockResponse
+		if err := rpc.CallContext(ctx, &raw, "eth_getBlockByNumber", "0x"+number.Text(16), true); err != nil {
+			log.Error().Err(err).Str("block", number.String()).Msg("Unable to fetch reorged block")
+			break
+		}
+		canonical := rpctypes.NewPolyBlock(&raw)
+
+		ms.BlocksLock.Lock()
+		ms.Blocks[number.String()] = canonical
+		ms.BlocksLock.Unlock()
+
+		replaced = append(replaced, stale.Hash())
+		child = canonical
+		depth++
+	}
+
+	ms.Alerts.add(ctx, alertKindReorg, block.Number(),
+		"Reorg of depth %d detected below block %s, replaced %s", depth, block.Number(), shortHashes(replaced))
+}
+
+func shortHashes(hashes []common.Hash) string {
+	if len(hashes) == 0 {
+		return "no blocks"
+	}
+	if len(hashes) == 1 {
+		return shortHash(hashes[0])
+	}
+	return fmt.Sprintf("%s (and %d more)", shortHash(hashes[0]), len(hashes)-1)
+}
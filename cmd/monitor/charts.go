@@ -0,0 +1,183 @@
+package monitor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/metrics"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+// chartSeries is a single charted metric with one value per block.
+type chartSeries struct {
+	Title  string
+	Header string
+	Values func([]rpctypes.PolyBlock) []float64
+}
+
+var chartSeriesList = []chartSeries{
+	{Title: "Block Time (s)", Header: "block_time", Values: metrics.GetBlockTimePerBlock},
+	{Title: "Gas Used (%)", Header: "gas_used_percent", Values: metrics.GetGasUsedPercentPerBlock},
+	{Title: "Base Fee (gwei)", Header: "base_fee_gwei", Values: metrics.GetBaseFeePerBlock},
+	{Title: "Tx Count", Header: "tx_count", Values: metrics.GetTxsPerBlock},
+}
+
+type chartsUI struct {
+	grid    *ui.Grid
+	header  *widgets.Paragraph
+	plots   []*widgets.Plot
+	offset  int
+	span    int
+	message string
+}
+
+func newChartsUI() *chartsUI {
+	c := &chartsUI{
+		grid:   ui.NewGrid(),
+		header: widgets.NewParagraph(),
+	}
+	c.header.Title = "Charts"
+
+	colors := []ui.Color{ui.ColorYellow, ui.ColorGreen, ui.ColorMagenta, ui.ColorCyan}
+	for i, series := range chartSeriesList {
+		plot := widgets.NewPlot()
+		plot.Title = series.Title
+		plot.LineColors = []ui.Color{colors[i%len(colors)]}
+		c.plots = append(c.plots, plot)
+	}
+
+	c.grid.Set(
+		ui.NewRow(1.0/10, c.header),
+		ui.NewRow(9.0/20,
+			ui.NewCol(1.0/2, c.plots[0]),
+			ui.NewCol(1.0/2, c.plots[1]),
+		),
+		ui.NewRow(9.0/20,
+			ui.NewCol(1.0/2, c.plots[2]),
+			ui.NewCol(1.0/2, c.plots[3]),
+		),
+	)
+
+	return c
+}
+
+// scroll moves the charted window by half of its width. Positive values move
+// towards older blocks.
+func (c *chartsUI) scroll(direction int) {
+	step := c.span / 2
+	if step < 1 {
+		step = 1
+	}
+	c.offset += direction * step
+	if c.offset < 0 {
+		c.offset = 0
+	}
+}
+
+// render charts the window of blocks that fits in the plots. The blocks must
+// be sorted in ascending order.
+func (c *chartsUI) render(blocks metrics.SortableBlocks) {
+	// Each data point of a braille line chart takes one cell and the y-axis
+	// labels take up the first 5 cells.
+	c.span = c.plots[0].Inner.Dx() - 5
+	if c.span < 2 {
+		c.span = 2
+	}
+
+	if c.offset > len(blocks)-c.span {
+		c.offset = max(len(blocks)-c.span, 0)
+	}
+	end := len(blocks) - c.offset
+	start := max(end-c.span, 0)
+	window := blocks[start:end]
+
+	text := "Press <Left>/<Right> to scroll, <e> to export the charted series to CSV, and <Esc> to go back to the explorer view"
+	if len(window) > 0 {
+		text = fmt.Sprintf("Blocks %s to %s (%d of %d loaded)\n%s",
+			window[0].Number(), window[len(window)-1].Number(), len(window), len(blocks), text)
+	}
+	if c.message != "" {
+		text += "\n" + c.message
+	}
+	c.header.Text = text
+
+	for i, series := range chartSeriesList {
+		values := series.Values(window)
+		// The line chart needs at least two points and a non zero max value.
+		if len(values) < 2 {
+			c.plots[i].Data = [][]float64{}
+			continue
+		}
+		c.plots[i].Data = [][]float64{values}
+		c.plots[i].MaxVal = 0
+		if maxVal, _ := ui.GetMaxFloat64From2dSlice(c.plots[i].Data); maxVal == 0 {
+			c.plots[i].MaxVal = 1
+		}
+	}
+}
+
+// exportCharts writes every charted series for all of the loaded blocks to a
+// CSV file. The blocks must be sorted in ascending order.
+func exportCharts(file string, blocks metrics.SortableBlocks) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"block", "timestamp"}
+	for _, series := range chartSeriesList {
+		header = append(header, series.Header)
+	}
+	if err = w.Write(header); err != nil {
+		return err
+	}
+
+	values := make([][]float64, len(chartSeriesList))
+	for i, series := range chartSeriesList {
+		values[i] = series.Values(blocks)
+	}
+
+	for i, block := range blocks {
+		record := []string{block.Number().String(), strconv.FormatUint(block.Time(), 10)}
+		for j := range chartSeriesList {
+			record = append(record, strconv.FormatFloat(values[j][i], 'f', -1, 64))
+		}
+		if err = w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// loadHistory fetches older blocks until the given number of blocks behind
+// the head have been retrieved, so the charts cover more than just the blocks
+// seen since the monitor started.
+func loadHistory(ms *monitorStatus, fetchOlder func() error, blocks uint64) {
+	if blocks == 0 || ms.HeadBlock == nil {
+		return
+	}
+
+	target := new(big.Int).Sub(ms.HeadBlock, new(big.Int).SetUint64(blocks-1))
+	if target.Cmp(one) < 0 {
+		target.Set(one)
+	}
+
+	log.Info().Uint64("blocks", blocks).Str("from", target.String()).Msg("Loading block history")
+	for ms.MinBlockRetrieved != nil && ms.MinBlockRetrieved.Cmp(target) == 1 {
+		if err := fetchOlder(); err != nil {
+			log.Warn().Err(err).Msg("Unable to load block history")
+			return
+		}
+	}
+}
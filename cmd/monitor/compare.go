@@ -0,0 +1,184 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/metrics"
+)
+
+type (
+	// endpoint is one of the RPC endpoints being compared.
+	endpoint struct {
+		URL    string
+		client *ethclient.Client
+		rpc    *ethrpc.Client
+	}
+
+	// endpointStatus is the latest chain state observed on an endpoint. The
+	// CommonHash is the hash of the block at CommonHeight, which is the lowest
+	// head across all endpoints, so the hashes can be compared to detect
+	// endpoints that have diverged.
+	endpointStatus struct {
+		URL          string
+		HeadBlock    uint64
+		HeadHash     common.Hash
+		CommonHeight uint64
+		CommonHash   common.Hash
+		GasPrice     *big.Int
+		PeerCount    uint64
+		PendingCount uint
+		Diverged     bool
+		Err          error `json:"-"`
+	}
+)
+
+// compareEndpoints fetches the chain state from every endpoint concurrently
+// and checks whether they agree on the block hash at the lowest common
+// height.
+func compareEndpoints(ctx context.Context, endpoints []endpoint) []endpointStatus {
+	statuses := make([]endpointStatus, len(endpoints))
+
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func(i int, e endpoint) {
+			defer wg.Done()
+			statuses[i] = getEndpointStatus(ctx, e)
+		}(i, e)
+	}
+	wg.Wait()
+
+	var commonHeight *uint64
+	for _, s := range statuses {
+		if s.Err != nil {
+			continue
+		}
+		if commonHeight == nil || s.HeadBlock < *commonHeight {
+			height := s.HeadBlock
+			commonHeight = &height
+		}
+	}
+	if commonHeight == nil {
+		return statuses
+	}
+
+	for i, e := range endpoints {
+		if statuses[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, e endpoint) {
+			defer wg.Done()
+			header, err := e.client.HeaderByNumber(ctx, new(big.Int).SetUint64(*commonHeight))
+			if err != nil {
+				statuses[i].Err = err
+				return
+			}
+			statuses[i].CommonHeight = *commonHeight
+			statuses[i].CommonHash = header.Hash()
+		}(i, e)
+	}
+	wg.Wait()
+
+	// Treat the hash that the most endpoints agree on as the canonical one and
+	// flag every other endpoint as diverged.
+	votes := make(map[common.Hash]int)
+	for _, s := range statuses {
+		if s.Err == nil {
+			votes[s.CommonHash]++
+		}
+	}
+	var canonical common.Hash
+	for hash, count := range votes {
+		if count > votes[canonical] {
+			canonical = hash
+		}
+	}
+	for i := range statuses {
+		if statuses[i].Err == nil && statuses[i].CommonHash != canonical {
+			statuses[i].Diverged = true
+			log.Warn().
+				Str("url", statuses[i].URL).
+				Uint64("height", statuses[i].CommonHeight).
+				Str("hash", statuses[i].CommonHash.Hex()).
+				Str("expected", canonical.Hex()).
+				Msg("Endpoint has diverged")
+		}
+	}
+
+	return statuses
+}
+
+func getEndpointStatus(ctx context.Context, e endpoint) endpointStatus {
+	status := endpointStatus{URL: e.URL}
+
+	cs, err := getChainState(ctx, e.rpc)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	status.HeadBlock = cs.HeadBlock
+	status.GasPrice = cs.GasPrice
+	status.PeerCount = cs.PeerCount
+	status.PendingCount = cs.PendingCount
+
+	header, err := e.client.HeaderByNumber(ctx, new(big.Int).SetUint64(cs.HeadBlock))
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	status.HeadHash = header.Hash()
+
+	return status
+}
+
+// renderEndpoints updates the endpoints table with the latest statuses.
+// Diverged endpoints are highlighted in red and unreachable endpoints in
+// yellow.
+func renderEndpoints(table *widgets.Table, statuses []endpointStatus) {
+	table.Rows = [][]string{{"Endpoint", "Head", "Head Hash", "Common Height", "Common Hash", "Gas Price", "Peers", "Pending"}}
+	table.RowStyles = map[int]ui.Style{0: ui.NewStyle(ui.ColorWhite, ui.ColorClear, ui.ModifierBold)}
+
+	for i, s := range statuses {
+		if s.Err != nil {
+			table.Rows = append(table.Rows, []string{s.URL, "-", "-", "-", "-", "-", "-", s.Err.Error()})
+			table.RowStyles[i+1] = ui.NewStyle(ui.ColorYellow)
+			continue
+		}
+
+		gasPrice := "-"
+		if s.GasPrice != nil {
+			gasPrice = fmt.Sprintf("%s gwei", new(big.Int).Div(s.GasPrice, metrics.UnitShannon))
+		}
+
+		table.Rows = append(table.Rows, []string{
+			s.URL,
+			fmt.Sprint(s.HeadBlock),
+			shortHash(s.HeadHash),
+			fmt.Sprint(s.CommonHeight),
+			shortHash(s.CommonHash),
+			gasPrice,
+			fmt.Sprint(s.PeerCount),
+			fmt.Sprint(s.PendingCount),
+		})
+		if s.Diverged {
+			table.RowStyles[i+1] = ui.NewStyle(ui.ColorRed, ui.ColorClear, ui.ModifierBold)
+		}
+	}
+}
+
+// shortHash abbreviates the hash so the endpoints table fits on the screen.
+func shortHash(hash common.Hash) string {
+	hex := hash.Hex()
+	return hex[:10] + "..." + hex[len(hex)-4:]
+}
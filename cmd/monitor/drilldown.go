@@ -0,0 +1,215 @@
+package monitor
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/metrics"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/maticnetwork/polygon-cli/util"
+)
+
+// receiptState is the receipt of the transaction open in the transaction
+// view. It's fetched in the background so the view opens right away.
+type receiptState struct {
+	Hash    common.Hash
+	Receipt *rpctypes.RawTxReceipt
+	Err     error
+	Lock    sync.RWMutex
+}
+
+// fetch fetches the receipt of the transaction unless it's already the one
+// fetched.
+func (state *receiptState) fetch(ctx context.Context, rpc *ethrpc.Client, hash common.Hash) {
+	state.Lock.Lock()
+	if state.Hash == hash {
+		state.Lock.Unlock()
+		return
+	}
+	state.Hash = hash
+	state.Receipt = nil
+	state.Err = nil
+	if rpc == nil {
+		state.Err = errNotRecorded
+	}
+	state.Lock.Unlock()
+
+	if rpc == nil {
+		return
+	}
+
+	go func() {
+		var receipt rpctypes.RawTxReceipt
+		err := rpc.CallContext(ctx, &receipt, "eth_getTransactionReceipt", hash)
+		if err != nil {
+			log.Debug().Err(err).Str("hash", hash.Hex()).Msg("Unable to fetch transaction receipt")
+		}
+
+		state.Lock.Lock()
+		defer state.Lock.Unlock()
+		// Another transaction may have been opened while this one was fetched.
+		if state.Hash != hash {
+			return
+		}
+		state.Err = err
+		if err == nil {
+			state.Receipt = &receipt
+		}
+	}()
+}
+
+// getBlockTxRows returns one row per transaction of the block, which are
+// selected to open the transaction view.
+func getBlockTxRows(block rpctypes.PolyBlock) []string {
+	rows := make([]string, 0, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		rows = append(rows, fmt.Sprintf("%-4d %s  %s -> %s  %s  %s ether",
+			i, shortHash(tx.Hash()), shortAddress(tx.From()), txRecipient(tx),
+			txMethod(tx), weiToUnit(tx.Value(), metrics.UnitEther)))
+	}
+	return rows
+}
+
+func shortAddress(address common.Address) string {
+	s := address.Hex()
+	return s[:8] + ".." + s[len(s)-6:]
+}
+
+func txRecipient(tx rpctypes.PolyTransaction) string {
+	if tx.To() == (common.Address{}) {
+		return "contract creation"
+	}
+	return shortAddress(tx.To())
+}
+
+// txMethod is the selector of a contract call, or the kind of transaction
+// otherwise.
+func txMethod(tx rpctypes.PolyTransaction) string {
+	switch {
+	case tx.To() == (common.Address{}):
+		return "deploy"
+	case len(tx.Data()) >= 4:
+		return "0x" + hex.EncodeToString(tx.Data()[:4])
+	default:
+		return "transfer"
+	}
+}
+
+type txUI struct {
+	grid    *ui.Grid
+	header  *widgets.Paragraph
+	fields  *widgets.List
+	receipt *widgets.List
+}
+
+func newTxUI() *txUI {
+	t := &txUI{
+		grid:    ui.NewGrid(),
+		header:  widgets.NewParagraph(),
+		fields:  widgets.NewList(),
+		receipt: widgets.NewList(),
+	}
+
+	t.header.Title = "Transaction"
+	t.header.Text = "Use the arrow keys to scroll through the receipt. Press <Esc> to go back to the block view"
+
+	t.fields.Title = "Transaction"
+	t.fields.TextStyle = ui.NewStyle(ui.ColorYellow)
+	t.fields.WrapText = true
+
+	t.receipt.Title = "Receipt"
+	t.receipt.TextStyle = ui.NewStyle(ui.ColorGreen)
+	t.receipt.WrapText = true
+
+	t.grid.Set(
+		ui.NewRow(1.0/10, t.header),
+		ui.NewRow(9.0/10,
+			ui.NewCol(1.0/2, t.fields),
+			ui.NewCol(1.0/2, t.receipt),
+		),
+	)
+
+	return t
+}
+
+func (t *txUI) render(block rpctypes.PolyBlock, tx rpctypes.PolyTransaction, index int, state *receiptState) {
+	name := util.TxTypeName(tx.Type())
+
+	t.fields.Title = fmt.Sprintf("Transaction %d of block %s", index, block.Number())
+	t.fields.Rows = []string{
+		"",
+		fmt.Sprintf("Hash:         %s", tx.Hash()),
+		fmt.Sprintf("Type:         %d (%s)", tx.Type(), name),
+		fmt.Sprintf("From:         %s", tx.From()),
+		fmt.Sprintf("To:           %s", txRecipient(tx)),
+		fmt.Sprintf("Method:       %s", txMethod(tx)),
+		fmt.Sprintf("Value:        %s ether", weiToUnit(tx.Value(), metrics.UnitEther)),
+		fmt.Sprintf("Nonce:        %d", tx.Nonce()),
+		fmt.Sprintf("Gas limit:    %d", tx.Gas()),
+		fmt.Sprintf("Gas price:    %s gwei", weiToUnit(tx.GasPrice(), metrics.UnitShannon)),
+	}
+	if tx.Type() >= 2 {
+		t.fields.Rows = append(t.fields.Rows,
+			fmt.Sprintf("Max fee:      %s gwei", weiToUnit(new(big.Int).SetUint64(tx.MaxFeePerGas()), metrics.UnitShannon)),
+			fmt.Sprintf("Max tip:      %s gwei", weiToUnit(new(big.Int).SetUint64(tx.MaxPriorityFeePerGas()), metrics.UnitShannon)),
+		)
+	}
+	t.fields.Rows = append(t.fields.Rows,
+		fmt.Sprintf("Chain ID:     %d", tx.ChainID()),
+		fmt.Sprintf("Data size:    %d bytes", len(tx.Data())),
+		fmt.Sprintf("Data:         0x%s", hex.EncodeToString(tx.Data())),
+	)
+
+	state.Lock.RLock()
+	receipt, err := state.Receipt, state.Err
+	state.Lock.RUnlock()
+
+	switch {
+	case err != nil:
+		t.receipt.Rows = []string{"", fmt.Sprintf("Unable to fetch the receipt: %s", err)}
+		return
+	case receipt == nil:
+		t.receipt.Rows = []string{"", "Loading receipt..."}
+		return
+	}
+
+	// The receipts before Byzantium have a state root instead of a status.
+	status := "failed"
+	switch {
+	case len(receipt.Status) == 0:
+		status = "unknown"
+	case receipt.Status.ToUint64() == 1:
+		status = "success"
+	}
+	gasUsed := receipt.GasUsed.ToBigInt()
+	fee := new(big.Int).Mul(gasUsed, receipt.EffectiveGasPrice.ToBigInt())
+
+	rows := []string{
+		"",
+		fmt.Sprintf("Status:              %s", status),
+		fmt.Sprintf("Gas used:            %s (%.2f%% of the limit)", gasUsed, 100*float64(gasUsed.Uint64())/float64(max(int(tx.Gas()), 1))),
+		fmt.Sprintf("Effective gas price: %s gwei", weiToUnit(receipt.EffectiveGasPrice.ToBigInt(), metrics.UnitShannon)),
+		fmt.Sprintf("Fee:                 %s ether", weiToUnit(fee, metrics.UnitEther)),
+		fmt.Sprintf("Cumulative gas used: %s", receipt.CumulativeGasUsed.ToBigInt()),
+	}
+	if len(receipt.ContractAddress) > 0 {
+		rows = append(rows, fmt.Sprintf("Contract address:    %s", receipt.ContractAddress.ToAddress()))
+	}
+	rows = append(rows, fmt.Sprintf("Logs:                %d", len(receipt.Logs)))
+	for i, l := range receipt.Logs {
+		rows = append(rows, "", fmt.Sprintf("Log %d: %s", i, l.Address.ToAddress()))
+		for j, topic := range l.Topics {
+			rows = append(rows, fmt.Sprintf("  Topic %d: %s", j, topic.ToHash()))
+		}
+	}
+	t.receipt.Rows = rows
+}
@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+const alertKindFinalityStall alertKind = "finality_stall"
+
+// finalityState is the latest, safe, and finalized heads, and for zkEVM
+// endpoints the trusted, virtual, and verified batch numbers. The Has fields
+// are false when the endpoint doesn't support the block tag or method.
+type finalityState struct {
+	Latest       uint64
+	Safe         uint64
+	Finalized    uint64
+	HasSafe      bool
+	HasFinalized bool
+
+	TrustedBatch  uint64
+	VirtualBatch  uint64
+	VerifiedBatch uint64
+	HasBatches    bool
+
+	// FinalizedAt is when the finalized head last advanced, which is used to
+	// detect finality stalls.
+	FinalizedAt time.Time
+	Stalled     bool
+}
+
+// SafeLag is the number of blocks between the latest and safe heads.
+func (f *finalityState) SafeLag() uint64 {
+	return lag(f.Latest, f.Safe)
+}
+
+// FinalizedLag is the number of blocks between the latest and finalized
+// heads.
+func (f *finalityState) FinalizedLag() uint64 {
+	return lag(f.Latest, f.Finalized)
+}
+
+// VirtualLag is the number of batches that are trusted but not yet virtual.
+func (f *finalityState) VirtualLag() uint64 {
+	return lag(f.TrustedBatch, f.VirtualBatch)
+}
+
+// VerifiedLag is the number of batches that are trusted but not yet verified.
+func (f *finalityState) VerifiedLag() uint64 {
+	return lag(f.TrustedBatch, f.VerifiedBatch)
+}
+
+func lag(head, behind uint64) uint64 {
+	if behind > head {
+		return 0
+	}
+	return head - behind
+}
+
+// finalityRequest is the calls for the safe and finalized heads and the zkEVM
+// batch numbers, which are sent in the batch of every refresh.
+type finalityRequest struct {
+	safe, finalized                 rpctypes.RawBlockResponse
+	trusted, virtual, verifiedBatch rpctypes.RawQuantityResponse
+
+	batch []ethrpc.BatchElem
+}
+
+func newFinalityRequest() *finalityRequest {
+	r := new(finalityRequest)
+	r.batch = []ethrpc.BatchElem{
+		{Method: "eth_getBlockByNumber", Args: []interface{}{"safe", false}, Result: &r.safe},
+		{Method: "eth_getBlockByNumber", Args: []interface{}{"finalized", false}, Result: &r.finalized},
+		{Method: "zkevm_batchNumber", Result: &r.trusted},
+		{Method: "zkevm_virtualBatchNumber", Result: &r.virtual},
+		{Method: "zkevm_verifiedBatchNumber", Result: &r.verifiedBatch},
+	}
+	return r
+}
+
+// updateFinality applies the safe and finalized heads and the zkEVM batch
+// numbers once the batch was sent. Errors for individual elements are
+// expected on endpoints that don't support them so they only mark the value
+// unavailable.
+func (ms *monitorStatus) updateFinality(ctx context.Context, r *finalityRequest) {
+	batch := r.batch
+	safe, finalized := r.safe, r.finalized
+	trusted, virtual, verifiedBatch := r.trusted, r.virtual, r.verifiedBatch
+
+	ms.FinalityLock.Lock()
+	defer ms.FinalityLock.Unlock()
+
+	f := ms.Finality
+	f.Latest = ms.HeadBlock.Uint64()
+	f.HasSafe = batch[0].Error == nil && len(safe.Number) > 0
+	if f.HasSafe {
+		f.Safe = safe.Number.ToUint64()
+	}
+
+	f.HasFinalized = batch[1].Error == nil && len(finalized.Number) > 0
+	if f.HasFinalized {
+		number := finalized.Number.ToUint64()
+		if number != f.Finalized || f.FinalizedAt.IsZero() {
+			f.FinalizedAt = time.Now()
+			f.Stalled = false
+		}
+		f.Finalized = number
+	}
+
+	f.HasBatches = batch[2].Error == nil && batch[3].Error == nil && batch[4].Error == nil
+	if f.HasBatches {
+		f.TrustedBatch = trusted.ToUint64()
+		f.VirtualBatch = virtual.ToUint64()
+		f.VerifiedBatch = verifiedBatch.ToUint64()
+	}
+
+	if finalityStall > 0 && f.HasFinalized && !f.Stalled && time.Since(f.FinalizedAt) > finalityStall {
+		f.Stalled = true
+		if ms.Alerts != nil {
+			ms.Alerts.add(ctx, alertKindFinalityStall, ms.HeadBlock,
+				"Finalized head has been stuck at block %d for more than %s", f.Finalized, finalityStall)
+		}
+	}
+	ms.Finality = f
+}
+
+// renderFinality shows the heads with their lag behind the latest block. The
+// text turns red when finality has stalled.
+func renderFinality(p *widgets.Paragraph, f finalityState) {
+	p.Title = "Finality"
+	p.TextStyle = ui.NewStyle(ui.ColorWhite)
+	if f.Stalled {
+		p.Title = "Finality (stalled)"
+		p.TextStyle = ui.NewStyle(ui.ColorRed, ui.ColorClear, ui.ModifierBold)
+	}
+
+	safe, finalized := "n/a", "n/a"
+	if f.HasSafe {
+		safe = fmt.Sprintf("%d (-%d)", f.Safe, f.SafeLag())
+	}
+	if f.HasFinalized {
+		finalized = fmt.Sprintf("%d (-%d)", f.Finalized, f.FinalizedLag())
+	}
+	p.Text = fmt.Sprintf("Safe: %s\nFinal: %s", safe, finalized)
+
+	if f.HasBatches {
+		p.Text += fmt.Sprintf("\nBatch: %d Virt: -%d Ver: -%d", f.TrustedBatch, f.VirtualLag(), f.VerifiedLag())
+	}
+}
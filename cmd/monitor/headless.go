@@ -0,0 +1,363 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/metrics"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+const (
+	headlessOutputJSON       = "json"
+	headlessOutputPrometheus = "prometheus"
+
+	// headlessRetainedBlocks is the number of blocks behind the head that are
+	// kept in memory when running without the terminal UI, since nothing
+	// scrolls back through the history.
+	headlessRetainedBlocks = 256
+)
+
+type (
+	// headlessBlock is the summary of a block emitted in headless mode.
+	headlessBlock struct {
+		Number         uint64  `json:"number"`
+		Hash           string  `json:"hash"`
+		Timestamp      uint64  `json:"timestamp"`
+		BlockTime      float64 `json:"blockTime"`
+		TxCount        int     `json:"txCount"`
+		GasUsed        uint64  `json:"gasUsed"`
+		GasLimit       uint64  `json:"gasLimit"`
+		GasUsedPercent float64 `json:"gasUsedPercent"`
+		BaseFee        string  `json:"baseFee"`
+	}
+
+	headlessAlert struct {
+		Time    time.Time `json:"time"`
+		Kind    alertKind `json:"kind"`
+		Block   string    `json:"block"`
+		Message string    `json:"message"`
+	}
+
+	// headlessSnapshot is a single JSON line emitted after every poll. Only
+	// the blocks and alerts that are new since the previous line are included.
+	headlessSnapshot struct {
+		Time          time.Time        `json:"time"`
+		ChainID       string           `json:"chainId"`
+		HeadBlock     string           `json:"headBlock"`
+		PeerCount     uint64           `json:"peerCount"`
+		GasPrice      string           `json:"gasPrice"`
+		PendingCount  uint             `json:"pendingCount"`
+		MeanBlockTime float64          `json:"meanBlockTime"`
+		Blocks        []headlessBlock  `json:"blocks"`
+		Endpoints     []endpointStatus `json:"endpoints,omitempty"`
+		Alerts        []headlessAlert  `json:"alerts,omitempty"`
+		Accounts      []watchedAccount `json:"accounts,omitempty"`
+		Finality      finalityState    `json:"finality"`
+		Peers         peerState        `json:"peers"`
+	}
+
+	// monitorMetrics are the Prometheus metrics exposed in headless mode.
+	monitorMetrics struct {
+		headBlock        prometheus.Gauge
+		chainID          prometheus.Gauge
+		peerCount        prometheus.Gauge
+		gasPrice         prometheus.Gauge
+		pendingCount     prometheus.Gauge
+		meanBlockTime    prometheus.Gauge
+		blockTime        prometheus.Gauge
+		txCount          prometheus.Gauge
+		gasUsed          prometheus.Gauge
+		gasUsedPercent   prometheus.Gauge
+		baseFee          prometheus.Gauge
+		endpointHead     *prometheus.GaugeVec
+		endpointPeers    *prometheus.GaugeVec
+		endpointUp       *prometheus.GaugeVec
+		endpointDiverged *prometheus.GaugeVec
+		alerts           *prometheus.GaugeVec
+		watchedBalance   *prometheus.GaugeVec
+		watchedNonce     *prometheus.GaugeVec
+		safeBlock        prometheus.Gauge
+		finalizedBlock   prometheus.Gauge
+		safeLag          prometheus.Gauge
+		finalizedLag     prometheus.Gauge
+		trustedBatch     prometheus.Gauge
+		virtualBatch     prometheus.Gauge
+		verifiedBatch    prometheus.Gauge
+	}
+)
+
+func newMonitorMetrics(registry *prometheus.Registry) *monitorMetrics {
+	gauge := func(name, help string) prometheus.Gauge {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "polycli", Subsystem: "monitor", Name: name, Help: help})
+		registry.MustRegister(g)
+		return g
+	}
+	gaugeVec := func(name, help string, labels ...string) *prometheus.GaugeVec {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: "polycli", Subsystem: "monitor", Name: name, Help: help}, labels)
+		registry.MustRegister(g)
+		return g
+	}
+
+	return &monitorMetrics{
+		headBlock:        gauge("head_block", "The latest block number"),
+		chainID:          gauge("chain_id", "The chain ID"),
+		peerCount:        gauge("peer_count", "The number of peers connected to the node"),
+		gasPrice:         gauge("gas_price_wei", "The suggested gas price in wei"),
+		pendingCount:     gauge("pending_transactions", "The number of pending transactions"),
+		meanBlockTime:    gauge("mean_block_time_seconds", "The mean block time of the retained blocks"),
+		blockTime:        gauge("block_time_seconds", "The time between the latest block and its parent"),
+		txCount:          gauge("block_transactions", "The number of transactions in the latest block"),
+		gasUsed:          gauge("block_gas_used", "The gas used by the latest block"),
+		gasUsedPercent:   gauge("block_gas_used_percent", "The percentage of the gas limit used by the latest block"),
+		baseFee:          gauge("block_base_fee_wei", "The base fee of the latest block in wei"),
+		endpointHead:     gaugeVec("endpoint_head_block", "The latest block number of each compared endpoint", "url"),
+		endpointPeers:    gaugeVec("endpoint_peer_count", "The peer count of each compared endpoint", "url"),
+		endpointUp:       gaugeVec("endpoint_up", "Whether the compared endpoint responded", "url"),
+		endpointDiverged: gaugeVec("endpoint_diverged", "Whether the compared endpoint has diverged from the others", "url"),
+		alerts:           gaugeVec("alerts", "The number of alerts raised since the monitor started", "kind"),
+		watchedBalance:   gaugeVec("watched_balance_wei", "The balance of each watched address in wei", "address"),
+		watchedNonce:     gaugeVec("watched_nonce", "The nonce of each watched address", "address"),
+		safeBlock:        gauge("safe_block", "The safe head block number"),
+		finalizedBlock:   gauge("finalized_block", "The finalized head block number"),
+		safeLag:          gauge("safe_lag_blocks", "The number of blocks between the latest and safe heads"),
+		finalizedLag:     gauge("finalized_lag_blocks", "The number of blocks between the latest and finalized heads"),
+		trustedBatch:     gauge("zkevm_trusted_batch", "The latest trusted zkEVM batch number"),
+		virtualBatch:     gauge("zkevm_virtual_batch", "The latest virtual zkEVM batch number"),
+		verifiedBatch:    gauge("zkevm_verified_batch", "The latest verified zkEVM batch number"),
+	}
+}
+
+// runHeadless polls the same data as the terminal UI but emits it as JSON
+// lines or Prometheus metrics instead of rendering it.
+func runHeadless(ctx context.Context, ec *ethclient.Client, ms *monitorStatus, rpc *ethrpc.Client, endpoints []endpoint) error {
+	var m *monitorMetrics
+	if headlessOutput == headlessOutputPrometheus {
+		registry := prometheus.NewRegistry()
+		m = newMonitorMetrics(registry)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		server := &http.Server{Addr: prometheusAddr, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Err(err).Msg("Failed to start the metrics server")
+			}
+		}()
+		defer server.Close()
+		log.Info().Str("addr", prometheusAddr).Msg("Serving Prometheus metrics")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	var (
+		lastBlock uint64
+		lastAlert time.Time
+	)
+
+	for {
+		if err := fetchBlocks(ctx, ec, ms, rpc, endpoints, false); err == nil {
+			ms.pruneBlocks(headlessRetainedBlocks)
+			blocks := metrics.SortableBlocks(updateAllBlocks(ms))
+			sort.Sort(blocks)
+
+			if m != nil {
+				m.update(ms, blocks)
+			} else {
+				snapshot := newHeadlessSnapshot(ms, blocks, lastBlock, lastAlert)
+				if err = encoder.Encode(snapshot); err != nil {
+					return err
+				}
+				if len(snapshot.Blocks) > 0 {
+					lastBlock = snapshot.Blocks[len(snapshot.Blocks)-1].Number
+				}
+				if len(snapshot.Alerts) > 0 {
+					lastAlert = snapshot.Alerts[len(snapshot.Alerts)-1].Time
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(tuner.interval()):
+		}
+	}
+}
+
+// pruneBlocks drops blocks that are more than the given number of blocks
+// behind the head.
+func (ms *monitorStatus) pruneBlocks(retain int64) {
+	min := new(big.Int).Sub(ms.HeadBlock, big.NewInt(retain-1))
+	if min.Sign() <= 0 {
+		return
+	}
+
+	ms.BlocksLock.Lock()
+	defer ms.BlocksLock.Unlock()
+	for key, block := range ms.Blocks {
+		if block.Number().Cmp(min) == -1 {
+			delete(ms.Blocks, key)
+		}
+	}
+	if ms.MinBlockRetrieved != nil && ms.MinBlockRetrieved.Cmp(min) == -1 {
+		ms.MinBlockRetrieved = min
+	}
+}
+
+func newHeadlessSnapshot(ms *monitorStatus, blocks metrics.SortableBlocks, lastBlock uint64, lastAlert time.Time) headlessSnapshot {
+	snapshot := headlessSnapshot{
+		Time:          time.Now(),
+		ChainID:       ms.ChainID.String(),
+		HeadBlock:     ms.HeadBlock.String(),
+		PeerCount:     ms.PeerCount,
+		GasPrice:      ms.GasPrice.String(),
+		PendingCount:  ms.PendingCount,
+		MeanBlockTime: metrics.GetMeanBlockTime(blocks),
+		Blocks:        []headlessBlock{},
+	}
+
+	blockTimes := metrics.GetBlockTimePerBlock(blocks)
+	gasUsedPercents := metrics.GetGasUsedPercentPerBlock(blocks)
+	for i, block := range blocks {
+		if block.Number().Uint64() <= lastBlock {
+			continue
+		}
+		snapshot.Blocks = append(snapshot.Blocks, newHeadlessBlock(block, blockTimes[i], gasUsedPercents[i]))
+	}
+
+	ms.EndpointsLock.RLock()
+	snapshot.Endpoints = ms.Endpoints
+	ms.EndpointsLock.RUnlock()
+
+	ms.WatchedLock.RLock()
+	snapshot.Accounts = ms.Watched
+	ms.WatchedLock.RUnlock()
+
+	ms.FinalityLock.RLock()
+	snapshot.Finality = ms.Finality
+	ms.FinalityLock.RUnlock()
+
+	ms.PeersLock.RLock()
+	snapshot.Peers = ms.Peers
+	ms.PeersLock.RUnlock()
+
+	for _, al := range ms.Alerts.since(lastAlert) {
+		snapshot.Alerts = append(snapshot.Alerts, headlessAlert{
+			Time:    al.Time,
+			Kind:    al.Kind,
+			Block:   al.Block.String(),
+			Message: al.Message,
+		})
+	}
+
+	return snapshot
+}
+
+func newHeadlessBlock(block rpctypes.PolyBlock, blockTime, gasUsedPercent float64) headlessBlock {
+	return headlessBlock{
+		Number:         block.Number().Uint64(),
+		Hash:           block.Hash().Hex(),
+		Timestamp:      block.Time(),
+		BlockTime:      blockTime,
+		TxCount:        len(block.Transactions()),
+		GasUsed:        block.GasUsed(),
+		GasLimit:       block.GasLimit(),
+		GasUsedPercent: gasUsedPercent,
+		BaseFee:        block.BaseFee().String(),
+	}
+}
+
+func (m *monitorMetrics) update(ms *monitorStatus, blocks metrics.SortableBlocks) {
+	toFloat := func(i *big.Int) float64 {
+		f, _ := new(big.Float).SetInt(i).Float64()
+		return f
+	}
+
+	m.headBlock.Set(toFloat(ms.HeadBlock))
+	m.chainID.Set(toFloat(ms.ChainID))
+	m.peerCount.Set(float64(ms.PeerCount))
+	m.gasPrice.Set(toFloat(ms.GasPrice))
+	m.pendingCount.Set(float64(ms.PendingCount))
+	m.meanBlockTime.Set(metrics.GetMeanBlockTime(blocks))
+
+	if len(blocks) > 0 {
+		latest := blocks[len(blocks)-1]
+		blockTimes := metrics.GetBlockTimePerBlock(blocks)
+		gasUsedPercents := metrics.GetGasUsedPercentPerBlock(blocks)
+
+		m.blockTime.Set(blockTimes[len(blockTimes)-1])
+		m.txCount.Set(float64(len(latest.Transactions())))
+		m.gasUsed.Set(float64(latest.GasUsed()))
+		m.gasUsedPercent.Set(gasUsedPercents[len(gasUsedPercents)-1])
+		m.baseFee.Set(toFloat(latest.BaseFee()))
+	}
+
+	ms.EndpointsLock.RLock()
+	for _, e := range ms.Endpoints {
+		if e.Err != nil {
+			m.endpointUp.WithLabelValues(e.URL).Set(0)
+			continue
+		}
+		m.endpointUp.WithLabelValues(e.URL).Set(1)
+		m.endpointHead.WithLabelValues(e.URL).Set(float64(e.HeadBlock))
+		m.endpointPeers.WithLabelValues(e.URL).Set(float64(e.PeerCount))
+		diverged := 0.0
+		if e.Diverged {
+			diverged = 1
+		}
+		m.endpointDiverged.WithLabelValues(e.URL).Set(diverged)
+	}
+	ms.EndpointsLock.RUnlock()
+
+	ms.WatchedLock.RLock()
+	for _, account := range ms.Watched {
+		if account.Balance == nil {
+			continue
+		}
+		m.watchedBalance.WithLabelValues(account.Address.Hex()).Set(toFloat(account.Balance))
+		m.watchedNonce.WithLabelValues(account.Address.Hex()).Set(float64(account.Nonce))
+	}
+	ms.WatchedLock.RUnlock()
+
+	ms.FinalityLock.RLock()
+	f := ms.Finality
+	ms.FinalityLock.RUnlock()
+	if f.HasSafe {
+		m.safeBlock.Set(float64(f.Safe))
+		m.safeLag.Set(float64(f.SafeLag()))
+	}
+	if f.HasFinalized {
+		m.finalizedBlock.Set(float64(f.Finalized))
+		m.finalizedLag.Set(float64(f.FinalizedLag()))
+	}
+	if f.HasBatches {
+		m.trustedBatch.Set(float64(f.TrustedBatch))
+		m.virtualBatch.Set(float64(f.VirtualBatch))
+		m.verifiedBatch.Set(float64(f.VerifiedBatch))
+	}
+
+	for kind, count := range ms.Alerts.counts() {
+		m.alerts.WithLabelValues(string(kind)).Set(float64(count))
+	}
+}
+
+func validateHeadlessOutput(output string) error {
+	switch output {
+	case headlessOutputJSON, headlessOutputPrometheus:
+		return nil
+	default:
+		return fmt.Errorf("invalid output %q, expected one of: %s, %s", output, headlessOutputJSON, headlessOutputPrometheus)
+	}
+}
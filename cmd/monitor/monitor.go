@@ -12,6 +12,7 @@ import (
 
 	_ "embed"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	ethrpc "github.com/ethereum/go-ethereum/rpc"
 
@@ -19,7 +20,9 @@ import (
 	ui "github.com/gizak/termui/v3"
 	"github.com/gizak/termui/v3/widgets"
 	"github.com/maticnetwork/polygon-cli/metrics"
+	"github.com/maticnetwork/polygon-cli/multicall"
 	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
@@ -32,6 +35,24 @@ var (
 	batchSize      int
 	intervalStr    string
 	interval       time.Duration
+	historyBlocks  uint64
+	chartsCSVFile  string
+	maxBlockGap    time.Duration
+	alertWebhook   string
+	noTui          bool
+	headlessOutput string
+	prometheusAddr string
+	watchFlag      []string
+	watchAddresses []common.Address
+	finalityStall  time.Duration
+	recordFile     string
+	replayFile     string
+	replaySpeed    float64
+	recorder       *sessionRecorder
+	maxInterval    time.Duration
+	tuner          *pollTuner
+	multicallAddr  string
+	watchReader    *multicall.Reader
 
 	one           = big.NewInt(1)
 	zero          = big.NewInt(0)
@@ -54,6 +75,24 @@ type (
 		BlocksLock        sync.RWMutex                  `json:"-"`
 		MaxBlockRetrieved *big.Int
 		MinBlockRetrieved *big.Int
+
+		Endpoints     []endpointStatus
+		EndpointsLock sync.RWMutex `json:"-"`
+
+		Alerts *alertLog `json:"-"`
+
+		Watched     []watchedAccount
+		WatchedLock sync.RWMutex `json:"-"`
+
+		Finality     finalityState
+		FinalityLock sync.RWMutex `json:"-"`
+
+		Peers     peerState
+		PeersLock sync.RWMutex `json:"-"`
+
+		// ReplayTime is when the frame being replayed was recorded. It's zero
+		// when the monitor isn't replaying a session.
+		ReplayTime time.Time `json:"-"`
 	}
 	chainState struct {
 		HeadBlock    uint64
@@ -73,6 +112,7 @@ type (
 		h2  *widgets.Paragraph
 		h3  *widgets.Paragraph
 		h4  *widgets.Paragraph
+		h5  *widgets.Paragraph
 		sl0 *widgets.Sparkline
 		sl1 *widgets.Sparkline
 		sl2 *widgets.Sparkline
@@ -80,6 +120,8 @@ type (
 		sl4 *widgets.Sparkline
 		b1  *widgets.List
 		b2  *widgets.List
+		e0  *widgets.Table
+		a0  *widgets.List
 	}
 	monitorMode int
 )
@@ -88,39 +130,72 @@ const (
 	monitorModeHelp monitorMode = iota
 	monitorModeExplorer
 	monitorModeBlock
+	monitorModeTxPool
+	monitorModeCharts
+	monitorModeWatch
+	monitorModePeers
+	monitorModeTransaction
 )
 
-func getChainState(ctx context.Context, ec *ethclient.Client) (*chainState, error) {
-	var err error
-	cs := new(chainState)
-	cs.HeadBlock, err = ec.BlockNumber(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't fetch block number: %s", err.Error())
+// chainStateRequest is the calls for the chain state, which are sent in the
+// batch of every refresh.
+type chainStateRequest struct {
+	head, chainID, peers, gasPrice, pending rpctypes.RawQuantityResponse
+
+	batch []ethrpc.BatchElem
+}
+
+func newChainStateRequest() *chainStateRequest {
+	r := new(chainStateRequest)
+	r.batch = []ethrpc.BatchElem{
+		{Method: "eth_blockNumber", Result: &r.head},
+		{Method: "eth_chainId", Result: &r.chainID},
+		{Method: "net_peerCount", Result: &r.peers},
+		{Method: "eth_gasPrice", Result: &r.gasPrice},
+		{Method: "eth_getBlockTransactionCountByNumber", Args: []interface{}{"pending"}, Result: &r.pending},
 	}
+	return r
+}
 
-	cs.ChainID, err = ec.ChainID(ctx)
-	if err != nil {
+// chainState returns the chain state once the batch was sent. The peer and
+// pending transaction counts are optional since not every endpoint supports
+// them.
+func (r *chainStateRequest) chainState() (*chainState, error) {
+	if err := r.batch[0].Error; err != nil {
+		return nil, fmt.Errorf("couldn't fetch block number: %s", err.Error())
+	}
+	if err := r.batch[1].Error; err != nil {
 		return nil, fmt.Errorf("couldn't fetch chain id: %s", err.Error())
 	}
-
-	cs.PeerCount, err = ec.PeerCount(ctx)
-	if err != nil {
-		log.Debug().Err(err).Msg("Using fake peer count")
-		cs.PeerCount = 0
+	if err := r.batch[3].Error; err != nil {
+		return nil, fmt.Errorf("couldn't estimate gas: %s", err.Error())
 	}
 
-	cs.GasPrice, err = ec.SuggestGasPrice(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't estimate gas: %s", err.Error())
+	cs := &chainState{
+		HeadBlock: r.head.ToUint64(),
+		ChainID:   r.chainID.ToBigInt(),
+		GasPrice:  r.gasPrice.ToBigInt(),
 	}
-	cs.PendingCount, err = ec.PendingTransactionCount(ctx)
-	if err != nil {
+	if err := r.batch[2].Error; err != nil {
+		log.Debug().Err(err).Msg("Using fake peer count")
+	} else {
+		cs.PeerCount = r.peers.ToUint64()
+	}
+	if err := r.batch[4].Error; err != nil {
 		log.Debug().Err(err).Msg("Unable to get pending transaction count")
-		cs.PendingCount = 0
+	} else {
+		cs.PendingCount = uint(r.pending.ToUint64())
 	}
 
 	return cs, nil
+}
 
+func getChainState(ctx context.Context, rpc *ethrpc.Client) (*chainState, error) {
+	r := newChainStateRequest()
+	if err := sendBatch(ctx, rpc, r.batch); err != nil {
+		return nil, err
+	}
+	return r.chainState()
 }
 
 func (h historicalRange) getValues(limit int) []float64 {
@@ -129,11 +204,11 @@ func (h historicalRange) getValues(limit int) []float64 {
 		values[idx] = v.SampleValue
 	}
 	if limit < len(values) {
-		values = values[len(values) - limit:]
+		values = values[len(values)-limit:]
 	}
 	return values
 }
-func prependLatestBlocks(ctx context.Context, ms *monitorStatus, rpc *ethrpc.Client) {
+func prependLatestBlocks(ctx context.Context, ms *monitorStatus, rpc *ethrpc.Client) error {
 	from := new(big.Int).Sub(ms.HeadBlock, big.NewInt(int64(batchSize-1)))
 	// Prevent getBlockRange from fetching duplicate blocks.
 	if ms.MaxBlockRetrieved.Cmp(from) == 1 {
@@ -154,6 +229,7 @@ func prependLatestBlocks(ctx context.Context, ms *monitorStatus, rpc *ethrpc.Cli
 	if err != nil {
 		log.Error().Err(err).Msg("There was an issue fetching the block range")
 	}
+	return err
 }
 
 func appendOlderBlocks(ctx context.Context, ms *monitorStatus, rpc *ethrpc.Client) error {
@@ -161,6 +237,9 @@ func appendOlderBlocks(ctx context.Context, ms *monitorStatus, rpc *ethrpc.Clien
 		log.Warn().Msg("Nil min block")
 		return fmt.Errorf("the min block is nil")
 	}
+	if rpc == nil {
+		return errNotRecorded
+	}
 	if !currentlyFetchingHistoryLock.TryLock() {
 		return fmt.Errorf("the function is currently locked")
 	}
@@ -186,12 +265,21 @@ func appendOlderBlocks(ctx context.Context, ms *monitorStatus, rpc *ethrpc.Clien
 	return nil
 }
 
-func fetchBlocks(ctx context.Context, ec *ethclient.Client, ms *monitorStatus, rpc *ethrpc.Client, isUiRendered bool) (err error) {
+func fetchBlocks(ctx context.Context, ec *ethclient.Client, ms *monitorStatus, rpc *ethrpc.Client, endpoints []endpoint, isUiRendered bool) (err error) {
+	// The chain state, finality, and peers are fetched in a single batch, and
+	// how long the refresh takes tunes the interval until the next one.
+	start := time.Now()
+	state, finality, peers := newChainStateRequest(), newFinalityRequest(), newPeersRequest()
+	err = sendBatch(ctx, rpc, state.batch, finality.batch, peers.batch)
+
 	var cs *chainState
-	cs, err = getChainState(ctx, ec)
+	if err == nil {
+		cs, err = state.chainState()
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Encountered issue fetching network information")
-		time.Sleep(interval)
+		tuner.observe(time.Since(start), err)
+		time.Sleep(tuner.interval())
 		return err
 	}
 	observedPendingTxs = append(observedPendingTxs, historicalDataPoint{SampleTime: time.Now(), SampleValue: float64(cs.PendingCount)})
@@ -211,7 +299,20 @@ func fetchBlocks(ctx context.Context, ec *ethclient.Client, ms *monitorStatus, r
 	ms.GasPrice = cs.GasPrice
 	ms.PendingCount = cs.PendingCount
 
-	prependLatestBlocks(ctx, ms, rpc)
+	ms.updateFinality(ctx, finality)
+	ms.updatePeers(peers)
+	ms.updateWatchedAccounts(ctx, rpc, watchAddresses)
+
+	if len(endpoints) > 1 {
+		statuses := compareEndpoints(ctx, endpoints)
+		ms.EndpointsLock.Lock()
+		ms.Endpoints = statuses
+		ms.EndpointsLock.Unlock()
+	}
+
+	blocksErr := prependLatestBlocks(ctx, ms, rpc)
+	tuner.observe(time.Since(start), blocksErr)
+
 	if shouldLoadMoreHistory(ctx, ms) {
 		err = appendOlderBlocks(ctx, ms, rpc)
 		if err != nil {
@@ -219,6 +320,10 @@ func fetchBlocks(ctx context.Context, ec *ethclient.Client, ms *monitorStatus, r
 		}
 	}
 
+	if recordErr := recorder.record(ms); recordErr != nil {
+		log.Error().Err(recordErr).Msg("Unable to record the session")
+	}
+
 	return
 }
 
@@ -244,15 +349,23 @@ func shouldLoadMoreHistory(ctx context.Context, ms *monitorStatus) bool {
 
 // monitorCmd represents the monitor command
 var MonitorCmd = &cobra.Command{
-	Use:   "monitor url",
+	Use:   "monitor url [url...]",
 	Short: "Monitor blocks using a JSON-RPC endpoint.",
 	Long:  usage,
-	Args:  cobra.MinimumNArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		// A replayed session doesn't need an endpoint.
+		if replayFile != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, util.WithProfileRPCURL(args))
+	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
-		// validate url argument
-		_, err := url.Parse(args[0])
-		if err != nil {
-			return err
+		// validate url arguments
+		var err error
+		for _, arg := range util.WithProfileRPCURL(args) {
+			if _, err = url.Parse(arg); err != nil {
+				return err
+			}
 		}
 
 		// validate interval duration
@@ -260,6 +373,33 @@ var MonitorCmd = &cobra.Command{
 			return err
 		}
 
+		if watchAddresses, err = parseWatchAddresses(watchFlag); err != nil {
+			return err
+		}
+
+		// The JSON results of the monitor are the snapshots of the headless
+		// mode.
+		if util.JSONOutput() {
+			if err = util.UseJSONFormat(cmd.Flags(), "output", headlessOutputJSON); err != nil {
+				return err
+			}
+			noTui = true
+		}
+		if noTui {
+			if err = validateHeadlessOutput(headlessOutput); err != nil {
+				return err
+			}
+		}
+
+		if replayFile != "" {
+			if noTui || recordFile != "" {
+				return fmt.Errorf("--replay can't be used with --no-tui, --json, or --record")
+			}
+			if replaySpeed <= 0 {
+				return fmt.Errorf("--replay-speed must be positive")
+			}
+		}
+
 		// validate batch-size flag
 		if batchSizeValue == "auto" {
 			batchSize = -1
@@ -279,40 +419,68 @@ var MonitorCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
-		rpc, err := ethrpc.DialContext(ctx, args[0])
+		ms := newMonitorStatus()
+		if replayFile != "" {
+			return replaySession(ctx, ms, replayFile, replaySpeed)
+		}
+
+		args = util.WithProfileRPCURL(args)
+		rpc, err := util.DialRPC(ctx, args[0])
 		if err != nil {
 			log.Error().Err(err).Msg("Unable to dial rpc")
 			return err
 		}
 		ec := ethclient.NewClient(rpc)
 
-		ms := new(monitorStatus)
+		// When more than one URL is provided, the first one drives the block
+		// explorer and all of them are compared side by side.
+		endpoints := []endpoint{{URL: args[0], client: ec, rpc: rpc}}
+		for _, arg := range args[1:] {
+			client, err := util.DialRPC(ctx, arg)
+			if err != nil {
+				log.Error().Err(err).Str("url", arg).Msg("Unable to dial rpc")
+				return err
+			}
+			endpoints = append(endpoints, endpoint{URL: arg, client: ethclient.NewClient(client), rpc: client})
+		}
 
-		ms.MaxBlockRetrieved = big.NewInt(0)
-		ms.BlocksLock.Lock()
-		ms.Blocks = make(map[string]rpctypes.PolyBlock, 0)
-		ms.BlocksLock.Unlock()
-		ms.ChainID = big.NewInt(0)
-		ms.PendingCount = 0
-		observedPendingTxs = make(historicalRange, 0)
+		tuner = newPollTuner(interval, maxInterval)
+
+		if len(watchAddresses) > 0 {
+			if watchReader, err = multicall.NewReader(ctx, rpc, multicallAddr, 0); err != nil {
+				return err
+			}
+		}
+
+		if recordFile != "" {
+			if recorder, err = newSessionRecorder(recordFile); err != nil {
+				return err
+			}
+			defer recorder.Close()
+		}
+
+		if noTui {
+			return runHeadless(ctx, ec, ms, rpc, endpoints)
+		}
 
 		isUiRendered := false
 		errChan := make(chan error)
 		go func() {
 			for {
-				err = fetchBlocks(ctx, ec, ms, rpc, isUiRendered)
+				err = fetchBlocks(ctx, ec, ms, rpc, endpoints, isUiRendered)
 				if err != nil {
 					continue
 				}
 
 				if !isUiRendered {
+					loadHistory(ms, func() error { return appendOlderBlocks(ctx, ms, rpc) }, historyBlocks)
 					go func() {
-						errChan <- renderMonitorUI(ctx, ec, ms, rpc)
+						errChan <- renderMonitorUI(ctx, ec, ms, rpc, len(endpoints) > 1)
 					}()
 					isUiRendered = true
 				}
 
-				time.Sleep(interval)
+				time.Sleep(tuner.interval())
 			}
 		}()
 
@@ -321,6 +489,21 @@ var MonitorCmd = &cobra.Command{
 	},
 }
 
+func newMonitorStatus() *monitorStatus {
+	ms := new(monitorStatus)
+
+	ms.MaxBlockRetrieved = big.NewInt(0)
+	ms.BlocksLock.Lock()
+	ms.Blocks = make(map[string]rpctypes.PolyBlock, 0)
+	ms.BlocksLock.Unlock()
+	ms.ChainID = big.NewInt(0)
+	ms.PendingCount = 0
+	ms.Alerts = newAlertLog(alertWebhook)
+	observedPendingTxs = make(historicalRange, 0)
+
+	return ms
+}
+
 func (ms *monitorStatus) getBlockRange(ctx context.Context, from, to *big.Int, rpc *ethrpc.Client) error {
 	blms := make([]ethrpc.BatchElem, 0)
 	for i := from; i.Cmp(to) != 1; i.Add(i, one) {
@@ -343,6 +526,7 @@ func (ms *monitorStatus) getBlockRange(ctx context.Context, from, to *big.Int, r
 	if err != nil {
 		return err
 	}
+	prevMax := new(big.Int).Set(ms.MaxBlockRetrieved)
 	for _, b := range blms {
 		if b.Error != nil {
 			return b.Error
@@ -350,9 +534,16 @@ func (ms *monitorStatus) getBlockRange(ctx context.Context, from, to *big.Int, r
 		pb := rpctypes.NewPolyBlock(b.Result.(*rpctypes.RawBlockResponse))
 
 		ms.BlocksLock.Lock()
+		parent := ms.Blocks[new(big.Int).Sub(pb.Number(), one).String()]
 		ms.Blocks[pb.Number().String()] = pb
 		ms.BlocksLock.Unlock()
 
+		// Only check blocks past the previous head so loading older history
+		// doesn't raise alerts.
+		if prevMax.Sign() == 1 && pb.Number().Cmp(prevMax) == 1 {
+			ms.checkNewBlock(ctx, rpc, pb, parent)
+		}
+
 		if ms.MaxBlockRetrieved.Cmp(pb.Number()) == -1 {
 			ms.MaxBlockRetrieved = pb.Number()
 		}
@@ -367,9 +558,23 @@ func (ms *monitorStatus) getBlockRange(ctx context.Context, from, to *big.Int, r
 func init() {
 	MonitorCmd.PersistentFlags().StringVarP(&batchSizeValue, "batch-size", "b", "auto", "Number of requests per batch")
 	MonitorCmd.PersistentFlags().StringVarP(&intervalStr, "interval", "i", "5s", "Amount of time between batch block rpc calls")
+	MonitorCmd.PersistentFlags().DurationVar(&maxInterval, "max-interval", time.Minute, "Longest the interval is raised to when the endpoint is slow or rate limited (set it to the interval to disable)")
+	MonitorCmd.PersistentFlags().Uint64Var(&historyBlocks, "history-blocks", 0, "Number of blocks behind the head to load on startup for the charts")
+	MonitorCmd.PersistentFlags().DurationVar(&maxBlockGap, "max-block-gap", 30*time.Second, "Raise an alert when the time between consecutive blocks exceeds this (0 to disable)")
+	MonitorCmd.PersistentFlags().StringVar(&alertWebhook, "alert-webhook", "", "URL to POST reorg and block gap alerts to (Slack and PagerDuty compatible payload)")
+	MonitorCmd.PersistentFlags().DurationVar(&finalityStall, "finality-stall", 5*time.Minute, "Raise an alert when the finalized head hasn't advanced for this long (0 to disable)")
+	MonitorCmd.PersistentFlags().StringSliceVar(&watchFlag, "watch", []string{}, "Addresses to track the balance and nonce of on every poll")
+	MonitorCmd.PersistentFlags().StringVar(&multicallAddr, "multicall-address", multicall.DefaultAddress, "Multicall3 contract the balances of the watched addresses are read through in a single call (empty to request them one by one)")
+	MonitorCmd.PersistentFlags().BoolVar(&noTui, "no-tui", false, "Run without the terminal UI and emit the collected data instead")
+	MonitorCmd.PersistentFlags().StringVar(&headlessOutput, "output", headlessOutputJSON, "Output format when running with --no-tui (json, prometheus)")
+	MonitorCmd.PersistentFlags().StringVar(&prometheusAddr, "prometheus-addr", ":9090", "Address to serve Prometheus metrics on when the output is prometheus")
+	MonitorCmd.PersistentFlags().StringVar(&chartsCSVFile, "charts-csv", "monitor-charts.csv", "File the charted series are exported to")
+	MonitorCmd.PersistentFlags().StringVar(&recordFile, "record", "", "File to record the data collected by every poll to, so the session can be replayed")
+	MonitorCmd.PersistentFlags().StringVar(&replayFile, "replay", "", "Session file recorded with --record to replay in the terminal UI instead of polling an endpoint")
+	MonitorCmd.PersistentFlags().Float64Var(&replaySpeed, "replay-speed", 1, "How many times faster than it was recorded the session is replayed")
 }
 
-func setUISkeleton() (blockTable *widgets.List, grid *ui.Grid, blockGrid *ui.Grid, termUi uiSkeleton) {
+func setUISkeleton(compare bool) (blockTable *widgets.List, grid *ui.Grid, blockGrid *ui.Grid, termUi uiSkeleton) {
 	blockTable = widgets.NewList()
 	blockTable.TextStyle = ui.NewStyle(ui.ColorWhite)
 	termUi = uiSkeleton{}
@@ -389,6 +594,9 @@ func setUISkeleton() (blockTable *widgets.List, grid *ui.Grid, blockGrid *ui.Gri
 	termUi.h4 = widgets.NewParagraph()
 	termUi.h4.Title = "Avg Block Time"
 
+	termUi.h5 = widgets.NewParagraph()
+	termUi.h5.Title = "Finality"
+
 	termUi.sl0 = widgets.NewSparkline()
 	termUi.sl0.LineColor = ui.ColorRed
 	slg0 := widgets.NewSparklineGroup(termUi.sl0)
@@ -419,7 +627,7 @@ func setUISkeleton() (blockTable *widgets.List, grid *ui.Grid, blockGrid *ui.Gri
 
 	b0 := widgets.NewParagraph()
 	b0.Title = "Block Headers"
-	b0.Text = "Use the arrow keys to scroll through the transactions. Press <Esc> to go back to the explorer view"
+	b0.Text = "Use the arrow keys to select a transaction and press <Enter> to view it with its receipt. Press <Esc> to go back to the explorer view"
 
 	termUi.b1 = widgets.NewList()
 	termUi.b1.Title = "Block Info"
@@ -429,7 +637,8 @@ func setUISkeleton() (blockTable *widgets.List, grid *ui.Grid, blockGrid *ui.Gri
 	termUi.b2 = widgets.NewList()
 	termUi.b2.Title = "Transactions"
 	termUi.b2.TextStyle = ui.NewStyle(ui.ColorGreen)
-	termUi.b2.WrapText = true
+	termUi.b2.SelectedRowStyle = ui.NewStyle(ui.ColorGreen, ui.ColorClear, ui.ModifierReverse)
+	termUi.b2.WrapText = false
 
 	blockGrid.Set(
 		ui.NewRow(1.0/10, b0),
@@ -440,24 +649,52 @@ func setUISkeleton() (blockTable *widgets.List, grid *ui.Grid, blockGrid *ui.Gri
 		),
 	)
 
-	grid.Set(
-		ui.NewRow(1.0/10,
-			ui.NewCol(1.0/5, termUi.h0),
-			ui.NewCol(1.0/5, termUi.h1),
-			ui.NewCol(1.0/5, termUi.h2),
-			ui.NewCol(1.0/5, termUi.h3),
-			ui.NewCol(1.0/5, termUi.h4),
-		),
-
-		ui.NewRow(4.0/10,
-			ui.NewCol(1.0/5, slg0),
-			ui.NewCol(1.0/5, slg1),
-			ui.NewCol(1.0/5, slg2),
-			ui.NewCol(1.0/5, slg3),
-			ui.NewCol(1.0/5, slg4),
-		),
-		ui.NewRow(5.0/10, blockTable),
+	headerRow := ui.NewRow(1.0/10,
+		ui.NewCol(1.0/6, termUi.h0),
+		ui.NewCol(1.0/6, termUi.h1),
+		ui.NewCol(1.0/6, termUi.h2),
+		ui.NewCol(1.0/6, termUi.h3),
+		ui.NewCol(1.0/6, termUi.h4),
+		ui.NewCol(1.0/6, termUi.h5),
 	)
+	sparklineCols := []interface{}{
+		ui.NewCol(1.0/5, slg0),
+		ui.NewCol(1.0/5, slg1),
+		ui.NewCol(1.0/5, slg2),
+		ui.NewCol(1.0/5, slg3),
+		ui.NewCol(1.0/5, slg4),
+	}
+
+	termUi.a0 = widgets.NewList()
+	termUi.a0.Title = "Alerts"
+	termUi.a0.TextStyle = ui.NewStyle(ui.ColorRed)
+	termUi.a0.WrapText = false
+
+	if compare {
+		termUi.e0 = widgets.NewTable()
+		termUi.e0.Title = "Endpoints"
+		termUi.e0.TextAlignment = ui.AlignLeft
+		termUi.e0.RowSeparator = false
+
+		// Shrink the sparklines to make room for the endpoints table and alerts
+		// while keeping the block table the same height.
+		grid.Set(
+			headerRow,
+			ui.NewRow(2.0/10, sparklineCols...),
+			ui.NewRow(2.0/10,
+				ui.NewCol(6.0/10, termUi.e0),
+				ui.NewCol(4.0/10, termUi.a0),
+			),
+			ui.NewRow(5.0/10, blockTable),
+		)
+	} else {
+		grid.Set(
+			headerRow,
+			ui.NewRow(3.0/10, sparklineCols...),
+			ui.NewRow(1.0/10, termUi.a0),
+			ui.NewRow(5.0/10, blockTable),
+		)
+	}
 
 	return
 }
@@ -477,7 +714,7 @@ func updateAllBlocks(ms *monitorStatus) []rpctypes.PolyBlock {
 	return allBlocks
 }
 
-func renderMonitorUI(ctx context.Context, ec *ethclient.Client, ms *monitorStatus, rpc *ethrpc.Client) error {
+func renderMonitorUI(ctx context.Context, ec *ethclient.Client, ms *monitorStatus, rpc *ethrpc.Client, compare bool) error {
 	if err := ui.Init(); err != nil {
 		return err
 	}
@@ -485,13 +722,30 @@ func renderMonitorUI(ctx context.Context, ec *ethclient.Client, ms *monitorStatu
 
 	currentMode := monitorModeExplorer
 
-	blockTable, grid, blockGrid, termUi := setUISkeleton()
+	blockTable, grid, blockGrid, termUi := setUISkeleton(compare)
 
 	termWidth, termHeight := ui.TerminalDimensions()
 	windowSize = termHeight/2 - 4
 	grid.SetRect(0, 0, termWidth, termHeight)
 	blockGrid.SetRect(0, 0, termWidth, termHeight)
 
+	txPool := &txPoolState{}
+	txPoolUi := newTxPoolUI()
+	txPoolUi.grid.SetRect(0, 0, termWidth, termHeight)
+
+	chartsUi := newChartsUI()
+	chartsUi.grid.SetRect(0, 0, termWidth, termHeight)
+
+	watchTable := newWatchTable()
+	watchTable.SetRect(0, 0, termWidth, termHeight)
+
+	peersUi := newPeersUI()
+	peersUi.grid.SetRect(0, 0, termWidth, termHeight)
+
+	txUi := newTxUI()
+	txUi.grid.SetRect(0, 0, termWidth, termHeight)
+	receipt := &receiptState{}
+
 	var setBlock = false
 	var allBlocks metrics.SortableBlocks
 	var renderedBlocks metrics.SortableBlocks
@@ -505,11 +759,45 @@ func renderMonitorUI(ctx context.Context, ec *ethclient.Client, ms *monitorStatu
 		} else if currentMode == monitorModeBlock {
 			// render a block
 			termUi.b1.Rows = metrics.GetSimpleBlockFields(selectedBlock)
-			termUi.b2.Rows = metrics.GetSimpleBlockTxFields(selectedBlock, ms.ChainID)
+			termUi.b2.Rows = getBlockTxRows(selectedBlock)
+			termUi.b2.Title = fmt.Sprintf("Transactions (%d)", len(termUi.b2.Rows))
 
 			ui.Clear()
 			ui.Render(blockGrid)
 			return
+		} else if currentMode == monitorModeTransaction {
+			idx := termUi.b2.SelectedRow
+			txUi.render(selectedBlock, selectedBlock.Transactions()[idx], idx, receipt)
+			ui.Clear()
+			ui.Render(txUi.grid)
+			return
+		} else if currentMode == monitorModeTxPool {
+			_, termHeight := ui.TerminalDimensions()
+			txPoolUi.render(txPool, termHeight)
+			ui.Clear()
+			ui.Render(txPoolUi.grid)
+			return
+		} else if currentMode == monitorModeCharts {
+			allBlocks = updateAllBlocks(ms)
+			sort.Sort(allBlocks)
+			chartsUi.render(allBlocks)
+			ui.Clear()
+			ui.Render(chartsUi.grid)
+			return
+		} else if currentMode == monitorModeWatch {
+			ms.WatchedLock.RLock()
+			renderWatchedAccounts(watchTable, ms.Watched)
+			ms.WatchedLock.RUnlock()
+			ui.Clear()
+			ui.Render(watchTable)
+			return
+		} else if currentMode == monitorModePeers {
+			ms.PeersLock.RLock()
+			peersUi.render(ms.Peers)
+			ms.PeersLock.RUnlock()
+			ui.Clear()
+			ui.Render(peersUi.grid)
+			return
 		}
 
 		if blockTable.SelectedRow == 0 || len(force) > 0 && force[0] {
@@ -523,13 +811,22 @@ func renderMonitorUI(ctx context.Context, ec *ethclient.Client, ms *monitorStatu
 		end := len(allBlocks) - windowOffset
 		renderedBlocks = allBlocks[start:end]
 
-		termUi.h0.Text = fmt.Sprintf("Height: %s\nTime: %s", ms.HeadBlock.String(), time.Now().Format("02 Jan 06 15:04:05 MST"))
+		now := time.Now()
+		if !ms.ReplayTime.IsZero() {
+			now = ms.ReplayTime
+			termUi.h0.Title = "Replay"
+		}
+		termUi.h0.Text = fmt.Sprintf("Height: %s\nTime: %s", ms.HeadBlock.String(), now.Format("02 Jan 06 15:04:05 MST"))
 		gasGwei := new(big.Int).Div(ms.GasPrice, metrics.UnitShannon)
 		termUi.h1.Text = fmt.Sprintf("%s gwei", gasGwei.String())
 		termUi.h2.Text = fmt.Sprintf("%d Peers\n%d Pending Tx", ms.PeerCount, ms.PendingCount)
 		termUi.h3.Text = ms.ChainID.String()
 		termUi.h4.Text = fmt.Sprintf("%0.2f", metrics.GetMeanBlockTime(renderedBlocks))
 
+		ms.FinalityLock.RLock()
+		renderFinality(termUi.h5, ms.Finality)
+		ms.FinalityLock.RUnlock()
+
 		termUi.sl0.Data = metrics.GetTxsPerBlock(renderedBlocks)
 		termUi.sl1.Data = metrics.GetMeanGasPricePerBlock(renderedBlocks)
 		termUi.sl2.Data = metrics.GetSizePerBlock(renderedBlocks)
@@ -537,6 +834,14 @@ func renderMonitorUI(ctx context.Context, ec *ethclient.Client, ms *monitorStatu
 		termUi.sl3.Data = observedPendingTxs.getValues(25)
 		termUi.sl4.Data = metrics.GetGasPerBlock(renderedBlocks)
 
+		ms.Alerts.render(termUi.a0)
+
+		if termUi.e0 != nil {
+			ms.EndpointsLock.RLock()
+			renderEndpoints(termUi.e0, ms.Endpoints)
+			ms.EndpointsLock.RUnlock()
+		}
+
 		// If a row has not been selected, continue to update the list with new blocks.
 		rows, title := metrics.GetSimpleBlockRecords(renderedBlocks)
 		blockTable.Rows = rows
@@ -573,21 +878,85 @@ func renderMonitorUI(ctx context.Context, ec *ethclient.Client, ms *monitorStatu
 			case "q", "<C-c>":
 				return nil
 			case "<Escape>":
+				if currentMode == monitorModeTransaction {
+					currentMode = monitorModeBlock
+					break
+				}
 				blockTable.SelectedRow = 0
 				currentMode = monitorModeExplorer
 				windowOffset = 0
 			case "<Enter>":
-				if blockTable.SelectedRow > 0 {
+				if blockTable.SelectedRow > 0 && currentMode == monitorModeExplorer {
 					currentMode = monitorModeBlock
+					termUi.b2.SelectedRow = 0
+				} else if currentMode == monitorModeBlock && len(selectedBlock.Transactions()) > 0 {
+					currentMode = monitorModeTransaction
+					txUi.receipt.SelectedRow = 0
+					receipt.fetch(ctx, rpc, selectedBlock.Transactions()[termUi.b2.SelectedRow].Hash())
+				}
+			case "t":
+				currentMode = monitorModeTxPool
+				txPool.refresh(ctx, rpc)
+			case "w":
+				if len(watchAddresses) > 0 {
+					currentMode = monitorModeWatch
+				}
+			case "p":
+				currentMode = monitorModePeers
+			case "c":
+				currentMode = monitorModeCharts
+				chartsUi.offset = 0
+			case "<Left>", "<Right>":
+				if currentMode != monitorModeCharts {
+					break
+				}
+				if e.ID == "<Left>" {
+					chartsUi.scroll(1)
+				} else {
+					chartsUi.scroll(-1)
+				}
+			case "e":
+				if currentMode != monitorModeCharts {
+					break
+				}
+				if err := exportCharts(chartsCSVFile, allBlocks); err != nil {
+					log.Error().Err(err).Msg("Unable to export charts")
+					chartsUi.message = fmt.Sprintf("Unable to export charts: %s", err)
+				} else {
+					chartsUi.message = fmt.Sprintf("Exported %d blocks to %s", len(allBlocks), chartsCSVFile)
+				}
+			case "s":
+				if currentMode == monitorModeTxPool {
+					txPool.sortBy = txPool.sortBy.next()
+				}
+			case "f":
+				if currentMode == monitorModeTxPool {
+					txPool.filter = txPool.filter.next()
 				}
 			case "<Resize>":
 				payload := e.Payload.(ui.Resize)
 				grid.SetRect(0, 0, payload.Width, payload.Height)
 				blockGrid.SetRect(0, 0, payload.Width, payload.Height)
+				txPoolUi.grid.SetRect(0, 0, payload.Width, payload.Height)
+				chartsUi.grid.SetRect(0, 0, payload.Width, payload.Height)
+				watchTable.SetRect(0, 0, payload.Width, payload.Height)
+				peersUi.grid.SetRect(0, 0, payload.Width, payload.Height)
+				txUi.grid.SetRect(0, 0, payload.Width, payload.Height)
 				_, termHeight = ui.TerminalDimensions()
 				windowSize = termHeight/2 - 4
 				ui.Clear()
 			case "<Up>", "<Down>":
+				if currentMode == monitorModeTxPool || currentMode == monitorModeCharts || currentMode == monitorModeWatch || currentMode == monitorModePeers {
+					break
+				}
+				if currentMode == monitorModeTransaction {
+					if len(txUi.receipt.Rows) != 0 && e.ID == "<Down>" {
+						txUi.receipt.ScrollDown()
+					} else if len(txUi.receipt.Rows) != 0 && e.ID == "<Up>" {
+						txUi.receipt.ScrollUp()
+					}
+					break
+				}
 				if currentMode == monitorModeBlock {
 					if len(termUi.b2.Rows) != 0 && e.ID == "<Down>" {
 						termUi.b2.ScrollDown()
@@ -701,6 +1070,15 @@ func renderMonitorUI(ctx context.Context, ec *ethclient.Client, ms *monitorStatu
 				redraw(ms)
 			}
 		case <-ticker:
+			if currentMode == monitorModeTxPool {
+				txPool.refresh(ctx, rpc)
+				redraw(ms)
+				break
+			}
+			if currentMode == monitorModePeers || currentMode == monitorModeTransaction {
+				redraw(ms)
+				break
+			}
 			if currentBn != ms.HeadBlock {
 				currentBn = ms.HeadBlock
 				redraw(ms)
@@ -0,0 +1,224 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+	"github.com/rs/zerolog/log"
+)
+
+// peerHistory is the number of polls the peer counts are charted for.
+const peerHistory = 240
+
+type (
+	// adminPeer is the part of an admin_peers entry the peers view uses.
+	adminPeer struct {
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		Network struct {
+			Inbound bool `json:"inbound"`
+			Trusted bool `json:"trusted"`
+			Static  bool `json:"static"`
+		} `json:"network"`
+	}
+
+	// peerSample is the peer counts of a single poll.
+	peerSample struct {
+		Total    float64
+		Inbound  float64
+		Outbound float64
+	}
+
+	// peerState is the latest peer counts. HasAdmin is false when the endpoint
+	// doesn't expose admin_peers, in which case only the net_peerCount total
+	// is known.
+	peerState struct {
+		Count    uint64         `json:"count"`
+		Inbound  int            `json:"inbound"`
+		Outbound int            `json:"outbound"`
+		Clients  map[string]int `json:"clients,omitempty"`
+		HasAdmin bool           `json:"hasAdmin"`
+
+		History []peerSample `json:"-"`
+	}
+
+	// peerClient is the number of peers running a client.
+	peerClient struct {
+		Name  string
+		Count int
+	}
+)
+
+// clientName returns the client of a peer from its name, e.g. "Geth" from
+// "Geth/v1.13.5-stable/linux-amd64/go1.21.4".
+func clientName(name string) string {
+	client, _, _ := strings.Cut(name, "/")
+	if len(client) == 0 {
+		return "unknown"
+	}
+	return client
+}
+
+// peersRequest is the admin_peers call, which is sent in the batch of every
+// refresh.
+type peersRequest struct {
+	peers []adminPeer
+	batch []ethrpc.BatchElem
+}
+
+func newPeersRequest() *peersRequest {
+	r := new(peersRequest)
+	r.batch = []ethrpc.BatchElem{{Method: "admin_peers", Result: &r.peers}}
+	return r
+}
+
+// updatePeers applies the connected peers once the batch was sent. The admin
+// namespace is usually not exposed on public endpoints, so the error is
+// expected and only the net_peerCount total is kept then.
+func (ms *monitorStatus) updatePeers(r *peersRequest) {
+	peers, err := r.peers, r.batch[0].Error
+
+	ms.PeersLock.Lock()
+	defer ms.PeersLock.Unlock()
+
+	p := ms.Peers
+	p.Count = ms.PeerCount
+	p.HasAdmin = err == nil
+	p.Inbound, p.Outbound = 0, 0
+	p.Clients = nil
+
+	if err != nil {
+		log.Debug().Err(err).Msg("Unable to fetch admin_peers")
+	} else {
+		p.Count = uint64(len(peers))
+		p.Clients = make(map[string]int)
+		for _, peer := range peers {
+			if peer.Network.Inbound {
+				p.Inbound++
+			} else {
+				p.Outbound++
+			}
+			p.Clients[clientName(peer.Name)]++
+		}
+	}
+
+	p.History = append(p.History, peerSample{
+		Total:    float64(p.Count),
+		Inbound:  float64(p.Inbound),
+		Outbound: float64(p.Outbound),
+	})
+	if len(p.History) > peerHistory {
+		p.History = p.History[len(p.History)-peerHistory:]
+	}
+
+	ms.Peers = p
+}
+
+// clients returns the clients sorted by their number of peers.
+func (p *peerState) clients() []peerClient {
+	clients := make([]peerClient, 0, len(p.Clients))
+	for name, count := range p.Clients {
+		clients = append(clients, peerClient{Name: name, Count: count})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].Count != clients[j].Count {
+			return clients[i].Count > clients[j].Count
+		}
+		return clients[i].Name < clients[j].Name
+	})
+	return clients
+}
+
+type peersUI struct {
+	grid    *ui.Grid
+	summary *widgets.Paragraph
+	history *widgets.Plot
+	clients *widgets.Table
+}
+
+func newPeersUI() *peersUI {
+	p := &peersUI{
+		grid:    ui.NewGrid(),
+		summary: widgets.NewParagraph(),
+		history: widgets.NewPlot(),
+		clients: widgets.NewTable(),
+	}
+
+	p.summary.Title = "Peers"
+
+	p.history.Title = "Peers over time (total, inbound, outbound)"
+	p.history.LineColors = []ui.Color{ui.ColorWhite, ui.ColorGreen, ui.ColorYellow}
+
+	p.clients.Title = "Clients"
+	p.clients.TextAlignment = ui.AlignLeft
+	p.clients.RowSeparator = false
+
+	p.grid.Set(
+		ui.NewRow(2.0/10, p.summary),
+		ui.NewRow(8.0/10,
+			ui.NewCol(6.0/10, p.history),
+			ui.NewCol(4.0/10, p.clients),
+		),
+	)
+
+	return p
+}
+
+func (p *peersUI) render(state peerState) {
+	keys := "Press <Esc> to go back to the explorer view"
+	if !state.HasAdmin {
+		p.summary.Text = fmt.Sprintf("Peers: %d\nThe endpoint doesn't expose admin_peers, so only net_peerCount is charted\n%s", state.Count, keys)
+	} else {
+		share := func(n int) float64 {
+			if state.Count == 0 {
+				return 0
+			}
+			return 100 * float64(n) / float64(state.Count)
+		}
+		p.summary.Text = fmt.Sprintf("Peers: %d    Inbound: %d (%.0f%%)    Outbound: %d (%.0f%%)    Clients: %d\n%s",
+			state.Count, state.Inbound, share(state.Inbound), state.Outbound, share(state.Outbound), len(state.Clients), keys)
+	}
+
+	// Each data point of a braille line chart takes one cell and the y-axis
+	// labels take up the first 5 cells, so only the latest samples that fit
+	// are charted.
+	history := state.History
+	if span := max(p.history.Inner.Dx()-5, 2); len(history) > span {
+		history = history[len(history)-span:]
+	}
+
+	// The line chart needs at least two points and a non zero max value.
+	p.history.Data = [][]float64{}
+	if len(history) >= 2 {
+		total := make([]float64, 0, len(history))
+		inbound := make([]float64, 0, len(history))
+		outbound := make([]float64, 0, len(history))
+		for _, sample := range history {
+			total = append(total, sample.Total)
+			inbound = append(inbound, sample.Inbound)
+			outbound = append(outbound, sample.Outbound)
+		}
+		p.history.Data = [][]float64{total}
+		if state.HasAdmin {
+			p.history.Data = append(p.history.Data, inbound, outbound)
+		}
+
+		p.history.MaxVal = 0
+		if maxVal, _ := ui.GetMaxFloat64From2dSlice(p.history.Data); maxVal == 0 {
+			p.history.MaxVal = 1
+		}
+	}
+
+	p.clients.Rows = [][]string{{"Client", "Peers", "Share"}}
+	for _, client := range state.clients() {
+		p.clients.Rows = append(p.clients.Rows, []string{
+			client.Name,
+			fmt.Sprint(client.Count),
+			fmt.Sprintf("%.1f%%", 100*float64(client.Count)/float64(state.Count)),
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+// errNotRecorded is returned for the data the monitor only fetches on demand,
+// which isn't part of a recorded session.
+var errNotRecorded = errors.New("not available when replaying a session")
+
+type (
+	// sessionFrame is a single JSON line of a recorded session, written after
+	// every poll. Only the blocks and alerts that are new since the previous
+	// frame are included.
+	sessionFrame struct {
+		Time         time.Time         `json:"time"`
+		ChainID      *big.Int          `json:"chainId"`
+		HeadBlock    *big.Int          `json:"headBlock"`
+		PeerCount    uint64            `json:"peerCount"`
+		GasPrice     *big.Int          `json:"gasPrice"`
+		PendingCount uint              `json:"pendingCount"`
+		Blocks       []json.RawMessage `json:"blocks,omitempty"`
+		Endpoints    []endpointStatus  `json:"endpoints,omitempty"`
+		Accounts     []watchedAccount  `json:"accounts,omitempty"`
+		Finality     finalityState     `json:"finality"`
+		Peers        peerState         `json:"peers"`
+		Alerts       []alert           `json:"alerts,omitempty"`
+	}
+
+	// sessionRecorder writes the data collected by every poll to a session
+	// file, so it can be replayed later.
+	sessionRecorder struct {
+		file    *os.File
+		writer  *bufio.Writer
+		encoder *json.Encoder
+
+		// blocks is the hash of every recorded block by number, so the blocks
+		// are only written again when they were reorged.
+		blocks    map[string]common.Hash
+		lastAlert time.Time
+	}
+)
+
+func newSessionRecorder(path string) (*sessionRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := bufio.NewWriter(file)
+	return &sessionRecorder{
+		file:    file,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+		blocks:  make(map[string]common.Hash),
+	}, nil
+}
+
+// record writes a frame with the current state of the monitor. A nil
+// recorder does nothing, which is the case when recording is disabled.
+func (r *sessionRecorder) record(ms *monitorStatus) error {
+	if r == nil {
+		return nil
+	}
+
+	frame := sessionFrame{
+		Time:         time.Now(),
+		ChainID:      ms.ChainID,
+		HeadBlock:    ms.HeadBlock,
+		PeerCount:    ms.PeerCount,
+		GasPrice:     ms.GasPrice,
+		PendingCount: ms.PendingCount,
+	}
+
+	ms.BlocksLock.RLock()
+	for number, block := range ms.Blocks {
+		if hash, ok := r.blocks[number]; ok && hash == block.Hash() {
+			continue
+		}
+		raw, err := block.MarshalJSON()
+		if err != nil {
+			ms.BlocksLock.RUnlock()
+			return err
+		}
+		frame.Blocks = append(frame.Blocks, raw)
+		r.blocks[number] = block.Hash()
+	}
+	ms.BlocksLock.RUnlock()
+
+	ms.EndpointsLock.RLock()
+	frame.Endpoints = ms.Endpoints
+	ms.EndpointsLock.RUnlock()
+
+	ms.WatchedLock.RLock()
+	frame.Accounts = ms.Watched
+	ms.WatchedLock.RUnlock()
+
+	ms.FinalityLock.RLock()
+	frame.Finality = ms.Finality
+	ms.FinalityLock.RUnlock()
+
+	ms.PeersLock.RLock()
+	frame.Peers = ms.Peers
+	ms.PeersLock.RUnlock()
+
+	frame.Alerts = ms.Alerts.since(r.lastAlert)
+	if len(frame.Alerts) > 0 {
+		r.lastAlert = frame.Alerts[len(frame.Alerts)-1].Time
+	}
+
+	if err := r.encoder.Encode(frame); err != nil {
+		return err
+	}
+	return r.writer.Flush()
+}
+
+func (r *sessionRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// apply sets the state of the monitor to the one of the recorded frame.
+func (ms *monitorStatus) apply(frame *sessionFrame) error {
+	ms.ReplayTime = frame.Time
+	ms.HeadBlock = frame.HeadBlock
+	ms.ChainID = frame.ChainID
+	ms.PeerCount = frame.PeerCount
+	ms.GasPrice = frame.GasPrice
+	ms.PendingCount = frame.PendingCount
+	observedPendingTxs = append(observedPendingTxs, historicalDataPoint{SampleTime: frame.Time, SampleValue: float64(frame.PendingCount)})
+
+	for _, raw := range frame.Blocks {
+		r := new(rpctypes.RawBlockResponse)
+		if err := json.Unmarshal(raw, r); err != nil {
+			return err
+		}
+		pb := rpctypes.NewPolyBlock(r)
+
+		ms.BlocksLock.Lock()
+		ms.Blocks[pb.Number().String()] = pb
+		ms.BlocksLock.Unlock()
+
+		if ms.MaxBlockRetrieved.Cmp(pb.Number()) == -1 {
+			ms.MaxBlockRetrieved = pb.Number()
+		}
+		if ms.MinBlockRetrieved == nil || (ms.MinBlockRetrieved.Cmp(pb.Number()) == 1 && pb.Number().Cmp(zero) == 1) {
+			ms.MinBlockRetrieved = pb.Number()
+		}
+	}
+
+	ms.EndpointsLock.Lock()
+	ms.Endpoints = frame.Endpoints
+	ms.EndpointsLock.Unlock()
+
+	ms.WatchedLock.Lock()
+	ms.Watched = frame.Accounts
+	ms.WatchedLock.Unlock()
+
+	ms.FinalityLock.Lock()
+	ms.Finality = frame.Finality
+	ms.FinalityLock.Unlock()
+
+	// The history of the peer counts isn't recorded since every frame has
+	// the counts of its poll.
+	ms.PeersLock.Lock()
+	history := append(ms.Peers.History, peerSample{
+		Total:    float64(frame.Peers.Count),
+		Inbound:  float64(frame.Peers.Inbound),
+		Outbound: float64(frame.Peers.Outbound),
+	})
+	if len(history) > peerHistory {
+		history = history[len(history)-peerHistory:]
+	}
+	ms.Peers = frame.Peers
+	ms.Peers.History = history
+	ms.PeersLock.Unlock()
+
+	for _, al := range frame.Alerts {
+		ms.Alerts.insert(al)
+	}
+
+	return nil
+}
+
+// replaySession renders the recorded session in the terminal UI. The frames
+// are applied with the time that passed between them when they were
+// recorded, divided by the speed.
+func replaySession(ctx context.Context, ms *monitorStatus, path string, speed float64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+
+	var first sessionFrame
+	if err = decoder.Decode(&first); err != nil {
+		return fmt.Errorf("unable to read the first frame of %s: %w", path, err)
+	}
+	if err = ms.apply(&first); err != nil {
+		return err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- renderMonitorUI(ctx, nil, ms, nil, len(first.Endpoints) > 1)
+	}()
+
+	go func() {
+		previous := first.Time
+		for {
+			var frame sessionFrame
+			if err := decoder.Decode(&frame); err != nil {
+				if err != io.EOF {
+					log.Error().Err(err).Msg("Unable to read the session")
+				}
+				log.Info().Time("time", previous).Msg("Reached the end of the session")
+				return
+			}
+
+			select {
+			case <-time.After(time.Duration(float64(frame.Time.Sub(previous)) / speed)):
+			case <-ctx.Done():
+				return
+			}
+			previous = frame.Time
+
+			if err := ms.apply(&frame); err != nil {
+				log.Error().Err(err).Msg("Unable to apply the session frame")
+			}
+		}
+	}()
+
+	return <-errChan
+}
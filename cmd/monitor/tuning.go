@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// rpcLimitExceededCode is the JSON-RPC error code of EIP-1474 for requests
+// exceeding the limits of the endpoint.
+const rpcLimitExceededCode = -32005
+
+// pollTuner adapts the time between polls to the endpoint. The interval is
+// raised when a refresh fails or takes more than half of the interval, and
+// doubled when the endpoint is rate limiting, up to the max. It's lowered
+// back towards the configured interval while the refreshes are fast.
+type pollTuner struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+	lock    sync.Mutex
+}
+
+func newPollTuner(min, max time.Duration) *pollTuner {
+	if max < min {
+		max = min
+	}
+	return &pollTuner{min: min, max: max, current: min}
+}
+
+// interval returns the time to wait before the next poll. A nil tuner always
+// returns the configured interval.
+func (t *pollTuner) interval() time.Duration {
+	if t == nil {
+		return interval
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.current
+}
+
+// observe adjusts the interval to how long the last refresh took and whether
+// it failed.
+func (t *pollTuner) observe(took time.Duration, err error) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	previous := t.current
+	switch {
+	case isRateLimited(err):
+		t.current *= 2
+	case err != nil || took > t.current/2:
+		t.current += t.current / 2
+	default:
+		t.current -= (t.current - t.min) / 4
+	}
+	if t.current > t.max {
+		t.current = t.max
+	}
+	if t.current < t.min {
+		t.current = t.min
+	}
+
+	if t.current > previous {
+		log.Warn().Err(err).Dur("took", took).Dur("interval", t.current).Msg("Endpoint is slow or rate limited, backing off")
+	} else if t.current < previous {
+		log.Debug().Dur("took", took).Dur("interval", t.current).Msg("Lowering the polling interval")
+	}
+}
+
+// isRateLimited returns whether the error is the endpoint rejecting requests
+// because of their rate or size.
+func isRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var httpErr ethrpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	var rpcErr ethrpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == rpcLimitExceededCode {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests")
+}
+
+// sendBatch sends the calls of every request in a single batch, so a refresh
+// makes one round trip instead of one per call. The errors of the individual
+// calls are set on the requests' elements.
+func sendBatch(ctx context.Context, rpc *ethrpc.Client, requests ...[]ethrpc.BatchElem) error {
+	var batch []ethrpc.BatchElem
+	for _, r := range requests {
+		batch = append(batch, r...)
+	}
+	if err := rpc.BatchCallContext(ctx, batch); err != nil {
+		return err
+	}
+
+	i := 0
+	for _, r := range requests {
+		copy(r, batch[i:i+len(r)])
+		i += len(r)
+	}
+	return nil
+}
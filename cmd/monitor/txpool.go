@@ -0,0 +1,329 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/metrics"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+type (
+	// txPoolContent is the response of txpool_content which maps the sender to
+	// the nonce to the transaction.
+	txPoolContent struct {
+		Pending map[string]map[string]rpctypes.RawTransactionResponse `json:"pending"`
+		Queued  map[string]map[string]rpctypes.RawTransactionResponse `json:"queued"`
+	}
+
+	// txPoolStatusResponse is the response of txpool_status.
+	txPoolStatusResponse struct {
+		Pending rpctypes.RawQuantityResponse `json:"pending"`
+		Queued  rpctypes.RawQuantityResponse `json:"queued"`
+	}
+
+	txPoolSender struct {
+		Address string
+		Pending int
+		Queued  int
+		MaxFee  *big.Int
+	}
+
+	txPoolStatus struct {
+		Pending   uint64
+		Queued    uint64
+		Senders   []txPoolSender
+		Fees      []*big.Int
+		UpdatedAt time.Time
+		Err       error
+	}
+
+	txPoolState struct {
+		Status   *txPoolStatus
+		Lock     sync.RWMutex
+		fetching sync.Mutex
+		sortBy   txPoolSort
+		filter   txPoolFilter
+	}
+
+	txPoolSort   int
+	txPoolFilter int
+)
+
+const (
+	txPoolSortCount txPoolSort = iota
+	txPoolSortFee
+	txPoolSortAddress
+)
+
+const (
+	txPoolFilterAll txPoolFilter = iota
+	txPoolFilterPending
+	txPoolFilterQueued
+)
+
+func (s txPoolSort) String() string {
+	return [...]string{"count", "fee", "address"}[s]
+}
+
+func (f txPoolFilter) String() string {
+	return [...]string{"all", "pending", "queued"}[f]
+}
+
+// next cycles through the sort orders.
+func (s txPoolSort) next() txPoolSort {
+	return (s + 1) % 3
+}
+
+// next cycles through the filters.
+func (f txPoolFilter) next() txPoolFilter {
+	return (f + 1) % 3
+}
+
+// fetchTxPool polls txpool_status and txpool_content. Not every client
+// supports txpool_content (or it may be disabled because of its size), so the
+// status counts are still shown if only the content request fails.
+func fetchTxPool(ctx context.Context, rpc *ethrpc.Client) *txPoolStatus {
+	var (
+		status  txPoolStatusResponse
+		content txPoolContent
+	)
+	batch := []ethrpc.BatchElem{
+		{Method: "txpool_status", Result: &status},
+		{Method: "txpool_content", Result: &content},
+	}
+
+	result := &txPoolStatus{UpdatedAt: time.Now()}
+	if err := rpc.BatchCallContext(ctx, batch); err != nil {
+		result.Err = err
+		return result
+	}
+	if batch[0].Error != nil {
+		result.Err = batch[0].Error
+		return result
+	}
+
+	result.Pending = status.Pending.ToUint64()
+	result.Queued = status.Queued.ToUint64()
+	if batch[1].Error != nil {
+		result.Err = batch[1].Error
+		return result
+	}
+
+	senders := make(map[string]*txPoolSender)
+	add := func(txs map[string]map[string]rpctypes.RawTransactionResponse, pending bool) {
+		for address, nonces := range txs {
+			sender, ok := senders[address]
+			if !ok {
+				sender = &txPoolSender{Address: address, MaxFee: big.NewInt(0)}
+				senders[address] = sender
+			}
+
+			for _, tx := range nonces {
+				if pending {
+					sender.Pending++
+				} else {
+					sender.Queued++
+				}
+
+				fee := txFeeCap(tx)
+				result.Fees = append(result.Fees, fee)
+				if fee.Cmp(sender.MaxFee) == 1 {
+					sender.MaxFee = fee
+				}
+			}
+		}
+	}
+	add(content.Pending, true)
+	add(content.Queued, false)
+
+	for _, sender := range senders {
+		result.Senders = append(result.Senders, *sender)
+	}
+
+	return result
+}
+
+// refresh fetches the transaction pool in the background if it hasn't been
+// fetched within the interval. Only one fetch is in flight at a time since
+// txpool_content can be large on busy chains.
+func (state *txPoolState) refresh(ctx context.Context, rpc *ethrpc.Client) {
+	if rpc == nil {
+		state.Lock.Lock()
+		state.Status = &txPoolStatus{UpdatedAt: time.Now(), Err: errNotRecorded}
+		state.Lock.Unlock()
+		return
+	}
+
+	state.Lock.RLock()
+	stale := state.Status == nil || time.Since(state.Status.UpdatedAt) >= tuner.interval()
+	state.Lock.RUnlock()
+	if !stale || !state.fetching.TryLock() {
+		return
+	}
+
+	go func() {
+		defer state.fetching.Unlock()
+		status := fetchTxPool(ctx, rpc)
+		if status.Err != nil {
+			log.Debug().Err(status.Err).Msg("Unable to fetch transaction pool")
+		}
+
+		state.Lock.Lock()
+		state.Status = status
+		state.Lock.Unlock()
+	}()
+}
+
+// txFeeCap returns the max fee per gas for dynamic fee transactions and the
+// gas price for legacy transactions.
+func txFeeCap(tx rpctypes.RawTransactionResponse) *big.Int {
+	if len(tx.MaxFeePerGas) > 0 {
+		return tx.MaxFeePerGas.ToBigInt()
+	}
+	return tx.GasPrice.ToBigInt()
+}
+
+// filteredSenders applies the filter and sort order to the senders.
+func (s *txPoolStatus) filteredSenders(sortBy txPoolSort, filter txPoolFilter) []txPoolSender {
+	senders := make([]txPoolSender, 0, len(s.Senders))
+	for _, sender := range s.Senders {
+		if filter == txPoolFilterPending && sender.Pending == 0 {
+			continue
+		}
+		if filter == txPoolFilterQueued && sender.Queued == 0 {
+			continue
+		}
+		senders = append(senders, sender)
+	}
+
+	count := func(sender txPoolSender) int {
+		switch filter {
+		case txPoolFilterPending:
+			return sender.Pending
+		case txPoolFilterQueued:
+			return sender.Queued
+		default:
+			return sender.Pending + sender.Queued
+		}
+	}
+
+	sort.SliceStable(senders, func(i, j int) bool {
+		switch sortBy {
+		case txPoolSortFee:
+			return senders[i].MaxFee.Cmp(senders[j].MaxFee) == 1
+		case txPoolSortAddress:
+			return strings.ToLower(senders[i].Address) < strings.ToLower(senders[j].Address)
+		default:
+			return count(senders[i]) > count(senders[j])
+		}
+	})
+
+	return senders
+}
+
+// feePercentiles returns the fee cap percentiles in gwei.
+func (s *txPoolStatus) feePercentiles(percentiles []float64) []float64 {
+	values := make([]float64, 0, len(percentiles))
+	if len(s.Fees) == 0 {
+		return values
+	}
+
+	fees := make([]*big.Int, len(s.Fees))
+	copy(fees, s.Fees)
+	sort.Slice(fees, func(i, j int) bool { return fees[i].Cmp(fees[j]) == -1 })
+
+	for _, p := range percentiles {
+		idx := int(p / 100 * float64(len(fees)-1))
+		gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(fees[idx]), new(big.Float).SetInt(metrics.UnitShannon)).Float64()
+		values = append(values, gwei)
+	}
+
+	return values
+}
+
+type txPoolUI struct {
+	grid    *ui.Grid
+	summary *widgets.Paragraph
+	senders *widgets.Table
+	fees    *widgets.BarChart
+}
+
+var txPoolFeePercentiles = []float64{10, 25, 50, 75, 90, 100}
+
+func newTxPoolUI() *txPoolUI {
+	t := &txPoolUI{
+		grid:    ui.NewGrid(),
+		summary: widgets.NewParagraph(),
+		senders: widgets.NewTable(),
+		fees:    widgets.NewBarChart(),
+	}
+
+	t.summary.Title = "Transaction Pool"
+
+	t.senders.Title = "Top Senders"
+	t.senders.TextAlignment = ui.AlignLeft
+	t.senders.RowSeparator = false
+
+	t.fees.Title = "Fee Cap Distribution (gwei)"
+	t.fees.Labels = []string{"p10", "p25", "p50", "p75", "p90", "max"}
+	t.fees.BarWidth = 6
+	t.fees.BarColors = []ui.Color{ui.ColorGreen}
+	t.fees.NumFormatter = func(f float64) string { return fmt.Sprintf("%.1f", f) }
+
+	t.grid.Set(
+		ui.NewRow(2.0/10, t.summary),
+		ui.NewRow(8.0/10,
+			ui.NewCol(6.0/10, t.senders),
+			ui.NewCol(4.0/10, t.fees),
+		),
+	)
+
+	return t
+}
+
+func (t *txPoolUI) render(state *txPoolState, rows int) {
+	state.Lock.RLock()
+	status := state.Status
+	state.Lock.RUnlock()
+
+	keys := "Press <s> to change the sort order, <f> to change the filter, and <Esc> to go back to the explorer view"
+	if status == nil {
+		t.summary.Text = "Loading transaction pool...\n" + keys
+		t.senders.Rows = [][]string{{"Sender", "Pending", "Queued", "Max Fee Cap (gwei)"}}
+		t.fees.Data = nil
+		return
+	}
+
+	t.summary.Text = fmt.Sprintf("Pending: %d    Queued: %d    Senders: %d    Updated: %s\nSort: %s    Filter: %s\n%s",
+		status.Pending, status.Queued, len(status.Senders), status.UpdatedAt.Format("15:04:05"),
+		state.sortBy, state.filter, keys)
+	if status.Err != nil {
+		t.summary.Text += fmt.Sprintf("\nError: %s", status.Err)
+	}
+
+	t.senders.Rows = [][]string{{"Sender", "Pending", "Queued", "Max Fee Cap (gwei)"}}
+	for i, sender := range status.filteredSenders(state.sortBy, state.filter) {
+		if i >= rows {
+			break
+		}
+		t.senders.Rows = append(t.senders.Rows, []string{
+			sender.Address,
+			fmt.Sprint(sender.Pending),
+			fmt.Sprint(sender.Queued),
+			new(big.Int).Div(sender.MaxFee, metrics.UnitShannon).String(),
+		})
+	}
+
+	t.fees.Data = status.feePercentiles(txPoolFeePercentiles)
+}
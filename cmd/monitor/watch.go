@@ -0,0 +1,229 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/metrics"
+	"github.com/maticnetwork/polygon-cli/multicall"
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+// watchedAccount is the latest balance and nonce of a watched address. The
+// deltas are relative to the previous poll so changes can be flagged.
+type watchedAccount struct {
+	Address      common.Address
+	Block        *big.Int
+	Balance      *big.Int
+	Nonce        uint64
+	BalanceDelta *big.Int
+	NonceDelta   int64
+	Changed      bool
+	ChangedAt    *big.Int
+	Err          error `json:"-"`
+}
+
+// parseWatchAddresses validates the addresses passed to --watch.
+func parseWatchAddresses(addresses []string) ([]common.Address, error) {
+	parsed := make([]common.Address, 0, len(addresses))
+	for _, address := range addresses {
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid address to watch: %s", address)
+		}
+		parsed = append(parsed, common.HexToAddress(address))
+	}
+	return parsed, nil
+}
+
+// updateWatchedAccounts fetches the balance and nonce of every watched
+// address at the head block in a single batch and compares them against the
+// previous poll. Where Multicall3 is deployed the balances are read in a
+// single call of the batch, rather than one request per address.
+func (ms *monitorStatus) updateWatchedAccounts(ctx context.Context, rpc *ethrpc.Client, addresses []common.Address) {
+	if len(addresses) == 0 {
+		return
+	}
+
+	block := "0x" + ms.HeadBlock.Text(16)
+	nonces := make([]rpctypes.RawQuantityResponse, len(addresses))
+	nonceBatch := make([]ethrpc.BatchElem, 0, len(addresses))
+	for i, address := range addresses {
+		nonceBatch = append(nonceBatch, ethrpc.BatchElem{Method: "eth_getTransactionCount", Args: []interface{}{address, block}, Result: &nonces[i]})
+	}
+	balances, err := newBalancesRequest(block, addresses)
+	if err == nil {
+		err = sendBatch(ctx, rpc, nonceBatch, balances.batch)
+	}
+
+	ms.WatchedLock.Lock()
+	defer ms.WatchedLock.Unlock()
+
+	previous := make(map[common.Address]watchedAccount, len(ms.Watched))
+	for _, account := range ms.Watched {
+		previous[account.Address] = account
+	}
+
+	watched := make([]watchedAccount, len(addresses))
+	for i, address := range addresses {
+		account := watchedAccount{Address: address, Block: ms.HeadBlock}
+		prev, hasPrev := previous[address]
+		if hasPrev {
+			account.ChangedAt = prev.ChangedAt
+		}
+
+		var balance *big.Int
+		switch {
+		case err != nil:
+			account.Err = err
+		case nonceBatch[i].Error != nil:
+			account.Err = nonceBatch[i].Error
+		default:
+			balance, account.Err = balances.balance(i)
+		}
+		if account.Err != nil {
+			if hasPrev {
+				account.Balance, account.Nonce = prev.Balance, prev.Nonce
+			}
+			watched[i] = account
+			continue
+		}
+
+		account.Balance = balance
+		account.Nonce = nonces[i].ToUint64()
+		account.BalanceDelta = big.NewInt(0)
+		if hasPrev && prev.Balance != nil {
+			account.BalanceDelta.Sub(account.Balance, prev.Balance)
+			account.NonceDelta = int64(account.Nonce) - int64(prev.Nonce)
+			account.Changed = account.BalanceDelta.Sign() != 0 || account.NonceDelta != 0
+		}
+		if account.Changed {
+			account.ChangedAt = ms.HeadBlock
+			log.Info().
+				Str("address", address.Hex()).
+				Str("block", ms.HeadBlock.String()).
+				Str("balance", account.Balance.String()).
+				Str("balanceDelta", account.BalanceDelta.String()).
+				Uint64("nonce", account.Nonce).
+				Int64("nonceDelta", account.NonceDelta).
+				Msg("Watched account changed")
+		}
+		watched[i] = account
+	}
+	ms.Watched = watched
+}
+
+// balancesRequest is the calls for the balances of the watched addresses,
+// which are sent in the batch of the nonces.
+type balancesRequest struct {
+	balances  []rpctypes.RawQuantityResponse
+	multicall *multicall.Request
+	results   []multicall.Result
+	err       error
+
+	batch []ethrpc.BatchElem
+}
+
+func newBalancesRequest(block string, addresses []common.Address) (*balancesRequest, error) {
+	r := new(balancesRequest)
+	if watchReader == nil || !watchReader.Deployed() {
+		r.balances = make([]rpctypes.RawQuantityResponse, len(addresses))
+		for i, address := range addresses {
+			r.batch = append(r.batch, ethrpc.BatchElem{Method: "eth_getBalance", Args: []interface{}{address, block}, Result: &r.balances[i]})
+		}
+		return r, nil
+	}
+
+	calls := make([]multicall.Call, 0, len(addresses))
+	for _, address := range addresses {
+		call, err := watchReader.EthBalance(address)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+	var err error
+	if r.multicall, err = watchReader.NewRequest(block, calls); err != nil {
+		return nil, err
+	}
+	r.batch = r.multicall.Batch
+	return r, nil
+}
+
+// balance returns the balance of the i-th address once the batch was sent.
+func (r *balancesRequest) balance(i int) (*big.Int, error) {
+	if r.multicall == nil {
+		if err := r.batch[i].Error; err != nil {
+			return nil, err
+		}
+		return r.balances[i].ToBigInt(), nil
+	}
+	if r.results == nil && r.err == nil {
+		r.results, r.err = r.multicall.Results()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return multicall.DecodeBig(r.results[i])
+}
+
+func newWatchTable() *widgets.Table {
+	table := widgets.NewTable()
+	table.Title = "Watched Accounts"
+	table.TextAlignment = ui.AlignLeft
+	table.RowSeparator = false
+	return table
+}
+
+// renderWatchedAccounts updates the watch table. Accounts that changed in
+// the latest poll are highlighted in green and accounts that couldn't be
+// fetched in yellow.
+func renderWatchedAccounts(table *widgets.Table, accounts []watchedAccount) {
+	table.Title = "Watched Accounts - press <Esc> to go back to the explorer view"
+	table.Rows = [][]string{{"Address", "Balance (ether)", "Balance Change (gwei)", "Nonce", "Nonce Change", "Last Changed"}}
+	table.RowStyles = map[int]ui.Style{0: ui.NewStyle(ui.ColorWhite, ui.ColorClear, ui.ModifierBold)}
+
+	for i, account := range accounts {
+		if account.Err != nil && account.Balance == nil {
+			table.Rows = append(table.Rows, []string{account.Address.Hex(), "-", "-", "-", "-", account.Err.Error()})
+			table.RowStyles[i+1] = ui.NewStyle(ui.ColorYellow)
+			continue
+		}
+
+		balanceDelta, nonceDelta := "-", "-"
+		if account.BalanceDelta != nil {
+			balanceDelta = weiToUnit(account.BalanceDelta, metrics.UnitShannon)
+			nonceDelta = fmt.Sprintf("%+d", account.NonceDelta)
+		}
+		changedAt := "-"
+		if account.ChangedAt != nil {
+			changedAt = account.ChangedAt.String()
+		}
+
+		table.Rows = append(table.Rows, []string{
+			account.Address.Hex(),
+			weiToUnit(account.Balance, metrics.UnitEther),
+			balanceDelta,
+			fmt.Sprint(account.Nonce),
+			nonceDelta,
+			changedAt,
+		})
+
+		if account.Err != nil {
+			table.RowStyles[i+1] = ui.NewStyle(ui.ColorYellow)
+		} else if account.Changed {
+			table.RowStyles[i+1] = ui.NewStyle(ui.ColorGreen, ui.ColorClear, ui.ModifierBold)
+		}
+	}
+}
+
+// weiToUnit formats the wei amount in the given unit.
+func weiToUnit(wei, unit *big.Int) string {
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetInt(unit)).Text('f', 6)
+}
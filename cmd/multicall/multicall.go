@@ -0,0 +1,353 @@
+package multicall
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/maticnetwork/polygon-cli/multicall"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	multicallParams struct {
+		RPCURL           string
+		Block            string
+		MulticallAddress string
+		BatchSize        int
+		CallsFile        string
+	}
+
+	// parsedCall is a call given on the command line. The signature is empty
+	// if the calldata was given as hex, in which case the return data isn't
+	// decoded.
+	parsedCall struct {
+		Raw       string
+		Signature string
+		Outputs   abi.Arguments
+		Call      multicall.Call
+	}
+
+	callOutput struct {
+		Target     common.Address `json:"target"`
+		Signature  string         `json:"signature,omitempty"`
+		Success    bool           `json:"success"`
+		ReturnData hexutil.Bytes  `json:"returnData,omitempty"`
+		Values     []interface{}  `json:"values,omitempty"`
+		Error      string         `json:"error,omitempty"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage          string
+	inputMulticall multicallParams
+)
+
+var MulticallCmd = &cobra.Command{
+	Use:   "multicall [address:signature[:arg...]|address:calldata ...]",
+	Short: "Run many read only calls through Multicall3 in as few requests as possible.",
+	Long:  usage,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		raw, err := readCalls(args)
+		if err != nil {
+			return err
+		}
+		if len(raw) == 0 {
+			return fmt.Errorf("no calls were given")
+		}
+		calls := make([]parsedCall, 0, len(raw))
+		for _, r := range raw {
+			c, err := parseCall(r)
+			if err != nil {
+				return fmt.Errorf("unable to parse the call %q: %w", r, err)
+			}
+			calls = append(calls, c)
+		}
+
+		rpc, err := util.DialRPC(ctx, inputMulticall.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to dial rpc")
+			return err
+		}
+		defer rpc.Close()
+
+		reader, err := multicall.NewReader(ctx, rpc, inputMulticall.MulticallAddress, inputMulticall.BatchSize)
+		if err != nil {
+			return err
+		}
+		block := inputMulticall.Block
+		if number, err := strconv.ParseUint(block, 0, 64); err == nil {
+			block = hexutil.EncodeUint64(number)
+		}
+
+		toRun := make([]multicall.Call, 0, len(calls))
+		for _, c := range calls {
+			toRun = append(toRun, c.Call)
+		}
+		results, err := reader.Call(ctx, block, toRun)
+		if err != nil {
+			return err
+		}
+
+		outputs := make([]callOutput, 0, len(results))
+		for i, r := range results {
+			outputs = append(outputs, decodeResult(calls[i], r))
+		}
+		if util.JSONOutput() {
+			out, err := json.MarshalIndent(outputs, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+		for i, o := range outputs {
+			printOutput(calls[i], o)
+		}
+		return nil
+	},
+}
+
+// readCalls combines the calls from the arguments and the file. The file has
+// one call per line, and blank lines and lines starting with # are skipped.
+func readCalls(args []string) ([]string, error) {
+	calls := append([]string{}, args...)
+	if inputMulticall.CallsFile == "" {
+		return calls, nil
+	}
+
+	f, err := os.Open(inputMulticall.CallsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		calls = append(calls, line)
+	}
+	return calls, scanner.Err()
+}
+
+// parseCall parses a call like 0xtarget:balanceOf(address)(uint256):0xowner,
+// or 0xtarget:0x70a08231... with the calldata as hex.
+func parseCall(raw string) (parsedCall, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) < 2 {
+		return parsedCall{}, fmt.Errorf("expected the target and the signature or calldata separated by a colon")
+	}
+	if !common.IsHexAddress(parts[0]) {
+		return parsedCall{}, fmt.Errorf("the target %s is invalid", parts[0])
+	}
+	c := parsedCall{Raw: raw, Call: multicall.Call{Target: common.HexToAddress(parts[0])}}
+
+	if strings.HasPrefix(parts[1], "0x") {
+		if len(parts) > 2 {
+			return parsedCall{}, fmt.Errorf("arguments can't be given with the calldata as hex")
+		}
+		data, err := hexutil.Decode(parts[1])
+		if err != nil {
+			return parsedCall{}, err
+		}
+		c.Call.Data = data
+		return c, nil
+	}
+
+	name, inputs, outputs, err := parseSignature(parts[1])
+	if err != nil {
+		return parsedCall{}, err
+	}
+	values, err := parseArgs(inputs, parts[2:])
+	if err != nil {
+		return parsedCall{}, err
+	}
+	method := abi.NewMethod(name, name, abi.Function, "view", false, false, inputs, outputs)
+	packed, err := inputs.Pack(values...)
+	if err != nil {
+		return parsedCall{}, err
+	}
+	c.Signature = method.Sig
+	c.Outputs = outputs
+	c.Call.Data = append(method.ID, packed...)
+	return c, nil
+}
+
+// parseSignature parses a signature like name(type,...)(type,...), where the
+// return types are optional. Tuples aren't supported.
+func parseSignature(signature string) (string, abi.Arguments, abi.Arguments, error) {
+	open := strings.Index(signature, "(")
+	end := strings.Index(signature, ")")
+	if open < 1 || end < open {
+		return "", nil, nil, fmt.Errorf("the signature %s is invalid", signature)
+	}
+	name := signature[:open]
+	inputs, err := parseTypes(signature[open+1 : end])
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	rest := signature[end+1:]
+	if rest == "" {
+		return name, inputs, nil, nil
+	}
+	if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+		return "", nil, nil, fmt.Errorf("the return types %s are invalid", rest)
+	}
+	outputs, err := parseTypes(rest[1 : len(rest)-1])
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return name, inputs, outputs, nil
+}
+
+func parseTypes(types string) (abi.Arguments, error) {
+	if strings.ContainsAny(types, "()") {
+		return nil, fmt.Errorf("tuples aren't supported")
+	}
+	var args abi.Arguments
+	if types == "" {
+		return args, nil
+	}
+	for i, t := range strings.Split(types, ",") {
+		parsed, err := abi.NewType(strings.TrimSpace(t), "", nil)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, abi.Argument{Name: fmt.Sprintf("arg%d", i), Type: parsed})
+	}
+	return args, nil
+}
+
+func parseArgs(inputs abi.Arguments, args []string) ([]interface{}, error) {
+	if len(args) != len(inputs) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(inputs), len(args))
+	}
+	values := make([]interface{}, 0, len(args))
+	for i, arg := range args {
+		v, err := parseArg(inputs[i].Type, arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// parseArg converts an argument to the Go type the ABI packs for the type.
+func parseArg(t abi.Type, arg string) (interface{}, error) {
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(arg) {
+			return nil, fmt.Errorf("the address %s is invalid", arg)
+		}
+		return common.HexToAddress(arg), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(arg)
+	case abi.StringTy:
+		return arg, nil
+	case abi.BytesTy:
+		return hexutil.Decode(arg)
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > t.Size {
+			return nil, fmt.Errorf("%s is longer than %d bytes", arg, t.Size)
+		}
+		v := reflect.New(t.GetType()).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v.Interface(), nil
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(arg, 0)
+		if !ok {
+			return nil, fmt.Errorf("%s isn't a number", arg)
+		}
+		if !inRange(t, n) {
+			return nil, fmt.Errorf("%s is out of range for %s", arg, t)
+		}
+		// Only the sizes with a native Go type aren't packed from a big.Int.
+		if t.GetType() == reflect.TypeOf(n) {
+			return n, nil
+		}
+		if t.T == abi.IntTy {
+			return reflect.ValueOf(n.Int64()).Convert(t.GetType()).Interface(), nil
+		}
+		return reflect.ValueOf(n.Uint64()).Convert(t.GetType()).Interface(), nil
+	}
+	return nil, fmt.Errorf("arguments of type %s aren't supported", t)
+}
+
+func inRange(t abi.Type, n *big.Int) bool {
+	if t.T == abi.UintTy {
+		return n.Sign() >= 0 && n.BitLen() <= t.Size
+	}
+	// The magnitude of a negative int is one more than the largest positive.
+	if n.Sign() < 0 {
+		return new(big.Int).Not(n).BitLen() < t.Size
+	}
+	return n.BitLen() < t.Size
+}
+
+func decodeResult(c parsedCall, r multicall.Result) callOutput {
+	o := callOutput{Target: c.Call.Target, Signature: c.Signature}
+	if r.Err != nil {
+		o.Error = r.Err.Error()
+		return o
+	}
+	o.Success = true
+	o.ReturnData = r.Data
+	if len(c.Outputs) == 0 {
+		return o
+	}
+	values, err := util.DecodeReturnData(c.Outputs, r.Data)
+	if err != nil {
+		o.Error = err.Error()
+		return o
+	}
+	o.Values = values
+	return o
+}
+
+func printOutput(c parsedCall, o callOutput) {
+	switch {
+	case !o.Success:
+		fmt.Printf("%s: error: %s\n", c.Raw, o.Error)
+	case len(o.Values) > 0:
+		values := make([]string, 0, len(o.Values))
+		for _, v := range o.Values {
+			values = append(values, fmt.Sprint(v))
+		}
+		fmt.Printf("%s: %s\n", c.Raw, strings.Join(values, ", "))
+	case o.Error != "":
+		fmt.Printf("%s: %s (%s)\n", c.Raw, o.ReturnData, o.Error)
+	default:
+		fmt.Printf("%s: %s\n", c.Raw, o.ReturnData)
+	}
+}
+
+func init() {
+	flagSet := MulticallCmd.PersistentFlags()
+	flagSet.StringVarP(&inputMulticall.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint url")
+	flagSet.StringVarP(&inputMulticall.Block, "block", "b", "latest", "The block number or tag the calls are run at")
+	flagSet.StringVar(&inputMulticall.MulticallAddress, "multicall-address", multicall.DefaultAddress, "The Multicall3 contract the calls are batched with. Without a contract at the address, or if empty, the calls are batched as JSON-RPC requests instead")
+	flagSet.IntVar(&inputMulticall.BatchSize, "batch-size", multicall.DefaultBatchSize, "The number of calls per batch")
+	flagSet.StringVar(&inputMulticall.CallsFile, "calls-file", "", "A file with one call per line, in the same format as the arguments")
+}
@@ -0,0 +1,109 @@
+package nodekey
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// bootnodeFormats are the output formats of the devp2p keys. json prints
+// every key while the others print a bootnode list for the client config.
+var bootnodeFormats = []string{"json", "enodes", "enrs", "bor", "geth", "erigon"}
+
+// ethENREntry is the eth entry advertising the fork ID of the node, which
+// is how clients filter discovered peers by chain.
+type ethENREntry struct {
+	ForkID forkid.ID
+	Rest   []rlp.RawValue `rlp:"tail"`
+}
+
+func (e ethENREntry) ENRKey() string {
+	return "eth"
+}
+
+// loadForkID computes the fork ID from the genesis file at the head block.
+// The genesis hash is computed from the genesis unless it's given.
+func loadForkID(genesisFile, genesisHash string, head uint64) (*forkid.ID, error) {
+	data, err := os.ReadFile(genesisFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the genesis file: %w", err)
+	}
+	var gen core.Genesis
+	if err = json.Unmarshal(data, &gen); err != nil {
+		return nil, fmt.Errorf("unable to parse the genesis file: %w", err)
+	}
+	if gen.Config == nil {
+		return nil, fmt.Errorf("the genesis file doesn't have a chain config")
+	}
+
+	hash := common.HexToHash(genesisHash)
+	if genesisHash == "" {
+		hash = gen.ToBlock().Hash()
+	}
+	id := forkid.NewID(gen.Config, hash, head)
+	return &id, nil
+}
+
+// writeNodeKeys writes each private key to its own file in the directory in
+// the format of the clients' --nodekey flag.
+func writeNodeKeys(dir string, keys []nodeKeyOut) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	for i, k := range keys {
+		name := filepath.Join(dir, fmt.Sprintf("nodekey-%d", i))
+		if err := os.WriteFile(name, []byte(k.PrivateKey), 0o600); err != nil {
+			return fmt.Errorf("unable to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// printBootnodes prints the nodes as a list or a config snippet for the
+// client.
+func printBootnodes(w io.Writer, keys []nodeKeyOut, format string) error {
+	enodes := make([]string, 0, len(keys))
+	enrs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		enodes = append(enodes, k.Enode)
+		enrs = append(enrs, k.ENR)
+	}
+
+	switch format {
+	case "enodes":
+		fmt.Fprintln(w, strings.Join(enodes, ","))
+	case "enrs":
+		fmt.Fprintln(w, strings.Join(enrs, ","))
+	case "bor":
+		fmt.Fprintf(w, "[p2p.discovery]\n  bootnodes = %s\n", tomlList(enodes))
+	case "geth":
+		fmt.Fprintf(w, "[Node.P2P]\nBootstrapNodes = %s\nBootstrapNodesV5 = %s\n", tomlList(enodes), tomlList(enrs))
+	case "erigon":
+		fmt.Fprintf(w, "bootnodes = %q\n", strings.Join(enodes, ","))
+	default:
+		for _, k := range keys {
+			out, err := json.Marshal(k)
+			if err != nil {
+				return fmt.Errorf("could not json marshal the key data %w", err)
+			}
+			fmt.Fprintln(w, string(out))
+		}
+	}
+	return nil
+}
+
+func tomlList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", v))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
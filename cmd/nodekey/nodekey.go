@@ -10,13 +10,17 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"strings"
 
 	_ "embed"
 
+	"github.com/ethereum/go-ethereum/core/forkid"
 	gethcrypto "github.com/ethereum/go-ethereum/crypto"
 	gethenode "github.com/ethereum/go-ethereum/p2p/enode"
 	libp2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
 	libp2ppeer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"golang.org/x/exp/slices"
@@ -45,6 +49,12 @@ var (
 	inputNodeKeySign            *bool
 	inputNodeKeySeed            *uint64
 	inputNodeKeyMarshalProtobuf *bool
+	inputNodeKeyCount           *int
+	inputNodeKeyFormat          *string
+	inputNodeKeyDir             *string
+	inputNodeKeyGenesis         *string
+	inputNodeKeyGenesisHash     *string
+	inputNodeKeyHead            *uint64
 )
 
 type (
@@ -53,6 +63,8 @@ type (
 		PrivateKey     string
 		FullPrivateKey string `json:",omitempty"`
 		ENR            string `json:",omitempty"`
+		Enode          string `json:",omitempty"`
+		ForkID         string `json:",omitempty"`
 		Seed           uint64 `json:",omitempty"`
 	}
 )
@@ -67,11 +79,28 @@ var NodekeyCmd = &cobra.Command{
 		var withSeed bool
 		switch *inputNodeKeyProtocol {
 		case "devp2p":
-			var err error
-			nko, err = generateDevp2pNodeKey()
-			if err != nil {
-				return err
+			var forkID *forkid.ID
+			if *inputNodeKeyGenesis != "" {
+				var err error
+				forkID, err = loadForkID(*inputNodeKeyGenesis, *inputNodeKeyGenesisHash, *inputNodeKeyHead)
+				if err != nil {
+					return err
+				}
+			}
+			keys := make([]nodeKeyOut, 0, *inputNodeKeyCount)
+			for i := 0; i < *inputNodeKeyCount; i++ {
+				key, err := generateDevp2pNodeKey(i, forkID)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, key)
 			}
+			if *inputNodeKeyDir != "" {
+				if err := writeNodeKeys(*inputNodeKeyDir, keys); err != nil {
+					return err
+				}
+			}
+			return printBootnodes(os.Stdout, keys, *inputNodeKeyFormat)
 		case "seed-libp2p":
 			withSeed = true
 			fallthrough
@@ -112,16 +141,29 @@ var NodekeyCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
+			if *inputNodeKeyCount < 1 {
+				return fmt.Errorf("the count must be at least 1")
+			}
+			if *inputNodeKeyFile != "" && *inputNodeKeyCount != 1 {
+				return fmt.Errorf("only one key can be loaded from a file")
+			}
+			if err := util.UseJSONFormat(cmd.Flags(), "format", "json"); err != nil {
+				return err
+			}
+			if !slices.Contains(bootnodeFormats, *inputNodeKeyFormat) {
+				return fmt.Errorf("the format must be one of %v", bootnodeFormats)
+			}
 		}
+		devp2pFlags := []string{"count", "format", "key-dir", "genesis", "genesis-hash", "head"}
 		if *inputNodeKeyProtocol == "libp2p" {
-			invalidFlags := []string{"file", "ip", "tcp", "udp", "sign", "seed"}
+			invalidFlags := append([]string{"file", "ip", "tcp", "udp", "sign", "seed"}, devp2pFlags...)
 			err := validateNodeKeyFlags(cmd, invalidFlags)
 			if err != nil {
 				return err
 			}
 		}
 		if *inputNodeKeyProtocol == "seed-libp2p" {
-			invalidFlags := []string{"file", "ip", "tcp", "udp", "sign"}
+			invalidFlags := append([]string{"file", "ip", "tcp", "udp", "sign"}, devp2pFlags...)
 			err := validateNodeKeyFlags(cmd, invalidFlags)
 			if err != nil {
 				return err
@@ -168,7 +210,11 @@ func keyTypeToInt(keyType string) (int, error) {
 	}
 }
 
-func generateDevp2pNodeKey() (nodeKeyOut, error) {
+// generateDevp2pNodeKey generates the key of the node at the index. The ports
+// are offset by the index so several nodes can run on the same host. The
+// record is always signed for bootnode lists and when the fork ID is
+// advertised because any extra entry needs a signature.
+func generateDevp2pNodeKey(index int, forkID *forkid.ID) (nodeKeyOut, error) {
 	nodeKey, err := gethcrypto.GenerateKey()
 
 	if *inputNodeKeyFile != "" {
@@ -184,10 +230,18 @@ func generateDevp2pNodeKey() (nodeKeyOut, error) {
 	nko.PrivateKey = hex.EncodeToString(prvKeyBytes)
 
 	ip := net.ParseIP(*inputNodeKeyIP)
-	n := gethenode.NewV4(&nodeKey.PublicKey, ip, *inputNodeKeyTCP, *inputNodeKeyUDP)
+	udp := *inputNodeKeyUDP
+	if udp == 0 {
+		udp = *inputNodeKeyTCP
+	}
+	n := gethenode.NewV4(&nodeKey.PublicKey, ip, *inputNodeKeyTCP+index, udp+index)
 
-	if *inputNodeKeySign {
+	if *inputNodeKeySign || forkID != nil || *inputNodeKeyFormat != "json" {
 		r := n.Record()
+		if forkID != nil {
+			r.Set(ethENREntry{ForkID: *forkID})
+			nko.ForkID = fmt.Sprintf("%#x/%d", forkID.Hash, forkID.Next)
+		}
 		err = gethenode.SignV4(r, nodeKey)
 		if err != nil {
 			return nodeKeyOut{}, err
@@ -198,8 +252,8 @@ func generateDevp2pNodeKey() (nodeKeyOut, error) {
 		}
 	}
 
-	// ko.ENR = n.URLv4()
 	nko.ENR = n.String()
+	nko.Enode = n.URLv4()
 	return nko, nil
 }
 
@@ -254,10 +308,16 @@ func init() {
 	inputNodeKeyType = NodekeyCmd.PersistentFlags().String("key-type", "ed25519", "ed25519|secp256k1|ecdsa|rsa")
 	inputNodeKeyIP = NodekeyCmd.PersistentFlags().StringP("ip", "i", "0.0.0.0", "The IP to be associated with this address")
 	inputNodeKeyTCP = NodekeyCmd.PersistentFlags().IntP("tcp", "t", 30303, "The tcp Port to be associated with this address")
-	inputNodeKeyUDP = NodekeyCmd.PersistentFlags().IntP("udp", "u", 0, "The udp Port to be associated with this address")
+	inputNodeKeyUDP = NodekeyCmd.PersistentFlags().IntP("udp", "u", 0, "The udp Port to be associated with this address. Defaults to the tcp port")
 	inputNodeKeySign = NodekeyCmd.PersistentFlags().BoolP("sign", "s", false, "Should the node record be signed?")
 	inputNodeKeySeed = NodekeyCmd.PersistentFlags().Uint64P("seed", "S", 271828, "A numeric seed value")
 	inputNodeKeyMarshalProtobuf = NodekeyCmd.PersistentFlags().BoolP("marshal-protobuf", "m", false, "If true the libp2p key will be marshaled to protobuf format rather than raw")
 
 	inputNodeKeyFile = NodekeyCmd.PersistentFlags().StringP("file", "f", "", "A file with the private nodekey in hex format")
+	inputNodeKeyCount = NodekeyCmd.PersistentFlags().Int("count", 1, "The number of devp2p keys to generate. The ports of each node are offset by its index")
+	inputNodeKeyFormat = NodekeyCmd.PersistentFlags().String("format", "json", "The devp2p output format ["+strings.Join(bootnodeFormats, ", ")+"]")
+	inputNodeKeyDir = NodekeyCmd.PersistentFlags().String("key-dir", "", "A directory to write each devp2p private key to as nodekey-<index>")
+	inputNodeKeyGenesis = NodekeyCmd.PersistentFlags().String("genesis", "", "A genesis file used to add the fork ID to the node record")
+	inputNodeKeyGenesisHash = NodekeyCmd.PersistentFlags().String("genesis-hash", "", "The genesis block hash if it can't be computed from the genesis file")
+	inputNodeKeyHead = NodekeyCmd.PersistentFlags().Uint64("head", 0, "The block number the fork ID is computed at")
 }
@@ -7,7 +7,7 @@ import (
 )
 
 func TestGenerateDevp2pNodeKey(t *testing.T) {
-	res, err := generateDevp2pNodeKey()
+	res, err := generateDevp2pNodeKey(0, nil)
 	if err != nil {
 		t.Errorf("could not generate eth key: %v", err)
 	} else {
@@ -0,0 +1,161 @@
+package crawl
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// budgetWindow is how often the failure rate of the attempts is checked.
+	budgetWindow = 5 * time.Second
+
+	// budgetMinAttempts is the number of attempts a window needs for its
+	// failure rate to be acted on.
+	budgetMinAttempts = 20
+
+	// budgetMinRate is the rate the backoff doesn't go under, so the crawl
+	// always makes progress.
+	budgetMinRate = rate.Limit(1)
+)
+
+// dialBudget bounds the concurrency and rate of the attempts of one kind,
+// e.g. RLPx dials or discovery requests. The rate backs off when too many
+// attempts fail, and immediately when the OS runs out of sockets or file
+// descriptors, then grows back to the maximum while attempts succeed.
+type dialBudget struct {
+	name      string
+	sem       chan struct{}
+	limiter   *rate.Limiter
+	max       rate.Limit
+	threshold float64
+
+	mu          sync.Mutex
+	windowStart time.Time
+	attempts    int
+	failures    int
+}
+
+// newDialBudget creates a budget of concurrency attempts at once and at most
+// maxRate attempts per second, backing off when more than threshold of the
+// attempts fail. A zero rate doesn't limit the rate.
+func newDialBudget(name string, concurrency int, maxRate, threshold float64) *dialBudget {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	limit := rate.Inf
+	if maxRate > 0 {
+		limit = rate.Limit(maxRate)
+	}
+
+	return &dialBudget{
+		name:        name,
+		sem:         make(chan struct{}, concurrency),
+		limiter:     rate.NewLimiter(limit, concurrency),
+		max:         limit,
+		threshold:   threshold,
+		windowStart: time.Now(),
+	}
+}
+
+// acquire waits for the rate and concurrency to allow another attempt. It
+// returns false if done is closed first.
+func (b *dialBudget) acquire(done <-chan struct{}) bool {
+	r := b.limiter.Reserve()
+	if delay := r.Delay(); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			r.Cancel()
+			return false
+		}
+	}
+
+	select {
+	case b.sem <- struct{}{}:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// release frees the attempt and records its result.
+func (b *dialBudget) release(err error) {
+	<-b.sem
+
+	if b.max == rate.Inf {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.attempts++
+	if err != nil {
+		b.failures++
+	}
+
+	// The attempts in flight when the OS runs out fail together, so only the
+	// first of them backs off.
+	if isResourceExhausted(err) && time.Since(b.windowStart) >= time.Second {
+		b.backoff("resources exhausted")
+		return
+	}
+
+	if time.Since(b.windowStart) < budgetWindow || b.attempts < budgetMinAttempts {
+		return
+	}
+
+	if float64(b.failures)/float64(b.attempts) > b.threshold {
+		b.backoff("failure rate exceeded")
+	} else if limit := b.limiter.Limit(); limit < b.max {
+		limit *= 1.25
+		if limit > b.max {
+			limit = b.max
+		}
+		b.limiter.SetLimit(limit)
+		log.Debug().Str("budget", b.name).Float64("rate", float64(limit)).Msg("Increasing attempt rate")
+	}
+	b.reset()
+}
+
+// backoff halves the rate and starts a new window. The caller must hold the
+// lock.
+func (b *dialBudget) backoff(reason string) {
+	limit := b.limiter.Limit() / 2
+	if limit < budgetMinRate {
+		limit = budgetMinRate
+	}
+	b.limiter.SetLimit(limit)
+
+	log.Info().
+		Str("budget", b.name).
+		Str("reason", reason).
+		Int("attempts", b.attempts).
+		Int("failures", b.failures).
+		Float64("rate", float64(limit)).
+		Msg("Backing off attempt rate")
+	b.reset()
+}
+
+func (b *dialBudget) reset() {
+	b.windowStart = time.Now()
+	b.attempts = 0
+	b.failures = 0
+}
+
+// isResourceExhausted returns whether the error is the OS running out of file
+// descriptors, sockets, or ephemeral ports.
+func isResourceExhausted(err error) bool {
+	return errors.Is(err, syscall.EMFILE) ||
+		errors.Is(err, syscall.ENFILE) ||
+		errors.Is(err, syscall.ENOBUFS) ||
+		errors.Is(err, syscall.EADDRNOTAVAIL)
+}
@@ -1,7 +1,14 @@
 package crawl
 
 import (
+	"crypto/ecdsa"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -11,6 +18,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/maticnetwork/polygon-cli/p2p"
+	"github.com/maticnetwork/polygon-cli/util"
 )
 
 type (
@@ -23,8 +31,33 @@ type (
 		NodesFile            string
 		Database             string
 		RevalidationInterval string
+		NodesFormat          string
+		SeedNodesFile        string
+		DNSDomain            string
+		DNSKey               string
+		DNSDir               string
+		DNSLinks             []string
+		DNSInterval          string
+		DialConcurrency      int
+		DialRate             float64
+		DiscoveryRate        float64
+		BackoffFailureRate   float64
+		Continuous           bool
+		RevisitInterval      string
+		DeadAfter            string
+		SaveInterval         string
+		LivenessFile         string
+		ChurnInterval        string
+		ChurnFile            string
 
 		revalidationInterval time.Duration
+		nodesFormat          p2p.NodeSetFormat
+		dnsKey               *ecdsa.PrivateKey
+		dnsInterval          time.Duration
+		revisitInterval      time.Duration
+		deadAfter            time.Duration
+		saveInterval         time.Duration
+		churnInterval        time.Duration
 	}
 )
 
@@ -37,8 +70,21 @@ var (
 var CrawlCmd = &cobra.Command{
 	Use:   "crawl [nodes file]",
 	Short: "Crawl a network on the devp2p layer and generate a nodes JSON file.",
-	Long:  "If no nodes.json file exists, it will be created.",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `If no nodes.json file exists, it will be created.
+
+With --dns-domain, the crawled nodes are also written to --dns-dir as an
+EIP-1459 DNS discovery tree signed with --dns-key. The tree is rewritten every
+--dns-interval while crawling with an incremented sequence number, and the
+zone.txt file can be imported into the DNS provider to publish it. Clients can
+then bootstrap from the enrtree:// URL in enrtree-info.json.
+
+With --continuous, the crawl runs until it's interrupted instead of for
+--timeout. Known nodes are revisited every --revisit-interval, their liveness
+score, latency, and first and last seen times are kept in --liveness-file, and
+the ones not seen for --dead-after are removed from the nodes file, which is
+rewritten every --save-interval. A churn summary of the nodes seen, new, and
+lost is logged and appended to --churn-file every --churn-interval.`,
+	Args: cobra.MinimumNArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
 		inputCrawlParams.NodesFile = args[0]
 
@@ -52,6 +98,54 @@ var CrawlCmd = &cobra.Command{
 			return err
 		}
 
+		inputCrawlParams.nodesFormat, err = p2p.ParseNodeSetFormat(inputCrawlParams.NodesFormat)
+		if err != nil {
+			return err
+		}
+
+		if len(inputCrawlParams.Bootnodes) == 0 && len(inputCrawlParams.SeedNodesFile) == 0 {
+			return errors.New("at least one of --bootnodes or --seed-nodes is required")
+		}
+
+		if len(inputCrawlParams.DNSDomain) > 0 {
+			if len(inputCrawlParams.DNSKey) == 0 || len(inputCrawlParams.DNSDir) == 0 {
+				return errors.New("--dns-key and --dns-dir are required with --dns-domain")
+			}
+			inputCrawlParams.dnsKey, err = crypto.LoadECDSA(inputCrawlParams.DNSKey)
+			if err != nil {
+				return fmt.Errorf("unable to load the DNS tree signing key: %w", err)
+			}
+			inputCrawlParams.dnsInterval, err = time.ParseDuration(inputCrawlParams.DNSInterval)
+			if err != nil {
+				return err
+			}
+		}
+
+		if inputCrawlParams.BackoffFailureRate <= 0 || inputCrawlParams.BackoffFailureRate > 1 {
+			return errors.New("--backoff-failure-rate must be between 0 and 1")
+		}
+
+		if inputCrawlParams.Continuous {
+			if inputCrawlParams.revisitInterval, err = time.ParseDuration(inputCrawlParams.RevisitInterval); err != nil {
+				return err
+			}
+			if inputCrawlParams.revisitInterval <= 0 {
+				return errors.New("--revisit-interval must be positive")
+			}
+			if inputCrawlParams.deadAfter, err = time.ParseDuration(inputCrawlParams.DeadAfter); err != nil {
+				return err
+			}
+			if inputCrawlParams.saveInterval, err = time.ParseDuration(inputCrawlParams.SaveInterval); err != nil {
+				return err
+			}
+			if inputCrawlParams.churnInterval, err = time.ParseDuration(inputCrawlParams.ChurnInterval); err != nil {
+				return err
+			}
+			if len(inputCrawlParams.LivenessFile) == 0 {
+				inputCrawlParams.LivenessFile = strings.TrimSuffix(inputCrawlParams.NodesFile, filepath.Ext(inputCrawlParams.NodesFile)) + ".liveness.json"
+			}
+		}
+
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -62,9 +156,24 @@ var CrawlCmd = &cobra.Command{
 
 		var cfg discover.Config
 		cfg.PrivateKey, _ = crypto.GenerateKey()
-		cfg.Bootnodes, err = p2p.ParseBootnodes(inputCrawlParams.Bootnodes)
-		if err != nil {
-			return fmt.Errorf("unable to parse bootnodes: %w", err)
+		if len(inputCrawlParams.Bootnodes) > 0 {
+			cfg.Bootnodes, err = p2p.ParseBootnodes(inputCrawlParams.Bootnodes)
+			if err != nil {
+				return fmt.Errorf("unable to parse bootnodes: %w", err)
+			}
+		}
+
+		// Seed nodes are used both to bootstrap discovery and as input nodes to
+		// revalidate, which allows importing node lists from other tooling.
+		if len(inputCrawlParams.SeedNodesFile) > 0 {
+			seeds, err := p2p.ReadNodeSet(inputCrawlParams.SeedNodesFile)
+			if err != nil {
+				return err
+			}
+
+			log.Info().Int("seeds", len(seeds)).Msg("Imported seed nodes")
+			cfg.Bootnodes = append(cfg.Bootnodes, seeds...)
+			nodes = append(nodes, seeds...)
 		}
 
 		db, err := enode.OpenDB(inputCrawlParams.Database)
@@ -85,25 +194,165 @@ var CrawlCmd = &cobra.Command{
 		defer disc.Close()
 
 		c := newCrawler(nodes, disc, disc.RandomNodes())
+		c.dials = newDialBudget("dial", inputCrawlParams.DialConcurrency, inputCrawlParams.DialRate, inputCrawlParams.BackoffFailureRate)
+		c.requests = newDialBudget("discovery", inputCrawlParams.Threads, inputCrawlParams.DiscoveryRate, inputCrawlParams.BackoffFailureRate)
 		c.revalidateInterval = inputCrawlParams.revalidationInterval
+		if inputCrawlParams.dnsKey != nil {
+			c.publish = func(nodes p2p.NodeSet) {
+				if err := writeDNSTree(nodes); err != nil {
+					log.Error().Err(err).Msg("Unable to write the DNS discovery tree")
+				}
+			}
+			c.publishInterval = inputCrawlParams.dnsInterval
+		}
+
+		timeout := inputCrawlParams.timeout
+		if inputCrawlParams.Continuous {
+			if err = continuous(c); err != nil {
+				return err
+			}
+			timeout = 0
+		}
 
 		log.Info().Msg("Starting crawl")
 
-		output := c.run(inputCrawlParams.timeout, inputCrawlParams.Threads)
-		return p2p.WriteNodeSet(inputCrawlParams.NodesFile, output)
+		output := c.run(timeout, inputCrawlParams.Threads)
+		if err = p2p.WriteNodeSet(inputCrawlParams.NodesFile, output, inputCrawlParams.nodesFormat); err != nil {
+			return err
+		}
+		if c.liveness != nil {
+			if err = c.liveness.save(inputCrawlParams.LivenessFile); err != nil {
+				return err
+			}
+		}
+		if inputCrawlParams.dnsKey != nil {
+			if err = writeDNSTree(output); err != nil {
+				return err
+			}
+		}
+		if util.JSONOutput() {
+			result := crawlOutput{
+				NodesFile:   inputCrawlParams.NodesFile,
+				NodesFormat: string(inputCrawlParams.nodesFormat),
+				Nodes:       len(output),
+			}
+			if c.liveness != nil {
+				result.LivenessFile = inputCrawlParams.LivenessFile
+			}
+			return util.PrintJSON(result)
+		}
+		return nil
 	},
 }
 
+// crawlOutput is the result of the crawl that's printed with --json. The
+// nodes themselves are in the nodes file.
+type crawlOutput struct {
+	NodesFile    string `json:"nodesFile"`
+	NodesFormat  string `json:"nodesFormat"`
+	Nodes        int    `json:"nodes"`
+	LivenessFile string `json:"livenessFile,omitempty"`
+}
+
+// continuous sets the crawler up to run until interrupted, revisiting the
+// known nodes and periodically saving the nodes, liveness, and churn.
+func continuous(c *crawler) error {
+	liveness, err := loadLiveness(inputCrawlParams.LivenessFile)
+	if err != nil {
+		return fmt.Errorf("unable to load the liveness file: %w", err)
+	}
+
+	c.liveness = liveness
+	c.revisitInterval = inputCrawlParams.revisitInterval
+	c.deadAfter = inputCrawlParams.deadAfter
+	c.saveInterval = inputCrawlParams.saveInterval
+	c.save = func(nodes p2p.NodeSet) {
+		if err := p2p.WriteNodeSet(inputCrawlParams.NodesFile, nodes, inputCrawlParams.nodesFormat); err != nil {
+			log.Error().Err(err).Msg("Failed to write nodes to file")
+		}
+		if err := liveness.save(inputCrawlParams.LivenessFile); err != nil {
+			log.Error().Err(err).Msg("Failed to write the liveness file")
+		}
+	}
+	c.churnInterval = inputCrawlParams.churnInterval
+	c.onChurn = func(summary churnSummary) {
+		log.Info().Interface("churn", summary).Msg("Network churn")
+		if len(inputCrawlParams.ChurnFile) == 0 {
+			return
+		}
+		if err := appendChurn(inputCrawlParams.ChurnFile, summary); err != nil {
+			log.Error().Err(err).Msg("Failed to write the churn summary")
+		}
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Info().Msg("Stopping crawl...")
+		close(stop)
+	}()
+	c.stop = stop
+
+	return nil
+}
+
+// writeDNSTree writes the nodes as a signed DNS discovery tree.
+func writeDNSTree(nodes p2p.NodeSet) error {
+	info, err := p2p.WriteDNSTree(inputCrawlParams.DNSDir, inputCrawlParams.DNSDomain,
+		inputCrawlParams.dnsKey, nodes, inputCrawlParams.DNSLinks)
+	if err != nil {
+		return err
+	}
+	log.Info().Uint("seq", info.Seq).Str("url", info.URL).Int("nodes", len(nodes)).Msg("Wrote DNS discovery tree")
+	return nil
+}
+
 func init() {
 	CrawlCmd.PersistentFlags().StringVarP(&inputCrawlParams.Bootnodes, "bootnodes", "b", "",
-		`Comma separated nodes used for bootstrapping. At least one bootnode is
-required, so other nodes in the network can discover each other.`)
-	if err := CrawlCmd.MarkPersistentFlagRequired("bootnodes"); err != nil {
-		log.Error().Err(err).Msg("Failed to mark bootnodes as required persistent flag")
-	}
+		`Comma separated nodes used for bootstrapping. At least one bootnode or seed
+node is required, so other nodes in the network can discover each other.`)
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.SeedNodesFile, "seed-nodes", "",
+		`Nodes file (enode or ENR list, or geth nodes.json) used as additional
+bootnodes and crawl seeds`)
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.NodesFormat, "nodes-format", string(p2p.NodeSetFormatEnode),
+		"Format of the written nodes file (enode|enr|geth)")
 	CrawlCmd.PersistentFlags().StringVarP(&inputCrawlParams.Timeout, "timeout", "t", "30m0s", "Time limit for the crawl")
 	CrawlCmd.PersistentFlags().IntVarP(&inputCrawlParams.Threads, "parallel", "p", 16, "How many parallel discoveries to attempt")
 	CrawlCmd.PersistentFlags().Uint64VarP(&inputCrawlParams.NetworkID, "network-id", "n", 0, "Filter discovered nodes by this network id")
 	CrawlCmd.PersistentFlags().StringVarP(&inputCrawlParams.Database, "database", "d", "", "Node database for updating and storing client information")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.DNSDomain, "dns-domain", "",
+		"Publish the crawled nodes as an EIP-1459 DNS discovery tree under this domain")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.DNSKey, "dns-key", "", "Node key file used to sign the DNS discovery tree")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.DNSDir, "dns-dir", "", "Directory the DNS discovery tree records are written to")
+	CrawlCmd.PersistentFlags().StringSliceVar(&inputCrawlParams.DNSLinks, "dns-links", nil, "Comma separated enrtree:// URLs of other trees to link to")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.DNSInterval, "dns-interval", "5m",
+		"How often the DNS discovery tree is rewritten during the crawl (0 to only write it at the end)")
+	CrawlCmd.PersistentFlags().IntVar(&inputCrawlParams.DialConcurrency, "dial-concurrency", 16,
+		"How many RLPx dials to attempt at once when filtering by network id")
+	CrawlCmd.PersistentFlags().Float64Var(&inputCrawlParams.DialRate, "dial-rate", 100,
+		"Maximum RLPx dials per second, which backs off when they fail (0 for no limit)")
+	CrawlCmd.PersistentFlags().Float64Var(&inputCrawlParams.DiscoveryRate, "discovery-rate", 0,
+		"Maximum discovery ENR requests per second, which backs off when they fail (0 for no limit)")
+	CrawlCmd.PersistentFlags().Float64Var(&inputCrawlParams.BackoffFailureRate, "backoff-failure-rate", 0.9,
+		`Share of the dials or requests failing over a few seconds that halves their
+rate. The rate also halves when the OS runs out of sockets, and grows back
+while they succeed.`)
+	CrawlCmd.PersistentFlags().BoolVar(&inputCrawlParams.Continuous, "continuous", false,
+		"Crawl until interrupted, revisiting the known nodes and tracking their liveness")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.RevisitInterval, "revisit-interval", "30m",
+		"How often the known nodes are checked for liveness in the continuous mode")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.DeadAfter, "dead-after", "24h",
+		"How long a node can go unseen before it's removed from the nodes file in the continuous mode")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.SaveInterval, "save-interval", "5m",
+		"How often the nodes and liveness files are written in the continuous mode")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.LivenessFile, "liveness-file", "",
+		`File the liveness of the nodes is kept in across restarts in the continuous mode
+(default is the nodes file with a .liveness.json extension)`)
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.ChurnInterval, "churn-interval", "24h",
+		"How often the network churn is summarized in the continuous mode")
+	CrawlCmd.PersistentFlags().StringVar(&inputCrawlParams.ChurnFile, "churn-file", "",
+		"File the churn summaries are appended to as JSON lines")
 	CrawlCmd.PersistentFlags().StringVarP(&inputCrawlParams.RevalidationInterval, "revalidation-interval", "r", "10m", "Time before retrying to connect to a failed peer")
 }
@@ -23,6 +23,28 @@ type crawler struct {
 	// settings
 	revalidateInterval time.Duration
 	mu                 sync.RWMutex
+
+	// publish is called with a snapshot of the output every publishInterval
+	// while crawling, so the crawl results can be used before it's done.
+	publish         func(p2p.NodeSet)
+	publishInterval time.Duration
+
+	// dials and requests bound the RLPx dials and the discovery ENR requests.
+	dials    *dialBudget
+	requests *dialBudget
+
+	// liveness is set in the continuous mode, where the known nodes are
+	// revisited every revisitInterval and the ones not seen for deadAfter are
+	// removed from the output. save and onChurn are then called every
+	// saveInterval and churnInterval, and the crawl runs until stop is closed.
+	liveness        *livenessTracker
+	revisitInterval time.Duration
+	deadAfter       time.Duration
+	save            func(p2p.NodeSet)
+	saveInterval    time.Duration
+	onChurn         func(churnSummary)
+	churnInterval   time.Duration
+	stop            <-chan struct{}
 }
 
 const (
@@ -51,7 +73,7 @@ func newCrawler(input []*enode.Node, disc resolver, iters ...enode.Iterator) *cr
 	// Copy input to output initially. Any nodes that fail validation
 	// will be dropped from output during the run.
 	for _, n := range input {
-		c.output[n.ID()] = n.URLv4()
+		c.output[n.ID()] = n
 	}
 	return c
 }
@@ -61,6 +83,11 @@ func (c *crawler) run(timeout time.Duration, nthreads int) p2p.NodeSet {
 		timeoutTimer = time.NewTimer(timeout)
 		timeoutCh    <-chan time.Time
 		statusTicker = time.NewTicker(time.Second * 8)
+		publishCh    <-chan time.Time
+		revisitCh    <-chan time.Time
+		saveCh       <-chan time.Time
+		churnCh      <-chan time.Time
+		churnStart   = time.Now()
 		doneCh       = make(chan enode.Iterator, len(c.iters))
 		liveIters    = len(c.iters)
 	)
@@ -69,11 +96,33 @@ func (c *crawler) run(timeout time.Duration, nthreads int) p2p.NodeSet {
 	}
 	defer timeoutTimer.Stop()
 	defer statusTicker.Stop()
+	if c.publish != nil && c.publishInterval > 0 {
+		publishTicker := time.NewTicker(c.publishInterval)
+		defer publishTicker.Stop()
+		publishCh = publishTicker.C
+	}
+	if c.liveness != nil {
+		revisitTicker := time.NewTicker(c.revisitInterval)
+		defer revisitTicker.Stop()
+		revisitCh = revisitTicker.C
+		if c.save != nil && c.saveInterval > 0 {
+			saveTicker := time.NewTicker(c.saveInterval)
+			defer saveTicker.Stop()
+			saveCh = saveTicker.C
+		}
+		if c.onChurn != nil && c.churnInterval > 0 {
+			churnTicker := time.NewTicker(c.churnInterval)
+			defer churnTicker.Stop()
+			churnCh = churnTicker.C
+		}
+	}
 	for _, it := range c.iters {
 		go c.runIterator(doneCh, it)
 	}
 	var (
 		added   uint64
+		updated uint64
+		removed uint64
 		skipped uint64
 		recent  uint64
 		wg      sync.WaitGroup
@@ -92,6 +141,10 @@ func (c *crawler) run(timeout time.Duration, nthreads int) p2p.NodeSet {
 						atomic.AddUint64(&recent, 1)
 					case nodeAdded:
 						atomic.AddUint64(&added, 1)
+					case nodeUpdated:
+						atomic.AddUint64(&updated, 1)
+					case nodeRemoved:
+						atomic.AddUint64(&removed, 1)
 					}
 				case <-c.closed:
 					return
@@ -116,12 +169,25 @@ loop:
 			}
 		case <-timeoutCh:
 			break loop
+		case <-c.stop:
+			break loop
 		case <-statusTicker.C:
 			log.Info().
 				Uint64("added", atomic.LoadUint64(&added)).
+				Uint64("updated", atomic.LoadUint64(&updated)).
+				Uint64("removed", atomic.LoadUint64(&removed)).
 				Uint64("ignored(recent)", atomic.LoadUint64(&recent)).
 				Uint64("ignored(incompatible)", atomic.LoadUint64(&skipped)).
 				Msg("Crawling in progress")
+		case <-publishCh:
+			c.publish(c.snapshot())
+		case <-revisitCh:
+			go c.revisit()
+		case <-saveCh:
+			c.save(c.snapshot())
+		case now := <-churnCh:
+			c.onChurn(c.liveness.churn(churnStart, now))
+			churnStart = now
 		}
 	}
 
@@ -136,6 +202,33 @@ loop:
 	return c.output
 }
 
+// snapshot copies the output so it can be used while the crawl goes on.
+func (c *crawler) snapshot() p2p.NodeSet {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	nodes := make(p2p.NodeSet, len(c.output))
+	for id, n := range c.output {
+		nodes[id] = n
+	}
+	return nodes
+}
+
+// revisit feeds the known nodes back to the workers, which check the ones due
+// for a liveness check.
+func (c *crawler) revisit() {
+	for _, n := range c.snapshot() {
+		if !c.liveness.due(n.ID(), c.revisitInterval) {
+			continue
+		}
+		select {
+		case c.ch <- n:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
 func (c *crawler) runIterator(done chan<- enode.Iterator, it enode.Iterator) {
 	defer func() { done <- it }()
 	for it.Next() {
@@ -150,12 +243,16 @@ func (c *crawler) runIterator(done chan<- enode.Iterator, it enode.Iterator) {
 // shouldSkipNode filters out nodes by their network id. If there is a status
 // message, skip nodes that don't have the correct network id. Otherwise, skip
 // nodes that are unable to peer.
-func shouldSkipNode(n *enode.Node) bool {
+func (c *crawler) shouldSkipNode(n *enode.Node) bool {
 	if inputCrawlParams.NetworkID == 0 {
 		return false
 	}
 
+	if !c.dials.acquire(c.closed) {
+		return true
+	}
 	conn, err := p2p.Dial(n)
+	c.dials.release(err)
 	if err != nil {
 		log.Error().Err(err).Msg("Dial failed")
 		return true
@@ -179,24 +276,41 @@ func (c *crawler) updateNode(n *enode.Node) int {
 	_, ok := c.output[n.ID()]
 	c.mu.RUnlock()
 
-	if ok {
+	// Known nodes are only checked again when their liveness is due, which is
+	// never outside of the continuous mode.
+	if ok && !c.liveness.due(n.ID(), c.revisitInterval) {
 		return nodeSkipRecent
 	}
 
 	// Filter out incompatible nodes.
-	if shouldSkipNode(n) {
+	if !ok && c.shouldSkipNode(n) {
 		return nodeSkipIncompat
 	}
 
+	if !c.requests.acquire(c.closed) {
+		return nodeSkipRecent
+	}
+	start := time.Now()
 	nn, err := c.disc.RequestENR(n)
+	c.requests.release(err)
+	unseen := c.liveness.record(n, err == nil, time.Since(start))
 	if err != nil {
+		if ok && c.liveness != nil && unseen >= c.deadAfter {
+			c.mu.Lock()
+			delete(c.output, n.ID())
+			c.mu.Unlock()
+			return nodeRemoved
+		}
 		return nodeSkipIncompat
 	}
 
 	// Store/update node in output set.
 	c.mu.Lock()
-	c.output[nn.ID()] = nn.URLv4()
+	c.output[nn.ID()] = nn
 	c.mu.Unlock()
 
+	if ok {
+		return nodeUpdated
+	}
 	return nodeAdded
 }
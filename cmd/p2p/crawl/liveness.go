@@ -0,0 +1,190 @@
+package crawl
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// livenessAlpha is the weight of the latest check in the liveness score and
+// latency averages.
+const livenessAlpha = 0.2
+
+// nodeLiveness is what the continuous crawl remembers about a node across its
+// revisits.
+type nodeLiveness struct {
+	URL          string    `json:"url"`
+	FirstChecked time.Time `json:"firstChecked"`
+	// FirstSeen and LastSeen are zero if the node never answered.
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	LastChecked time.Time `json:"lastChecked"`
+	Checks      uint64    `json:"checks"`
+	Failures    uint64    `json:"failures"`
+	// Score is the moving average of the check results, from 0 when the node
+	// never answers to 1 when it always does.
+	Score float64 `json:"score"`
+	// LatencyMs is the moving average of the ENR request round trip.
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+// churnSummary is the network churn over a period of the continuous crawl.
+type churnSummary struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Known is the number of nodes that ever answered, Live the ones seen
+	// during the period, New the ones seen for the first time, and Lost the
+	// ones seen during the previous period but not this one.
+	Known int     `json:"known"`
+	Live  int     `json:"live"`
+	New   int     `json:"new"`
+	Lost  int     `json:"lost"`
+	Churn float64 `json:"churn"`
+}
+
+// livenessTracker keeps the liveness of every node the crawl contacted, and
+// can be saved and loaded so it survives restarts.
+type livenessTracker struct {
+	mu    sync.Mutex
+	nodes map[enode.ID]*nodeLiveness
+}
+
+// loadLiveness reads the liveness file. A missing file is an empty tracker.
+func loadLiveness(path string) (*livenessTracker, error) {
+	t := &livenessTracker{nodes: make(map[enode.ID]*nodeLiveness)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &t.nodes); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// save writes the liveness file.
+func (t *livenessTracker) save(path string) error {
+	t.mu.Lock()
+	data, err := json.MarshalIndent(t.nodes, "", "    ")
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// due returns whether the node hasn't been checked within the interval. A nil
+// tracker is never due, which is the case outside of the continuous mode.
+func (t *livenessTracker) due(id enode.ID, interval time.Duration) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node, ok := t.nodes[id]
+	return !ok || time.Since(node.LastChecked) >= interval
+}
+
+// record updates the liveness of the node with the result of a check, and
+// returns how long ago it was last seen, or first checked if it never
+// answered.
+func (t *livenessTracker) record(n *enode.Node, alive bool, latency time.Duration) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node, ok := t.nodes[n.ID()]
+	if !ok {
+		node = &nodeLiveness{URL: n.URLv4(), FirstChecked: now}
+		t.nodes[n.ID()] = node
+	}
+
+	node.LastChecked = now
+	node.Checks++
+	result := 0.0
+	if alive {
+		result = 1
+		node.URL = n.URLv4()
+		if node.FirstSeen.IsZero() {
+			node.FirstSeen = now
+		}
+		node.LastSeen = now
+		ms := float64(latency.Microseconds()) / 1000
+		if node.LatencyMs == 0 {
+			node.LatencyMs = ms
+		} else {
+			node.LatencyMs += livenessAlpha * (ms - node.LatencyMs)
+		}
+	} else {
+		node.Failures++
+	}
+	if node.Checks == 1 {
+		node.Score = result
+	} else {
+		node.Score += livenessAlpha * (result - node.Score)
+	}
+
+	if node.LastSeen.IsZero() {
+		return now.Sub(node.FirstChecked)
+	}
+	return now.Sub(node.LastSeen)
+}
+
+// churn summarizes the nodes seen between start and end, compared with the
+// period of the same length before it.
+func (t *livenessTracker) churn(start, end time.Time) churnSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev := start.Add(-end.Sub(start))
+	summary := churnSummary{Start: start, End: end}
+	for _, node := range t.nodes {
+		if node.FirstSeen.IsZero() {
+			continue
+		}
+		summary.Known++
+		if !node.LastSeen.Before(start) {
+			summary.Live++
+		} else if !node.LastSeen.Before(prev) {
+			summary.Lost++
+		}
+		if !node.FirstSeen.Before(start) {
+			summary.New++
+		}
+	}
+
+	if summary.Live > 0 {
+		summary.Churn = float64(summary.New+summary.Lost) / float64(summary.Live)
+	}
+
+	return summary
+}
+
+// appendChurn appends the summary as a JSON line to the file.
+func appendChurn(path string, summary churnSummary) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(summary)
+}
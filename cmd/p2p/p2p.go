@@ -8,6 +8,7 @@ import (
 	"github.com/maticnetwork/polygon-cli/cmd/p2p/crawl"
 	"github.com/maticnetwork/polygon-cli/cmd/p2p/nodelist"
 	"github.com/maticnetwork/polygon-cli/cmd/p2p/ping"
+	"github.com/maticnetwork/polygon-cli/cmd/p2p/replay"
 	"github.com/maticnetwork/polygon-cli/cmd/p2p/sensor"
 )
 
@@ -24,5 +25,6 @@ func init() {
 	P2pCmd.AddCommand(crawl.CrawlCmd)
 	P2pCmd.AddCommand(nodelist.NodeListCmd)
 	P2pCmd.AddCommand(ping.PingCmd)
+	P2pCmd.AddCommand(replay.ReplayCmd)
 	P2pCmd.AddCommand(sensor.SensorCmd)
 }
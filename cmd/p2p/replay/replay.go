@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/maticnetwork/polygon-cli/p2p"
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+	"github.com/maticnetwork/polygon-cli/util"
+)
+
+type (
+	replayParams struct {
+		CaptureFile                  string
+		ProjectID                    string
+		DatabaseID                   string
+		SensorID                     string
+		MaxDatabaseConcurrency       int
+		ShouldWriteBlocks            bool
+		ShouldWriteBlockEvents       bool
+		ShouldWriteTransactions      bool
+		ShouldWriteTransactionEvents bool
+	}
+)
+
+var (
+	inputReplayParams replayParams
+)
+
+// ReplayCmd represents the replay command. This is responsible for feeding
+// messages captured by the sensor back through the message handlers.
+var ReplayCmd = &cobra.Command{
+	Use:   "replay [capture file]",
+	Short: "Replay raw devp2p messages captured by the sensor through the message handlers.",
+	Long: `Replay a capture file written with the sensor's --capture-file flag.
+
+Every captured message is decoded and handled the same way the sensor would have
+handled it, which is useful for debugging and regression testing protocol
+parsing. By default nothing is written to the database. If a project ID is
+provided, the replayed blocks and transactions will be written to datastore.`,
+	Args: cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		inputReplayParams.CaptureFile = args[0]
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reader, err := p2p.NewCaptureReader(inputReplayParams.CaptureFile)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		db := database.NewNoopDatabase()
+		if len(inputReplayParams.ProjectID) > 0 {
+			db = database.NewDatastore(cmd.Context(), database.DatastoreOptions{
+				ProjectID:                    inputReplayParams.ProjectID,
+				DatabaseID:                   inputReplayParams.DatabaseID,
+				SensorID:                     inputReplayParams.SensorID,
+				MaxConcurrency:               inputReplayParams.MaxDatabaseConcurrency,
+				ShouldWriteBlocks:            inputReplayParams.ShouldWriteBlocks,
+				ShouldWriteBlockEvents:       inputReplayParams.ShouldWriteBlockEvents,
+				ShouldWriteTransactions:      inputReplayParams.ShouldWriteTransactions,
+				ShouldWriteTransactionEvents: inputReplayParams.ShouldWriteTransactionEvents,
+				TxSampleRate:                 1,
+				TxEventSampleRate:            1,
+				TxBodySampleRate:             1,
+			})
+		}
+
+		count := &p2p.MessageCount{}
+		replayed, err := p2p.Replay(p2p.ReplayOptions{
+			Context:  cmd.Context(),
+			Database: db,
+			SensorID: inputReplayParams.SensorID,
+			Count:    count,
+		}, reader)
+		if err != nil {
+			return err
+		}
+
+		counts := count.Load()
+		log.Info().Int("records", replayed).Interface("counts", counts).Msg("Finished replay")
+
+		if counts.Errors > 0 {
+			return util.PartialFailure(fmt.Errorf("%d of %d captured messages failed to be handled", counts.Errors, replayed))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	ReplayCmd.Flags().StringVarP(&inputReplayParams.ProjectID, "project-id", "p", "", "GCP project ID")
+	ReplayCmd.Flags().StringVarP(&inputReplayParams.DatabaseID, "database-id", "d", "", "Datastore database ID")
+	ReplayCmd.Flags().StringVarP(&inputReplayParams.SensorID, "sensor-id", "s", "replay", "Sensor ID when writing block/tx events")
+	ReplayCmd.Flags().IntVarP(&inputReplayParams.MaxDatabaseConcurrency, "max-db-concurrency", "D", 10000, "Maximum number of concurrent database operations to perform")
+	ReplayCmd.Flags().BoolVarP(&inputReplayParams.ShouldWriteBlocks, "write-blocks", "B", true, "Whether to write blocks to the database")
+	ReplayCmd.Flags().BoolVar(&inputReplayParams.ShouldWriteBlockEvents, "write-block-events", true, "Whether to write block events to the database")
+	ReplayCmd.Flags().BoolVarP(&inputReplayParams.ShouldWriteTransactions, "write-txs", "t", true, "Whether to write transactions to the database")
+	ReplayCmd.Flags().BoolVar(&inputReplayParams.ShouldWriteTransactionEvents, "write-tx-events", true, "Whether to write transaction events to the database")
+}
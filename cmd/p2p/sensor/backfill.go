@@ -0,0 +1,249 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/maticnetwork/polygon-cli/p2p"
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+)
+
+type backfillParams struct {
+	Start                   uint64
+	End                     uint64
+	PageSize                uint64
+	GenesisHash             string
+	MaxDatabaseConcurrency  int
+	ShouldWriteTransactions bool
+
+	nodes []*enode.Node
+}
+
+var inputBackfillParams backfillParams
+
+// BackfillCmd represents the sensor backfill command.
+var BackfillCmd = &cobra.Command{
+	Use:   "backfill [nodes file or enode/enr]",
+	Short: "Backfill the block headers and bodies of a range from peers into the sensor database.",
+	Long: `Backfill the block headers and bodies of a range from peers into the sensor
+database, so the stored chain is contiguous even for the blocks announced
+before the sensor started.
+
+The headers are requested in pages with GetBlockHeaders and their bodies with
+GetBlockBodies. The headers have to link to each other by their parent hash and
+the bodies have to match the transaction and uncle roots of their header. When
+a peer fails or returns invalid data, the backfill continues from the next peer
+in the nodes file where it stopped.
+
+Blocks already in the database are written again, which doesn't overwrite what
+was already stored.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if input, err := p2p.ReadNodeSet(args[0]); err == nil {
+			inputBackfillParams.nodes = input
+		} else if node, err := p2p.ParseNode(args[0]); err == nil {
+			inputBackfillParams.nodes = []*enode.Node{node}
+		} else {
+			return err
+		}
+
+		if inputBackfillParams.Start > inputBackfillParams.End {
+			return errors.New("--start can't be after --end")
+		}
+		if inputBackfillParams.PageSize == 0 {
+			return errors.New("--page-size must be positive")
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := database.NewDatastore(cmd.Context(), database.DatastoreOptions{
+			ProjectID:               inputSensorParams.ProjectID,
+			DatabaseID:              inputSensorParams.DatabaseID,
+			MaxConcurrency:          inputBackfillParams.MaxDatabaseConcurrency,
+			ShouldWriteBlocks:       true,
+			ShouldWriteTransactions: inputBackfillParams.ShouldWriteTransactions,
+			TxSampleRate:            1,
+			TxBodySampleRate:        1,
+		})
+
+		b := backfill{
+			db:      db,
+			genesis: common.HexToHash(inputBackfillParams.GenesisHash),
+			next:    inputBackfillParams.Start,
+			end:     inputBackfillParams.End,
+			page:    inputBackfillParams.PageSize,
+		}
+
+		for _, node := range inputBackfillParams.nodes {
+			if b.next > b.end {
+				break
+			}
+			if err := b.from(cmd.Context(), node); err != nil {
+				log.Warn().Err(err).Str("peer", node.URLv4()).Uint64("next", b.next).Msg("Failed to backfill from peer")
+			}
+		}
+
+		if err := db.Flush(cmd.Context()); err != nil {
+			return err
+		}
+
+		if b.next <= b.end {
+			return fmt.Errorf("ran out of peers, backfilled blocks %d to %d", inputBackfillParams.Start, b.next-1)
+		}
+
+		log.Info().Uint64("start", inputBackfillParams.Start).Uint64("end", b.end).Msg("Backfilled blocks")
+		return nil
+	},
+}
+
+// backfill is the state of a backfill, which carries on from peer to peer.
+type backfill struct {
+	db      database.Database
+	genesis common.Hash
+	next    uint64
+	end     uint64
+	page    uint64
+
+	// parent is the hash of the block before next, which the next header has
+	// to link to. It's zero until the first page was fetched.
+	parent common.Hash
+}
+
+// from backfills the blocks from the node until they're all written or the
+// node fails.
+func (b *backfill) from(ctx context.Context, node *enode.Node) error {
+	conn, err := p2p.Dial(node)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, status, err := conn.Peer()
+	if err != nil {
+		return err
+	}
+	if status.Genesis != b.genesis {
+		return fmt.Errorf("peer is on another network with genesis %v", status.Genesis)
+	}
+
+	for b.next <= b.end {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		amount := b.end - b.next + 1
+		if amount > b.page {
+			amount = b.page
+		}
+
+		headers, err := conn.RequestBlockHeaders(b.next, amount)
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return errors.New("peer returned no headers")
+		}
+		if err = b.verifyHeaders(headers); err != nil {
+			return err
+		}
+
+		bodies := make([]*database.Body, 0, len(headers))
+		for len(bodies) < len(headers) {
+			pending := headers[len(bodies):]
+			hashes := make([]common.Hash, 0, len(pending))
+			for _, header := range pending {
+				hashes = append(hashes, header.Hash())
+			}
+
+			page, err := conn.RequestBlockBodies(hashes)
+			if err != nil {
+				return err
+			}
+			if len(page) == 0 {
+				return errors.New("peer returned no bodies")
+			}
+			for i, body := range page {
+				if i >= len(pending) {
+					break
+				}
+				if err = verifyBody(pending[i], body); err != nil {
+					return err
+				}
+				bodies = append(bodies, body)
+			}
+		}
+
+		b.db.WriteBlockHeaders(ctx, headers)
+		for i, body := range bodies {
+			b.db.WriteBlockBody(ctx, body, headers[i].Hash())
+		}
+
+		last := headers[len(headers)-1]
+		b.next = last.Number.Uint64() + 1
+		b.parent = last.Hash()
+		log.Info().Uint64("block", last.Number.Uint64()).Uint64("end", b.end).Msg("Backfilled page")
+	}
+
+	return nil
+}
+
+// verifyHeaders checks that the headers are the next ones and link to each
+// other by their parent hash.
+func (b *backfill) verifyHeaders(headers []*database.Header) error {
+	parent := b.parent
+	for i, header := range headers {
+		if header.Number == nil || header.Number.Uint64() != b.next+uint64(i) {
+			return fmt.Errorf("expected header %d, got %v", b.next+uint64(i), header.Number)
+		}
+		if parent != (common.Hash{}) && header.ParentHash != parent {
+			return fmt.Errorf("header %d doesn't link to its parent %v", header.Number, parent)
+		}
+		parent = header.Hash()
+	}
+	return nil
+}
+
+// verifyBody checks that the body matches the transaction, uncle, and
+// withdrawal roots of its header.
+func verifyBody(header *database.Header, body *database.Body) error {
+	if hash := types.DeriveSha(body.Transactions, trie.NewStackTrie(nil)); hash != header.TxHash {
+		return fmt.Errorf("transactions of block %d don't match its header", header.Number)
+	}
+	if hash := body.UncleHash(); hash != header.UncleHash {
+		return fmt.Errorf("uncles of block %d don't match its header", header.Number)
+	}
+	if header.WithdrawalsHash == nil {
+		if len(body.Withdrawals) > 0 {
+			return fmt.Errorf("block %d has withdrawals before Shanghai", header.Number)
+		}
+		return nil
+	}
+	if hash := types.DeriveSha(body.Withdrawals, trie.NewStackTrie(nil)); hash != *header.WithdrawalsHash {
+		return fmt.Errorf("withdrawals of block %d don't match its header", header.Number)
+	}
+	return nil
+}
+
+func init() {
+	BackfillCmd.Flags().Uint64Var(&inputBackfillParams.Start, "start", 0, "First block number to backfill")
+	BackfillCmd.Flags().Uint64Var(&inputBackfillParams.End, "end", 0, "Last block number to backfill")
+	BackfillCmd.Flags().Uint64Var(&inputBackfillParams.PageSize, "page-size", 128, "Number of headers and bodies requested at once")
+	BackfillCmd.Flags().StringVar(&inputBackfillParams.GenesisHash, "genesis-hash", "0xa9c28ce2141b56c474f1dc504bee9b01eb1bd7d1a507580d5519d4437a97de1b",
+		"The genesis block hash, peers on other networks are skipped")
+	BackfillCmd.Flags().IntVarP(&inputBackfillParams.MaxDatabaseConcurrency, "max-db-concurrency", "D", 100,
+		"Maximum number of concurrent database operations to perform")
+	BackfillCmd.Flags().BoolVarP(&inputBackfillParams.ShouldWriteTransactions, "write-txs", "t", true,
+		"Whether to write the transactions of the blocks to the database")
+	if err := BackfillCmd.MarkFlagRequired("end"); err != nil {
+		log.Error().Err(err).Msg("Failed to mark end as required")
+	}
+}
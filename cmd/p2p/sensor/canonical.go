@@ -0,0 +1,114 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+)
+
+type canonicalParams struct {
+	Window   time.Duration
+	Interval time.Duration
+}
+
+var inputCanonicalParams canonicalParams
+
+// CanonicalCmd represents the sensor canonical command.
+var CanonicalCmd = &cobra.Command{
+	Use:   "canonical",
+	Short: "Mark the stored blocks as canonical or orphaned.",
+	Long: `Mark the stored blocks as canonical or orphaned, so the queries of the sensor
+database don't have to rebuild the chain themselves.
+
+The blocks whose timestamp is within --window are linked by their parent hash,
+and the chain is walked back from the head with the highest total difficulty.
+The total difficulty of a block is the one received with it, or derived from
+its parent's. The blocks on that chain are canonical, and the other blocks at
+the same heights are orphaned. The blocks at heights the chain doesn't reach,
+e.g. before a gap in the stored blocks, are left as they are until the gap is
+backfilled.
+
+The status is written to the Chain property of the blocks. With --interval, the
+chain is rebuilt periodically rather than once, which keeps the status of the
+latest blocks up to date while the sensors are running.`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if inputCanonicalParams.Window <= 0 {
+			return errors.New("--window must be positive")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, ok := database.NewDatastore(cmd.Context(), database.DatastoreOptions{
+			ProjectID:  inputSensorParams.ProjectID,
+			DatabaseID: inputSensorParams.DatabaseID,
+		}).(*database.Datastore)
+		if !ok {
+			return errors.New("the database doesn't support building the canonical chain")
+		}
+
+		if inputCanonicalParams.Interval <= 0 {
+			return buildCanonicalChain(cmd.Context(), db)
+		}
+
+		ticker := time.NewTicker(inputCanonicalParams.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := buildCanonicalChain(cmd.Context(), db); err != nil {
+				log.Error().Err(err).Msg("Failed to build the canonical chain")
+			}
+
+			select {
+			case <-ticker.C:
+			case <-cmd.Context().Done():
+				return nil
+			}
+		}
+	},
+}
+
+// buildCanonicalChain marks the blocks within the window.
+func buildCanonicalChain(ctx context.Context, db *database.Datastore) error {
+	until := time.Now()
+	blocks, err := db.ReadChain(ctx, until.Add(-inputCanonicalParams.Window), until)
+	if err != nil {
+		return err
+	}
+
+	statuses := database.BuildCanonicalChain(blocks)
+
+	canonical := 0
+	for _, status := range statuses {
+		if status == database.ChainCanonical {
+			canonical++
+		}
+	}
+
+	changed, err := db.WriteChainStatus(ctx, statuses)
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Int("blocks", len(blocks)).
+		Int("canonical", canonical).
+		Int("orphaned", len(statuses)-canonical).
+		Int("undecided", len(blocks)-len(statuses)).
+		Int("changed", changed).
+		Msg("Built canonical chain")
+
+	return nil
+}
+
+func init() {
+	CanonicalCmd.Flags().DurationVar(&inputCanonicalParams.Window, "window", 24*time.Hour,
+		"How far back from now the block timestamps are for the blocks to be marked")
+	CanonicalCmd.Flags().DurationVar(&inputCanonicalParams.Interval, "interval", 0,
+		"How often to rebuild the canonical chain (0 builds it once)")
+}
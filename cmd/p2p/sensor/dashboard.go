@@ -0,0 +1,196 @@
+package sensor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+
+	"github.com/maticnetwork/polygon-cli/p2p"
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+)
+
+// dashboardHistory is the number of message rates kept for the sparklines.
+const dashboardHistory = 120
+
+// dashboardSeries is a message type charted on the dashboard.
+type dashboardSeries struct {
+	Title string
+	Count func(p2p.MessageCount) int32
+}
+
+var dashboardSeriesList = []dashboardSeries{
+	{Title: "Blocks", Count: func(c p2p.MessageCount) int32 { return c.Blocks }},
+	{Title: "Block Hashes", Count: func(c p2p.MessageCount) int32 { return c.BlockHashes }},
+	{Title: "Block Headers", Count: func(c p2p.MessageCount) int32 { return c.BlockHeaders }},
+	{Title: "Block Bodies", Count: func(c p2p.MessageCount) int32 { return c.BlockBodies }},
+	{Title: "Transactions", Count: func(c p2p.MessageCount) int32 { return c.Transactions }},
+	{Title: "Transaction Hashes", Count: func(c p2p.MessageCount) int32 { return c.TransactionHashes }},
+	{Title: "Requests", Count: func(c p2p.MessageCount) int32 {
+		return c.BlockHeaderRequests + c.BlockBodiesRequests + c.TransactionRequests
+	}},
+	{Title: "Errors", Count: func(c p2p.MessageCount) int32 { return c.Errors }},
+	{Title: "Disconnects", Count: func(c p2p.MessageCount) int32 { return c.Disconnects }},
+}
+
+// dashboardState is what the dashboard shows besides the message rates.
+type dashboardState struct {
+	Peers     []*ethp2p.Peer
+	MaxPeers  int
+	Head      p2p.HeadBlock
+	Bandwidth []database.PeerBandwidth
+
+	// PendingWrites is the number of database writes in flight, and Backlog
+	// the number of journal segments waiting to be replayed, or -1 without a
+	// journal.
+	PendingWrites int
+	Backlog       int
+}
+
+// dashboard is the terminal UI of the sensor, showing the connected peers,
+// the message rates, the head block, and the database write lag.
+type dashboard struct {
+	grid       *ui.Grid
+	summary    *widgets.Paragraph
+	sparklines []*widgets.Sparkline
+	peers      *widgets.Table
+	rates      [][]float64
+}
+
+func newDashboard() *dashboard {
+	d := &dashboard{
+		grid:    ui.NewGrid(),
+		summary: widgets.NewParagraph(),
+		peers:   widgets.NewTable(),
+		rates:   make([][]float64, len(dashboardSeriesList)),
+	}
+
+	d.summary.Title = "Sensor"
+
+	d.peers.Title = "Peers"
+	d.peers.TextAlignment = ui.AlignLeft
+	d.peers.RowSeparator = false
+
+	left := widgets.NewSparklineGroup()
+	right := widgets.NewSparklineGroup()
+	left.Title = "Messages/s"
+	right.Title = "Messages/s"
+	for i, series := range dashboardSeriesList {
+		sl := widgets.NewSparkline()
+		sl.Title = series.Title
+		sl.LineColor = ui.ColorGreen
+		d.sparklines = append(d.sparklines, sl)
+		if i < (len(dashboardSeriesList)+1)/2 {
+			left.Sparklines = append(left.Sparklines, sl)
+		} else {
+			right.Sparklines = append(right.Sparklines, sl)
+		}
+	}
+	d.sparklines[len(d.sparklines)-2].LineColor = ui.ColorRed
+	d.sparklines[len(d.sparklines)-1].LineColor = ui.ColorRed
+
+	d.grid.Set(
+		ui.NewRow(2.0/10, d.summary),
+		ui.NewRow(4.0/10,
+			ui.NewCol(5.0/10, left),
+			ui.NewCol(5.0/10, right),
+		),
+		ui.NewRow(4.0/10, d.peers),
+	)
+
+	termWidth, termHeight := ui.TerminalDimensions()
+	d.grid.SetRect(0, 0, termWidth, termHeight)
+
+	return d
+}
+
+// record adds the message counts of the last interval to the rates.
+func (d *dashboard) record(count p2p.MessageCount, interval time.Duration) {
+	for i, series := range dashboardSeriesList {
+		rate := float64(series.Count(count)) / interval.Seconds()
+		d.rates[i] = append(d.rates[i], rate)
+		if len(d.rates[i]) > dashboardHistory {
+			d.rates[i] = d.rates[i][len(d.rates[i])-dashboardHistory:]
+		}
+	}
+}
+
+func (d *dashboard) resize(width, height int) {
+	d.grid.SetRect(0, 0, width, height)
+	ui.Clear()
+}
+
+func (d *dashboard) render(state dashboardState) {
+	lag := fmt.Sprintf("%d writes in flight", state.PendingWrites)
+	if state.Backlog >= 0 {
+		lag += fmt.Sprintf(", %d journal segments waiting", state.Backlog)
+	}
+	d.summary.Text = fmt.Sprintf("Head: %d %s    TD: %v\nPeers: %d/%d    Database: %s    Time: %s\nPress <q> to stop the sensor",
+		state.Head.Number, state.Head.Hash.TerminalString(), state.Head.TotalDifficulty,
+		len(state.Peers), state.MaxPeers, lag, time.Now().Format("15:04:05"))
+
+	for i, sl := range d.sparklines {
+		sl.Data = d.rates[i]
+		latest := 0.0
+		if len(d.rates[i]) > 0 {
+			latest = d.rates[i][len(d.rates[i])-1]
+		}
+		sl.Title = fmt.Sprintf("%s %.1f", dashboardSeriesList[i].Title, latest)
+	}
+
+	d.renderPeers(state)
+
+	ui.Render(d.grid)
+}
+
+// stopping shows that the sensor is draining, which can take up to the
+// timeout.
+func (d *dashboard) stopping(timeout time.Duration) {
+	d.summary.Text = fmt.Sprintf("Stopping sensor, waiting up to %s for the peers to disconnect and the writes to be flushed...", timeout)
+	ui.Render(d.summary)
+}
+
+func (d *dashboard) renderPeers(state dashboardState) {
+	usages := make(map[enode.ID]database.PeerBandwidth, len(state.Bandwidth))
+	for _, usage := range state.Bandwidth {
+		usages[usage.Node.ID()] = usage
+	}
+
+	peers := make([]*ethp2p.Peer, len(state.Peers))
+	copy(peers, state.Peers)
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Fullname() < peers[j].Fullname()
+	})
+
+	rows := [][]string{{"ID", "Client", "Address", "Direction", "Connected", "In (KiB)", "Out (KiB)"}}
+	for _, peer := range peers {
+		direction := "outbound"
+		if peer.Inbound() {
+			direction = "inbound"
+		}
+
+		connected, in, out := "", "", ""
+		if usage, ok := usages[peer.ID()]; ok {
+			connected = time.Since(usage.Connected).Truncate(time.Second).String()
+			in = fmt.Sprintf("%.1f", float64(usage.BytesIn)/1024)
+			out = fmt.Sprintf("%.1f", float64(usage.BytesOut)/1024)
+		}
+
+		rows = append(rows, []string{
+			peer.ID().TerminalString(),
+			peer.Fullname(),
+			peer.RemoteAddr().String(),
+			direction,
+			connected,
+			in,
+			out,
+		})
+	}
+
+	d.peers.Rows = rows
+	d.peers.Title = fmt.Sprintf("Peers (%d)", len(peers))
+}
@@ -1,12 +1,15 @@
 package sensor
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -15,18 +18,23 @@ import (
 	_ "net/http/pprof"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/crypto"
 	ethp2p "github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/nat"
-	"github.com/ethereum/go-ethereum/rpc"
+	ui "github.com/gizak/termui/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
 	"github.com/maticnetwork/polygon-cli/p2p"
 	"github.com/maticnetwork/polygon-cli/p2p/database"
+	"github.com/maticnetwork/polygon-cli/p2p/geoip"
 	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/maticnetwork/polygon-cli/util"
 )
 
 type (
@@ -44,6 +52,10 @@ type (
 		ShouldWriteBlockEvents       bool
 		ShouldWriteTransactions      bool
 		ShouldWriteTransactionEvents bool
+		ShouldWritePeers             bool
+		TxSampleRate                 float64
+		TxEventSampleRate            float64
+		TxBodySampleRate             float64
 		ShouldRunPprof               bool
 		PprofPort                    uint
 		KeyFile                      string
@@ -55,13 +67,47 @@ type (
 		DialRatio                    int
 		NAT                          string
 		QuickStart                   bool
+		ShardIndex                   uint64
+		ShardCount                   uint64
+		GeoIPCityFile                string
+		GeoIPASNFile                 string
+		NodesFormat                  string
+		CaptureFile                  string
+		Network                      string
+		RotationInterval             time.Duration
+		RotationFraction             float64
+		RotationMinAge               time.Duration
+		DroppedTxTimeout             time.Duration
+		DroppedTxMinPeers            int
+		DroppedTxRPC                 string
+		MetricsAddr                  string
+		BandwidthInterval            time.Duration
+		StatusForkID                 string
+		StatusForkIDNumber           uint64
+		StatusHead                   string
+		StatusTD                     string
+		JournalDir                   string
+		JournalSegment               time.Duration
+		DrainTimeout                 time.Duration
+		AdminAddr                    string
+		SettingsFile                 string
+		Dashboard                    bool
+		DashboardLog                 string
+		AlertsFile                   string
 
 		bootnodes    []*enode.Node
 		nodes        []*enode.Node
 		trustedNodes []*enode.Node
 		privateKey   *ecdsa.PrivateKey
 		genesis      core.Genesis
+		forks        p2p.Forks
 		nat          nat.Interface
+		shard        p2p.Shard
+		geoip        *geoip.Reader
+		nodesFormat  p2p.NodeSetFormat
+		capture      *p2p.CaptureWriter
+		status       *p2p.StatusOverride
+		alerts       *p2p.Alerts
 	}
 )
 
@@ -74,8 +120,83 @@ var (
 var SensorCmd = &cobra.Command{
 	Use:   "sensor [nodes file]",
 	Short: "Start a devp2p sensor that discovers other peers and will receive blocks and transactions.",
-	Long:  "If no nodes.json file exists, it will be created.",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `If no nodes.json file exists, it will be created.
+
+Use --network to join one of the bundled networks without providing a genesis
+file, genesis hash, and bootnodes. Only the fork block numbers and timestamps
+of the genesis config are bundled. The zkEVM networks, zkevm-mainnet and
+cardona, don't bundle a genesis hash or bootnodes: the genesis hash is read from
+their RPC unless --genesis-hash is set, and the bootnodes have to be set with
+--bootnodes.
+
+Otherwise, when --rpc is set without --genesis, the network ID, genesis hash,
+and fork ID are derived from the RPC (net_version or eth_chainId, block 0, and
+eth_config when it's available).
+
+With --journal-dir, the writes are appended to a local journal and replayed
+into the database in segments, retrying while the database is unavailable. The
+segments left by a crash are replayed on the next start, so some events can be
+written twice.
+
+On SIGINT or SIGTERM, the peers are disconnected, the pending database writes
+are flushed, and the nodes file is written before exiting, for at most
+--drain-timeout. A second signal exits right away.
+
+Some settings can be changed without restarting the sensor, which would drop
+every peer. They're reloaded from --settings-file on SIGHUP, or read and changed
+with GET and POST requests on the /settings path of --admin-addr. Only the
+settings present are changed:
+
+    {
+      "logLevel": "debug",
+      "maxPeers": 100,
+      "writeBlocks": true,
+      "writeBlockEvents": true,
+      "writeTxs": true,
+      "writeTxEvents": true,
+      "writePeers": true,
+      "txSampleRate": 0.5,
+      "txEventSampleRate": 1,
+      "txBodySampleRate": 0.1
+    }
+
+The max peers can't exceed --max-peers, and lowering it doesn't disconnect any
+peer, it only rejects new ones until enough peers have left.
+
+With --dashboard, a terminal dashboard shows the connected peers, the message
+rates, the head block, and the database write lag, and the logs are written to
+--dashboard-log instead.
+
+With --alerts-file, the rules of the YAML file are evaluated against what the
+sensor observes, and the alerts are posted to Slack or PagerDuty compatible
+webhooks. A rule fires at most once per cooldown:
+
+    webhook: https://hooks.slack.com/services/...
+    cooldown: 10m
+    rules:
+      - name: stalled
+        kind: no_new_blocks
+        duration: 60s
+      - name: reorg
+        kind: reorg_depth
+        depth: 3
+      - name: peers
+        kind: peer_count_below
+        threshold: 10
+        duration: 5m
+      - name: bridge
+        kind: address_in_tx
+        addresses: [0x2a3dd3eb832af982ec71669e178424b10dca2ede]
+        webhook: https://events.pagerduty.com/...
+
+Reorgs are measured from the blocks received in the last 256 blocks, and the
+addresses match both the sender and the recipient of the gossiped transactions.
+
+The withdrawals root, blob gas used, excess blob gas, and parent beacon block
+root of Shanghai and Cancun headers are stored with the blocks, and so are the
+withdrawals of their bodies. Blob transactions are referenced by hash from their
+blocks, but they aren't decoded or stored.`,
+	Args: cobra.MinimumNArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
 		inputSensorParams.NodesFile = args[0]
 		inputSensorParams.nodes, err = p2p.ReadNodeSet(inputSensorParams.NodesFile)
@@ -90,6 +211,23 @@ var SensorCmd = &cobra.Command{
 			}
 		}
 
+		var network *p2p.Network
+		if len(inputSensorParams.Network) > 0 {
+			network, err = p2p.LoadNetwork(inputSensorParams.Network)
+		} else if util.Changed(cmd.Flags(), "rpc") && !util.Changed(cmd.Flags(), "genesis") {
+			// Without a bundled network or a genesis file, everything the status
+			// exchange needs is derived from the RPC instead.
+			network, err = p2p.FetchNetwork(cmd.Context(), inputSensorParams.RPC)
+		}
+		if err != nil {
+			return err
+		}
+		if network != nil {
+			if err = applyNetwork(cmd, network); err != nil {
+				return err
+			}
+		}
+
 		if len(inputSensorParams.Bootnodes) > 0 {
 			inputSensorParams.bootnodes, err = p2p.ParseBootnodes(inputSensorParams.Bootnodes)
 			if err != nil {
@@ -101,6 +239,19 @@ var SensorCmd = &cobra.Command{
 			return errors.New("network ID must be greater than zero")
 		}
 
+		inputSensorParams.nodesFormat, err = p2p.ParseNodeSetFormat(inputSensorParams.NodesFormat)
+		if err != nil {
+			return err
+		}
+
+		inputSensorParams.shard = p2p.Shard{
+			Index: inputSensorParams.ShardIndex,
+			Count: inputSensorParams.ShardCount,
+		}
+		if err = inputSensorParams.shard.Validate(); err != nil {
+			return err
+		}
+
 		if inputSensorParams.ShouldRunPprof {
 			go func() {
 				if pprofErr := http.ListenAndServe(fmt.Sprintf("localhost:%v", inputSensorParams.PprofPort), nil); pprofErr != nil {
@@ -130,10 +281,15 @@ var SensorCmd = &cobra.Command{
 			}
 		}
 
-		inputSensorParams.genesis, err = loadGenesis(inputSensorParams.GenesisFile)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to load genesis file")
-			return err
+		if network != nil && !util.Changed(cmd.Flags(), "genesis") {
+			inputSensorParams.genesis = network.Genesis()
+			inputSensorParams.forks = network.Forks
+		} else {
+			inputSensorParams.genesis, inputSensorParams.forks, err = loadGenesis(inputSensorParams.GenesisFile)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to load genesis file")
+				return err
+			}
 		}
 
 		inputSensorParams.nat, err = nat.Parse(inputSensorParams.NAT)
@@ -142,9 +298,62 @@ var SensorCmd = &cobra.Command{
 			return err
 		}
 
+		if inputSensorParams.RotationFraction < 0 || inputSensorParams.RotationFraction > 1 {
+			return errors.New("rotation fraction must be between 0 and 1")
+		}
+
+		inputSensorParams.status, err = parseStatusOverride(cmd)
+		if err != nil {
+			return err
+		}
+		if network != nil && network.ForkID != nil && inputSensorParams.status.ForkID == nil && inputSensorParams.status.ForkIDNumber == nil {
+			inputSensorParams.status.ForkID = network.ForkID
+		}
+
+		if inputSensorParams.DroppedTxTimeout > 0 && !inputSensorParams.ShouldWriteTransactionEvents {
+			return errors.New("dropped transaction detection requires --write-tx-events")
+		}
+
+		for _, rate := range []float64{inputSensorParams.TxSampleRate, inputSensorParams.TxEventSampleRate, inputSensorParams.TxBodySampleRate} {
+			if rate < 0 || rate > 1 {
+				return errors.New("transaction sample rates must be between 0 and 1")
+			}
+		}
+
+		if len(inputSensorParams.JournalDir) > 0 && inputSensorParams.JournalSegment <= 0 {
+			return errors.New("--journal-segment must be positive")
+		}
+
+		if len(inputSensorParams.GeoIPCityFile) > 0 || len(inputSensorParams.GeoIPASNFile) > 0 {
+			inputSensorParams.geoip, err = geoip.Open(inputSensorParams.GeoIPCityFile, inputSensorParams.GeoIPASNFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(inputSensorParams.AlertsFile) > 0 {
+			config, err := p2p.LoadAlertConfig(inputSensorParams.AlertsFile)
+			if err != nil {
+				return fmt.Errorf("unable to load alert rules: %w", err)
+			}
+			inputSensorParams.alerts = p2p.NewAlerts(config)
+			log.Info().Str("rules", config.String()).Msg("Loaded alert rules")
+		}
+
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// The logs would draw over the dashboard, so they're written to a file
+		// instead.
+		if inputSensorParams.Dashboard {
+			logFile, err := os.OpenFile(inputSensorParams.DashboardLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+			log.Logger = log.Output(logFile)
+		}
+
 		db := database.NewDatastore(cmd.Context(), database.DatastoreOptions{
 			ProjectID:                    inputSensorParams.ProjectID,
 			DatabaseID:                   inputSensorParams.DatabaseID,
@@ -154,8 +363,49 @@ var SensorCmd = &cobra.Command{
 			ShouldWriteBlockEvents:       inputSensorParams.ShouldWriteBlockEvents,
 			ShouldWriteTransactions:      inputSensorParams.ShouldWriteTransactions,
 			ShouldWriteTransactionEvents: inputSensorParams.ShouldWriteTransactionEvents,
+			ShouldWritePeers:             inputSensorParams.ShouldWritePeers,
+			TxSampleRate:                 inputSensorParams.TxSampleRate,
+			TxEventSampleRate:            inputSensorParams.TxEventSampleRate,
+			TxBodySampleRate:             inputSensorParams.TxBodySampleRate,
 		})
 
+		// The write options are changed on the datastore itself, since the
+		// journal only filters the writes with them.
+		datastore, _ := db.(*database.Datastore)
+		limit := p2p.NewPeerLimit(inputSensorParams.MaxPeers)
+		reconfig := &settings{db: datastore, limit: limit, maxPeers: inputSensorParams.MaxPeers}
+
+		var journal *database.Journal
+		if len(inputSensorParams.JournalDir) > 0 {
+			var err error
+			journal, err = database.NewJournal(cmd.Context(), db, database.JournalOptions{
+				Dir:             inputSensorParams.JournalDir,
+				SegmentDuration: inputSensorParams.JournalSegment,
+			})
+			if err != nil {
+				return err
+			}
+			defer journal.Close()
+			db = journal
+		}
+
+		if inputSensorParams.geoip != nil {
+			defer inputSensorParams.geoip.Close()
+		}
+
+		if len(inputSensorParams.CaptureFile) > 0 {
+			capture, err := p2p.NewCaptureWriter(inputSensorParams.CaptureFile)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := capture.Close(); err != nil {
+					log.Error().Err(err).Msg("Failed to close capture file")
+				}
+			}()
+			inputSensorParams.capture = capture
+		}
+
 		// Fetch the latest block which will be used later when crafting the status
 		// message. This call will only be made once and stored in the head field
 		// until the sensor receives a new block it can overwrite it with.
@@ -167,6 +417,67 @@ var SensorCmd = &cobra.Command{
 			Hash:            block.Hash.ToHash(),
 			TotalDifficulty: block.TotalDifficulty.ToBigInt(),
 			Number:          block.Number.ToUint64(),
+			Time:            block.Timestamp.ToUint64(),
+		}
+
+		if td := inputSensorParams.status.TD; td != nil && td.Cmp(head.TotalDifficulty) > 0 {
+			return fmt.Errorf("status TD %v can't exceed the head TD %v", td, head.TotalDifficulty)
+		}
+
+		var rotation *p2p.Rotation
+		if inputSensorParams.RotationInterval > 0 {
+			rotation = p2p.NewRotation(p2p.RotationOptions{
+				MinAge:   inputSensorParams.RotationMinAge,
+				Fraction: inputSensorParams.RotationFraction,
+				GeoIP:    inputSensorParams.geoip,
+			})
+		}
+
+		var dropped *p2p.DroppedTxs
+		if inputSensorParams.DroppedTxTimeout > 0 {
+			dropped = p2p.NewDroppedTxs(p2p.DroppedTxOptions{
+				Timeout:  inputSensorParams.DroppedTxTimeout,
+				MinPeers: inputSensorParams.DroppedTxMinPeers,
+				RPC:      inputSensorParams.DroppedTxRPC,
+			})
+		}
+
+		var registry *prometheus.Registry
+		if len(inputSensorParams.MetricsAddr) > 0 {
+			registry = prometheus.NewRegistry()
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			server := &http.Server{Addr: inputSensorParams.MetricsAddr, Handler: mux}
+			go func() {
+				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Error().Err(err).Msg("Failed to start the metrics server")
+				}
+			}()
+			defer server.Close()
+			log.Info().Str("addr", inputSensorParams.MetricsAddr).Msg("Serving Prometheus metrics")
+		}
+
+		if len(inputSensorParams.AdminAddr) > 0 {
+			mux := http.NewServeMux()
+			mux.Handle("/settings", reconfig)
+			server := &http.Server{Addr: inputSensorParams.AdminAddr, Handler: mux}
+			go func() {
+				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Error().Err(err).Msg("Failed to start the admin server")
+				}
+			}()
+			defer server.Close()
+			log.Info().Str("addr", inputSensorParams.AdminAddr).Msg("Serving admin endpoint")
+		}
+
+		// The registry is only passed when it's set, since a nil registry in the
+		// Registerer interface isn't nil.
+		var bandwidth *p2p.Bandwidth
+		if registry != nil {
+			bandwidth = p2p.NewBandwidth(registry)
+		} else if inputSensorParams.ShouldWritePeers {
+			bandwidth = p2p.NewBandwidth(nil)
 		}
 
 		opts := p2p.Eth66ProtocolOptions{
@@ -174,6 +485,7 @@ var SensorCmd = &cobra.Command{
 			Database:    db,
 			Genesis:     &inputSensorParams.genesis,
 			GenesisHash: common.HexToHash(inputSensorParams.GenesisHash),
+			Forks:       inputSensorParams.forks,
 			RPC:         inputSensorParams.RPC,
 			SensorID:    inputSensorParams.SensorID,
 			NetworkID:   inputSensorParams.NetworkID,
@@ -181,6 +493,16 @@ var SensorCmd = &cobra.Command{
 			Head:        &head,
 			HeadMutex:   &sync.RWMutex{},
 			Count:       &p2p.MessageCount{},
+			Shard:       inputSensorParams.shard,
+			GeoIP:       inputSensorParams.geoip,
+			Capture:     inputSensorParams.capture,
+			Rotation:    rotation,
+			DroppedTxs:  dropped,
+			Bandwidth:   bandwidth,
+			PeerLimit:   limit,
+			Alerts:      inputSensorParams.alerts,
+
+			StatusOverride: inputSensorParams.status,
 		}
 
 		config := ethp2p.Config{
@@ -196,12 +518,23 @@ var SensorCmd = &cobra.Command{
 		}
 
 		if inputSensorParams.QuickStart {
-			config.StaticNodes = inputSensorParams.nodes
+			config.StaticNodes = inputSensorParams.shard.Filter(inputSensorParams.nodes)
+		}
+
+		// When sharding, only dial nodes that fall into this sensor's portion of
+		// the key space so the other sensors can handle the rest.
+		if inputSensorParams.shard.Count > 1 {
+			config.Dialer = p2p.NewShardDialer(inputSensorParams.shard)
+		} else if util.HasProxy() {
+			config.Dialer = p2p.NewProxyDialer()
 		}
 
 		server := ethp2p.Server{Config: config}
 
-		log.Info().Str("enode", server.Self().URLv4()).Msg("Starting sensor")
+		log.Info().
+			Str("enode", server.Self().URLv4()).
+			Stringer("shard", inputSensorParams.shard).
+			Msg("Starting sensor")
 
 		// Starting the server isn't actually a blocking call so the sensor needs to
 		// have something that waits for it. This is implemented by the for {} loop
@@ -211,17 +544,63 @@ var SensorCmd = &cobra.Command{
 		}
 		defer server.Stop()
 
-		ticker := time.NewTicker(2 * time.Second)
+		const logInterval = 2 * time.Second
+		ticker := time.NewTicker(logInterval)
 		defer ticker.Stop()
 
+		var dash *dashboard
+		var uiEvents <-chan ui.Event
+		if inputSensorParams.Dashboard {
+			if err := ui.Init(); err != nil {
+				return err
+			}
+			defer ui.Close()
+			dash = newDashboard()
+			uiEvents = ui.PollEvents()
+		}
+
+		var rotationCh <-chan time.Time
+		if rotation != nil {
+			rotationTicker := time.NewTicker(inputSensorParams.RotationInterval)
+			defer rotationTicker.Stop()
+			rotationCh = rotationTicker.C
+		}
+		var droppedCh <-chan time.Time
+		if dropped != nil {
+			// Sweep often enough that the transactions are flagged shortly after
+			// the timeout, without walking the pending set more than once a minute.
+			interval := inputSensorParams.DroppedTxTimeout
+			if interval > time.Minute {
+				interval = time.Minute
+			}
+			droppedTicker := time.NewTicker(interval)
+			defer droppedTicker.Stop()
+			droppedCh = droppedTicker.C
+		}
+
+		var bandwidthCh <-chan time.Time
+		if bandwidth != nil && inputSensorParams.BandwidthInterval > 0 {
+			bandwidthTicker := time.NewTicker(inputSensorParams.BandwidthInterval)
+			defer bandwidthTicker.Stop()
+			bandwidthCh = bandwidthTicker.C
+		}
+
+		// dialed are the nodes added as static peers by the rotation. The ones
+		// that didn't connect are removed on the next rotation, so they aren't
+		// redialed forever.
+		dialed := make(p2p.NodeSet)
+
 		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
+		reloads := make(chan os.Signal, 1)
+		signal.Notify(reloads, syscall.SIGHUP)
+
 		peers := make(p2p.NodeSet)
 		for _, node := range inputSensorParams.nodes {
 			// Because the node URLs can change, map them to the node ID to prevent
 			// duplicates.
-			peers[node.ID()] = node.URLv4()
+			peers[node.ID()] = node
 		}
 
 		for {
@@ -230,42 +609,277 @@ var SensorCmd = &cobra.Command{
 				count := opts.Count.Load()
 				opts.Count.Clear()
 				log.Info().Interface("peers", server.PeerCount()).Interface("counts", count).Send()
+				inputSensorParams.alerts.Check(cmd.Context(), server.PeerCount())
+
+				if dash != nil {
+					dash.record(count, logInterval)
+					dash.render(dashboardState{
+						Peers:         server.Peers(),
+						MaxPeers:      limit.Limit(),
+						Head:          currentHead(&opts),
+						Bandwidth:     bandwidth.Snapshot(),
+						PendingWrites: pendingWrites(datastore),
+						Backlog:       journalBacklog(journal),
+					})
+				}
+			case e := <-uiEvents:
+				switch e.ID {
+				case "q", "<C-c>":
+					dash.stopping(inputSensorParams.DrainTimeout)
+					log.Info().Dur("timeout", inputSensorParams.DrainTimeout).Msg("Stopping sensor...")
+					drain(&server, db, peers, opts.Peers, signals)
+					return nil
+				case "<Resize>":
+					payload := e.Payload.(ui.Resize)
+					dash.resize(payload.Width, payload.Height)
+				}
 			case peer := <-opts.Peers:
 				// Update the peer list and the nodes file.
 				if _, ok := peers[peer.ID()]; !ok {
-					peers[peer.ID()] = peer.URLv4()
+					peers[peer.ID()] = peer
 
-					if err := p2p.WriteNodeSet(inputSensorParams.NodesFile, peers); err != nil {
+					if err := p2p.WriteNodeSet(inputSensorParams.NodesFile, peers, inputSensorParams.nodesFormat); err != nil {
 						log.Error().Err(err).Msg("Failed to write nodes to file")
 					}
 				}
+			case <-rotationCh:
+				known := make([]*enode.Node, 0, len(peers))
+				for _, node := range peers {
+					known = append(known, node)
+				}
+				drop, dial := rotation.Rotate(known)
+				connected := make(map[enode.ID]struct{})
+				for _, peer := range server.Peers() {
+					connected[peer.ID()] = struct{}{}
+				}
+				for id, node := range dialed {
+					if _, ok := connected[id]; !ok {
+						drop = append(drop, node)
+						delete(dialed, id)
+					}
+				}
+				// Removing a peer blocks until it has disconnected, so it's done in the
+				// background to keep handling new peers.
+				go func() {
+					for _, node := range drop {
+						server.RemovePeer(node)
+					}
+				}()
+				for _, node := range dial {
+					dialed[node.ID()] = node
+					server.AddPeer(node)
+				}
+				log.Info().Int("dropped", len(drop)).Int("dialed", len(dial)).Msg("Rotated peers")
+			case <-droppedCh:
+				// Checking against the reference RPC can take a while, so it's done in
+				// the background like removing the rotated peers.
+				go func() {
+					txs := dropped.Sweep(cmd.Context())
+					db.WriteDroppedTransactions(cmd.Context(), txs)
+					if len(txs) > 0 {
+						log.Info().Int("dropped", len(txs)).Msg("Flagged dropped transactions")
+					}
+				}()
+			case <-bandwidthCh:
+				db.WritePeerBandwidth(cmd.Context(), bandwidth.Snapshot())
+			case <-reloads:
+				if len(inputSensorParams.SettingsFile) == 0 {
+					log.Warn().Msg("Ignoring SIGHUP since --settings-file isn't set")
+					continue
+				}
+				if err := reconfig.reload(inputSensorParams.SettingsFile); err != nil {
+					log.Error().Err(err).Msg("Failed to reload the runtime settings")
+					continue
+				}
+				log.Info().Interface("settings", reconfig.current()).Msg("Reloaded runtime settings")
 			case <-signals:
-				// This gracefully stops the sensor so that the peers can be written to
-				// the nodes file.
-				log.Info().Msg("Stopping sensor...")
+				// This gracefully stops the sensor so that the pending writes are
+				// flushed and the peers can be written to the nodes file.
+				log.Info().Dur("timeout", inputSensorParams.DrainTimeout).Msg("Stopping sensor...")
+				drain(&server, db, peers, opts.Peers, signals)
 				return nil
 			}
 		}
 	},
 }
 
-// loadGenesis unmarshals the genesis file into the core.Genesis struct.
-func loadGenesis(genesisFile string) (core.Genesis, error) {
+// drain stops the sensor within the drain timeout. The server disconnects the
+// peers with the quitting reason, which stops the message handlers, then the
+// pending database writes are flushed and the nodes file is written one last
+// time. Another signal skips what's left of the drain.
+func drain(server *ethp2p.Server, db database.Database, peers p2p.NodeSet, peersCh <-chan *enode.Node, signals <-chan os.Signal) {
+	ctx, cancel := context.WithTimeout(context.Background(), inputSensorParams.DrainTimeout)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-signals:
+			log.Warn().Msg("Stopping sensor without draining")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		server.Stop()
+		close(stopped)
+	}()
+
+	// The message handlers block on sending their node to the peers channel, so
+	// it's still received from while the server waits for them to return.
+	for waiting := true; waiting; {
+		select {
+		case peer := <-peersCh:
+			peers[peer.ID()] = peer
+		case <-stopped:
+			waiting = false
+		case <-ctx.Done():
+			log.Warn().Msg("Timed out waiting for the peers to disconnect")
+			waiting = false
+		}
+	}
+
+	if err := db.Flush(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to flush the pending database writes")
+	}
+
+	if err := p2p.WriteNodeSet(inputSensorParams.NodesFile, peers, inputSensorParams.nodesFormat); err != nil {
+		log.Error().Err(err).Msg("Failed to write nodes to file")
+	}
+
+	log.Info().Int("nodes", len(peers)).Msg("Stopped sensor")
+}
+
+// currentHead returns a copy of the head block the sensor advertises.
+func currentHead(opts *p2p.Eth66ProtocolOptions) p2p.HeadBlock {
+	opts.HeadMutex.RLock()
+	defer opts.HeadMutex.RUnlock()
+	return *opts.Head
+}
+
+// pendingWrites returns the writes in flight to the datastore, which is nil
+// if another database is used.
+func pendingWrites(d *database.Datastore) int {
+	if d == nil {
+		return 0
+	}
+	return d.PendingWrites()
+}
+
+// journalBacklog returns the journal segments waiting to be replayed, or -1
+// if the journal is disabled.
+func journalBacklog(j *database.Journal) int {
+	if j == nil {
+		return -1
+	}
+	return j.Backlog()
+}
+
+// applyNetwork uses the bundled or fetched network for the network ID, genesis
+// hash, bootnodes, and RPC, unless they were set with flags.
+func applyNetwork(cmd *cobra.Command, network *p2p.Network) (err error) {
+	flags := cmd.Flags()
+	if !util.Changed(flags, "network-id") {
+		inputSensorParams.NetworkID = network.NetworkID
+	}
+	if !util.Changed(flags, "rpc") {
+		inputSensorParams.RPC = network.RPC
+	}
+	if !util.Changed(flags, "genesis-hash") {
+		genesisHash := network.GenesisHash
+		if genesisHash == nil {
+			// The zkEVM networks don't have a bundled genesis hash, so it's
+			// read from their RPC.
+			hash, err := p2p.FetchGenesisHash(cmd.Context(), inputSensorParams.RPC)
+			if err != nil {
+				return fmt.Errorf("network %s doesn't have a bundled genesis hash, set it with --genesis-hash: %w", network.Name, err)
+			}
+			genesisHash = &hash
+		}
+		inputSensorParams.GenesisHash = genesisHash.Hex()
+	}
+	if !util.Changed(flags, "bootnodes") {
+		inputSensorParams.bootnodes, err = network.BootstrapNodes()
+		if err != nil {
+			return err
+		}
+		if len(inputSensorParams.bootnodes) == 0 {
+			log.Warn().Str("network", network.Name).Msg("The network doesn't have bundled bootnodes, set them with --bootnodes or list peers in the nodes file")
+		}
+	}
+
+	log.Info().Str("network", network.Name).Uint64("network-id", inputSensorParams.NetworkID).Msg("Using network profile")
+	return nil
+}
+
+// parseStatusOverride parses the status flags into the fields the sensor
+// replaces in its status message.
+func parseStatusOverride(cmd *cobra.Command) (*p2p.StatusOverride, error) {
+	status := &p2p.StatusOverride{}
+	flags := cmd.Flags()
+
+	if len(inputSensorParams.StatusForkID) > 0 {
+		id, err := p2p.ParseForkID(inputSensorParams.StatusForkID)
+		if err != nil {
+			return nil, err
+		}
+		status.ForkID = &id
+	}
+
+	if util.Changed(flags, "status-fork-id-number") {
+		if status.ForkID != nil {
+			return nil, errors.New("--status-fork-id and --status-fork-id-number can't both be set")
+		}
+		status.ForkIDNumber = &inputSensorParams.StatusForkIDNumber
+	}
+
+	if len(inputSensorParams.StatusHead) > 0 {
+		b, err := hexutil.Decode(inputSensorParams.StatusHead)
+		if err != nil || len(b) != common.HashLength {
+			return nil, fmt.Errorf("invalid status head %q", inputSensorParams.StatusHead)
+		}
+		head := common.BytesToHash(b)
+		status.Head = &head
+	}
+
+	if len(inputSensorParams.StatusTD) > 0 {
+		td, ok := new(big.Int).SetString(inputSensorParams.StatusTD, 0)
+		if !ok || td.Sign() < 0 {
+			return nil, fmt.Errorf("invalid status TD %q", inputSensorParams.StatusTD)
+		}
+		status.TD = td
+	}
+
+	if !status.IsEmpty() {
+		log.Warn().Interface("status", status).Msg("Overriding the status sent to peers")
+	}
+
+	return status, nil
+}
+
+// loadGenesis unmarshals the genesis file into the core.Genesis struct, along
+// with the time based forks of its config.
+func loadGenesis(genesisFile string) (core.Genesis, p2p.Forks, error) {
 	chainConfig, err := os.ReadFile(genesisFile)
 
 	if err != nil {
-		return core.Genesis{}, err
+		return core.Genesis{}, p2p.Forks{}, err
 	}
 	var gen core.Genesis
 	if err := json.Unmarshal(chainConfig, &gen); err != nil {
-		return core.Genesis{}, err
+		return core.Genesis{}, p2p.Forks{}, err
 	}
-	return gen, nil
+	forks, err := p2p.ReadForks(chainConfig)
+	if err != nil {
+		return core.Genesis{}, p2p.Forks{}, err
+	}
+	return gen, forks, nil
 }
 
 // getLatestBlock will get the latest block from an RPC provider.
 func getLatestBlock(url string) (*rpctypes.RawBlockResponse, error) {
-	client, err := rpc.Dial(url)
+	client, err := util.DialRPC(context.Background(), url)
 	if err != nil {
 		return nil, err
 	}
@@ -281,11 +895,16 @@ func getLatestBlock(url string) (*rpctypes.RawBlockResponse, error) {
 }
 
 func init() {
+	SensorCmd.AddCommand(BackfillCmd)
+	SensorCmd.AddCommand(CanonicalCmd)
+
 	SensorCmd.Flags().StringVarP(&inputSensorParams.Bootnodes, "bootnodes", "b", "", "Comma separated nodes used for bootstrapping")
-	SensorCmd.Flags().Uint64VarP(&inputSensorParams.NetworkID, "network-id", "n", 0, "Filter discovered nodes by this network ID")
-	if err := SensorCmd.MarkFlagRequired("network-id"); err != nil {
-		log.Error().Err(err).Msg("Failed to mark network-id as required persistent flag")
-	}
+	SensorCmd.Flags().Uint64VarP(&inputSensorParams.NetworkID, "network-id", "n", 0,
+		"Filter discovered nodes by this network ID. Required unless --network or --rpc is set")
+	SensorCmd.Flags().StringVar(&inputSensorParams.Network, "network", "",
+		fmt.Sprintf(`Bundled network profile providing the network ID, genesis, bootnodes, and
+RPC, which can still be overridden with their flags. The zkEVM networks don't
+bundle bootnodes (%s)`, strings.Join(p2p.NetworkNames(), "|")))
 	SensorCmd.PersistentFlags().StringVarP(&inputSensorParams.ProjectID, "project-id", "p", "", "GCP project ID")
 	SensorCmd.PersistentFlags().StringVarP(&inputSensorParams.DatabaseID, "database-id", "d", "", "Datastore database ID")
 	SensorCmd.Flags().StringVarP(&inputSensorParams.SensorID, "sensor-id", "s", "", "Sensor ID when writing block/tx events")
@@ -305,6 +924,17 @@ increase CPU and memory usage.`)
 	SensorCmd.Flags().BoolVar(&inputSensorParams.ShouldWriteTransactionEvents, "write-tx-events", true,
 		`Whether to write transaction events to the database. This option could
 significantly increase CPU and memory usage.`)
+	SensorCmd.Flags().Float64Var(&inputSensorParams.TxSampleRate, "tx-sample-rate", 1,
+		`Share of the transactions written to the database, including the ones in
+blocks. Transactions are sampled by hash, so sensors sample the same ones.`)
+	SensorCmd.Flags().Float64Var(&inputSensorParams.TxEventSampleRate, "tx-event-sample-rate", 1,
+		`Share of the transactions whose events are written to the database. The
+events of a sampled transaction are written for every peer.`)
+	SensorCmd.Flags().Float64Var(&inputSensorParams.TxBodySampleRate, "tx-body-sample-rate", 1,
+		`Share of the written transactions stored with their data and signature. The
+others are only stored with their metadata (sender, nonce, gas, fees, value,
+type, and size), which takes much less space.`)
+	SensorCmd.Flags().BoolVar(&inputSensorParams.ShouldWritePeers, "write-peers", true, "Whether to write peers and their locations to the database")
 	SensorCmd.Flags().BoolVar(&inputSensorParams.ShouldRunPprof, "pprof", false, "Whether to run pprof")
 	SensorCmd.Flags().UintVar(&inputSensorParams.PprofPort, "pprof-port", 6060, "Port pprof runs on")
 	SensorCmd.Flags().StringVarP(&inputSensorParams.KeyFile, "key-file", "k", "", "Private key file")
@@ -322,4 +952,70 @@ connections to be dialed. Setting this to 0 defaults it to 3.`)
 This produces faster development cycles but can prevent the sensor from being to
 connect to new peers if the nodes.json file is large.`)
 	SensorCmd.Flags().StringVar(&inputSensorParams.TrustedNodesFile, "trusted-nodes", "", "Trusted nodes file")
+	SensorCmd.Flags().StringVar(&inputSensorParams.NodesFormat, "nodes-format", string(p2p.NodeSetFormatEnode),
+		`Format of the written nodes file. Nodes files in any of these formats can be
+read (enode|enr|geth)`)
+	SensorCmd.Flags().StringVar(&inputSensorParams.CaptureFile, "capture-file", "",
+		`File to capture every raw devp2p message received to. The capture can be
+replayed with the replay command.`)
+	SensorCmd.Flags().Uint64Var(&inputSensorParams.ShardIndex, "shard-index", 0, "Index of the node ID key space shard this sensor handles")
+	SensorCmd.Flags().Uint64Var(&inputSensorParams.ShardCount, "shard-count", 1,
+		`Number of sensors the node ID key space is split between. Each sensor only
+connects to peers in its shard, so run every sensor with the same shard count,
+a unique shard index, and a unique sensor ID.`)
+	SensorCmd.Flags().StringVar(&inputSensorParams.GeoIPCityFile, "geoip-city-db", "",
+		`GeoIP2/GeoLite2 City MMDB file used to enrich peers with their country, city,
+and coordinates`)
+	SensorCmd.Flags().DurationVar(&inputSensorParams.RotationInterval, "rotation-interval", 0,
+		`How often to drop the peers announcing the least new blocks and transactions
+and dial nodes from the least observed ASNs, countries, and clients (0 disables
+rotation)`)
+	SensorCmd.Flags().Float64Var(&inputSensorParams.RotationFraction, "rotation-fraction", 0.1, "Share of the peers replaced every rotation")
+	SensorCmd.Flags().DurationVar(&inputSensorParams.RotationMinAge, "rotation-min-age", 10*time.Minute,
+		"How long a peer has to be connected before it can be rotated out")
+	SensorCmd.Flags().StringVar(&inputSensorParams.GeoIPASNFile, "geoip-asn-db", "",
+		"GeoIP2/GeoLite2 ASN MMDB file used to enrich peers with their ASN and organization")
+	SensorCmd.Flags().StringVar(&inputSensorParams.MetricsAddr, "metrics-addr", "",
+		`Address to serve Prometheus metrics on, including the bytes and messages
+exchanged with every peer per message type (e.g. :9090)`)
+	SensorCmd.Flags().DurationVar(&inputSensorParams.BandwidthInterval, "bandwidth-interval", 5*time.Minute,
+		`How often to write the bytes and messages exchanged with every peer to the
+database. The usage is also written when a peer disconnects (0 only writes it
+then).`)
+	SensorCmd.Flags().StringVar(&inputSensorParams.StatusForkID, "status-fork-id", "",
+		`Fork ID advertised in the status message instead of the computed one, as the
+hex fork hash optionally followed by the next fork block (e.g. 0x0c015a91:1000000)`)
+	SensorCmd.Flags().Uint64Var(&inputSensorParams.StatusForkIDNumber, "status-fork-id-number", 0,
+		`Block number the advertised fork ID is computed at instead of the head, e.g.
+to connect to peers on either side of a fork transition`)
+	SensorCmd.Flags().StringVar(&inputSensorParams.StatusHead, "status-head", "", "Head block hash advertised in the status message")
+	SensorCmd.Flags().StringVar(&inputSensorParams.StatusTD, "status-td", "",
+		`Total difficulty advertised in the status message. It can't exceed the TD of
+the head block, so peers never try to sync from the sensor.`)
+	SensorCmd.Flags().DurationVar(&inputSensorParams.DroppedTxTimeout, "dropped-tx-timeout", 0,
+		`How long an announced transaction has to go without being included in an
+observed block before it's written as dropped (0 disables the detection)`)
+	SensorCmd.Flags().IntVar(&inputSensorParams.DroppedTxMinPeers, "dropped-tx-min-peers", 3,
+		"Number of peers that have to announce a transaction for it to be written as dropped")
+	SensorCmd.Flags().StringVar(&inputSensorParams.DroppedTxRPC, "dropped-tx-rpc", "",
+		`Reference RPC asked for the receipts of the dropped transactions, so the ones
+included in blocks the sensor didn't observe aren't written`)
+	SensorCmd.Flags().StringVar(&inputSensorParams.JournalDir, "journal-dir", "",
+		`Directory the writes are journaled to before being replayed into the database,
+so they survive database outages and restarts (empty disables the journal)`)
+	SensorCmd.Flags().DurationVar(&inputSensorParams.JournalSegment, "journal-segment", 10*time.Second,
+		"How long writes are appended to a journal segment before it's replayed")
+	SensorCmd.Flags().DurationVar(&inputSensorParams.DrainTimeout, "drain-timeout", 30*time.Second,
+		`How long to wait on SIGINT or SIGTERM for the peers to disconnect and the
+pending database writes to be flushed before exiting`)
+	SensorCmd.Flags().StringVar(&inputSensorParams.AdminAddr, "admin-addr", "",
+		"Address to serve the admin endpoint on, which reads and changes the runtime settings at /settings")
+	SensorCmd.Flags().StringVar(&inputSensorParams.SettingsFile, "settings-file", "",
+		"JSON file the runtime settings are reloaded from on SIGHUP")
+	SensorCmd.Flags().BoolVar(&inputSensorParams.Dashboard, "dashboard", false,
+		"Whether to show a terminal dashboard of the peers, message rates, head block, and database write lag")
+	SensorCmd.Flags().StringVar(&inputSensorParams.DashboardLog, "dashboard-log", "sensor.log",
+		"File the logs are written to while the dashboard is shown")
+	SensorCmd.Flags().StringVar(&inputSensorParams.AlertsFile, "alerts-file", "",
+		"YAML file of the alert rules evaluated by the sensor and the webhooks they post to")
 }
@@ -0,0 +1,178 @@
+package sensor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/p2p"
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+)
+
+// runtimeSettings are the sensor settings that can be changed while it's
+// running, so tweaking them doesn't drop the peer connections. When applied,
+// only the fields that are set are changed.
+type runtimeSettings struct {
+	LogLevel               *string  `json:"logLevel,omitempty"`
+	MaxPeers               *int     `json:"maxPeers,omitempty"`
+	WriteBlocks            *bool    `json:"writeBlocks,omitempty"`
+	WriteBlockEvents       *bool    `json:"writeBlockEvents,omitempty"`
+	WriteTransactions      *bool    `json:"writeTxs,omitempty"`
+	WriteTransactionEvents *bool    `json:"writeTxEvents,omitempty"`
+	WritePeers             *bool    `json:"writePeers,omitempty"`
+	TxSampleRate           *float64 `json:"txSampleRate,omitempty"`
+	TxEventSampleRate      *float64 `json:"txEventSampleRate,omitempty"`
+	TxBodySampleRate       *float64 `json:"txBodySampleRate,omitempty"`
+}
+
+// settings applies the runtime settings to the running sensor.
+type settings struct {
+	mu       sync.Mutex
+	db       *database.Datastore
+	limit    *p2p.PeerLimit
+	maxPeers int
+}
+
+// current returns every runtime setting as it is now.
+func (s *settings) current() runtimeSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	level := zerolog.GlobalLevel().String()
+	limit := s.limit.Limit()
+	current := runtimeSettings{LogLevel: &level, MaxPeers: &limit}
+
+	if s.db != nil {
+		opts := s.db.WriteOptions()
+		current.WriteBlocks = &opts.ShouldWriteBlocks
+		current.WriteBlockEvents = &opts.ShouldWriteBlockEvents
+		current.WriteTransactions = &opts.ShouldWriteTransactions
+		current.WriteTransactionEvents = &opts.ShouldWriteTransactionEvents
+		current.WritePeers = &opts.ShouldWritePeers
+		current.TxSampleRate = &opts.TxSampleRate
+		current.TxEventSampleRate = &opts.TxEventSampleRate
+		current.TxBodySampleRate = &opts.TxBodySampleRate
+	}
+
+	return current
+}
+
+// apply validates the settings and changes the ones that are set. Nothing is
+// changed if any of them is invalid.
+func (s *settings) apply(r runtimeSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var level zerolog.Level
+	if r.LogLevel != nil {
+		var err error
+		if level, err = zerolog.ParseLevel(*r.LogLevel); err != nil {
+			return err
+		}
+	}
+
+	if r.MaxPeers != nil && (*r.MaxPeers < 0 || *r.MaxPeers > s.maxPeers) {
+		return fmt.Errorf("max peers must be between 0 and the --max-peers of %d", s.maxPeers)
+	}
+
+	var opts database.WriteOptions
+	if s.db != nil {
+		opts = s.db.WriteOptions()
+	}
+	dbChanged := false
+	for _, b := range []struct {
+		value *bool
+		opt   *bool
+	}{
+		{r.WriteBlocks, &opts.ShouldWriteBlocks},
+		{r.WriteBlockEvents, &opts.ShouldWriteBlockEvents},
+		{r.WriteTransactions, &opts.ShouldWriteTransactions},
+		{r.WriteTransactionEvents, &opts.ShouldWriteTransactionEvents},
+		{r.WritePeers, &opts.ShouldWritePeers},
+	} {
+		if b.value != nil {
+			*b.opt = *b.value
+			dbChanged = true
+		}
+	}
+	for _, f := range []struct {
+		value *float64
+		opt   *float64
+	}{
+		{r.TxSampleRate, &opts.TxSampleRate},
+		{r.TxEventSampleRate, &opts.TxEventSampleRate},
+		{r.TxBodySampleRate, &opts.TxBodySampleRate},
+	} {
+		if f.value == nil {
+			continue
+		}
+		if *f.value < 0 || *f.value > 1 {
+			return errors.New("transaction sample rates must be between 0 and 1")
+		}
+		*f.opt = *f.value
+		dbChanged = true
+	}
+	if dbChanged && s.db == nil {
+		return errors.New("the database doesn't support changing what's written")
+	}
+
+	if r.LogLevel != nil {
+		zerolog.SetGlobalLevel(level)
+	}
+	if r.MaxPeers != nil {
+		s.limit.Set(*r.MaxPeers)
+	}
+	if dbChanged {
+		s.db.SetWriteOptions(opts)
+	}
+
+	return nil
+}
+
+// reload applies the settings of the JSON file.
+func (s *settings) reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var r runtimeSettings
+	if err = json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+
+	return s.apply(r)
+}
+
+// ServeHTTP returns the current settings on GET, and applies the JSON body on
+// POST or PUT before returning the updated settings.
+func (s *settings) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var r runtimeSettings
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.apply(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Info().Interface("settings", r).Msg("Applied runtime settings")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.current()); err != nil {
+		log.Error().Err(err).Msg("Failed to write runtime settings")
+	}
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// profileCommandsKey is the key of a profile with the flags of single
+// commands, keyed by the path of the command without polycli, e.g. loadtest
+// or devnet start.
+const profileCommandsKey = "commands"
+
+// beforeArgs applies the profile and the output format before the arguments
+// of every command are checked, which is the first thing that runs once the
+// command and its flags are known. Many commands check their flags along
+// with the arguments.
+func beforeArgs(root *cobra.Command) {
+	walkCommands(root, func(c *cobra.Command) {
+		if c == root {
+			return
+		}
+		args := c.Args
+		c.Args = func(cmd *cobra.Command, a []string) error {
+			if err := applyProfile(root, cmd, profile); err != nil {
+				return err
+			}
+			util.SetJSONOutput(jsonOut)
+			if args == nil {
+				return nil
+			}
+			return args(cmd, a)
+		}
+	})
+}
+
+// applyProfile sets the flags of the command that weren't given on the
+// command line to the values of the profile. The values of the command in
+// the profile take precedence over the ones of the whole profile. The
+// profile is the one of --profile, or else the default profile of the config
+// file.
+func applyProfile(root, cmd *cobra.Command, name string) error {
+	if name == "" {
+		name = viper.GetString("profile")
+	}
+	if name == "" {
+		return nil
+	}
+	// Viper lowercases the keys of the config file.
+	values, ok := viper.GetStringMap("profiles")[strings.ToLower(name)].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("the profile %s isn't in the config file", name)
+	}
+	commands, ok := values[profileCommandsKey].(map[string]interface{})
+	if !ok && values[profileCommandsKey] != nil {
+		return fmt.Errorf("the commands of the profile %s need to be a map of command to flags", name)
+	}
+	if err := checkProfile(root, values, commands); err != nil {
+		return fmt.Errorf("the profile %s is invalid: %w", name, err)
+	}
+	if url, ok := values["rpc-url"].(string); ok {
+		util.SetProfileRPCURL(url)
+	}
+
+	path := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(cmd.CommandPath(), root.Name())))
+	if v, ok := commands[path].(map[string]interface{}); ok {
+		if err := setProfileFlags(cmd, v); err != nil {
+			return fmt.Errorf("the profile %s sets an invalid value for %s: %w", name, path, err)
+		}
+	}
+	if err := setProfileFlags(cmd, values); err != nil {
+		return fmt.Errorf("the profile %s sets an invalid value: %w", name, err)
+	}
+	return nil
+}
+
+// checkProfile returns an error for the keys that aren't flags of any
+// command and the commands that don't exist or don't have the flags, which
+// are most likely typos.
+func checkProfile(root *cobra.Command, values, commands map[string]interface{}) error {
+	paths := make(map[string]*cobra.Command)
+	flags := make(map[string]bool)
+	walkCommands(root, func(c *cobra.Command) {
+		paths[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(c.CommandPath(), root.Name())))] = c
+		c.Flags().VisitAll(func(f *pflag.Flag) { flags[f.Name] = true })
+		c.PersistentFlags().VisitAll(func(f *pflag.Flag) { flags[f.Name] = true })
+	})
+
+	var unknown []string
+	for key := range values {
+		if key != profileCommandsKey && !flags[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	for path, v := range commands {
+		c, ok := paths[path]
+		if !ok {
+			unknown = append(unknown, profileCommandsKey+"."+path)
+			continue
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("the flags of %s need to be a map", path)
+		}
+		for key := range m {
+			if c.Flags().Lookup(key) == nil && c.InheritedFlags().Lookup(key) == nil {
+				unknown = append(unknown, profileCommandsKey+"."+path+"."+key)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("these keys aren't flags or commands of polycli: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func walkCommands(c *cobra.Command, fn func(*cobra.Command)) {
+	fn(c)
+	for _, sub := range c.Commands() {
+		walkCommands(sub, fn)
+	}
+}
+
+// setProfileFlags sets the flags of the command that weren't set yet, on the
+// command line or by the profile. The keys that aren't flags of the command
+// are left for the other commands.
+func setProfileFlags(cmd *cobra.Command, values map[string]interface{}) error {
+	for key, value := range values {
+		// The profile can't select the config or another profile.
+		if key == "config" || key == "profile" {
+			continue
+		}
+		f := cmd.Flags().Lookup(key)
+		if f == nil || util.Changed(cmd.Flags(), key) {
+			continue
+		}
+		if err := setProfileFlag(f, value); err != nil {
+			return fmt.Errorf("--%s: %w", key, err)
+		}
+		util.MarkProfileFlag(f)
+	}
+	return nil
+}
+
+// setProfileFlag sets the flag to the value, which is a list for the flags
+// that take more than one value. The value is set directly rather than
+// through the flag set, which would mark the flag as changed.
+func setProfileFlag(f *pflag.Flag, value interface{}) error {
+	list, isList := value.([]interface{})
+	if !isList {
+		return f.Value.Set(fmt.Sprint(value))
+	}
+	if _, ok := f.Value.(pflag.SliceValue); !ok {
+		return fmt.Errorf("the flag takes a single value")
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		items = append(items, fmt.Sprint(item))
+	}
+	if f.Value.Type() != "stringArray" {
+		return f.Value.Set(strings.Join(items, ","))
+	}
+	for _, item := range items {
+		if err := f.Value.Set(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package retest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/rs/zerolog/log"
+)
+
+// forks are the forks with checks, in activation order.
+var forks = []string{"berlin", "london", "shanghai", "cancun", "prague", "osaka"}
+
+var (
+	// beaconRootsAddress is the system contract of EIP-4788.
+	beaconRootsAddress = ethcommon.HexToAddress("0x000F3fe6aD2D8Ea4ACb91dC39F3EDa8D0Bb3d0A2")
+	// historyStorageAddress is the system contract of EIP-2935.
+	historyStorageAddress = ethcommon.HexToAddress("0x0000F90827F1C53a10cb7A02335B175320002935")
+	blsG1AddAddress       = ethcommon.BytesToAddress([]byte{0x0b})
+	p256VerifyAddress     = ethcommon.BytesToAddress([]byte{0x01, 0x00})
+	deadAddress           = ethcommon.HexToAddress("0x000000000000000000000000000000000000dEaD")
+)
+
+const (
+	// maxInitCodeSize is the limit of EIP-3860 on the init code of contract
+	// creations.
+	maxInitCodeSize = 49152
+	// floorDataSize is the size of the calldata sent to estimate the gas of
+	// EIP-7623. Without the floor, 1000 nonzero bytes cost 37000 gas with the
+	// transaction, and with it at least 61000.
+	floorDataSize    = 1000
+	floorMinimumCost = 21000 + 10*4*floorDataSize
+	probeGas         = 1000000
+)
+
+type (
+	// check tests a single behavior. Run returns whether the behavior is
+	// active and optionally why, a skipError if it can't run here, or any
+	// other error if the result was inconclusive. Checks with Tx set send
+	// transactions and need a signer.
+	check struct {
+		Fork     string
+		EIP      string
+		Behavior string
+		Tx       bool
+		Run      func(ctx context.Context, e *env) (bool, string, error)
+	}
+
+	// env is the state shared by the checks. The nonce is the next nonce of
+	// the signer, which the transaction checks advance.
+	env struct {
+		rpc     *ethrpc.Client
+		c       *ethclient.Client
+		chainID *big.Int
+		signer  signer.Signer
+		nonce   uint64
+	}
+
+	skipError struct {
+		reason string
+	}
+)
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+func skip(format string, args ...interface{}) error {
+	return &skipError{reason: fmt.Sprintf(format, args...)}
+}
+
+var checks = []check{
+	{Fork: "berlin", EIP: "EIP-2930", Behavior: "Access list transactions", Tx: true, Run: checkAccessListTx},
+	{Fork: "london", EIP: "EIP-3198", Behavior: "BASEFEE opcode", Run: opcodeProbe("0x4800")},
+	{Fork: "london", EIP: "EIP-1559", Behavior: "Dynamic fee transactions", Tx: true, Run: checkDynamicFeeTx},
+	{Fork: "shanghai", EIP: "EIP-3855", Behavior: "PUSH0 opcode", Run: opcodeProbe("0x5f00")},
+	{Fork: "shanghai", EIP: "EIP-3860", Behavior: "Init code size limit", Run: checkInitCodeLimit},
+	{Fork: "cancun", EIP: "EIP-1153", Behavior: "TSTORE and TLOAD opcodes", Run: opcodeProbe("0x60015f5d5f5c00")},
+	{Fork: "cancun", EIP: "EIP-5656", Behavior: "MCOPY opcode", Run: opcodeProbe("0x60205f5f5e00")},
+	{Fork: "cancun", EIP: "EIP-4844", Behavior: "BLOBHASH opcode", Run: opcodeProbe("0x5f4900")},
+	{Fork: "cancun", EIP: "EIP-7516", Behavior: "BLOBBASEFEE opcode", Run: opcodeProbe("0x4a00")},
+	{Fork: "cancun", EIP: "EIP-4788", Behavior: "Beacon block root contract", Run: codeProbe(beaconRootsAddress)},
+	{Fork: "cancun", EIP: "EIP-1153", Behavior: "Transient storage in a mined transaction", Tx: true, Run: checkTransientStorageTx},
+	{Fork: "prague", EIP: "EIP-2537", Behavior: "BLS12-381 precompiles", Run: checkBLSPrecompile},
+	{Fork: "prague", EIP: "EIP-2935", Behavior: "Historical block hashes contract", Run: codeProbe(historyStorageAddress)},
+	{Fork: "prague", EIP: "EIP-7623", Behavior: "Calldata cost floor", Run: checkCalldataFloor},
+	{Fork: "prague", EIP: "EIP-7702", Behavior: "Set code transactions", Tx: true, Run: checkSetCodeTx},
+	{Fork: "osaka", EIP: "EIP-7939", Behavior: "CLZ opcode", Run: opcodeProbe("0x5f1e00")},
+	{Fork: "osaka", EIP: "EIP-7951", Behavior: "P256VERIFY precompile", Run: checkP256Precompile},
+}
+
+// newEnv gets the chain id and, if there is a signer, its nonce.
+func newEnv(ctx context.Context, rpc *ethrpc.Client, c *ethclient.Client, s signer.Signer) (*env, error) {
+	e := &env{rpc: rpc, c: c, signer: s}
+	chainID, err := c.ChainID(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the chain id")
+		return nil, err
+	}
+	e.chainID = chainID
+	if s == nil {
+		return e, nil
+	}
+	if e.nonce, err = c.PendingNonceAt(ctx, s.Address()); err != nil {
+		log.Error().Err(err).Msg("Unable to get account nonce")
+		return nil, err
+	}
+	log.Info().Str("address", s.Address().Hex()).Uint64("nonce", e.nonce).Msg("Sending transactions from account")
+	return e, nil
+}
+
+// call runs eth_call at the latest block. Without a target, the data is run
+// as init code, which lets opcodes be tried without deploying anything.
+func (e *env) call(ctx context.Context, to *ethcommon.Address, data []byte) ([]byte, error) {
+	msg := map[string]interface{}{"data": hexutil.Bytes(data), "gas": hexutil.Uint64(probeGas)}
+	if to != nil {
+		msg["to"] = to
+	}
+	var out hexutil.Bytes
+	err := e.rpc.CallContext(ctx, &out, "eth_call", msg, "latest")
+	return out, err
+}
+
+// opcodeProbe runs the code, which is active if it runs at all. Clients
+// report undefined opcodes with different messages, but they all mention the
+// opcode.
+func opcodeProbe(code string) func(ctx context.Context, e *env) (bool, string, error) {
+	return func(ctx context.Context, e *env) (bool, string, error) {
+		_, err := e.call(ctx, nil, hexutil.MustDecode(code))
+		if err == nil {
+			return true, "", nil
+		}
+		if isUndefinedOpcode(err) {
+			return false, err.Error(), nil
+		}
+		return false, "", err
+	}
+}
+
+func isUndefinedOpcode(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "opcode") || strings.Contains(msg, "not activated") || strings.Contains(msg, "notactivated")
+}
+
+// codeProbe checks for a system contract, which is deployed by the fork.
+func codeProbe(address ethcommon.Address) func(ctx context.Context, e *env) (bool, string, error) {
+	return func(ctx context.Context, e *env) (bool, string, error) {
+		code, err := e.c.CodeAt(ctx, address, nil)
+		if err != nil {
+			return false, "", err
+		}
+		if len(code) == 0 {
+			return false, fmt.Sprintf("%s has no code", address), nil
+		}
+		return true, fmt.Sprintf("%s has %d bytes of code", address, len(code)), nil
+	}
+}
+
+// checkInitCodeLimit creates a contract from init code one byte over the
+// limit. The zeros stop right away, so the creation only fails if the limit
+// is enforced.
+func checkInitCodeLimit(ctx context.Context, e *env) (bool, string, error) {
+	_, err := e.call(ctx, nil, make([]byte, maxInitCodeSize+1))
+	if err == nil {
+		return false, "the oversized init code ran", nil
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "initcode") || strings.Contains(msg, "init code") {
+		return true, err.Error(), nil
+	}
+	return false, "", err
+}
+
+// checkBLSPrecompile adds the point at infinity to itself with G1ADD. Before
+// the fork there's nothing at the address and the call returns nothing.
+func checkBLSPrecompile(ctx context.Context, e *env) (bool, string, error) {
+	out, err := e.call(ctx, &blsG1AddAddress, make([]byte, 256))
+	if err != nil {
+		return false, "", err
+	}
+	if len(out) == 0 {
+		return false, "G1ADD returned nothing", nil
+	}
+	if len(out) != 128 || !bytes.Equal(out, make([]byte, 128)) {
+		return false, "", fmt.Errorf("G1ADD returned %d unexpected bytes", len(out))
+	}
+	return true, "", nil
+}
+
+// checkP256Precompile verifies a fresh secp256r1 signature. Some chains
+// added the same precompile earlier as RIP-7212, which is reported as active
+// too.
+func checkP256Precompile(ctx context.Context, e *env) (bool, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, "", err
+	}
+	hash := ethcrypto.Keccak256([]byte("retest"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash)
+	if err != nil {
+		return false, "", err
+	}
+	input := make([]byte, 0, 160)
+	for _, v := range []*big.Int{new(big.Int).SetBytes(hash), r, s, key.X, key.Y} {
+		input = append(input, ethcommon.LeftPadBytes(v.Bytes(), 32)...)
+	}
+
+	out, err := e.call(ctx, &p256VerifyAddress, input)
+	if err != nil {
+		return false, "", err
+	}
+	if len(out) == 0 {
+		return false, "P256VERIFY returned nothing", nil
+	}
+	if len(out) != 32 || new(big.Int).SetBytes(out).Cmp(big.NewInt(1)) != 0 {
+		return false, "", fmt.Errorf("P256VERIFY returned %x for a valid signature", out)
+	}
+	return true, "", nil
+}
+
+// checkCalldataFloor estimates a transfer with calldata that costs more under
+// the floor than it does otherwise.
+func checkCalldataFloor(ctx context.Context, e *env) (bool, string, error) {
+	msg := map[string]interface{}{"to": deadAddress, "data": hexutil.Bytes(bytes.Repeat([]byte{0xff}, floorDataSize))}
+	var gas hexutil.Uint64
+	if err := e.rpc.CallContext(ctx, &gas, "eth_estimateGas", msg); err != nil {
+		return false, "", err
+	}
+	return uint64(gas) >= floorMinimumCost, fmt.Sprintf("estimated %d gas", uint64(gas)), nil
+}
@@ -0,0 +1,199 @@
+package retest
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	statusActive   = "active"
+	statusInactive = "inactive"
+	statusUnknown  = "unknown"
+	statusSkipped  = "skipped"
+)
+
+type (
+	retestParams struct {
+		RPCURL     string
+		PrivateKey string
+		Forks      []string
+		Timeout    time.Duration
+		Signer     signer.Config
+
+		// sendTxs is whether a signer was configured, which the transaction
+		// checks need.
+		sendTxs bool
+	}
+
+	// result is the outcome of a single check.
+	result struct {
+		Fork     string `json:"fork"`
+		EIP      string `json:"eip"`
+		Behavior string `json:"behavior"`
+		Status   string `json:"status"`
+		Detail   string `json:"detail,omitempty"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage       string
+	inputRetest retestParams
+)
+
+var RetestCmd = &cobra.Command{
+	Use:   "retest",
+	Short: "Check which behaviors of recent forks are active on a chain.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		for _, f := range inputRetest.Forks {
+			if !knownFork(f) {
+				return fmt.Errorf("the fork %s is not valid, expected one of %v", f, forks)
+			}
+		}
+		inputRetest.sendTxs = inputRetest.PrivateKey != "" || inputRetest.Signer.Kind != signer.KindPrivateKey
+		if inputRetest.sendTxs {
+			return inputRetest.Signer.Validate()
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		rpc, err := util.DialRPC(ctx, inputRetest.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to dial rpc")
+			return err
+		}
+		defer rpc.Close()
+
+		var s signer.Signer
+		if inputRetest.sendTxs {
+			if s, err = signer.New(ctx, inputRetest.Signer, inputRetest.PrivateKey); err != nil {
+				log.Error().Err(err).Msg("Unable to create the signer")
+				return err
+			}
+		} else {
+			log.Info().Msg("No signer is configured, only the read only checks are run")
+		}
+
+		e, err := newEnv(ctx, rpc, ethclient.NewClient(rpc), s)
+		if err != nil {
+			return err
+		}
+		results := runChecks(ctx, e)
+
+		if util.JSONOutput() {
+			out, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+		} else {
+			printResults(results)
+		}
+
+		// Only the forks that were asked for are expected to be active.
+		if len(inputRetest.Forks) == 0 {
+			return nil
+		}
+		inactive := 0
+		for _, r := range results {
+			if r.Status == statusInactive {
+				inactive++
+			}
+		}
+		if inactive > 0 {
+			return util.AssertionFailure(fmt.Errorf("%d of %d behaviors are inactive", inactive, len(results)))
+		}
+		return nil
+	},
+}
+
+// runChecks runs the checks of the selected forks in order. An error that
+// isn't a skip means the check was inconclusive.
+func runChecks(ctx context.Context, e *env) []result {
+	results := make([]result, 0, len(checks))
+	for _, c := range checks {
+		if !forkSelected(c.Fork) {
+			continue
+		}
+		r := result{Fork: c.Fork, EIP: c.EIP, Behavior: c.Behavior}
+
+		var (
+			active bool
+			err    error
+		)
+		if c.Tx && e.signer == nil {
+			err = skip("no signer is configured")
+		} else {
+			checkCtx, cancel := context.WithTimeout(ctx, inputRetest.Timeout)
+			active, r.Detail, err = c.Run(checkCtx, e)
+			cancel()
+		}
+
+		var s *skipError
+		switch {
+		case errors.As(err, &s):
+			r.Status = statusSkipped
+			r.Detail = err.Error()
+		case err != nil:
+			r.Status = statusUnknown
+			r.Detail = err.Error()
+		case active:
+			r.Status = statusActive
+		default:
+			r.Status = statusInactive
+		}
+		log.Debug().Str("fork", r.Fork).Str("behavior", r.Behavior).Str("status", r.Status).Str("detail", r.Detail).Msg("Checked behavior")
+		results = append(results, r)
+	}
+	return results
+}
+
+func printResults(results []result) {
+	fmt.Printf("%-10s %-10s %-44s %-9s %s\n", "Fork", "EIP", "Behavior", "Status", "Detail")
+	for _, r := range results {
+		fmt.Printf("%-10s %-10s %-44s %-9s %s\n", r.Fork, r.EIP, r.Behavior, r.Status, r.Detail)
+	}
+}
+
+func knownFork(fork string) bool {
+	for _, f := range forks {
+		if f == fork {
+			return true
+		}
+	}
+	return false
+}
+
+func forkSelected(fork string) bool {
+	if len(inputRetest.Forks) == 0 {
+		return true
+	}
+	for _, f := range inputRetest.Forks {
+		if f == fork {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	flagSet := RetestCmd.PersistentFlags()
+	flagSet.StringVarP(&inputRetest.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint url")
+	flagSet.StringVar(&inputRetest.PrivateKey, "private-key", "", "The hex encoded private key of a funded account. The checks that send transactions are skipped without a signer")
+	flagSet.StringSliceVar(&inputRetest.Forks, "forks", nil, "A comma separated list of the forks to check. All forks are checked if empty, otherwise the command fails if a behavior of the forks is inactive")
+	flagSet.DurationVar(&inputRetest.Timeout, "timeout", time.Minute, "The timeout for each check, which includes waiting for the receipts of its transactions")
+	inputRetest.Signer.AddFlags(flagSet)
+}
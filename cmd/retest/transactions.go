@@ -0,0 +1,193 @@
+package retest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/maticnetwork/polygon-cli/signer"
+	"github.com/rs/zerolog/log"
+)
+
+// transientStorageInitCode stores to transient storage, loads it back, and
+// copies memory with MCOPY, then deploys an empty contract.
+const transientStorageInitCode = "0x60015f5d5f5c5060205f5f5e00"
+
+// receipt is the part of the receipt that's used. It's decoded here rather
+// than by ethclient, which rejects the receipts of transaction types it
+// doesn't know.
+type receipt struct {
+	Status      hexutil.Uint64 `json:"status"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+}
+
+// checkAccessListTx sends a transfer to ourselves with an access list.
+func checkAccessListTx(ctx context.Context, e *env) (bool, string, error) {
+	if signer.RequiresLegacy(e.signer) {
+		return false, "", skip("the signer can only sign legacy transactions")
+	}
+	gasPrice, err := e.c.SuggestGasPrice(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	self := e.signer.Address()
+	return e.sendTx(ctx, &ethtypes.AccessListTx{
+		ChainID:    e.chainID,
+		Nonce:      e.nonce,
+		GasPrice:   gasPrice,
+		Gas:        30000,
+		To:         &self,
+		Value:      big.NewInt(0),
+		AccessList: ethtypes.AccessList{{Address: self}},
+	})
+}
+
+// checkDynamicFeeTx sends a transfer to ourselves with a fee cap and tip.
+func checkDynamicFeeTx(ctx context.Context, e *env) (bool, string, error) {
+	if signer.RequiresLegacy(e.signer) {
+		return false, "", skip("the signer can only sign legacy transactions")
+	}
+	gasTipCap, gasFeeCap, err := e.fees(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	self := e.signer.Address()
+	return e.sendTx(ctx, &ethtypes.DynamicFeeTx{
+		ChainID:   e.chainID,
+		Nonce:     e.nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       21000,
+		To:        &self,
+		Value:     big.NewInt(0),
+	})
+}
+
+// checkTransientStorageTx creates a contract with init code using the new
+// opcodes of Cancun, so they're run by the block producer and not only by
+// eth_call. The creation only succeeds if they're all defined.
+func checkTransientStorageTx(ctx context.Context, e *env) (bool, string, error) {
+	gasPrice, err := e.c.SuggestGasPrice(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	return e.sendTx(ctx, &ethtypes.LegacyTx{
+		Nonce:    e.nonce,
+		GasPrice: gasPrice,
+		Gas:      100000,
+		Value:    big.NewInt(0),
+		Data:     hexutil.MustDecode(transientStorageInitCode),
+	})
+}
+
+// checkSetCodeTx sends a set code transaction that clears the delegation of
+// our own account. The authorization is signed with the nonce after the one
+// of the transaction, since the nonce is incremented before authorizations
+// are processed.
+func checkSetCodeTx(ctx context.Context, e *env) (bool, string, error) {
+	local, ok := e.signer.(*signer.LocalSigner)
+	if !ok {
+		return false, "", skip("set code transactions need the private key signer")
+	}
+	gasTipCap, gasFeeCap, err := e.fees(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	auth, err := signer.SignSetCodeAuthorization(local.Key, e.chainID, ethcommon.Address{}, e.nonce+1)
+	if err != nil {
+		return false, "", err
+	}
+	raw, hash, err := signer.SignSetCodeTx(&signer.SetCodeTx{
+		ChainID:   e.chainID,
+		Nonce:     e.nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       60000,
+		To:        local.Address(),
+		Value:     big.NewInt(0),
+		AuthList:  []signer.SetCodeAuthorization{auth},
+	}, local.Key)
+	if err != nil {
+		return false, "", err
+	}
+	return e.send(ctx, raw, hash)
+}
+
+// fees returns the tip and a fee cap that leaves room for the base fee to
+// double before the next block.
+func (e *env) fees(ctx context.Context) (*big.Int, *big.Int, error) {
+	header, err := e.c.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.BaseFee == nil {
+		return nil, nil, fmt.Errorf("the latest block has no base fee")
+	}
+	gasTipCap, err := e.c.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	gasFeeCap := new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), gasTipCap)
+	return gasTipCap, gasFeeCap, nil
+}
+
+func (e *env) sendTx(ctx context.Context, data ethtypes.TxData) (bool, string, error) {
+	tx, err := e.signer.SignTx(ctx, ethtypes.NewTx(data), e.chainID)
+	if err != nil {
+		return false, "", err
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return false, "", err
+	}
+	return e.send(ctx, raw, tx.Hash())
+}
+
+// send sends the transaction and waits for its receipt. The behavior is
+// inactive if the transaction is rejected as unsupported or fails.
+func (e *env) send(ctx context.Context, raw []byte, hash ethcommon.Hash) (bool, string, error) {
+	if err := e.rpc.CallContext(ctx, nil, "eth_sendRawTransaction", hexutil.Encode(raw)); err != nil {
+		if isUnsupportedTx(err) {
+			return false, err.Error(), nil
+		}
+		return false, "", err
+	}
+	e.nonce++
+	log.Trace().Str("txHash", hash.Hex()).Msg("Sent transaction")
+
+	r, err := e.waitForReceipt(ctx, hash)
+	if err != nil {
+		return false, "", err
+	}
+	detail := fmt.Sprintf("%s in block %d", hash.Hex(), uint64(r.BlockNumber))
+	return r.Status == hexutil.Uint64(ethtypes.ReceiptStatusSuccessful), detail, nil
+}
+
+func (e *env) waitForReceipt(ctx context.Context, hash ethcommon.Hash) (*receipt, error) {
+	for {
+		var r *receipt
+		if err := e.rpc.CallContext(ctx, &r, "eth_getTransactionReceipt", hash); err != nil {
+			return nil, err
+		}
+		if r != nil {
+			return r, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for the receipt of %s", hash.Hex())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// isUnsupportedTx returns true if the node rejected the transaction because
+// it doesn't accept its type yet.
+func isUnsupportedTx(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "not supported") || strings.Contains(msg, "unsupported") || strings.Contains(msg, "transaction type")
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/maticnetwork/polygon-cli/cmd/fork"
+	"github.com/maticnetwork/polygon-cli/cmd/fund"
 	"github.com/maticnetwork/polygon-cli/cmd/p2p"
 	"github.com/maticnetwork/polygon-cli/cmd/parseethwallet"
 	"github.com/rs/zerolog"
@@ -14,26 +15,49 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/maticnetwork/polygon-cli/cmd/abi"
+	"github.com/maticnetwork/polygon-cli/cmd/blockfetch"
+	"github.com/maticnetwork/polygon-cli/cmd/chaininfo"
+	"github.com/maticnetwork/polygon-cli/cmd/devnet"
 	"github.com/maticnetwork/polygon-cli/cmd/dumpblocks"
+	"github.com/maticnetwork/polygon-cli/cmd/engine"
 	"github.com/maticnetwork/polygon-cli/cmd/enr"
+	"github.com/maticnetwork/polygon-cli/cmd/feeoracle"
 	"github.com/maticnetwork/polygon-cli/cmd/forge"
+	"github.com/maticnetwork/polygon-cli/cmd/genalloc"
 	"github.com/maticnetwork/polygon-cli/cmd/hash"
+	"github.com/maticnetwork/polygon-cli/cmd/keystore"
 	"github.com/maticnetwork/polygon-cli/cmd/leveldbbench"
 	"github.com/maticnetwork/polygon-cli/cmd/loadtest"
+	"github.com/maticnetwork/polygon-cli/cmd/logscheck"
+	"github.com/maticnetwork/polygon-cli/cmd/mempoolwatch"
 	"github.com/maticnetwork/polygon-cli/cmd/metricsToDash"
 	"github.com/maticnetwork/polygon-cli/cmd/mnemonic"
 	"github.com/maticnetwork/polygon-cli/cmd/monitor"
+	"github.com/maticnetwork/polygon-cli/cmd/multicall"
 	"github.com/maticnetwork/polygon-cli/cmd/nodekey"
+	"github.com/maticnetwork/polygon-cli/cmd/retest"
 	"github.com/maticnetwork/polygon-cli/cmd/rpc"
 	"github.com/maticnetwork/polygon-cli/cmd/rpcfuzz"
+	"github.com/maticnetwork/polygon-cli/cmd/rpctest"
+	"github.com/maticnetwork/polygon-cli/cmd/signbench"
+	"github.com/maticnetwork/polygon-cli/cmd/storage"
+	"github.com/maticnetwork/polygon-cli/cmd/token"
+	"github.com/maticnetwork/polygon-cli/cmd/tx"
 	"github.com/maticnetwork/polygon-cli/cmd/version"
 	"github.com/maticnetwork/polygon-cli/cmd/wallet"
+	"github.com/maticnetwork/polygon-cli/cmd/wrapjrpc"
+	"github.com/maticnetwork/polygon-cli/util"
 )
 
 var (
-	cfgFile   string
-	verbosity int
-	pretty    bool
+	cfgFile      string
+	profile      string
+	jsonOut      bool
+	otlpEndpoint string
+	verbosity    int
+	pretty       bool
+	proxy        string
+	rpcAuth      util.RPCAuth
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -43,8 +67,9 @@ var rootCmd *cobra.Command
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
+	util.ShutdownTracing(err)
 	if err != nil {
-		os.Exit(1)
+		os.Exit(util.ExitCode(err))
 	}
 }
 
@@ -84,15 +109,36 @@ func NewPolycliCommand() *cobra.Command {
 		Use:   "polycli",
 		Short: "A Swiss Army knife of blockchain tools.",
 		Long:  "Polycli is a collection of tools that are meant to be useful while building, testing, and running block chain applications.",
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			setLogLevel(verbosity, pretty)
+			if err := util.SetProxy(proxy); err != nil {
+				return util.ConfigError(err)
+			}
+			if err := util.SetRPCAuth(rpcAuth); err != nil {
+				return util.ConfigError(err)
+			}
+			ctx, err := util.SetTracing(cmd.Context(), otlpEndpoint, cmd.CommandPath())
+			if err != nil {
+				return util.ConfigError(err)
+			}
+			cmd.SetContext(ctx)
+			return nil
 		},
 	}
 
 	// Define flags and configuration settings.
 	cmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.polygon-cli.yaml)")
+	cmd.PersistentFlags().StringVar(&profile, "profile", "", "The profile of the config file whose flags are used, which defaults to the profile set in the config file")
 	cmd.PersistentFlags().IntVarP(&verbosity, "verbosity", "v", 400, "0 - Silent\n100 Fatal\n200 Error\n300 Warning\n400 Info\n500 Debug\n600 Trace")
 	cmd.PersistentFlags().BoolVar(&pretty, "pretty-logs", true, "Should logs be in pretty format or JSON")
+	cmd.PersistentFlags().BoolVar(&jsonOut, "json", false, "Print the results of the command as JSON")
+	cmd.PersistentFlags().StringVar(&proxy, "proxy", "", "Connect through an HTTP CONNECT or SOCKS5 proxy, e.g. socks5://localhost:1080. Applies to the RPC endpoints and the devp2p connections, but not the UDP discovery")
+	cmd.PersistentFlags().StringVar(&rpcAuth.CertFile, "rpc-tls-cert", "", "The PEM encoded client certificate for mutual TLS with the RPC endpoints")
+	cmd.PersistentFlags().StringVar(&rpcAuth.KeyFile, "rpc-tls-key", "", "The PEM encoded key of the client certificate")
+	cmd.PersistentFlags().StringVar(&rpcAuth.CAFile, "rpc-tls-ca", "", "The PEM encoded certificates of the authorities the certificates of the RPC endpoints are checked against, instead of the system ones")
+	cmd.PersistentFlags().StringVar(&rpcAuth.Token, "rpc-token", "", "A bearer token sent to the HTTP RPC endpoints")
+	cmd.PersistentFlags().StringVar(&rpcAuth.JWTSecretFile, "rpc-jwt-secret", "", "The file with the hex encoded secret of engine API style JWTs, which are signed for every request to the HTTP RPC endpoints")
+	cmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "The OTLP HTTP endpoint the spans of the RPC requests, load test requests, and sensor messages are exported to, e.g. http://localhost:4318")
 
 	// Define local flags which will only run when this action is called directly.
 	cmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
@@ -101,24 +147,44 @@ func NewPolycliCommand() *cobra.Command {
 	// Define commands.
 	cmd.AddCommand(
 		abi.ABICmd,
+		blockfetch.BlockFetchCmd,
+		chaininfo.ChainInfoCmd,
+		devnet.DevnetCmd,
 		dumpblocks.DumpblocksCmd,
+		engine.EngineCmd,
 		forge.ForgeCmd,
 		fork.ForkCmd,
+		fund.FundCmd,
+		genalloc.GenallocCmd,
 		hash.HashCmd,
 		enr.ENRCmd,
+		feeoracle.FeeOracleCmd,
+		keystore.KeystoreCmd,
 		leveldbbench.LevelDBBenchCmd,
 		loadtest.LoadtestCmd,
+		logscheck.LogsCheckCmd,
+		mempoolwatch.MempoolWatchCmd,
 		metricsToDash.MetricsToDashCmd,
 		mnemonic.MnemonicCmd,
 		monitor.MonitorCmd,
+		multicall.MulticallCmd,
 		nodekey.NodekeyCmd,
 		p2p.P2pCmd,
 		parseethwallet.ParseETHWalletCmd,
+		retest.RetestCmd,
 		rpc.RpcCmd,
 		rpcfuzz.RPCFuzzCmd,
+		rpctest.RPCTestCmd,
+		signbench.SignbenchCmd,
+		storage.StorageCmd,
+		token.TokenCmd,
+		tx.TxCmd,
 		version.VersionCmd,
 		wallet.WalletCmd,
+		wrapjrpc.WrapJRPCCmd,
 	)
+	beforeArgs(cmd)
+	withConfigErrors(cmd)
 	return cmd
 }
 
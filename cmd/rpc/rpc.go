@@ -8,8 +8,7 @@ import (
 
 	_ "embed"
 
-	ethrpc "github.com/ethereum/go-ethereum/rpc"
-
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/spf13/cobra"
 )
 
@@ -23,7 +22,7 @@ var RpcCmd = &cobra.Command{
 	Long:  usage,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
-		ec, err := ethrpc.DialContext(ctx, args[0])
+		ec, err := util.DialRPC(ctx, args[0])
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,157 @@
+package rpcfuzz
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/cmd/rpcfuzz/testreporter"
+	"github.com/rs/zerolog/log"
+)
+
+// fuzzedSuffix is appended to the name of the tests that run with fuzzed args.
+const fuzzedSuffix = "-FUZZED"
+
+type (
+	// CorpusEntry is a failing input that's persisted so it can be replayed
+	// later.
+	CorpusEntry struct {
+		Name      string        `json:"name"`
+		Method    string        `json:"method"`
+		Args      []interface{} `json:"args"`
+		Seed      int64         `json:"seed"`
+		Result    interface{}   `json:"result,omitempty"`
+		Error     string        `json:"error,omitempty"`
+		Timestamp time.Time     `json:"timestamp"`
+	}
+
+	// corpusTest replays a corpus entry with the validation of the test that
+	// originally failed.
+	corpusTest struct {
+		RPCTest
+		args []interface{}
+	}
+)
+
+var corpusMutex sync.Mutex
+
+func (c *corpusTest) GetArgs() []interface{} {
+	return c.args
+}
+
+// saveCorpusEntry writes a failing input to the corpus directory. The file
+// name is derived from the test, method, and args so the same failure is
+// only stored once.
+func saveCorpusEntry(name, method string, args []interface{}, result interface{}, err error) {
+	if *testCorpus == "" || *testReplay {
+		return
+	}
+
+	entry := CorpusEntry{
+		Name:      name,
+		Method:    method,
+		Args:      args,
+		Seed:      *seed,
+		Result:    result,
+		Timestamp: time.Now(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	key, jsonErr := json.Marshal([]interface{}{name, method, args})
+	if jsonErr != nil {
+		log.Error().Err(jsonErr).Str("name", name).Msg("Unable to encode the corpus entry")
+		return
+	}
+	hash := sha256.Sum256(key)
+	path := filepath.Join(*testCorpus, name+"-"+hex.EncodeToString(hash[:8])+".json")
+
+	corpusMutex.Lock()
+	defer corpusMutex.Unlock()
+	if _, statErr := os.Stat(path); statErr == nil {
+		return
+	}
+	if jsonErr = writeJSONFile(path, entry); jsonErr != nil {
+		log.Error().Err(jsonErr).Str("path", path).Msg("Unable to write the corpus entry")
+		return
+	}
+	log.Debug().Str("path", path).Msg("Saved failing input to the corpus")
+}
+
+// loadCorpus reads every entry in the corpus directory sorted by file name.
+// Numbers are decoded as json.Number so the args are sent exactly as they were
+// recorded.
+func loadCorpus(dir string) ([]CorpusEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	entries := make([]CorpusEntry, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var entry CorpusEntry
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err = decoder.Decode(&entry); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Skipping invalid corpus entry")
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// replayCorpus re-runs every corpus entry. Entries from a regular test are
+// validated by that test, and fuzzed entries fail if the call returns an
+// error, which is how they were recorded. Any entry that still fails is
+// reported as a regression.
+func replayCorpus(ctx context.Context, rpcClient *rpc.Client, dir string) ([]testreporter.TestResult, error) {
+	entries, err := loadCorpus(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tests := make(map[string]RPCTest, len(allTests))
+	for _, t := range allTests {
+		tests[t.GetName()] = t
+	}
+
+	results := make([]testreporter.TestResult, 0, len(entries))
+	regressions := 0
+	for _, entry := range entries {
+		var test RPCTest = &RPCTestGeneric{
+			Name:      entry.Name,
+			Method:    entry.Method,
+			Validator: func(interface{}) error { return nil },
+		}
+		if t, ok := tests[entry.Name]; ok && !strings.HasSuffix(entry.Name, fuzzedSuffix) {
+			test = t
+		}
+
+		result := CallRPCAndValidate(ctx, rpcClient, &corpusTest{RPCTest: test, args: entry.Args})
+		if result.NumberOfTestsFailed > 0 {
+			regressions++
+			log.Warn().Str("name", entry.Name).Str("method", entry.Method).Interface("args", entry.Args).Msg("Corpus entry still fails")
+		}
+		results = append(results, result)
+	}
+
+	log.Info().Int("entries", len(entries)).Int("regressions", regressions).Msg("Replayed the corpus")
+	return results, nil
+}
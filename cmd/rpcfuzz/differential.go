@@ -0,0 +1,152 @@
+package rpcfuzz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// differentialWriteMethods change state, so sending the same request to both
+// endpoints is expected to fail on the second one when they share a network.
+var differentialWriteMethods = map[string]struct{}{
+	"eth_sendRawTransaction": {},
+	"eth_sendTransaction":    {},
+}
+
+type (
+	// DifferentialDivergence is a request where the endpoint and the reference
+	// endpoint disagreed on the result or the error code.
+	DifferentialDivergence struct {
+		Name               string        `json:"name"`
+		Method             string        `json:"method"`
+		Args               []interface{} `json:"args"`
+		Reason             string        `json:"reason"`
+		Result             interface{}   `json:"result,omitempty"`
+		ReferenceResult    interface{}   `json:"referenceResult,omitempty"`
+		ErrorCode          *int          `json:"errorCode,omitempty"`
+		ReferenceErrorCode *int          `json:"referenceErrorCode,omitempty"`
+		Error              string        `json:"error,omitempty"`
+		ReferenceError     string        `json:"referenceError,omitempty"`
+	}
+
+	// DifferentialReport is the machine readable report of every divergence
+	// found while comparing the endpoints.
+	DifferentialReport struct {
+		URL          string                   `json:"url"`
+		ReferenceURL string                   `json:"referenceUrl"`
+		Compared     int                      `json:"compared"`
+		Skipped      int                      `json:"skipped"`
+		Divergences  []DifferentialDivergence `json:"divergences"`
+
+		mutex sync.Mutex
+	}
+)
+
+var (
+	referenceClient    *rpc.Client
+	differentialIgnore *regexp.Regexp
+	differentialReport DifferentialReport
+)
+
+// compareWithReference sends the same request to the reference endpoint and
+// records a divergence if the results or error codes differ.
+func compareWithReference(ctx context.Context, name, method string, args []interface{}, result interface{}, err error) {
+	if referenceClient == nil {
+		return
+	}
+
+	_, isWrite := differentialWriteMethods[method]
+	if isWrite || (differentialIgnore != nil && differentialIgnore.MatchString(method)) {
+		differentialReport.mutex.Lock()
+		differentialReport.Skipped++
+		differentialReport.mutex.Unlock()
+		return
+	}
+
+	var refResult interface{}
+	refErr := referenceClient.CallContext(ctx, &refResult, method, args...)
+
+	divergence := DifferentialDivergence{
+		Name:            name,
+		Method:          method,
+		Args:            args,
+		Result:          result,
+		ReferenceResult: refResult,
+	}
+	if err != nil {
+		divergence.Error = err.Error()
+		divergence.ErrorCode = errorCode(err)
+	}
+	if refErr != nil {
+		divergence.ReferenceError = refErr.Error()
+		divergence.ReferenceErrorCode = errorCode(refErr)
+	}
+
+	switch {
+	case err != nil && refErr == nil:
+		divergence.Reason = "only the endpoint returned an error"
+	case err == nil && refErr != nil:
+		divergence.Reason = "only the reference endpoint returned an error"
+	case err != nil && refErr != nil:
+		if !reflect.DeepEqual(divergence.ErrorCode, divergence.ReferenceErrorCode) {
+			divergence.Reason = "the error codes differ"
+		}
+	default:
+		if !reflect.DeepEqual(result, refResult) {
+			divergence.Reason = "the results differ"
+		}
+	}
+
+	differentialReport.mutex.Lock()
+	defer differentialReport.mutex.Unlock()
+	differentialReport.Compared++
+	if divergence.Reason == "" {
+		return
+	}
+
+	log.Warn().Str("name", name).Str("method", method).Str("reason", divergence.Reason).Msg("Endpoints diverged")
+	differentialReport.Divergences = append(differentialReport.Divergences, divergence)
+}
+
+// errorCode returns the JSON-RPC error code if the error has one.
+func errorCode(err error) *int {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		code := rpcErr.ErrorCode()
+		return &code
+	}
+	return nil
+}
+
+// writeDifferentialReport writes the report as JSON.
+func writeDifferentialReport(path string) error {
+	differentialReport.mutex.Lock()
+	defer differentialReport.mutex.Unlock()
+
+	if differentialReport.Divergences == nil {
+		differentialReport.Divergences = []DifferentialDivergence{}
+	}
+
+	return writeJSONFile(path, &differentialReport)
+}
+
+// writeJSONFile writes the value as indented JSON, creating the directory if
+// needed.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
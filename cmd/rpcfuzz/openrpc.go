@@ -0,0 +1,461 @@
+package rpcfuzz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/maticnetwork/polygon-cli/cmd/rpcfuzz/testreporter"
+	"github.com/rs/zerolog/log"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	// openRPCDiscover loads the OpenRPC document from the endpoint itself.
+	openRPCDiscover = "discover"
+
+	// openRPCMaxDepth limits how deep nested schemas are generated.
+	openRPCMaxDepth = 6
+
+	// openRPCMaxRepeat limits unbounded repetitions in regular expressions.
+	openRPCMaxRepeat = 8
+)
+
+type (
+	openRPCDocument struct {
+		Methods    []openRPCMethod `json:"methods"`
+		Components struct {
+			Schemas            map[string]interface{}              `json:"schemas"`
+			ContentDescriptors map[string]openRPCContentDescriptor `json:"contentDescriptors"`
+		} `json:"components"`
+	}
+
+	openRPCMethod struct {
+		Name     string                     `json:"name"`
+		Params   []openRPCContentDescriptor `json:"params"`
+		Result   *openRPCContentDescriptor  `json:"result"`
+		Examples []openRPCExample           `json:"examples"`
+	}
+
+	openRPCContentDescriptor struct {
+		Ref      string                 `json:"$ref"`
+		Name     string                 `json:"name"`
+		Required bool                   `json:"required"`
+		Schema   map[string]interface{} `json:"schema"`
+	}
+
+	openRPCExample struct {
+		Name   string `json:"name"`
+		Params []struct {
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"params"`
+	}
+
+	// openRPCCase is a single request generated from the OpenRPC document.
+	openRPCCase struct {
+		Name   string
+		Method string
+		Args   []interface{}
+	}
+
+	// OpenRPCCoverage is the coverage of a single method in the OpenRPC
+	// document.
+	OpenRPCCoverage struct {
+		Method      string `json:"method"`
+		Cases       int    `json:"cases"`
+		Passed      int    `json:"passed"`
+		Failed      int    `json:"failed"`
+		Unsupported bool   `json:"unsupported"`
+		HandWritten bool   `json:"handWritten"`
+	}
+
+	openRPCFuzzer struct {
+		doc *openRPCDocument
+		rng *rand.Rand
+	}
+)
+
+// loadOpenRPCDocument reads the OpenRPC document from a file, a URL, or the
+// endpoint's rpc.discover method.
+func loadOpenRPCDocument(ctx context.Context, rpcClient *rpc.Client, source string) (*openRPCDocument, error) {
+	var data []byte
+	switch {
+	case source == openRPCDiscover:
+		var raw json.RawMessage
+		if err := rpcClient.CallContext(ctx, &raw, "rpc.discover"); err != nil {
+			return nil, fmt.Errorf("unable to discover the OpenRPC document: %w", err)
+		}
+		data = raw
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unable to fetch the OpenRPC document: %s", resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return nil, err
+		}
+	}
+
+	doc := new(openRPCDocument)
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("unable to parse the OpenRPC document: %w", err)
+	}
+	return doc, nil
+}
+
+// resolveDescriptor follows a content descriptor reference.
+func (doc *openRPCDocument) resolveDescriptor(cd openRPCContentDescriptor) openRPCContentDescriptor {
+	if cd.Ref == "" {
+		return cd
+	}
+	name := cd.Ref[strings.LastIndex(cd.Ref, "/")+1:]
+	if resolved, ok := doc.Components.ContentDescriptors[name]; ok {
+		return resolved
+	}
+	return cd
+}
+
+// resolveSchema follows schema references until it reaches a schema without
+// one.
+func (doc *openRPCDocument) resolveSchema(schema map[string]interface{}) map[string]interface{} {
+	for i := 0; i < openRPCMaxDepth; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		name := ref[strings.LastIndex(ref, "/")+1:]
+		resolved, ok := doc.Components.Schemas[name].(map[string]interface{})
+		if !ok {
+			return schema
+		}
+		schema = resolved
+	}
+	return schema
+}
+
+// cases returns the requests for a method: one per example in the document
+// followed by the generated ones.
+func (f *openRPCFuzzer) cases(method openRPCMethod, generated int) []openRPCCase {
+	var cases []openRPCCase
+	for i, example := range method.Examples {
+		args := make([]interface{}, 0, len(example.Params))
+		for _, p := range example.Params {
+			args = append(args, p.Value)
+		}
+		cases = append(cases, openRPCCase{Name: fmt.Sprintf("OpenRPC-%s-Example%d", method.Name, i), Method: method.Name, Args: args})
+	}
+
+	for i := 0; i < generated; i++ {
+		args := make([]interface{}, 0, len(method.Params))
+		for _, p := range method.Params {
+			p = f.doc.resolveDescriptor(p)
+			// Stop at the first optional param half the time so both the short
+			// and full forms of the method are covered.
+			if !p.Required && f.rng.Intn(2) == 0 {
+				break
+			}
+			args = append(args, f.generate(p.Schema, 0))
+		}
+		cases = append(cases, openRPCCase{Name: fmt.Sprintf("OpenRPC-%s-Generated%d", method.Name, i), Method: method.Name, Args: args})
+	}
+
+	return cases
+}
+
+// generate returns a random value that conforms to the schema.
+func (f *openRPCFuzzer) generate(schema map[string]interface{}, depth int) interface{} {
+	if schema == nil || depth > openRPCMaxDepth {
+		return nil
+	}
+	schema = f.doc.resolveSchema(schema)
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[f.rng.Intn(len(enum))]
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if options, ok := schema[key].([]interface{}); ok && len(options) > 0 {
+			option, _ := options[f.rng.Intn(len(options))].(map[string]interface{})
+			return f.generate(option, depth+1)
+		}
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok && len(all) > 0 {
+		option, _ := all[0].(map[string]interface{})
+		return f.generate(option, depth+1)
+	}
+
+	schemaType, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]interface{}); ok {
+		for _, t := range types {
+			if t != "null" {
+				schemaType, _ = t.(string)
+				break
+			}
+		}
+	}
+
+	switch schemaType {
+	case "string":
+		if pattern, ok := schema["pattern"].(string); ok {
+			if s, err := f.generatePattern(pattern); err == nil {
+				return s
+			}
+			log.Trace().Str("pattern", pattern).Msg("Unable to generate a string for the pattern")
+		}
+		return fmt.Sprintf("0x%x", f.rng.Uint32())
+	case "integer", "number":
+		return f.rng.Intn(1 << 16)
+	case "boolean":
+		return f.rng.Intn(2) == 0
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		values := make([]interface{}, f.rng.Intn(4))
+		for i := range values {
+			values[i] = f.generate(items, depth+1)
+		}
+		return values
+	case "object":
+		properties, _ := schema["properties"].(map[string]interface{})
+		required := make(map[string]bool)
+		if r, ok := schema["required"].([]interface{}); ok {
+			for _, name := range r {
+				if s, ok := name.(string); ok {
+					required[s] = true
+				}
+			}
+		}
+
+		// Sort the property names so the same seed generates the same object.
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		object := make(map[string]interface{})
+		for _, name := range names {
+			if !required[name] && f.rng.Intn(2) == 0 {
+				continue
+			}
+			property, _ := properties[name].(map[string]interface{})
+			object[name] = f.generate(property, depth+1)
+		}
+		return object
+	}
+
+	return nil
+}
+
+// generatePattern returns a random string that matches the regular
+// expression.
+func (f *openRPCFuzzer) generatePattern(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err = f.generateRegexp(&sb, re.Simplify()); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func (f *openRPCFuzzer) generateRegexp(sb *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpLiteral:
+		sb.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return errors.New("empty character class")
+		}
+		// Pick a range and then a rune within it.
+		i := f.rng.Intn(len(re.Rune)/2) * 2
+		lo, hi := re.Rune[i], re.Rune[i+1]
+		if hi > 0x7e {
+			hi = 0x7e
+		}
+		if hi < lo {
+			hi = lo
+		}
+		sb.WriteRune(lo + rune(f.rng.Intn(int(hi-lo)+1)))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sb.WriteRune(rune('a' + f.rng.Intn(26)))
+	case syntax.OpCapture:
+		return f.generateRegexp(sb, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := f.generateRegexp(sb, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		return f.generateRegexp(sb, re.Sub[f.rng.Intn(len(re.Sub))])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := 0, openRPCMaxRepeat
+		switch re.Op {
+		case syntax.OpPlus:
+			min = 1
+		case syntax.OpQuest:
+			max = 1
+		case syntax.OpRepeat:
+			min, max = re.Min, re.Max
+			if max < 0 {
+				max = min + openRPCMaxRepeat
+			}
+		}
+		for n := min + f.rng.Intn(max-min+1); n > 0; n-- {
+			if err := f.generateRegexp(sb, re.Sub[0]); err != nil {
+				return err
+			}
+		}
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+	default:
+		return fmt.Errorf("unsupported regular expression operation %v", re.Op)
+	}
+	return nil
+}
+
+// validator returns a function that validates a result against the method's
+// result schema. The document's components are included so references
+// resolve.
+func (doc *openRPCDocument) validator(method openRPCMethod) (func(result interface{}) error, error) {
+	if method.Result == nil {
+		return func(interface{}) error { return nil }, nil
+	}
+	result := doc.resolveDescriptor(*method.Result)
+	if result.Schema == nil {
+		return func(interface{}) error { return nil }, nil
+	}
+
+	schema := map[string]interface{}{
+		"allOf":      []interface{}{result.Schema},
+		"components": map[string]interface{}{"schemas": doc.Components.Schemas},
+	}
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(result interface{}) error {
+		validatorResult, err := compiled.Validate(gojsonschema.NewGoLoader(result))
+		if err != nil {
+			return fmt.Errorf("Unable to run json validation: %w", err)
+		}
+		if !validatorResult.Valid() {
+			errStr := ""
+			for _, desc := range validatorResult.Errors() {
+				errStr += desc.String() + "\n"
+			}
+			return fmt.Errorf("The json document is not valid: %s", errStr)
+		}
+		return nil
+	}, nil
+}
+
+// runOpenRPCTests generates requests for every method in the OpenRPC
+// document that belongs to an enabled namespace. Application errors (e.g. an
+// unknown transaction hash) are expected for random inputs, but the method
+// not existing or the params being rejected are failures since the inputs
+// conform to the spec.
+func runOpenRPCTests(ctx context.Context, rpcClient *rpc.Client, doc *openRPCDocument) ([]testreporter.TestResult, []OpenRPCCoverage) {
+	f := &openRPCFuzzer{doc: doc, rng: rand.New(rand.NewSource(*seed))}
+
+	handWritten := make(map[string]bool)
+	for _, t := range allTests {
+		handWritten[t.GetMethod()] = true
+	}
+
+	var (
+		results  []testreporter.TestResult
+		coverage []OpenRPCCoverage
+	)
+	for _, method := range doc.Methods {
+		if !shouldRunTest(&RPCTestGeneric{Method: method.Name}) {
+			continue
+		}
+
+		cov := OpenRPCCoverage{Method: method.Name, HandWritten: handWritten[method.Name]}
+		validate, err := doc.validator(method)
+		if err != nil {
+			log.Warn().Err(err).Str("method", method.Name).Msg("Unable to compile the result schema")
+			validate = func(interface{}) error { return nil }
+		}
+
+		for _, c := range f.cases(method, *testOpenRPCCases) {
+			result := testreporter.New(c.Name, c.Method, 1)
+
+			var response interface{}
+			err := rpcClient.CallContext(ctx, &response, c.Method, c.Args...)
+			compareWithReference(ctx, c.Name, c.Method, c.Args, response, err)
+
+			if err != nil {
+				var rpcErr rpc.Error
+				switch {
+				case !errors.As(err, &rpcErr):
+					result.Fail(c.Args, response, err)
+				case rpcErr.ErrorCode() == -32601:
+					cov.Unsupported = true
+					result.Fail(c.Args, response, fmt.Errorf("Method is not supported: %w", err))
+				case rpcErr.ErrorCode() == -32602 || rpcErr.ErrorCode() == -32600 || rpcErr.ErrorCode() == -32700:
+					result.Fail(c.Args, response, fmt.Errorf("Spec conforming params were rejected: %w", err))
+				default:
+					result.Pass(c.Args, response, err)
+				}
+			} else if err = validate(response); err != nil {
+				result.Fail(c.Args, response, errors.New("Failed to validate: "+err.Error()))
+			} else {
+				result.Pass(c.Args, response, nil)
+			}
+
+			cov.Cases++
+			cov.Passed += result.NumberOfTestsPassed
+			cov.Failed += result.NumberOfTestsFailed
+			results = append(results, result)
+
+			if *testFuzz {
+				results = append(results, CallRPCWithFuzzAndValidate(ctx, rpcClient, &RPCTestGeneric{Name: c.Name, Method: c.Method, Args: c.Args}))
+			}
+		}
+
+		coverage = append(coverage, cov)
+	}
+
+	return results, coverage
+}
+
+// printOpenRPCCoverage prints the per method coverage table.
+func printOpenRPCCoverage(coverage []OpenRPCCoverage) {
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Method", "Cases", "Passed", "Failed", "Unsupported", "Hand Written"})
+	for _, c := range coverage {
+		tw.AppendRow(table.Row{c.Method, c.Cases, c.Passed, c.Failed, c.Unsupported, c.HandWritten})
+	}
+	tw.SortBy([]table.SortBy{{Name: "Method", Mode: table.Asc}})
+	tw.SetOutputMirror(os.Stdout)
+	tw.SetStyle(table.StyleColoredBright)
+	tw.Render()
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/maticnetwork/polygon-cli/cmd/rpcfuzz/argfuzz"
 	"github.com/maticnetwork/polygon-cli/cmd/rpcfuzz/testreporter"
 	"github.com/maticnetwork/polygon-cli/rpctypes"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/xeipuuv/gojsonschema"
@@ -148,10 +149,23 @@ var (
 	testFuzzNum           *int
 	seed                  *int64
 	testOutputExportPath  *string
-	testExportJson        *bool
 	testExportCSV         *bool
 	testExportMarkdown    *bool
 	testExportHTML        *bool
+	testStateful          *bool
+	testStatefulAccounts  *int
+	testStatefulTxs       *int
+	testReferenceURL      *string
+	testDiffIgnore        *string
+	testDiffReport        *string
+	testOpenRPC           *string
+	testOpenRPCCases      *int
+	testWSURL             *string
+	testCorpus            *string
+	testReplay            *bool
+	testWSTimeout         *time.Duration
+	testWSCycles          *int
+	testWSSlowDuration    *time.Duration
 	testAccountNonce      uint64
 	testAccountNonceMutex sync.Mutex
 	currentChainID        *big.Int
@@ -1691,13 +1705,18 @@ func CallRPCAndValidate(ctx context.Context, rpcClient *rpc.Client, currTest RPC
 
 	var result interface{}
 	err := rpcClient.CallContext(ctx, &result, currTest.GetMethod(), args...)
+	compareWithReference(ctx, currTest.GetName(), currTest.GetMethod(), args, result, err)
 
 	if err != nil && !currTest.ExpectError() {
-		currTestResult.Fail(args, result, errors.New("Method test failed: "+err.Error()))
+		err = errors.New("Method test failed: " + err.Error())
+		saveCorpusEntry(currTest.GetName(), currTest.GetMethod(), args, result, err)
+		currTestResult.Fail(args, result, err)
 		return currTestResult
 	}
 	if err == nil && currTest.ExpectError() {
-		currTestResult.Fail(args, result, errors.New("Expected an error but didn't get one"))
+		err = errors.New("Expected an error but didn't get one")
+		saveCorpusEntry(currTest.GetName(), currTest.GetMethod(), args, result, err)
+		currTestResult.Fail(args, result, err)
 		return currTestResult
 	}
 
@@ -1708,7 +1727,9 @@ func CallRPCAndValidate(ctx context.Context, rpcClient *rpc.Client, currTest RPC
 	}
 
 	if err != nil {
-		currTestResult.Fail(args, result, errors.New("Failed to validate: "+err.Error()))
+		err = errors.New("Failed to validate: " + err.Error())
+		saveCorpusEntry(currTest.GetName(), currTest.GetMethod(), args, result, err)
+		currTestResult.Fail(args, result, err)
 		return currTestResult
 	}
 
@@ -1718,7 +1739,8 @@ func CallRPCAndValidate(ctx context.Context, rpcClient *rpc.Client, currTest RPC
 }
 
 func CallRPCWithFuzzAndValidate(ctx context.Context, rpcClient *rpc.Client, currTest RPCTest) testreporter.TestResult {
-	currTestResult := testreporter.New(currTest.GetName()+"-FUZZED", currTest.GetMethod(), *testFuzzNum)
+	name := currTest.GetName() + fuzzedSuffix
+	currTestResult := testreporter.New(name, currTest.GetMethod(), *testFuzzNum)
 
 	originalArgs := currTest.GetArgs()
 	for i := 0; i < *testFuzzNum; i++ {
@@ -1727,8 +1749,10 @@ func CallRPCWithFuzzAndValidate(ctx context.Context, rpcClient *rpc.Client, curr
 
 		var result interface{}
 		err := rpcClient.CallContext(ctx, &result, currTest.GetMethod(), args...)
+		compareWithReference(ctx, name, currTest.GetMethod(), args, result, err)
 
 		if err != nil {
+			saveCorpusEntry(name, currTest.GetMethod(), args, result, err)
 			currTestResult.Fail(args, result, err)
 		} else {
 			currTestResult.Pass(args, result, err)
@@ -1781,11 +1805,11 @@ var RPCFuzzCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
-		if *testOutputExportPath != "" && !*testExportJson && !*testExportCSV && !*testExportMarkdown && !*testExportHTML {
+		if *testOutputExportPath != "" && !util.JSONOutput() && !*testExportCSV && !*testExportMarkdown && !*testExportHTML {
 			log.Warn().Msg("Setting --export-path must pair with a export type: --json, --csv, --md, or --html")
 		}
 
-		rpcClient, err := rpc.DialContext(ctx, args[0])
+		rpcClient, err := util.DialRPC(ctx, args[0])
 		if err != nil {
 			return err
 		}
@@ -1800,28 +1824,86 @@ var RPCFuzzCmd = &cobra.Command{
 		testAccountNonce = nonce
 		currentChainID = chainId
 
+		if *testReferenceURL != "" {
+			referenceClient, err = util.DialRPC(ctx, *testReferenceURL)
+			if err != nil {
+				return err
+			}
+			differentialReport.URL = args[0]
+			differentialReport.ReferenceURL = *testReferenceURL
+			log.Info().Str("reference", *testReferenceURL).Msg("Comparing results against the reference endpoint")
+		}
+
 		log.Trace().Uint64("nonce", nonce).Uint64("chainid", chainId.Uint64()).Msg("Doing test setup")
 		setupTests(ctx, rpcClient)
 
-		for _, t := range allTests {
-			if !shouldRunTest(t) {
-				log.Trace().Str("name", t.GetName()).Str("method", t.GetMethod()).Msg("Skipping test")
-				continue
+		var openRPCCoverage []OpenRPCCoverage
+		if *testReplay {
+			log.Info().Str("corpus", *testCorpus).Msg("Replaying the corpus")
+			corpusResults, err := replayCorpus(ctx, rpcClient, *testCorpus)
+			if err != nil {
+				return err
+			}
+			for _, currTestResult := range corpusResults {
+				testResults.AddTestResult(currTestResult)
 			}
-			log.Trace().Str("name", t.GetName()).Str("method", t.GetMethod()).Msg("Running Test")
+		} else {
+			for _, t := range allTests {
+				if !shouldRunTest(t) {
+					log.Trace().Str("name", t.GetName()).Str("method", t.GetMethod()).Msg("Skipping test")
+					continue
+				}
+				log.Trace().Str("name", t.GetName()).Str("method", t.GetMethod()).Msg("Running Test")
+
+				currTestResult := CallRPCAndValidate(ctx, rpcClient, t)
+				testResults.AddTestResult(currTestResult)
+
+				if *testFuzz {
+					fuzzedTestsGroup.Add(1)
 
-			currTestResult := CallRPCAndValidate(ctx, rpcClient, t)
-			testResults.AddTestResult(currTestResult)
+					log.Info().Str("method", t.GetMethod()).Msg("Running with fuzzed args")
+					go func(t RPCTest) {
+						defer fuzzedTestsGroup.Done()
+						currTestResult := CallRPCWithFuzzAndValidate(ctx, rpcClient, t)
+						testResultsCh <- currTestResult
+					}(t)
+				}
+			}
 
-			if *testFuzz {
-				fuzzedTestsGroup.Add(1)
+			if *testStateful && shouldRunTest(&RPCTestGeneric{Method: "eth_sendRawTransaction"}) {
+				log.Info().Int("accounts", *testStatefulAccounts).Int("txs", *testStatefulTxs).Msg("Running stateful tests")
+				for _, currTestResult := range runStatefulTests(ctx, rpcClient) {
+					testResults.AddTestResult(currTestResult)
+				}
+			}
 
-				log.Info().Str("method", t.GetMethod()).Msg("Running with fuzzed args")
-				go func(t RPCTest) {
-					defer fuzzedTestsGroup.Done()
-					currTestResult := CallRPCWithFuzzAndValidate(ctx, rpcClient, t)
-					testResultsCh <- currTestResult
-				}(t)
+			wsURL := *testWSURL
+			if wsURL == "" && isWebSocketURL(args[0]) {
+				wsURL = args[0]
+			}
+			if wsURL != "" && shouldRunTest(&RPCTestGeneric{Method: "eth_subscribe"}) {
+				log.Info().Str("url", wsURL).Msg("Running websocket subscription tests")
+				wsResults, err := runWebSocketTests(ctx, wsURL)
+				if err != nil {
+					return err
+				}
+				for _, currTestResult := range wsResults {
+					testResults.AddTestResult(currTestResult)
+				}
+			}
+
+			if *testOpenRPC != "" {
+				doc, err := loadOpenRPCDocument(ctx, rpcClient, *testOpenRPC)
+				if err != nil {
+					return err
+				}
+				log.Info().Int("methods", len(doc.Methods)).Msg("Running OpenRPC tests")
+
+				var openRPCResults []testreporter.TestResult
+				openRPCResults, openRPCCoverage = runOpenRPCTests(ctx, rpcClient, doc)
+				for _, currTestResult := range openRPCResults {
+					testResults.AddTestResult(currTestResult)
+				}
 			}
 		}
 
@@ -1837,7 +1919,7 @@ var RPCFuzzCmd = &cobra.Command{
 		close(testResultsCh)
 
 		testResults.GenerateTabularResult()
-		if *testExportJson {
+		if util.JSONOutput() {
 			testResults.ExportResultToJSON(filepath.Join(*testOutputExportPath, "output.json"))
 		}
 		if *testExportCSV {
@@ -1851,6 +1933,31 @@ var RPCFuzzCmd = &cobra.Command{
 		}
 		testResults.PrintTabularResult()
 
+		if openRPCCoverage != nil {
+			printOpenRPCCoverage(openRPCCoverage)
+			if util.JSONOutput() {
+				if err = writeJSONFile(filepath.Join(*testOutputExportPath, "coverage.json"), openRPCCoverage); err != nil {
+					return err
+				}
+			}
+		}
+
+		if referenceClient != nil {
+			reportPath := *testDiffReport
+			if reportPath == "" {
+				reportPath = filepath.Join(*testOutputExportPath, "diff.json")
+			}
+			if err = writeDifferentialReport(reportPath); err != nil {
+				return err
+			}
+			log.Info().
+				Int("compared", differentialReport.Compared).
+				Int("skipped", differentialReport.Skipped).
+				Int("divergences", len(differentialReport.Divergences)).
+				Str("report", reportPath).
+				Msg("Wrote differential report")
+		}
+
 		return nil
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
@@ -1878,6 +1985,17 @@ var RPCFuzzCmd = &cobra.Command{
 		}
 		log.Info().Strs("namespaces", enabledNamespaces).Msg("enabling namespaces")
 
+		if *testReplay && *testCorpus == "" {
+			return fmt.Errorf("The --replay flag requires a --corpus directory")
+		}
+
+		if *testDiffIgnore != "" {
+			differentialIgnore, err = regexp.Compile(*testDiffIgnore)
+			if err != nil {
+				return fmt.Errorf("The diff ignore pattern is not valid: %w", err)
+			}
+		}
+
 		testPrivateKey = privateKey
 		testEthAddress = ethAddress
 
@@ -1904,10 +2022,23 @@ func init() {
 	testFuzzNum = flagSet.Int("fuzzn", 100, "Number of times to run the fuzzer per test.")
 	seed = flagSet.Int64("seed", 123456, "A seed for generating random values within the fuzzer")
 	testOutputExportPath = flagSet.String("export-path", "", "The directory export path of the output of the tests. Must pair this with either --json, --csv, --md, or --html")
-	testExportJson = flagSet.Bool("json", false, "Flag to indicate that output will be exported as a JSON.")
 	testExportCSV = flagSet.Bool("csv", false, "Flag to indicate that output will be exported as a CSV.")
 	testExportMarkdown = flagSet.Bool("md", false, "Flag to indicate that output will be exported as a Markdown.")
 	testExportHTML = flagSet.Bool("html", false, "Flag to indicate that output will be exported as a HTML.")
+	testStateful = flagSet.Bool("stateful", false, "Flag to indicate whether to fund generated accounts and fuzz write path methods with valid signed transactions.")
+	testStatefulAccounts = flagSet.Int("stateful-accounts", 3, "Number of accounts to generate and fund for the stateful tests.")
+	testReferenceURL = flagSet.String("reference-url", "", "A reference endpoint that every request is also sent to. Any divergence in results or error codes is written to the differential report.")
+	testDiffIgnore = flagSet.String("diff-ignore", `^(web3_clientVersion|net_peerCount|eth_syncing|eth_coinbase|eth_mining|eth_hashrate|eth_newFilter|eth_newBlockFilter|eth_newPendingTransactionFilter|eth_getFilterChanges|eth_getFilterLogs|eth_uninstallFilter)$`, "A regular expression of methods to exclude from the differential comparison because their results are expected to differ.")
+	testDiffReport = flagSet.String("diff-report", "", "The path of the differential report. Defaults to diff.json in the export path.")
+	testOpenRPC = flagSet.String("openrpc", "", "An OpenRPC document to generate test cases from. This can be a file, a URL, or \"discover\" to use the endpoint's rpc.discover method.")
+	testOpenRPCCases = flagSet.Int("openrpc-cases", 3, "Number of test cases to generate from the schema for each method in the OpenRPC document, in addition to its examples.")
+	testWSURL = flagSet.String("ws-url", "", "A websocket endpoint used to fuzz eth_subscribe and eth_unsubscribe. Defaults to the RPC URL if it's a websocket URL.")
+	testWSTimeout = flagSet.Duration("ws-timeout", 30*time.Second, "How long to wait for subscription notifications and responses in the websocket tests.")
+	testWSCycles = flagSet.Int("ws-cycles", 100, "Number of concurrent subscribe and unsubscribe cycles in the websocket tests.")
+	testWSSlowDuration = flagSet.Duration("ws-slow-duration", 10*time.Second, "How long the slow reader stops reading subscription notifications in the websocket tests.")
+	testCorpus = flagSet.String("corpus", "", "A directory where failing inputs are saved along with the seed so they can be replayed.")
+	testReplay = flagSet.Bool("replay", false, "Flag to indicate whether to replay the inputs in the corpus directory instead of running the tests, and report the ones that still fail.")
+	testStatefulTxs = flagSet.Int("stateful-txs", 5, "Number of transactions to send from each generated account in the stateful tests.")
 
 	argfuzz.SetSeed(seed)
 
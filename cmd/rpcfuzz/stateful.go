@@ -0,0 +1,330 @@
+package rpcfuzz
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/cmd/rpcfuzz/testreporter"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// statefulMaxDataLength is the largest random calldata attached to a
+	// generated transaction.
+	statefulMaxDataLength = 64
+
+	// statefulMaxGas is enough gas for a transfer to an account without code
+	// with the largest calldata, assuming every byte is non-zero.
+	statefulMaxGas = 21000 + 16*statefulMaxDataLength
+)
+
+var (
+	// statefulMaxValue is the largest random value sent in a generated
+	// transaction.
+	statefulMaxValue = big.NewInt(1_000_000_000_000)
+)
+
+type (
+	// statefulAccount is a generated account that is funded by the test
+	// account and used to send valid signed transactions.
+	statefulAccount struct {
+		key     *ecdsa.PrivateKey
+		address ethcommon.Address
+		nonce   uint64
+	}
+
+	// statefulFuzzer generates funded accounts and valid transactions so the
+	// write path methods can be fuzzed and the node responses can be checked
+	// against the expected state changes.
+	statefulFuzzer struct {
+		rpcClient *rpc.Client
+		rng       *rand.Rand
+		accounts  []*statefulAccount
+		feeCap    *big.Int
+		tipCap    *big.Int
+		fund      *big.Int
+	}
+)
+
+// runStatefulTests funds the generated accounts and runs the stateful tests.
+// The results are returned so they can be reported alongside the other tests.
+func runStatefulTests(ctx context.Context, rpcClient *rpc.Client) []testreporter.TestResult {
+	s := &statefulFuzzer{
+		rpcClient: rpcClient,
+		rng:       rand.New(rand.NewSource(*seed)),
+	}
+
+	var gasPrice hexutil.Big
+	if err := rpcClient.CallContext(ctx, &gasPrice, "eth_gasPrice"); err != nil {
+		log.Error().Err(err).Msg("Unable to get the gas price for the stateful tests")
+		return nil
+	}
+	s.tipCap = gasPrice.ToInt()
+	s.feeCap = new(big.Int).Mul(s.tipCap, big.NewInt(2))
+
+	// Fund each account with enough to pay for all of its transactions.
+	perTx := new(big.Int).Mul(big.NewInt(statefulMaxGas), s.feeCap)
+	perTx.Add(perTx, statefulMaxValue)
+	s.fund = new(big.Int).Mul(perTx, big.NewInt(int64(*testStatefulTxs)))
+
+	for i := 0; i < *testStatefulAccounts; i++ {
+		s.accounts = append(s.accounts, s.newAccount())
+	}
+
+	results := []testreporter.TestResult{s.fundAccounts(ctx)}
+	results = append(results, s.testSendRawTransaction(ctx)...)
+	results = append(results, s.testInsufficientFunds(ctx))
+	results = append(results, s.testCallStateOverride(ctx))
+
+	return results
+}
+
+// newAccount generates an account from the seeded random source so the same
+// seed produces the same accounts.
+func (s *statefulFuzzer) newAccount() *statefulAccount {
+	for {
+		b := make([]byte, 32)
+		s.rng.Read(b)
+		key, err := ethcrypto.ToECDSA(b)
+		if err != nil {
+			continue
+		}
+		return &statefulAccount{key: key, address: ethcrypto.PubkeyToAddress(key.PublicKey)}
+	}
+}
+
+// randomAddress returns an address that almost certainly has no balance,
+// code, or history, so its balance after a transfer is exactly the value.
+func (s *statefulFuzzer) randomAddress() ethcommon.Address {
+	var address ethcommon.Address
+	s.rng.Read(address[:])
+	return address
+}
+
+func (s *statefulFuzzer) randomValue() *big.Int {
+	return new(big.Int).Add(new(big.Int).Rand(s.rng, statefulMaxValue), big.NewInt(1))
+}
+
+func (s *statefulFuzzer) randomData() []byte {
+	data := make([]byte, s.rng.Intn(statefulMaxDataLength+1))
+	s.rng.Read(data)
+	return data
+}
+
+// signTx signs a dynamic fee transaction from the account with its next
+// nonce.
+func (s *statefulFuzzer) signTx(account *statefulAccount, to ethcommon.Address, value *big.Int, data []byte) (*ethtypes.Transaction, error) {
+	tx := &ethtypes.DynamicFeeTx{
+		ChainID:   currentChainID,
+		Nonce:     account.nonce,
+		GasTipCap: s.tipCap,
+		GasFeeCap: s.feeCap,
+		Gas:       statefulMaxGas,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	}
+	return ethtypes.SignNewTx(account.key, ethtypes.NewLondonSigner(currentChainID), tx)
+}
+
+// fundAccounts sends the funds for every generated account from the test
+// account and checks the balances afterwards.
+func (s *statefulFuzzer) fundAccounts(ctx context.Context) testreporter.TestResult {
+	result := testreporter.New("RPCTestStatefulFundAccounts", "eth_sendRawTransaction", len(s.accounts))
+
+	for _, account := range s.accounts {
+		tx := &RPCTestTransactionArgs{
+			To:                   account.address.String(),
+			Gas:                  hexutil.EncodeUint64(21000),
+			MaxFeePerGas:         hexutil.EncodeBig(s.feeCap),
+			MaxPriorityFeePerGas: hexutil.EncodeBig(s.tipCap),
+			Value:                hexutil.EncodeBig(s.fund),
+			Data:                 "0x",
+		}
+		args := []interface{}{tx}
+
+		hash, receipt, err := prepareAndSendTransaction(ctx, s.rpcClient, tx)
+		if err != nil {
+			result.Fail(args, nil, err)
+			continue
+		}
+
+		var balance hexutil.Big
+		if err = s.rpcClient.CallContext(ctx, &balance, "eth_getBalance", account.address, receipt["blockNumber"]); err != nil {
+			result.Fail(args, hash, err)
+			continue
+		}
+		if balance.ToInt().Cmp(s.fund) != 0 {
+			result.Fail(args, hash, fmt.Errorf("expected balance %s but got %s", s.fund, balance.ToInt()))
+			continue
+		}
+		result.Pass(args, hash, nil)
+	}
+
+	return result
+}
+
+// testSendRawTransaction sends random valid transactions from the generated
+// accounts and checks the returned hash, the receipt, the recipient's
+// balance, and the sender's nonce. Every transaction is also resent, which
+// should be rejected.
+func (s *statefulFuzzer) testSendRawTransaction(ctx context.Context) []testreporter.TestResult {
+	sent := testreporter.New("RPCTestStatefulSendRawTransaction", "eth_sendRawTransaction", len(s.accounts)**testStatefulTxs)
+	replayed := testreporter.New("RPCTestStatefulSendRawTransactionReplay", "eth_sendRawTransaction", len(s.accounts)**testStatefulTxs)
+
+	for _, account := range s.accounts {
+		for i := 0; i < *testStatefulTxs; i++ {
+			to, value, data := s.randomAddress(), s.randomValue(), s.randomData()
+			tx, err := s.signTx(account, to, value, data)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to sign stateful transaction")
+				sent.Fail(nil, nil, err)
+				replayed.Fail(nil, nil, err)
+				continue
+			}
+			raw, err := tx.MarshalBinary()
+			if err != nil {
+				sent.Fail(nil, nil, err)
+				replayed.Fail(nil, nil, err)
+				continue
+			}
+			args := []interface{}{hexutil.Encode(raw)}
+
+			hash, receipt, err := executeRawTxAndWait(ctx, s.rpcClient, raw)
+			if err != nil {
+				sent.Fail(args, nil, err)
+				replayed.Fail(args, nil, errors.New("skipped because the transaction failed"))
+				continue
+			}
+			account.nonce++
+
+			if err = s.verifyTransaction(ctx, tx, account, hash, receipt); err != nil {
+				sent.Fail(args, hash, err)
+			} else {
+				sent.Pass(args, hash, nil)
+			}
+
+			var replayHash interface{}
+			if err = s.rpcClient.CallContext(ctx, &replayHash, "eth_sendRawTransaction", args...); err == nil {
+				replayed.Fail(args, replayHash, errors.New("expected an error when resending a mined transaction"))
+			} else {
+				replayed.Pass(args, replayHash, err)
+			}
+		}
+	}
+
+	return []testreporter.TestResult{sent, replayed}
+}
+
+func (s *statefulFuzzer) verifyTransaction(ctx context.Context, tx *ethtypes.Transaction, account *statefulAccount, hash string, receipt map[string]interface{}) error {
+	if hash != tx.Hash().Hex() {
+		return fmt.Errorf("expected transaction hash %s but got %s", tx.Hash().Hex(), hash)
+	}
+	if receipt["status"] != "0x1" {
+		return fmt.Errorf("expected a successful receipt but got status %v", receipt["status"])
+	}
+
+	var balance hexutil.Big
+	if err := s.rpcClient.CallContext(ctx, &balance, "eth_getBalance", tx.To(), receipt["blockNumber"]); err != nil {
+		return err
+	}
+	if balance.ToInt().Cmp(tx.Value()) != 0 {
+		return fmt.Errorf("expected recipient balance %s but got %s", tx.Value(), balance.ToInt())
+	}
+
+	var nonce hexutil.Uint64
+	if err := s.rpcClient.CallContext(ctx, &nonce, "eth_getTransactionCount", account.address, receipt["blockNumber"]); err != nil {
+		return err
+	}
+	if uint64(nonce) != account.nonce {
+		return fmt.Errorf("expected sender nonce %d but got %d", account.nonce, nonce)
+	}
+
+	return nil
+}
+
+// testInsufficientFunds sends a transaction worth more than the sender's
+// balance, which should be rejected.
+func (s *statefulFuzzer) testInsufficientFunds(ctx context.Context) testreporter.TestResult {
+	result := testreporter.New("RPCTestStatefulSendRawTransactionInsufficientFunds", "eth_sendRawTransaction", len(s.accounts))
+
+	for _, account := range s.accounts {
+		value := new(big.Int).Mul(s.fund, big.NewInt(2))
+		tx, err := s.signTx(account, s.randomAddress(), value, nil)
+		if err != nil {
+			result.Fail(nil, nil, err)
+			continue
+		}
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			result.Fail(nil, nil, err)
+			continue
+		}
+		args := []interface{}{hexutil.Encode(raw)}
+
+		var hash interface{}
+		if err = s.rpcClient.CallContext(ctx, &hash, "eth_sendRawTransaction", args...); err == nil {
+			result.Fail(args, hash, errors.New("expected an error when sending more than the balance"))
+			continue
+		}
+		result.Pass(args, hash, err)
+	}
+
+	return result
+}
+
+// testCallStateOverride calls a contract injected with a state override that
+// returns either the overridden balance of a random address or an overridden
+// storage slot, and checks the returned value matches the override.
+func (s *statefulFuzzer) testCallStateOverride(ctx context.Context) testreporter.TestResult {
+	runs := len(s.accounts) * *testStatefulTxs
+	result := testreporter.New("RPCTestStatefulCallStateOverride", "eth_call", runs)
+
+	for i := 0; i < runs; i++ {
+		contract := s.randomAddress()
+		expected := ethcommon.BigToHash(s.randomValue())
+
+		var code []byte
+		overrides := map[ethcommon.Address]map[string]interface{}{}
+		if i%2 == 0 {
+			// PUSH20 <address> BALANCE PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+			target := s.randomAddress()
+			code = append([]byte{0x73}, target.Bytes()...)
+			code = append(code, 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+			overrides[target] = map[string]interface{}{"balance": hexutil.EncodeBig(expected.Big())}
+			overrides[contract] = map[string]interface{}{"code": hexutil.Encode(code)}
+		} else {
+			// PUSH1 0 SLOAD PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+			code = []byte{0x60, 0x00, 0x54, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+			overrides[contract] = map[string]interface{}{
+				"code":      hexutil.Encode(code),
+				"stateDiff": map[string]string{ethcommon.Hash{}.Hex(): expected.Hex()},
+			}
+		}
+
+		call := map[string]interface{}{"to": contract, "data": "0x"}
+		args := []interface{}{call, "latest", overrides}
+
+		var returned hexutil.Bytes
+		if err := s.rpcClient.CallContext(ctx, &returned, "eth_call", args...); err != nil {
+			result.Fail(args, nil, err)
+			continue
+		}
+		if ethcommon.BytesToHash(returned) != expected || len(returned) != ethcommon.HashLength {
+			result.Fail(args, returned, fmt.Errorf("expected %s but got %s", expected.Hex(), returned))
+			continue
+		}
+		result.Pass(args, returned, nil)
+	}
+
+	return result
+}
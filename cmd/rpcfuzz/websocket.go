@@ -0,0 +1,315 @@
+package rpcfuzz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/maticnetwork/polygon-cli/cmd/rpcfuzz/testreporter"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+)
+
+// wsSubscriptionKinds are the subscriptions that should be supported by every
+// client. Each one is subscribed to with valid params.
+var wsSubscriptionKinds = []struct {
+	Kind string
+	Args []interface{}
+}{
+	{Kind: "newHeads"},
+	{Kind: "logs", Args: []interface{}{RPCTestFilterArgs{}}},
+	{Kind: "newPendingTransactions"},
+}
+
+// wsMalformedSubscriptions are eth_subscribe params that should be rejected.
+var wsMalformedSubscriptions = [][]interface{}{
+	{},
+	{nil},
+	{"newHeadz"},
+	{123},
+	{[]interface{}{"newHeads"}},
+	{"logs", map[string]interface{}{"address": "0xzz"}},
+	{"logs", map[string]interface{}{"fromBlock": true}},
+	{"logs", map[string]interface{}{"topics": "0x00"}},
+	{"newHeads", "unexpected", "params"},
+}
+
+// isWebSocketURL returns true if the URL uses the websocket transport.
+func isWebSocketURL(url string) bool {
+	return strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://")
+}
+
+// runWebSocketTests runs the subscription tests against the websocket
+// endpoint.
+func runWebSocketTests(ctx context.Context, wsURL string) ([]testreporter.TestResult, error) {
+	wsClient, err := util.DialRPC(ctx, wsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer wsClient.Close()
+
+	var results []testreporter.TestResult
+	for _, s := range wsSubscriptionKinds {
+		results = append(results, testWSSubscribe(ctx, wsClient, s.Kind, s.Args))
+	}
+	results = append(results,
+		testWSSubscribeMalformed(ctx, wsClient),
+		testWSUnsubscribeUnknown(ctx, wsClient),
+		testWSSubscribeCycles(ctx, wsClient),
+		testWSSlowReader(ctx, wsURL),
+	)
+	if *testFuzz {
+		results = append(results, testWSSubscribeFuzzed(ctx, wsClient))
+	}
+
+	return results, nil
+}
+
+// testWSSubscribe subscribes with valid params and unsubscribes. For new
+// heads, a notification is also expected within the timeout.
+func testWSSubscribe(ctx context.Context, wsClient *rpc.Client, kind string, extraArgs []interface{}) testreporter.TestResult {
+	result := testreporter.New("RPCTestWSSubscribe-"+kind, "eth_subscribe", 1)
+	args := append([]interface{}{kind}, extraArgs...)
+
+	ch := make(chan json.RawMessage, 16)
+	sub, err := wsClient.EthSubscribe(ctx, ch, args...)
+	if err != nil {
+		result.Fail(args, nil, err)
+		return result
+	}
+	defer sub.Unsubscribe()
+
+	if kind != "newHeads" {
+		result.Pass(args, nil, nil)
+		return result
+	}
+
+	select {
+	case msg := <-ch:
+		var header map[string]interface{}
+		if err = json.Unmarshal(msg, &header); err != nil {
+			result.Fail(args, string(msg), err)
+		} else if header["hash"] == nil || header["number"] == nil {
+			result.Fail(args, header, errors.New("Notification is missing the hash or number"))
+		} else {
+			result.Pass(args, header, nil)
+		}
+	case err = <-sub.Err():
+		result.Fail(args, nil, fmt.Errorf("Subscription failed: %w", err))
+	case <-time.After(*testWSTimeout):
+		result.Fail(args, nil, fmt.Errorf("No new head was received within %s", *testWSTimeout))
+	}
+
+	return result
+}
+
+// testWSSubscribeMalformed expects an error for each of the malformed params.
+// Any subscription that's created anyway is removed.
+func testWSSubscribeMalformed(ctx context.Context, wsClient *rpc.Client) testreporter.TestResult {
+	result := testreporter.New("RPCTestWSSubscribeMalformed", "eth_subscribe", len(wsMalformedSubscriptions))
+
+	for _, args := range wsMalformedSubscriptions {
+		var id interface{}
+		err := wsClient.CallContext(ctx, &id, "eth_subscribe", args...)
+		if err == nil {
+			wsUnsubscribe(ctx, wsClient, id)
+			result.Fail(args, id, errors.New("Expected an error but didn't get one"))
+			continue
+		}
+		result.Pass(args, id, err)
+	}
+
+	return result
+}
+
+// testWSSubscribeFuzzed mutates valid subscription params. The node may
+// accept or reject them, but the connection should survive.
+func testWSSubscribeFuzzed(ctx context.Context, wsClient *rpc.Client) testreporter.TestResult {
+	result := testreporter.New("RPCTestWSSubscribe-FUZZED", "eth_subscribe", *testFuzzNum)
+
+	for i := 0; i < *testFuzzNum; i++ {
+		s := wsSubscriptionKinds[i%len(wsSubscriptionKinds)]
+		args := append([]interface{}{s.Kind}, s.Args...)
+		fuzzer.Fuzz(&args)
+
+		var id interface{}
+		err := wsClient.CallContext(ctx, &id, "eth_subscribe", args...)
+		var rpcErr rpc.Error
+		switch {
+		case err == nil:
+			wsUnsubscribe(ctx, wsClient, id)
+			result.Pass(args, id, nil)
+		case errors.As(err, &rpcErr):
+			result.Pass(args, id, err)
+		default:
+			result.Fail(args, id, fmt.Errorf("Connection failed: %w", err))
+		}
+	}
+
+	return result
+}
+
+// testWSUnsubscribeUnknown expects unsubscribing from a subscription that
+// doesn't exist to return false or an error.
+func testWSUnsubscribeUnknown(ctx context.Context, wsClient *rpc.Client) testreporter.TestResult {
+	result := testreporter.New("RPCTestWSUnsubscribeUnknown", "eth_unsubscribe", 1)
+	args := []interface{}{"0x2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a"}
+
+	var ok interface{}
+	err := wsClient.CallContext(ctx, &ok, "eth_unsubscribe", args...)
+	if err == nil && ok != false {
+		result.Fail(args, ok, errors.New("Expected false or an error when unsubscribing from an unknown subscription"))
+		return result
+	}
+	result.Pass(args, ok, err)
+	return result
+}
+
+// testWSSubscribeCycles subscribes and unsubscribes rapidly from concurrent
+// workers. Every unsubscribe should succeed once, and a second unsubscribe
+// should return false or an error.
+func testWSSubscribeCycles(ctx context.Context, wsClient *rpc.Client) testreporter.TestResult {
+	result := testreporter.New("RPCTestWSSubscribeUnsubscribeCycles", "eth_subscribe", *testWSCycles)
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		jobs  = make(chan int)
+	)
+	for w := 0; w < 10; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				args, id, err := wsSubscribeCycle(ctx, wsClient)
+				mutex.Lock()
+				if err != nil {
+					result.Fail(args, id, err)
+				} else {
+					result.Pass(args, id, nil)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < *testWSCycles; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return result
+}
+
+func wsSubscribeCycle(ctx context.Context, wsClient *rpc.Client) ([]interface{}, interface{}, error) {
+	args := []interface{}{"newHeads"}
+
+	var id interface{}
+	if err := wsClient.CallContext(ctx, &id, "eth_subscribe", args...); err != nil {
+		return args, nil, err
+	}
+
+	var ok interface{}
+	if err := wsClient.CallContext(ctx, &ok, "eth_unsubscribe", id); err != nil {
+		return args, id, fmt.Errorf("Unable to unsubscribe: %w", err)
+	}
+	if ok != true {
+		return args, id, fmt.Errorf("Expected true when unsubscribing but got %v", ok)
+	}
+
+	err := wsClient.CallContext(ctx, &ok, "eth_unsubscribe", id)
+	if err == nil && ok != false {
+		return args, id, errors.New("Unsubscribing twice should return false or an error")
+	}
+	return args, id, nil
+}
+
+func wsUnsubscribe(ctx context.Context, wsClient *rpc.Client, id interface{}) {
+	var ok interface{}
+	if err := wsClient.CallContext(ctx, &ok, "eth_unsubscribe", id); err != nil {
+		log.Debug().Err(err).Interface("id", id).Msg("Unable to unsubscribe")
+	}
+}
+
+// testWSSlowReader subscribes on a raw websocket connection and stops reading
+// for a while so the node has to apply backpressure. Afterwards the buffered
+// messages are drained and the connection should still answer requests. A
+// node may drop a slow subscriber, in which case the close is recorded as the
+// result rather than a failure.
+func testWSSlowReader(ctx context.Context, wsURL string) testreporter.TestResult {
+	result := testreporter.New("RPCTestWSSlowReader", "eth_subscribe", 1)
+	args := []interface{}{"newHeads", "newPendingTransactions"}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		result.Fail(args, nil, err)
+		return result
+	}
+	defer conn.Close()
+
+	for i, kind := range []string{"newHeads", "newPendingTransactions"} {
+		req := map[string]interface{}{"jsonrpc": "2.0", "id": i + 1, "method": "eth_subscribe", "params": []interface{}{kind}}
+		if err = conn.WriteJSON(req); err != nil {
+			result.Fail(args, nil, err)
+			return result
+		}
+	}
+
+	log.Info().Dur("duration", *testWSSlowDuration).Msg("Pausing reads to test backpressure")
+	time.Sleep(*testWSSlowDuration)
+
+	// Send a request after the pause and drain messages until its response
+	// arrives.
+	const chainIDRequest = 100
+	if err = conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": chainIDRequest, "method": "eth_chainId", "params": []interface{}{}}); err != nil {
+		result.Pass(args, nil, fmt.Errorf("The node closed the slow connection: %w", err))
+		return result
+	}
+
+	notifications := 0
+	deadline := time.Now().Add(*testWSTimeout)
+	for {
+		if err = conn.SetReadDeadline(deadline); err != nil {
+			result.Fail(args, nil, err)
+			return result
+		}
+
+		var msg struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Error  *RPCJSONError   `json:"error"`
+		}
+		if err = conn.ReadJSON(&msg); err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				result.Pass(args, notifications, fmt.Errorf("The node closed the slow connection: %w", err))
+			} else {
+				result.Fail(args, notifications, fmt.Errorf("Connection failed after draining %d notifications: %w", notifications, err))
+			}
+			return result
+		}
+
+		if msg.Method == "eth_subscription" {
+			notifications++
+			continue
+		}
+		if msg.ID == nil || *msg.ID != chainIDRequest {
+			continue
+		}
+		if msg.Error != nil {
+			result.Fail(args, notifications, msg.Error)
+			return result
+		}
+
+		log.Info().Int("notifications", notifications).Msg("Drained notifications from the slow connection")
+		result.Pass(args, notifications, nil)
+		return result
+	}
+}
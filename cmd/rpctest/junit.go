@@ -0,0 +1,100 @@
+package rpctest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type (
+	// junitReport is the root testsuites element of a JUnit XML report. Each
+	// conformance suite is a testsuite. It's printed as is with --json.
+	junitReport struct {
+		XMLName  xml.Name         `xml:"testsuites" json:"-"`
+		Name     string           `xml:"name,attr" json:"name"`
+		Tests    int              `xml:"tests,attr" json:"tests"`
+		Failures int              `xml:"failures,attr" json:"failures"`
+		Skipped  int              `xml:"skipped,attr" json:"skipped"`
+		Time     string           `xml:"time,attr" json:"time"`
+		Suites   []junitTestSuite `xml:"testsuite" json:"suites"`
+	}
+	junitTestSuite struct {
+		Name      string          `xml:"name,attr" json:"name"`
+		Tests     int             `xml:"tests,attr" json:"tests"`
+		Failures  int             `xml:"failures,attr" json:"failures"`
+		Skipped   int             `xml:"skipped,attr" json:"skipped"`
+		Time      string          `xml:"time,attr" json:"time"`
+		TestCases []junitTestCase `xml:"testcase" json:"testCases"`
+	}
+	junitTestCase struct {
+		Name      string        `xml:"name,attr" json:"name"`
+		ClassName string        `xml:"classname,attr" json:"className"`
+		Time      string        `xml:"time,attr" json:"time"`
+		Failure   *junitMessage `xml:"failure,omitempty" json:"failure,omitempty"`
+		Skipped   *junitMessage `xml:"skipped,omitempty" json:"skipped,omitempty"`
+	}
+	junitMessage struct {
+		Message string `xml:"message,attr" json:"message"`
+	}
+)
+
+// newJUnitReport groups the results by suite, keeping the order the suites
+// were run in.
+func newJUnitReport(url string, results []testResult) *junitReport {
+	report := &junitReport{Name: "rpctest " + url}
+	suiteIndex := make(map[string]int)
+
+	var total float64
+	suiteTimes := make(map[string]float64)
+	for _, r := range results {
+		i, ok := suiteIndex[r.Suite]
+		if !ok {
+			i = len(report.Suites)
+			suiteIndex[r.Suite] = i
+			report.Suites = append(report.Suites, junitTestSuite{Name: r.Suite})
+		}
+		suite := &report.Suites[i]
+
+		seconds := r.Duration.Seconds()
+		tc := junitTestCase{Name: r.Name, ClassName: "rpctest." + r.Suite, Time: formatSeconds(seconds)}
+		switch {
+		case r.Skipped:
+			tc.Skipped = &junitMessage{Message: r.Err.Error()}
+			suite.Skipped++
+			report.Skipped++
+		case r.Err != nil:
+			tc.Failure = &junitMessage{Message: r.Err.Error()}
+			suite.Failures++
+			report.Failures++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+		report.Tests++
+
+		suiteTimes[r.Suite] += seconds
+		total += seconds
+	}
+
+	for i := range report.Suites {
+		report.Suites[i].Time = formatSeconds(suiteTimes[report.Suites[i].Name])
+	}
+	report.Time = formatSeconds(total)
+	return report
+}
+
+func (r *junitReport) write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func formatSeconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
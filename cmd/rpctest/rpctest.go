@@ -0,0 +1,147 @@
+package rpctest
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	//go:embed usage.md
+	usage string
+
+	junitFile     *string
+	suites        *string
+	txSearchDepth *uint64
+	testTimeout   *time.Duration
+
+	enabledSuites map[string]bool
+)
+
+// RPCTestCmd represents the rpctest command.
+var RPCTestCmd = &cobra.Command{
+	Use:   "rpctest http://localhost:8545",
+	Short: "Run a conformance suite against an RPC endpoint.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Expected 1 argument, but got %d", len(args))
+		}
+
+		enabledSuites = make(map[string]bool)
+		for _, s := range strings.Split(*suites, ",") {
+			s = strings.TrimSpace(s)
+			if _, ok := knownSuites[s]; !ok {
+				return fmt.Errorf("The suite %s is not valid", s)
+			}
+			enabledSuites[s] = true
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		rpc, err := util.DialRPC(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		defer rpc.Close()
+
+		env, err := newTestEnv(ctx, rpc)
+		if err != nil {
+			return err
+		}
+
+		results := runConformanceTests(ctx, env)
+
+		report := newJUnitReport(args[0], results)
+		if *junitFile != "" {
+			f, err := os.Create(*junitFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err = report.write(f); err != nil {
+				return err
+			}
+			log.Info().Str("file", *junitFile).Msg("Wrote JUnit report")
+		}
+		if util.JSONOutput() {
+			if err = util.PrintJSON(report); err != nil {
+				return err
+			}
+		} else if *junitFile == "" {
+			if err = report.write(os.Stdout); err != nil {
+				return err
+			}
+		}
+
+		log.Info().
+			Int("tests", report.Tests).
+			Int("failures", report.Failures).
+			Int("skipped", report.Skipped).
+			Msg("Finished the conformance tests")
+		if report.Failures > 0 {
+			return util.AssertionFailure(fmt.Errorf("%d of %d conformance tests failed", report.Failures, report.Tests))
+		}
+		return nil
+	},
+}
+
+// testResult is the outcome of a single conformance test.
+type testResult struct {
+	Suite    string
+	Name     string
+	Duration time.Duration
+	Err      error
+	Skipped  bool
+}
+
+// runConformanceTests runs every test in the enabled suites in order.
+func runConformanceTests(ctx context.Context, env *testEnv) []testResult {
+	results := make([]testResult, 0, len(conformanceTests))
+	for _, t := range conformanceTests {
+		if !enabledSuites[t.Suite] {
+			continue
+		}
+
+		testCtx, cancel := context.WithTimeout(ctx, *testTimeout)
+		start := time.Now()
+		err := t.Run(testCtx, env)
+		cancel()
+
+		result := testResult{Suite: t.Suite, Name: t.Name, Duration: time.Since(start), Err: err}
+		var skip *skipError
+		if errors.As(err, &skip) {
+			result.Skipped = true
+		}
+
+		switch {
+		case result.Skipped:
+			log.Info().Str("suite", t.Suite).Str("name", t.Name).Str("reason", err.Error()).Msg("Skipped")
+		case err != nil:
+			log.Error().Str("suite", t.Suite).Str("name", t.Name).Err(err).Msg("Failed")
+		default:
+			log.Info().Str("suite", t.Suite).Str("name", t.Name).Msg("Passed")
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func init() {
+	flagSet := RPCTestCmd.PersistentFlags()
+
+	junitFile = flagSet.String("junit", "", "The file to write the JUnit XML report to. The report is written to stdout if this is empty, unless --json is set.")
+	suites = flagSet.String("suites", "blocks,transactions,state,errors", "Comma separated list of suites to run.")
+	txSearchDepth = flagSet.Uint64("tx-search-depth", 100, "Number of recent blocks to search for a transaction to use in the transaction tests.")
+	testTimeout = flagSet.Duration("timeout", 30*time.Second, "The timeout for each test.")
+}
@@ -0,0 +1,609 @@
+package rpctest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/maticnetwork/polygon-cli/rpctypes"
+)
+
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+
+	unknownHash = "0x2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a"
+	zeroAddress = "0x0000000000000000000000000000000000000000"
+)
+
+var (
+	quantityRegex = regexp.MustCompile(`^0x(0|[1-9a-f][0-9a-f]*)$`)
+	dataRegex     = regexp.MustCompile(`^0x([0-9a-f]{2})*$`)
+
+	knownSuites = map[string]struct{}{
+		"blocks":       {},
+		"transactions": {},
+		"state":        {},
+		"errors":       {},
+	}
+)
+
+type (
+	// conformanceTest is a single assertion against the endpoint. Run returns
+	// a skipError when the test can't run against this endpoint.
+	conformanceTest struct {
+		Suite string
+		Name  string
+		Run   func(ctx context.Context, env *testEnv) error
+	}
+
+	// testEnv is the state shared by the tests. The block is the head when
+	// the run started so the tests agree on it, and the transaction is the
+	// first one found in the recent blocks, if any.
+	testEnv struct {
+		rpc     *ethrpc.Client
+		head    uint64
+		chainID string
+		block   map[string]interface{}
+		tx      map[string]interface{}
+	}
+
+	skipError struct {
+		reason string
+	}
+)
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+func skip(format string, args ...interface{}) error {
+	return &skipError{reason: fmt.Sprintf(format, args...)}
+}
+
+// newTestEnv fetches the head block and searches the recent blocks for a
+// transaction.
+func newTestEnv(ctx context.Context, rpc *ethrpc.Client) (*testEnv, error) {
+	env := &testEnv{rpc: rpc}
+
+	var head hexutil.Uint64
+	if err := rpc.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return nil, err
+	}
+	env.head = uint64(head)
+
+	if err := rpc.CallContext(ctx, &env.chainID, "eth_chainId"); err != nil {
+		return nil, err
+	}
+
+	var err error
+	env.block, err = env.getObject(ctx, "eth_getBlockByNumber", hexutil.EncodeUint64(env.head), false)
+	if err != nil {
+		return nil, err
+	}
+	if env.block == nil {
+		return nil, fmt.Errorf("Unable to get the head block %d", env.head)
+	}
+
+	for i := uint64(0); i < *txSearchDepth && i <= env.head; i++ {
+		block, err := env.getObject(ctx, "eth_getBlockByNumber", hexutil.EncodeUint64(env.head-i), true)
+		if err != nil {
+			return nil, err
+		}
+		txs, _ := block["transactions"].([]interface{})
+		if len(txs) == 0 {
+			continue
+		}
+		env.tx, _ = txs[0].(map[string]interface{})
+		log.Debug().Interface("hash", env.tx["hash"]).Uint64("block", env.head-i).Msg("Found a transaction for the transaction tests")
+		break
+	}
+
+	return env, nil
+}
+
+// getObject calls a method that returns an object or null.
+func (env *testEnv) getObject(ctx context.Context, method string, args ...interface{}) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := env.rpc.CallContext(ctx, &result, method, args...)
+	return result, err
+}
+
+// requireTx returns the transaction found in the recent blocks or a skip.
+func (env *testEnv) requireTx() (map[string]interface{}, error) {
+	if env.tx == nil {
+		return nil, skip("No transaction in the last %d blocks", *txSearchDepth)
+	}
+	return env.tx, nil
+}
+
+// conformanceTests is the curated suite. The tests are read only so they're
+// safe to run against production endpoints.
+var conformanceTests = []conformanceTest{
+	{Suite: "blocks", Name: "BlockNumberIsQuantity", Run: func(ctx context.Context, env *testEnv) error {
+		var result string
+		if err := env.rpc.CallContext(ctx, &result, "eth_blockNumber"); err != nil {
+			return err
+		}
+		return checkQuantity("result", result)
+	}},
+	{Suite: "blocks", Name: "ChainIDMatchesNetVersion", Run: func(ctx context.Context, env *testEnv) error {
+		if err := checkQuantity("eth_chainId", env.chainID); err != nil {
+			return err
+		}
+		var version string
+		if err := env.rpc.CallContext(ctx, &version, "net_version"); err != nil {
+			return err
+		}
+		chainID, err := hexutil.DecodeBig(env.chainID)
+		if err != nil {
+			return err
+		}
+		if version != chainID.String() {
+			return fmt.Errorf("net_version %s doesn't match eth_chainId %s", version, chainID)
+		}
+		return nil
+	}},
+	{Suite: "blocks", Name: "BlockByNumberEncoding", Run: func(ctx context.Context, env *testEnv) error {
+		if err := validateSchema(rpctypes.RPCSchemaEthBlock, env.block); err != nil {
+			return err
+		}
+		return checkBlockFields(env.block)
+	}},
+	{Suite: "blocks", Name: "BlockByNumberHasTransactionHashes", Run: func(ctx context.Context, env *testEnv) error {
+		txs, ok := env.block["transactions"].([]interface{})
+		if !ok {
+			return errors.New("transactions is not an array")
+		}
+		for i, tx := range txs {
+			hash, ok := tx.(string)
+			if !ok {
+				return fmt.Errorf("transactions[%d] is not a hash", i)
+			}
+			if err := checkData(fmt.Sprintf("transactions[%d]", i), hash, 32); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Suite: "blocks", Name: "BlockByHashMatchesBlockByNumber", Run: func(ctx context.Context, env *testEnv) error {
+		block, err := env.getObject(ctx, "eth_getBlockByHash", env.block["hash"], false)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(block, env.block) {
+			return fmt.Errorf("eth_getBlockByHash returned a different block than eth_getBlockByNumber for %v", env.block["hash"])
+		}
+		return nil
+	}},
+	{Suite: "blocks", Name: "FullTransactionsMatchBlock", Run: func(ctx context.Context, env *testEnv) error {
+		block, err := env.getObject(ctx, "eth_getBlockByHash", env.block["hash"], true)
+		if err != nil {
+			return err
+		}
+		txs, _ := block["transactions"].([]interface{})
+		for i, raw := range txs {
+			tx, ok := raw.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("transactions[%d] is not an object", i)
+			}
+			if tx["blockHash"] != block["hash"] || tx["blockNumber"] != block["number"] {
+				return fmt.Errorf("transactions[%d] has block %v %v but is in block %v %v", i, tx["blockNumber"], tx["blockHash"], block["number"], block["hash"])
+			}
+			if tx["transactionIndex"] != hexutil.EncodeUint64(uint64(i)) {
+				return fmt.Errorf("transactions[%d] has index %v", i, tx["transactionIndex"])
+			}
+		}
+		return nil
+	}},
+	{Suite: "blocks", Name: "EarliestIsGenesis", Run: func(ctx context.Context, env *testEnv) error {
+		block, err := env.getObject(ctx, "eth_getBlockByNumber", "earliest", false)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			return skip("The earliest block isn't available, the node may be pruned")
+		}
+		if block["number"] != "0x0" {
+			return fmt.Errorf("Expected the earliest block to be 0x0 but got %v", block["number"])
+		}
+		return nil
+	}},
+	{Suite: "blocks", Name: "PendingTagIsAtOrAfterHead", Run: func(ctx context.Context, env *testEnv) error {
+		block, err := env.getObject(ctx, "eth_getBlockByNumber", "pending", false)
+		if err != nil {
+			return err
+		}
+		// Some clients don't support the pending block or return it without a
+		// number.
+		if block == nil || block["number"] == nil {
+			return nil
+		}
+		number, err := hexutil.DecodeUint64(fmt.Sprint(block["number"]))
+		if err != nil {
+			return fmt.Errorf("The pending block number is not a quantity: %w", err)
+		}
+		if number < env.head {
+			return fmt.Errorf("The pending block %d is before the head %d", number, env.head)
+		}
+		return nil
+	}},
+	{Suite: "blocks", Name: "SafeAndFinalizedAreAtOrBeforeHead", Run: func(ctx context.Context, env *testEnv) error {
+		supported := false
+		for _, tag := range []string{"safe", "finalized"} {
+			block, err := env.getObject(ctx, "eth_getBlockByNumber", tag, false)
+			if err != nil || block == nil {
+				continue
+			}
+			supported = true
+			number, err := hexutil.DecodeUint64(fmt.Sprint(block["number"]))
+			if err != nil {
+				return fmt.Errorf("The %s block number is not a quantity: %w", tag, err)
+			}
+			if number > env.head {
+				return fmt.Errorf("The %s block %d is after the head %d", tag, number, env.head)
+			}
+		}
+		if !supported {
+			return skip("The safe and finalized tags aren't supported")
+		}
+		return nil
+	}},
+	{Suite: "blocks", Name: "FutureBlockIsNull", Run: func(ctx context.Context, env *testEnv) error {
+		block, err := env.getObject(ctx, "eth_getBlockByNumber", hexutil.EncodeUint64(env.head+1_000_000), false)
+		if err != nil {
+			return err
+		}
+		if block != nil {
+			return errors.New("Expected null for a block after the head")
+		}
+		return nil
+	}},
+	{Suite: "blocks", Name: "UnknownBlockHashIsNull", Run: func(ctx context.Context, env *testEnv) error {
+		block, err := env.getObject(ctx, "eth_getBlockByHash", unknownHash, false)
+		if err != nil {
+			return err
+		}
+		if block != nil {
+			return errors.New("Expected null for an unknown block hash")
+		}
+		return nil
+	}},
+	{Suite: "transactions", Name: "TransactionByHashEncoding", Run: func(ctx context.Context, env *testEnv) error {
+		expected, err := env.requireTx()
+		if err != nil {
+			return err
+		}
+		tx, err := env.getObject(ctx, "eth_getTransactionByHash", expected["hash"])
+		if err != nil {
+			return err
+		}
+		if err = validateSchema(rpctypes.RPCSchemaEthTransaction, tx); err != nil {
+			return err
+		}
+		if err = checkTransactionFields(tx); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(tx, expected) {
+			return errors.New("eth_getTransactionByHash doesn't match the transaction in the block")
+		}
+		return nil
+	}},
+	{Suite: "transactions", Name: "TransactionByBlockHashAndIndex", Run: func(ctx context.Context, env *testEnv) error {
+		expected, err := env.requireTx()
+		if err != nil {
+			return err
+		}
+		tx, err := env.getObject(ctx, "eth_getTransactionByBlockHashAndIndex", expected["blockHash"], expected["transactionIndex"])
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(tx, expected) {
+			return errors.New("eth_getTransactionByBlockHashAndIndex doesn't match the transaction in the block")
+		}
+		return nil
+	}},
+	{Suite: "transactions", Name: "TransactionByBlockNumberAndIndex", Run: func(ctx context.Context, env *testEnv) error {
+		expected, err := env.requireTx()
+		if err != nil {
+			return err
+		}
+		tx, err := env.getObject(ctx, "eth_getTransactionByBlockNumberAndIndex", expected["blockNumber"], expected["transactionIndex"])
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(tx, expected) {
+			return errors.New("eth_getTransactionByBlockNumberAndIndex doesn't match the transaction in the block")
+		}
+		return nil
+	}},
+	{Suite: "transactions", Name: "TransactionIndexOutOfRangeIsNull", Run: func(ctx context.Context, env *testEnv) error {
+		tx, err := env.getObject(ctx, "eth_getTransactionByBlockHashAndIndex", env.block["hash"], "0xffff")
+		if err != nil {
+			return err
+		}
+		if tx != nil {
+			return errors.New("Expected null for a transaction index out of range")
+		}
+		return nil
+	}},
+	{Suite: "transactions", Name: "ReceiptEncoding", Run: func(ctx context.Context, env *testEnv) error {
+		tx, err := env.requireTx()
+		if err != nil {
+			return err
+		}
+		receipt, err := env.getObject(ctx, "eth_getTransactionReceipt", tx["hash"])
+		if err != nil {
+			return err
+		}
+		if receipt == nil {
+			return fmt.Errorf("No receipt for the mined transaction %v", tx["hash"])
+		}
+		if err = validateSchema(rpctypes.RPCSchemaEthReceipt, receipt); err != nil {
+			return err
+		}
+		return checkReceiptFields(tx, receipt)
+	}},
+	{Suite: "transactions", Name: "UnknownTransactionIsNull", Run: func(ctx context.Context, env *testEnv) error {
+		tx, err := env.getObject(ctx, "eth_getTransactionByHash", unknownHash)
+		if err != nil {
+			return err
+		}
+		if tx != nil {
+			return errors.New("Expected null for an unknown transaction hash")
+		}
+		return nil
+	}},
+	{Suite: "transactions", Name: "UnknownReceiptIsNull", Run: func(ctx context.Context, env *testEnv) error {
+		receipt, err := env.getObject(ctx, "eth_getTransactionReceipt", unknownHash)
+		if err != nil {
+			return err
+		}
+		if receipt != nil {
+			return errors.New("Expected null for an unknown transaction receipt")
+		}
+		return nil
+	}},
+	{Suite: "state", Name: "BalanceIsQuantity", Run: func(ctx context.Context, env *testEnv) error {
+		var result string
+		if err := env.rpc.CallContext(ctx, &result, "eth_getBalance", zeroAddress, "latest"); err != nil {
+			return err
+		}
+		return checkQuantity("result", result)
+	}},
+	{Suite: "state", Name: "CodeIsData", Run: func(ctx context.Context, env *testEnv) error {
+		var result string
+		if err := env.rpc.CallContext(ctx, &result, "eth_getCode", zeroAddress, "latest"); err != nil {
+			return err
+		}
+		return checkData("result", result, -1)
+	}},
+	{Suite: "state", Name: "PendingNonceIsAtLeastLatest", Run: func(ctx context.Context, env *testEnv) error {
+		tx, err := env.requireTx()
+		if err != nil {
+			return err
+		}
+		var latest, pending string
+		if err = env.rpc.CallContext(ctx, &latest, "eth_getTransactionCount", tx["from"], "latest"); err != nil {
+			return err
+		}
+		if err = env.rpc.CallContext(ctx, &pending, "eth_getTransactionCount", tx["from"], "pending"); err != nil {
+			return err
+		}
+		if err = checkQuantity("latest", latest); err != nil {
+			return err
+		}
+		if err = checkQuantity("pending", pending); err != nil {
+			return err
+		}
+		if hexutil.MustDecodeBig(pending).Cmp(hexutil.MustDecodeBig(latest)) < 0 {
+			return fmt.Errorf("The pending nonce %s is less than the latest nonce %s", pending, latest)
+		}
+		return nil
+	}},
+	{Suite: "state", Name: "GasPriceIsQuantity", Run: func(ctx context.Context, env *testEnv) error {
+		var result string
+		if err := env.rpc.CallContext(ctx, &result, "eth_gasPrice"); err != nil {
+			return err
+		}
+		return checkQuantity("result", result)
+	}},
+	{Suite: "state", Name: "EstimateGasForTransfer", Run: func(ctx context.Context, env *testEnv) error {
+		var result string
+		args := map[string]interface{}{"from": zeroAddress, "to": zeroAddress, "value": "0x0"}
+		if err := env.rpc.CallContext(ctx, &result, "eth_estimateGas", args); err != nil {
+			return err
+		}
+		if result != "0x5208" {
+			return fmt.Errorf("Expected 0x5208 for a plain transfer but got %s", result)
+		}
+		return nil
+	}},
+	{Suite: "state", Name: "FeeHistoryEncoding", Run: func(ctx context.Context, env *testEnv) error {
+		result, err := env.getObject(ctx, "eth_feeHistory", "0x1", "latest", []interface{}{50})
+		if err != nil {
+			return err
+		}
+		return validateSchema(rpctypes.RPCSchemaEthFeeHistory, result)
+	}},
+	{Suite: "state", Name: "LogsAreInRange", Run: func(ctx context.Context, env *testEnv) error {
+		var logs []map[string]interface{}
+		number := env.block["number"]
+		filter := map[string]interface{}{"fromBlock": number, "toBlock": number}
+		if err := env.rpc.CallContext(ctx, &logs, "eth_getLogs", filter); err != nil {
+			return err
+		}
+		for i, l := range logs {
+			if l["blockNumber"] != number || l["blockHash"] != env.block["hash"] {
+				return fmt.Errorf("logs[%d] is from block %v but the filter was for %v", i, l["blockNumber"], number)
+			}
+		}
+		return nil
+	}},
+	{Suite: "errors", Name: "UnknownMethod", Run: func(ctx context.Context, env *testEnv) error {
+		err := env.rpc.CallContext(ctx, nil, "eth_notAMethod")
+		return expectErrorCode(err, codeMethodNotFound)
+	}},
+	{Suite: "errors", Name: "MissingParams", Run: func(ctx context.Context, env *testEnv) error {
+		err := env.rpc.CallContext(ctx, nil, "eth_getBlockByNumber")
+		return expectErrorCode(err, codeInvalidParams)
+	}},
+	{Suite: "errors", Name: "InvalidBlockTag", Run: func(ctx context.Context, env *testEnv) error {
+		err := env.rpc.CallContext(ctx, nil, "eth_getBlockByNumber", "latestt", false)
+		return expectErrorCode(err, codeInvalidParams)
+	}},
+	{Suite: "errors", Name: "QuantityWithLeadingZeros", Run: func(ctx context.Context, env *testEnv) error {
+		err := env.rpc.CallContext(ctx, nil, "eth_getBlockByNumber", "0x01", false)
+		return expectErrorCode(err, codeInvalidParams)
+	}},
+	{Suite: "errors", Name: "InvalidAddress", Run: func(ctx context.Context, env *testEnv) error {
+		err := env.rpc.CallContext(ctx, nil, "eth_getBalance", "0x1234", "latest")
+		return expectErrorCode(err, codeInvalidParams)
+	}},
+	{Suite: "errors", Name: "InvalidHashLength", Run: func(ctx context.Context, env *testEnv) error {
+		err := env.rpc.CallContext(ctx, nil, "eth_getTransactionByHash", "0x1234")
+		return expectErrorCode(err, codeInvalidParams)
+	}},
+}
+
+func checkBlockFields(block map[string]interface{}) error {
+	for _, f := range []string{"number", "gasLimit", "gasUsed", "timestamp", "size"} {
+		if err := checkQuantity(f, block[f]); err != nil {
+			return err
+		}
+	}
+	for _, f := range []string{"hash", "parentHash", "stateRoot", "transactionsRoot", "receiptsRoot", "sha3Uncles"} {
+		if err := checkData(f, block[f], 32); err != nil {
+			return err
+		}
+	}
+	if err := checkData("miner", block["miner"], 20); err != nil {
+		return err
+	}
+	if err := checkData("logsBloom", block["logsBloom"], 256); err != nil {
+		return err
+	}
+	if baseFee, ok := block["baseFeePerGas"]; ok {
+		return checkQuantity("baseFeePerGas", baseFee)
+	}
+	return nil
+}
+
+func checkTransactionFields(tx map[string]interface{}) error {
+	for _, f := range []string{"blockNumber", "gas", "nonce", "transactionIndex", "value", "v", "r", "s"} {
+		if err := checkQuantity(f, tx[f]); err != nil {
+			return err
+		}
+	}
+	for _, f := range []string{"hash", "blockHash"} {
+		if err := checkData(f, tx[f], 32); err != nil {
+			return err
+		}
+	}
+	if err := checkData("from", tx["from"], 20); err != nil {
+		return err
+	}
+	if tx["to"] != nil {
+		if err := checkData("to", tx["to"], 20); err != nil {
+			return err
+		}
+	}
+	return checkData("input", tx["input"], -1)
+}
+
+func checkReceiptFields(tx, receipt map[string]interface{}) error {
+	for _, f := range []string{"blockNumber", "cumulativeGasUsed", "gasUsed", "transactionIndex"} {
+		if err := checkQuantity(f, receipt[f]); err != nil {
+			return err
+		}
+	}
+	if receipt["transactionHash"] != tx["hash"] || receipt["blockHash"] != tx["blockHash"] || receipt["transactionIndex"] != tx["transactionIndex"] {
+		return errors.New("The receipt doesn't match the transaction's hash, block, or index")
+	}
+	if status := receipt["status"]; status != "0x0" && status != "0x1" {
+		return fmt.Errorf("Expected a status of 0x0 or 0x1 but got %v", status)
+	}
+	cumulative := hexutil.MustDecodeBig(fmt.Sprint(receipt["cumulativeGasUsed"]))
+	gasUsed := hexutil.MustDecodeBig(fmt.Sprint(receipt["gasUsed"]))
+	if cumulative.Cmp(gasUsed) < 0 {
+		return fmt.Errorf("cumulativeGasUsed %s is less than gasUsed %s", cumulative, gasUsed)
+	}
+	logs, ok := receipt["logs"].([]interface{})
+	if !ok {
+		return errors.New("logs is not an array")
+	}
+	for i, raw := range logs {
+		l, ok := raw.(map[string]interface{})
+		if !ok || l["transactionHash"] != tx["hash"] {
+			return fmt.Errorf("logs[%d] doesn't belong to the transaction", i)
+		}
+	}
+	return nil
+}
+
+// checkQuantity checks that the value is a hex encoded quantity without
+// leading zeros.
+func checkQuantity(field string, v interface{}) error {
+	s, ok := v.(string)
+	if !ok || !quantityRegex.MatchString(s) {
+		return fmt.Errorf("%s is not a valid quantity: %v", field, v)
+	}
+	return nil
+}
+
+// checkData checks that the value is lowercase hex encoded data with the
+// given number of bytes, or any length if size is negative.
+func checkData(field string, v interface{}, size int) error {
+	s, ok := v.(string)
+	if !ok || !dataRegex.MatchString(s) {
+		return fmt.Errorf("%s is not valid data: %v", field, v)
+	}
+	if size >= 0 && len(s) != 2+2*size {
+		return fmt.Errorf("%s should be %d bytes: %s", field, size, s)
+	}
+	return nil
+}
+
+// expectErrorCode checks that the call failed with the JSON-RPC error code.
+func expectErrorCode(err error, code int) error {
+	if err == nil {
+		return fmt.Errorf("Expected error code %d but the call succeeded", code)
+	}
+	var rpcErr ethrpc.Error
+	if !errors.As(err, &rpcErr) {
+		return fmt.Errorf("Expected error code %d but got: %w", code, err)
+	}
+	if rpcErr.ErrorCode() != code {
+		return fmt.Errorf("Expected error code %d but got %d: %s", code, rpcErr.ErrorCode(), err)
+	}
+	return nil
+}
+
+// validateSchema validates the value against a JSON schema.
+func validateSchema(schema string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(schema), gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return fmt.Errorf("Unable to run json validation: %w", err)
+	}
+	if !result.Valid() {
+		errs := make([]string, 0, len(result.Errors()))
+		for _, desc := range result.Errors() {
+			errs = append(errs, desc.String())
+		}
+		return fmt.Errorf("The json document is not valid: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package signbench
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	//go:embed usage.md
+	usage string
+
+	duration    *time.Duration
+	concurrency *int
+	chainID     *uint64
+	dataSize    *uint64
+	legacy      *bool
+	benchmarks  *[]string
+)
+
+type (
+	// TestResult is the throughput of one benchmark.
+	TestResult struct {
+		Description  string
+		Concurrency  int
+		TestDuration time.Duration
+		OpCount      uint64
+		OpRate       float64
+		NsPerOp      float64
+	}
+
+	// benchmark is an operation which is repeated by each worker. The
+	// function returned by setup is called once per operation, and it gets
+	// its own state so the workers don't share anything.
+	benchmark struct {
+		name        string
+		description string
+		setup       func() (func() error, error)
+	}
+)
+
+var SignbenchCmd = &cobra.Command{
+	Use:   "signbench [flags]",
+	Short: "Benchmark local transaction signing, encoding, and hashing",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if *concurrency < 0 {
+			return fmt.Errorf("the concurrency can't be negative")
+		}
+		if *concurrency == 0 {
+			*concurrency = runtime.NumCPU()
+		}
+		if *duration <= 0 {
+			return fmt.Errorf("the duration needs to be positive")
+		}
+		for _, name := range *benchmarks {
+			if getBenchmark(name) == nil {
+				return fmt.Errorf("unknown benchmark %s", name)
+			}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Info().Int("concurrency", *concurrency).Dur("duration", *duration).Msg("Starting signing benchmark")
+		trs := make([]*TestResult, 0, len(*benchmarks))
+		for _, name := range *benchmarks {
+			tr, err := runBenchmark(getBenchmark(name))
+			if err != nil {
+				return err
+			}
+			trs = append(trs, tr)
+		}
+		return printSummary(trs)
+	},
+}
+
+func getBenchmarks() []benchmark {
+	return []benchmark{
+		{"sign", "ecdsa sign transaction", setupSign},
+		{"encode", "rlp encode signed transaction", setupEncode},
+		{"hash", "keccak256 transaction hash", setupHash},
+		{"recover", "ecdsa recover transaction sender", setupRecover},
+	}
+}
+
+func getBenchmark(name string) *benchmark {
+	for _, b := range getBenchmarks() {
+		if b.name == name {
+			return &b
+		}
+	}
+	return nil
+}
+
+// newTransaction returns an unsigned transaction with the configured type and
+// data size. Only the nonce changes between calls, so the signature changes
+// too.
+func newTransaction(nonce uint64) *ethtypes.Transaction {
+	to := ethcommon.HexToAddress("0xDEADBEEF00000000000000000000000000000000")
+	data := make([]byte, *dataSize)
+	if *legacy {
+		return ethtypes.NewTx(&ethtypes.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: big.NewInt(1000000000),
+			Gas:      21000,
+			To:       &to,
+			Value:    big.NewInt(1),
+			Data:     data,
+		})
+	}
+	return ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+		ChainID:   new(big.Int).SetUint64(*chainID),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1000000000),
+		GasFeeCap: big.NewInt(2000000000),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1),
+		Data:      data,
+	})
+}
+
+// newSignedTransaction returns a transaction signed by a new key.
+func newSignedTransaction(signer ethtypes.Signer) (*ethtypes.Transaction, error) {
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return ethtypes.SignTx(newTransaction(0), signer, key)
+}
+
+func getSigner() ethtypes.Signer {
+	return ethtypes.LatestSignerForChainID(new(big.Int).SetUint64(*chainID))
+}
+
+// setupSign measures types.SignTx, which hashes the transaction for the
+// signer and signs the hash.
+func setupSign() (func() error, error) {
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	signer := getSigner()
+	var nonce uint64
+	return func() error {
+		nonce++
+		_, err := ethtypes.SignTx(newTransaction(nonce), signer, key)
+		return err
+	}, nil
+}
+
+// setupEncode measures the encoding of a signed transaction for
+// eth_sendRawTransaction.
+func setupEncode() (func() error, error) {
+	tx, err := newSignedTransaction(getSigner())
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := tx.MarshalBinary()
+		return err
+	}, nil
+}
+
+// setupHash measures the keccak256 hash of the encoded transaction. The
+// transaction caches its hash, so the encoding is hashed directly.
+func setupHash() (func() error, error) {
+	tx, err := newSignedTransaction(getSigner())
+	if err != nil {
+		return nil, err
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		ethcrypto.Keccak256Hash(raw)
+		return nil
+	}, nil
+}
+
+// setupRecover measures recovering the sender of a signed transaction, which
+// is what the nodes do for each transaction they receive. The signer is used
+// directly, since types.Sender caches the sender in the transaction.
+func setupRecover() (func() error, error) {
+	signer := getSigner()
+	tx, err := newSignedTransaction(signer)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := signer.Sender(tx)
+		return err
+	}, nil
+}
+
+// runBenchmark repeats the benchmark operation on each worker until the
+// duration has passed.
+func runBenchmark(b *benchmark) (*TestResult, error) {
+	ops := make([]func() error, *concurrency)
+	for i := range ops {
+		op, err := b.setup()
+		if err != nil {
+			return nil, err
+		}
+		ops[i] = op
+	}
+
+	var opCount atomic.Uint64
+	var stop atomic.Bool
+	errs := make([]error, *concurrency)
+	var wg sync.WaitGroup
+	startTime := time.Now()
+	for i, op := range ops {
+		wg.Add(1)
+		go func(i int, op func() error) {
+			defer wg.Done()
+			var count uint64
+			for !stop.Load() {
+				if err := op(); err != nil {
+					errs[i] = err
+					break
+				}
+				count++
+			}
+			opCount.Add(count)
+		}(i, op)
+	}
+	time.Sleep(*duration)
+	stop.Store(true)
+	wg.Wait()
+	testDuration := time.Since(startTime)
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tr := &TestResult{
+		Description:  b.description,
+		Concurrency:  *concurrency,
+		TestDuration: testDuration,
+		OpCount:      opCount.Load(),
+	}
+	tr.OpRate = float64(tr.OpCount) / testDuration.Seconds()
+	if tr.OpCount > 0 {
+		// The workers run in parallel, so the time per operation is the
+		// time one worker spends on it.
+		tr.NsPerOp = float64(testDuration.Nanoseconds()) * float64(*concurrency) / float64(tr.OpCount)
+	}
+
+	log.Info().Str("desc", tr.Description).Uint64("ops", tr.OpCount).Float64("opRate", tr.OpRate).Msg("recorded result")
+	return tr, nil
+}
+
+func printSummary(trs []*TestResult) error {
+	jsonResults, err := json.Marshal(trs)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonResults))
+	return nil
+}
+
+func init() {
+	names := make([]string, 0)
+	for _, b := range getBenchmarks() {
+		names = append(names, b.name)
+	}
+
+	flagSet := SignbenchCmd.PersistentFlags()
+	duration = flagSet.Duration("duration", 5*time.Second, "How long to run each benchmark")
+	concurrency = flagSet.Int("concurrency", 0, "The number of concurrent goroutines running each benchmark, or 0 for one per CPU")
+	chainID = flagSet.Uint64("chain-id", 1337, "The chain id of the signed transactions")
+	dataSize = flagSet.Uint64("data-size", 0, "The byte length of the transaction call data")
+	legacy = flagSet.Bool("legacy", false, "Use legacy transactions instead of dynamic fee transactions")
+	benchmarks = flagSet.StringSlice("benchmarks", names, "The benchmarks to run, from sign, encode, hash, and recover")
+}
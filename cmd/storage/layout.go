@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+type (
+	// storageLayout is the storage layout of a contract as output by solc
+	// with `--storage-layout`, or in the `storageLayout` field of the
+	// standard JSON output.
+	storageLayout struct {
+		Storage []storageVariable      `json:"storage"`
+		Types   map[string]storageType `json:"types"`
+	}
+
+	storageVariable struct {
+		Label  string `json:"label"`
+		Offset int    `json:"offset"`
+		// Slot is a decimal string in the solc output, but the patterns use
+		// hex for the hashed slots.
+		Slot string `json:"slot"`
+		Type string `json:"type"`
+	}
+
+	storageType struct {
+		// Encoding is "inplace", "mapping", "dynamic_array", or "bytes".
+		Encoding      string            `json:"encoding"`
+		Label         string            `json:"label"`
+		NumberOfBytes string            `json:"numberOfBytes"`
+		Key           string            `json:"key,omitempty"`
+		Value         string            `json:"value,omitempty"`
+		Base          string            `json:"base,omitempty"`
+		Members       []storageVariable `json:"members,omitempty"`
+	}
+)
+
+// loadLayout reads the storage layout from a file, which is either the layout
+// itself or a contract output with a storageLayout field.
+func loadLayout(path string) (*storageLayout, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var wrapped struct {
+		StorageLayout *storageLayout `json:"storageLayout"`
+	}
+	if err = json.Unmarshal(raw, &wrapped); err != nil {
+		return nil, fmt.Errorf("unable to parse the storage layout: %w", err)
+	}
+	if wrapped.StorageLayout != nil {
+		return wrapped.StorageLayout, nil
+	}
+
+	layout := new(storageLayout)
+	if err = json.Unmarshal(raw, layout); err != nil {
+		return nil, fmt.Errorf("unable to parse the storage layout: %w", err)
+	}
+	if len(layout.Storage) == 0 {
+		return nil, fmt.Errorf("%s has no storage variables", path)
+	}
+	return layout, nil
+}
+
+// merge adds the variables and types of the other layout.
+func (l *storageLayout) merge(other *storageLayout) {
+	l.Storage = append(l.Storage, other.Storage...)
+	if l.Types == nil {
+		l.Types = make(map[string]storageType)
+	}
+	for id, t := range other.Types {
+		l.Types[id] = t
+	}
+}
+
+func (l *storageLayout) typeOf(id string) (storageType, error) {
+	t, ok := l.Types[id]
+	if !ok {
+		return t, fmt.Errorf("the type %s isn't in the storage layout", id)
+	}
+	return t, nil
+}
+
+func (t storageType) size() int {
+	size, err := parseSlot(t.NumberOfBytes)
+	if err != nil {
+		return 32
+	}
+	return int(size.Int64())
+}
+
+func parseSlot(s string) (*big.Int, error) {
+	slot, ok := new(big.Int).SetString(s, 0)
+	if !ok || slot.Sign() < 0 || slot.BitLen() > 256 {
+		return nil, fmt.Errorf("the slot %s is invalid", s)
+	}
+	return slot, nil
+}
+
+func slotHash(slot *big.Int) common.Hash {
+	return common.BigToHash(slot)
+}
+
+// addSlots returns slot + n, wrapping around at 2^256 like the EVM does.
+func addSlots(slot *big.Int, n int64) *big.Int {
+	sum := new(big.Int).Add(slot, big.NewInt(n))
+	return sum.And(sum, maxWord)
+}
+
+// dataSlot returns the slot where the data of a dynamic array or a long byte
+// array stored at the slot starts, keccak256(slot).
+func dataSlot(slot *big.Int) *big.Int {
+	h := slotHash(slot)
+	return new(big.Int).SetBytes(ethcrypto.Keccak256(h[:]))
+}
+
+// mappingSlot returns the slot of the value of the key in the mapping stored
+// at the slot. Value type keys are padded to 32 bytes like in abi.encode,
+// while string and bytes keys are hashed as is.
+func mappingSlot(slot *big.Int, keyType storageType, key string) (*big.Int, error) {
+	encoded, err := encodeKey(keyType, key)
+	if err != nil {
+		return nil, err
+	}
+	h := slotHash(slot)
+	return new(big.Int).SetBytes(ethcrypto.Keccak256(encoded, h[:])), nil
+}
+
+func encodeKey(keyType storageType, key string) ([]byte, error) {
+	label := keyType.Label
+	switch {
+	case label == "string":
+		return []byte(key), nil
+	case label == "bytes":
+		return hexutil.Decode(key)
+	case label == "bool":
+		switch key {
+		case "true":
+			return common.LeftPadBytes([]byte{1}, 32), nil
+		case "false":
+			return make([]byte, 32), nil
+		}
+		return nil, fmt.Errorf("the bool key %s is invalid", key)
+	case label == "address" || label == "address payable" || strings.HasPrefix(label, "contract "):
+		if !common.IsHexAddress(key) {
+			return nil, fmt.Errorf("the address key %s is invalid", key)
+		}
+		return common.LeftPadBytes(common.HexToAddress(key).Bytes(), 32), nil
+	case strings.HasPrefix(label, "bytes"):
+		b, err := hexutil.Decode(key)
+		if err != nil || len(b) > keyType.size() {
+			return nil, fmt.Errorf("the %s key %s is invalid", label, key)
+		}
+		return common.RightPadBytes(b, 32), nil
+	case strings.HasPrefix(label, "uint") || strings.HasPrefix(label, "enum "):
+		n, ok := new(big.Int).SetString(key, 0)
+		if !ok || n.Sign() < 0 || n.BitLen() > 8*keyType.size() {
+			return nil, fmt.Errorf("the %s key %s is invalid", label, key)
+		}
+		return common.LeftPadBytes(n.Bytes(), 32), nil
+	case strings.HasPrefix(label, "int"):
+		n, ok := new(big.Int).SetString(key, 0)
+		if !ok {
+			return nil, fmt.Errorf("the %s key %s is invalid", label, key)
+		}
+		// Negative keys are sign extended to 32 bytes.
+		return common.BigToHash(new(big.Int).And(n, maxWord)).Bytes(), nil
+	}
+	return nil, fmt.Errorf("the key type %s isn't supported", label)
+}
+
+// maxWord is 2^256 - 1.
+var maxWord = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// decodeValue decodes a value type of the given size at the offset of the
+// word, counting from the lowest order byte like solc does.
+func decodeValue(t storageType, word common.Hash, offset int) string {
+	size := t.size()
+	if offset+size > common.HashLength {
+		size = common.HashLength - offset
+	}
+	b := word[common.HashLength-offset-size : common.HashLength-offset]
+
+	label := t.Label
+	switch {
+	case label == "bool":
+		return fmt.Sprint(b[len(b)-1] != 0)
+	case label == "address" || label == "address payable" || strings.HasPrefix(label, "contract "):
+		return common.BytesToAddress(b).Hex()
+	case strings.HasPrefix(label, "uint") || strings.HasPrefix(label, "enum "):
+		return new(big.Int).SetBytes(b).String()
+	case strings.HasPrefix(label, "int"):
+		n := new(big.Int).SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+		}
+		return n.String()
+	}
+	return hexutil.Encode(b)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"math/big"
+	"sort"
+)
+
+var (
+	uint256Type = storageType{Encoding: "inplace", Label: "uint256", NumberOfBytes: "32"}
+	addressType = storageType{Encoding: "inplace", Label: "address", NumberOfBytes: "20"}
+	stringType  = storageType{Encoding: "bytes", Label: "string", NumberOfBytes: "32"}
+
+	// erc20Types are the types of the OpenZeppelin ERC-20 variables.
+	erc20Types = map[string]storageType{
+		"t_uint256":        uint256Type,
+		"t_address":        addressType,
+		"t_string_storage": stringType,
+		"t_mapping(t_address,t_uint256)": {
+			Encoding: "mapping", Label: "mapping(address => uint256)", NumberOfBytes: "32",
+			Key: "t_address", Value: "t_uint256",
+		},
+		"t_mapping(t_address,t_mapping(t_address,t_uint256))": {
+			Encoding: "mapping", Label: "mapping(address => mapping(address => uint256))", NumberOfBytes: "32",
+			Key: "t_address", Value: "t_mapping(t_address,t_uint256)",
+		},
+	}
+
+	// erc20Variables are the variables of the OpenZeppelin ERC-20, relative to
+	// the first slot of the contract or of its namespace.
+	erc20Variables = []storageVariable{
+		{Label: "_balances", Slot: "0", Type: "t_mapping(t_address,t_uint256)"},
+		{Label: "_allowances", Slot: "1", Type: "t_mapping(t_address,t_mapping(t_address,t_uint256))"},
+		{Label: "_totalSupply", Slot: "2", Type: "t_uint256"},
+		{Label: "_name", Slot: "3", Type: "t_string_storage"},
+		{Label: "_symbol", Slot: "4", Type: "t_string_storage"},
+	}
+
+	// patterns are the storage layouts of common contracts, which are read
+	// without a layout file.
+	patterns = map[string]*storageLayout{
+		// The layout of the OpenZeppelin ERC-20 before the upgradeable
+		// contracts moved to namespaced storage, and of the non upgradeable
+		// one in every version.
+		"erc20": {
+			Storage: erc20Variables,
+			Types:   erc20Types,
+		},
+		// The layout of the OpenZeppelin 5 upgradeable ERC-20, which is
+		// stored in the ERC-7201 namespace "openzeppelin.storage.ERC20".
+		"erc20-namespaced": {
+			Storage: offsetSlots(erc20Variables, "0x52c63247e1f47db19d5ce0460030c497f067ca4cebf71ba98eeadabe20bace00"),
+			Types:   erc20Types,
+		},
+		// The EIP-1967 proxy slots, each being keccak256 of a name minus one.
+		"eip1967": {
+			Storage: []storageVariable{
+				{Label: "implementation", Slot: "0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc", Type: "t_address"},
+				{Label: "admin", Slot: "0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103", Type: "t_address"},
+				{Label: "beacon", Slot: "0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50", Type: "t_address"},
+			},
+			Types: map[string]storageType{"t_address": addressType},
+		},
+		// The state of a Uniswap V3 pool. The tokens and the fee are
+		// immutables, which aren't in the storage.
+		"uniswapv3-pool": {
+			Storage: []storageVariable{
+				{Label: "slot0", Slot: "0", Type: "t_struct(Slot0)"},
+				{Label: "feeGrowthGlobal0X128", Slot: "1", Type: "t_uint256"},
+				{Label: "feeGrowthGlobal1X128", Slot: "2", Type: "t_uint256"},
+				{Label: "protocolFees", Slot: "3", Type: "t_struct(ProtocolFees)"},
+				{Label: "liquidity", Slot: "4", Type: "t_uint128"},
+				{Label: "tickBitmap", Slot: "6", Type: "t_mapping(t_int16,t_uint256)"},
+			},
+			Types: map[string]storageType{
+				"t_uint256": uint256Type,
+				"t_uint160": {Encoding: "inplace", Label: "uint160", NumberOfBytes: "20"},
+				"t_uint128": {Encoding: "inplace", Label: "uint128", NumberOfBytes: "16"},
+				"t_int24":   {Encoding: "inplace", Label: "int24", NumberOfBytes: "3"},
+				"t_int16":   {Encoding: "inplace", Label: "int16", NumberOfBytes: "2"},
+				"t_uint16":  {Encoding: "inplace", Label: "uint16", NumberOfBytes: "2"},
+				"t_uint8":   {Encoding: "inplace", Label: "uint8", NumberOfBytes: "1"},
+				"t_bool":    {Encoding: "inplace", Label: "bool", NumberOfBytes: "1"},
+				"t_mapping(t_int16,t_uint256)": {
+					Encoding: "mapping", Label: "mapping(int16 => uint256)", NumberOfBytes: "32",
+					Key: "t_int16", Value: "t_uint256",
+				},
+				"t_struct(Slot0)": {
+					Encoding: "inplace", Label: "struct UniswapV3Pool.Slot0", NumberOfBytes: "32",
+					Members: []storageVariable{
+						{Label: "sqrtPriceX96", Slot: "0", Offset: 0, Type: "t_uint160"},
+						{Label: "tick", Slot: "0", Offset: 20, Type: "t_int24"},
+						{Label: "observationIndex", Slot: "0", Offset: 23, Type: "t_uint16"},
+						{Label: "observationCardinality", Slot: "0", Offset: 25, Type: "t_uint16"},
+						{Label: "observationCardinalityNext", Slot: "0", Offset: 27, Type: "t_uint16"},
+						{Label: "feeProtocol", Slot: "0", Offset: 29, Type: "t_uint8"},
+						{Label: "unlocked", Slot: "0", Offset: 30, Type: "t_bool"},
+					},
+				},
+				"t_struct(ProtocolFees)": {
+					Encoding: "inplace", Label: "struct UniswapV3Pool.ProtocolFees", NumberOfBytes: "32",
+					Members: []storageVariable{
+						{Label: "token0", Slot: "0", Offset: 0, Type: "t_uint128"},
+						{Label: "token1", Slot: "0", Offset: 16, Type: "t_uint128"},
+					},
+				},
+			},
+		},
+	}
+)
+
+// offsetSlots returns the variables moved to start at the base slot.
+func offsetSlots(variables []storageVariable, base string) []storageVariable {
+	baseSlot, err := parseSlot(base)
+	if err != nil {
+		panic(err)
+	}
+	moved := make([]storageVariable, 0, len(variables))
+	for _, v := range variables {
+		slot, err := parseSlot(v.Slot)
+		if err != nil {
+			panic(err)
+		}
+		v.Slot = slotHash(new(big.Int).Add(baseSlot, slot)).Hex()
+		moved = append(moved, v)
+	}
+	return moved
+}
+
+func patternNames() []string {
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
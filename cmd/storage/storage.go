@@ -0,0 +1,469 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	storageParams struct {
+		RPCURL      string
+		Block       string
+		LayoutFile  string
+		Patterns    []string
+		Reads       []string
+		Slots       []string
+		MaxElements int
+		MaxBytes    int
+	}
+
+	// entry is a decoded value, or a raw slot.
+	entry struct {
+		Name   string      `json:"name"`
+		Type   string      `json:"type"`
+		Slot   common.Hash `json:"slot"`
+		Offset int         `json:"offset"`
+		Value  string      `json:"value"`
+	}
+
+	// segment is an index, a mapping key, or a struct member of a path to
+	// read, e.g. `_allowances[0xabc][0xdef]` or `slot0.tick`.
+	segment struct {
+		Key    string
+		Member string
+	}
+
+	// reader reads the storage slots of the contract, each one only once.
+	reader struct {
+		ctx     context.Context
+		rpc     *ethrpc.Client
+		address common.Address
+		block   string
+		cache   map[common.Hash]common.Hash
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage        string
+	inputStorage storageParams
+
+	pathSegmentRegex = regexp.MustCompile(`^(\[[^\]]*\]|\.[A-Za-z_$][A-Za-z0-9_$]*)`)
+	arrayLengthRegex = regexp.MustCompile(`\[(\d+)\]$`)
+)
+
+var StorageCmd = &cobra.Command{
+	Use:   "storage 0xaddress",
+	Short: "Read and decode the storage of a contract.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected exactly one contract address")
+		}
+		if !common.IsHexAddress(args[0]) {
+			return fmt.Errorf("the address %s is invalid", args[0])
+		}
+		if inputStorage.LayoutFile == "" && len(inputStorage.Patterns) == 0 && len(inputStorage.Slots) == 0 {
+			return fmt.Errorf("expected a --layout, a --pattern, or raw --slot values to read")
+		}
+		for _, p := range inputStorage.Patterns {
+			if _, ok := patterns[p]; !ok {
+				return fmt.Errorf("the pattern %s is unknown, expected one of %s", p, strings.Join(patternNames(), ", "))
+			}
+		}
+		if inputStorage.MaxElements < 0 || inputStorage.MaxBytes < 0 {
+			return fmt.Errorf("the max elements and bytes can't be negative")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		layout := new(storageLayout)
+		if inputStorage.LayoutFile != "" {
+			fromFile, err := loadLayout(inputStorage.LayoutFile)
+			if err != nil {
+				return err
+			}
+			layout.merge(fromFile)
+		}
+		for _, p := range inputStorage.Patterns {
+			layout.merge(patterns[p])
+		}
+
+		rpc, err := util.DialRPC(ctx, inputStorage.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to dial rpc")
+			return err
+		}
+		defer rpc.Close()
+
+		// The block is a tag or a number, which is sent as a hex quantity.
+		block := inputStorage.Block
+		if number, err := strconv.ParseUint(block, 0, 64); err == nil {
+			block = hexutil.EncodeUint64(number)
+		}
+
+		r := &reader{
+			ctx:     ctx,
+			rpc:     rpc,
+			address: common.HexToAddress(args[0]),
+			block:   block,
+			cache:   make(map[common.Hash]common.Hash),
+		}
+
+		entries, err := readStorage(r, layout)
+		if err != nil {
+			return err
+		}
+		return printEntries(entries)
+	},
+}
+
+func readStorage(r *reader, layout *storageLayout) ([]entry, error) {
+	var entries []entry
+	for _, s := range inputStorage.Slots {
+		slot, err := parseSlot(s)
+		if err != nil {
+			return nil, err
+		}
+		word, err := r.word(slot)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{Name: "slot " + s, Type: "bytes32", Slot: slotHash(slot), Value: word.Hex()})
+	}
+
+	// Without paths, every variable is read, except for the values of the
+	// mappings which need keys.
+	if len(inputStorage.Reads) == 0 {
+		for _, v := range layout.Storage {
+			read, err := readVariable(r, layout, v, nil)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, read...)
+		}
+		return entries, nil
+	}
+
+	for _, path := range inputStorage.Reads {
+		name, segments, err := parsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, v := range layout.Storage {
+			if v.Label != name {
+				continue
+			}
+			read, err := readVariable(r, layout, v, segments)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s: %w", path, err)
+			}
+			entries = append(entries, read...)
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("the variable %s isn't in the storage layout", name)
+		}
+	}
+	return entries, nil
+}
+
+// parsePath splits a path like `_allowances[0xabc][0xdef]` or `slot0.tick`
+// into the variable name and the segments after it.
+func parsePath(path string) (string, []segment, error) {
+	end := strings.IndexAny(path, "[.")
+	if end == 0 {
+		return "", nil, fmt.Errorf("the path %s doesn't start with a variable name", path)
+	}
+	if end < 0 {
+		return path, nil, nil
+	}
+
+	name, rest := path[:end], path[end:]
+	var segments []segment
+	for len(rest) > 0 {
+		match := pathSegmentRegex.FindString(rest)
+		if match == "" {
+			return "", nil, fmt.Errorf("the path %s is invalid at %s", path, rest)
+		}
+		if match[0] == '[' {
+			segments = append(segments, segment{Key: match[1 : len(match)-1]})
+		} else {
+			segments = append(segments, segment{Member: match[1:]})
+		}
+		rest = rest[len(match):]
+	}
+	return name, segments, nil
+}
+
+func readVariable(r *reader, layout *storageLayout, v storageVariable, segments []segment) ([]entry, error) {
+	slot, err := parseSlot(v.Slot)
+	if err != nil {
+		return nil, err
+	}
+	return readValue(r, layout, v.Label, v.Type, slot, v.Offset, segments)
+}
+
+// readValue reads the value of the type stored at the slot and offset. The
+// segments are followed first, and whatever they point to is read.
+func readValue(r *reader, layout *storageLayout, name, typeID string, slot *big.Int, offset int, segments []segment) ([]entry, error) {
+	t, err := layout.typeOf(typeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) > 0 {
+		s := segments[0]
+		switch {
+		case s.Member != "":
+			for _, m := range t.Members {
+				if m.Label != s.Member {
+					continue
+				}
+				memberSlot, err := parseSlot(m.Slot)
+				if err != nil {
+					return nil, err
+				}
+				return readValue(r, layout, name+"."+m.Label, m.Type, addSlots(slot, memberSlot.Int64()), m.Offset, segments[1:])
+			}
+			return nil, fmt.Errorf("%s has no member %s", t.Label, s.Member)
+
+		case t.Encoding == "mapping":
+			keyType, err := layout.typeOf(t.Key)
+			if err != nil {
+				return nil, err
+			}
+			valueSlot, err := mappingSlot(slot, keyType, s.Key)
+			if err != nil {
+				return nil, err
+			}
+			return readValue(r, layout, fmt.Sprintf("%s[%s]", name, s.Key), t.Value, valueSlot, 0, segments[1:])
+
+		case t.Encoding == "dynamic_array" || t.Base != "":
+			index, err := strconv.ParseInt(s.Key, 0, 64)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("the index %s is invalid", s.Key)
+			}
+			length, start, err := arrayBounds(r, t, slot)
+			if err != nil {
+				return nil, err
+			}
+			if length >= 0 && index >= length {
+				return nil, fmt.Errorf("the index %d is out of the bounds of %s, which has %d elements", index, name, length)
+			}
+			base, err := layout.typeOf(t.Base)
+			if err != nil {
+				return nil, err
+			}
+			elementSlot, elementOffset := elementLocation(base, start, index)
+			return readValue(r, layout, fmt.Sprintf("%s[%d]", name, index), t.Base, elementSlot, elementOffset, segments[1:])
+		}
+		return nil, fmt.Errorf("%s of type %s can't be indexed", name, t.Label)
+	}
+
+	switch {
+	case t.Encoding == "mapping":
+		return []entry{{Name: name, Type: t.Label, Slot: slotHash(slot), Value: fmt.Sprintf("mapping, read its values with --read '%s[key]'", name)}}, nil
+
+	case t.Encoding == "bytes":
+		value, err := readBytes(r, t, slot)
+		if err != nil {
+			return nil, err
+		}
+		return []entry{{Name: name, Type: t.Label, Slot: slotHash(slot), Value: value}}, nil
+
+	case len(t.Members) > 0:
+		var entries []entry
+		for _, m := range t.Members {
+			memberSlot, err := parseSlot(m.Slot)
+			if err != nil {
+				return nil, err
+			}
+			read, err := readValue(r, layout, name+"."+m.Label, m.Type, addSlots(slot, memberSlot.Int64()), m.Offset, nil)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, read...)
+		}
+		return entries, nil
+
+	case t.Encoding == "dynamic_array" || t.Base != "":
+		length, start, err := arrayBounds(r, t, slot)
+		if err != nil {
+			return nil, err
+		}
+		var entries []entry
+		if t.Encoding == "dynamic_array" {
+			entries = append(entries, entry{Name: name + ".length", Type: "uint256", Slot: slotHash(slot), Value: fmt.Sprint(length)})
+		}
+		base, err := layout.typeOf(t.Base)
+		if err != nil {
+			return nil, err
+		}
+		for i := int64(0); i < length && i < int64(inputStorage.MaxElements); i++ {
+			elementSlot, elementOffset := elementLocation(base, start, i)
+			read, err := readValue(r, layout, fmt.Sprintf("%s[%d]", name, i), t.Base, elementSlot, elementOffset, nil)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, read...)
+		}
+		if length > int64(inputStorage.MaxElements) {
+			log.Info().Str("name", name).Int64("length", length).Msgf("Only the first %d elements were read", inputStorage.MaxElements)
+		}
+		return entries, nil
+	}
+
+	word, err := r.word(slot)
+	if err != nil {
+		return nil, err
+	}
+	return []entry{{Name: name, Type: t.Label, Slot: slotHash(slot), Offset: offset, Value: decodeValue(t, word, offset)}}, nil
+}
+
+// arrayBounds returns the length of the array and the slot of its first
+// element. The length of a dynamic array is stored at its slot and its
+// elements start at keccak256(slot), while a static array is stored in place.
+func arrayBounds(r *reader, t storageType, slot *big.Int) (int64, *big.Int, error) {
+	if t.Encoding != "dynamic_array" {
+		length := int64(-1)
+		if match := arrayLengthRegex.FindStringSubmatch(t.Label); match != nil {
+			length, _ = strconv.ParseInt(match[1], 10, 64)
+		}
+		return length, slot, nil
+	}
+
+	word, err := r.word(slot)
+	if err != nil {
+		return 0, nil, err
+	}
+	length := word.Big()
+	if !length.IsInt64() {
+		return 0, nil, fmt.Errorf("the array length %s is invalid", length)
+	}
+	return length.Int64(), dataSlot(slot), nil
+}
+
+// elementLocation returns the slot and offset of an element of an array.
+// Elements smaller than a slot are packed, while larger ones start a new slot.
+func elementLocation(base storageType, start *big.Int, index int64) (*big.Int, int) {
+	size := int64(base.size())
+	if size < common.HashLength && base.Encoding == "inplace" && len(base.Members) == 0 && base.Base == "" {
+		perSlot := common.HashLength / size
+		return addSlots(start, index/perSlot), int((index % perSlot) * size)
+	}
+	slots := (size + common.HashLength - 1) / common.HashLength
+	return addSlots(start, index*slots), 0
+}
+
+// readBytes reads a string or bytes. Up to 31 bytes are stored in the slot
+// along with twice the length, otherwise the slot has twice the length plus
+// one and the data starts at keccak256(slot).
+func readBytes(r *reader, t storageType, slot *big.Int) (string, error) {
+	word, err := r.word(slot)
+	if err != nil {
+		return "", err
+	}
+
+	var data []byte
+	if word[common.HashLength-1]&1 == 0 {
+		length := int(word[common.HashLength-1] / 2)
+		data = word[:length]
+	} else {
+		length := new(big.Int).Rsh(word.Big(), 1)
+		if !length.IsInt64() {
+			return "", fmt.Errorf("the length %s is invalid", length)
+		}
+		size := int(length.Int64())
+		if size > inputStorage.MaxBytes {
+			log.Info().Int("length", size).Msgf("Only the first %d bytes were read", inputStorage.MaxBytes)
+			size = inputStorage.MaxBytes
+		}
+		start := dataSlot(slot)
+		for i := 0; len(data) < size; i++ {
+			chunk, err := r.word(addSlots(start, int64(i)))
+			if err != nil {
+				return "", err
+			}
+			data = append(data, chunk[:]...)
+		}
+		data = data[:size]
+	}
+
+	if t.Label == "string" {
+		return strconv.Quote(string(data)), nil
+	}
+	return hexutil.Encode(data), nil
+}
+
+func (r *reader) word(slot *big.Int) (common.Hash, error) {
+	key := slotHash(slot)
+	if word, ok := r.cache[key]; ok {
+		return word, nil
+	}
+
+	// Some endpoints strip the leading zeros of the value.
+	var raw string
+	if err := r.rpc.CallContext(r.ctx, &raw, "eth_getStorageAt", r.address, key, r.block); err != nil {
+		return common.Hash{}, fmt.Errorf("unable to read the slot %s: %w", key, err)
+	}
+	value := new(big.Int)
+	if digits := strings.TrimPrefix(raw, "0x"); len(digits) > 0 {
+		if _, ok := value.SetString(digits, 16); !ok || value.BitLen() > 256 {
+			return common.Hash{}, fmt.Errorf("the value %s of the slot %s is invalid", raw, key)
+		}
+	}
+	word := common.BigToHash(value)
+	r.cache[key] = word
+	return word, nil
+}
+
+func printEntries(entries []entry) error {
+	if util.JSONOutput() {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	for _, e := range entries {
+		location := e.Slot.Hex()
+		if e.Offset > 0 {
+			location = fmt.Sprintf("%s+%d", location, e.Offset)
+		}
+		fmt.Printf("%s (%s)\n  slot:  %s\n  value: %s\n", e.Name, e.Type, location, e.Value)
+	}
+	return nil
+}
+
+func init() {
+	flagSet := StorageCmd.PersistentFlags()
+	flagSet.StringVarP(&inputStorage.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint url")
+	flagSet.StringVarP(&inputStorage.Block, "block", "b", "latest", "The block number or tag the storage is read at")
+	flagSet.StringVar(&inputStorage.LayoutFile, "layout", "", "The storage layout output by solc, or a contract output with a storageLayout field")
+	flagSet.StringSliceVar(&inputStorage.Patterns, "pattern", nil, fmt.Sprintf("The common layouts to read without a layout file: %s", strings.Join(patternNames(), ", ")))
+	flagSet.StringArrayVar(&inputStorage.Reads, "read", nil, "A variable to read, with the mapping keys, array indexes, and struct members to follow, e.g. '_allowances[0xabc][0xdef]'. Repeat the flag for more. By default every variable is read")
+	flagSet.StringSliceVar(&inputStorage.Slots, "slot", nil, "Raw slots to read")
+	flagSet.IntVar(&inputStorage.MaxElements, "max-elements", 10, "The number of elements read of each array")
+	flagSet.IntVar(&inputStorage.MaxBytes, "max-bytes", 1024, "The number of bytes read of each long string or bytes")
+}
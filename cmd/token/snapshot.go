@@ -0,0 +1,170 @@
+package token
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/multicall"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type rpcLog struct {
+	Topics []common.Hash `json:"topics"`
+}
+
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+var TokenSnapshotCmd = &cobra.Command{
+	Use:   "snapshot 0xtoken",
+	Short: "Write the holders of a token and their balances to CSV.",
+	Args:  tokenAddressArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if inputToken.ChunkSize < 1 {
+			return fmt.Errorf("the chunk size must be at least 1")
+		}
+
+		rpc, err := dial(ctx)
+		if err != nil {
+			return err
+		}
+		defer rpc.Close()
+		toBlock, err := resolveBlock(ctx, rpc, inputToken.ToBlock)
+		if err != nil {
+			return err
+		}
+		if inputToken.FromBlock > toBlock {
+			return fmt.Errorf("the from block %d is after the to block %d", inputToken.FromBlock, toBlock)
+		}
+
+		// The balances are read at the last scanned block, so that every
+		// holder the logs show is accounted for.
+		reader, err := multicall.NewReader(ctx, rpc, inputToken.MulticallAddress, inputToken.BatchSize)
+		if err != nil {
+			return err
+		}
+		c := &caller{reader: reader, block: hexutil.EncodeUint64(toBlock)}
+
+		token := common.HexToAddress(args[0])
+		md, err := getMetadata(ctx, c, token)
+		if err != nil {
+			return err
+		}
+		holders, err := getHolders(ctx, rpc, token, inputToken.FromBlock, toBlock)
+		if err != nil {
+			return err
+		}
+		log.Info().Int("holders", len(holders)).Msg("Reading balances")
+		balances, err := getBalances(ctx, c, token, holders)
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if inputToken.Output != "" {
+			f, err := os.Create(inputToken.Output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		return writeSnapshot(out, md, balances)
+	},
+}
+
+// resolveBlock turns a block tag into a number, so that the logs and the
+// balances are read at the same block even as the chain moves on.
+func resolveBlock(ctx context.Context, rpc *ethrpc.Client, block string) (uint64, error) {
+	if number, err := strconv.ParseUint(block, 0, 64); err == nil {
+		return number, nil
+	}
+	var header struct {
+		Number hexutil.Uint64 `json:"number"`
+	}
+	if err := rpc.CallContext(ctx, &header, "eth_getBlockByNumber", block, false); err != nil {
+		return 0, fmt.Errorf("unable to get the %s block: %w", block, err)
+	}
+	return uint64(header.Number), nil
+}
+
+// getHolders returns every address that sent or received the token in the
+// block range, in the order they first appear.
+func getHolders(ctx context.Context, rpc *ethrpc.Client, token common.Address, from, to uint64) ([]common.Address, error) {
+	seen := make(map[common.Address]struct{})
+	var holders []common.Address
+	add := func(topic common.Hash) {
+		a := common.BytesToAddress(topic.Bytes())
+		if a == (common.Address{}) {
+			return
+		}
+		if _, ok := seen[a]; ok {
+			return
+		}
+		seen[a] = struct{}{}
+		holders = append(holders, a)
+	}
+
+	for start := from; start <= to; start += inputToken.ChunkSize {
+		end := min(start+inputToken.ChunkSize-1, to)
+		filter := map[string]interface{}{
+			"fromBlock": hexutil.EncodeUint64(start),
+			"toBlock":   hexutil.EncodeUint64(end),
+			"address":   token,
+			"topics":    []interface{}{transferTopic},
+		}
+
+		var logs []rpcLog
+		if err := rpc.CallContext(ctx, &logs, "eth_getLogs", filter); err != nil {
+			return nil, fmt.Errorf("unable to get the logs of blocks %d to %d: %w", start, end, err)
+		}
+		for _, l := range logs {
+			// ERC-721 transfers have the same signature with an indexed
+			// token id, so only the ERC-20 ones with three topics count.
+			if len(l.Topics) != 3 {
+				continue
+			}
+			add(l.Topics[1])
+			add(l.Topics[2])
+		}
+		log.Debug().Uint64("start", start).Uint64("end", end).Int("logs", len(logs)).Int("holders", len(holders)).Msg("Scanned logs")
+
+		if end == to {
+			break
+		}
+	}
+	return holders, nil
+}
+
+// writeSnapshot writes the balances from largest to smallest, both raw and in
+// whole tokens.
+func writeSnapshot(w io.Writer, md *metadata, balances []balance) error {
+	sort.SliceStable(balances, func(i, j int) bool {
+		return balances[i].Balance.Cmp(balances[j].Balance) > 0
+	})
+
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"address", "balance", "amount"}); err != nil {
+		return err
+	}
+	for _, b := range balances {
+		if b.Balance.Sign() == 0 && !inputToken.IncludeZero {
+			continue
+		}
+		if err := out.Write([]string{b.Address.Hex(), b.Balance.String(), md.units(b.Balance)}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
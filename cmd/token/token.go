@@ -0,0 +1,347 @@
+package token
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "embed"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/contracts/tokens"
+	"github.com/maticnetwork/polygon-cli/multicall"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	tokenParams struct {
+		RPCURL           string
+		Block            string
+		MulticallAddress string
+		BatchSize        int
+
+		Addresses     []string
+		AddressesFile string
+
+		FromBlock   uint64
+		ToBlock     string
+		ChunkSize   uint64
+		Output      string
+		IncludeZero bool
+	}
+
+	// metadata is the ERC-20 metadata of a token. Any of the optional
+	// fields is empty if the token doesn't implement it.
+	metadata struct {
+		Address     common.Address `json:"address"`
+		Name        string         `json:"name,omitempty"`
+		Symbol      string         `json:"symbol,omitempty"`
+		Decimals    *uint8         `json:"decimals,omitempty"`
+		TotalSupply *big.Int       `json:"totalSupply"`
+	}
+
+	balance struct {
+		Address common.Address `json:"address"`
+		Balance *big.Int       `json:"balance"`
+	}
+
+	// caller runs the calls of a command at the same block.
+	caller struct {
+		reader *multicall.Reader
+		block  string
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage      string
+	inputToken tokenParams
+
+	erc20ABI *abi.ABI
+)
+
+var TokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Inspect ERC-20 tokens and snapshot their holders.",
+	Long:  usage,
+}
+
+var TokenInspectCmd = &cobra.Command{
+	Use:   "inspect 0xtoken",
+	Short: "Read the metadata, total supply, and balances of a token.",
+	Args:  tokenAddressArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		addresses, err := loadAddresses()
+		if err != nil {
+			return err
+		}
+
+		rpc, err := dial(ctx)
+		if err != nil {
+			return err
+		}
+		defer rpc.Close()
+		reader, err := multicall.NewReader(ctx, rpc, inputToken.MulticallAddress, inputToken.BatchSize)
+		if err != nil {
+			return err
+		}
+		c := &caller{reader: reader, block: blockParam(inputToken.Block)}
+
+		token := common.HexToAddress(args[0])
+		md, err := getMetadata(ctx, c, token)
+		if err != nil {
+			return err
+		}
+		balances, err := getBalances(ctx, c, token, addresses)
+		if err != nil {
+			return err
+		}
+
+		if util.JSONOutput() {
+			out, err := json.MarshalIndent(struct {
+				*metadata
+				Balances []balance `json:"balances,omitempty"`
+			}{md, balances}, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+
+		fmt.Printf("Token:        %s\n", md.Address.Hex())
+		fmt.Printf("Name:         %s\n", md.Name)
+		fmt.Printf("Symbol:       %s\n", md.Symbol)
+		if md.Decimals != nil {
+			fmt.Printf("Decimals:     %d\n", *md.Decimals)
+		}
+		fmt.Printf("Total supply: %s\n", md.format(md.TotalSupply))
+		for _, b := range balances {
+			fmt.Printf("%s %s\n", b.Address.Hex(), md.format(b.Balance))
+		}
+		return nil
+	},
+}
+
+func tokenAddressArg(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one token address")
+	}
+	if !common.IsHexAddress(args[0]) {
+		return fmt.Errorf("the token address %s is invalid", args[0])
+	}
+	return nil
+}
+
+// dial connects to the endpoint once the flags are checked.
+func dial(ctx context.Context) (*ethrpc.Client, error) {
+	var err error
+	if erc20ABI, err = tokens.ERC20MetaData.GetAbi(); err != nil {
+		return nil, err
+	}
+
+	rpc, err := util.DialRPC(ctx, inputToken.RPCURL)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to dial rpc")
+		return nil, err
+	}
+	return rpc, nil
+}
+
+// blockParam converts a block number to the hex the endpoint expects, and
+// leaves tags as they are.
+func blockParam(block string) string {
+	if number, err := strconv.ParseUint(block, 0, 64); err == nil {
+		return hexutil.EncodeUint64(number)
+	}
+	return block
+}
+
+// getMetadata reads the metadata and total supply in a single batch. Only
+// the total supply is required since name, symbol, and decimals are optional
+// in ERC-20.
+func getMetadata(ctx context.Context, c *caller, token common.Address) (*metadata, error) {
+	methods := []string{"name", "symbol", "decimals", "totalSupply"}
+	calls := make([]multicall.Call, 0, len(methods))
+	for _, method := range methods {
+		data, err := erc20ABI.Pack(method)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, multicall.Call{Target: token, Data: data})
+	}
+	results, err := c.reader.Call(ctx, c.block, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &metadata{Address: token}
+	md.Name = decodeString(erc20ABI, "name", results[0])
+	md.Symbol = decodeString(erc20ABI, "symbol", results[1])
+	if out, err := unpack(erc20ABI, "decimals", results[2]); err == nil {
+		decimals := out[0].(uint8)
+		md.Decimals = &decimals
+	}
+	out, err := unpack(erc20ABI, "totalSupply", results[3])
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the total supply of %s, it may not be an ERC-20 token: %w", token, err)
+	}
+	md.TotalSupply = out[0].(*big.Int)
+	return md, nil
+}
+
+// getBalances reads the balances of the addresses. A balance that can't be
+// read fails the whole read, since a missing balance in a snapshot would go
+// unnoticed.
+func getBalances(ctx context.Context, c *caller, token common.Address, addresses []common.Address) ([]balance, error) {
+	calls := make([]multicall.Call, 0, len(addresses))
+	for _, a := range addresses {
+		data, err := erc20ABI.Pack("balanceOf", a)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, multicall.Call{Target: token, Data: data})
+	}
+	results, err := c.reader.Call(ctx, c.block, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]balance, 0, len(addresses))
+	for i, r := range results {
+		out, err := unpack(erc20ABI, "balanceOf", r)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the balance of %s: %w", addresses[i], err)
+		}
+		balances = append(balances, balance{Address: addresses[i], Balance: out[0].(*big.Int)})
+	}
+	return balances, nil
+}
+
+func unpack(parsed *abi.ABI, method string, r multicall.Result) ([]interface{}, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	out, err := parsed.Unpack(method, r.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != 1 {
+		return nil, fmt.Errorf("%s returned %d values", method, len(out))
+	}
+	return out, nil
+}
+
+// decodeString decodes a string, or a bytes32 as returned by the name and
+// symbol of some older tokens like MKR.
+func decodeString(parsed *abi.ABI, method string, r multicall.Result) string {
+	if out, err := unpack(parsed, method, r); err == nil {
+		return out[0].(string)
+	}
+	if r.Err == nil && len(r.Data) == common.HashLength {
+		return string(bytes.TrimRight(r.Data, "\x00"))
+	}
+	log.Debug().Err(r.Err).Str("method", method).Msg("Unable to read the metadata")
+	return ""
+}
+
+// format formats an amount of the token with its decimals, followed by the
+// raw amount.
+func (md *metadata) format(amount *big.Int) string {
+	if md.Decimals == nil || *md.Decimals == 0 {
+		return amount.String()
+	}
+	return fmt.Sprintf("%s %s (%s)", md.units(amount), md.Symbol, amount)
+}
+
+// units returns the amount in whole tokens, without trailing zeros.
+func (md *metadata) units(amount *big.Int) string {
+	if md.Decimals == nil || *md.Decimals == 0 {
+		return amount.String()
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(*md.Decimals)), nil)
+	whole, frac := new(big.Int).QuoRem(new(big.Int).Abs(amount), unit, new(big.Int))
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+	if frac.Sign() == 0 {
+		return sign + whole.String()
+	}
+	digits := fmt.Sprintf("%0*s", int(*md.Decimals), frac.String())
+	return sign + whole.String() + "." + strings.TrimRight(digits, "0")
+}
+
+// loadAddresses combines the addresses from the flag and the file, without
+// duplicates.
+func loadAddresses() ([]common.Address, error) {
+	raw := append([]string{}, inputToken.Addresses...)
+
+	if inputToken.AddressesFile != "" {
+		f, err := os.Open(inputToken.AddressesFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			raw = append(raw, line)
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[common.Address]struct{}, len(raw))
+	addresses := make([]common.Address, 0, len(raw))
+	for _, r := range raw {
+		if !common.IsHexAddress(r) {
+			return nil, fmt.Errorf("the address %s is invalid", r)
+		}
+		a := common.HexToAddress(r)
+		if _, ok := seen[a]; ok {
+			continue
+		}
+		seen[a] = struct{}{}
+		addresses = append(addresses, a)
+	}
+	return addresses, nil
+}
+
+func init() {
+	flagSet := TokenCmd.PersistentFlags()
+	flagSet.StringVarP(&inputToken.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint url")
+	flagSet.StringVar(&inputToken.MulticallAddress, "multicall-address", multicall.DefaultAddress, "The Multicall3 contract the calls are batched with. Without a contract at the address, or if empty, the calls are batched as JSON-RPC requests instead")
+	flagSet.IntVar(&inputToken.BatchSize, "batch-size", multicall.DefaultBatchSize, "The number of calls per batch")
+
+	inspectFlags := TokenInspectCmd.Flags()
+	inspectFlags.StringVarP(&inputToken.Block, "block", "b", "latest", "The block number or tag the token is read at")
+	inspectFlags.StringSliceVar(&inputToken.Addresses, "addresses", nil, "A comma separated list of addresses to read the balances of")
+	inspectFlags.StringVar(&inputToken.AddressesFile, "addresses-file", "", "A file with one address to read the balance of per line")
+
+	snapshotFlags := TokenSnapshotCmd.Flags()
+	snapshotFlags.Uint64Var(&inputToken.FromBlock, "from-block", 0, "The first block scanned for Transfer logs")
+	snapshotFlags.StringVar(&inputToken.ToBlock, "to-block", "latest", "The last block scanned for Transfer logs, at which the balances are read")
+	snapshotFlags.Uint64Var(&inputToken.ChunkSize, "chunk-size", 2000, "The number of blocks per eth_getLogs request")
+	snapshotFlags.StringVarP(&inputToken.Output, "output", "o", "", "The CSV file the holders are written to, or stdout if empty")
+	snapshotFlags.BoolVar(&inputToken.IncludeZero, "include-zero", false, "Include the addresses that no longer hold the token")
+
+	TokenCmd.AddCommand(TokenInspectCmd)
+	TokenCmd.AddCommand(TokenSnapshotCmd)
+}
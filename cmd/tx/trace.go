@@ -0,0 +1,125 @@
+package tx
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/maticnetwork/polygon-cli/util"
+)
+
+// callFrame is a call in the output of the callTracer.
+type callFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to"`
+	Value        *hexutil.Big    `json:"value"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output"`
+	Error        string          `json:"error"`
+	RevertReason string          `json:"revertReason"`
+	Calls        []callFrame     `json:"calls"`
+}
+
+// traceSummary counts the internal calls in a trace.
+type traceSummary struct {
+	calls    int
+	failed   int
+	maxDepth int
+	types    map[string]int
+}
+
+func (s *traceSummary) add(frame *callFrame, depth int) {
+	s.calls++
+	s.types[frame.Type]++
+	if frame.Error != "" {
+		s.failed++
+	}
+	if depth > s.maxDepth {
+		s.maxDepth = depth
+	}
+	for i := range frame.Calls {
+		s.add(&frame.Calls[i], depth+1)
+	}
+}
+
+func printTrace(abi *gethabi.ABI, frame *callFrame) {
+	summary := &traceSummary{types: make(map[string]int)}
+	summary.add(frame, 0)
+
+	types := make([]string, 0, len(summary.types))
+	for t, n := range summary.types {
+		types = append(types, fmt.Sprintf("%s=%d", t, n))
+	}
+	sort.Strings(types)
+
+	util.PrintSection("Trace")
+	util.PrintField("Internal calls", fmt.Sprint(summary.calls-1))
+	util.PrintField("Call types", strings.Join(types, " "))
+	util.PrintField("Max depth", fmt.Sprint(summary.maxDepth))
+	util.PrintField("Failed calls", fmt.Sprint(summary.failed))
+	fmt.Println()
+	printFrame(abi, frame, 0)
+}
+
+func printFrame(abi *gethabi.ABI, frame *callFrame, depth int) {
+	if inputTx.TraceDepth > 0 && depth > inputTx.TraceDepth {
+		return
+	}
+
+	to := "new contract"
+	if frame.To != nil {
+		to = frame.To.Hex()
+	}
+	line := fmt.Sprintf("%s%s %s -> %s gasUsed=%d", strings.Repeat("  ", depth+1), frame.Type, frame.From.Hex(), to, uint64(frame.GasUsed))
+	if frame.Value != nil && frame.Value.ToInt().Sign() > 0 {
+		line += " value=" + util.FormatWei(frame.Value.ToInt(), "ether", 18)
+	}
+	if len(frame.Input) >= 4 {
+		line += " selector=" + hexutil.Encode(frame.Input[:4])
+	}
+	if frame.Error != "" {
+		line += fmt.Sprintf(" error=%q", frame.Error)
+		if reason := revertReason(abi, frame); reason != "" {
+			line += " revert=" + reason
+		}
+	}
+	fmt.Println(line)
+
+	for i := range frame.Calls {
+		printFrame(abi, &frame.Calls[i], depth+1)
+	}
+}
+
+// revertReason decodes the output of a reverted call. The standard errors are
+// decoded without an ABI.
+func revertReason(abi *gethabi.ABI, frame *callFrame) string {
+	if frame.RevertReason != "" {
+		return fmt.Sprintf("%q", frame.RevertReason)
+	}
+	if len(frame.Output) < 4 {
+		return ""
+	}
+	if abi == nil {
+		abi = &gethabi.ABI{}
+	}
+	decoded, err := util.DecodeCallOrError(abi, frame.Output)
+	if err != nil || decoded.Type != "error" {
+		return hexutil.Encode(frame.Output)
+	}
+
+	values := make([]string, 0, len(decoded.Values))
+	for k, v := range decoded.Values {
+		if b, ok := v.(*big.Int); ok {
+			v = b.String()
+		}
+		values = append(values, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(values)
+	return fmt.Sprintf("%s{%s}", decoded.Signature, strings.Join(values, " "))
+}
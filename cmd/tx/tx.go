@@ -0,0 +1,438 @@
+package tx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	_ "embed"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+type (
+	txParams struct {
+		RPCURL          string
+		ABIFile         string
+		FetchABI        bool
+		SourcifyURL     string
+		EtherscanURL    string
+		EtherscanAPIKey string
+		Trace           bool
+		TraceDepth      int
+	}
+
+	rpcAccessTuple struct {
+		Address     common.Address `json:"address"`
+		StorageKeys []common.Hash  `json:"storageKeys"`
+	}
+	rpcTransaction struct {
+		Type                 *hexutil.Uint64   `json:"type"`
+		Hash                 common.Hash       `json:"hash"`
+		From                 common.Address    `json:"from"`
+		To                   *common.Address   `json:"to"`
+		Nonce                hexutil.Uint64    `json:"nonce"`
+		Value                *hexutil.Big      `json:"value"`
+		Gas                  hexutil.Uint64    `json:"gas"`
+		GasPrice             *hexutil.Big      `json:"gasPrice"`
+		MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas"`
+		MaxFeePerBlobGas     *hexutil.Big      `json:"maxFeePerBlobGas"`
+		Input                hexutil.Bytes     `json:"input"`
+		ChainID              *hexutil.Big      `json:"chainId"`
+		AccessList           []rpcAccessTuple  `json:"accessList"`
+		BlobVersionedHashes  []common.Hash     `json:"blobVersionedHashes"`
+		AuthorizationList    []json.RawMessage `json:"authorizationList"`
+		BlockNumber          *hexutil.Big      `json:"blockNumber"`
+		TransactionIndex     *hexutil.Uint64   `json:"transactionIndex"`
+		V                    *hexutil.Big      `json:"v"`
+		R                    *hexutil.Big      `json:"r"`
+		S                    *hexutil.Big      `json:"s"`
+	}
+	rpcLog struct {
+		Address common.Address `json:"address"`
+		Topics  []common.Hash  `json:"topics"`
+		Data    hexutil.Bytes  `json:"data"`
+	}
+	rpcReceipt struct {
+		Status            *hexutil.Uint64 `json:"status"`
+		GasUsed           hexutil.Uint64  `json:"gasUsed"`
+		EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice"`
+		ContractAddress   *common.Address `json:"contractAddress"`
+		BlobGasUsed       *hexutil.Uint64 `json:"blobGasUsed"`
+		BlobGasPrice      *hexutil.Big    `json:"blobGasPrice"`
+		Logs              []rpcLog        `json:"logs"`
+	}
+	rpcBlock struct {
+		Timestamp     hexutil.Uint64 `json:"timestamp"`
+		BaseFeePerGas *hexutil.Big   `json:"baseFeePerGas"`
+	}
+
+	// txResult is what's printed with --json. The input and logs are only
+	// decoded when the ABI of the recipient is known, and the logs that
+	// weren't decoded are null.
+	txResult struct {
+		Transaction   *rpcTransaction        `json:"transaction"`
+		TypeName      string                 `json:"typeName"`
+		Receipt       *rpcReceipt            `json:"receipt,omitempty"`
+		BaseFeePerGas *hexutil.Big           `json:"baseFeePerGas,omitempty"`
+		Input         *util.DecodedABIData   `json:"input,omitempty"`
+		Logs          []*util.DecodedABIData `json:"logs,omitempty"`
+		Trace         *callFrame             `json:"trace,omitempty"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage   string
+	inputTx txParams
+)
+
+var TxCmd = &cobra.Command{
+	Use:   "tx 0xhash",
+	Short: "Decode, trace, and explain a transaction.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected exactly one transaction hash")
+		}
+		if b, err := hexutil.Decode(args[0]); err != nil || len(b) != common.HashLength {
+			return fmt.Errorf("the transaction hash %s is invalid", args[0])
+		}
+		if inputTx.ABIFile != "" && inputTx.FetchABI {
+			return fmt.Errorf("--abi-file and --fetch-abi can't be used together")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		rpc, err := util.DialRPC(ctx, inputTx.RPCURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to dial rpc")
+			return err
+		}
+		defer rpc.Close()
+
+		return explainTransaction(ctx, rpc, common.HexToHash(args[0]))
+	},
+}
+
+func explainTransaction(ctx context.Context, rpc *ethrpc.Client, hash common.Hash) error {
+	var tx *rpcTransaction
+	if err := rpc.CallContext(ctx, &tx, "eth_getTransactionByHash", hash); err != nil {
+		return fmt.Errorf("unable to fetch the transaction: %w", err)
+	}
+	if tx == nil {
+		return fmt.Errorf("the transaction %s wasn't found", hash)
+	}
+
+	var (
+		receipt *rpcReceipt
+		block   *rpcBlock
+	)
+	if tx.BlockNumber != nil {
+		if err := rpc.CallContext(ctx, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+			return fmt.Errorf("unable to fetch the receipt: %w", err)
+		}
+		if err := rpc.CallContext(ctx, &block, "eth_getBlockByNumber", tx.BlockNumber, false); err != nil {
+			return fmt.Errorf("unable to fetch the block: %w", err)
+		}
+	}
+
+	abi, err := resolveABI(ctx, rpc, tx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to resolve the abi")
+	}
+
+	var frame *callFrame
+	if inputTx.Trace {
+		if tx.BlockNumber == nil {
+			return fmt.Errorf("pending transactions can't be traced")
+		}
+		if err = rpc.CallContext(ctx, &frame, "debug_traceTransaction", hash, map[string]interface{}{"tracer": "callTracer"}); err != nil {
+			return fmt.Errorf("unable to trace the transaction: %w", err)
+		}
+	}
+
+	if util.JSONOutput() {
+		result := txResult{
+			Transaction: tx,
+			TypeName:    util.TxTypeName(txType(tx)),
+			Receipt:     receipt,
+			Input:       decodeInput(abi, tx),
+			Trace:       frame,
+		}
+		if block != nil {
+			result.BaseFeePerGas = block.BaseFeePerGas
+		}
+		if receipt != nil {
+			for _, l := range receipt.Logs {
+				result.Logs = append(result.Logs, decodeLog(abi, tx, l))
+			}
+		}
+		return util.PrintJSON(result)
+	}
+
+	printEnvelope(tx, receipt)
+	printInput(abi, tx)
+	printGas(tx, receipt, block)
+	if receipt != nil {
+		printLogs(abi, tx, receipt)
+	}
+	if frame != nil {
+		printTrace(abi, frame)
+	}
+	return nil
+}
+
+// resolveABI parses the ABI file or fetches the ABI of the recipient if either
+// is requested. A nil ABI is returned otherwise.
+func resolveABI(ctx context.Context, rpc *ethrpc.Client, tx *rpcTransaction) (*gethabi.ABI, error) {
+	var (
+		raw []byte
+		err error
+	)
+	switch {
+	case inputTx.ABIFile != "":
+		raw, err = os.ReadFile(inputTx.ABIFile)
+	case inputTx.FetchABI && tx.To != nil:
+		source := util.ABISource{
+			SourcifyURL:     inputTx.SourcifyURL,
+			EtherscanURL:    inputTx.EtherscanURL,
+			EtherscanAPIKey: inputTx.EtherscanAPIKey,
+		}
+		if tx.ChainID != nil {
+			source.ChainID = tx.ChainID.ToInt().Uint64()
+		} else {
+			var chainID hexutil.Uint64
+			if err = rpc.CallContext(ctx, &chainID, "eth_chainId"); err != nil {
+				return nil, err
+			}
+			source.ChainID = uint64(chainID)
+		}
+		raw, err = source.FetchABI(ctx, tx.To.Hex())
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	abi, err := gethabi.JSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &abi, nil
+}
+
+// txType returns the type of the transaction, which is legacy if the RPC
+// doesn't report it.
+func txType(tx *rpcTransaction) uint64 {
+	if tx.Type == nil {
+		return 0
+	}
+	return uint64(*tx.Type)
+}
+
+// decodeInput decodes the input of the transaction with the ABI of the
+// recipient. It returns nil if the input can't be decoded.
+func decodeInput(abi *gethabi.ABI, tx *rpcTransaction) *util.DecodedABIData {
+	if abi == nil || tx.To == nil || len(tx.Input) == 0 {
+		return nil
+	}
+	decoded, err := util.DecodeCallOrError(abi, tx.Input)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to decode the input")
+		return nil
+	}
+	return decoded
+}
+
+// decodeLog decodes a log emitted by the recipient with its ABI. The ABI is
+// only for the recipient, so other contracts' logs are left as is and nil is
+// returned.
+func decodeLog(abi *gethabi.ABI, tx *rpcTransaction, l rpcLog) *util.DecodedABIData {
+	if abi == nil || tx.To == nil || l.Address != *tx.To || len(l.Topics) == 0 {
+		return nil
+	}
+	decoded, err := util.DecodeLog(abi, l.Topics, l.Data)
+	if err != nil {
+		log.Debug().Err(err).Str("topic", l.Topics[0].Hex()).Msg("Unable to decode the log")
+		return nil
+	}
+	return decoded
+}
+
+func printEnvelope(tx *rpcTransaction, receipt *rpcReceipt) {
+	util.PrintSection("Transaction")
+	util.PrintField("Hash", tx.Hash.Hex())
+	util.PrintField("Type", fmt.Sprintf("%d (%s)", txType(tx), util.TxTypeName(txType(tx))))
+	switch {
+	case tx.BlockNumber == nil:
+		util.PrintField("Status", "pending")
+	case receipt != nil && receipt.Status != nil && *receipt.Status == 1:
+		util.PrintField("Status", "success")
+	case receipt != nil && receipt.Status != nil:
+		util.PrintField("Status", "failed")
+	}
+	if tx.BlockNumber != nil {
+		util.PrintField("Block", tx.BlockNumber.ToInt().String())
+	}
+	if tx.TransactionIndex != nil {
+		util.PrintField("Index", fmt.Sprint(uint64(*tx.TransactionIndex)))
+	}
+	if tx.ChainID != nil {
+		util.PrintField("Chain ID", tx.ChainID.ToInt().String())
+	}
+	util.PrintField("From", tx.From.Hex())
+	switch {
+	case tx.To != nil:
+		util.PrintField("To", tx.To.Hex())
+	case receipt != nil && receipt.ContractAddress != nil:
+		util.PrintField("To", fmt.Sprintf("contract creation (%s)", receipt.ContractAddress.Hex()))
+	default:
+		util.PrintField("To", "contract creation")
+	}
+	util.PrintField("Nonce", fmt.Sprint(uint64(tx.Nonce)))
+	if tx.Value != nil {
+		util.PrintField("Value", util.FormatWei(tx.Value.ToInt(), "ether", 18))
+	}
+	util.PrintField("Gas limit", fmt.Sprint(uint64(tx.Gas)))
+	if tx.AccessList != nil {
+		keys := 0
+		for _, t := range tx.AccessList {
+			keys += len(t.StorageKeys)
+		}
+		util.PrintField("Access list", fmt.Sprintf("%d addresses, %d storage keys", len(tx.AccessList), keys))
+	}
+	for i, h := range tx.BlobVersionedHashes {
+		util.PrintField(fmt.Sprintf("Blob hash %d", i), h.Hex())
+	}
+	if tx.AuthorizationList != nil {
+		util.PrintField("Authorizations", fmt.Sprint(len(tx.AuthorizationList)))
+	}
+	if tx.V != nil && tx.R != nil && tx.S != nil {
+		util.PrintField("Signature", fmt.Sprintf("v=%s r=%s s=%s", tx.V, tx.R, tx.S))
+	}
+}
+
+func printInput(abi *gethabi.ABI, tx *rpcTransaction) {
+	util.PrintSection("Input")
+	if len(tx.Input) == 0 {
+		util.PrintField("Data", "none")
+		return
+	}
+	util.PrintField("Size", fmt.Sprintf("%d bytes", len(tx.Input)))
+	if len(tx.Input) >= 4 {
+		util.PrintField("Selector", hexutil.Encode(tx.Input[:4]))
+	}
+	if decoded := decodeInput(abi, tx); decoded != nil {
+		printDecoded(decoded)
+	}
+}
+
+// printGas explains how the gas price paid by the transaction was derived.
+// After London, the sender pays the base fee plus a tip which is capped by
+// the max fee, and the base fee is burned.
+func printGas(tx *rpcTransaction, receipt *rpcReceipt, block *rpcBlock) {
+	util.PrintSection("Gas")
+
+	var baseFee *big.Int
+	if block != nil && block.BaseFeePerGas != nil {
+		baseFee = block.BaseFeePerGas.ToInt()
+		util.PrintField("Base fee", util.FormatWei(baseFee, "gwei", 9))
+	}
+
+	var effective *big.Int
+	if tx.MaxFeePerGas != nil && tx.MaxPriorityFeePerGas != nil {
+		maxFee, maxTip := tx.MaxFeePerGas.ToInt(), tx.MaxPriorityFeePerGas.ToInt()
+		util.PrintField("Max fee", util.FormatWei(maxFee, "gwei", 9))
+		util.PrintField("Max priority fee", util.FormatWei(maxTip, "gwei", 9))
+		if baseFee != nil {
+			effective = new(big.Int).Add(baseFee, maxTip)
+			if effective.Cmp(maxFee) > 0 {
+				effective = new(big.Int).Set(maxFee)
+				util.PrintField("Effective price", fmt.Sprintf("%s = max fee (capped)", util.FormatWei(effective, "gwei", 9)))
+			} else {
+				util.PrintField("Effective price", fmt.Sprintf("%s = base fee + max priority fee", util.FormatWei(effective, "gwei", 9)))
+			}
+		}
+	} else if tx.GasPrice != nil {
+		effective = tx.GasPrice.ToInt()
+		util.PrintField("Gas price", util.FormatWei(effective, "gwei", 9))
+	}
+
+	if receipt == nil {
+		return
+	}
+	if receipt.EffectiveGasPrice != nil {
+		reported := receipt.EffectiveGasPrice.ToInt()
+		if effective != nil && effective.Cmp(reported) != 0 {
+			log.Warn().Str("computed", effective.String()).Str("reported", reported.String()).Msg("The effective gas price doesn't match the receipt")
+		}
+		effective = reported
+	}
+	if effective == nil {
+		return
+	}
+
+	gasUsed := new(big.Int).SetUint64(uint64(receipt.GasUsed))
+	util.PrintField("Gas used", fmt.Sprintf("%d (%.2f%% of the limit)", uint64(receipt.GasUsed), 100*float64(receipt.GasUsed)/float64(tx.Gas)))
+	fee := new(big.Int).Mul(gasUsed, effective)
+	util.PrintField("Fee", fmt.Sprintf("%s = gas used * effective price", util.FormatWei(fee, "ether", 18)))
+	if baseFee != nil {
+		burned := new(big.Int).Mul(gasUsed, baseFee)
+		util.PrintField("Burned", fmt.Sprintf("%s = gas used * base fee", util.FormatWei(burned, "ether", 18)))
+		util.PrintField("Tip", fmt.Sprintf("%s = fee - burned", util.FormatWei(new(big.Int).Sub(fee, burned), "ether", 18)))
+	}
+	if receipt.BlobGasUsed != nil && receipt.BlobGasPrice != nil {
+		blobFee := new(big.Int).Mul(new(big.Int).SetUint64(uint64(*receipt.BlobGasUsed)), receipt.BlobGasPrice.ToInt())
+		util.PrintField("Blob gas used", fmt.Sprint(uint64(*receipt.BlobGasUsed)))
+		util.PrintField("Blob gas price", util.FormatWei(receipt.BlobGasPrice.ToInt(), "gwei", 9))
+		util.PrintField("Blob fee", fmt.Sprintf("%s = blob gas used * blob gas price", util.FormatWei(blobFee, "ether", 18)))
+	}
+}
+
+func printLogs(abi *gethabi.ABI, tx *rpcTransaction, receipt *rpcReceipt) {
+	util.PrintSection(fmt.Sprintf("Logs (%d)", len(receipt.Logs)))
+	for i, l := range receipt.Logs {
+		topic0 := "anonymous"
+		if len(l.Topics) > 0 {
+			topic0 = l.Topics[0].Hex()
+		}
+		util.PrintField(fmt.Sprintf("Log %d", i), fmt.Sprintf("%s %s", l.Address.Hex(), topic0))
+		if decoded := decodeLog(abi, tx, l); decoded != nil {
+			printDecoded(decoded)
+		}
+	}
+}
+
+func printDecoded(decoded *util.DecodedABIData) {
+	util.PrintField("Signature", decoded.Signature)
+	values, err := json.MarshalIndent(decoded.Values, "  ", "  ")
+	if err != nil {
+		return
+	}
+	util.PrintField("Values", string(values))
+}
+
+func init() {
+	flagSet := TxCmd.PersistentFlags()
+	flagSet.StringVarP(&inputTx.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint url")
+	flagSet.StringVar(&inputTx.ABIFile, "abi-file", "", "The ABI of the recipient used to decode the input and logs")
+	flagSet.BoolVar(&inputTx.FetchABI, "fetch-abi", false, "Fetch the ABI of the recipient from Sourcify or an Etherscan compatible API")
+	flagSet.StringVar(&inputTx.SourcifyURL, "sourcify-url", "https://sourcify.dev/server", "The Sourcify server used to fetch the ABI")
+	flagSet.StringVar(&inputTx.EtherscanURL, "etherscan-url", "", "The Etherscan compatible API used to fetch the ABI instead of Sourcify")
+	flagSet.StringVar(&inputTx.EtherscanAPIKey, "etherscan-api-key", "", "The API key for the Etherscan compatible API")
+	flagSet.BoolVar(&inputTx.Trace, "trace", false, "Trace the transaction with debug_traceTransaction and summarize the internal calls")
+	flagSet.IntVar(&inputTx.TraceDepth, "trace-depth", 0, "The maximum depth of internal calls to print, or 0 for all")
+}
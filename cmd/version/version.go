@@ -1,6 +1,7 @@
 package version
 
 import (
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/spf13/cobra"
 )
 
@@ -11,12 +12,23 @@ var (
 	BuiltBy = "unknown"
 )
 
+type versionOutput struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+	BuiltBy string `json:"builtBy"`
+}
+
 // versionCmd represents the version command
 var VersionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Get the current version of this application",
 	Long:  `Nothing fancy. Print the version of this application`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if util.JSONOutput() {
+			return util.PrintJSON(versionOutput{Version: Version, Commit: Commit, Date: Date, BuiltBy: BuiltBy})
+		}
 		cmd.Printf("Polygon CLI Version %s\n", Version)
+		return nil
 	},
 }
@@ -1,14 +1,19 @@
 package wallet
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	_ "embed"
 
 	"github.com/maticnetwork/polygon-cli/hdwallet"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
 )
 
 var (
@@ -25,8 +30,15 @@ var (
 	inputAddressesToGenerate *uint
 	inputUseRawEntropy       *bool
 	inputRootOnly            *bool
+	inputStartIndex          *uint
+	inputFormat              *string
 )
 
+// formats are the supported output formats. Apart from json they only
+// include the Ethereum addresses and private keys so they can be fed to
+// other tools.
+var formats = []string{"json", "csv", "env", "keys", "addresses"}
+
 // WalletCmd represents the wallet command
 var WalletCmd = &cobra.Command{
 	Use:   "wallet [create|inspect]",
@@ -78,18 +90,19 @@ var WalletCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
-			out, _ := json.MarshalIndent(key, " ", " ")
-			fmt.Println(string(out))
-			return nil
+			if *inputFormat != "json" {
+				root := &hdwallet.PolyAddressExport{Path: "m"}
+				root.ETHAddress = key.ETHAddress
+				root.HexPrivateKey = key.HexPrivateKey
+				key.Addresses = []*hdwallet.PolyAddressExport{root}
+			}
+			return printExport(os.Stdout, key, *inputFormat)
 		}
-		key, err := pw.ExportHDAddresses(int(*inputAddressesToGenerate))
+		key, err := pw.ExportHDAddressRange(int(*inputStartIndex), int(*inputAddressesToGenerate))
 		if err != nil {
 			return err
 		}
-		// TODO support json vs txt out
-		out, _ := json.MarshalIndent(key, " ", " ")
-		fmt.Println(string(out))
-		return nil
+		return printExport(os.Stdout, key, *inputFormat)
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
@@ -98,10 +111,54 @@ var WalletCmd = &cobra.Command{
 		if args[0] != "create" && args[0] != "inspect" {
 			return fmt.Errorf("expected argument to be create or inspect. Got: %s", args[0])
 		}
+		if err := util.UseJSONFormat(cmd.Flags(), "format", "json"); err != nil {
+			return err
+		}
+		if !slices.Contains(formats, *inputFormat) {
+			return fmt.Errorf("the format must be one of %v", formats)
+		}
 		return nil
 	},
 }
 
+// printExport writes the wallet in the format. The csv, env, keys, and
+// addresses formats only have the derived accounts.
+func printExport(w io.Writer, key *hdwallet.PolyWalletExport, format string) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"path", "address", "private_key"}); err != nil {
+			return err
+		}
+		for _, a := range key.Addresses {
+			if err := cw.Write([]string{a.Path, a.ETHAddress, "0x" + a.HexPrivateKey}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case "env":
+		for i, a := range key.Addresses {
+			fmt.Fprintf(w, "ADDRESS_%d=%s\nPRIVATE_KEY_%d=0x%s\n", i, a.ETHAddress, i, a.HexPrivateKey)
+		}
+	case "keys":
+		for _, a := range key.Addresses {
+			fmt.Fprintf(w, "0x%s\n", a.HexPrivateKey)
+		}
+	case "addresses":
+		for _, a := range key.Addresses {
+			fmt.Fprintln(w, a.ETHAddress)
+		}
+	default:
+		out, err := json.MarshalIndent(key, " ", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(out))
+	}
+	return nil
+}
+
 func getFileOrFlag(filename *string, flag *string) (string, error) {
 	if filename == nil && flag == nil {
 		return "", fmt.Errorf("both the filename and the flag pointers are nil")
@@ -135,4 +192,6 @@ func init() {
 	inputMnemonicFile = WalletCmd.PersistentFlags().String("mnemonic-file", "", "A mneomonic phrase written in a file used to generate entropy")
 	inputUseRawEntropy = WalletCmd.PersistentFlags().Bool("raw-entropy", false, "substrate and polkda dot don't follow strict bip39 and use raw entropy")
 	inputRootOnly = WalletCmd.PersistentFlags().Bool("root-only", false, "don't produce HD accounts. Just produce a single wallet")
+	inputStartIndex = WalletCmd.PersistentFlags().Uint("index", 0, "The index of the first address to generate")
+	inputFormat = WalletCmd.PersistentFlags().String("format", "json", "The output format ["+strings.Join(formats, ", ")+"]")
 }
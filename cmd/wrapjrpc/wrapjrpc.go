@@ -0,0 +1,585 @@
+package wrapjrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	_ "embed"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/maticnetwork/polygon-cli/cmd/rpcfuzz"
+	"github.com/maticnetwork/polygon-cli/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// The error codes of the responses the proxy makes itself.
+const (
+	errInvalidRequest = -32600
+	errInvalidParams  = -32602
+	errInternal       = -32603
+	// errNotRecorded is returned in replay mode for calls that aren't in
+	// the recording.
+	errNotRecorded = -32000
+)
+
+type (
+	wrapParams struct {
+		RPCURL        string
+		Listen        string
+		Record        string
+		Replay        string
+		ReplayLatency bool
+		Corpus        string
+		Latency       time.Duration
+		LatencyJitter time.Duration
+		ErrorRate     float64
+		DropRate      float64
+		FaultMethods  []string
+		Seed          int64
+	}
+
+	jsonrpcRequest struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id,omitempty"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params,omitempty"`
+	}
+
+	jsonrpcError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    any    `json:"data,omitempty"`
+	}
+
+	jsonrpcResponse struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  json.RawMessage `json:"result,omitempty"`
+		Error   *jsonrpcError   `json:"error,omitempty"`
+	}
+
+	// record is a call and its response, which are written to the
+	// recording as a line of JSON.
+	record struct {
+		Time    time.Time       `json:"time"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params,omitempty"`
+		Result  json.RawMessage `json:"result,omitempty"`
+		Error   *jsonrpcError   `json:"error,omitempty"`
+		Latency time.Duration   `json:"latency"`
+		// Fault is the fault that was injected instead of forwarding the
+		// call, if any.
+		Fault string `json:"fault,omitempty"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	usage     string
+	inputWrap wrapParams
+)
+
+var WrapJRPCCmd = &cobra.Command{
+	Use:   "wrapjrpc",
+	Short: "Proxy a JSON-RPC endpoint, recording, replaying, and injecting faults into the calls.",
+	Long:  usage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("this command expects no arguments")
+		}
+		if inputWrap.ErrorRate < 0 || inputWrap.ErrorRate > 1 || inputWrap.DropRate < 0 || inputWrap.DropRate > 1 {
+			return fmt.Errorf("the error and drop rates need to be between 0 and 1")
+		}
+		if inputWrap.Latency < 0 || inputWrap.LatencyJitter < 0 {
+			return fmt.Errorf("the latency can't be negative")
+		}
+		if inputWrap.Replay != "" && inputWrap.Replay == inputWrap.Record {
+			return fmt.Errorf("the recording can't be replayed and recorded to at the same time")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		p, err := newProxy(ctx)
+		if err != nil {
+			return err
+		}
+		defer p.close()
+
+		server := &http.Server{Addr: inputWrap.Listen, Handler: p}
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- server.ListenAndServe()
+		}()
+		log.Info().Str("listen", inputWrap.Listen).Str("upstream", p.upstreamName()).Msg("Proxying JSON-RPC")
+
+		select {
+		case err = <-errCh:
+			return err
+		case <-ctx.Done():
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err = server.Shutdown(shutdownCtx); err != nil {
+			log.Warn().Err(err).Msg("Unable to shut down the server")
+		}
+		p.printStats()
+		return nil
+	},
+}
+
+// proxy forwards the calls to the upstream endpoint, or answers them from a
+// recording in replay mode.
+type proxy struct {
+	upstream *ethrpc.Client
+	replay   *replayer
+
+	recordMu sync.Mutex
+	recordF  *os.File
+	recorder *json.Encoder
+
+	corpusMu sync.Mutex
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+
+	requests atomic.Uint64
+	calls    atomic.Uint64
+	errors   atomic.Uint64
+	faults   atomic.Uint64
+}
+
+func newProxy(ctx context.Context) (*proxy, error) {
+	p := &proxy{rng: rand.New(rand.NewSource(inputWrap.Seed))}
+	var err error
+	if inputWrap.Replay != "" {
+		if p.replay, err = newReplayer(inputWrap.Replay); err != nil {
+			return nil, err
+		}
+	} else {
+		if p.upstream, err = util.DialRPC(ctx, inputWrap.RPCURL); err != nil {
+			log.Error().Err(err).Str("url", inputWrap.RPCURL).Msg("Unable to dial rpc")
+			return nil, err
+		}
+	}
+	if inputWrap.Record != "" {
+		p.recordF, err = os.OpenFile(inputWrap.Record, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			p.close()
+			return nil, fmt.Errorf("unable to open the recording: %w", err)
+		}
+		p.recorder = json.NewEncoder(p.recordF)
+	}
+	if inputWrap.Corpus != "" {
+		if err = os.MkdirAll(inputWrap.Corpus, 0755); err != nil {
+			p.close()
+			return nil, fmt.Errorf("unable to create the corpus directory: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func (p *proxy) close() {
+	if p.upstream != nil {
+		p.upstream.Close()
+	}
+	if p.recordF != nil {
+		p.recordF.Close()
+	}
+}
+
+func (p *proxy) upstreamName() string {
+	if p.replay != nil {
+		return inputWrap.Replay
+	}
+	return inputWrap.RPCURL
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.requests.Add(1)
+
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+	var reqs []jsonrpcRequest
+	if batch {
+		err = json.Unmarshal(body, &reqs)
+	} else {
+		reqs = make([]jsonrpcRequest, 1)
+		err = json.Unmarshal(body, &reqs[0])
+	}
+	if err != nil || len(reqs) == 0 {
+		writeJSON(w, jsonrpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &jsonrpcError{Code: errInvalidRequest, Message: "invalid request"}})
+		return
+	}
+
+	faulty := p.hasFaultyMethod(reqs)
+	if faulty && p.chance(inputWrap.DropRate) {
+		p.faults.Add(1)
+		p.drop(w)
+		for _, req := range reqs {
+			p.save(&record{Time: time.Now(), Method: req.Method, Params: req.Params, Fault: "drop"})
+		}
+		return
+	}
+	if faulty && (inputWrap.Latency > 0 || inputWrap.LatencyJitter > 0) {
+		time.Sleep(p.getLatency())
+	}
+
+	records := p.handle(r.Context(), reqs)
+	resps := make([]jsonrpcResponse, 0, len(reqs))
+	for i, req := range reqs {
+		rec := records[i]
+		p.calls.Add(1)
+		if rec.Error != nil {
+			p.errors.Add(1)
+		}
+		p.save(rec)
+		// Notifications don't get a response
+		if len(req.ID) == 0 {
+			continue
+		}
+		resps = append(resps, jsonrpcResponse{JSONRPC: "2.0", ID: req.ID, Result: rec.Result, Error: rec.Error})
+	}
+	switch {
+	case batch:
+		writeJSON(w, resps)
+	case len(resps) == 1:
+		writeJSON(w, resps[0])
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handle answers the calls, injecting errors into some of them. The calls of
+// a batch are forwarded as a batch.
+func (p *proxy) handle(ctx context.Context, reqs []jsonrpcRequest) []*record {
+	records := make([]*record, len(reqs))
+	args := make([][]any, len(reqs))
+	var forward []int
+	for i, req := range reqs {
+		records[i] = &record{Time: time.Now(), Method: req.Method, Params: req.Params}
+		if isFaultyMethod(req.Method) && p.chance(inputWrap.ErrorRate) {
+			p.faults.Add(1)
+			records[i].Fault = "error"
+			records[i].Error = &jsonrpcError{Code: errInternal, Message: "injected fault"}
+			continue
+		}
+		var err error
+		if args[i], err = getArgs(req.Params); err != nil {
+			records[i].Error = &jsonrpcError{Code: errInvalidParams, Message: err.Error()}
+			continue
+		}
+		forward = append(forward, i)
+	}
+	if len(forward) == 0 {
+		return records
+	}
+	if p.replay != nil {
+		for _, i := range forward {
+			p.replay.answer(records[i])
+		}
+		return records
+	}
+
+	elems := make([]ethrpc.BatchElem, len(forward))
+	results := make([]json.RawMessage, len(forward))
+	for j, i := range forward {
+		elems[j] = ethrpc.BatchElem{Method: reqs[i].Method, Args: args[i], Result: &results[j]}
+	}
+	start := time.Now()
+	var err error
+	if len(elems) == 1 {
+		elems[0].Error = p.upstream.CallContext(ctx, elems[0].Result, elems[0].Method, elems[0].Args...)
+	} else {
+		err = p.upstream.BatchCallContext(ctx, elems)
+	}
+	latency := time.Since(start)
+	for j, i := range forward {
+		rec := records[i]
+		rec.Latency = latency
+		switch {
+		case err != nil:
+			rec.Error = toJSONRPCError(err)
+		case elems[j].Error != nil:
+			rec.Error = toJSONRPCError(elems[j].Error)
+		case results[j] == nil:
+			rec.Result = json.RawMessage("null")
+		default:
+			rec.Result = results[j]
+		}
+	}
+	return records
+}
+
+// getArgs returns the params as args of the RPC client, which sends them as
+// they are.
+func getArgs(params json.RawMessage) ([]any, error) {
+	if len(params) == 0 || string(params) == "null" {
+		return nil, nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil {
+		return nil, fmt.Errorf("the params need to be an array")
+	}
+	args := make([]any, len(raw))
+	for i := range raw {
+		args[i] = raw[i]
+	}
+	return args, nil
+}
+
+func toJSONRPCError(err error) *jsonrpcError {
+	var rpcErr ethrpc.Error
+	if !errors.As(err, &rpcErr) {
+		return &jsonrpcError{Code: errInternal, Message: fmt.Sprintf("unable to reach the upstream endpoint: %s", err)}
+	}
+	e := &jsonrpcError{Code: rpcErr.ErrorCode(), Message: err.Error()}
+	var dataErr ethrpc.DataError
+	if errors.As(err, &dataErr) {
+		e.Data = dataErr.ErrorData()
+	}
+	return e
+}
+
+func (p *proxy) hasFaultyMethod(reqs []jsonrpcRequest) bool {
+	for _, req := range reqs {
+		if isFaultyMethod(req.Method) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFaultyMethod is true if faults can be injected into calls of the method.
+func isFaultyMethod(method string) bool {
+	if len(inputWrap.FaultMethods) == 0 {
+		return true
+	}
+	for _, m := range inputWrap.FaultMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *proxy) chance(rate float64) bool {
+	if rate == 0 {
+		return false
+	}
+	p.rngMu.Lock()
+	defer p.rngMu.Unlock()
+	return p.rng.Float64() < rate
+}
+
+func (p *proxy) getLatency() time.Duration {
+	latency := inputWrap.Latency
+	if inputWrap.LatencyJitter > 0 {
+		p.rngMu.Lock()
+		latency += time.Duration(p.rng.Int63n(int64(inputWrap.LatencyJitter)))
+		p.rngMu.Unlock()
+	}
+	return latency
+}
+
+// drop closes the connection without a response, like an endpoint that
+// went away.
+func (p *proxy) drop(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "injected fault", http.StatusBadGateway)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to drop the connection")
+		return
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		// Without lingering, the connection is reset instead of closed
+		_ = tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// save writes the call to the recording and to the corpus.
+func (p *proxy) save(rec *record) {
+	log.Trace().Str("method", rec.Method).Dur("latency", rec.Latency).Str("fault", rec.Fault).Bool("error", rec.Error != nil).Msg("Call")
+	if p.recorder != nil {
+		p.recordMu.Lock()
+		if err := p.recorder.Encode(rec); err != nil {
+			log.Error().Err(err).Msg("Unable to write the recording")
+		}
+		p.recordMu.Unlock()
+	}
+	if inputWrap.Corpus != "" && rec.Fault == "" {
+		p.saveCorpusEntry(rec)
+	}
+}
+
+// saveCorpusEntry writes the call in the format of the rpcfuzz corpus, so
+// it can be replayed with rpcfuzz --corpus --replay. The file name is
+// derived from the method and params, so every call is only stored once.
+func (p *proxy) saveCorpusEntry(rec *record) {
+	args, err := getArgs(rec.Params)
+	if err != nil {
+		return
+	}
+	entry := rpcfuzz.CorpusEntry{
+		Name:      "wrapjrpc-" + rec.Method,
+		Method:    rec.Method,
+		Args:      args,
+		Result:    rec.Result,
+		Timestamp: rec.Time,
+	}
+	if rec.Error != nil {
+		entry.Error = rec.Error.Message
+	}
+	hash := sha256.Sum256(append([]byte(rec.Method), rec.Params...))
+	path := filepath.Join(inputWrap.Corpus, entry.Name+"-"+hex.EncodeToString(hash[:8])+".json")
+
+	p.corpusMu.Lock()
+	defer p.corpusMu.Unlock()
+	if _, statErr := os.Stat(path); statErr == nil {
+		return
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Unable to write the corpus entry")
+	}
+}
+
+func (p *proxy) printStats() {
+	log.Info().
+		Uint64("requests", p.requests.Load()).
+		Uint64("calls", p.calls.Load()).
+		Uint64("errors", p.errors.Load()).
+		Uint64("faults", p.faults.Load()).
+		Msg("Stopped proxying")
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("Unable to write the response")
+	}
+}
+
+// replayer answers the calls with the responses of a recording. The calls
+// are matched by method and params, and the responses of a call that was
+// recorded more than once are replayed in order, repeating the last one.
+type replayer struct {
+	mu      sync.Mutex
+	records map[string][]*record
+	next    map[string]int
+}
+
+func newReplayer(path string) (*replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the recording: %w", err)
+	}
+	defer f.Close()
+
+	r := &replayer{records: make(map[string][]*record), next: make(map[string]int)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	count := 0
+	for scanner.Scan() {
+		var rec record
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("unable to decode the recording: %w", err)
+		}
+		// The injected faults aren't responses of the endpoint
+		if rec.Fault != "" {
+			continue
+		}
+		key := getReplayKey(rec.Method, rec.Params)
+		r.records[key] = append(r.records[key], &rec)
+		count++
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the recording: %w", err)
+	}
+	log.Info().Int("calls", count).Str("path", path).Msg("Loaded the recording")
+	return r, nil
+}
+
+func getReplayKey(method string, params json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, params); err != nil {
+		return method + string(params)
+	}
+	return method + compact.String()
+}
+
+func (r *replayer) answer(rec *record) {
+	key := getReplayKey(rec.Method, rec.Params)
+	r.mu.Lock()
+	recorded, ok := r.records[key]
+	var match *record
+	if ok {
+		match = recorded[min(r.next[key], len(recorded)-1)]
+		r.next[key]++
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		rec.Error = &jsonrpcError{Code: errNotRecorded, Message: fmt.Sprintf("no recorded response for %s", rec.Method)}
+		return
+	}
+	if inputWrap.ReplayLatency {
+		time.Sleep(match.Latency)
+	}
+	rec.Result = match.Result
+	rec.Error = match.Error
+	rec.Latency = match.Latency
+}
+
+func init() {
+	flagSet := WrapJRPCCmd.PersistentFlags()
+	flagSet.StringVarP(&inputWrap.RPCURL, "rpc-url", "r", "http://localhost:8545", "The RPC endpoint the calls are forwarded to")
+	flagSet.StringVarP(&inputWrap.Listen, "listen", "l", "127.0.0.1:8546", "The address the proxy listens on")
+	flagSet.StringVar(&inputWrap.Record, "record", "", "A file the calls and responses are appended to as JSON lines")
+	flagSet.StringVar(&inputWrap.Replay, "replay", "", "A recording to answer the calls from instead of forwarding them")
+	flagSet.BoolVar(&inputWrap.ReplayLatency, "replay-latency", false, "Wait for the recorded latency before answering a replayed call")
+	flagSet.StringVar(&inputWrap.Corpus, "corpus", "", "A directory where every distinct call is saved as an rpcfuzz corpus entry")
+	flagSet.DurationVar(&inputWrap.Latency, "latency", 0, "The latency added to every request")
+	flagSet.DurationVar(&inputWrap.LatencyJitter, "latency-jitter", 0, "The maximum random latency added on top of --latency")
+	flagSet.Float64Var(&inputWrap.ErrorRate, "error-rate", 0, "The share of calls answered with an error instead of being forwarded (0 to 1)")
+	flagSet.Float64Var(&inputWrap.DropRate, "drop-rate", 0, "The share of requests whose connection is closed without a response (0 to 1)")
+	flagSet.StringSliceVar(&inputWrap.FaultMethods, "fault-methods", nil, "The methods faults are injected into, or all of them if unset")
+	flagSet.Int64Var(&inputWrap.Seed, "seed", 123456, "The seed of the injected faults")
+}
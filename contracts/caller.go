@@ -0,0 +1,56 @@
+package contracts
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// The caller contract is written in assembly, see asm/caller.easm.
+
+//go:embed caller/Caller.bin
+var RawCallerBin string
+
+func GetCallerBytes() ([]byte, error) {
+	return hex.DecodeString(RawCallerBin)
+}
+
+// Caller is a contract that calls itself. A transaction with a depth and
+// width makes width * depth calls and reaches a call depth of depth.
+type Caller struct {
+	contract *bind.BoundContract
+}
+
+// DeployCaller deploys a new caller contract.
+func DeployCaller(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *ethtypes.Transaction, *Caller, error) {
+	bin, err := GetCallerBytes()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(opts, abi.ABI{}, bin, backend)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &Caller{contract: contract}, nil
+}
+
+// NewCaller creates an instance of a deployed caller contract.
+func NewCaller(address common.Address, backend bind.ContractBackend) *Caller {
+	return &Caller{contract: bind.NewBoundContract(address, abi.ABI{}, backend, backend, backend)}
+}
+
+// CallerInput returns the call data for a transaction to the caller contract.
+func CallerInput(depth, width uint64) []byte {
+	input := common.LeftPadBytes(new(big.Int).SetUint64(depth).Bytes(), 32)
+	return append(input, common.LeftPadBytes(new(big.Int).SetUint64(width).Bytes(), 32)...)
+}
+
+// Call sends a transaction that makes nested calls with the depth and width.
+func (c *Caller) Call(opts *bind.TransactOpts, depth, width uint64) (*ethtypes.Transaction, error) {
+	return c.contract.RawTransact(opts, CallerInput(depth, width))
+}
@@ -0,0 +1,53 @@
+package contracts
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// The cold access contract is written in assembly, see asm/cold-access.easm.
+
+//go:embed coldaccess/ColdAccess.bin
+var RawColdAccessBin string
+
+func GetColdAccessBytes() ([]byte, error) {
+	return hex.DecodeString(RawColdAccessBin)
+}
+
+// DeployColdAccess deploys a new cold access contract. The contract has no
+// binding because its transactions are built by hand to include access lists.
+func DeployColdAccess(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *ethtypes.Transaction, error) {
+	bin, err := GetColdAccessBytes()
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	address, tx, _, err := bind.DeployContract(opts, abi.ABI{}, bin, backend)
+	return address, tx, err
+}
+
+// ColdAccessInput returns the call data for a transaction to the cold access
+// contract that touches count accounts and storage slots starting at seed.
+func ColdAccessInput(count uint64, seed *big.Int) []byte {
+	input := common.LeftPadBytes(new(big.Int).SetUint64(count).Bytes(), 32)
+	return append(input, common.LeftPadBytes(seed.Bytes(), 32)...)
+}
+
+// ColdAccessList returns the access list with every account and storage slot
+// a transaction with the count and seed touches, so they're warm from the
+// start of the transaction.
+func ColdAccessList(address common.Address, count uint64, seed *big.Int) ethtypes.AccessList {
+	accessList := make(ethtypes.AccessList, 0, count+1)
+	keys := make([]common.Hash, 0, count)
+	for i := uint64(1); i <= count; i++ {
+		v := new(big.Int).Add(seed, new(big.Int).SetUint64(i))
+		keys = append(keys, common.BigToHash(v))
+		accessList = append(accessList, ethtypes.AccessTuple{Address: common.BigToAddress(v)})
+	}
+	return append(accessList, ethtypes.AccessTuple{Address: address, StorageKeys: keys})
+}
@@ -0,0 +1,62 @@
+package contracts
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// The compute loop contract is written in assembly, see asm/compute-loop.easm.
+
+//go:embed compute/ComputeLoop.bin
+var RawComputeLoopBin string
+
+func GetComputeLoopBytes() ([]byte, error) {
+	return hex.DecodeString(RawComputeLoopBin)
+}
+
+// ComputeLoop is a contract that burns the gas of the transaction on pure
+// compute, so the gas limit of the transaction is its compute budget.
+type ComputeLoop struct {
+	contract *bind.BoundContract
+}
+
+// DeployComputeLoop deploys a new compute loop contract.
+func DeployComputeLoop(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *ethtypes.Transaction, *ComputeLoop, error) {
+	bin, err := GetComputeLoopBytes()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(opts, abi.ABI{}, bin, backend)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &ComputeLoop{contract: contract}, nil
+}
+
+// NewComputeLoop creates an instance of a deployed compute loop contract.
+func NewComputeLoop(address common.Address, backend bind.ContractBackend) *ComputeLoop {
+	return &ComputeLoop{contract: bind.NewBoundContract(address, abi.ABI{}, backend, backend, backend)}
+}
+
+// ComputeLoopInput returns the call data for a transaction to the compute
+// loop contract, which hashes with keccak256 or does arithmetic.
+func ComputeLoopInput(keccak bool) []byte {
+	kind := big.NewInt(1)
+	if keccak {
+		kind = big.NewInt(0)
+	}
+	return common.LeftPadBytes(kind.Bytes(), 32)
+}
+
+// Compute sends a transaction that loops until its gas is used up. The gas
+// limit of the options must be set, because gas estimation can't bound a loop
+// that runs for as long as there's gas.
+func (l *ComputeLoop) Compute(opts *bind.TransactOpts, keccak bool) (*ethtypes.Transaction, error) {
+	return l.contract.RawTransact(opts, ComputeLoopInput(keccak))
+}
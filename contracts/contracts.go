@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
-	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
@@ -19,14 +18,18 @@ import (
 // solc LoadTester.sol --bin --abi -o . --overwrite
 // ~/code/go-ethereum/build/bin/abigen --abi LoadTester.abi --pkg contracts --type LoadTester --bin LoadTester.bin --out loadtester.go
 
+// The assembly contracts in asm/ are compiled with the evm of go-ethereum
+// v1.10.26. Their .bin files are asm/deploy-header.easm, pushing the length of
+// the runtime code, followed by the runtime code, except for the multisig
+// proxy, which has its own constructor.
+// ~/code/go-ethereum/build/bin/evm compile asm/caller.easm
+
 //go:embed loadtester/LoadTester.bin
 var RawLoadTesterBin string
 
 //go:embed loadtester/LoadTester.abi
 var RawLoadTesterABI string
 
-var randSrc *rand.Rand
-
 func GetLoadTesterBytes() ([]byte, error) {
 	return hex.DecodeString(RawLoadTesterBin)
 }
@@ -284,7 +287,8 @@ func CallLoadTestFunctionByOpCode(shortCode uint64, lt *LoadTester, opts *bind.T
 	return nil, fmt.Errorf("the tx code %d was unrecognized", shortCode)
 }
 
-func GetRandomOPCode() uint64 {
+// GetRandomOPCode picks one of the opcodes of the load test contract.
+func GetRandomOPCode(rng *rand.Rand) uint64 {
 	codes := []uint64{
 		0x01,
 		0x02,
@@ -349,9 +353,5 @@ func GetRandomOPCode() uint64 {
 		0xA4,
 	}
 
-	return codes[randSrc.Intn(len(codes))]
-}
-
-func init() {
-	randSrc = rand.New(rand.NewSource(time.Now().Unix()))
+	return codes[rng.Intn(len(codes))]
 }
@@ -0,0 +1,81 @@
+package contracts
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// The disperse contract is written in assembly, see asm/disperse.easm.
+
+//go:embed disperse/Disperse.bin
+var RawDisperseBin string
+
+// maxDisperseAmountBits is the size of the amount packed with each recipient.
+const maxDisperseAmountBits = 96
+
+func GetDisperseBytes() ([]byte, error) {
+	return hex.DecodeString(RawDisperseBin)
+}
+
+// Disperse is a contract that sends ether or ERC20 tokens to many recipients
+// in a single transaction. Tokens are sent with transferFrom, so the sender
+// has to approve the contract first.
+type Disperse struct {
+	contract *bind.BoundContract
+}
+
+// DeployDisperse deploys a new disperse contract.
+func DeployDisperse(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *ethtypes.Transaction, *Disperse, error) {
+	bin, err := GetDisperseBytes()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(opts, abi.ABI{}, bin, backend)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &Disperse{contract: contract}, nil
+}
+
+// NewDisperse creates an instance of a deployed disperse contract.
+func NewDisperse(address common.Address, backend bind.ContractBackend) *Disperse {
+	return &Disperse{contract: bind.NewBoundContract(address, abi.ABI{}, backend, backend, backend)}
+}
+
+// DisperseInput returns the call data for a transaction to the disperse
+// contract. The token is the zero address to send ether. Each amount is packed
+// with its recipient in a single word, so it has to fit in 96 bits.
+func DisperseInput(token common.Address, recipients []common.Address, amounts []*big.Int) ([]byte, error) {
+	if len(recipients) != len(amounts) {
+		return nil, fmt.Errorf("got %d recipients but %d amounts", len(recipients), len(amounts))
+	}
+	input := make([]byte, 0, 32*(len(recipients)+1))
+	input = append(input, common.LeftPadBytes(token.Bytes(), 32)...)
+	for i, recipient := range recipients {
+		if amounts[i].Sign() < 0 || amounts[i].BitLen() > maxDisperseAmountBits {
+			return nil, fmt.Errorf("the amount %s doesn't fit in %d bits", amounts[i], maxDisperseAmountBits)
+		}
+		word := new(big.Int).Lsh(amounts[i], 160)
+		word.Or(word, new(big.Int).SetBytes(recipient.Bytes()))
+		input = append(input, common.LeftPadBytes(word.Bytes(), 32)...)
+	}
+	return input, nil
+}
+
+// Disperse sends a transaction that sends each recipient its amount of the
+// token, or of ether if the token is the zero address. When sending ether,
+// the value of the transaction has to be the sum of the amounts.
+func (d *Disperse) Disperse(opts *bind.TransactOpts, token common.Address, recipients []common.Address, amounts []*big.Int) (*ethtypes.Transaction, error) {
+	input, err := DisperseInput(token, recipients, amounts)
+	if err != nil {
+		return nil, err
+	}
+	return d.contract.RawTransact(opts, input)
+}
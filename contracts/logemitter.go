@@ -0,0 +1,61 @@
+package contracts
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// The log emitter contract is written in assembly, see asm/log-emitter.easm.
+
+//go:embed logemitter/LogEmitter.bin
+var RawLogEmitterBin string
+
+func GetLogEmitterBytes() ([]byte, error) {
+	return hex.DecodeString(RawLogEmitterBin)
+}
+
+// LogEmitter is a contract that emits a number of logs per transaction with a
+// number of topics and the same data.
+type LogEmitter struct {
+	contract *bind.BoundContract
+}
+
+// DeployLogEmitter deploys a new log emitter contract.
+func DeployLogEmitter(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *ethtypes.Transaction, *LogEmitter, error) {
+	bin, err := GetLogEmitterBytes()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(opts, abi.ABI{}, bin, backend)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &LogEmitter{contract: contract}, nil
+}
+
+// NewLogEmitter creates an instance of a deployed log emitter contract.
+func NewLogEmitter(address common.Address, backend bind.ContractBackend) *LogEmitter {
+	return &LogEmitter{contract: bind.NewBoundContract(address, abi.ABI{}, backend, backend, backend)}
+}
+
+// LogEmitterInput returns the call data for a transaction to the log emitter
+// contract. Topics above four are capped to four by the contract, and the
+// topics of each log are derived from the seed so they differ between
+// transactions.
+func LogEmitterInput(count, topics, seed uint64, data []byte) []byte {
+	input := common.LeftPadBytes(new(big.Int).SetUint64(count).Bytes(), 32)
+	input = append(input, common.LeftPadBytes(new(big.Int).SetUint64(topics).Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(new(big.Int).SetUint64(seed).Bytes(), 32)...)
+	return append(input, data...)
+}
+
+// Emit sends a transaction that emits count logs with the topics and data.
+func (l *LogEmitter) Emit(opts *bind.TransactOpts, count, topics, seed uint64, data []byte) (*ethtypes.Transaction, error) {
+	return l.contract.RawTransact(opts, LogEmitterInput(count, topics, seed, data))
+}
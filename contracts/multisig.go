@@ -0,0 +1,156 @@
+package contracts
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	_ "embed"
+	"encoding/hex"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The multisig contracts are written in assembly, see asm/multisig.easm and
+// asm/multisig-proxy.easm. The wallet is a proxy that delegates every call to
+// a shared multisig contract, the same way Safe wallets are deployed.
+
+//go:embed multisig/Multisig.bin
+var RawMultisigBin string
+
+//go:embed multisig/MultisigProxy.bin
+var RawMultisigProxyBin string
+
+// The operations a multisig transaction can execute.
+const (
+	MultisigOperationCall         uint64 = 1
+	MultisigOperationDelegateCall uint64 = 2
+)
+
+func GetMultisigBytes() ([]byte, error) {
+	return hex.DecodeString(RawMultisigBin)
+}
+
+func GetMultisigProxyBytes() ([]byte, error) {
+	return hex.DecodeString(RawMultisigProxyBin)
+}
+
+// Multisig is a wallet that executes transactions signed by a threshold of
+// its owners.
+type Multisig struct {
+	contract *bind.BoundContract
+}
+
+// DeployMultisig deploys the multisig contract that the wallets delegate to.
+func DeployMultisig(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *ethtypes.Transaction, error) {
+	bin, err := GetMultisigBytes()
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	address, tx, _, err := bind.DeployContract(opts, abi.ABI{}, bin, backend)
+	return address, tx, err
+}
+
+// DeployMultisigProxy deploys a new wallet that delegates to the multisig
+// contract at the address. The wallet needs to be set up before it's used.
+func DeployMultisigProxy(opts *bind.TransactOpts, backend bind.ContractBackend, multisig common.Address) (common.Address, *ethtypes.Transaction, *Multisig, error) {
+	bin, err := GetMultisigProxyBytes()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	bin = append(bin, common.LeftPadBytes(multisig.Bytes(), 32)...)
+	address, tx, contract, err := bind.DeployContract(opts, abi.ABI{}, bin, backend)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &Multisig{contract: contract}, nil
+}
+
+// NewMultisig creates an instance of a deployed wallet.
+func NewMultisig(address common.Address, backend bind.ContractBackend) *Multisig {
+	return &Multisig{contract: bind.NewBoundContract(address, abi.ABI{}, backend, backend, backend)}
+}
+
+// MultisigSetupInput returns the call data that sets the owners and the
+// threshold of a new wallet.
+func MultisigSetupInput(threshold uint64, owners []common.Address) []byte {
+	input := make([]byte, 32, 32*(len(owners)+2))
+	input = append(input, common.LeftPadBytes(new(big.Int).SetUint64(threshold).Bytes(), 32)...)
+	for _, owner := range owners {
+		input = append(input, common.LeftPadBytes(owner.Bytes(), 32)...)
+	}
+	return input
+}
+
+// MultisigHash returns the hash the owners sign to approve a transaction.
+func MultisigHash(wallet common.Address, chainID *big.Int, nonce, operation uint64, to common.Address, value *big.Int, data []byte) common.Hash {
+	return crypto.Keccak256Hash(
+		common.LeftPadBytes(wallet.Bytes(), 32),
+		common.LeftPadBytes(chainID.Bytes(), 32),
+		common.LeftPadBytes(new(big.Int).SetUint64(nonce).Bytes(), 32),
+		common.LeftPadBytes(new(big.Int).SetUint64(operation).Bytes(), 32),
+		common.LeftPadBytes(to.Bytes(), 32),
+		common.LeftPadBytes(value.Bytes(), 32),
+		crypto.Keccak256(data),
+	)
+}
+
+// SignMultisig signs the hash with every key. The wallet needs the signatures
+// in ascending order of the owner addresses, so they're sorted that way.
+func SignMultisig(hash common.Hash, keys []*ecdsa.PrivateKey) ([][]byte, error) {
+	sorted := make([]*ecdsa.PrivateKey, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		a := crypto.PubkeyToAddress(sorted[i].PublicKey)
+		b := crypto.PubkeyToAddress(sorted[j].PublicKey)
+		return bytes.Compare(a.Bytes(), b.Bytes()) < 0
+	})
+	signatures := make([][]byte, 0, len(sorted))
+	for _, key := range sorted {
+		signature, err := crypto.Sign(hash.Bytes(), key)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// MultisigExecInput returns the call data that executes a transaction with the
+// 65 byte signatures returned by SignMultisig.
+func MultisigExecInput(operation uint64, to common.Address, value *big.Int, nonce uint64, data []byte, signatures [][]byte) []byte {
+	padded := (len(data) + 31) / 32 * 32
+	input := make([]byte, 0, 32*6+padded+96*len(signatures))
+	input = append(input, common.LeftPadBytes(new(big.Int).SetUint64(operation).Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(to.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(value.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(new(big.Int).SetUint64(nonce).Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(big.NewInt(int64(len(data))).Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(big.NewInt(int64(len(signatures))).Bytes(), 32)...)
+	input = append(input, common.RightPadBytes(data, padded)...)
+	for _, signature := range signatures {
+		input = append(input, signature[:64]...)
+		// The recovery id is 0 or 1, while ecrecover expects 27 or 28
+		input = append(input, common.LeftPadBytes([]byte{signature[64] + 27}, 32)...)
+	}
+	return input
+}
+
+// Setup sends a transaction that sets the owners and threshold of the wallet.
+func (m *Multisig) Setup(opts *bind.TransactOpts, threshold uint64, owners []common.Address) (*ethtypes.Transaction, error) {
+	return m.contract.RawTransact(opts, MultisigSetupInput(threshold, owners))
+}
+
+// Fund sends a plain transfer of the value of the options to the wallet.
+func (m *Multisig) Fund(opts *bind.TransactOpts) (*ethtypes.Transaction, error) {
+	return m.contract.Transfer(opts)
+}
+
+// Exec sends a transaction that has the wallet execute the operation.
+func (m *Multisig) Exec(opts *bind.TransactOpts, operation uint64, to common.Address, value *big.Int, nonce uint64, data []byte, signatures [][]byte) (*ethtypes.Transaction, error) {
+	return m.contract.RawTransact(opts, MultisigExecInput(operation, to, value, nonce, data, signatures))
+}
@@ -207,7 +207,9 @@ func CallPrecompiledContracts(address int, lt *LoadTester, opts *bind.TransactOp
 	return nil, fmt.Errorf("Unrecognized precompiled address %d", address)
 }
 
-func GetRandomPrecompiledContractAddress() int {
+// GetRandomPrecompiledContractAddress picks one of the precompiled contracts
+// the load test contract can call.
+func GetRandomPrecompiledContractAddress(rng *rand.Rand) int {
 	codes := []int{
 		1,
 		2,
@@ -220,5 +222,5 @@ func GetRandomPrecompiledContractAddress() int {
 		9,
 	}
 
-	return codes[rand.Intn(len(codes))]
+	return codes[rng.Intn(len(codes))]
 }
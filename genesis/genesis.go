@@ -0,0 +1,285 @@
+// Package genesis builds genesis allocations that fund accounts and include
+// the load test contracts, so a devnet can start ready for load testing
+// without any setup transactions. The contracts are deployed by running their
+// constructors in an in-memory EVM, and the resulting code and storage are
+// copied into the allocation.
+package genesis
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+	gethparams "github.com/ethereum/go-ethereum/params"
+	"github.com/maticnetwork/polygon-cli/contracts"
+	"github.com/maticnetwork/polygon-cli/contracts/tokens"
+	"github.com/maticnetwork/polygon-cli/metrics"
+)
+
+const (
+	ContractLoadTester = "loadtester"
+	ContractERC20      = "erc20"
+	ContractERC721     = "erc721"
+	ContractCaller     = "caller"
+	ContractLogEmitter = "log-emitter"
+	ContractColdAccess = "cold-access"
+	ContractCompute    = "compute"
+	ContractDisperse   = "disperse"
+)
+
+// Contracts are the contracts that can be included. A contract's address is
+// derived from the deployer and its position here, so it's the same no
+// matter which of the others are included.
+var Contracts = []string{
+	ContractLoadTester,
+	ContractERC20,
+	ContractERC721,
+	ContractCaller,
+	ContractLogEmitter,
+	ContractColdAccess,
+	ContractCompute,
+	ContractDisperse,
+}
+
+type (
+	// Config selects the funded accounts and the included contracts.
+	Config struct {
+		Accounts  []common.Address
+		Balance   *big.Int
+		Deployer  common.Address
+		Contracts []string
+	}
+
+	// Contract is an included contract.
+	Contract struct {
+		Name    string
+		Address common.Address
+	}
+
+	// storageTracer records the storage slots written by each contract, since
+	// the state doesn't list the slots of an account.
+	storageTracer struct {
+		slots map[common.Address]map[common.Hash]struct{}
+	}
+)
+
+// Address returns the address of the contract when it's included with the
+// deployer.
+func Address(deployer common.Address, name string) (common.Address, error) {
+	i, err := position(name)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.CreateAddress(deployer, i), nil
+}
+
+func position(name string) (uint64, error) {
+	for i, c := range Contracts {
+		if c == name {
+			return uint64(i), nil
+		}
+	}
+	return 0, fmt.Errorf("the contract %s is not valid, expected one of %v", name, Contracts)
+}
+
+// NewAlloc returns the allocation and the included contracts. Every account
+// gets the balance and, if the tokens are included, a million ERC20 tokens and
+// an ERC721 token, the same as the load test mints when it deploys them. The
+// deployer's nonce is set past the nonces of the contract addresses, so its
+// later deployments can't collide with them.
+func NewAlloc(c Config) (core.GenesisAlloc, []Contract, error) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	tracer := &storageTracer{slots: make(map[common.Address]map[common.Hash]struct{})}
+	cfg := &runtime.Config{
+		ChainConfig: chainConfig(),
+		Origin:      c.Deployer,
+		State:       statedb,
+		// Some of the contracts are compiled with PUSH0.
+		EVMConfig: vm.Config{Debug: true, Tracer: tracer, ExtraEips: []int{3855}},
+	}
+
+	included := make([]Contract, 0, len(c.Contracts))
+	for _, name := range c.Contracts {
+		address, err := deploy(cfg, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to deploy the %s contract: %w", name, err)
+		}
+		included = append(included, Contract{Name: name, Address: address})
+	}
+	for _, contract := range included {
+		if err = mint(cfg, contract, c.Accounts); err != nil {
+			return nil, nil, fmt.Errorf("unable to mint the %s tokens: %w", contract.Name, err)
+		}
+	}
+
+	alloc := make(core.GenesisAlloc)
+	for _, a := range c.Accounts {
+		alloc[a] = core.GenesisAccount{Balance: new(big.Int).Set(c.Balance)}
+	}
+	if len(included) > 0 {
+		deployer := alloc[c.Deployer]
+		if deployer.Balance == nil {
+			deployer.Balance = new(big.Int)
+		}
+		deployer.Nonce = uint64(len(Contracts))
+		alloc[c.Deployer] = deployer
+	}
+	for _, contract := range included {
+		account := core.GenesisAccount{
+			Code:    statedb.GetCode(contract.Address),
+			Nonce:   statedb.GetNonce(contract.Address),
+			Balance: new(big.Int),
+		}
+		if slots := tracer.slots[contract.Address]; len(slots) > 0 {
+			account.Storage = make(map[common.Hash]common.Hash, len(slots))
+			for slot := range slots {
+				if value := statedb.GetState(contract.Address, slot); value != (common.Hash{}) {
+					account.Storage[slot] = value
+				}
+			}
+		}
+		alloc[contract.Address] = account
+	}
+	return alloc, included, nil
+}
+
+// chainConfig returns the rules the constructors run with. None of the
+// contracts depend on the fork, as long as it's at least London.
+func chainConfig() *gethparams.ChainConfig {
+	config := *gethparams.AllEthashProtocolChanges
+	return &config
+}
+
+// deploy runs the constructor of the contract. Its address depends on the
+// nonce of the deployer, which is set to the position of the contract rather
+// than counting the included contracts.
+func deploy(cfg *runtime.Config, name string) (common.Address, error) {
+	nonce, err := position(name)
+	if err != nil {
+		return common.Address{}, err
+	}
+	initCode, err := getInitCode(name)
+	if err != nil {
+		return common.Address{}, err
+	}
+	cfg.State.SetNonce(cfg.Origin, nonce)
+	_, address, _, err := runtime.Create(initCode, cfg)
+	return address, err
+}
+
+func getInitCode(name string) ([]byte, error) {
+	switch name {
+	case ContractLoadTester:
+		return hexutil.Decode(contracts.LoadTesterMetaData.Bin)
+	case ContractERC20:
+		parsed, err := tokens.ERC20MetaData.GetAbi()
+		if err != nil {
+			return nil, err
+		}
+		args, err := parsed.Pack("", "ERC20TestToken", "T20")
+		if err != nil {
+			return nil, err
+		}
+		bin, err := hexutil.Decode(tokens.ERC20MetaData.Bin)
+		if err != nil {
+			return nil, err
+		}
+		return append(bin, args...), nil
+	case ContractERC721:
+		return hexutil.Decode(tokens.ERC721MetaData.Bin)
+	case ContractCaller:
+		return contracts.GetCallerBytes()
+	case ContractLogEmitter:
+		return contracts.GetLogEmitterBytes()
+	case ContractColdAccess:
+		return contracts.GetColdAccessBytes()
+	case ContractCompute:
+		return contracts.GetComputeLoopBytes()
+	case ContractDisperse:
+		return contracts.GetDisperseBytes()
+	}
+	return nil, fmt.Errorf("the contract %s is not valid, expected one of %v", name, Contracts)
+}
+
+// mint mints the tokens of the contract for every account, if it's a token.
+func mint(cfg *runtime.Config, contract Contract, accounts []common.Address) error {
+	var (
+		parsed *abi.ABI
+		err    error
+	)
+	switch contract.Name {
+	case ContractERC20:
+		parsed, err = tokens.ERC20MetaData.GetAbi()
+	case ContractERC721:
+		parsed, err = tokens.ERC721MetaData.GetAbi()
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	deployer := cfg.Origin
+	defer func() { cfg.Origin = deployer }()
+	for _, a := range accounts {
+		var input []byte
+		if contract.Name == ContractERC20 {
+			// ERC20 mints to the sender.
+			cfg.Origin = a
+			input, err = parsed.Pack("mint", metrics.UnitMegaether)
+		} else {
+			input, err = parsed.Pack("mintBatch", a, big.NewInt(1))
+		}
+		if err != nil {
+			return err
+		}
+		if _, _, err = runtime.Call(contract.Address, input, cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *storageTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if op != vm.SSTORE {
+		return
+	}
+	stack := scope.Stack.Data()
+	if len(stack) == 0 {
+		return
+	}
+	address := scope.Contract.Address()
+	if t.slots[address] == nil {
+		t.slots[address] = make(map[common.Hash]struct{})
+	}
+	t.slots[address][stack[len(stack)-1].Bytes32()] = struct{}{}
+}
+
+func (t *storageTracer) CaptureTxStart(gasLimit uint64) {}
+
+func (t *storageTracer) CaptureTxEnd(restGas uint64) {}
+
+func (t *storageTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+
+func (t *storageTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+
+func (t *storageTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+func (t *storageTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *storageTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
@@ -0,0 +1,49 @@
+package genesis
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNewAlloc(t *testing.T) {
+	deployer := common.HexToAddress("0x85dA99c8a7C2C95964c8EfD687E95E632Fc533D6")
+	other := common.HexToAddress("0x8b3aF2b12FC2b4E1E3f5b0D2B9E8dB2C3a4D5e6F")
+	alloc, included, err := NewAlloc(Config{
+		Accounts:  []common.Address{deployer, other},
+		Balance:   big.NewInt(1e18),
+		Deployer:  deployer,
+		Contracts: []string{ContractERC20, ContractLoadTester},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(included) != 2 {
+		t.Fatalf("expected 2 contracts, got %d", len(included))
+	}
+	for _, c := range included {
+		address, err := Address(deployer, c.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Address != address {
+			t.Errorf("expected the %s contract at %s, got %s", c.Name, address, c.Address)
+		}
+		if len(alloc[c.Address].Code) == 0 {
+			t.Errorf("expected the %s contract to have code", c.Name)
+		}
+	}
+
+	// The name, symbol, total supply, and both balances are set.
+	if slots := len(alloc[included[0].Address].Storage); slots < 5 {
+		t.Errorf("expected at least 5 storage slots in the ERC20 contract, got %d", slots)
+	}
+	if alloc[deployer].Nonce != uint64(len(Contracts)) {
+		t.Errorf("expected the deployer nonce to be %d, got %d", len(Contracts), alloc[deployer].Nonce)
+	}
+	if alloc[other].Balance.Cmp(big.NewInt(1e18)) != 0 {
+		t.Errorf("expected the balance to be 1 ether, got %s", alloc[other].Balance)
+	}
+}
@@ -298,6 +298,14 @@ func (p *PolyWallet) ExportRootAddress() (*PolyWalletExport, error) {
 }
 
 func (p *PolyWallet) ExportHDAddresses(count int) (*PolyWalletExport, error) {
+	return p.ExportHDAddressRange(0, count)
+}
+
+// ExportHDAddressRange exports count addresses starting at the start index.
+func (p *PolyWallet) ExportHDAddressRange(start, count int) (*PolyWalletExport, error) {
+	if start < 0 || count < 0 {
+		return nil, fmt.Errorf("the start index and count can't be negative")
+	}
 	pwe := new(PolyWalletExport)
 	pwe.Mnemonic = p.Mnemonic
 	pwe.Passphrase = p.Passphrase // ???
@@ -326,7 +334,7 @@ func (p *PolyWallet) ExportHDAddresses(count int) (*PolyWalletExport, error) {
 	pwe.BIP32PublicKey = bip32Key.PublicKey().String()
 	pwe.Addresses = make([]*PolyAddressExport, 0)
 
-	for i := 0; i < count; i = i + 1 {
+	for i := start; i < start+count; i = i + 1 {
 		// TODO if we want to provide support for hardened addresses it would need to be accommodated here
 		currentPath := p.derivationPath + "/0/" + fmt.Sprintf("%d", i)
 		k, err := p.GetKeyForPath(currentPath)
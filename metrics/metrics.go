@@ -105,6 +105,52 @@ func GetGasPerBlock(blocks []rpctypes.PolyBlock) []float64 {
 	return gasUsed
 }
 
+// GetBlockTimePerBlock returns the seconds between each block and its
+// predecessor. The first block has no predecessor in the slice so it's 0.
+func GetBlockTimePerBlock(blocks []rpctypes.PolyBlock) []float64 {
+	bs := SortableBlocks(blocks)
+	sort.Sort(bs)
+
+	blockTimes := make([]float64, 0)
+	for i, b := range bs {
+		if i == 0 || b.Time() < bs[i-1].Time() {
+			blockTimes = append(blockTimes, 0)
+			continue
+		}
+		blockTimes = append(blockTimes, float64(b.Time()-bs[i-1].Time()))
+	}
+	return blockTimes
+}
+
+func GetGasUsedPercentPerBlock(blocks []rpctypes.PolyBlock) []float64 {
+	bs := SortableBlocks(blocks)
+	sort.Sort(bs)
+
+	gasUsed := make([]float64, 0)
+	for _, b := range bs {
+		if b.GasLimit() == 0 {
+			gasUsed = append(gasUsed, 0)
+			continue
+		}
+		gasUsed = append(gasUsed, float64(b.GasUsed())/float64(b.GasLimit())*100)
+	}
+	return gasUsed
+}
+
+// GetBaseFeePerBlock returns the base fee of each block in gwei. Blocks before
+// London have no base fee so they're 0.
+func GetBaseFeePerBlock(blocks []rpctypes.PolyBlock) []float64 {
+	bs := SortableBlocks(blocks)
+	sort.Sort(bs)
+
+	baseFees := make([]float64, 0)
+	for _, b := range bs {
+		gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(b.BaseFee()), new(big.Float).SetInt(UnitShannon)).Float64()
+		baseFees = append(baseFees, gwei)
+	}
+	return baseFees
+}
+
 func GetMeanGasPricePerBlock(blocks []rpctypes.PolyBlock) []float64 {
 	bs := SortableBlocks(blocks)
 	sort.Sort(bs)
@@ -0,0 +1,264 @@
+// Package multicall reads many contract calls in a few round trips by
+// aggregating them through the Multicall3 contract. Where Multicall3 isn't
+// deployed, the calls are sent as JSON-RPC batches of eth_call instead, so
+// callers don't have to handle both cases.
+package multicall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultAddress is where Multicall3 is deployed on most chains.
+	DefaultAddress = "0xcA11bde05977b3631167028862bE2a173976CA11"
+	// DefaultBatchSize is the number of calls aggregated in one eth_call,
+	// which stays well within the gas limit of eth_call on most endpoints.
+	DefaultBatchSize = 500
+)
+
+// multicall3ABI is the part of Multicall3 that's used. aggregate3 runs calls
+// that are allowed to fail individually.
+const multicall3ABI = `[
+{"inputs":[{"components":[{"internalType":"address","name":"target","type":"address"},{"internalType":"bool","name":"allowFailure","type":"bool"},{"internalType":"bytes","name":"callData","type":"bytes"}],"internalType":"struct Multicall3.Call3[]","name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"internalType":"bool","name":"success","type":"bool"},{"internalType":"bytes","name":"returnData","type":"bytes"}],"internalType":"struct Multicall3.Result[]","name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"},
+{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"getEthBalance","outputs":[{"internalType":"uint256","name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`
+
+// ErrReverted is the error of a call that reverted inside Multicall3, which
+// doesn't return why.
+var ErrReverted = errors.New("the call reverted")
+
+var parsedABI abi.ABI
+
+type (
+	// Call is a read only call of a contract.
+	Call struct {
+		Target common.Address
+		Data   []byte
+	}
+
+	// Result is the return data of a call, or why it failed.
+	Result struct {
+		Data []byte
+		Err  error
+	}
+
+	call3 struct {
+		Target       common.Address
+		AllowFailure bool
+		CallData     []byte
+	}
+
+	result3 struct {
+		Success    bool
+		ReturnData []byte
+	}
+
+	// Reader runs calls in batches, through Multicall3 if it's deployed.
+	Reader struct {
+		rpc       *ethrpc.Client
+		address   *common.Address
+		batchSize int
+	}
+
+	// Request is an aggregate3 call that's sent along with other requests in
+	// a JSON-RPC batch. The results are available once Batch is sent.
+	Request struct {
+		Batch  []ethrpc.BatchElem
+		output hexutil.Bytes
+		calls  int
+	}
+)
+
+func init() {
+	var err error
+	if parsedABI, err = abi.JSON(strings.NewReader(multicall3ABI)); err != nil {
+		panic(err)
+	}
+}
+
+// NewReader returns a reader of the endpoint that aggregates the calls
+// through the Multicall3 contract at the address. If the address is empty or
+// has no code, the reader falls back to JSON-RPC batches. A batch size below
+// one uses DefaultBatchSize.
+func NewReader(ctx context.Context, rpc *ethrpc.Client, address string, batchSize int) (*Reader, error) {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+	r := &Reader{rpc: rpc, batchSize: batchSize}
+	if address == "" {
+		return r, nil
+	}
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("the Multicall3 address %s is invalid", address)
+	}
+
+	multicall := common.HexToAddress(address)
+	var code hexutil.Bytes
+	if err := rpc.CallContext(ctx, &code, "eth_getCode", multicall, "latest"); err != nil {
+		return nil, fmt.Errorf("unable to check for Multicall3: %w", err)
+	}
+	if len(code) == 0 {
+		log.Warn().Str("address", multicall.Hex()).Msg("Multicall3 isn't deployed, batching eth_call requests instead")
+		return r, nil
+	}
+	r.address = &multicall
+	return r, nil
+}
+
+// Deployed is whether the calls are aggregated through Multicall3.
+func (r *Reader) Deployed() bool {
+	return r.address != nil
+}
+
+// Call runs the calls at the block, which is a tag or a hex number, and
+// returns their results in the same order. A call failing is only an error
+// of its result, while the batch failing is returned as the error.
+func (r *Reader) Call(ctx context.Context, block string, calls []Call) ([]Result, error) {
+	results := make([]Result, 0, len(calls))
+	for start := 0; start < len(calls); start += r.batchSize {
+		end := min(start+r.batchSize, len(calls))
+		var (
+			batch []Result
+			err   error
+		)
+		if r.Deployed() {
+			batch, err = r.aggregate(ctx, block, calls[start:end])
+		} else {
+			batch, err = r.batch(ctx, block, calls[start:end])
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batch...)
+		log.Trace().Int("calls", end).Int("total", len(calls)).Msg("Ran calls")
+	}
+	return results, nil
+}
+
+// EthBalance returns a call of Multicall3 that reads the balance of the
+// account, so balances can be aggregated along with other calls. It's only
+// valid when Multicall3 is deployed.
+func (r *Reader) EthBalance(account common.Address) (Call, error) {
+	if !r.Deployed() {
+		return Call{}, fmt.Errorf("balances can only be aggregated through Multicall3")
+	}
+	data, err := parsedABI.Pack("getEthBalance", account)
+	if err != nil {
+		return Call{}, err
+	}
+	return Call{Target: *r.address, Data: data}, nil
+}
+
+// NewRequest returns a single aggregate3 call of all the calls at the block,
+// for callers that send it in a JSON-RPC batch of their own. It ignores the
+// batch size and is only valid when Multicall3 is deployed.
+func (r *Reader) NewRequest(block string, calls []Call) (*Request, error) {
+	if !r.Deployed() {
+		return nil, fmt.Errorf("calls can only be aggregated through Multicall3")
+	}
+	input, err := pack(calls)
+	if err != nil {
+		return nil, err
+	}
+	req := &Request{calls: len(calls)}
+	req.Batch = []ethrpc.BatchElem{{
+		Method: "eth_call",
+		Args:   []interface{}{map[string]interface{}{"to": r.address, "data": hexutil.Bytes(input)}, block},
+		Result: &req.output,
+	}}
+	return req, nil
+}
+
+// Results decodes the results of the calls once the batch is sent.
+func (req *Request) Results() ([]Result, error) {
+	if err := req.Batch[0].Error; err != nil {
+		return nil, fmt.Errorf("unable to call Multicall3: %w", err)
+	}
+	return unpack(req.output, req.calls)
+}
+
+// DecodeBig decodes the result of a call returning a single uint256, like a
+// balance.
+func DecodeBig(r Result) (*big.Int, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if len(r.Data) != common.HashLength {
+		return nil, fmt.Errorf("expected %d bytes of return data, got %d", common.HashLength, len(r.Data))
+	}
+	return new(big.Int).SetBytes(r.Data), nil
+}
+
+func (r *Reader) aggregate(ctx context.Context, block string, calls []Call) ([]Result, error) {
+	req, err := r.NewRequest(block, calls)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.rpc.BatchCallContext(ctx, req.Batch); err != nil {
+		return nil, err
+	}
+	return req.Results()
+}
+
+func (r *Reader) batch(ctx context.Context, block string, calls []Call) ([]Result, error) {
+	outputs := make([]hexutil.Bytes, len(calls))
+	batch := make([]ethrpc.BatchElem, 0, len(calls))
+	for i, c := range calls {
+		batch = append(batch, ethrpc.BatchElem{
+			Method: "eth_call",
+			Args:   []interface{}{map[string]interface{}{"to": c.Target, "data": hexutil.Bytes(c.Data)}, block},
+			Result: &outputs[i],
+		})
+	}
+	if err := r.rpc.BatchCallContext(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(calls))
+	for i, elem := range batch {
+		results = append(results, Result{Data: outputs[i], Err: elem.Error})
+	}
+	return results, nil
+}
+
+func pack(calls []Call) ([]byte, error) {
+	args := make([]call3, 0, len(calls))
+	for _, c := range calls {
+		args = append(args, call3{Target: c.Target, AllowFailure: true, CallData: c.Data})
+	}
+	return parsedABI.Pack("aggregate3", args)
+}
+
+func unpack(output []byte, calls int) ([]Result, error) {
+	unpacked, err := parsedABI.Unpack("aggregate3", output)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the Multicall3 results: %w", err)
+	}
+	if len(unpacked) != 1 {
+		return nil, fmt.Errorf("unable to decode the Multicall3 results")
+	}
+	decoded := *abi.ConvertType(unpacked[0], new([]result3)).(*[]result3)
+	if len(decoded) != calls {
+		return nil, fmt.Errorf("Multicall3 returned %d results for %d calls", len(decoded), calls)
+	}
+
+	results := make([]Result, 0, len(decoded))
+	for _, r := range decoded {
+		if !r.Success {
+			results = append(results, Result{Err: ErrReverted})
+			continue
+		}
+		results = append(results, Result{Data: r.ReturnData})
+	}
+	return results, nil
+}
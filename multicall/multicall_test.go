@@ -0,0 +1,59 @@
+package multicall
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestUnpackResults(t *testing.T) {
+	output, err := parsedABI.Methods["aggregate3"].Outputs.Pack([]result3{
+		{Success: true, ReturnData: common.LeftPadBytes([]byte{0x2a}, 32)},
+		{Success: false, ReturnData: []byte{0x08, 0xc3, 0x79, 0xa0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := unpack(output, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, err := DecodeBig(results[0])
+	if err != nil || balance.Uint64() != 42 {
+		t.Errorf("expected the first result to decode to 42, got %v (%v)", balance, err)
+	}
+	if !errors.Is(results[1].Err, ErrReverted) {
+		t.Errorf("expected the second result to have reverted, got %v", results[1].Err)
+	}
+
+	if _, err = unpack(output, 3); err == nil {
+		t.Error("expected an error when Multicall3 returns fewer results than calls")
+	}
+}
+
+func TestPackCalls(t *testing.T) {
+	target := common.HexToAddress("0x4200000000000000000000000000000000000006")
+	input, err := pack([]Call{{Target: target, Data: []byte{0x18, 0x16, 0x0d, 0xdd}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(input[:4], parsedABI.Methods["aggregate3"].ID) {
+		t.Fatalf("expected the aggregate3 selector, got %x", input[:4])
+	}
+
+	args, err := parsedABI.Methods["aggregate3"].Inputs.Unpack(input[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := args[0].([]struct {
+		Target       common.Address `json:"target"`
+		AllowFailure bool           `json:"allowFailure"`
+		CallData     []byte         `json:"callData"`
+	})
+	if len(calls) != 1 || calls[0].Target != target || !calls[0].AllowFailure {
+		t.Errorf("expected a single call of %v allowed to fail, got %+v", target, calls)
+	}
+}
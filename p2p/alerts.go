@@ -0,0 +1,413 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+)
+
+const (
+	// alertChainDepth is the number of recent blocks kept to find the common
+	// ancestor of a reorg, which bounds the depth that can be measured.
+	alertChainDepth = 256
+
+	// defaultAlertCooldown is the least time between two alerts of a rule.
+	defaultAlertCooldown = 5 * time.Minute
+
+	alertSource = "polycli-sensor"
+)
+
+// AlertRuleKind is the condition an alert rule checks.
+type AlertRuleKind string
+
+const (
+	// AlertNoNewBlocks fires when no new block was received for Duration.
+	AlertNoNewBlocks AlertRuleKind = "no_new_blocks"
+
+	// AlertReorgDepth fires when a reorg replaces more than Depth blocks.
+	AlertReorgDepth AlertRuleKind = "reorg_depth"
+
+	// AlertPeerCount fires when the peer count stays below Threshold for
+	// Duration, which can be zero to fire right away.
+	AlertPeerCount AlertRuleKind = "peer_count_below"
+
+	// AlertAddress fires when a gossiped transaction is sent from or to one of
+	// the Addresses.
+	AlertAddress AlertRuleKind = "address_in_tx"
+)
+
+// AlertRule is a condition that posts an alert to a webhook when it occurs.
+type AlertRule struct {
+	Name      string        `yaml:"name"`
+	Kind      AlertRuleKind `yaml:"kind"`
+	Duration  time.Duration `yaml:"duration"`
+	Depth     uint64        `yaml:"depth"`
+	Threshold int           `yaml:"threshold"`
+	Addresses []string      `yaml:"addresses"`
+
+	// Webhook and Cooldown override the ones of the config for this rule.
+	Webhook  string        `yaml:"webhook"`
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// AlertConfig is the YAML file of the alert rules. Webhook is where the alerts
+// are posted unless their rule has its own, and Cooldown is the least time
+// between two alerts of the same rule.
+type AlertConfig struct {
+	Webhook  string        `yaml:"webhook"`
+	Cooldown time.Duration `yaml:"cooldown"`
+	Rules    []AlertRule   `yaml:"rules"`
+}
+
+// LoadAlertConfig reads and validates the alert rules file.
+func LoadAlertConfig(path string) (*AlertConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config AlertConfig
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	if config.Cooldown == 0 {
+		config.Cooldown = defaultAlertCooldown
+	}
+
+	for i, rule := range config.Rules {
+		if len(rule.Name) == 0 {
+			config.Rules[i].Name = string(rule.Kind)
+		}
+
+		switch rule.Kind {
+		case AlertNoNewBlocks:
+			if rule.Duration <= 0 {
+				return nil, fmt.Errorf("rule %q needs a positive duration", rule.Name)
+			}
+		case AlertReorgDepth:
+		case AlertPeerCount:
+			if rule.Threshold <= 0 {
+				return nil, fmt.Errorf("rule %q needs a positive threshold", rule.Name)
+			}
+		case AlertAddress:
+			if len(rule.Addresses) == 0 {
+				return nil, fmt.Errorf("rule %q needs addresses", rule.Name)
+			}
+			for _, address := range rule.Addresses {
+				if !common.IsHexAddress(address) {
+					return nil, fmt.Errorf("rule %q has an invalid address %q", rule.Name, address)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("rule %q has an unknown kind %q", rule.Name, rule.Kind)
+		}
+
+		if len(rule.Webhook) == 0 && len(config.Webhook) == 0 {
+			return nil, fmt.Errorf("rule %q has no webhook", rule.Name)
+		}
+	}
+
+	return &config, nil
+}
+
+// Alerts evaluates the alert rules against what the sensor observes, and
+// posts the alerts to the webhooks of the rules.
+type Alerts struct {
+	config    *AlertConfig
+	client    *http.Client
+	addresses map[common.Address][]*AlertRule
+
+	mu        sync.Mutex
+	started   time.Time
+	lastBlock time.Time
+	lowSince  time.Time
+	fired     map[string]time.Time
+
+	// headers are the recent blocks, and canonical the hash of the block of
+	// each recent number on the chain of the head.
+	headers   map[common.Hash]*database.Header
+	canonical map[uint64]common.Hash
+	head      uint64
+}
+
+// NewAlerts creates the alerts of the rules.
+func NewAlerts(config *AlertConfig) *Alerts {
+	a := &Alerts{
+		config:    config,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		addresses: make(map[common.Address][]*AlertRule),
+		started:   time.Now(),
+		fired:     make(map[string]time.Time),
+		headers:   make(map[common.Hash]*database.Header),
+		canonical: make(map[uint64]common.Hash),
+	}
+
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.Kind != AlertAddress {
+			continue
+		}
+		for _, address := range rule.Addresses {
+			addr := common.HexToAddress(address)
+			a.addresses[addr] = append(a.addresses[addr], rule)
+		}
+	}
+
+	return a
+}
+
+// Block records a received block header, which resets the stall timer and
+// fires the reorg rules when it replaces blocks of the chain. A nil Alerts
+// does nothing, which is the case when alerting is disabled.
+func (a *Alerts) Block(ctx context.Context, header *database.Header) {
+	if a == nil {
+		return
+	}
+
+	depth, reorged := a.addBlock(header)
+	if !reorged {
+		return
+	}
+
+	for i := range a.config.Rules {
+		rule := &a.config.Rules[i]
+		if rule.Kind == AlertReorgDepth && depth > rule.Depth {
+			a.fire(ctx, rule, "Reorg of depth %d replaced the chain below block %d %s",
+				depth, header.Number, header.Hash().TerminalString())
+		}
+	}
+}
+
+// addBlock adds the header to the recent blocks and returns the depth of the
+// reorg it caused, if it did.
+func (a *Alerts) addBlock(header *database.Header) (uint64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	hash := header.Hash()
+	if _, ok := a.headers[hash]; ok {
+		return 0, false
+	}
+	a.headers[hash] = header
+	a.lastBlock = time.Now()
+
+	number := header.Number.Uint64()
+	if len(a.canonical) == 0 {
+		a.canonical[number] = hash
+		a.head = number
+		return 0, false
+	}
+	if number <= a.head {
+		return 0, false
+	}
+
+	// Walk back the new chain until it meets the current one. The reorg depth
+	// is how many blocks of the current chain were above the common ancestor.
+	oldHead := a.head
+	var depth uint64
+	reorged := false
+	for h := header; h != nil; h = a.headers[h.ParentHash] {
+		n := h.Number.Uint64()
+		if canonical, ok := a.canonical[n]; ok && canonical == h.Hash() {
+			if n < oldHead {
+				depth = oldHead - n
+				reorged = true
+			}
+			break
+		}
+		a.canonical[n] = h.Hash()
+	}
+	a.head = number
+
+	a.prune()
+	return depth, reorged
+}
+
+// prune drops the blocks too old to be part of a measured reorg. The caller
+// must hold the lock.
+func (a *Alerts) prune() {
+	if a.head < alertChainDepth {
+		return
+	}
+	oldest := a.head - alertChainDepth
+
+	for n := range a.canonical {
+		if n < oldest {
+			delete(a.canonical, n)
+		}
+	}
+	for hash, header := range a.headers {
+		if header.Number.Uint64() < oldest {
+			delete(a.headers, hash)
+		}
+	}
+}
+
+// Transactions fires the address rules matching the sender or recipient of
+// the gossiped transactions.
+func (a *Alerts) Transactions(ctx context.Context, txs []*types.Transaction) {
+	if a == nil || len(a.addresses) == 0 {
+		return
+	}
+
+	for _, tx := range txs {
+		if to := tx.To(); to != nil {
+			for _, rule := range a.addresses[*to] {
+				a.fire(ctx, rule, "Transaction %s was sent to %s", tx.Hash().Hex(), to.Hex())
+			}
+		}
+
+		from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			continue
+		}
+		for _, rule := range a.addresses[from] {
+			a.fire(ctx, rule, "Transaction %s was sent from %s", tx.Hash().Hex(), from.Hex())
+		}
+	}
+}
+
+// Check evaluates the rules that depend on time passing, i.e. the stalled
+// chain and the peer count rules. It's meant to be called periodically.
+func (a *Alerts) Check(ctx context.Context, peers int) {
+	if a == nil {
+		return
+	}
+
+	now := time.Now()
+
+	a.mu.Lock()
+	lastBlock := a.lastBlock
+	if lastBlock.IsZero() {
+		lastBlock = a.started
+	}
+	// lowSince is when the peer count went below the highest threshold, and a
+	// rule with a lower threshold may only be reached after, which makes its
+	// duration a lower bound rather than exact.
+	low := false
+	for _, rule := range a.config.Rules {
+		if rule.Kind == AlertPeerCount && peers < rule.Threshold {
+			low = true
+		}
+	}
+	if !low {
+		a.lowSince = time.Time{}
+	} else if a.lowSince.IsZero() {
+		a.lowSince = now
+	}
+	lowSince := a.lowSince
+	a.mu.Unlock()
+
+	for i := range a.config.Rules {
+		rule := &a.config.Rules[i]
+		switch rule.Kind {
+		case AlertNoNewBlocks:
+			if since := now.Sub(lastBlock); since >= rule.Duration {
+				a.fire(ctx, rule, "No new block received for %s", since.Truncate(time.Second))
+			}
+		case AlertPeerCount:
+			if peers < rule.Threshold && now.Sub(lowSince) >= rule.Duration {
+				a.fire(ctx, rule, "Peer count %d is below %d", peers, rule.Threshold)
+			}
+		}
+	}
+}
+
+// fire posts the alert of the rule unless the rule fired within its cooldown.
+func (a *Alerts) fire(ctx context.Context, rule *AlertRule, format string, args ...interface{}) {
+	cooldown := rule.Cooldown
+	if cooldown == 0 {
+		cooldown = a.config.Cooldown
+	}
+
+	now := time.Now()
+	a.mu.Lock()
+	if last, ok := a.fired[rule.Name]; ok && now.Sub(last) < cooldown {
+		a.mu.Unlock()
+		return
+	}
+	a.fired[rule.Name] = now
+	a.mu.Unlock()
+
+	message := fmt.Sprintf(format, args...)
+	log.Warn().Str("rule", rule.Name).Str("kind", string(rule.Kind)).Msg(message)
+
+	webhook := rule.Webhook
+	if len(webhook) == 0 {
+		webhook = a.config.Webhook
+	}
+	go a.post(ctx, webhook, rule, message, now)
+}
+
+// alertPayload is compatible with Slack incoming webhooks through the text
+// field, and carries the summary, severity, source, and timestamp fields used
+// by PagerDuty events.
+type alertPayload struct {
+	Text          string            `json:"text"`
+	Summary       string            `json:"summary"`
+	Severity      string            `json:"severity"`
+	Source        string            `json:"source"`
+	Timestamp     string            `json:"timestamp"`
+	CustomDetails map[string]string `json:"custom_details"`
+}
+
+func (a *Alerts) post(ctx context.Context, webhook string, rule *AlertRule, message string, t time.Time) {
+	payload := alertPayload{
+		Text:      fmt.Sprintf("[%s] %s", rule.Name, message),
+		Summary:   message,
+		Severity:  "warning",
+		Source:    alertSource,
+		Timestamp: t.UTC().Format(time.RFC3339),
+		CustomDetails: map[string]string{
+			"rule": rule.Name,
+			"kind": string(rule.Kind),
+		},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to marshal alert")
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create alert webhook request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		log.Error().Err(err).Str("rule", rule.Name).Msg("Unable to post alert to webhook")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Error().Int("status", resp.StatusCode).Str("rule", rule.Name).Msg("Alert webhook returned an error")
+	}
+}
+
+// String lists the rules, e.g. for logging.
+func (c *AlertConfig) String() string {
+	names := make([]string, 0, len(c.Rules))
+	for _, rule := range c.Rules {
+		names = append(names, rule.Name)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,114 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+)
+
+func newAlertServer(t *testing.T) (*httptest.Server, chan alertPayload) {
+	payloads := make(chan alertPayload, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload alertPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode alert: %v", err)
+		}
+		payloads <- payload
+	}))
+	t.Cleanup(server.Close)
+	return server, payloads
+}
+
+func expectAlert(t *testing.T, payloads chan alertPayload, rule string) {
+	t.Helper()
+	select {
+	case payload := <-payloads:
+		if payload.CustomDetails["rule"] != rule {
+			t.Errorf("expected an alert of rule %q, got %q", rule, payload.CustomDetails["rule"])
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected an alert of rule %q", rule)
+	}
+}
+
+func expectNoAlert(t *testing.T, payloads chan alertPayload) {
+	t.Helper()
+	select {
+	case payload := <-payloads:
+		t.Errorf("expected no alert, got %q", payload.Text)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAlertsReorg(t *testing.T) {
+	server, payloads := newAlertServer(t)
+	a := NewAlerts(&AlertConfig{
+		Webhook:  server.URL,
+		Cooldown: time.Minute,
+		Rules:    []AlertRule{{Name: "reorg", Kind: AlertReorgDepth, Depth: 1}},
+	})
+	ctx := context.Background()
+
+	header := func(number int64, parent common.Hash, extra byte) *database.Header {
+		return &database.Header{Number: big.NewInt(number), ParentHash: parent, Extra: []byte{extra}}
+	}
+
+	// The chain is 1 <- 2a <- 3a, then 2b <- 3b <- 4b replaces the 2 blocks
+	// above block 1.
+	b1 := header(1, common.Hash{}, 0)
+	b2a := header(2, b1.Hash(), 'a')
+	b3a := header(3, b2a.Hash(), 'a')
+	for _, h := range []*database.Header{b1, b2a, b3a} {
+		a.Block(ctx, h)
+	}
+	expectNoAlert(t, payloads)
+
+	b2b := header(2, b1.Hash(), 'b')
+	b3b := header(3, b2b.Hash(), 'b')
+	b4b := header(4, b3b.Hash(), 'b')
+	a.Block(ctx, b2b)
+	a.Block(ctx, b3b)
+	expectNoAlert(t, payloads)
+
+	a.Block(ctx, b4b)
+	expectAlert(t, payloads, "reorg")
+}
+
+func TestAlertsCheck(t *testing.T) {
+	server, payloads := newAlertServer(t)
+	a := NewAlerts(&AlertConfig{
+		Webhook:  server.URL,
+		Cooldown: time.Minute,
+		Rules: []AlertRule{
+			{Name: "stalled", Kind: AlertNoNewBlocks, Duration: time.Hour},
+			{Name: "peers", Kind: AlertPeerCount, Threshold: 5},
+		},
+	})
+	ctx := context.Background()
+
+	a.Check(ctx, 10)
+	expectNoAlert(t, payloads)
+
+	a.Check(ctx, 3)
+	expectAlert(t, payloads, "peers")
+
+	// The rule already fired within its cooldown.
+	a.Check(ctx, 3)
+	expectNoAlert(t, payloads)
+
+	a.started = time.Now().Add(-2 * time.Hour)
+	a.Check(ctx, 10)
+	expectAlert(t, payloads, "stalled")
+
+	var nilAlerts *Alerts
+	nilAlerts.Check(ctx, 0)
+	nilAlerts.Block(ctx, &database.Header{Number: big.NewInt(1)})
+}
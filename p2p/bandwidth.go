@@ -0,0 +1,254 @@
+package p2p
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+)
+
+// Bandwidth accounts the bytes and messages exchanged with every peer per
+// message type, so abusive peers can be found and deployments sized. The
+// sizes are the ones of the decrypted message payloads, which excludes the
+// RLPx framing.
+type Bandwidth struct {
+	mu    sync.Mutex
+	peers map[enode.ID]*peerBandwidth
+
+	bytes    *prometheus.CounterVec
+	messages *prometheus.CounterVec
+}
+
+type peerBandwidth struct {
+	node      *enode.Node
+	name      string
+	connected time.Time
+	in, out   [eth.PooledTransactionsMsg + 1]messageBandwidth
+}
+
+type messageBandwidth struct {
+	bytes    uint64
+	messages uint64
+}
+
+// NewBandwidth creates a new bandwidth tracker. The counters are registered
+// with the registry, which can be nil if the metrics aren't served.
+func NewBandwidth(registry prometheus.Registerer) *Bandwidth {
+	b := &Bandwidth{
+		peers: make(map[enode.ID]*peerBandwidth),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "polycli",
+			Subsystem: "sensor",
+			Name:      "peer_bytes_total",
+			Help:      "The bytes of the message payloads exchanged with the peer",
+		}, []string{"peer", "direction", "message"}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "polycli",
+			Subsystem: "sensor",
+			Name:      "peer_messages_total",
+			Help:      "The messages exchanged with the peer",
+		}, []string{"peer", "direction", "message"}),
+	}
+
+	if registry != nil {
+		registry.MustRegister(b.bytes, b.messages)
+	}
+
+	return b
+}
+
+// Meter starts accounting the peer and returns a MsgReadWriter counting the
+// messages read from and written to rw. It returns rw as is if the tracker is
+// nil, which is the case when the accounting is disabled.
+func (b *Bandwidth) Meter(p *ethp2p.Peer, rw ethp2p.MsgReadWriter) ethp2p.MsgReadWriter {
+	if b == nil {
+		return rw
+	}
+
+	peer := &peerBandwidth{
+		node:      p.Node(),
+		name:      p.Fullname(),
+		connected: time.Now(),
+	}
+
+	b.mu.Lock()
+	b.peers[p.ID()] = peer
+	b.mu.Unlock()
+
+	return &meteredRW{MsgReadWriter: rw, bandwidth: b, peer: peer}
+}
+
+// Disconnected stops accounting the peer and returns its final usage, so it
+// can be written one last time.
+func (b *Bandwidth) Disconnected(id enode.ID) *database.PeerBandwidth {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	peer, ok := b.peers[id]
+	delete(b.peers, id)
+	b.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	// The label cardinality would otherwise grow with every peer ever seen.
+	b.bytes.DeletePartialMatch(prometheus.Labels{"peer": id.TerminalString()})
+	b.messages.DeletePartialMatch(prometheus.Labels{"peer": id.TerminalString()})
+
+	usage := peer.usage()
+	return &usage
+}
+
+// Snapshot returns the usage of every connected peer, the heaviest first.
+func (b *Bandwidth) Snapshot() []database.PeerBandwidth {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	peers := make([]*peerBandwidth, 0, len(b.peers))
+	for _, peer := range b.peers {
+		peers = append(peers, peer)
+	}
+	b.mu.Unlock()
+
+	usages := make([]database.PeerBandwidth, 0, len(peers))
+	for _, peer := range peers {
+		usages = append(usages, peer.usage())
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].BytesIn+usages[i].BytesOut > usages[j].BytesIn+usages[j].BytesOut
+	})
+
+	return usages
+}
+
+func (b *Bandwidth) record(peer *peerBandwidth, inbound bool, code uint64, size uint32) {
+	direction, counters := "out", &peer.out
+	if inbound {
+		direction, counters = "in", &peer.in
+	}
+
+	if code < uint64(len(counters)) {
+		atomic.AddUint64(&counters[code].bytes, uint64(size))
+		atomic.AddUint64(&counters[code].messages, 1)
+	}
+
+	labels := prometheus.Labels{
+		"peer":      peer.node.ID().TerminalString(),
+		"direction": direction,
+		"message":   messageName(code),
+	}
+	b.bytes.With(labels).Add(float64(size))
+	b.messages.With(labels).Inc()
+}
+
+// usage returns the per message usage of the peer in a thread-safe manner.
+func (p *peerBandwidth) usage() database.PeerBandwidth {
+	usage := database.PeerBandwidth{
+		Node:      p.node,
+		Name:      p.name,
+		Connected: p.connected,
+	}
+
+	for code := range p.in {
+		in := messageBandwidth{
+			bytes:    atomic.LoadUint64(&p.in[code].bytes),
+			messages: atomic.LoadUint64(&p.in[code].messages),
+		}
+		out := messageBandwidth{
+			bytes:    atomic.LoadUint64(&p.out[code].bytes),
+			messages: atomic.LoadUint64(&p.out[code].messages),
+		}
+		if in.messages == 0 && out.messages == 0 {
+			continue
+		}
+
+		usage.BytesIn += in.bytes
+		usage.BytesOut += out.bytes
+		usage.Messages = append(usage.Messages, database.MessageBandwidth{
+			Message:     messageName(uint64(code)),
+			BytesIn:     in.bytes,
+			BytesOut:    out.bytes,
+			MessagesIn:  in.messages,
+			MessagesOut: out.messages,
+		})
+	}
+
+	return usage
+}
+
+// meteredRW records the size of every message read and written with the
+// peer's bandwidth.
+type meteredRW struct {
+	ethp2p.MsgReadWriter
+	bandwidth *Bandwidth
+	peer      *peerBandwidth
+}
+
+func (rw *meteredRW) ReadMsg() (ethp2p.Msg, error) {
+	msg, err := rw.MsgReadWriter.ReadMsg()
+	if err == nil {
+		rw.bandwidth.record(rw.peer, true, msg.Code, msg.Size)
+	}
+	return msg, err
+}
+
+func (rw *meteredRW) WriteMsg(msg ethp2p.Msg) error {
+	code, size := msg.Code, msg.Size
+	err := rw.MsgReadWriter.WriteMsg(msg)
+	if err == nil {
+		rw.bandwidth.record(rw.peer, false, code, size)
+	}
+	return err
+}
+
+// messageName returns the name of the eth protocol message code.
+func messageName(code uint64) string {
+	switch code {
+	case eth.StatusMsg:
+		return "Status"
+	case eth.NewBlockHashesMsg:
+		return "NewBlockHashes"
+	case eth.TransactionsMsg:
+		return "Transactions"
+	case eth.GetBlockHeadersMsg:
+		return "GetBlockHeaders"
+	case eth.BlockHeadersMsg:
+		return "BlockHeaders"
+	case eth.GetBlockBodiesMsg:
+		return "GetBlockBodies"
+	case eth.BlockBodiesMsg:
+		return "BlockBodies"
+	case eth.NewBlockMsg:
+		return "NewBlock"
+	case eth.GetNodeDataMsg:
+		return "GetNodeData"
+	case eth.NodeDataMsg:
+		return "NodeData"
+	case eth.GetReceiptsMsg:
+		return "GetReceipts"
+	case eth.ReceiptsMsg:
+		return "Receipts"
+	case eth.NewPooledTransactionHashesMsg:
+		return "NewPooledTransactionHashes"
+	case eth.GetPooledTransactionsMsg:
+		return "GetPooledTransactions"
+	case eth.PooledTransactionsMsg:
+		return "PooledTransactions"
+	default:
+		return fmt.Sprintf("Unknown(%d)", code)
+	}
+}
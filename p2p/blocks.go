@@ -0,0 +1,129 @@
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/rs/zerolog/log"
+)
+
+// bodyKey identifies a block body by the roots it hashes to in the header.
+type bodyKey struct {
+	txHash    common.Hash
+	uncleHash common.Hash
+}
+
+// CapturedBlocks reads the full blocks out of a capture file. Blocks come from
+// the new block announcements, and from the block headers that a matching
+// block body was captured for. If several blocks were captured at the same
+// height, the one the next block builds on is picked, so the result is the
+// chain the peers converged on. The blocks are sorted by number.
+func CapturedBlocks(reader *CaptureReader) ([]*types.Block, error) {
+	blocks := make(map[common.Hash]*types.Block)
+	headers := make(map[common.Hash]*types.Header)
+	bodies := make(map[bodyKey]*eth.BlockBody)
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch record.Code {
+		case eth.NewBlockMsg:
+			var packet eth.NewBlockPacket
+			if err = rlp.Decode(bytes.NewReader(record.Payload), &packet); err != nil {
+				log.Warn().Err(err).Msg("Skipping undecodable new block message")
+				continue
+			}
+			blocks[packet.Block.Hash()] = packet.Block
+		case eth.BlockHeadersMsg:
+			var packet eth.BlockHeadersPacket66
+			if err = rlp.Decode(bytes.NewReader(record.Payload), &packet); err != nil {
+				log.Warn().Err(err).Msg("Skipping undecodable block headers message")
+				continue
+			}
+			for _, header := range packet.BlockHeadersPacket {
+				headers[header.Hash()] = header
+			}
+		case eth.BlockBodiesMsg:
+			var packet eth.BlockBodiesPacket66
+			if err = rlp.Decode(bytes.NewReader(record.Payload), &packet); err != nil {
+				log.Warn().Err(err).Msg("Skipping undecodable block bodies message")
+				continue
+			}
+			for _, body := range packet.BlockBodiesPacket {
+				key := bodyKey{
+					txHash:    types.DeriveSha(types.Transactions(body.Transactions), trie.NewStackTrie(nil)),
+					uncleHash: types.CalcUncleHash(body.Uncles),
+				}
+				bodies[key] = body
+			}
+		}
+	}
+
+	for hash, header := range headers {
+		if _, ok := blocks[hash]; ok {
+			continue
+		}
+		body, ok := bodies[bodyKey{txHash: header.TxHash, uncleHash: header.UncleHash}]
+		if !ok {
+			if header.TxHash != types.EmptyRootHash || header.UncleHash != types.EmptyUncleHash {
+				continue
+			}
+			body = &eth.BlockBody{}
+		}
+		blocks[hash] = types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
+	}
+
+	return canonicalBlocks(blocks), nil
+}
+
+// canonicalBlocks picks a single block at every height, preferring the
+// parent of the block picked at the next height.
+func canonicalBlocks(blocks map[common.Hash]*types.Block) []*types.Block {
+	byNumber := make(map[uint64][]*types.Block)
+	numbers := make([]uint64, 0)
+	for _, block := range blocks {
+		n := block.NumberU64()
+		if _, ok := byNumber[n]; !ok {
+			numbers = append(numbers, n)
+		}
+		byNumber[n] = append(byNumber[n], block)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] > numbers[j] })
+
+	chain := make([]*types.Block, 0, len(numbers))
+	var parent *common.Hash
+	for _, n := range numbers {
+		candidates := byNumber[n]
+		sort.Slice(candidates, func(i, j int) bool {
+			return bytes.Compare(candidates[i].Hash().Bytes(), candidates[j].Hash().Bytes()) < 0
+		})
+		picked := candidates[0]
+		for _, candidate := range candidates {
+			if parent != nil && candidate.Hash() == *parent {
+				picked = candidate
+				break
+			}
+		}
+		hash := picked.ParentHash()
+		parent = &hash
+		chain = append(chain, picked)
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
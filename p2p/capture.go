@@ -0,0 +1,143 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// captureMagic is written at the start of every capture file so that the
+// reader can reject files that aren't captures.
+var captureMagic = []byte("polycli-devp2p-capture-v1\n")
+
+// CaptureRecord is a single raw devp2p message received from a peer. The
+// payload is the RLP encoded message exactly as it was read off the wire, so
+// it can be fed back through the protocol handlers.
+type CaptureRecord struct {
+	// Time is the unix timestamp in nanoseconds the message was received.
+	Time    uint64
+	Peer    string
+	Code    uint64
+	Payload []byte
+}
+
+// Received returns the time the message was received.
+func (r *CaptureRecord) Received() time.Time {
+	return time.Unix(0, int64(r.Time))
+}
+
+// CaptureWriter writes messages to a capture file. It is safe to use across
+// multiple peer connections.
+type CaptureWriter struct {
+	file *os.File
+	w    *bufio.Writer
+	mu   sync.Mutex
+}
+
+// NewCaptureWriter creates the capture file. If the file exists it will be
+// truncated.
+func NewCaptureWriter(path string) (*CaptureWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(file)
+	if _, err = w.Write(captureMagic); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &CaptureWriter{file: file, w: w}, nil
+}
+
+// Write reads the message payload and writes it to the capture file. The
+// payload is read in full, so the message's payload is replaced with a new
+// reader containing the same bytes to allow the message to still be decoded
+// afterwards.
+func (c *CaptureWriter) Write(peer *enode.Node, msg *ethp2p.Msg) error {
+	payload, err := io.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	msg.Payload = bytes.NewReader(payload)
+
+	record := CaptureRecord{
+		Time:    uint64(msg.ReceivedAt.UnixNano()),
+		Peer:    peer.URLv4(),
+		Code:    msg.Code,
+		Payload: payload,
+	}
+	if msg.ReceivedAt.IsZero() {
+		record.Time = uint64(time.Now().UnixNano())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return rlp.Encode(c.w, &record)
+}
+
+// Close flushes the buffered records and closes the capture file.
+func (c *CaptureWriter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.w.Flush(); err != nil {
+		c.file.Close()
+		return err
+	}
+
+	return c.file.Close()
+}
+
+// CaptureReader reads records from a capture file.
+type CaptureReader struct {
+	file   *os.File
+	stream *rlp.Stream
+}
+
+// NewCaptureReader opens a capture file written by a CaptureWriter.
+func NewCaptureReader(path string) (*CaptureReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(file)
+	magic := make([]byte, len(captureMagic))
+	if _, err = io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, captureMagic) {
+		file.Close()
+		return nil, fmt.Errorf("%v is not a capture file", path)
+	}
+
+	return &CaptureReader{file: file, stream: rlp.NewStream(r, 0)}, nil
+}
+
+// Next returns the next record in the capture file. It returns io.EOF when
+// there are no more records.
+func (c *CaptureReader) Next() (*CaptureRecord, error) {
+	var record CaptureRecord
+	if err := c.stream.Decode(&record); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// Close closes the capture file.
+func (c *CaptureReader) Close() error {
+	return c.file.Close()
+}
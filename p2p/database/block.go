@@ -0,0 +1,173 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Header is a block header with the fields added since London. The types of
+// go-ethereum v1.10.26 stop at London, so the headers of Shanghai and later
+// blocks fail to decode with them, and their hashes are wrong. The fields of
+// the forks a block predates are nil.
+type Header struct {
+	ParentHash  common.Hash
+	UncleHash   common.Hash
+	Coinbase    common.Address
+	Root        common.Hash
+	TxHash      common.Hash
+	ReceiptHash common.Hash
+	Bloom       types.Bloom
+	Difficulty  *big.Int
+	Number      *big.Int
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        uint64
+	Extra       []byte
+	MixDigest   common.Hash
+	Nonce       types.BlockNonce
+
+	// BaseFee was added in London.
+	BaseFee *big.Int `rlp:"optional"`
+
+	// WithdrawalsHash was added in Shanghai.
+	WithdrawalsHash *common.Hash `rlp:"optional"`
+
+	// BlobGasUsed, ExcessBlobGas, and ParentBeaconRoot were added in Cancun.
+	BlobGasUsed      *uint64      `rlp:"optional"`
+	ExcessBlobGas    *uint64      `rlp:"optional"`
+	ParentBeaconRoot *common.Hash `rlp:"optional"`
+
+	// RequestsHash was added in Prague.
+	RequestsHash *common.Hash `rlp:"optional"`
+}
+
+// Hash returns the keccak256 hash of the RLP encoding of the header, which
+// covers the optional fields the header has.
+func (h *Header) Hash() common.Hash {
+	data, err := rlp.EncodeToBytes(h)
+	if err != nil {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(data)
+}
+
+// Withdrawal is a withdrawal from the beacon chain to the execution layer.
+// The amount is in gwei.
+type Withdrawal struct {
+	Index     uint64
+	Validator uint64
+	Address   common.Address
+	Amount    uint64
+}
+
+// Withdrawals are the withdrawals of a block. Their root is derived with
+// types.DeriveSha.
+type Withdrawals []*Withdrawal
+
+func (ws Withdrawals) Len() int { return len(ws) }
+
+func (ws Withdrawals) EncodeIndex(i int, w *bytes.Buffer) {
+	_ = rlp.Encode(w, ws[i])
+}
+
+// Transactions are the encoded transactions of a block. They're decoded one
+// by one, so the transactions of types go-ethereum v1.10.26 doesn't know, like
+// the blob transactions of Cancun, don't fail the whole block.
+type Transactions []rlp.RawValue
+
+// Decode returns the transactions that could be decoded and the hashes of all
+// the transactions, in the order of the block.
+func (txs Transactions) Decode() ([]*types.Transaction, []common.Hash, error) {
+	decoded := make([]*types.Transaction, 0, len(txs))
+	hashes := make([]common.Hash, 0, len(txs))
+	for _, raw := range txs {
+		envelope, err := txEnvelope(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes = append(hashes, crypto.Keccak256Hash(envelope))
+
+		tx := new(types.Transaction)
+		err = rlp.DecodeBytes(raw, tx)
+		if errors.Is(err, types.ErrTxTypeNotSupported) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		decoded = append(decoded, tx)
+	}
+	return decoded, hashes, nil
+}
+
+// Len and EncodeIndex make the transactions a types.DerivableList, so their
+// root can be derived with types.DeriveSha.
+func (txs Transactions) Len() int { return len(txs) }
+
+func (txs Transactions) EncodeIndex(i int, w *bytes.Buffer) {
+	envelope, err := txEnvelope(txs[i])
+	if err != nil {
+		envelope = txs[i]
+	}
+	w.Write(envelope)
+}
+
+// txEnvelope returns the canonical encoding of the transaction, which its
+// hash and the transactions root are computed from. Legacy transactions are
+// an RLP list, and the envelope of typed transactions is wrapped in a string
+// inside blocks.
+func txEnvelope(raw rlp.RawValue) ([]byte, error) {
+	kind, content, _, err := rlp.Split(raw)
+	if err != nil {
+		return nil, err
+	}
+	if kind == rlp.List {
+		return raw, nil
+	}
+	return content, nil
+}
+
+// Body is the body of a block, with the withdrawals added in Shanghai.
+type Body struct {
+	Transactions Transactions
+	Uncles       []*Header
+	Withdrawals  Withdrawals `rlp:"optional"`
+}
+
+// UncleHash returns the hash of the uncles, which is the uncle hash of the
+// header of the block.
+func (b *Body) UncleHash() common.Hash {
+	data, err := rlp.EncodeToBytes(b.Uncles)
+	if err != nil {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(data)
+}
+
+// Block is a block with the fields added since London.
+type Block struct {
+	Header       *Header
+	Transactions Transactions
+	Uncles       []*Header
+	Withdrawals  Withdrawals `rlp:"optional"`
+}
+
+// Hash returns the hash of the header of the block.
+func (b *Block) Hash() common.Hash {
+	return b.Header.Hash()
+}
+
+// Body returns the body of the block.
+func (b *Block) Body() *Body {
+	return &Body{
+		Transactions: b.Transactions,
+		Uncles:       b.Uncles,
+		Withdrawals:  b.Withdrawals,
+	}
+}
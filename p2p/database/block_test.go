@@ -0,0 +1,125 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestHeaderHashBeforeShanghai(t *testing.T) {
+	header := &types.Header{
+		ParentHash: common.Hash{1},
+		Difficulty: big.NewInt(0),
+		Number:     big.NewInt(17034869),
+		GasLimit:   30000000,
+		GasUsed:    12000000,
+		Time:       1681338455,
+		Extra:      []byte("polycli"),
+		BaseFee:    big.NewInt(7),
+	}
+	data, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Header
+	if err = rlp.DecodeBytes(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash() != header.Hash() {
+		t.Errorf("expected hash %v, got %v", header.Hash(), decoded.Hash())
+	}
+	if decoded.WithdrawalsHash != nil || decoded.BlobGasUsed != nil {
+		t.Errorf("expected no Shanghai or Cancun fields, got %+v", decoded)
+	}
+}
+
+func TestDecodeCancunBlock(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewLondonSigner(big.NewInt(1))
+	legacy := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 1, Gas: 21000, GasPrice: big.NewInt(1)})
+	dynamic := types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: big.NewInt(1), Nonce: 2, Gas: 21000, GasFeeCap: big.NewInt(2), GasTipCap: big.NewInt(1)})
+
+	// go-ethereum v1.10.26 doesn't know blob transactions, so the envelope is
+	// made up of the type and any payload.
+	blobEnvelope := append([]byte{0x03}, mustEncode(t, []uint64{3, 21000})...)
+
+	txs := Transactions{mustEncode(t, legacy), mustEncode(t, dynamic), mustEncode(t, blobEnvelope)}
+	withdrawals := Withdrawals{{Index: 1, Validator: 2, Address: common.Address{3}, Amount: 4}}
+
+	withdrawalsHash := types.DeriveSha(withdrawals, trie.NewStackTrie(nil))
+	blobGasUsed, excessBlobGas := uint64(131072), uint64(0)
+	beaconRoot := common.Hash{5}
+	header := &Header{
+		UncleHash:        types.EmptyUncleHash,
+		TxHash:           types.DeriveSha(txs, trie.NewStackTrie(nil)),
+		Difficulty:       big.NewInt(0),
+		Number:           big.NewInt(19426587),
+		BaseFee:          big.NewInt(7),
+		WithdrawalsHash:  &withdrawalsHash,
+		BlobGasUsed:      &blobGasUsed,
+		ExcessBlobGas:    &excessBlobGas,
+		ParentBeaconRoot: &beaconRoot,
+	}
+
+	// The transactions root of the known transactions is the one go-ethereum
+	// derives.
+	known := Transactions{txs[0], txs[1]}
+	if hash := types.DeriveSha(known, trie.NewStackTrie(nil)); hash != types.DeriveSha(types.Transactions{legacy, dynamic}, trie.NewStackTrie(nil)) {
+		t.Errorf("unexpected transactions root %v", hash)
+	}
+
+	data := mustEncode(t, &Block{Header: header, Transactions: txs, Withdrawals: withdrawals})
+	var block Block
+	if err := rlp.DecodeBytes(data, &block); err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Hash() != header.Hash() {
+		t.Errorf("expected hash %v, got %v", header.Hash(), block.Hash())
+	}
+	if block.Header.BlobGasUsed == nil || *block.Header.BlobGasUsed != blobGasUsed {
+		t.Errorf("expected blob gas used %d, got %v", blobGasUsed, block.Header.BlobGasUsed)
+	}
+	if block.Header.ExcessBlobGas == nil || *block.Header.ExcessBlobGas != excessBlobGas {
+		t.Errorf("expected excess blob gas %d, got %v", excessBlobGas, block.Header.ExcessBlobGas)
+	}
+	if len(block.Withdrawals) != 1 || *block.Withdrawals[0] != *withdrawals[0] {
+		t.Errorf("unexpected withdrawals %v", block.Withdrawals)
+	}
+
+	decoded, hashes, err := block.Transactions.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0].Hash() != legacy.Hash() || decoded[1].Hash() != dynamic.Hash() {
+		t.Errorf("expected the legacy and dynamic fee transactions, got %v", decoded)
+	}
+	expected := []common.Hash{legacy.Hash(), dynamic.Hash(), crypto.Keccak256Hash(blobEnvelope)}
+	if len(hashes) != len(expected) {
+		t.Fatalf("expected %d hashes, got %d", len(expected), len(hashes))
+	}
+	for i := range expected {
+		if hashes[i] != expected[i] {
+			t.Errorf("expected hash %v of transaction %d, got %v", expected[i], i, hashes[i])
+		}
+	}
+
+	if hash := block.Body().UncleHash(); hash != types.EmptyUncleHash {
+		t.Errorf("expected the empty uncle hash, got %v", hash)
+	}
+}
+
+func mustEncode(t *testing.T, v interface{}) rlp.RawValue {
+	t.Helper()
+	data, err := rlp.EncodeToBytes(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/api/iterator"
+)
+
+// chainBatchSize is the number of blocks updated per datastore transaction,
+// which is the most a transaction can write.
+const chainBatchSize = 500
+
+// ChainStatus is whether a stored block is part of the canonical chain. It's
+// empty when it can't be decided, e.g. for the blocks before a gap in the
+// stored chain.
+type ChainStatus string
+
+const (
+	ChainCanonical ChainStatus = "canonical"
+	ChainOrphaned  ChainStatus = "orphaned"
+)
+
+// ChainBlock is what the canonical chain is built from for every stored block.
+// TotalDifficulty is nil when the sensor didn't receive it with the block.
+type ChainBlock struct {
+	Hash            common.Hash
+	ParentHash      common.Hash
+	Number          uint64
+	Difficulty      *big.Int
+	TotalDifficulty *big.Int
+}
+
+// BuildCanonicalChain links the blocks by their parent hash, and walks back
+// from the head with the highest total difficulty, or the highest number when
+// no total difficulty is known. The blocks on that walk are canonical, and the
+// other blocks at the same heights are orphaned. The blocks at heights the
+// walk doesn't reach are left out of the result, since they can't be decided
+// until the gaps are backfilled.
+func BuildCanonicalChain(blocks []ChainBlock) map[common.Hash]ChainStatus {
+	sorted := make([]*ChainBlock, 0, len(blocks))
+	byHash := make(map[common.Hash]*ChainBlock, len(blocks))
+	for i := range blocks {
+		sorted = append(sorted, &blocks[i])
+		byHash[blocks[i].Hash] = &blocks[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Number < sorted[j].Number
+	})
+
+	// Parents always come before their children in the sorted blocks, so the
+	// total difficulty of the parent is known by the time the child is reached.
+	// The received total difficulty is preferred over the derived one.
+	td := make(map[common.Hash]*big.Int, len(blocks))
+	for _, block := range sorted {
+		switch {
+		case block.TotalDifficulty != nil:
+			td[block.Hash] = block.TotalDifficulty
+		case td[block.ParentHash] != nil && block.Difficulty != nil:
+			td[block.Hash] = new(big.Int).Add(td[block.ParentHash], block.Difficulty)
+		}
+	}
+
+	var head *ChainBlock
+	for _, block := range sorted {
+		if head == nil || isHeavier(block, head, td) {
+			head = block
+		}
+	}
+
+	statuses := make(map[common.Hash]ChainStatus)
+	if head == nil {
+		return statuses
+	}
+
+	canonical := make(map[uint64]common.Hash)
+	for block := head; block != nil; block = byHash[block.ParentHash] {
+		canonical[block.Number] = block.Hash
+		statuses[block.Hash] = ChainCanonical
+	}
+
+	for _, block := range sorted {
+		if hash, ok := canonical[block.Number]; ok && hash != block.Hash {
+			statuses[block.Hash] = ChainOrphaned
+		}
+	}
+
+	return statuses
+}
+
+// isHeavier returns whether a is a better head than b. A known total
+// difficulty beats an unknown one, then the higher total difficulty, the
+// higher number, and the lower hash win, so the choice is deterministic.
+func isHeavier(a, b *ChainBlock, td map[common.Hash]*big.Int) bool {
+	tdA, tdB := td[a.Hash], td[b.Hash]
+	if (tdA != nil) != (tdB != nil) {
+		return tdA != nil
+	}
+	if tdA != nil {
+		if c := tdA.Cmp(tdB); c != 0 {
+			return c > 0
+		}
+	}
+	if a.Number != b.Number {
+		return a.Number > b.Number
+	}
+	return bytes.Compare(a.Hash[:], b.Hash[:]) < 0
+}
+
+// ReadChain reads the blocks with a header whose timestamp is between since
+// and until.
+func (d *Datastore) ReadChain(ctx context.Context, since, until time.Time) ([]ChainBlock, error) {
+	if d.client == nil {
+		return nil, errors.New("datastore client isn't initialized")
+	}
+
+	query := datastore.NewQuery(BlocksKind).
+		FilterField("Time", ">=", since).
+		FilterField("Time", "<", until)
+	iter := d.client.Run(ctx, query)
+
+	var blocks []ChainBlock
+	for {
+		var block DatastoreBlock
+		key, err := iter.Next(&block)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if block.DatastoreHeader == nil || block.ParentHash == nil {
+			continue
+		}
+
+		number, err := strconv.ParseUint(block.Number, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		chainBlock := ChainBlock{
+			Hash:       common.HexToHash(key.Name),
+			ParentHash: common.HexToHash(block.ParentHash.Name),
+			Number:     number,
+		}
+		if difficulty, ok := new(big.Int).SetString(block.Difficulty, 10); ok {
+			chainBlock.Difficulty = difficulty
+		}
+		if td, ok := new(big.Int).SetString(block.TotalDifficulty, 10); ok {
+			chainBlock.TotalDifficulty = td
+		}
+		blocks = append(blocks, chainBlock)
+	}
+
+	return blocks, nil
+}
+
+// WriteChainStatus marks the blocks as canonical or orphaned, and returns the
+// number of blocks whose status changed. The blocks are updated in
+// transactions, so the writes of a running sensor aren't overwritten.
+func (d *Datastore) WriteChainStatus(ctx context.Context, statuses map[common.Hash]ChainStatus) (int, error) {
+	if d.client == nil {
+		return 0, errors.New("datastore client isn't initialized")
+	}
+
+	keys := make([]*datastore.Key, 0, len(statuses))
+	for hash := range statuses {
+		keys = append(keys, datastore.NameKey(BlocksKind, hash.Hex(), nil))
+	}
+
+	changed := 0
+	for start := 0; start < len(keys); start += chainBatchSize {
+		end := start + chainBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+
+		var n int
+		_, err := d.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			n = 0
+			blocks := make([]DatastoreBlock, len(batch))
+			err := tx.GetMulti(batch, blocks)
+
+			var errs datastore.MultiError
+			if err != nil && !errors.As(err, &errs) {
+				return err
+			}
+
+			var putKeys []*datastore.Key
+			var putBlocks []*DatastoreBlock
+			for i, key := range batch {
+				if errs != nil && errs[i] != nil {
+					continue
+				}
+				status := string(statuses[common.HexToHash(key.Name)])
+				if blocks[i].Chain == status {
+					continue
+				}
+				blocks[i].Chain = status
+				putKeys = append(putKeys, key)
+				putBlocks = append(putBlocks, &blocks[i])
+			}
+
+			if len(putKeys) == 0 {
+				return nil
+			}
+			n = len(putKeys)
+			_, err = tx.PutMulti(putKeys, putBlocks)
+			return err
+		})
+		if err != nil {
+			return changed, err
+		}
+		changed += n
+	}
+
+	return changed, nil
+}
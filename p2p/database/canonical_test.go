@@ -0,0 +1,63 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestBuildCanonicalChain(t *testing.T) {
+	hash := func(b byte) common.Hash { return common.Hash{b} }
+	block := func(h, parent byte, number uint64, difficulty int64) ChainBlock {
+		return ChainBlock{Hash: hash(h), ParentHash: hash(parent), Number: number, Difficulty: big.NewInt(difficulty)}
+	}
+
+	// 1 <- 2 <- 3 <- 4 is the heaviest chain, 2 <- 13 is a lighter fork, and
+	// 20 <- 21 is after a gap the stored chain doesn't reach.
+	blocks := []ChainBlock{
+		block(1, 0, 1, 1),
+		block(2, 1, 2, 2),
+		block(3, 2, 3, 2),
+		block(4, 3, 4, 2),
+		block(13, 2, 3, 1),
+		block(20, 19, 7, 1),
+		block(21, 20, 8, 1),
+	}
+	blocks[0].TotalDifficulty = big.NewInt(100)
+
+	statuses := BuildCanonicalChain(blocks)
+
+	for _, h := range []byte{1, 2, 3, 4} {
+		if statuses[hash(h)] != ChainCanonical {
+			t.Errorf("expected block %d to be canonical, got %q", h, statuses[hash(h)])
+		}
+	}
+	if statuses[hash(13)] != ChainOrphaned {
+		t.Errorf("expected block 13 to be orphaned, got %q", statuses[hash(13)])
+	}
+	for _, h := range []byte{20, 21} {
+		if status, ok := statuses[hash(h)]; ok {
+			t.Errorf("expected block %d to be undecided, got %q", h, status)
+		}
+	}
+}
+
+func TestBuildCanonicalChainWithoutTD(t *testing.T) {
+	blocks := []ChainBlock{
+		{Hash: common.Hash{1}, Number: 1},
+		{Hash: common.Hash{2}, ParentHash: common.Hash{1}, Number: 2},
+		{Hash: common.Hash{3}, ParentHash: common.Hash{1}, Number: 2},
+	}
+
+	statuses := BuildCanonicalChain(blocks)
+
+	// Without any total difficulty, the tie at the same number goes to the
+	// lower hash.
+	if statuses[common.Hash{2}] != ChainCanonical || statuses[common.Hash{3}] != ChainOrphaned {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+	if statuses[common.Hash{1}] != ChainCanonical {
+		t.Errorf("expected block 1 to be canonical, got %q", statuses[common.Hash{1}])
+	}
+}
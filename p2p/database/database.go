@@ -3,11 +3,14 @@ package database
 import (
 	"context"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/maticnetwork/polygon-cli/p2p/geoip"
 )
 
 // Database represents a database solution to write block and transaction data
@@ -16,11 +19,11 @@ import (
 type Database interface {
 	// WriteBlock will write the both the block and block event to the database
 	// if ShouldWriteBlocks and ShouldWriteBlockEvents return true, respectively.
-	WriteBlock(context.Context, *enode.Node, *types.Block, *big.Int)
+	WriteBlock(context.Context, *enode.Node, *Block, *big.Int)
 
 	// WriteBlockHeaders will write the block headers if ShouldWriteBlocks
 	// returns true.
-	WriteBlockHeaders(context.Context, []*types.Header)
+	WriteBlockHeaders(context.Context, []*Header)
 
 	// WriteBlockHashes will write the block hashes if ShouldWriteBlockEvents
 	// returns true.
@@ -28,23 +31,69 @@ type Database interface {
 
 	// WriteBlockBodies will write the block bodies if ShouldWriteBlocks returns
 	// true.
-	WriteBlockBody(context.Context, *eth.BlockBody, common.Hash)
+	WriteBlockBody(context.Context, *Body, common.Hash)
 
 	// WriteTransactions will write the both the transaction and transaction
 	// event to the database if ShouldWriteTransactions and
 	// ShouldWriteTransactionEvents return true, respectively.
 	WriteTransactions(context.Context, *enode.Node, []*types.Transaction)
 
+	// WriteDroppedTransactions will write the transactions that were gossiped
+	// widely but never included if ShouldWriteTransactionEvents returns true.
+	WriteDroppedTransactions(context.Context, []DroppedTransaction)
+
+	// WritePeerBandwidth will write the bytes and messages exchanged with the
+	// peers if ShouldWritePeers returns true.
+	WritePeerBandwidth(context.Context, []PeerBandwidth)
+
+	// WritePeer will write the peer's client information and location to the
+	// database if ShouldWritePeers returns true. The location can be nil if
+	// the peer's IP address could not be enriched.
+	WritePeer(context.Context, *p2p.Peer, *geoip.Location)
+
 	// HasBlock will return whether the block is in the database. If the database
 	// client has not been initialized this will always return true.
 	HasBlock(context.Context, common.Hash) bool
 
+	// Flush will wait for the writes in flight to finish, or for the context
+	// to be done, which is returned as an error.
+	Flush(context.Context) error
+
 	MaxConcurrentWrites() int
 	ShouldWriteBlocks() bool
 	ShouldWriteBlockEvents() bool
 	ShouldWriteTransactions() bool
 	ShouldWriteTransactionEvents() bool
+	ShouldWritePeers() bool
 
 	// NodeList will return a list of enode URLs.
 	NodeList(ctx context.Context, limit int) ([]string, error)
 }
+
+// DroppedTransaction is a transaction that was announced by Peers peers since
+// FirstSeen but wasn't included in a block before it was flagged.
+type DroppedTransaction struct {
+	Hash      common.Hash
+	FirstSeen time.Time
+	Peers     int
+}
+
+// PeerBandwidth is the usage of a peer since it Connected. The sizes are the
+// ones of the message payloads.
+type PeerBandwidth struct {
+	Node      *enode.Node
+	Name      string
+	Connected time.Time
+	BytesIn   uint64
+	BytesOut  uint64
+	Messages  []MessageBandwidth
+}
+
+// MessageBandwidth is the usage of a peer for one message type.
+type MessageBandwidth struct {
+	Message     string
+	BytesIn     uint64
+	BytesOut    uint64
+	MessagesIn  uint64
+	MessagesOut uint64
+}
@@ -2,38 +2,58 @@ package database
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/datastore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/api/iterator"
+
+	"github.com/maticnetwork/polygon-cli/p2p/geoip"
 )
 
 const (
 	// Kinds are the datastore equivalent of tables.
-	BlocksKind            = "blocks"
-	BlockEventsKind       = "block_events"
-	TransactionsKind      = "transactions"
-	TransactionEventsKind = "transaction_events"
+	BlocksKind              = "blocks"
+	BlockEventsKind         = "block_events"
+	TransactionsKind        = "transactions"
+	TransactionEventsKind   = "transaction_events"
+	DroppedTransactionsKind = "dropped_transactions"
+	PeersKind               = "peers"
+	PeerBandwidthKind       = "peer_bandwidth"
 )
 
 // Datastore wraps the datastore client, stores the sensorID, and other
 // information needed when writing blocks and transactions.
 type Datastore struct {
-	client                       *datastore.Client
-	sensorID                     string
-	maxConcurrency               int
-	shouldWriteBlocks            bool
-	shouldWriteBlockEvents       bool
-	shouldWriteTransactions      bool
-	shouldWriteTransactionEvents bool
-	jobs                         chan struct{}
+	client         *datastore.Client
+	sensorID       string
+	maxConcurrency int
+	options        atomic.Pointer[WriteOptions]
+	jobs           chan struct{}
+}
+
+// WriteOptions are what the Datastore writes, which can be changed while it's
+// running with SetWriteOptions.
+type WriteOptions struct {
+	ShouldWriteBlocks            bool
+	ShouldWriteBlockEvents       bool
+	ShouldWriteTransactions      bool
+	ShouldWriteTransactionEvents bool
+	ShouldWritePeers             bool
+	TxSampleRate                 float64
+	TxEventSampleRate            float64
+	TxBodySampleRate             float64
 }
 
 // DatastoreEvent can represent a peer sending the sensor a transaction hash or
@@ -66,19 +86,39 @@ type DatastoreHeader struct {
 	MixDigest   string
 	Nonce       string
 	BaseFee     string
+
+	// The fields of the later forks are empty for the blocks before them.
+	WithdrawalsHash  string
+	BlobGasUsed      string
+	ExcessBlobGas    string
+	ParentBeaconRoot string
+	RequestsHash     string
+}
+
+// DatastoreWithdrawal represents a withdrawal of a block stored in datastore.
+// The amount is in gwei.
+type DatastoreWithdrawal struct {
+	Index     string
+	Validator string
+	Address   string
+	Amount    string
 }
 
-// DatastoreBlock represents a block stored in datastore.
+// DatastoreBlock represents a block stored in datastore. Chain is the
+// ChainStatus of the block, which is empty until the canonical chain is built.
 type DatastoreBlock struct {
 	*DatastoreHeader
 	TotalDifficulty string
 	Transactions    []*datastore.Key
 	Uncles          []*datastore.Key
+	Withdrawals     []DatastoreWithdrawal
+	Chain           string
 }
 
 // DatastoreTransaction represents a transaction stored in datastore. Data is
 // not indexed because there is a max sized for indexed byte slices, which Data
-// will occasionally exceed.
+// will occasionally exceed. Data and the signature are left empty for the
+// transactions that are only stored with their metadata.
 type DatastoreTransaction struct {
 	Data      []byte `datastore:",noindex"`
 	From      string
@@ -92,6 +132,57 @@ type DatastoreTransaction struct {
 	V, R, S   string
 	Time      time.Time
 	Type      int16
+	Size      int64
+}
+
+// DatastoreDroppedTransaction is written when a transaction was announced by
+// Peers peers since FirstSeen but wasn't included in a block by Time.
+type DatastoreDroppedTransaction struct {
+	SensorId  string
+	Hash      *datastore.Key
+	FirstSeen time.Time
+	Peers     int64
+	Time      time.Time
+}
+
+// DatastorePeer stores the client information of a peer along with the
+// optional geolocation and ASN enrichment. Peers are keyed by node ID so the
+// latest connection overwrites the previous one.
+type DatastorePeer struct {
+	Name         string
+	Caps         []string
+	URL          string
+	SensorId     string
+	LastSeen     time.Time
+	Country      string
+	City         string
+	Latitude     float64
+	Longitude    float64
+	ASN          int64
+	Organization string
+}
+
+// DatastorePeerBandwidth stores the usage of a peer for the connection that
+// started at Connected. It's rewritten periodically, so the latest usage is
+// kept until the peer disconnects.
+type DatastorePeerBandwidth struct {
+	SensorId  string
+	URL       string
+	Name      string
+	Connected time.Time
+	LastSeen  time.Time
+	BytesIn   int64
+	BytesOut  int64
+	Messages  []DatastoreMessageBandwidth `datastore:",noindex"`
+}
+
+// DatastoreMessageBandwidth stores the usage of a peer for one message type.
+type DatastoreMessageBandwidth struct {
+	Message     string
+	BytesIn     int64
+	BytesOut    int64
+	MessagesIn  int64
+	MessagesOut int64
 }
 
 // DatastoreOptions is used when creating a NewDatastore.
@@ -104,6 +195,14 @@ type DatastoreOptions struct {
 	ShouldWriteBlockEvents       bool
 	ShouldWriteTransactions      bool
 	ShouldWriteTransactionEvents bool
+	ShouldWritePeers             bool
+	// TxSampleRate and TxEventSampleRate are the shares of the transactions
+	// whose content and events are written, and TxBodySampleRate the share
+	// of the written transactions stored with their data and signature
+	// rather than only their metadata.
+	TxSampleRate      float64
+	TxEventSampleRate float64
+	TxBodySampleRate  float64
 }
 
 // NewDatastore connects to datastore and creates the client. This should
@@ -114,20 +213,39 @@ func NewDatastore(ctx context.Context, opts DatastoreOptions) Database {
 		log.Error().Err(err).Msg("Could not connect to Datastore")
 	}
 
-	return &Datastore{
-		client:                       client,
-		sensorID:                     opts.SensorID,
-		maxConcurrency:               opts.MaxConcurrency,
-		shouldWriteBlocks:            opts.ShouldWriteBlocks,
-		shouldWriteBlockEvents:       opts.ShouldWriteBlockEvents,
-		shouldWriteTransactions:      opts.ShouldWriteTransactions,
-		shouldWriteTransactionEvents: opts.ShouldWriteTransactionEvents,
-		jobs:                         make(chan struct{}, opts.MaxConcurrency),
+	d := &Datastore{
+		client:         client,
+		sensorID:       opts.SensorID,
+		maxConcurrency: opts.MaxConcurrency,
+		jobs:           make(chan struct{}, opts.MaxConcurrency),
 	}
+	d.SetWriteOptions(WriteOptions{
+		ShouldWriteBlocks:            opts.ShouldWriteBlocks,
+		ShouldWriteBlockEvents:       opts.ShouldWriteBlockEvents,
+		ShouldWriteTransactions:      opts.ShouldWriteTransactions,
+		ShouldWriteTransactionEvents: opts.ShouldWriteTransactionEvents,
+		ShouldWritePeers:             opts.ShouldWritePeers,
+		TxSampleRate:                 opts.TxSampleRate,
+		TxEventSampleRate:            opts.TxEventSampleRate,
+		TxBodySampleRate:             opts.TxBodySampleRate,
+	})
+
+	return d
+}
+
+// WriteOptions returns what the Datastore currently writes.
+func (d *Datastore) WriteOptions() WriteOptions {
+	return *d.options.Load()
+}
+
+// SetWriteOptions changes what the Datastore writes. The writes already in
+// flight aren't affected.
+func (d *Datastore) SetWriteOptions(opts WriteOptions) {
+	d.options.Store(&opts)
 }
 
 // WriteBlock writes the block and the block event to datastore.
-func (d *Datastore) WriteBlock(ctx context.Context, peer *enode.Node, block *types.Block, td *big.Int) {
+func (d *Datastore) WriteBlock(ctx context.Context, peer *enode.Node, block *Block, td *big.Int) {
 	if d.client == nil {
 		return
 	}
@@ -135,7 +253,7 @@ func (d *Datastore) WriteBlock(ctx context.Context, peer *enode.Node, block *typ
 	if d.ShouldWriteBlockEvents() {
 		d.jobs <- struct{}{}
 		go func() {
-			d.writeEvent(peer, BlockEventsKind, block.Hash(), BlocksKind)
+			d.writeEvent(peer, BlockEventsKind, block.Hash(), BlocksKind, time.Now())
 			<-d.jobs
 		}()
 	}
@@ -153,14 +271,14 @@ func (d *Datastore) WriteBlock(ctx context.Context, peer *enode.Node, block *typ
 // write block events because headers will only be sent to the sensor when
 // requested. The block events will be written when the hash is received
 // instead.
-func (d *Datastore) WriteBlockHeaders(ctx context.Context, headers []*types.Header) {
+func (d *Datastore) WriteBlockHeaders(ctx context.Context, headers []*Header) {
 	if d.client == nil || !d.ShouldWriteBlocks() {
 		return
 	}
 
 	for _, h := range headers {
 		d.jobs <- struct{}{}
-		go func(header *types.Header) {
+		go func(header *Header) {
 			d.writeBlockHeader(ctx, header)
 			<-d.jobs
 		}(h)
@@ -172,7 +290,7 @@ func (d *Datastore) WriteBlockHeaders(ctx context.Context, headers []*types.Head
 // requested. The block events will be written when the hash is received
 // instead. It will write the uncles and transactions to datastore if they
 // don't already exist.
-func (d *Datastore) WriteBlockBody(ctx context.Context, body *eth.BlockBody, hash common.Hash) {
+func (d *Datastore) WriteBlockBody(ctx context.Context, body *Body, hash common.Hash) {
 	if d.client == nil || !d.ShouldWriteBlocks() {
 		return
 	}
@@ -192,7 +310,7 @@ func (d *Datastore) WriteBlockHashes(ctx context.Context, peer *enode.Node, hash
 
 	d.jobs <- struct{}{}
 	go func() {
-		d.writeEvents(ctx, peer, BlockEventsKind, hashes, BlocksKind)
+		d.writeEvents(ctx, peer, BlockEventsKind, hashes, BlocksKind, time.Now())
 		<-d.jobs
 	}()
 }
@@ -212,37 +330,196 @@ func (d *Datastore) WriteTransactions(ctx context.Context, peer *enode.Node, txs
 	}
 
 	if d.ShouldWriteTransactionEvents() {
+		rate := d.options.Load().TxEventSampleRate
 		hashes := make([]common.Hash, 0, len(txs))
 		for _, tx := range txs {
-			hashes = append(hashes, tx.Hash())
+			if isSampled(tx.Hash(), 0, rate) {
+				hashes = append(hashes, tx.Hash())
+			}
+		}
+		if len(hashes) == 0 {
+			return
 		}
 
 		d.jobs <- struct{}{}
 		go func() {
-			d.writeEvents(ctx, peer, TransactionEventsKind, hashes, TransactionsKind)
+			d.writeEvents(ctx, peer, TransactionEventsKind, hashes, TransactionsKind, time.Now())
 			<-d.jobs
 		}()
 	}
 }
 
+// WriteDroppedTransactions writes the dropped transactions to datastore.
+func (d *Datastore) WriteDroppedTransactions(ctx context.Context, txs []DroppedTransaction) {
+	if d.client == nil || !d.ShouldWriteTransactionEvents() || len(txs) == 0 {
+		return
+	}
+
+	d.jobs <- struct{}{}
+	go func() {
+		d.writeDroppedTransactions(ctx, txs)
+		<-d.jobs
+	}()
+}
+
+// WritePeerBandwidth writes the usage of the peers to datastore.
+func (d *Datastore) WritePeerBandwidth(ctx context.Context, usages []PeerBandwidth) {
+	if d.client == nil || !d.ShouldWritePeers() || len(usages) == 0 {
+		return
+	}
+
+	d.jobs <- struct{}{}
+	go func() {
+		d.writePeerBandwidth(ctx, usages)
+		<-d.jobs
+	}()
+}
+
+// WritePeer writes the peer and its location to datastore.
+func (d *Datastore) WritePeer(ctx context.Context, peer *p2p.Peer, location *geoip.Location) {
+	if d.client == nil || !d.ShouldWritePeers() {
+		return
+	}
+
+	d.jobs <- struct{}{}
+	go func() {
+		d.writePeer(ctx, peer, location)
+		<-d.jobs
+	}()
+}
+
+// replay writes the journal record to datastore synchronously, with the same
+// settings as the write methods.
+func (d *Datastore) replay(ctx context.Context, r *journalRecord) error {
+	if d.client == nil {
+		return nil
+	}
+
+	var peer *enode.Node
+	if len(r.Peer) > 0 {
+		var err error
+		if peer, err = enode.ParseV4(r.Peer); err != nil {
+			return invalidRecord(err)
+		}
+	}
+
+	switch r.Kind {
+	case journalBlock:
+		var block Block
+		if err := rlp.DecodeBytes(r.Data, &block); err != nil {
+			return invalidRecord(err)
+		}
+		if d.ShouldWriteBlockEvents() {
+			if err := d.writeEvent(peer, BlockEventsKind, block.Hash(), BlocksKind, r.Time); err != nil {
+				return err
+			}
+		}
+		if d.ShouldWriteBlocks() {
+			return d.writeBlock(ctx, &block, r.TD)
+		}
+	case journalBlockHeaders:
+		var headers []*Header
+		if err := rlp.DecodeBytes(r.Data, &headers); err != nil {
+			return invalidRecord(err)
+		}
+		for _, header := range headers {
+			if err := d.writeBlockHeader(ctx, header); err != nil {
+				return err
+			}
+		}
+	case journalBlockHashes:
+		var hashes []common.Hash
+		if err := rlp.DecodeBytes(r.Data, &hashes); err != nil {
+			return invalidRecord(err)
+		}
+		return d.writeEvents(ctx, peer, BlockEventsKind, hashes, BlocksKind, r.Time)
+	case journalBlockBody:
+		var body Body
+		if err := rlp.DecodeBytes(r.Data, &body); err != nil {
+			return invalidRecord(err)
+		}
+		if r.Hash == nil {
+			return invalidRecord(errors.New("missing block hash"))
+		}
+		return d.writeBlockBody(ctx, &body, *r.Hash)
+	case journalTransactions:
+		var txs []*types.Transaction
+		if err := rlp.DecodeBytes(r.Data, &txs); err != nil {
+			return invalidRecord(err)
+		}
+		if d.ShouldWriteTransactions() {
+			if err := d.writeTransactions(ctx, txs); err != nil {
+				return err
+			}
+		}
+		if d.ShouldWriteTransactionEvents() {
+			rate := d.options.Load().TxEventSampleRate
+			hashes := make([]common.Hash, 0, len(txs))
+			for _, tx := range txs {
+				if isSampled(tx.Hash(), 0, rate) {
+					hashes = append(hashes, tx.Hash())
+				}
+			}
+			if len(hashes) > 0 {
+				return d.writeEvents(ctx, peer, TransactionEventsKind, hashes, TransactionsKind, r.Time)
+			}
+		}
+	case journalDropped:
+		return d.writeDroppedTransactions(ctx, r.Dropped)
+	default:
+		return invalidRecord(fmt.Errorf("unknown kind %q", r.Kind))
+	}
+
+	return nil
+}
+
 func (d *Datastore) MaxConcurrentWrites() int {
 	return d.maxConcurrency
 }
 
 func (d *Datastore) ShouldWriteBlocks() bool {
-	return d.shouldWriteBlocks
+	return d.options.Load().ShouldWriteBlocks
 }
 
 func (d *Datastore) ShouldWriteBlockEvents() bool {
-	return d.shouldWriteBlockEvents
+	return d.options.Load().ShouldWriteBlockEvents
 }
 
 func (d *Datastore) ShouldWriteTransactions() bool {
-	return d.shouldWriteTransactions
+	return d.options.Load().ShouldWriteTransactions
 }
 
 func (d *Datastore) ShouldWriteTransactionEvents() bool {
-	return d.shouldWriteTransactionEvents
+	return d.options.Load().ShouldWriteTransactionEvents
+}
+
+func (d *Datastore) ShouldWritePeers() bool {
+	return d.options.Load().ShouldWritePeers
+}
+
+// PendingWrites returns the number of writes in flight.
+func (d *Datastore) PendingWrites() int {
+	return len(d.jobs)
+}
+
+// Flush waits for the writes in flight by taking every job slot, so no write
+// can still be running once they're all taken.
+func (d *Datastore) Flush(ctx context.Context) error {
+	for i := 0; i < cap(d.jobs); i++ {
+		select {
+		case d.jobs <- struct{}{}:
+		case <-ctx.Done():
+			for ; i > 0; i-- {
+				<-d.jobs
+			}
+			return ctx.Err()
+		}
+	}
+
+	for i := 0; i < cap(d.jobs); i++ {
+		<-d.jobs
+	}
+	return nil
 }
 
 func (d *Datastore) HasBlock(ctx context.Context, hash common.Hash) bool {
@@ -257,10 +534,10 @@ func (d *Datastore) HasBlock(ctx context.Context, hash common.Hash) bool {
 	return err == nil && block.DatastoreHeader != nil
 }
 
-// newDatastoreHeader creates a DatastoreHeader from a types.Header. Some
-// values are converted into strings to prevent a loss of precision.
-func newDatastoreHeader(header *types.Header) *DatastoreHeader {
-	return &DatastoreHeader{
+// newDatastoreHeader creates a DatastoreHeader from a Header. Some values are
+// converted into strings to prevent a loss of precision.
+func newDatastoreHeader(header *Header) *DatastoreHeader {
+	dsHeader := &DatastoreHeader{
 		ParentHash:  datastore.NameKey(BlocksKind, header.ParentHash.Hex(), nil),
 		UncleHash:   header.UncleHash.Hex(),
 		Coinbase:    header.Coinbase.Hex(),
@@ -278,12 +555,59 @@ func newDatastoreHeader(header *types.Header) *DatastoreHeader {
 		Nonce:       fmt.Sprint(header.Nonce.Uint64()),
 		BaseFee:     header.BaseFee.String(),
 	}
+
+	if header.WithdrawalsHash != nil {
+		dsHeader.WithdrawalsHash = header.WithdrawalsHash.Hex()
+	}
+	if header.BlobGasUsed != nil {
+		dsHeader.BlobGasUsed = fmt.Sprint(*header.BlobGasUsed)
+	}
+	if header.ExcessBlobGas != nil {
+		dsHeader.ExcessBlobGas = fmt.Sprint(*header.ExcessBlobGas)
+	}
+	if header.ParentBeaconRoot != nil {
+		dsHeader.ParentBeaconRoot = header.ParentBeaconRoot.Hex()
+	}
+	if header.RequestsHash != nil {
+		dsHeader.RequestsHash = header.RequestsHash.Hex()
+	}
+
+	return dsHeader
+}
+
+// newDatastoreWithdrawals creates the DatastoreWithdrawals of a block.
+func newDatastoreWithdrawals(withdrawals Withdrawals) []DatastoreWithdrawal {
+	dsWithdrawals := make([]DatastoreWithdrawal, 0, len(withdrawals))
+	for _, w := range withdrawals {
+		dsWithdrawals = append(dsWithdrawals, DatastoreWithdrawal{
+			Index:     fmt.Sprint(w.Index),
+			Validator: fmt.Sprint(w.Validator),
+			Address:   w.Address.Hex(),
+			Amount:    fmt.Sprint(w.Amount),
+		})
+	}
+	return dsWithdrawals
+}
+
+// isSampled returns whether the hash is in the sampled share of the hashes.
+// The sample is taken from the eight bytes of the hash at the offset rather
+// than at random, so every sensor samples the same transactions, and the
+// events of a sampled transaction are written for every peer. Samples taken
+// at different offsets are independent.
+func isSampled(hash common.Hash, offset int, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	return float64(binary.BigEndian.Uint64(hash[offset:offset+8])) < rate*math.MaxUint64
 }
 
 // newDatastoreTransaction creates a DatastoreTransaction from a types.Transaction. Some
-// values are converted into strings to prevent a loss of precision.
-func newDatastoreTransaction(tx *types.Transaction) *DatastoreTransaction {
-	v, r, s := tx.RawSignatureValues()
+// values are converted into strings to prevent a loss of precision. Without
+// the body, the data and signature are left out.
+func newDatastoreTransaction(tx *types.Transaction, body bool) *DatastoreTransaction {
 	var from, to string
 
 	address, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
@@ -295,8 +619,7 @@ func newDatastoreTransaction(tx *types.Transaction) *DatastoreTransaction {
 		to = tx.To().Hex()
 	}
 
-	return &DatastoreTransaction{
-		Data:      tx.Data(),
+	dsTx := &DatastoreTransaction{
 		From:      from,
 		Gas:       fmt.Sprint(tx.Gas()),
 		GasFeeCap: tx.GasFeeCap().String(),
@@ -305,15 +628,23 @@ func newDatastoreTransaction(tx *types.Transaction) *DatastoreTransaction {
 		Nonce:     fmt.Sprint(tx.Nonce()),
 		To:        to,
 		Value:     tx.Value().String(),
-		V:         v.String(),
-		R:         r.String(),
-		S:         s.String(),
 		Time:      time.Now(),
 		Type:      int16(tx.Type()),
+		Size:      int64(tx.Size()),
+	}
+
+	if body {
+		v, r, s := tx.RawSignatureValues()
+		dsTx.Data = tx.Data()
+		dsTx.V = v.String()
+		dsTx.R = r.String()
+		dsTx.S = s.String()
 	}
+
+	return dsTx
 }
 
-func (d *Datastore) writeBlock(ctx context.Context, block *types.Block, td *big.Int) {
+func (d *Datastore) writeBlock(ctx context.Context, block *Block, td *big.Int) error {
 	key := datastore.NameKey(BlocksKind, block.Hash().Hex(), nil)
 
 	_, err := d.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
@@ -326,7 +657,7 @@ func (d *Datastore) writeBlock(ctx context.Context, block *types.Block, td *big.
 
 		if dsBlock.DatastoreHeader == nil {
 			shouldWrite = true
-			dsBlock.DatastoreHeader = newDatastoreHeader(block.Header())
+			dsBlock.DatastoreHeader = newDatastoreHeader(block.Header)
 		}
 
 		if len(dsBlock.TotalDifficulty) == 0 {
@@ -334,28 +665,12 @@ func (d *Datastore) writeBlock(ctx context.Context, block *types.Block, td *big.
 			dsBlock.TotalDifficulty = td.String()
 		}
 
-		if dsBlock.Transactions == nil && len(block.Transactions()) > 0 {
-			shouldWrite = true
-			if d.shouldWriteTransactions {
-				d.writeTransactions(ctx, block.Transactions())
-			}
-
-			dsBlock.Transactions = make([]*datastore.Key, 0, len(block.Transactions()))
-			for _, tx := range block.Transactions() {
-				dsBlock.Transactions = append(dsBlock.Transactions, datastore.NameKey(TransactionsKind, tx.Hash().Hex(), nil))
-			}
-		}
-
-		if dsBlock.Uncles == nil && len(block.Uncles()) > 0 {
-			shouldWrite = true
-			dsBlock.Uncles = make([]*datastore.Key, 0, len(block.Uncles()))
-			for _, uncle := range block.Uncles() {
-				d.writeBlockHeader(ctx, uncle)
-				dsBlock.Uncles = append(dsBlock.Uncles, datastore.NameKey(BlocksKind, uncle.Hash().Hex(), nil))
-			}
+		changed, err := d.setBlockBody(ctx, &dsBlock, block.Body())
+		if err != nil {
+			return err
 		}
 
-		if shouldWrite {
+		if shouldWrite || changed {
 			_, err := tx.Put(key, &dsBlock)
 			return err
 		}
@@ -366,30 +681,32 @@ func (d *Datastore) writeBlock(ctx context.Context, block *types.Block, td *big.
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to write new block")
 	}
+	return err
 }
 
 // writeEvent writes either a block or transaction event to datastore depending
 // on the provided eventKind and hashKind.
-func (d *Datastore) writeEvent(peer *enode.Node, eventKind string, hash common.Hash, hashKind string) {
+func (d *Datastore) writeEvent(peer *enode.Node, eventKind string, hash common.Hash, hashKind string, t time.Time) error {
 	key := datastore.IncompleteKey(eventKind, nil)
 	event := DatastoreEvent{
 		SensorId: d.sensorID,
 		PeerId:   peer.URLv4(),
 		Hash:     datastore.NameKey(hashKind, hash.Hex(), nil),
-		Time:     time.Now(),
+		Time:     t,
 	}
-	if _, err := d.client.Put(context.Background(), key, &event); err != nil {
+	_, err := d.client.Put(context.Background(), key, &event)
+	if err != nil {
 		log.Error().Err(err).Msgf("Failed to write to %v", eventKind)
 	}
+	return err
 }
 
 // writeEvents writes either block or transaction events to datastore depending
 // on the provided eventKind and hashKind. This is similar to writeEvent but
 // batches the request.
-func (d *Datastore) writeEvents(ctx context.Context, peer *enode.Node, eventKind string, hashes []common.Hash, hashKind string) {
+func (d *Datastore) writeEvents(ctx context.Context, peer *enode.Node, eventKind string, hashes []common.Hash, hashKind string, t time.Time) error {
 	keys := make([]*datastore.Key, 0, len(hashes))
 	events := make([]*DatastoreEvent, 0, len(hashes))
-	now := time.Now()
 
 	for _, hash := range hashes {
 		keys = append(keys, datastore.IncompleteKey(eventKind, nil))
@@ -398,19 +715,21 @@ func (d *Datastore) writeEvents(ctx context.Context, peer *enode.Node, eventKind
 			SensorId: d.sensorID,
 			PeerId:   peer.URLv4(),
 			Hash:     datastore.NameKey(hashKind, hash.Hex(), nil),
-			Time:     now,
+			Time:     t,
 		}
 		events = append(events, &event)
 	}
 
-	if _, err := d.client.PutMulti(ctx, keys, events); err != nil {
+	_, err := d.client.PutMulti(ctx, keys, events)
+	if err != nil {
 		log.Error().Err(err).Msgf("Failed to write to %v", eventKind)
 	}
+	return err
 }
 
 // writeBlockHeader will write the block header to datastore if it doesn't
 // exist.
-func (d *Datastore) writeBlockHeader(ctx context.Context, header *types.Header) {
+func (d *Datastore) writeBlockHeader(ctx context.Context, header *Header) error {
 	key := datastore.NameKey(BlocksKind, header.Hash().Hex(), nil)
 
 	_, err := d.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
@@ -427,9 +746,10 @@ func (d *Datastore) writeBlockHeader(ctx context.Context, header *types.Header)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to write block header")
 	}
+	return err
 }
 
-func (d *Datastore) writeBlockBody(ctx context.Context, body *eth.BlockBody, hash common.Hash) {
+func (d *Datastore) writeBlockBody(ctx context.Context, body *Body, hash common.Hash) error {
 	key := datastore.NameKey(BlocksKind, hash.Hex(), nil)
 
 	_, err := d.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
@@ -438,27 +758,9 @@ func (d *Datastore) writeBlockBody(ctx context.Context, body *eth.BlockBody, has
 			log.Debug().Err(err).Str("hash", hash.Hex()).Msg("Failed to fetch block when writing block body")
 		}
 
-		shouldWrite := false
-
-		if block.Transactions == nil && len(body.Transactions) > 0 {
-			shouldWrite = true
-			if d.shouldWriteTransactions {
-				d.writeTransactions(ctx, body.Transactions)
-			}
-
-			block.Transactions = make([]*datastore.Key, 0, len(body.Transactions))
-			for _, tx := range body.Transactions {
-				block.Transactions = append(block.Transactions, datastore.NameKey(TransactionsKind, tx.Hash().Hex(), nil))
-			}
-		}
-
-		if block.Uncles == nil && len(body.Uncles) > 0 {
-			shouldWrite = true
-			block.Uncles = make([]*datastore.Key, 0, len(body.Uncles))
-			for _, uncle := range body.Uncles {
-				d.writeBlockHeader(ctx, uncle)
-				block.Uncles = append(block.Uncles, datastore.NameKey(BlocksKind, uncle.Hash().Hex(), nil))
-			}
+		shouldWrite, err := d.setBlockBody(ctx, &block, body)
+		if err != nil {
+			return err
 		}
 
 		if shouldWrite {
@@ -472,22 +774,167 @@ func (d *Datastore) writeBlockBody(ctx context.Context, body *eth.BlockBody, has
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to write block body")
 	}
+	return err
+}
+
+// setBlockBody sets the transactions, uncles, and withdrawals of the block
+// that aren't set yet, and writes the transactions and uncles. It returns
+// whether the block changed.
+func (d *Datastore) setBlockBody(ctx context.Context, block *DatastoreBlock, body *Body) (bool, error) {
+	shouldWrite := false
+
+	if block.Transactions == nil && len(body.Transactions) > 0 {
+		txs, hashes, err := body.Transactions.Decode()
+		if err != nil {
+			return false, err
+		}
+
+		shouldWrite = true
+		if d.ShouldWriteTransactions() {
+			d.writeTransactions(ctx, txs)
+		}
+
+		// The transactions that couldn't be decoded are still referenced by
+		// their hashes.
+		block.Transactions = make([]*datastore.Key, 0, len(hashes))
+		for _, hash := range hashes {
+			block.Transactions = append(block.Transactions, datastore.NameKey(TransactionsKind, hash.Hex(), nil))
+		}
+	}
+
+	if block.Uncles == nil && len(body.Uncles) > 0 {
+		shouldWrite = true
+		block.Uncles = make([]*datastore.Key, 0, len(body.Uncles))
+		for _, uncle := range body.Uncles {
+			d.writeBlockHeader(ctx, uncle)
+			block.Uncles = append(block.Uncles, datastore.NameKey(BlocksKind, uncle.Hash().Hex(), nil))
+		}
+	}
+
+	if block.Withdrawals == nil && len(body.Withdrawals) > 0 {
+		shouldWrite = true
+		block.Withdrawals = newDatastoreWithdrawals(body.Withdrawals)
+	}
+
+	return shouldWrite, nil
 }
 
-// writeTransactions will write the transactions to datastore and return the
-// transaction hashes.
-func (d *Datastore) writeTransactions(ctx context.Context, txs []*types.Transaction) {
+// writeTransactions will write the sampled transactions to datastore. This
+// applies to the transactions of blocks too, so the blocks can reference
+// transactions that weren't written.
+func (d *Datastore) writeTransactions(ctx context.Context, txs []*types.Transaction) error {
 	keys := make([]*datastore.Key, 0, len(txs))
 	transactions := make([]*DatastoreTransaction, 0, len(txs))
+	opts := d.options.Load()
 
 	for _, tx := range txs {
-		keys = append(keys, datastore.NameKey(TransactionsKind, tx.Hash().Hex(), nil))
-		transactions = append(transactions, newDatastoreTransaction(tx))
+		hash := tx.Hash()
+		if !isSampled(hash, 0, opts.TxSampleRate) {
+			continue
+		}
+		keys = append(keys, datastore.NameKey(TransactionsKind, hash.Hex(), nil))
+		transactions = append(transactions, newDatastoreTransaction(tx, isSampled(hash, 8, opts.TxBodySampleRate)))
+	}
+
+	if len(keys) == 0 {
+		return nil
 	}
 
-	if _, err := d.client.PutMulti(ctx, keys, transactions); err != nil {
+	_, err := d.client.PutMulti(ctx, keys, transactions)
+	if err != nil {
 		log.Error().Err(err).Msg("Failed to write transactions")
 	}
+	return err
+}
+
+func (d *Datastore) writePeer(ctx context.Context, peer *p2p.Peer, location *geoip.Location) {
+	caps := make([]string, 0, len(peer.Caps()))
+	for _, c := range peer.Caps() {
+		caps = append(caps, c.String())
+	}
+
+	dsPeer := DatastorePeer{
+		Name:     peer.Fullname(),
+		Caps:     caps,
+		URL:      peer.Node().URLv4(),
+		SensorId: d.sensorID,
+		LastSeen: time.Now(),
+	}
+
+	if location != nil {
+		dsPeer.Country = location.Country
+		dsPeer.City = location.City
+		dsPeer.Latitude = location.Latitude
+		dsPeer.Longitude = location.Longitude
+		dsPeer.ASN = int64(location.ASN)
+		dsPeer.Organization = location.Organization
+	}
+
+	key := datastore.NameKey(PeersKind, peer.ID().String(), nil)
+	if _, err := d.client.Put(ctx, key, &dsPeer); err != nil {
+		log.Error().Err(err).Msg("Failed to write peer")
+	}
+}
+
+func (d *Datastore) writeDroppedTransactions(ctx context.Context, txs []DroppedTransaction) error {
+	keys := make([]*datastore.Key, 0, len(txs))
+	dropped := make([]*DatastoreDroppedTransaction, 0, len(txs))
+	now := time.Now()
+
+	for _, tx := range txs {
+		keys = append(keys, datastore.IncompleteKey(DroppedTransactionsKind, nil))
+		dropped = append(dropped, &DatastoreDroppedTransaction{
+			SensorId:  d.sensorID,
+			Hash:      datastore.NameKey(TransactionsKind, tx.Hash.Hex(), nil),
+			FirstSeen: tx.FirstSeen,
+			Peers:     int64(tx.Peers),
+			Time:      now,
+		})
+	}
+
+	_, err := d.client.PutMulti(ctx, keys, dropped)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to write dropped transactions")
+	}
+	return err
+}
+
+func (d *Datastore) writePeerBandwidth(ctx context.Context, usages []PeerBandwidth) {
+	keys := make([]*datastore.Key, 0, len(usages))
+	entities := make([]*DatastorePeerBandwidth, 0, len(usages))
+	now := time.Now()
+
+	for _, usage := range usages {
+		messages := make([]DatastoreMessageBandwidth, 0, len(usage.Messages))
+		for _, m := range usage.Messages {
+			messages = append(messages, DatastoreMessageBandwidth{
+				Message:     m.Message,
+				BytesIn:     int64(m.BytesIn),
+				BytesOut:    int64(m.BytesOut),
+				MessagesIn:  int64(m.MessagesIn),
+				MessagesOut: int64(m.MessagesOut),
+			})
+		}
+
+		// Each connection of each sensor gets its own entity, so reconnecting
+		// doesn't overwrite the usage of the previous connection.
+		name := fmt.Sprintf("%s-%s-%d", d.sensorID, usage.Node.ID(), usage.Connected.Unix())
+		keys = append(keys, datastore.NameKey(PeerBandwidthKind, name, nil))
+		entities = append(entities, &DatastorePeerBandwidth{
+			SensorId:  d.sensorID,
+			URL:       usage.Node.URLv4(),
+			Name:      usage.Name,
+			Connected: usage.Connected,
+			LastSeen:  now,
+			BytesIn:   int64(usage.BytesIn),
+			BytesOut:  int64(usage.BytesOut),
+			Messages:  messages,
+		})
+	}
+
+	if _, err := d.client.PutMulti(ctx, keys, entities); err != nil {
+		log.Error().Err(err).Msg("Failed to write peer bandwidth")
+	}
 }
 
 func (d *Datastore) NodeList(ctx context.Context, limit int) ([]string, error) {
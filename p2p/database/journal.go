@@ -0,0 +1,485 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	journalExt = ".journal"
+
+	// journalSyncInterval is how often the current segment is synced to disk,
+	// which bounds what a crash of the machine can lose.
+	journalSyncInterval = time.Second
+
+	// journalMaxBackoff is the longest wait between the retries of a record
+	// while the database is unavailable.
+	journalMaxBackoff = time.Minute
+)
+
+// The kinds of journal records, one per write method of the Database.
+const (
+	journalBlock        = "block"
+	journalBlockHeaders = "block_headers"
+	journalBlockHashes  = "block_hashes"
+	journalBlockBody    = "block_body"
+	journalTransactions = "transactions"
+	journalDropped      = "dropped_transactions"
+)
+
+// journalRecord is a write waiting to be replayed into the database. The
+// blocks, headers, bodies, and transactions are RLP encoded in Data.
+type journalRecord struct {
+	Kind    string               `json:"kind"`
+	Peer    string               `json:"peer,omitempty"`
+	Time    time.Time            `json:"time"`
+	Hash    *common.Hash         `json:"hash,omitempty"`
+	TD      *big.Int             `json:"td,omitempty"`
+	Data    []byte               `json:"data,omitempty"`
+	Dropped []DroppedTransaction `json:"dropped,omitempty"`
+}
+
+// errInvalidRecord is returned when replaying a record that can't be written
+// no matter how often it's retried.
+var errInvalidRecord = errors.New("invalid journal record")
+
+func invalidRecord(err error) error {
+	return fmt.Errorf("%w: %v", errInvalidRecord, err)
+}
+
+// replayer is implemented by the databases a journal can replay into. Unlike
+// the write methods of the Database, replay writes synchronously and returns
+// whether the record was written, so it can be retried.
+type replayer interface {
+	Database
+	replay(context.Context, *journalRecord) error
+}
+
+// JournalOptions is used when creating a NewJournal.
+type JournalOptions struct {
+	// Dir is the directory the journal segments are written to.
+	Dir string
+
+	// SegmentDuration is how long writes are appended to a segment before a
+	// new one is started and the segment is replayed into the database.
+	SegmentDuration time.Duration
+}
+
+// Journal is a write-behind cache in front of a database. The writes are
+// appended to a local journal first, which is replayed into the database in
+// segments, and a segment is only deleted once all of its writes succeeded.
+// Writes are retried while the database is unavailable, and the segments
+// left by a crash are replayed on the next start, so observations aren't lost.
+// A segment interrupted while replaying is replayed again from its start, so
+// some events can be written twice. The peers and their bandwidth aren't
+// journaled since they're rewritten periodically anyway.
+type Journal struct {
+	Database
+	db   replayer
+	opts JournalOptions
+
+	mu     sync.Mutex
+	seq    uint64
+	file   *os.File
+	writer *bufio.Writer
+	closed bool
+
+	// backlog is the number of segments waiting to be replayed.
+	backlog atomic.Int64
+}
+
+// NewJournal opens the journal in front of the database and starts replaying
+// it, including the segments left by a previous run. The journal is replayed
+// until ctx is done.
+func NewJournal(ctx context.Context, db Database, opts JournalOptions) (*Journal, error) {
+	r, ok := db.(replayer)
+	if !ok {
+		return nil, errors.New("the database doesn't support journaling")
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	segments, err := journalSegments(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		log.Info().Int("segments", len(segments)).Msg("Replaying the journal left by the previous run")
+	}
+
+	j := &Journal{Database: db, db: r, opts: opts}
+	if len(segments) > 0 {
+		j.seq = segments[len(segments)-1]
+	}
+	if err = j.rotate(); err != nil {
+		return nil, err
+	}
+
+	go j.run(ctx)
+	go j.replayLoop(ctx)
+
+	return j, nil
+}
+
+// Close syncs and closes the current segment, which is replayed on the next
+// start.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.closed = true
+	return j.closeSegment()
+}
+
+// Flush syncs the current segment and waits for the writes in flight to the
+// database, e.g. the peers which aren't journaled. The journaled writes that
+// weren't replayed yet are left for the next start.
+func (j *Journal) Flush(ctx context.Context) error {
+	j.mu.Lock()
+	if !j.closed {
+		if err := j.sync(); err != nil {
+			j.mu.Unlock()
+			return err
+		}
+	}
+	j.mu.Unlock()
+
+	return j.Database.Flush(ctx)
+}
+
+// Backlog returns the number of segments waiting to be replayed, as of the
+// last time the journal was checked.
+func (j *Journal) Backlog() int {
+	return int(j.backlog.Load())
+}
+
+func (j *Journal) WriteBlock(ctx context.Context, peer *enode.Node, block *Block, td *big.Int) {
+	if !j.ShouldWriteBlocks() && !j.ShouldWriteBlockEvents() {
+		return
+	}
+	j.append(journalBlock, peer, nil, td, block, nil)
+}
+
+func (j *Journal) WriteBlockHeaders(ctx context.Context, headers []*Header) {
+	if !j.ShouldWriteBlocks() || len(headers) == 0 {
+		return
+	}
+	j.append(journalBlockHeaders, nil, nil, nil, headers, nil)
+}
+
+func (j *Journal) WriteBlockHashes(ctx context.Context, peer *enode.Node, hashes []common.Hash) {
+	if !j.ShouldWriteBlockEvents() || len(hashes) == 0 {
+		return
+	}
+	j.append(journalBlockHashes, peer, nil, nil, hashes, nil)
+}
+
+func (j *Journal) WriteBlockBody(ctx context.Context, body *Body, hash common.Hash) {
+	if !j.ShouldWriteBlocks() {
+		return
+	}
+	j.append(journalBlockBody, nil, &hash, nil, body, nil)
+}
+
+func (j *Journal) WriteTransactions(ctx context.Context, peer *enode.Node, txs []*types.Transaction) {
+	if (!j.ShouldWriteTransactions() && !j.ShouldWriteTransactionEvents()) || len(txs) == 0 {
+		return
+	}
+	j.append(journalTransactions, peer, nil, nil, txs, nil)
+}
+
+func (j *Journal) WriteDroppedTransactions(ctx context.Context, txs []DroppedTransaction) {
+	if !j.ShouldWriteTransactionEvents() || len(txs) == 0 {
+		return
+	}
+	j.append(journalDropped, nil, nil, nil, nil, txs)
+}
+
+// append writes the record to the current segment. The value is RLP encoded
+// into the data of the record if it's not nil.
+func (j *Journal) append(kind string, peer *enode.Node, hash *common.Hash, td *big.Int, value interface{}, dropped []DroppedTransaction) {
+	record := journalRecord{Kind: kind, Time: time.Now(), Hash: hash, TD: td, Dropped: dropped}
+	if peer != nil {
+		record.Peer = peer.URLv4()
+	}
+
+	if value != nil {
+		data, err := rlp.EncodeToBytes(value)
+		if err != nil {
+			log.Error().Err(err).Str("kind", kind).Msg("Failed to encode journal record")
+			return
+		}
+		record.Data = data
+	}
+
+	line, err := json.Marshal(&record)
+	if err != nil {
+		log.Error().Err(err).Str("kind", kind).Msg("Failed to encode journal record")
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return
+	}
+
+	if _, err = j.writer.Write(append(line, '\n')); err != nil {
+		log.Error().Err(err).Str("kind", kind).Msg("Failed to write journal record")
+	}
+}
+
+// run syncs the current segment every second and starts a new one every
+// segment duration.
+func (j *Journal) run(ctx context.Context) {
+	syncTicker := time.NewTicker(journalSyncInterval)
+	defer syncTicker.Stop()
+	rotateTicker := time.NewTicker(j.opts.SegmentDuration)
+	defer rotateTicker.Stop()
+
+	for {
+		select {
+		case <-syncTicker.C:
+			j.mu.Lock()
+			if !j.closed {
+				if err := j.sync(); err != nil {
+					log.Error().Err(err).Msg("Failed to sync the journal")
+				}
+			}
+			j.mu.Unlock()
+		case <-rotateTicker.C:
+			j.mu.Lock()
+			if !j.closed {
+				if err := j.rotate(); err != nil {
+					log.Error().Err(err).Msg("Failed to start a new journal segment")
+				}
+			}
+			j.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rotate closes the current segment and opens the next one. The caller must
+// hold the lock, except when the journal is created.
+func (j *Journal) rotate() error {
+	if err := j.closeSegment(); err != nil {
+		return err
+	}
+
+	j.seq++
+	file, err := os.OpenFile(j.segmentPath(j.seq), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	j.file = file
+	j.writer = bufio.NewWriter(file)
+	return nil
+}
+
+func (j *Journal) closeSegment() error {
+	if j.file == nil {
+		return nil
+	}
+
+	if err := j.sync(); err != nil {
+		return err
+	}
+
+	err := j.file.Close()
+	j.file = nil
+	j.writer = nil
+	return err
+}
+
+func (j *Journal) sync() error {
+	if err := j.writer.Flush(); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// replayLoop replays the segments that are no longer written to, oldest
+// first, until ctx is done.
+func (j *Journal) replayLoop(ctx context.Context) {
+	ticker := time.NewTicker(j.opts.SegmentDuration)
+	defer ticker.Stop()
+
+	for {
+		segments, err := journalSegments(j.opts.Dir)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list the journal segments")
+		}
+
+		j.mu.Lock()
+		current := j.seq
+		j.mu.Unlock()
+
+		waiting := 0
+		for _, seq := range segments {
+			if seq < current {
+				waiting++
+			}
+		}
+		j.backlog.Store(int64(waiting))
+
+		for _, seq := range segments {
+			if seq >= current {
+				break
+			}
+			if err = j.replaySegment(ctx, seq); err != nil {
+				log.Error().Err(err).Uint64("segment", seq).Msg("Failed to replay journal segment")
+				break
+			}
+			j.backlog.Add(-1)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// replaySegment writes every record of the segment to the database and
+// deletes it once they're all written.
+func (j *Journal) replaySegment(ctx context.Context, seq uint64) error {
+	path := j.segmentPath(seq)
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	concurrency := j.db.MaxConcurrentWrites()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobs := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	reader := bufio.NewReader(file)
+	records := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A crash can leave the last record partially written, which is
+			// dropped since it was never acknowledged by a sync.
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		record := new(journalRecord)
+		if err = json.Unmarshal(line, record); err != nil {
+			log.Warn().Err(err).Uint64("segment", seq).Msg("Skipping corrupted journal record")
+			continue
+		}
+
+		select {
+		case jobs <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		records++
+		go func() {
+			defer wg.Done()
+			j.replayRecord(ctx, record)
+			<-jobs
+		}()
+	}
+
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	log.Debug().Uint64("segment", seq).Int("records", records).Msg("Replayed journal segment")
+	return os.Remove(path)
+}
+
+// replayRecord writes the record to the database, retrying with an increasing
+// backoff until it succeeds or ctx is done. Invalid records are dropped.
+func (j *Journal) replayRecord(ctx context.Context, record *journalRecord) {
+	backoff := time.Second
+	for {
+		err := j.db.replay(ctx, record)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errInvalidRecord) {
+			log.Warn().Err(err).Str("kind", record.Kind).Msg("Dropping journal record")
+			return
+		}
+
+		log.Warn().Err(err).Str("kind", record.Kind).Dur("backoff", backoff).Msg("Retrying journal record")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		if backoff *= 2; backoff > journalMaxBackoff {
+			backoff = journalMaxBackoff
+		}
+	}
+}
+
+func (j *Journal) segmentPath(seq uint64) string {
+	return filepath.Join(j.opts.Dir, fmt.Sprintf("%020d%s", seq, journalExt))
+}
+
+// journalSegments returns the sequence numbers of the segments in the
+// directory, oldest first.
+func journalSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, journalExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, journalExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, seq)
+	}
+
+	sort.Slice(segments, func(i, k int) bool { return segments[i] < segments[k] })
+	return segments, nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/maticnetwork/polygon-cli/p2p/geoip"
+)
+
+// nodb is a Database that discards all writes. This is useful when the
+// message handlers need to run without persisting anything, such as when
+// replaying captured messages.
+type nodb struct{}
+
+// NewNoopDatabase creates a Database that discards all writes.
+func NewNoopDatabase() Database {
+	return nodb{}
+}
+
+func (nodb) WriteBlock(context.Context, *enode.Node, *Block, *big.Int)            {}
+func (nodb) WriteBlockHeaders(context.Context, []*Header)                         {}
+func (nodb) WriteBlockHashes(context.Context, *enode.Node, []common.Hash)         {}
+func (nodb) WriteBlockBody(context.Context, *Body, common.Hash)                   {}
+func (nodb) WriteTransactions(context.Context, *enode.Node, []*types.Transaction) {}
+func (nodb) WriteDroppedTransactions(context.Context, []DroppedTransaction)       {}
+func (nodb) WritePeerBandwidth(context.Context, []PeerBandwidth)                  {}
+func (nodb) WritePeer(context.Context, *p2p.Peer, *geoip.Location)                {}
+func (nodb) HasBlock(context.Context, common.Hash) bool                           { return true }
+func (nodb) Flush(context.Context) error                                          { return nil }
+func (nodb) MaxConcurrentWrites() int                                             { return 0 }
+func (nodb) ShouldWriteBlocks() bool                                              { return false }
+func (nodb) ShouldWriteBlockEvents() bool                                         { return false }
+func (nodb) ShouldWriteTransactions() bool                                        { return false }
+func (nodb) ShouldWriteTransactionEvents() bool                                   { return false }
+func (nodb) ShouldWritePeers() bool                                               { return false }
+func (nodb) NodeList(context.Context, int) ([]string, error)                      { return nil, nil }
@@ -0,0 +1,140 @@
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// DNSTreeInfoFile holds the sequence number, signature, and enrtree URL
+	// of the last tree written to a directory.
+	DNSTreeInfoFile = "enrtree-info.json"
+
+	// DNSTreeRecordsFile maps the record names to the TXT record contents,
+	// the same as the geth `devp2p dns to-txt` output.
+	DNSTreeRecordsFile = "txtrecords.json"
+
+	// DNSTreeZoneFile is a BIND zone file of the TXT records, which can be
+	// imported by most DNS providers (e.g. `gcloud dns record-sets import`).
+	DNSTreeZoneFile = "zone.txt"
+
+	// dnsTreeTTL is the TTL of the TXT records in the zone file.
+	dnsTreeTTL = 300
+)
+
+// DNSTreeInfo is the content of the DNSTreeInfoFile.
+type DNSTreeInfo struct {
+	Seq       uint   `json:"seq"`
+	Signature string `json:"signature"`
+	URL       string `json:"url"`
+}
+
+// WriteDNSTree builds an EIP-1459 DNS discovery tree of the nodes, signs it
+// with the key, and writes it to dir. The sequence number is incremented from
+// the tree previously written to dir so clients pick up the update. Only nodes
+// with a signed record can be put in the tree, the rest are skipped.
+func WriteDNSTree(dir, domain string, key *ecdsa.PrivateKey, nodes NodeSet, links []string) (*DNSTreeInfo, error) {
+	records := make([]*enode.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if len(node.Record().Signature()) > 0 {
+			records = append(records, node)
+		}
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no signed node records to put in the tree")
+	}
+
+	var seq uint = 1
+	if prev, err := readDNSTreeInfo(dir); err == nil {
+		seq = prev.Seq + 1
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	tree, err := dnsdisc.MakeTree(seq, records, links)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build the tree: %w", err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign the tree: %w", err)
+	}
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	txt := tree.ToTXT(domain)
+	if err = writeJSON(filepath.Join(dir, DNSTreeRecordsFile), txt); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, DNSTreeZoneFile), []byte(zoneFile(domain, txt)), 0644); err != nil {
+		return nil, err
+	}
+	info := &DNSTreeInfo{Seq: tree.Seq(), Signature: tree.Signature(), URL: url}
+	if err = writeJSON(filepath.Join(dir, DNSTreeInfoFile), info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func readDNSTreeInfo(dir string) (*DNSTreeInfo, error) {
+	data, err := os.ReadFile(filepath.Join(dir, DNSTreeInfoFile))
+	if err != nil {
+		return nil, err
+	}
+	var info DNSTreeInfo
+	if err = json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", DNSTreeInfoFile, err)
+	}
+	return &info, nil
+}
+
+// zoneFile formats the TXT records as a BIND zone file. The records are sorted
+// with the root first so the output is deterministic.
+func zoneFile(domain string, txt map[string]string) string {
+	names := make([]string, 0, len(txt))
+	for name := range txt {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == domain || names[j] == domain {
+			return names[i] == domain
+		}
+		return names[i] < names[j]
+	})
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s.\t%d\tIN\tTXT\t%s\n", name, dnsTreeTTL, quoteTXT(txt[name]))
+	}
+	return b.String()
+}
+
+// quoteTXT quotes the TXT content, splitting it into strings of at most 255
+// bytes as required by the DNS wire format.
+func quoteTXT(content string) string {
+	parts := make([]string, 0, len(content)/255+1)
+	for len(content) > 255 {
+		parts = append(parts, `"`+content[:255]+`"`)
+		content = content[255:]
+	}
+	parts = append(parts, `"`+content+`"`)
+	return strings.Join(parts, " ")
+}
+
+func writeJSON(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", jsonIndent)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
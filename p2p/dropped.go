@@ -0,0 +1,230 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+	"github.com/maticnetwork/polygon-cli/util"
+)
+
+// maxPendingTxs is the number of transactions the dropped transaction tracker
+// waits on at once, so memory stays bounded when the chain stops including
+// transactions.
+const maxPendingTxs = 1 << 20
+
+// maxReceiptBatch is the number of receipts requested per batch from the
+// reference RPC, which is below the batch limit of most public endpoints.
+const maxReceiptBatch = 100
+
+// DroppedTxOptions configures when an observed transaction is flagged as
+// dropped.
+type DroppedTxOptions struct {
+	// Timeout is how long after it was first observed a transaction has to be
+	// included before it's flagged as dropped.
+	Timeout time.Duration
+
+	// MinPeers is the number of peers that have to announce a transaction for
+	// it to be flagged. Transactions announced by fewer peers are forgotten
+	// without being flagged, since they weren't gossiped widely.
+	MinPeers int
+
+	// RPC is a reference endpoint asked for the receipts of the transactions
+	// before they're flagged, which catches the ones included in blocks the
+	// sensor didn't observe. It can be empty, in which case only the observed
+	// blocks are used.
+	RPC string
+}
+
+// DroppedTxs correlates the transactions announced by the peers with the
+// transactions of the observed blocks, and flags the ones that were gossiped
+// widely but never included.
+type DroppedTxs struct {
+	opts DroppedTxOptions
+
+	mu      sync.Mutex
+	pending map[common.Hash]*pendingTx
+
+	// included is split in two generations like the seen set of the rotation,
+	// so the transactions announced again after their block aren't tracked.
+	included     map[common.Hash]struct{}
+	prevIncluded map[common.Hash]struct{}
+}
+
+type pendingTx struct {
+	firstSeen time.Time
+	peers     map[enode.ID]struct{}
+}
+
+// NewDroppedTxs creates a new dropped transaction tracker.
+func NewDroppedTxs(opts DroppedTxOptions) *DroppedTxs {
+	return &DroppedTxs{
+		opts:         opts,
+		pending:      make(map[common.Hash]*pendingTx),
+		included:     make(map[common.Hash]struct{}),
+		prevIncluded: make(map[common.Hash]struct{}),
+	}
+}
+
+// Observe records the transaction hashes announced by the peer. It's a no-op
+// if the tracker is nil, which is the case when the detection is disabled.
+func (d *DroppedTxs) Observe(id enode.ID, hashes ...common.Hash) {
+	if d == nil {
+		return
+	}
+
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, hash := range hashes {
+		if _, ok := d.included[hash]; ok {
+			continue
+		}
+		if _, ok := d.prevIncluded[hash]; ok {
+			continue
+		}
+
+		tx, ok := d.pending[hash]
+		if !ok {
+			if len(d.pending) >= maxPendingTxs {
+				continue
+			}
+			tx = &pendingTx{firstSeen: now, peers: make(map[enode.ID]struct{})}
+			d.pending[hash] = tx
+		}
+		tx.peers[id] = struct{}{}
+	}
+}
+
+// Included stops tracking the transactions of an observed block, and ignores
+// them when they're announced again later.
+func (d *DroppedTxs) Included(hashes ...common.Hash) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, hash := range hashes {
+		delete(d.pending, hash)
+		if len(d.included) >= maxSeenHashes {
+			d.prevIncluded = d.included
+			d.included = make(map[common.Hash]struct{})
+		}
+		d.included[hash] = struct{}{}
+	}
+}
+
+// Sweep returns the transactions observed for longer than the timeout that
+// were announced by enough peers, and stops tracking every expired one. When a
+// reference RPC is set, the transactions it has a receipt for are left out.
+func (d *DroppedTxs) Sweep(ctx context.Context) []database.DroppedTransaction {
+	if d == nil {
+		return nil
+	}
+
+	dropped := d.expired(time.Now())
+	if len(dropped) == 0 || len(d.opts.RPC) == 0 {
+		return dropped
+	}
+
+	included, err := d.includedOnChain(ctx, dropped)
+	if err != nil {
+		// Without the reference, the transactions are flagged from the observed
+		// blocks alone rather than being lost.
+		log.Error().Err(err).Msg("Failed to check the dropped transactions against the RPC")
+		return dropped
+	}
+
+	flagged := dropped[:0]
+	for _, tx := range dropped {
+		if _, ok := included[tx.Hash]; !ok {
+			flagged = append(flagged, tx)
+		}
+	}
+	return flagged
+}
+
+// expired removes the transactions older than the timeout and returns the
+// ones announced by at least MinPeers peers, oldest first.
+func (d *DroppedTxs) expired(now time.Time) []database.DroppedTransaction {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var dropped []database.DroppedTransaction
+	for hash, tx := range d.pending {
+		if now.Sub(tx.firstSeen) < d.opts.Timeout {
+			continue
+		}
+		delete(d.pending, hash)
+
+		if len(tx.peers) < d.opts.MinPeers {
+			continue
+		}
+		dropped = append(dropped, database.DroppedTransaction{
+			Hash:      hash,
+			FirstSeen: tx.firstSeen,
+			Peers:     len(tx.peers),
+		})
+	}
+
+	sort.Slice(dropped, func(i, j int) bool {
+		return dropped[i].FirstSeen.Before(dropped[j].FirstSeen)
+	})
+
+	return dropped
+}
+
+// includedOnChain returns the hashes of the transactions the reference RPC has
+// a receipt for.
+func (d *DroppedTxs) includedOnChain(ctx context.Context, txs []database.DroppedTransaction) (map[common.Hash]struct{}, error) {
+	client, err := util.DialRPC(ctx, d.opts.RPC)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	included := make(map[common.Hash]struct{})
+	for start := 0; start < len(txs); start += maxReceiptBatch {
+		end := start + maxReceiptBatch
+		if end > len(txs) {
+			end = len(txs)
+		}
+
+		receipts := make([]json.RawMessage, end-start)
+		batch := make([]rpc.BatchElem, end-start)
+		for i, tx := range txs[start:end] {
+			batch[i] = rpc.BatchElem{
+				Method: "eth_getTransactionReceipt",
+				Args:   []interface{}{tx.Hash},
+				Result: &receipts[i],
+			}
+		}
+
+		if err = client.BatchCallContext(ctx, batch); err != nil {
+			return nil, err
+		}
+
+		for i, elem := range batch {
+			if elem.Error != nil {
+				return nil, elem.Error
+			}
+			if len(receipts[i]) > 0 && string(receipts[i]) != "null" {
+				included[txs[start+i].Hash] = struct{}{}
+			}
+		}
+	}
+
+	return included, nil
+}
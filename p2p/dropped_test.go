@@ -0,0 +1,46 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestDroppedTxsExpired(t *testing.T) {
+	d := NewDroppedTxs(DroppedTxOptions{Timeout: time.Minute, MinPeers: 2})
+
+	widely := common.HexToHash("0x01")
+	included := common.HexToHash("0x02")
+	rarely := common.HexToHash("0x03")
+	late := common.HexToHash("0x04")
+
+	d.Observe(enode.ID{1}, widely, included, rarely)
+	d.Observe(enode.ID{2}, widely, included)
+	d.Included(included)
+	d.Observe(enode.ID{3}, included)
+
+	if dropped := d.expired(time.Now()); len(dropped) != 0 {
+		t.Fatalf("expected no dropped transactions before the timeout, got %d", len(dropped))
+	}
+
+	d.Observe(enode.ID{1}, late)
+	d.Observe(enode.ID{2}, late)
+	d.pending[late].firstSeen = time.Now().Add(30 * time.Second)
+
+	dropped := d.expired(time.Now().Add(time.Minute))
+	if len(dropped) != 1 || dropped[0].Hash != widely || dropped[0].Peers != 2 {
+		t.Fatalf("expected only %v to be dropped, got %+v", widely, dropped)
+	}
+
+	if _, ok := d.pending[rarely]; ok {
+		t.Errorf("expected %v to be forgotten after the timeout", rarely)
+	}
+	if _, ok := d.pending[included]; ok {
+		t.Errorf("expected %v to be ignored after its block", included)
+	}
+	if _, ok := d.pending[late]; !ok {
+		t.Errorf("expected %v to still be pending", late)
+	}
+}
@@ -0,0 +1,83 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+)
+
+// Forks are the forks activated by timestamp since the merge. The chain config
+// of go-ethereum v1.10.26 only has the block based forks, so these are read
+// from the config of the genesis separately and added to the fork ID after
+// them, the way EIP-6122 specifies.
+type Forks struct {
+	ShanghaiTime *uint64 `json:"shanghaiTime,omitempty"`
+	CancunTime   *uint64 `json:"cancunTime,omitempty"`
+	PragueTime   *uint64 `json:"pragueTime,omitempty"`
+	OsakaTime    *uint64 `json:"osakaTime,omitempty"`
+	BPO1Time     *uint64 `json:"bpo1Time,omitempty"`
+	BPO2Time     *uint64 `json:"bpo2Time,omitempty"`
+	BPO3Time     *uint64 `json:"bpo3Time,omitempty"`
+	BPO4Time     *uint64 `json:"bpo4Time,omitempty"`
+	BPO5Time     *uint64 `json:"bpo5Time,omitempty"`
+}
+
+// ReadForks reads the time based forks from the config of a genesis file or
+// network profile.
+func ReadForks(genesis []byte) (Forks, error) {
+	var gen struct {
+		Config Forks `json:"config"`
+	}
+	err := json.Unmarshal(genesis, &gen)
+	return gen.Config, err
+}
+
+// times returns the sorted and deduplicated fork timestamps. The forks active
+// at genesis are skipped since they're part of the genesis ruleset, the same
+// way geth does.
+func (f Forks) times(genesisTime uint64) []uint64 {
+	var times []uint64
+	for _, t := range []*uint64{f.ShanghaiTime, f.CancunTime, f.PragueTime, f.OsakaTime, f.BPO1Time, f.BPO2Time, f.BPO3Time, f.BPO4Time, f.BPO5Time} {
+		if t != nil && *t > genesisTime {
+			times = append(times, *t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	deduped := times[:0]
+	for i, t := range times {
+		if i == 0 || t != times[i-1] {
+			deduped = append(deduped, t)
+		}
+	}
+	return deduped
+}
+
+// NewForkID computes the fork ID of the block with the given number and
+// timestamp. The block based forks are handled by forkid.NewID, and the time
+// based forks only once all of them have passed.
+func NewForkID(genesis *core.Genesis, forks Forks, genesisHash common.Hash, number, time uint64) forkid.ID {
+	id := forkid.NewID(genesis.Config, genesisHash, number)
+	if id.Next > 0 {
+		return id
+	}
+
+	hash := binary.BigEndian.Uint32(id.Hash[:])
+	for _, fork := range forks.times(genesis.Timestamp) {
+		if fork > time {
+			id.Next = fork
+			break
+		}
+		var blob [8]byte
+		binary.BigEndian.PutUint64(blob[:], fork)
+		hash = crc32.Update(hash, crc32.IEEETable, blob[:])
+	}
+	binary.BigEndian.PutUint32(id.Hash[:], hash)
+
+	return id
+}
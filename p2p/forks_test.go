@@ -0,0 +1,71 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+)
+
+func TestNewForkIDMainnet(t *testing.T) {
+	network, err := LoadNetwork("ethereum-mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type test struct {
+		name   string
+		number uint64
+		time   uint64
+		id     forkid.ID
+	}
+
+	// The fork IDs of EIP-2124 and EIP-6122 for Ethereum mainnet.
+	tests := []test{
+		{name: "london", number: 12965000, time: 1628166822, id: forkid.ID{Hash: [4]byte{0xb7, 0x15, 0x07, 0x7d}, Next: 13773000}},
+		{name: "gray glacier", number: 15050000, time: 1656586444, id: forkid.ID{Hash: [4]byte{0xf0, 0xaf, 0xd0, 0xe3}, Next: 1681338455}},
+		{name: "shanghai", number: 17034870, time: 1681338455, id: forkid.ID{Hash: [4]byte{0xdc, 0xe9, 0x6c, 0x2d}, Next: 1710338135}},
+		{name: "cancun", number: 19426587, time: 1710338135, id: forkid.ID{Hash: [4]byte{0x9f, 0x3d, 0x22, 0x54}, Next: 1746612311}},
+		{name: "prague", number: 22431084, time: 1746612311, id: forkid.ID{Hash: [4]byte{0xc3, 0x76, 0xcf, 0x8b}, Next: 1764798551}},
+		{name: "osaka", number: 23935694, time: 1764798551, id: forkid.ID{Hash: [4]byte{0x51, 0x67, 0xe2, 0xa6}, Next: 1765290071}},
+		{name: "bpo2", number: 24000000, time: 1767747671, id: forkid.ID{Hash: [4]byte{0x07, 0xc9, 0x46, 0x2e}}},
+	}
+
+	genesis := network.Genesis()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			id := NewForkID(&genesis, network.Forks, *network.GenesisHash, tc.number, tc.time)
+			if id != tc.id {
+				t.Errorf("expected fork ID %x:%d, got %x:%d", tc.id.Hash, tc.id.Next, id.Hash, id.Next)
+			}
+		})
+	}
+}
+
+func TestNewForkIDForksAtGenesis(t *testing.T) {
+	// Shanghai and Cancun are active at genesis, so only Prague is part of
+	// the fork ID, like on most devnets.
+	genesisTime, shanghai, cancun, prague := uint64(1000), uint64(0), uint64(1000), uint64(2000)
+	genesis := &core.Genesis{Config: allForksConfig(big.NewInt(1337)), Timestamp: genesisTime}
+	forks := Forks{ShanghaiTime: &shanghai, CancunTime: &cancun, PragueTime: &prague}
+	genesisHash := common.Hash{1}
+
+	// The fork hash starts from the checksum of the genesis hash, and each
+	// fork after genesis adds its timestamp.
+	var before, after [4]byte
+	binary.BigEndian.PutUint32(before[:], crc32.ChecksumIEEE(genesisHash[:]))
+	var blob [8]byte
+	binary.BigEndian.PutUint64(blob[:], prague)
+	binary.BigEndian.PutUint32(after[:], crc32.Update(crc32.ChecksumIEEE(genesisHash[:]), crc32.IEEETable, blob[:]))
+
+	if id := NewForkID(genesis, forks, genesisHash, 0, genesisTime); id != (forkid.ID{Hash: before, Next: prague}) {
+		t.Errorf("expected fork ID %x:%d at genesis, got %x:%d", before, prague, id.Hash, id.Next)
+	}
+	if id := NewForkID(genesis, forks, genesisHash, 10, prague); id != (forkid.ID{Hash: after}) {
+		t.Errorf("expected fork ID %x:0 after prague, got %x:%d", after, id.Hash, id.Next)
+	}
+}
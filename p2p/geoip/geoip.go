@@ -0,0 +1,135 @@
+// Package geoip enriches peer IP addresses with location and autonomous system
+// data using local MaxMind DB (MMDB) files such as GeoLite2-City and
+// GeoLite2-ASN.
+package geoip
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is the geolocation and network ownership of an IP address. Any of
+// the fields may be empty if the databases don't contain the data.
+type Location struct {
+	Country      string  `json:",omitempty"`
+	City         string  `json:",omitempty"`
+	Latitude     float64 `json:",omitempty"`
+	Longitude    float64 `json:",omitempty"`
+	ASN          uint    `json:",omitempty"`
+	Organization string  `json:",omitempty"`
+}
+
+// cityRecord is the subset of the GeoIP2/GeoLite2 City schema that is used.
+type cityRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// asnRecord is the GeoIP2/GeoLite2 ASN schema.
+type asnRecord struct {
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// Reader looks up IP addresses in the city and ASN databases. Either database
+// is optional.
+type Reader struct {
+	city *maxminddb.Reader
+	asn  *maxminddb.Reader
+}
+
+// Open opens the city and ASN MMDB files. Empty paths are skipped, so only
+// the provided databases will be used for lookups.
+func Open(cityFile, asnFile string) (*Reader, error) {
+	var (
+		r   Reader
+		err error
+	)
+
+	if len(cityFile) > 0 {
+		r.city, err = maxminddb.Open(cityFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open city database: %w", err)
+		}
+	}
+
+	if len(asnFile) > 0 {
+		r.asn, err = maxminddb.Open(asnFile)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to open ASN database: %w", err)
+		}
+	}
+
+	return &r, nil
+}
+
+// Lookup returns the location of the IP address. If the IP address isn't
+// found in any of the databases, nil is returned.
+func (r *Reader) Lookup(ip net.IP) (*Location, error) {
+	if r == nil || ip == nil {
+		return nil, nil
+	}
+
+	var (
+		loc   Location
+		found bool
+	)
+
+	if r.city != nil {
+		var record cityRecord
+		_, ok, err := r.city.LookupNetwork(ip, &record)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			found = true
+			loc.Country = record.Country.ISOCode
+			loc.City = record.City.Names["en"]
+			loc.Latitude = record.Location.Latitude
+			loc.Longitude = record.Location.Longitude
+		}
+	}
+
+	if r.asn != nil {
+		var record asnRecord
+		_, ok, err := r.asn.LookupNetwork(ip, &record)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			found = true
+			loc.ASN = record.AutonomousSystemNumber
+			loc.Organization = record.AutonomousSystemOrganization
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return &loc, nil
+}
+
+// Close closes the underlying databases.
+func (r *Reader) Close() {
+	if r.city != nil {
+		r.city.Close()
+	}
+
+	if r.asn != nil {
+		r.asn.Close()
+	}
+}
@@ -0,0 +1,214 @@
+package p2p
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/util"
+)
+
+//go:embed networks/*.json
+var networkFiles embed.FS
+
+// Network is a network profile bundled in the binary, so the sensor can join
+// a well known network without being given its genesis and bootnodes. Only the
+// chain config of the genesis is bundled since that's all that's needed to
+// compute the fork ID.
+type Network struct {
+	Name        string              `json:"-"`
+	NetworkID   uint64              `json:"networkId"`
+	GenesisHash *common.Hash        `json:"genesisHash,omitempty"`
+	RPC         string              `json:"rpc"`
+	Bootnodes   []string            `json:"bootnodes"`
+	Config      *params.ChainConfig `json:"config"`
+
+	// Forks are the time based forks of the config, which the chain config
+	// of go-ethereum doesn't have.
+	Forks Forks `json:"-"`
+
+	// ForkID is the fork ID reported by eth_config for the networks fetched
+	// from an RPC, which is used instead of computing it from the config.
+	ForkID *forkid.ID `json:"-"`
+}
+
+// NetworkNames returns the names of the bundled networks.
+func NetworkNames() []string {
+	entries, err := networkFiles.ReadDir("networks")
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadNetwork returns the bundled network with the given name.
+func LoadNetwork(name string) (*Network, error) {
+	data, err := networkFiles.ReadFile(path.Join("networks", name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown network %q, expected one of: %s", name, strings.Join(NetworkNames(), ", "))
+	}
+
+	network := Network{Name: name}
+	if err = json.Unmarshal(data, &network); err != nil {
+		return nil, fmt.Errorf("unable to parse network %s: %w", name, err)
+	}
+	if network.Forks, err = ReadForks(data); err != nil {
+		return nil, fmt.Errorf("unable to parse the forks of network %s: %w", name, err)
+	}
+	if network.Config == nil {
+		return nil, fmt.Errorf("network %s is missing the chain config", name)
+	}
+	return &network, nil
+}
+
+// FetchNetwork derives the network from an RPC endpoint. The network ID comes
+// from net_version, falling back to eth_chainId, and the genesis hash from
+// block 0. The fork schedule of a bundled network with the same genesis hash
+// is used if there is one. Otherwise the fork ID is taken from eth_config
+// when the endpoint supports it, and as a last resort every fork is assumed
+// to be active from genesis, which is the case for most devnets.
+func FetchNetwork(ctx context.Context, rpcURL string) (*Network, error) {
+	client, err := util.DialRPC(ctx, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	network := &Network{Name: rpcURL, RPC: rpcURL}
+
+	var version string
+	if err = client.CallContext(ctx, &version, "net_version"); err == nil {
+		network.NetworkID, err = strconv.ParseUint(version, 0, 64)
+	}
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get the network ID, falling back to the chain ID")
+
+		var chainID hexutil.Uint64
+		if err = client.CallContext(ctx, &chainID, "eth_chainId"); err != nil {
+			return nil, fmt.Errorf("unable to get the network ID: %w", err)
+		}
+		network.NetworkID = uint64(chainID)
+	}
+
+	genesisHash, err := getGenesisHash(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	network.GenesisHash = &genesisHash
+
+	for _, name := range NetworkNames() {
+		bundled, err := LoadNetwork(name)
+		if err != nil || bundled.GenesisHash == nil || *bundled.GenesisHash != genesisHash {
+			continue
+		}
+		log.Info().Str("network", name).Msg("Using the fork schedule of the bundled network with the same genesis")
+		network.Config = bundled.Config
+		network.Forks = bundled.Forks
+		network.Bootnodes = bundled.Bootnodes
+		return network, nil
+	}
+
+	network.Config = allForksConfig(new(big.Int).SetUint64(network.NetworkID))
+
+	var config struct {
+		Current *struct {
+			ForkID hexutil.Bytes `json:"forkId"`
+		} `json:"current"`
+		Next *struct {
+			ActivationTime uint64 `json:"activationTime"`
+		} `json:"next"`
+	}
+	if err = client.CallContext(ctx, &config, "eth_config"); err != nil || config.Current == nil || len(config.Current.ForkID) != 4 {
+		log.Warn().Err(err).Msg("The RPC doesn't support eth_config, assuming every fork is active from genesis")
+		return network, nil
+	}
+
+	id := forkid.ID{}
+	copy(id.Hash[:], config.Current.ForkID)
+	if config.Next != nil {
+		id.Next = config.Next.ActivationTime
+	}
+	network.ForkID = &id
+
+	return network, nil
+}
+
+// FetchGenesisHash returns the hash of block 0 of the RPC endpoint. It's used
+// for the bundled networks without a genesis hash.
+func FetchGenesisHash(ctx context.Context, rpcURL string) (common.Hash, error) {
+	client, err := util.DialRPC(ctx, rpcURL)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer client.Close()
+	return getGenesisHash(ctx, client)
+}
+
+func getGenesisHash(ctx context.Context, client *rpc.Client) (common.Hash, error) {
+	var genesis struct {
+		Hash common.Hash `json:"hash"`
+	}
+	if err := client.CallContext(ctx, &genesis, "eth_getBlockByNumber", "0x0", false); err != nil {
+		return common.Hash{}, fmt.Errorf("unable to get the genesis block: %w", err)
+	}
+	return genesis.Hash, nil
+}
+
+// allForksConfig returns a chain config with every block based fork active
+// from genesis.
+func allForksConfig(chainID *big.Int) *params.ChainConfig {
+	zero := big.NewInt(0)
+	return &params.ChainConfig{
+		ChainID:             chainID,
+		HomesteadBlock:      zero,
+		EIP150Block:         zero,
+		EIP155Block:         zero,
+		EIP158Block:         zero,
+		ByzantiumBlock:      zero,
+		ConstantinopleBlock: zero,
+		PetersburgBlock:     zero,
+		IstanbulBlock:       zero,
+		MuirGlacierBlock:    zero,
+		BerlinBlock:         zero,
+		LondonBlock:         zero,
+	}
+}
+
+// Genesis returns the genesis of the network. It only has the chain config.
+func (n *Network) Genesis() core.Genesis {
+	return core.Genesis{Config: n.Config}
+}
+
+// BootstrapNodes parses the bootnodes of the network.
+func (n *Network) BootstrapNodes() ([]*enode.Node, error) {
+	nodes := make([]*enode.Node, 0, len(n.Bootnodes))
+	for _, record := range n.Bootnodes {
+		node, err := ParseNode(record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootstrap node of network %s: %w", n.Name, err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
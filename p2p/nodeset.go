@@ -1,28 +1,78 @@
 package p2p
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"time"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/rs/zerolog/log"
 )
 
 const jsonIndent = "    "
 
-// NodeSet is the mapping of the node ID to the URL. This is used in the p2p
-// ping, crawl, and sensor commands. When written this should be consistent with
-// the geth/bor static-nodes.json file format which is just an JSON string array
-// of URLs.
-type NodeSet map[enode.ID]string
+// NodeSet is the mapping of the node ID to the node. This is used in the p2p
+// ping, crawl, and sensor commands. See NodeSetFormat for the supported file
+// formats when writing it.
+type NodeSet map[enode.ID]*enode.Node
 
-// ReadNodeSet parses a list of discovery node URLs loaded from a JSON file.
+// NodeSetFormat is the file format used when writing a NodeSet.
+type NodeSetFormat string
+
+const (
+	// NodeSetFormatEnode is consistent with the geth/bor static-nodes.json file
+	// format which is just a JSON string array of enode URLs.
+	NodeSetFormatEnode NodeSetFormat = "enode"
+
+	// NodeSetFormatENR is a JSON string array of ENR records. Unlike enode URLs,
+	// these keep the signed key/value pairs of the node record (e.g. fork ID).
+	NodeSetFormatENR NodeSetFormat = "enr"
+
+	// NodeSetFormatGeth is the nodes.json format used by the geth `devp2p`
+	// tool, which maps the node ID to the node record and crawl metadata.
+	NodeSetFormatGeth NodeSetFormat = "geth"
+)
+
+// ParseNodeSetFormat validates the node set format string.
+func ParseNodeSetFormat(format string) (NodeSetFormat, error) {
+	switch f := NodeSetFormat(format); f {
+	case NodeSetFormatEnode, NodeSetFormatENR, NodeSetFormatGeth:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid node set format %q, expected one of: %s, %s, %s",
+			format, NodeSetFormatEnode, NodeSetFormatENR, NodeSetFormatGeth)
+	}
+}
+
+// gethNodeJSON is a single entry of the geth `devp2p` nodes.json file.
+type gethNodeJSON struct {
+	Seq           uint64      `json:"seq"`
+	N             *enode.Node `json:"record"`
+	Score         int         `json:"score,omitempty"`
+	FirstResponse time.Time   `json:"firstResponse,omitempty"`
+	LastResponse  time.Time   `json:"lastResponse,omitempty"`
+	LastCheck     time.Time   `json:"lastCheck,omitempty"`
+}
+
+// ReadNodeSet parses a list of discovery nodes loaded from a JSON file. The
+// file can either be a JSON array of enode URLs or ENRs (e.g. geth/bor
+// static-nodes.json), or a geth `devp2p` nodes.json object.
 func ReadNodeSet(file string) ([]*enode.Node, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node list file: %w", err)
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseGethNodeSet(data)
+	}
+
 	// Load the nodes from the config file.
 	var nodelist []string
-	if err := common.LoadJSON(file, &nodelist); err != nil {
+	if err := json.Unmarshal(data, &nodelist); err != nil {
 		return nil, fmt.Errorf("failed to load node list file: %w", err)
 	}
 
@@ -43,14 +93,59 @@ func ReadNodeSet(file string) ([]*enode.Node, error) {
 	return nodes, nil
 }
 
-// WriteNodeSet writes the node set as a JSON list of URLs to a file.
-func WriteNodeSet(file string, nodes NodeSet) error {
-	urls := make([]string, 0, len(nodes))
-	for _, url := range nodes {
-		urls = append(urls, url)
+// parseGethNodeSet parses the geth `devp2p` nodes.json format.
+func parseGethNodeSet(data []byte) ([]*enode.Node, error) {
+	var set map[enode.ID]gethNodeJSON
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to load node list file: %w", err)
+	}
+
+	nodes := make([]*enode.Node, 0, len(set))
+	for _, n := range set {
+		if n.N == nil {
+			continue
+		}
+		nodes = append(nodes, n.N)
+	}
+
+	// Map iteration order is random so sort the nodes to keep the order stable.
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].ID().Bytes(), nodes[j].ID().Bytes()) < 0
+	})
+
+	return nodes, nil
+}
+
+// WriteNodeSet writes the node set to a file in the given format. The output
+// is sorted so it is deterministic, which makes it easy to diff and merge node
+// sets written by different sensors or crawlers.
+func WriteNodeSet(file string, nodes NodeSet, format NodeSetFormat) error {
+	var v interface{}
+
+	switch format {
+	case NodeSetFormatGeth:
+		set := make(map[enode.ID]gethNodeJSON, len(nodes))
+		for id, node := range nodes {
+			set[id] = gethNodeJSON{Seq: node.Seq(), N: node}
+		}
+		v = set
+	case NodeSetFormatENR:
+		records := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			records = append(records, node.String())
+		}
+		sort.Strings(records)
+		v = records
+	default:
+		urls := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			urls = append(urls, node.URLv4())
+		}
+		sort.Strings(urls)
+		v = urls
 	}
 
-	bytes, err := json.MarshalIndent(urls, "", jsonIndent)
+	bytes, err := json.MarshalIndent(v, "", jsonIndent)
 	if err != nil {
 		return err
 	}
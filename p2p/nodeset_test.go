@@ -0,0 +1,48 @@
+package p2p
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestNodeSetRoundTrip(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var r enr.Record
+	r.Set(enr.IPv4{127, 0, 0, 1})
+	r.Set(enr.TCP(30303))
+	r.Set(enr.UDP(30303))
+	if err = enode.SignV4(&r, key); err != nil {
+		t.Fatalf("Failed to sign record: %v", err)
+	}
+	node, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	formats := []NodeSetFormat{NodeSetFormatEnode, NodeSetFormatENR, NodeSetFormatGeth}
+	for _, format := range formats {
+		t.Run(string(format), func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "nodes.json")
+			if err := WriteNodeSet(file, NodeSet{node.ID(): node}, format); err != nil {
+				t.Fatalf("Failed to write node set: %v", err)
+			}
+
+			nodes, err := ReadNodeSet(file)
+			if err != nil {
+				t.Fatalf("Failed to read node set: %v", err)
+			}
+
+			if len(nodes) != 1 || nodes[0].ID() != node.ID() {
+				t.Fatalf("Expected node %v, got %v", node.ID(), nodes)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package p2p
+
+import (
+	"sync/atomic"
+)
+
+// PeerLimit caps the number of peers the protocol handlers accept, and can be
+// changed while the sensor is running. It can only lower the max peers of the
+// server, which is fixed once the server is started. Lowering the limit
+// doesn't disconnect any peer, it only rejects the new ones until enough peers
+// have left.
+type PeerLimit struct {
+	limit atomic.Int64
+	peers atomic.Int64
+}
+
+// NewPeerLimit creates a limit of limit peers.
+func NewPeerLimit(limit int) *PeerLimit {
+	l := &PeerLimit{}
+	l.limit.Store(int64(limit))
+	return l
+}
+
+// Set changes the limit.
+func (l *PeerLimit) Set(limit int) {
+	l.limit.Store(int64(limit))
+}
+
+// Limit returns the current limit.
+func (l *PeerLimit) Limit() int {
+	return int(l.limit.Load())
+}
+
+// Peers returns the number of peers accepted.
+func (l *PeerLimit) Peers() int {
+	return int(l.peers.Load())
+}
+
+// acquire accepts a peer if the limit isn't reached. A nil limit accepts every
+// peer, which is the case when it's left to the server.
+func (l *PeerLimit) acquire() bool {
+	if l == nil {
+		return true
+	}
+
+	if l.peers.Add(1) > l.limit.Load() {
+		l.peers.Add(-1)
+		return false
+	}
+	return true
+}
+
+// release frees the slot of an accepted peer.
+func (l *PeerLimit) release() {
+	if l != nil {
+		l.peers.Add(-1)
+	}
+}
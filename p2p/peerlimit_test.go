@@ -0,0 +1,34 @@
+package p2p
+
+import "testing"
+
+func TestPeerLimit(t *testing.T) {
+	l := NewPeerLimit(2)
+	if !l.acquire() || !l.acquire() {
+		t.Fatal("expected the peers under the limit to be accepted")
+	}
+	if l.acquire() {
+		t.Fatal("expected the peer over the limit to be rejected")
+	}
+	if l.Peers() != 2 {
+		t.Errorf("expected 2 peers, got %d", l.Peers())
+	}
+
+	// Lowering the limit keeps the accepted peers but rejects new ones until
+	// enough of them left.
+	l.Set(1)
+	l.release()
+	if l.acquire() {
+		t.Fatal("expected the peer to be rejected at the lowered limit")
+	}
+	l.release()
+	if !l.acquire() {
+		t.Fatal("expected the peer to be accepted once the peers left")
+	}
+
+	var nilLimit *PeerLimit
+	if !nilLimit.acquire() {
+		t.Error("expected a nil limit to accept every peer")
+	}
+	nilLimit.release()
+}
@@ -13,13 +13,16 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 
 	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/maticnetwork/polygon-cli/p2p/database"
+	"github.com/maticnetwork/polygon-cli/p2p/geoip"
+	"github.com/maticnetwork/polygon-cli/util"
 )
 
 // conn represents an individual connection with a peer.
@@ -32,6 +35,9 @@ type conn struct {
 	head      *HeadBlock
 	headMutex *sync.RWMutex
 	count     *MessageCount
+	rotation  *Rotation
+	dropped   *DroppedTxs
+	alerts    *Alerts
 
 	// requests is used to store the request ID and the block hash. This is used
 	// when fetching block bodies because the eth protocol block bodies do not
@@ -41,7 +47,7 @@ type conn struct {
 
 	// oldestBlock stores the first block the sensor has seen so when fetching
 	// parent blocks, it does not request blocks older than this.
-	oldestBlock *types.Header
+	oldestBlock *database.Header
 }
 
 // Eth66ProtocolOptions is the options used when creating a new eth66 protocol.
@@ -50,12 +56,49 @@ type Eth66ProtocolOptions struct {
 	Database    database.Database
 	Genesis     *core.Genesis
 	GenesisHash common.Hash
+	Forks       Forks
 	RPC         string
 	SensorID    string
 	NetworkID   uint64
 	Peers       chan *enode.Node
 	Count       *MessageCount
 
+	// Capture writes every raw message received to a capture file. It can be
+	// nil if capturing is disabled.
+	Capture *CaptureWriter
+
+	// GeoIP is used to enrich the peer records with location and ASN data. It
+	// can be nil if enrichment is disabled.
+	GeoIP *geoip.Reader
+
+	// Rotation tracks the novelty of each peer's announcements so the least
+	// useful peers can be rotated out. It can be nil if rotation is disabled.
+	Rotation *Rotation
+
+	// DroppedTxs tracks the announced transactions so the ones that are never
+	// included can be flagged. It can be nil if the detection is disabled.
+	DroppedTxs *DroppedTxs
+
+	// Bandwidth accounts the bytes and messages exchanged with every peer. It
+	// can be nil if the accounting is disabled.
+	Bandwidth *Bandwidth
+
+	// PeerLimit caps the number of peers accepted below the max peers of the
+	// server. It can be nil to only rely on the server's limit.
+	PeerLimit *PeerLimit
+
+	// Alerts evaluates the alert rules against the received blocks and
+	// transactions. It can be nil if alerting is disabled.
+	Alerts *Alerts
+
+	// Shard is the portion of the node ID key space this sensor handles. Peers
+	// outside of the shard are disconnected before the status exchange.
+	Shard Shard
+
+	// StatusOverride replaces fields of the status message sent to the peers.
+	// It can be nil to send the actual status.
+	StatusOverride *StatusOverride
+
 	// Head keeps track of the current head block of the chain. This is required
 	// when doing the status exchange.
 	Head      *HeadBlock
@@ -67,6 +110,7 @@ type HeadBlock struct {
 	Hash            common.Hash
 	TotalDifficulty *big.Int
 	Number          uint64
+	Time            uint64
 }
 
 // NewEth66Proctocol creates the new eth66 protocol. This will handle writing the
@@ -77,6 +121,22 @@ func NewEth66Protocol(opts Eth66ProtocolOptions) ethp2p.Protocol {
 		Version: 66,
 		Length:  17,
 		Run: func(p *ethp2p.Peer, rw ethp2p.MsgReadWriter) error {
+			if !opts.Shard.Contains(p.ID()) {
+				return ethp2p.DiscUselessPeer
+			}
+
+			if !opts.PeerLimit.acquire() {
+				return ethp2p.DiscTooManyPeers
+			}
+			defer opts.PeerLimit.release()
+
+			rw = opts.Bandwidth.Meter(p, rw)
+			defer func() {
+				if usage := opts.Bandwidth.Disconnected(p.ID()); usage != nil {
+					opts.Database.WritePeerBandwidth(opts.Context, []database.PeerBandwidth{*usage})
+				}
+			}()
+
 			c := conn{
 				sensorID:   opts.SensorID,
 				node:       p.Node(),
@@ -88,6 +148,9 @@ func NewEth66Protocol(opts Eth66ProtocolOptions) ethp2p.Protocol {
 				head:       opts.Head,
 				headMutex:  opts.HeadMutex,
 				count:      opts.Count,
+				rotation:   opts.Rotation,
+				dropped:    opts.DroppedTxs,
+				alerts:     opts.Alerts,
 			}
 
 			c.headMutex.RLock()
@@ -95,16 +158,31 @@ func NewEth66Protocol(opts Eth66ProtocolOptions) ethp2p.Protocol {
 				ProtocolVersion: 66,
 				NetworkID:       opts.NetworkID,
 				Genesis:         opts.GenesisHash,
-				ForkID:          forkid.NewID(opts.Genesis.Config, opts.GenesisHash, opts.Head.Number),
+				ForkID:          NewForkID(opts.Genesis, opts.Forks, opts.GenesisHash, opts.Head.Number, opts.Head.Time),
 				Head:            opts.Head.Hash,
 				TD:              opts.Head.TotalDifficulty,
 			}
+			opts.StatusOverride.Apply(&status, opts)
 			err := c.statusExchange(&status)
 			c.headMutex.RUnlock()
 			if err != nil {
+				// How the peers react to the overridden status is what's being
+				// measured, so their rejections are worth more than a debug log.
+				if !opts.StatusOverride.IsEmpty() {
+					c.logger.Info().Err(err).Str("client", p.Fullname()).Interface("status", status).Msg("Peer rejected status")
+				}
 				return err
 			}
 
+			location, err := opts.GeoIP.Lookup(p.Node().IP())
+			if err != nil {
+				c.logger.Warn().Err(err).Msg("Failed to look up peer location")
+			}
+			c.db.WritePeer(opts.Context, p, location)
+
+			opts.Rotation.Connected(p, location)
+			defer opts.Rotation.Disconnected(p.ID())
+
 			// Send the node to the peers channel. This allows the peers to be captured
 			// across all connections and written to the nodes.json file.
 			opts.Peers <- p.Node()
@@ -117,37 +195,16 @@ func NewEth66Protocol(opts Eth66ProtocolOptions) ethp2p.Protocol {
 					return err
 				}
 
-				switch msg.Code {
-				case eth.NewBlockHashesMsg:
-					err = c.handleNewBlockHashes(ctx, msg)
-				case eth.TransactionsMsg:
-					err = c.handleTransactions(ctx, msg)
-				case eth.GetBlockHeadersMsg:
-					err = c.handleGetBlockHeaders(msg)
-				case eth.BlockHeadersMsg:
-					err = c.handleBlockHeaders(ctx, msg)
-				case eth.GetBlockBodiesMsg:
-					err = c.handleGetBlockBodies(msg)
-				case eth.BlockBodiesMsg:
-					err = c.handleBlockBodies(ctx, msg)
-				case eth.NewBlockMsg:
-					err = c.handleNewBlock(ctx, msg)
-				case eth.NewPooledTransactionHashesMsg:
-					err = c.handleNewPooledTransactionHashes(ctx, msg)
-				case eth.GetPooledTransactionsMsg:
-					err = c.handleGetPooledTransactions(msg)
-				case eth.PooledTransactionsMsg:
-					err = c.handlePooledTransactions(ctx, msg)
-				case eth.GetReceiptsMsg:
-					err = c.handleGetReceipts(msg)
-				default:
-					log.Trace().Interface("msg", msg).Send()
+				if opts.Capture != nil {
+					if err = opts.Capture.Write(c.node, &msg); err != nil {
+						c.logger.Error().Err(err).Msg("Failed to capture message")
+					}
 				}
 
 				// All the handler functions are built in a way where returning an error
 				// should drop the connection. If the connection shouldn't be dropped,
 				// then return nil and log the error instead.
-				if err != nil {
+				if err = c.handleMessage(ctx, msg); err != nil {
 					c.logger.Error().Err(err).Send()
 					return err
 				}
@@ -160,6 +217,46 @@ func NewEth66Protocol(opts Eth66ProtocolOptions) ethp2p.Protocol {
 	}
 }
 
+// handleMessage dispatches the message to the handler for its message code.
+// Every message is a trace of its own, since the connections last too long
+// for one.
+func (c *conn) handleMessage(ctx context.Context, msg ethp2p.Msg) (err error) {
+	ctx, span := util.Tracer().Start(ctx, "p2p."+messageName(msg.Code),
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("p2p.peer", c.node.ID().String()), attribute.Int64("p2p.message.size", int64(msg.Size))))
+	defer func() { util.EndSpan(span, err) }()
+
+	switch msg.Code {
+	case eth.NewBlockHashesMsg:
+		return c.handleNewBlockHashes(ctx, msg)
+	case eth.TransactionsMsg:
+		return c.handleTransactions(ctx, msg)
+	case eth.GetBlockHeadersMsg:
+		return c.handleGetBlockHeaders(msg)
+	case eth.BlockHeadersMsg:
+		return c.handleBlockHeaders(ctx, msg)
+	case eth.GetBlockBodiesMsg:
+		return c.handleGetBlockBodies(msg)
+	case eth.BlockBodiesMsg:
+		return c.handleBlockBodies(ctx, msg)
+	case eth.NewBlockMsg:
+		return c.handleNewBlock(ctx, msg)
+	case eth.NewPooledTransactionHashesMsg:
+		return c.handleNewPooledTransactionHashes(ctx, msg)
+	case eth.GetPooledTransactionsMsg:
+		return c.handleGetPooledTransactions(msg)
+	case eth.PooledTransactionsMsg:
+		return c.handlePooledTransactions(ctx, msg)
+	case eth.GetReceiptsMsg:
+		return c.handleGetReceipts(msg)
+	default:
+		log.Trace().Interface("msg", msg).Send()
+	}
+
+	return nil
+}
+
 // statusExchange will exchange status message between the nodes. It will return
 // and error if the nodes are incompatible.
 func (c *conn) statusExchange(packet *eth.StatusPacket) error {
@@ -223,7 +320,7 @@ func (c *conn) getBlockData(hash common.Hash) error {
 
 // getParentBlock will send a request to the peer if the parent of the header
 // does not exist in the database.
-func (c *conn) getParentBlock(ctx context.Context, header *types.Header) error {
+func (c *conn) getParentBlock(ctx context.Context, header *database.Header) error {
 	if !c.db.ShouldWriteBlocks() || !c.db.ShouldWriteBlockEvents() {
 		return nil
 	}
@@ -261,6 +358,7 @@ func (c *conn) handleNewBlockHashes(ctx context.Context, msg ethp2p.Msg) error {
 			return err
 		}
 	}
+	c.rotation.Observe(c.node.ID(), hashes...)
 
 	c.db.WriteBlockHashes(ctx, c.node, hashes)
 
@@ -274,6 +372,8 @@ func (c *conn) handleTransactions(ctx context.Context, msg ethp2p.Msg) error {
 	}
 
 	atomic.AddInt32(&c.count.Transactions, int32(len(txs)))
+	c.observeTransactions(txs)
+	c.alerts.Transactions(ctx, txs)
 
 	c.db.WriteTransactions(ctx, c.node, txs)
 
@@ -296,7 +396,7 @@ func (c *conn) handleGetBlockHeaders(msg ethp2p.Msg) error {
 }
 
 func (c *conn) handleBlockHeaders(ctx context.Context, msg ethp2p.Msg) error {
-	var packet eth.BlockHeadersPacket66
+	var packet BlockHeaders
 	if err := msg.Decode(&packet); err != nil {
 		return err
 	}
@@ -308,6 +408,7 @@ func (c *conn) handleBlockHeaders(ctx context.Context, msg ethp2p.Msg) error {
 		if err := c.getParentBlock(ctx, header); err != nil {
 			return err
 		}
+		c.alerts.Block(ctx, header)
 	}
 
 	c.db.WriteBlockHeaders(ctx, headers)
@@ -331,7 +432,7 @@ func (c *conn) handleGetBlockBodies(msg ethp2p.Msg) error {
 }
 
 func (c *conn) handleBlockBodies(ctx context.Context, msg ethp2p.Msg) error {
-	var packet eth.BlockBodiesPacket66
+	var packet BlockBodies
 	if err := msg.Decode(&packet); err != nil {
 		return err
 	}
@@ -362,36 +463,54 @@ func (c *conn) handleBlockBodies(ctx context.Context, msg ethp2p.Msg) error {
 		return nil
 	}
 
-	c.db.WriteBlockBody(ctx, packet.BlockBodiesPacket[0], *hash)
+	body := packet.BlockBodiesPacket[0]
+	txs, _, err := body.Transactions.Decode()
+	if err != nil {
+		return err
+	}
+	c.includeTransactions(txs)
+
+	c.db.WriteBlockBody(ctx, body, *hash)
 
 	return nil
 }
 
 func (c *conn) handleNewBlock(ctx context.Context, msg ethp2p.Msg) error {
-	var block eth.NewBlockPacket
+	var block NewBlock
 	if err := msg.Decode(&block); err != nil {
 		return err
 	}
 
+	txs, _, err := block.Block.Transactions.Decode()
+	if err != nil {
+		return err
+	}
+	number := block.Block.Header.Number.Uint64()
+
 	atomic.AddInt32(&c.count.Blocks, 1)
+	c.rotation.Observe(c.node.ID(), block.Block.Hash())
 
 	// Set the head block if newer.
 	c.headMutex.Lock()
-	if block.Block.Number().Uint64() > c.head.Number && block.TD.Cmp(c.head.TotalDifficulty) == 1 {
+	if number > c.head.Number && block.TD.Cmp(c.head.TotalDifficulty) == 1 {
 		*c.head = HeadBlock{
 			Hash:            block.Block.Hash(),
 			TotalDifficulty: block.TD,
-			Number:          block.Block.Number().Uint64(),
+			Number:          number,
+			Time:            block.Block.Header.Time,
 		}
 
 		c.logger.Info().Interface("head", c.head).Msg("Setting head block")
 	}
 	c.headMutex.Unlock()
 
-	if err := c.getParentBlock(ctx, block.Block.Header()); err != nil {
+	if err := c.getParentBlock(ctx, block.Block.Header); err != nil {
 		return err
 	}
 
+	c.includeTransactions(txs)
+	c.alerts.Block(ctx, block.Block.Header)
+
 	c.db.WriteBlock(ctx, c.node, block.Block, block.TD)
 
 	return nil
@@ -418,6 +537,8 @@ func (c *conn) handleNewPooledTransactionHashes(ctx context.Context, msg ethp2p.
 	}
 
 	atomic.AddInt32(&c.count.TransactionHashes, int32(len(txs)))
+	c.rotation.Observe(c.node.ID(), txs...)
+	c.dropped.Observe(c.node.ID(), txs...)
 
 	if !c.db.ShouldWriteTransactions() || !c.db.ShouldWriteTransactionEvents() {
 		return nil
@@ -439,12 +560,42 @@ func (c *conn) handlePooledTransactions(ctx context.Context, msg ethp2p.Msg) err
 	}
 
 	atomic.AddInt32(&c.count.Transactions, int32(len(packet.PooledTransactionsPacket)))
+	c.observeTransactions(packet.PooledTransactionsPacket)
+	c.alerts.Transactions(ctx, packet.PooledTransactionsPacket)
 
 	c.db.WriteTransactions(ctx, c.node, packet.PooledTransactionsPacket)
 
 	return nil
 }
 
+// observeTransactions credits the peer with the transactions it was the first
+// to send, and records them as announced by the peer.
+func (c *conn) observeTransactions(txs []*types.Transaction) {
+	if c.rotation == nil && c.dropped == nil {
+		return
+	}
+
+	hashes := make([]common.Hash, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	c.rotation.Observe(c.node.ID(), hashes...)
+	c.dropped.Observe(c.node.ID(), hashes...)
+}
+
+// includeTransactions stops tracking the transactions of an observed block.
+func (c *conn) includeTransactions(txs []*types.Transaction) {
+	if c.dropped == nil {
+		return
+	}
+
+	hashes := make([]common.Hash, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	c.dropped.Included(hashes...)
+}
+
 func (c *conn) handleGetReceipts(msg ethp2p.Msg) error {
 	var request eth.GetReceiptsPacket66
 	if err := msg.Decode(&request); err != nil {
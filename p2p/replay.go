@@ -0,0 +1,98 @@
+package p2p
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/rs/zerolog/log"
+
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+)
+
+// ReplayOptions is the options used when replaying a capture file.
+type ReplayOptions struct {
+	Context  context.Context
+	Database database.Database
+	SensorID string
+	Count    *MessageCount
+}
+
+// discardRW is a MsgReadWriter that drops every message written to it. When
+// replaying, the handlers still send responses and requests to the peer, but
+// there isn't anyone on the other end to receive them.
+type discardRW struct{}
+
+func (discardRW) ReadMsg() (ethp2p.Msg, error) { return ethp2p.Msg{}, io.EOF }
+func (discardRW) WriteMsg(msg ethp2p.Msg) error {
+	return msg.Discard()
+}
+
+// Replay reads every record from the capture file and feeds it through the
+// same message handlers used by the sensor. Each captured peer gets its own
+// connection state, so request tracking behaves as it did when the messages
+// were captured. Handler errors don't stop the replay, they are logged and
+// counted instead. The number of records replayed is returned.
+func Replay(opts ReplayOptions, reader *CaptureReader) (int, error) {
+	conns := make(map[string]*conn)
+	head := HeadBlock{TotalDifficulty: big.NewInt(0)}
+	headMutex := &sync.RWMutex{}
+
+	replayed := 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return replayed, nil
+		}
+		if err != nil {
+			return replayed, err
+		}
+
+		c, ok := conns[record.Peer]
+		if !ok {
+			node, err := enode.ParseV4(record.Peer)
+			if err != nil {
+				return replayed, err
+			}
+
+			c = &conn{
+				sensorID:  opts.SensorID,
+				node:      node,
+				logger:    log.With().Str("peer", record.Peer).Logger(),
+				rw:        discardRW{},
+				db:        opts.Database,
+				requests:  list.New(),
+				head:      &head,
+				headMutex: headMutex,
+				count:     opts.Count,
+			}
+			conns[record.Peer] = c
+		}
+
+		msg := ethp2p.Msg{
+			Code:       record.Code,
+			Size:       uint32(len(record.Payload)),
+			Payload:    bytes.NewReader(record.Payload),
+			ReceivedAt: record.Received(),
+		}
+
+		if err = c.handleMessage(opts.Context, msg); err != nil {
+			atomic.AddInt32(&opts.Count.Errors, 1)
+			c.logger.Error().
+				Err(err).
+				Int("record", replayed).
+				Uint64("code", record.Code).
+				Time("received", record.Received()).
+				Msg("Failed to handle captured message")
+		}
+
+		replayed++
+	}
+}
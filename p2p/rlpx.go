@@ -1,18 +1,21 @@
 package p2p
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
-	"net"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/rlpx"
+	"github.com/maticnetwork/polygon-cli/p2p/database"
+	"github.com/maticnetwork/polygon-cli/util"
 	"github.com/rs/zerolog/log"
 )
 
@@ -23,7 +26,7 @@ var (
 // Dial attempts to Dial the given node and perform a handshake,
 // returning the created Conn if successful.
 func Dial(n *enode.Node) (*rlpxConn, error) {
-	fd, err := net.Dial("tcp", fmt.Sprintf("%v:%d", n.IP(), n.TCP()))
+	fd, err := util.DialContext(context.Background(), "tcp", fmt.Sprintf("%v:%d", n.IP(), n.TCP()))
 	if err != nil {
 		return nil, err
 	}
@@ -251,3 +254,80 @@ func (c *rlpxConn) processNewPooledTransactionHashes(count *MessageCount, hashes
 
 	return nil
 }
+
+// RequestBlockHeaders requests amount headers starting at the block number and
+// waits for the response. The peer can return fewer headers than requested.
+func (c *rlpxConn) RequestBlockHeaders(start, amount uint64) ([]*database.Header, error) {
+	req := &GetBlockHeaders{
+		RequestId: rand.Uint64(),
+		GetBlockHeadersPacket: &eth.GetBlockHeadersPacket{
+			Origin: eth.HashOrNumber{Number: start},
+			Amount: amount,
+		},
+	}
+	if err := c.Write(req); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.readResponse(req.RequestId)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, ok := msg.(*BlockHeaders)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response to GetBlockHeaders: %T", msg)
+	}
+	return headers.BlockHeadersPacket, nil
+}
+
+// RequestBlockBodies requests the bodies of the block hashes and waits for the
+// response. The peer can return fewer bodies than requested.
+func (c *rlpxConn) RequestBlockBodies(hashes []common.Hash) ([]*database.Body, error) {
+	req := &GetBlockBodies{
+		RequestId:            rand.Uint64(),
+		GetBlockBodiesPacket: hashes,
+	}
+	if err := c.Write(req); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.readResponse(req.RequestId)
+	if err != nil {
+		return nil, err
+	}
+
+	bodies, ok := msg.(*BlockBodies)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response to GetBlockBodies: %T", msg)
+	}
+	return bodies.BlockBodiesPacket, nil
+}
+
+// readResponse reads messages until the response to the request, answering
+// the pings and ignoring the announcements received in the meantime.
+func (c *rlpxConn) readResponse(id uint64) (Message, error) {
+	defer func() { _ = c.SetReadDeadline(time.Time{}) }()
+	if err := c.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	for {
+		switch msg := c.Read().(type) {
+		case *Ping:
+			if err := c.Write(&Pong{}); err != nil {
+				return nil, err
+			}
+		case *Disconnect:
+			return nil, fmt.Errorf("disconnect received: %v", msg)
+		case *Disconnects:
+			return nil, fmt.Errorf("disconnect received: %v", msg)
+		case *Error:
+			return nil, msg.Unwrap()
+		case *BlockHeaders, *BlockBodies:
+			if msg.ReqID() == id {
+				return msg, nil
+			}
+		}
+	}
+}
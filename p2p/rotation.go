@@ -0,0 +1,268 @@
+package p2p
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/maticnetwork/polygon-cli/p2p/geoip"
+)
+
+// maxSeenHashes is the number of hashes kept in each generation of the seen
+// set, so memory stays bounded on busy networks.
+const maxSeenHashes = 1 << 20
+
+// RotationOptions configures the peer rotation policy.
+type RotationOptions struct {
+	// MinAge is how long a peer has to be connected before it can be dropped,
+	// so new peers get a chance to announce something.
+	MinAge time.Duration
+
+	// Fraction is the share of the peers that are replaced every rotation.
+	Fraction float64
+
+	// GeoIP is used to find the ASN and country of the peers and candidates.
+	// It can be nil, in which case only the client type and novelty are used.
+	GeoIP *geoip.Reader
+}
+
+// Rotation tracks how much each peer contributes to the observed gossip, and
+// picks which peers to drop and dial to keep the peer set diverse. A peer's
+// novelty is the number of block and transaction hashes it was the first peer
+// to announce.
+type Rotation struct {
+	opts RotationOptions
+
+	mu    sync.Mutex
+	peers map[enode.ID]*rotationPeer
+
+	// seen is split in two generations which are swapped when the current one
+	// is full, so the oldest hashes are forgotten first.
+	seen     map[common.Hash]struct{}
+	prevSeen map[common.Hash]struct{}
+}
+
+type rotationPeer struct {
+	node      *enode.Node
+	client    string
+	asn       uint
+	country   string
+	connected time.Time
+	novel     uint64
+}
+
+// NewRotation creates a new peer rotation policy.
+func NewRotation(opts RotationOptions) *Rotation {
+	return &Rotation{
+		opts:     opts,
+		peers:    make(map[enode.ID]*rotationPeer),
+		seen:     make(map[common.Hash]struct{}),
+		prevSeen: make(map[common.Hash]struct{}),
+	}
+}
+
+// Connected starts tracking the peer. It's a no-op if the rotation is nil,
+// which is the case when rotation is disabled.
+func (r *Rotation) Connected(p *ethp2p.Peer, location *geoip.Location) {
+	if r == nil {
+		return
+	}
+
+	peer := &rotationPeer{
+		node:      p.Node(),
+		client:    clientName(p.Fullname()),
+		connected: time.Now(),
+	}
+	if location != nil {
+		peer.asn = location.ASN
+		peer.country = location.Country
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peers[p.ID()] = peer
+}
+
+// Disconnected stops tracking the peer.
+func (r *Rotation) Disconnected(id enode.ID) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.peers, id)
+}
+
+// Observe records the hashes announced by the peer and credits it with the
+// ones no other peer announced before.
+func (r *Rotation) Observe(id enode.ID, hashes ...common.Hash) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var novel uint64
+	for _, hash := range hashes {
+		if _, ok := r.seen[hash]; ok {
+			continue
+		}
+		if _, ok := r.prevSeen[hash]; ok {
+			continue
+		}
+		novel++
+		if len(r.seen) >= maxSeenHashes {
+			r.prevSeen = r.seen
+			r.seen = make(map[common.Hash]struct{})
+		}
+		r.seen[hash] = struct{}{}
+	}
+
+	if peer, ok := r.peers[id]; ok {
+		peer.novel += novel
+	}
+}
+
+// Rotate returns the peers to drop and the nodes to dial in their place. The
+// peers connected for at least MinAge with the lowest novelty per minute are
+// dropped, preferring the ones whose ASN, country, and client are the most
+// common. The replacements are the known nodes from the least observed ASNs
+// and countries.
+func (r *Rotation) Rotate(known []*enode.Node) (drop, dial []*enode.Node) {
+	if r == nil {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, peer := range r.peers {
+		for _, key := range peer.groups() {
+			counts[key]++
+		}
+	}
+
+	now := time.Now()
+	eligible := make([]*rotationPeer, 0, len(r.peers))
+	for _, peer := range r.peers {
+		if now.Sub(peer.connected) >= r.opts.MinAge {
+			eligible = append(eligible, peer)
+		}
+	}
+
+	n := int(r.opts.Fraction * float64(len(r.peers)))
+	if n == 0 && r.opts.Fraction > 0 && len(eligible) > 0 {
+		n = 1
+	}
+	if n > len(eligible) {
+		n = len(eligible)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(eligible, func(i, j int) bool {
+		ri, rj := eligible[i].rate(now), eligible[j].rate(now)
+		if ri != rj {
+			return ri < rj
+		}
+		return eligible[i].redundancy(counts) > eligible[j].redundancy(counts)
+	})
+
+	dropped := make(map[enode.ID]struct{}, n)
+	for _, peer := range eligible[:n] {
+		drop = append(drop, peer.node)
+		dropped[peer.node.ID()] = struct{}{}
+		for _, key := range peer.groups() {
+			counts[key]--
+		}
+	}
+
+	candidates := make([]*rotationPeer, 0, len(known))
+	for _, node := range known {
+		if _, ok := r.peers[node.ID()]; ok {
+			continue
+		}
+		if _, ok := dropped[node.ID()]; ok {
+			continue
+		}
+		candidate := &rotationPeer{node: node}
+		if location, err := r.opts.GeoIP.Lookup(node.IP()); err == nil && location != nil {
+			candidate.asn = location.ASN
+			candidate.country = location.Country
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	// Shuffle first so candidates from equally observed groups are picked at
+	// random rather than in the order they're known.
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].redundancy(counts) < candidates[j].redundancy(counts)
+	})
+
+	for _, candidate := range candidates {
+		if len(dial) == n {
+			break
+		}
+		dial = append(dial, candidate.node)
+		for _, key := range candidate.groups() {
+			counts[key]++
+		}
+	}
+
+	return drop, dial
+}
+
+// rate is the novelty of the peer per minute connected.
+func (p *rotationPeer) rate(now time.Time) float64 {
+	minutes := now.Sub(p.connected).Minutes()
+	if minutes <= 0 {
+		return 0
+	}
+	return float64(p.novel) / minutes
+}
+
+// groups returns the keys of the groups the peer belongs to. Unknown values
+// aren't a group, so peers without GeoIP data aren't treated as alike.
+func (p *rotationPeer) groups() []string {
+	keys := make([]string, 0, 3)
+	if p.asn != 0 {
+		keys = append(keys, "asn:"+strconv.FormatUint(uint64(p.asn), 10))
+	}
+	if len(p.country) > 0 {
+		keys = append(keys, "country:"+p.country)
+	}
+	if len(p.client) > 0 {
+		keys = append(keys, "client:"+p.client)
+	}
+	return keys
+}
+
+// redundancy is the number of connected peers sharing the peer's groups.
+func (p *rotationPeer) redundancy(counts map[string]int) int {
+	total := 0
+	for _, key := range p.groups() {
+		total += counts[key]
+	}
+	return total
+}
+
+// clientName returns the client type from the name advertised in the hello
+// message, e.g. "bor" for "bor/v1.2.3-stable/linux-amd64/go1.21".
+func clientName(fullname string) string {
+	name, _, _ := strings.Cut(fullname, "/")
+	return strings.ToLower(name)
+}
@@ -0,0 +1,113 @@
+package p2p
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/maticnetwork/polygon-cli/util"
+)
+
+// Shard represents the portion of the node ID key space a sensor is
+// responsible for. When running multiple sensors against the same network,
+// giving each one a different index with the same count splits the peers
+// between them without duplicating connections. Because the node ID is
+// derived from the peer's public key, the assignment is stable across
+// restarts and IP changes.
+type Shard struct {
+	Index uint64
+	Count uint64
+}
+
+// ErrNodeNotInShard is returned when attempting to dial a node that belongs
+// to another shard.
+var ErrNodeNotInShard = errors.New("node is not in shard")
+
+// Validate ensures the shard index is within the shard count.
+func (s Shard) Validate() error {
+	if s.Count == 0 {
+		return errors.New("shard count must be greater than zero")
+	}
+
+	if s.Index >= s.Count {
+		return fmt.Errorf("shard index %d must be less than shard count %d", s.Index, s.Count)
+	}
+
+	return nil
+}
+
+// Contains returns whether the node ID falls into this shard. A shard count
+// of zero or one means there is only a single shard, so every node belongs to
+// it.
+func (s Shard) Contains(id enode.ID) bool {
+	if s.Count <= 1 {
+		return true
+	}
+
+	return binary.BigEndian.Uint64(id[:8])%s.Count == s.Index
+}
+
+// Filter returns the nodes that belong to the shard.
+func (s Shard) Filter(nodes []*enode.Node) []*enode.Node {
+	filtered := make([]*enode.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if s.Contains(node.ID()) {
+			filtered = append(filtered, node)
+		}
+	}
+
+	return filtered
+}
+
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+// shardDialer is a p2p.NodeDialer that refuses to dial nodes outside of the
+// shard. This prevents the server from spending file descriptors and
+// bandwidth on connections that would be dropped right after the handshake.
+type shardDialer struct {
+	shard  Shard
+	dialer nodeDialer
+}
+
+// NewShardDialer creates a dialer which only connects to nodes in the shard.
+func NewShardDialer(shard Shard) ethp2p.NodeDialer {
+	return &shardDialer{
+		shard:  shard,
+		dialer: nodeDialer{timeout: 15 * time.Second},
+	}
+}
+
+func (d *shardDialer) Dial(ctx context.Context, n *enode.Node) (net.Conn, error) {
+	if !d.shard.Contains(n.ID()) {
+		return nil, ErrNodeNotInShard
+	}
+
+	return d.dialer.Dial(ctx, n)
+}
+
+// nodeDialer is a p2p.NodeDialer that connects through the proxy set with
+// --proxy, if any. The discovery is over UDP, so it doesn't go through the
+// proxy.
+type nodeDialer struct {
+	timeout time.Duration
+}
+
+// NewProxyDialer creates a dialer which connects to nodes through the proxy.
+func NewProxyDialer() ethp2p.NodeDialer {
+	return &nodeDialer{timeout: 15 * time.Second}
+}
+
+func (d *nodeDialer) Dial(ctx context.Context, n *enode.Node) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	addr := &net.TCPAddr{IP: n.IP(), Port: n.TCP()}
+	return util.DialContext(ctx, "tcp", addr.String())
+}
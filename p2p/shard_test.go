@@ -0,0 +1,54 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestShardContains(t *testing.T) {
+	const count = 4
+
+	for i := 0; i < 1000; i++ {
+		var id enode.ID
+		id[0], id[7] = byte(i>>8), byte(i)
+
+		owners := 0
+		for index := uint64(0); index < count; index++ {
+			if (Shard{Index: index, Count: count}).Contains(id) {
+				owners++
+			}
+		}
+
+		if owners != 1 {
+			t.Fatalf("node %v is owned by %d shards, expected 1", id, owners)
+		}
+	}
+}
+
+func TestShardValidate(t *testing.T) {
+	type test struct {
+		name  string
+		shard Shard
+		valid bool
+	}
+
+	tests := []test{
+		{name: "single", shard: Shard{Index: 0, Count: 1}, valid: true},
+		{name: "last", shard: Shard{Index: 3, Count: 4}, valid: true},
+		{name: "zero count", shard: Shard{Index: 0, Count: 0}, valid: false},
+		{name: "out of range", shard: Shard{Index: 4, Count: 4}, valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.shard.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("expected shard %v to be valid: %v", tc.shard, err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected shard %v to be invalid", tc.shard)
+			}
+		})
+	}
+}
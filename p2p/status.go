@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+)
+
+// StatusOverride replaces fields of the status message the sensor sends, so
+// researchers can measure how strictly clients validate them, and so the
+// sensor can connect during fork transitions. The network ID and genesis are
+// never replaced, and the TD can't exceed the one of the head block so peers
+// never try to sync from the sensor.
+type StatusOverride struct {
+	// ForkID replaces the fork ID computed from the genesis and head.
+	ForkID *forkid.ID
+
+	// ForkIDNumber computes the fork ID as of this block number rather than
+	// the head. The time based forks are still the ones active at the head.
+	// It's ignored if ForkID is set.
+	ForkIDNumber *uint64
+
+	// Head replaces the hash of the head block.
+	Head *common.Hash
+
+	// TD replaces the total difficulty of the head block.
+	TD *big.Int
+}
+
+// IsEmpty returns whether the override doesn't replace any field. A nil
+// override is empty.
+func (o *StatusOverride) IsEmpty() bool {
+	return o == nil || (o.ForkID == nil && o.ForkIDNumber == nil && o.Head == nil && o.TD == nil)
+}
+
+// Apply replaces the fields of the status, using opts to compute the fork ID
+// at ForkIDNumber.
+func (o *StatusOverride) Apply(status *eth.StatusPacket, opts Eth66ProtocolOptions) {
+	if o.IsEmpty() {
+		return
+	}
+
+	switch {
+	case o.ForkID != nil:
+		status.ForkID = *o.ForkID
+	case o.ForkIDNumber != nil:
+		status.ForkID = NewForkID(opts.Genesis, opts.Forks, opts.GenesisHash, *o.ForkIDNumber, opts.Head.Time)
+	}
+
+	if o.Head != nil {
+		status.Head = *o.Head
+	}
+
+	if o.TD != nil {
+		if status.TD == nil || o.TD.Cmp(status.TD) <= 0 {
+			status.TD = o.TD
+		}
+	}
+}
+
+// ParseForkID parses a fork ID from the hex encoded 4 byte checksum of the
+// fork hashes, optionally followed by a colon and the next fork block number,
+// e.g. "0x0c015a91:1000000".
+func ParseForkID(s string) (forkid.ID, error) {
+	var id forkid.ID
+
+	hash, next, found := strings.Cut(s, ":")
+	b, err := hex.DecodeString(strings.TrimPrefix(hash, "0x"))
+	if err != nil {
+		return id, fmt.Errorf("invalid fork hash %q: %w", hash, err)
+	}
+	if len(b) != len(id.Hash) {
+		return id, fmt.Errorf("fork hash %q must be %d bytes", hash, len(id.Hash))
+	}
+	copy(id.Hash[:], b)
+
+	if found {
+		id.Next, err = strconv.ParseUint(next, 10, 64)
+		if err != nil {
+			return id, fmt.Errorf("invalid next fork %q: %w", next, err)
+		}
+	}
+
+	return id, nil
+}
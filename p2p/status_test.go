@@ -0,0 +1,40 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+)
+
+func TestParseForkID(t *testing.T) {
+	type test struct {
+		name  string
+		input string
+		id    forkid.ID
+		valid bool
+	}
+
+	tests := []test{
+		{name: "hash", input: "0x0c015a91", id: forkid.ID{Hash: [4]byte{0x0c, 0x01, 0x5a, 0x91}}, valid: true},
+		{name: "no prefix", input: "0c015a91", id: forkid.ID{Hash: [4]byte{0x0c, 0x01, 0x5a, 0x91}}, valid: true},
+		{name: "next", input: "0x0c015a91:1000000", id: forkid.ID{Hash: [4]byte{0x0c, 0x01, 0x5a, 0x91}, Next: 1000000}, valid: true},
+		{name: "short hash", input: "0x0c01", valid: false},
+		{name: "invalid hash", input: "0xzz015a91", valid: false},
+		{name: "invalid next", input: "0x0c015a91:next", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := ParseForkID(tc.input)
+			if tc.valid && err != nil {
+				t.Fatalf("expected %q to be valid: %v", tc.input, err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("expected %q to be invalid", tc.input)
+			}
+			if tc.valid && id != tc.id {
+				t.Errorf("expected %v, got %v", tc.id, id)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package p2p
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -13,6 +14,8 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/rlpx"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/rs/zerolog"
+
+	"github.com/maticnetwork/polygon-cli/p2p/database"
 )
 
 type Message interface {
@@ -97,7 +100,12 @@ type GetBlockHeaders eth.GetBlockHeadersPacket66
 func (msg GetBlockHeaders) Code() int     { return 19 }
 func (msg GetBlockHeaders) ReqID() uint64 { return msg.RequestId }
 
-type BlockHeaders eth.BlockHeadersPacket66
+// BlockHeaders is the network packet for the block headers. The headers
+// decode the fields added since London, which the types of go-ethereum don't.
+type BlockHeaders struct {
+	RequestId          uint64
+	BlockHeadersPacket []*database.Header
+}
 
 func (msg BlockHeaders) Code() int     { return 20 }
 func (msg BlockHeaders) ReqID() uint64 { return msg.RequestId }
@@ -109,13 +117,19 @@ func (msg GetBlockBodies) Code() int     { return 21 }
 func (msg GetBlockBodies) ReqID() uint64 { return msg.RequestId }
 
 // BlockBodies is the network packet for block content distribution.
-type BlockBodies eth.BlockBodiesPacket66
+type BlockBodies struct {
+	RequestId         uint64
+	BlockBodiesPacket []*database.Body
+}
 
 func (msg BlockBodies) Code() int     { return 22 }
 func (msg BlockBodies) ReqID() uint64 { return msg.RequestId }
 
 // NewBlock is the network packet for the block propagation message.
-type NewBlock eth.NewBlockPacket
+type NewBlock struct {
+	Block *database.Block
+	TD    *big.Int
+}
 
 func (msg NewBlock) Code() int     { return 23 }
 func (msg NewBlock) ReqID() uint64 { return 0 }
@@ -187,11 +201,7 @@ func (c *rlpxConn) Read() Message {
 		}
 		return (*GetBlockHeaders)(ethMsg)
 	case (BlockHeaders{}).Code():
-		ethMsg := new(eth.BlockHeadersPacket66)
-		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
-			return errorf("could not rlp decode message: %v", err)
-		}
-		return (*BlockHeaders)(ethMsg)
+		msg = new(BlockHeaders)
 	case (GetBlockBodies{}).Code():
 		ethMsg := new(eth.GetBlockBodiesPacket66)
 		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
@@ -199,11 +209,7 @@ func (c *rlpxConn) Read() Message {
 		}
 		return (*GetBlockBodies)(ethMsg)
 	case (BlockBodies{}).Code():
-		ethMsg := new(eth.BlockBodiesPacket66)
-		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
-			return errorf("could not rlp decode message: %v", err)
-		}
-		return (*BlockBodies)(ethMsg)
+		msg = new(BlockBodies)
 	case (NewBlock{}).Code():
 		msg = new(NewBlock)
 	case (NewBlockHashes{}).Code():
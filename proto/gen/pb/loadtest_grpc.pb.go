@@ -0,0 +1,146 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: loadtest.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	LoadTestControl_Register_FullMethodName = "/proto.LoadTestControl/Register"
+	LoadTestControl_Report_FullMethodName   = "/proto.LoadTestControl/Report"
+)
+
+// LoadTestControlClient is the client API for LoadTestControl service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LoadTestControlClient interface {
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*AgentAssignment, error)
+	Report(ctx context.Context, in *AgentReport, opts ...grpc.CallOption) (*ReportAck, error)
+}
+
+type loadTestControlClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLoadTestControlClient(cc grpc.ClientConnInterface) LoadTestControlClient {
+	return &loadTestControlClient{cc}
+}
+
+func (c *loadTestControlClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*AgentAssignment, error) {
+	out := new(AgentAssignment)
+	err := c.cc.Invoke(ctx, LoadTestControl_Register_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loadTestControlClient) Report(ctx context.Context, in *AgentReport, opts ...grpc.CallOption) (*ReportAck, error) {
+	out := new(ReportAck)
+	err := c.cc.Invoke(ctx, LoadTestControl_Report_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LoadTestControlServer is the server API for LoadTestControl service.
+// All implementations must embed UnimplementedLoadTestControlServer
+// for forward compatibility
+type LoadTestControlServer interface {
+	Register(context.Context, *RegisterRequest) (*AgentAssignment, error)
+	Report(context.Context, *AgentReport) (*ReportAck, error)
+	mustEmbedUnimplementedLoadTestControlServer()
+}
+
+// UnimplementedLoadTestControlServer must be embedded to have forward compatible implementations.
+type UnimplementedLoadTestControlServer struct {
+}
+
+func (UnimplementedLoadTestControlServer) Register(context.Context, *RegisterRequest) (*AgentAssignment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedLoadTestControlServer) Report(context.Context, *AgentReport) (*ReportAck, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Report not implemented")
+}
+func (UnimplementedLoadTestControlServer) mustEmbedUnimplementedLoadTestControlServer() {}
+
+// UnsafeLoadTestControlServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LoadTestControlServer will
+// result in compilation errors.
+type UnsafeLoadTestControlServer interface {
+	mustEmbedUnimplementedLoadTestControlServer()
+}
+
+func RegisterLoadTestControlServer(s grpc.ServiceRegistrar, srv LoadTestControlServer) {
+	s.RegisterService(&LoadTestControl_ServiceDesc, srv)
+}
+
+func _LoadTestControl_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoadTestControlServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoadTestControl_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoadTestControlServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoadTestControl_Report_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AgentReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoadTestControlServer).Report(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoadTestControl_Report_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoadTestControlServer).Report(ctx, req.(*AgentReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// LoadTestControl_ServiceDesc is the grpc.ServiceDesc for LoadTestControl service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LoadTestControl_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.LoadTestControl",
+	HandlerType: (*LoadTestControlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _LoadTestControl_Register_Handler,
+		},
+		{
+			MethodName: "Report",
+			Handler:    _LoadTestControl_Report_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "loadtest.proto",
+}
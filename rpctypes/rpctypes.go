@@ -139,6 +139,9 @@ type (
 
 		// baseFeePerGas: QUANTITY - fixed per block fee
 		BaseFeePerGas RawQuantityResponse `json:"baseFeePerGas"`
+
+		// mixHash: DATA, 32 Bytes - the mix digest of the block.
+		MixHash RawData32Response `json:"mixHash,omitempty"`
 	}
 
 	RawTxLogs struct {
@@ -212,6 +215,15 @@ type (
 
 		// status: QUANTITY either 1 (success) or 0 (failure)
 		Status RawQuantityResponse `json:"status"`
+
+		// l1Fee: QUANTITY - OP stack only, the fee paid for posting the transaction data to L1.
+		L1Fee RawQuantityResponse `json:"l1Fee,omitempty"`
+
+		// l1GasUsed: QUANTITY - OP stack only, the L1 gas used by the transaction data.
+		L1GasUsed RawQuantityResponse `json:"l1GasUsed,omitempty"`
+
+		// l1GasPrice: QUANTITY - OP stack only, the L1 base fee used to compute the L1 fee.
+		L1GasPrice RawQuantityResponse `json:"l1GasPrice,omitempty"`
 	}
 
 	PolyTransaction interface {
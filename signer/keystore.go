@@ -0,0 +1,98 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/term"
+)
+
+// DefaultPasswordEnv is the environment variable the keystore passphrase is
+// read from by default.
+const DefaultPasswordEnv = "POLYCLI_KEYSTORE_PASSWORD"
+
+// keystoreSigner signs with an account of an encrypted geth style keystore.
+type keystoreSigner struct {
+	ks      *keystore.KeyStore
+	account accounts.Account
+}
+
+// DefaultKeystoreDir is the keystore directory in the home directory.
+func DefaultKeystoreDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "keystore"
+	}
+	return filepath.Join(home, ".polygon-cli", "keystore")
+}
+
+// OpenKeystore opens the keystore directory, which is created if it doesn't
+// exist yet.
+func OpenKeystore(dir string) *keystore.KeyStore {
+	return keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+// ReadPassword reads the keystore passphrase from the file if one is given,
+// then from the environment variable, and finally prompts for it if stdin is
+// a terminal. It's never read from a flag so it can't leak into the shell
+// history.
+func ReadPassword(file, env, prompt string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("unable to read the password file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if env != "" {
+		if password, ok := os.LookupEnv(env); ok {
+			return password, nil
+		}
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no password was given with a file or the %s environment variable", env)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+func newKeystoreSigner(c Config) (*keystoreSigner, error) {
+	ks := OpenKeystore(c.KeystoreDir)
+	account, err := selectAccount(ks.Accounts(), c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := ReadPassword(c.PasswordFile, c.PasswordEnv, fmt.Sprintf("Passphrase for %s: ", account.Address.Hex()))
+	if err != nil {
+		return nil, err
+	}
+	if err = ks.Unlock(account, password); err != nil {
+		return nil, fmt.Errorf("unable to unlock %s: %w", account.Address.Hex(), err)
+	}
+
+	log.Info().Str("keystore", c.KeystoreDir).Str("address", account.Address.Hex()).Msg("Using keystore signer")
+	return &keystoreSigner{ks: ks, account: account}, nil
+}
+
+func (s *keystoreSigner) Address() ethcommon.Address {
+	return s.account.Address
+}
+
+func (s *keystoreSigner) SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error) {
+	return s.ks.SignTx(s.account, tx, chainID)
+}
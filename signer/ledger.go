@@ -0,0 +1,75 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// ledgerSigner signs on a Ledger connected over USB. Every transaction has to
+// be confirmed on the device.
+type ledgerSigner struct {
+	wallet  accounts.Wallet
+	account accounts.Account
+}
+
+func newLedgerSigner(path string) (*ledgerSigner, error) {
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("the derivation path %s is invalid: %w", path, err)
+	}
+
+	hub, err := usbwallet.NewLedgerHub()
+	if err != nil {
+		return nil, fmt.Errorf("unable to access usb devices: %w", err)
+	}
+
+	// The hub enumerates the devices in the background, so give it a moment
+	// to find the Ledger.
+	var wallets []accounts.Wallet
+	for i := 0; i < 10 && len(wallets) == 0; i++ {
+		if wallets = hub.Wallets(); len(wallets) == 0 {
+			time.Sleep(500 * time.Millisecond)
+		}
+	}
+	if len(wallets) == 0 {
+		return nil, fmt.Errorf("no ledger was found")
+	}
+
+	wallet := wallets[0]
+	if err = wallet.Open(""); err != nil {
+		return nil, fmt.Errorf("unable to open the ledger, is the ethereum app open: %w", err)
+	}
+	account, err := wallet.Derive(derivationPath, true)
+	if err != nil {
+		wallet.Close()
+		return nil, fmt.Errorf("unable to derive the ledger account: %w", err)
+	}
+
+	log.Info().Str("url", wallet.URL().String()).Str("path", path).Str("address", account.Address.Hex()).Msg("Using ledger signer")
+	return &ledgerSigner{wallet: wallet, account: account}, nil
+}
+
+func (s *ledgerSigner) Address() ethcommon.Address {
+	return s.account.Address
+}
+
+func (s *ledgerSigner) SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error) {
+	if tx.Type() != ethtypes.LegacyTxType {
+		return nil, fmt.Errorf("the ledger signer only supports legacy transactions")
+	}
+	log.Info().Uint64("nonce", tx.Nonce()).Msg("Confirm the transaction on the ledger")
+	return s.wallet.SignTx(s.account, tx, chainID)
+}
+
+// LegacyOnly is true because the ledger driver can't sign typed transactions.
+func (s *ledgerSigner) LegacyOnly() bool {
+	return true
+}
@@ -0,0 +1,138 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// clefSigner signs with Clef over its external API.
+	clefSigner struct {
+		clef    *external.ExternalSigner
+		account accounts.Account
+	}
+
+	// web3Signer signs with web3signer over its eth1 JSON-RPC API.
+	web3Signer struct {
+		client  *ethrpc.Client
+		address ethcommon.Address
+	}
+
+	// signTxArgs are the params of eth_signTransaction.
+	signTxArgs struct {
+		From                 ethcommon.Address  `json:"from"`
+		To                   *ethcommon.Address `json:"to,omitempty"`
+		Gas                  hexutil.Uint64     `json:"gas"`
+		GasPrice             *hexutil.Big       `json:"gasPrice,omitempty"`
+		MaxFeePerGas         *hexutil.Big       `json:"maxFeePerGas,omitempty"`
+		MaxPriorityFeePerGas *hexutil.Big       `json:"maxPriorityFeePerGas,omitempty"`
+		Value                *hexutil.Big       `json:"value"`
+		Nonce                hexutil.Uint64     `json:"nonce"`
+		Data                 hexutil.Bytes      `json:"data"`
+		ChainID              *hexutil.Big       `json:"chainId,omitempty"`
+	}
+)
+
+func newClefSigner(url, address string) (*clefSigner, error) {
+	clef, err := external.NewExternalSigner(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to clef: %w", err)
+	}
+	account, err := selectAccount(clef.Accounts(), address)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("url", url).Str("address", account.Address.Hex()).Msg("Using clef signer")
+	return &clefSigner{clef: clef, account: account}, nil
+}
+
+func (s *clefSigner) Address() ethcommon.Address {
+	return s.account.Address
+}
+
+func (s *clefSigner) SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error) {
+	return s.clef.SignTx(s.account, tx, chainID)
+}
+
+func newWeb3Signer(ctx context.Context, url, address string) (*web3Signer, error) {
+	client, err := ethrpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to web3signer: %w", err)
+	}
+	var addresses []ethcommon.Address
+	if err = client.CallContext(ctx, &addresses, "eth_accounts"); err != nil {
+		return nil, fmt.Errorf("unable to list the web3signer accounts: %w", err)
+	}
+	list := make([]accounts.Account, 0, len(addresses))
+	for _, a := range addresses {
+		list = append(list, accounts.Account{Address: a})
+	}
+	account, err := selectAccount(list, address)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("url", url).Str("address", account.Address.Hex()).Msg("Using web3signer signer")
+	return &web3Signer{client: client, address: account.Address}, nil
+}
+
+func (s *web3Signer) Address() ethcommon.Address {
+	return s.address
+}
+
+func (s *web3Signer) SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error) {
+	args := signTxArgs{
+		From:    s.address,
+		To:      tx.To(),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   (*hexutil.Big)(tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    tx.Data(),
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	switch tx.Type() {
+	case ethtypes.LegacyTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	case ethtypes.DynamicFeeTxType:
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	default:
+		return nil, fmt.Errorf("web3signer can't sign transactions of type %d", tx.Type())
+	}
+
+	var raw hexutil.Bytes
+	if err := s.client.CallContext(ctx, &raw, "eth_signTransaction", args); err != nil {
+		return nil, err
+	}
+	signed := new(ethtypes.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("unable to decode the signed transaction: %w", err)
+	}
+	return signed, nil
+}
+
+// selectAccount picks the account with the address or the only account if no
+// address is given.
+func selectAccount(list []accounts.Account, address string) (accounts.Account, error) {
+	if address == "" {
+		if len(list) != 1 {
+			return accounts.Account{}, fmt.Errorf("the signer has %d accounts, so --signer-address must be set", len(list))
+		}
+		return list[0], nil
+	}
+	want := ethcommon.HexToAddress(address)
+	for _, a := range list {
+		if a.Address == want {
+			return a, nil
+		}
+	}
+	return accounts.Account{}, fmt.Errorf("the signer doesn't have the account %s", address)
+}
@@ -0,0 +1,90 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// EIP-7702 isn't supported by the version of go-ethereum we build with, so
+// the set code transactions and their authorizations are encoded and signed
+// here. They need the private key since the other signers can only sign the
+// transaction types go-ethereum knows.
+const (
+	SetCodeTxType = 0x04
+	// setCodeAuthMagic prefixes the authorizations before they're hashed, so
+	// they can't be mistaken for a transaction.
+	setCodeAuthMagic = 0x05
+)
+
+// SetCodeAuthorization lets the address be the code of the authority account
+// that signed it, or clears the delegation if the address is zero.
+type SetCodeAuthorization struct {
+	ChainID *big.Int
+	Address ethcommon.Address
+	Nonce   uint64
+	V       uint8
+	R       *big.Int
+	S       *big.Int
+}
+
+// SetCodeTx is the payload of a transaction of type 0x04.
+type SetCodeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         ethcommon.Address
+	Value      *big.Int
+	Data       []byte
+	AccessList ethtypes.AccessList
+	AuthList   []SetCodeAuthorization
+	V          uint8
+	R          *big.Int
+	S          *big.Int
+}
+
+// SignSetCodeAuthorization signs the delegation of the authority to the
+// address at the nonce of the authority.
+func SignSetCodeAuthorization(key *ecdsa.PrivateKey, chainID *big.Int, address ethcommon.Address, nonce uint64) (SetCodeAuthorization, error) {
+	auth := SetCodeAuthorization{ChainID: chainID, Address: address, Nonce: nonce}
+	payload, err := rlp.EncodeToBytes([]any{auth.ChainID, auth.Address, auth.Nonce})
+	if err != nil {
+		return auth, err
+	}
+	sig, err := ethcrypto.Sign(ethcrypto.Keccak256(append([]byte{setCodeAuthMagic}, payload...)), key)
+	if err != nil {
+		return auth, err
+	}
+	auth.R = new(big.Int).SetBytes(sig[:32])
+	auth.S = new(big.Int).SetBytes(sig[32:64])
+	auth.V = sig[64]
+	return auth, nil
+}
+
+// SignSetCodeTx signs the transaction and returns its encoding and hash.
+func SignSetCodeTx(tx *SetCodeTx, key *ecdsa.PrivateKey) ([]byte, ethcommon.Hash, error) {
+	payload, err := rlp.EncodeToBytes([]any{tx.ChainID, tx.Nonce, tx.GasTipCap, tx.GasFeeCap, tx.Gas, tx.To, tx.Value, tx.Data, tx.AccessList, tx.AuthList})
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	sig, err := ethcrypto.Sign(ethcrypto.Keccak256(append([]byte{SetCodeTxType}, payload...)), key)
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	tx.R = new(big.Int).SetBytes(sig[:32])
+	tx.S = new(big.Int).SetBytes(sig[32:64])
+	tx.V = sig[64]
+
+	payload, err = rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	raw := append([]byte{SetCodeTxType}, payload...)
+	return raw, ethcrypto.Keccak256Hash(raw), nil
+}
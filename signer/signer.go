@@ -0,0 +1,150 @@
+// Package signer signs transactions with a local private key, an encrypted
+// keystore, a Ledger, or a remote signer such as Clef or web3signer so
+// commands that send transactions don't need raw private keys.
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/pflag"
+)
+
+const (
+	KindPrivateKey = "private-key"
+	KindKeystore   = "keystore"
+	KindLedger     = "ledger"
+	KindClef       = "clef"
+	KindWeb3Signer = "web3signer"
+)
+
+// Kinds are the supported signer backends.
+var Kinds = []string{KindPrivateKey, KindKeystore, KindLedger, KindClef, KindWeb3Signer}
+
+type (
+	// Signer signs transactions for a single address.
+	Signer interface {
+		Address() ethcommon.Address
+		SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error)
+	}
+
+	// Config selects and configures the signer backend.
+	Config struct {
+		Kind         string
+		URL          string
+		Path         string
+		Address      string
+		KeystoreDir  string
+		PasswordFile string
+		PasswordEnv  string
+	}
+
+	// LocalSigner signs with a private key held in memory.
+	LocalSigner struct {
+		Key     *ecdsa.PrivateKey
+		address ethcommon.Address
+	}
+
+	// legacyOnly is implemented by signers that can't sign typed
+	// transactions.
+	legacyOnly interface {
+		LegacyOnly() bool
+	}
+)
+
+// AddFlags registers the signer flags on the flag set.
+func (c *Config) AddFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(&c.Kind, "signer", KindPrivateKey, "The transaction signer ["+strings.Join(Kinds, ", ")+"]")
+	flagSet.StringVar(&c.URL, "signer-url", "", "The endpoint of the clef or web3signer remote signer")
+	flagSet.StringVar(&c.Path, "signer-path", "m/44'/60'/0'/0/0", "The derivation path of the ledger account")
+	flagSet.StringVar(&c.Address, "signer-address", "", "The account of the keystore or remote signer to use if it has more than one")
+	flagSet.StringVar(&c.KeystoreDir, "keystore", DefaultKeystoreDir(), "The keystore directory used by the keystore signer")
+	flagSet.StringVar(&c.PasswordFile, "keystore-password-file", "", "A file with the passphrase of the keystore account")
+	flagSet.StringVar(&c.PasswordEnv, "keystore-password-env", DefaultPasswordEnv, "The environment variable with the passphrase of the keystore account")
+}
+
+// Validate checks the config without connecting to the signer.
+func (c *Config) Validate() error {
+	switch c.Kind {
+	case KindPrivateKey, KindKeystore, KindLedger:
+	case KindClef, KindWeb3Signer:
+		if c.URL == "" {
+			return fmt.Errorf("--signer-url is required for the %s signer", c.Kind)
+		}
+	default:
+		return fmt.Errorf("the signer must be one of %v", Kinds)
+	}
+	if c.Address != "" && !ethcommon.IsHexAddress(c.Address) {
+		return fmt.Errorf("the signer address %s is invalid", c.Address)
+	}
+	return nil
+}
+
+// New creates the signer for the config. The private key is only used by the
+// private key signer.
+func New(ctx context.Context, c Config, privateKey string) (Signer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	switch c.Kind {
+	case KindKeystore:
+		return newKeystoreSigner(c)
+	case KindLedger:
+		return newLedgerSigner(c.Path)
+	case KindClef:
+		return newClefSigner(c.URL, c.Address)
+	case KindWeb3Signer:
+		return newWeb3Signer(ctx, c.URL, c.Address)
+	default:
+		return NewLocalSigner(privateKey)
+	}
+}
+
+// NewLocalSigner parses the hex encoded private key.
+func NewLocalSigner(privateKey string) (*LocalSigner, error) {
+	key, err := ethcrypto.HexToECDSA(strings.TrimPrefix(privateKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't process the hex private key: %w", err)
+	}
+	return &LocalSigner{Key: key, address: ethcrypto.PubkeyToAddress(key.PublicKey)}, nil
+}
+
+func (s *LocalSigner) Address() ethcommon.Address {
+	return s.address
+}
+
+func (s *LocalSigner) SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error) {
+	return ethtypes.SignTx(tx, ethtypes.LatestSignerForChainID(chainID), s.Key)
+}
+
+// RequiresLegacy returns true if the signer can only sign legacy
+// transactions.
+func RequiresLegacy(s Signer) bool {
+	l, ok := s.(legacyOnly)
+	return ok && l.LegacyOnly()
+}
+
+// NewTransactOpts creates the options used by the contract bindings to sign
+// with the signer.
+func NewTransactOpts(ctx context.Context, s Signer, chainID *big.Int) (*bind.TransactOpts, error) {
+	if chainID == nil {
+		return nil, bind.ErrNoChainID
+	}
+	return &bind.TransactOpts{
+		From: s.Address(),
+		Signer: func(address ethcommon.Address, tx *ethtypes.Transaction) (*ethtypes.Transaction, error) {
+			if address != s.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return s.SignTx(ctx, tx, chainID)
+		},
+		Context: ctx,
+	}, nil
+}
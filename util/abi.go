@@ -0,0 +1,264 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// panicSelector is the selector of the Panic(uint256) error raised by
+	// failed assertions, overflows, and similar.
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+	// revertSelector is the selector of the Error(string) error raised by
+	// require and revert with a reason.
+	revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+)
+
+// DecodedABIData is a decoded function call, event log, or error.
+type DecodedABIData struct {
+	Type      string                 `json:"type"`
+	Signature string                 `json:"signature"`
+	Selector  string                 `json:"selector"`
+	Values    map[string]interface{} `json:"values"`
+}
+
+// ABISource is where the ABIs of verified contracts are fetched from. An
+// Etherscan compatible API is used if its URL is set, otherwise Sourcify.
+type ABISource struct {
+	ChainID         uint64
+	SourcifyURL     string
+	EtherscanURL    string
+	EtherscanAPIKey string
+}
+
+// FetchABI fetches the ABI of a verified contract.
+func (s ABISource) FetchABI(ctx context.Context, address string) ([]byte, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("the address %s is invalid", address)
+	}
+	if s.EtherscanURL != "" {
+		return s.fetchEtherscanABI(ctx, address)
+	}
+	return s.fetchSourcifyABI(ctx, address)
+}
+
+func (s ABISource) fetchSourcifyABI(ctx context.Context, address string) ([]byte, error) {
+	source := fmt.Sprintf("%s/v2/contract/%d/%s?fields=abi", strings.TrimSuffix(s.SourcifyURL, "/"), s.ChainID, address)
+	body, err := httpGet(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the abi from sourcify: %w", err)
+	}
+
+	var contract struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if err = json.Unmarshal(body, &contract); err != nil {
+		return nil, err
+	}
+	if len(contract.ABI) == 0 {
+		return nil, fmt.Errorf("sourcify has no abi for %s on chain %d", address, s.ChainID)
+	}
+	return contract.ABI, nil
+}
+
+func (s ABISource) fetchEtherscanABI(ctx context.Context, address string) ([]byte, error) {
+	source, err := url.Parse(s.EtherscanURL)
+	if err != nil {
+		return nil, err
+	}
+	query := source.Query()
+	query.Set("chainid", strconv.FormatUint(s.ChainID, 10))
+	query.Set("module", "contract")
+	query.Set("action", "getabi")
+	query.Set("address", address)
+	if s.EtherscanAPIKey != "" {
+		query.Set("apikey", s.EtherscanAPIKey)
+	}
+	source.RawQuery = query.Encode()
+
+	body, err := httpGet(ctx, source.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the abi from etherscan: %w", err)
+	}
+
+	// The result is the ABI as a JSON string on success or the error message
+	// otherwise.
+	var resp struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Result  string `json:"result"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "1" {
+		return nil, fmt.Errorf("etherscan returned %s: %s", resp.Message, resp.Result)
+	}
+	return []byte(resp.Result), nil
+}
+
+func httpGet(ctx context.Context, source string) ([]byte, error) {
+	log.Trace().Str("url", source).Msg("Fetching abi")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// DecodeLog decodes an event log. The first topic is the event signature.
+func DecodeLog(abi *gethabi.ABI, topics []common.Hash, data []byte) (*DecodedABIData, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("the log has no topics")
+	}
+	event, err := abi.EventByID(topics[0])
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	if err = event.Inputs.UnpackIntoMap(values, data); err != nil {
+		return nil, fmt.Errorf("unable to decode the log data: %w", err)
+	}
+	var indexed gethabi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if err = gethabi.ParseTopicsIntoMap(values, indexed, topics[1:]); err != nil {
+		return nil, fmt.Errorf("unable to decode the log topics: %w", err)
+	}
+
+	return &DecodedABIData{
+		Type:      "event",
+		Signature: event.Sig,
+		Selector:  event.ID.Hex(),
+		Values:    formatValues(event.Inputs, values),
+	}, nil
+}
+
+// DecodeCallOrError decodes calldata if the selector matches a function, or a
+// revert error if it matches an error. The standard Error(string) and
+// Panic(uint256) errors are decoded even if they aren't in the ABI.
+func DecodeCallOrError(abi *gethabi.ABI, data []byte) (*DecodedABIData, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("the data is too short to contain a selector")
+	}
+	selector, args := data[:4], data[4:]
+	values := make(map[string]interface{})
+
+	if method, err := abi.MethodById(selector); err == nil {
+		if err = method.Inputs.UnpackIntoMap(values, args); err != nil {
+			return nil, fmt.Errorf("unable to decode the calldata: %w", err)
+		}
+		return &DecodedABIData{Type: "function", Signature: method.Sig, Selector: hexutil.Encode(selector), Values: formatValues(method.Inputs, values)}, nil
+	}
+
+	for _, e := range abi.Errors {
+		if !bytes.Equal(e.ID[:4], selector) {
+			continue
+		}
+		if err := e.Inputs.UnpackIntoMap(values, args); err != nil {
+			return nil, fmt.Errorf("unable to decode the error: %w", err)
+		}
+		return &DecodedABIData{Type: "error", Signature: e.Sig, Selector: hexutil.Encode(selector), Values: formatValues(e.Inputs, values)}, nil
+	}
+
+	switch {
+	case bytes.Equal(selector, revertSelector):
+		reason, err := gethabi.UnpackRevert(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode the revert reason: %w", err)
+		}
+		values["reason"] = reason
+		return &DecodedABIData{Type: "error", Signature: "Error(string)", Selector: hexutil.Encode(selector), Values: values}, nil
+	case bytes.Equal(selector, panicSelector):
+		if len(args) != 32 {
+			return nil, fmt.Errorf("unable to decode the panic code")
+		}
+		values["code"] = hexutil.EncodeBig(new(big.Int).SetBytes(args))
+		return &DecodedABIData{Type: "error", Signature: "Panic(uint256)", Selector: hexutil.Encode(selector), Values: values}, nil
+	}
+
+	return nil, fmt.Errorf("the selector %s wasn't matched in the given abi", hexutil.Encode(selector))
+}
+
+// DecodeReturnData decodes the return data of a call into its values, in the
+// order of the outputs.
+func DecodeReturnData(outputs gethabi.Arguments, data []byte) ([]interface{}, error) {
+	unpacked, err := outputs.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the return data: %w", err)
+	}
+	values := make([]interface{}, len(unpacked))
+	for i, v := range unpacked {
+		values[i] = formatValue(outputs[i].Type, reflect.ValueOf(v))
+	}
+	return values, nil
+}
+
+// formatValues makes the decoded values readable as JSON. Bytes are written
+// as hex rather than base64 or arrays of numbers.
+func formatValues(args gethabi.Arguments, values map[string]interface{}) map[string]interface{} {
+	formatted := make(map[string]interface{}, len(values))
+	for _, arg := range args {
+		if v, ok := values[arg.Name]; ok {
+			formatted[arg.Name] = formatValue(arg.Type, reflect.ValueOf(v))
+		}
+	}
+	return formatted
+}
+
+func formatValue(t gethabi.Type, v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	// Indexed dynamic values are only available as the hash in the topic.
+	if h, ok := v.Interface().(common.Hash); ok {
+		return h
+	}
+
+	switch t.T {
+	case gethabi.BytesTy, gethabi.FixedBytesTy, gethabi.FunctionTy:
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return hexutil.Encode(b)
+	case gethabi.SliceTy, gethabi.ArrayTy:
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = formatValue(*t.Elem, v.Index(i))
+		}
+		return out
+	case gethabi.TupleTy:
+		out := make(map[string]interface{}, v.NumField())
+		for i, elem := range t.TupleElems {
+			out[t.TupleRawNames[i]] = formatValue(*elem, v.Field(i))
+		}
+		return out
+	}
+	return v.Interface()
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// DialRPC dials an RPC endpoint through the proxy and with the auth set
+// with the global flags.
+func DialRPC(ctx context.Context, rawURL string) (*ethrpc.Client, error) {
+	return DialRPCWithClient(ctx, rawURL, nil)
+}
+
+// DialRPCWithClient is DialRPC with the HTTP client used for HTTP
+// endpoints. The tokens are added to it, but the client certificate is only
+// used if the client doesn't have its own transport.
+func DialRPCWithClient(ctx context.Context, rawURL string, hc *http.Client) (*ethrpc.Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return ethrpc.DialHTTPWithClient(rawURL, newRPCHTTPClient(hc))
+	case "ws", "wss":
+		// The WebSocket client of go-ethereum only sends the basic auth of
+		// the url with the handshake
+		if hasRPCToken() {
+			return nil, fmt.Errorf("the rpc tokens can only be sent to http endpoints")
+		}
+		// The WebSocket client has its own dialer, so it's the one that
+		// needs the proxy set here
+		dialer := websocket.Dialer{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			TLSClientConfig: RPCTLSConfig(),
+		}
+		if proxyURL != nil {
+			dialer.Proxy = http.ProxyURL(proxyURL)
+		}
+		return ethrpc.DialWebsocketWithDialer(ctx, rawURL, "", dialer)
+	}
+	return ethrpc.DialContext(ctx, rawURL)
+}
+
+// DialEthClient dials an RPC endpoint like DialRPC.
+func DialEthClient(ctx context.Context, rawURL string) (*ethclient.Client, error) {
+	client, err := DialRPC(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(client), nil
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// The exit codes of polycli, so CI pipelines can branch on the type of the
+// failure. They're part of the interface of polycli and never change.
+const (
+	// ExitOK is the exit code of a command that succeeded.
+	ExitOK = 0
+	// ExitFailure is the exit code of the failures that aren't one of the
+	// types below.
+	ExitFailure = 1
+	// ExitConfig is the exit code of invalid flags, arguments, or config
+	// files, which fail before anything is done.
+	ExitConfig = 2
+	// ExitRPCUnreachable is the exit code of an RPC endpoint or node that
+	// couldn't be reached.
+	ExitRPCUnreachable = 3
+	// ExitPartialFailure is the exit code of a command that ran to the end
+	// but couldn't do some of its work, like funding some of the addresses.
+	ExitPartialFailure = 4
+	// ExitAssertionFailure is the exit code of a command whose checks found
+	// a problem, like a failed conformance test or a regression.
+	ExitAssertionFailure = 5
+)
+
+// ExitError is an error with the exit code of its type.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigError marks the error as an invalid flag, argument, or config file.
+// Errors of unreachable endpoints keep their type, since checking the flags
+// can involve connecting to the endpoint.
+func ConfigError(err error) error {
+	if err == nil || isRPCUnreachable(err) {
+		return err
+	}
+	return withExitCode(ExitConfig, err)
+}
+
+// PartialFailure marks the error as some of the work of a command failing.
+func PartialFailure(err error) error {
+	return withExitCode(ExitPartialFailure, err)
+}
+
+// AssertionFailure marks the error as a check of a command failing.
+func AssertionFailure(err error) error {
+	return withExitCode(ExitAssertionFailure, err)
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// ExitCode returns the exit code of the error. Errors of unreachable
+// endpoints are found by their type, so they don't need to be marked.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	if isRPCUnreachable(err) {
+		return ExitRPCUnreachable
+	}
+	return ExitFailure
+}
+
+// isRPCUnreachable returns whether the error is a failed connection to the
+// endpoint or a gateway in front of it that couldn't reach the node.
+func isRPCUnreachable(err error) bool {
+	// The deadline of a context is a net.Error as well, but it's only a
+	// connection failure when a request to the endpoint wraps it.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr != context.DeadlineExceeded {
+		return true
+	}
+	var httpErr ethrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
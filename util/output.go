@@ -0,0 +1,78 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// jsonOutput is set with --json.
+var jsonOutput bool
+
+// SetJSONOutput sets whether the commands print their results as JSON.
+func SetJSONOutput(enabled bool) {
+	jsonOutput = enabled
+}
+
+// JSONOutput returns true if the commands print their results as JSON, so
+// they can be parsed by scripts. The logs are still written to stderr.
+func JSONOutput() bool {
+	return jsonOutput
+}
+
+// PrintJSON prints the result as indented JSON to stdout. The fields of the
+// results are part of their schema, so they're only ever added to.
+func PrintJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// UseJSONFormat sets the flag that selects the format of the results of a
+// command to its JSON format when the results are printed as JSON. It's an
+// error to select another format along with --json.
+func UseJSONFormat(flags *pflag.FlagSet, name, format string) error {
+	if !jsonOutput {
+		return nil
+	}
+	f := flags.Lookup(name)
+	if f == nil {
+		return fmt.Errorf("the command doesn't have a --%s flag", name)
+	}
+	if f.Changed && f.Value.String() != format {
+		return fmt.Errorf("--json can't be used with --%s %s", name, f.Value.String())
+	}
+	return flags.Set(name, format)
+}
+
+// PrintSection prints the heading of a section of the text output.
+func PrintSection(name string) {
+	fmt.Printf("\n%s\n", name)
+}
+
+// PrintField prints a field of a section of the text output.
+func PrintField(name, value string) {
+	fmt.Printf("  %-18s %s\n", name+":", value)
+}
+
+// FormatWei formats the amount of wei in the unit with the given decimals,
+// followed by the raw amount.
+func FormatWei(wei *big.Int, unit string, decimals int) string {
+	f := new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	return fmt.Sprintf("%s %s (%s wei)", strings.TrimRight(strings.TrimRight(f.Text('f', decimals), "0"), "."), unit, wei)
+}
+
+// FormatGwei formats the amount of wei in gwei, or ? if it isn't known.
+func FormatGwei(wei *big.Int) string {
+	if wei == nil {
+		return "?"
+	}
+	f := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e9))
+	return strings.TrimRight(strings.TrimRight(f.Text('f', 9), "0"), ".") + "gwei"
+}
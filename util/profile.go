@@ -0,0 +1,37 @@
+package util
+
+import "github.com/spf13/pflag"
+
+// profileRPCURL is the rpc-url of the profile selected with --profile.
+var profileRPCURL string
+
+// SetProfileRPCURL sets the endpoint of the profile, which the commands that
+// take the endpoint as an argument use when they're run without one.
+func SetProfileRPCURL(url string) {
+	profileRPCURL = url
+}
+
+// WithProfileRPCURL returns the arguments, or the endpoint of the profile if
+// there are none.
+func WithProfileRPCURL(args []string) []string {
+	if len(args) == 0 && profileRPCURL != "" {
+		return []string{profileRPCURL}
+	}
+	return args
+}
+
+// profileFlags are the flags set by the profile. They aren't marked as
+// changed, so the checks of the flags given on the command line, like the
+// one of --json, ignore them.
+var profileFlags = make(map[*pflag.Flag]bool)
+
+// MarkProfileFlag records that the flag was set by the profile.
+func MarkProfileFlag(f *pflag.Flag) {
+	profileFlags[f] = true
+}
+
+// Changed tells if the flag was set on the command line or by the profile.
+func Changed(flags *pflag.FlagSet, name string) bool {
+	f := flags.Lookup(name)
+	return f != nil && (f.Changed || profileFlags[f])
+}
@@ -0,0 +1,168 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// RPCAuth is how the commands authenticate to the RPC endpoints.
+type RPCAuth struct {
+	// CertFile and KeyFile are the client certificate and key of mutual
+	// TLS, and CAFile the certificates of the authorities the server
+	// certificate is checked against instead of the system ones.
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// Token is sent as a bearer token.
+	Token string
+	// JWTSecretFile is the hex encoded secret of engine API style JWTs. A
+	// new token is signed for every request, since they're only valid for a
+	// minute.
+	JWTSecretFile string
+}
+
+var (
+	rpcTLSConfig *tls.Config
+	rpcToken     string
+	rpcJWTSecret []byte
+)
+
+// SetRPCAuth loads the certificates and secrets used with the RPC
+// endpoints. They're only used with the RPC clients, so they aren't sent
+// to the other servers the commands talk to.
+func SetRPCAuth(a RPCAuth) error {
+	if (a.CertFile == "") != (a.KeyFile == "") {
+		return fmt.Errorf("the client certificate and key need to be set together")
+	}
+	if a.Token != "" && a.JWTSecretFile != "" {
+		return fmt.Errorf("only one of the bearer token and the jwt secret can be set")
+	}
+	if a.CertFile != "" || a.CAFile != "" {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if a.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile)
+			if err != nil {
+				return fmt.Errorf("unable to load the client certificate: %w", err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		if a.CAFile != "" {
+			pem, err := os.ReadFile(a.CAFile)
+			if err != nil {
+				return fmt.Errorf("unable to read the ca certificates: %w", err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates found in %s", a.CAFile)
+			}
+		}
+		rpcTLSConfig = config
+	}
+	rpcToken = a.Token
+	if a.JWTSecretFile != "" {
+		data, err := os.ReadFile(a.JWTSecretFile)
+		if err != nil {
+			return fmt.Errorf("unable to read the jwt secret: %w", err)
+		}
+		secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+		if err != nil || len(secret) != 32 {
+			return fmt.Errorf("the jwt secret needs to be 32 hex encoded bytes")
+		}
+		rpcJWTSecret = secret
+	}
+	return nil
+}
+
+// RPCTLSConfig returns the TLS config of the RPC endpoints, or nil to use
+// the default one.
+func RPCTLSConfig() *tls.Config {
+	if rpcTLSConfig == nil {
+		return nil
+	}
+	return rpcTLSConfig.Clone()
+}
+
+// hasRPCToken is true if the requests to the RPC endpoints need an
+// Authorization header.
+func hasRPCToken() bool {
+	return rpcToken != "" || rpcJWTSecret != nil
+}
+
+// getRPCAuthorization returns the value of the Authorization header.
+func getRPCAuthorization() (string, error) {
+	if rpcToken != "" {
+		return "Bearer " + rpcToken, nil
+	}
+	token, err := newEngineJWT(rpcJWTSecret, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+// newEngineJWT signs a token with the issued at claim, which is what the
+// engine API checks.
+func newEngineJWT(secret []byte, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := enc.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d}`, now.Unix())))
+	mac := hmac.New(sha256.New, secret)
+	if _, err := mac.Write([]byte(header + "." + claims)); err != nil {
+		return "", err
+	}
+	return header + "." + claims + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
+
+// rpcAuthTransport adds the Authorization header to the requests.
+type rpcAuthTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rpcAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authorization, err := getRPCAuthorization()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", authorization)
+	return t.base.RoundTrip(req)
+}
+
+// newRPCHTTPClient returns a client for the RPC endpoints that uses the
+// TLS config and sends the tokens. The transport of the given client is
+// used underneath, or the default transport if it has none.
+func newRPCHTTPClient(hc *http.Client) *http.Client {
+	client := new(http.Client)
+	if hc != nil {
+		*client = *hc
+	}
+	if client.Transport == nil && rpcTLSConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = RPCTLSConfig()
+		client.Transport = transport
+	}
+	if hasRPCToken() {
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		client.Transport = &rpcAuthTransport{base: base}
+	}
+	if tracerProvider != nil {
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		client.Transport = &rpcTraceTransport{base: base}
+	}
+	return client
+}
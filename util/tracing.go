@@ -0,0 +1,180 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of the spans of polycli.
+const tracerName = "github.com/maticnetwork/polygon-cli"
+
+var (
+	// tracerProvider exports the spans to the endpoint set with
+	// --otlp-endpoint, or is nil if the spans aren't exported.
+	tracerProvider *sdktrace.TracerProvider
+	// commandSpan is the span of the whole command.
+	commandSpan trace.Span
+)
+
+// SetTracing exports the spans of the command to an OTLP HTTP endpoint,
+// e.g. http://localhost:4318, and starts the span of the command. The
+// exporter also reads the standard OTEL_EXPORTER_OTLP_* environment
+// variables, like the headers, and the sampler is set with
+// OTEL_TRACES_SAMPLER.
+func SetTracing(ctx context.Context, endpoint, command string) (context.Context, error) {
+	if endpoint == "" {
+		return ctx, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ctx, fmt.Errorf("unable to parse the otlp endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ctx, fmt.Errorf("the otlp endpoint scheme %s is not supported, expected http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return ctx, fmt.Errorf("the otlp endpoint %s needs a host", endpoint)
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return ctx, fmt.Errorf("unable to create the otlp exporter: %w", err)
+	}
+
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String("polycli"))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx, commandSpan = Tracer().Start(ctx, command)
+	return ctx, nil
+}
+
+// ShutdownTracing ends the span of the command with its error and sends the
+// spans that weren't exported yet.
+func ShutdownTracing(err error) {
+	if tracerProvider == nil {
+		return
+	}
+	EndSpan(commandSpan, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = tracerProvider.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("Unable to export the remaining spans")
+	}
+}
+
+// Tracer returns the tracer of the spans of polycli. The spans are dropped
+// when tracing isn't set up, so they're cheap to start anywhere.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// EndSpan ends the span and marks it as failed if there's an error.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// rpcTraceTransport records a span for every JSON-RPC request and passes
+// the trace on to the endpoint in the traceparent header, so the spans of
+// nodes that trace their requests are part of the same trace.
+type rpcTraceTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rpcTraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is read for the methods and replaced by a copy.
+	var body []byte
+	hasBody := req.Body != nil
+	if hasBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	methods := rpcMethods(body)
+	name := "jsonrpc"
+	attrs := []attribute.KeyValue{semconv.RPCSystemKey.String("jsonrpc"), semconv.NetPeerNameKey.String(req.URL.Hostname())}
+	switch {
+	case len(methods) == 1:
+		name = methods[0]
+		attrs = append(attrs, semconv.RPCMethodKey.String(methods[0]))
+	case len(methods) > 1:
+		name = "batch"
+		attrs = append(attrs, attribute.StringSlice("rpc.methods", methods))
+	}
+	ctx, span := Tracer().Start(req.Context(), name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	req = req.Clone(ctx)
+	if hasBody {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		EndSpan(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	span.End()
+	return resp, nil
+}
+
+// rpcMethods returns the methods of a JSON-RPC request or batch.
+func rpcMethods(body []byte) []string {
+	type call struct {
+		Method string `json:"method"`
+	}
+	var calls []call
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		_ = json.Unmarshal(body, &calls)
+	} else {
+		var c call
+		if json.Unmarshal(body, &c) == nil {
+			calls = append(calls, c)
+		}
+	}
+	methods := make([]string, 0, len(calls))
+	for _, c := range calls {
+		methods = append(methods, c.Method)
+	}
+	return methods
+}
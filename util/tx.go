@@ -0,0 +1,21 @@
+package util
+
+// txTypeNames are the names of the known transaction envelopes.
+var txTypeNames = map[uint64]string{
+	0:    "legacy",
+	1:    "access list (EIP-2930)",
+	2:    "dynamic fee (EIP-1559)",
+	3:    "blob (EIP-4844)",
+	4:    "set code (EIP-7702)",
+	0x7e: "deposit (OP Stack)",
+	0x7f: "state sync (Bor)",
+}
+
+// TxTypeName returns the name of the transaction envelope, or unknown.
+func TxTypeName(txType uint64) string {
+	name, ok := txTypeNames[txType]
+	if !ok {
+		return "unknown"
+	}
+	return name
+}
@@ -3,6 +3,8 @@ package util
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math/big"
 	"strconv"
 
 	ethrpc "github.com/ethereum/go-ethereum/rpc"
@@ -155,3 +157,13 @@ func GetReceipts(ctx context.Context, rawBlocks []*json.RawMessage, c *ethrpc.Cl
 	log.Info().Int("hashes", len(txHashes)).Int("receipts", len(receipts)).Msg("Fetched tx receipts")
 	return receipts, nil
 }
+
+// ParseEther converts a decimal amount of ether to wei.
+func ParseEther(amount string) (*big.Int, error) {
+	f, ok := new(big.Float).SetPrec(256).SetString(amount)
+	if !ok || f.Sign() < 0 {
+		return nil, fmt.Errorf("the amount %s must be a non negative number of ether", amount)
+	}
+	wei, _ := f.Mul(f, new(big.Float).SetPrec(256).SetInt(big.NewInt(1e18))).Int(nil)
+	return wei, nil
+}